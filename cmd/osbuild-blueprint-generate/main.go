@@ -0,0 +1,165 @@
+// osbuild-blueprint-generate creates a blueprint reproducing an existing
+// system from its package list, e.g. the output of rpm -qa, or from the
+// packages installed by an osbuild manifest.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/osbuild/osbuild-composer/internal/blueprint"
+	"github.com/osbuild/osbuild-composer/internal/distro"
+	"github.com/osbuild/osbuild-composer/internal/distro/fedora32"
+	"github.com/osbuild/osbuild-composer/internal/distro/fedora33"
+	"github.com/osbuild/osbuild-composer/internal/distro/rhel8"
+	"github.com/osbuild/osbuild-composer/internal/distro/rhel84"
+	"github.com/osbuild/osbuild-composer/internal/osbuild"
+)
+
+// readPackageList reads one package per line
+func readPackageList(r io.Reader) ([]string, error) {
+	var packages []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		packages = append(packages, scanner.Text())
+	}
+	return packages, scanner.Err()
+}
+
+// readManifestPackages returns the NEVRAs of the packages installed into the
+// tree of an osbuild manifest, taken from the file names of their sources
+func readManifestPackages(r io.Reader) ([]string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var manifest osbuild.Manifest
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	files, ok := manifest.Sources["org.osbuild.files"].(*osbuild.FilesSource)
+	if !ok {
+		return nil, fmt.Errorf("the manifest has no package sources")
+	}
+
+	var packages []string
+	for _, stage := range manifest.Pipeline.Stages {
+		options, ok := stage.Options.(*osbuild.RPMStageOptions)
+		if !ok {
+			continue
+		}
+		for _, pkg := range options.Packages {
+			source, ok := files.URLs[pkg.Checksum]
+			if !ok {
+				return nil, fmt.Errorf("no source for package %s", pkg.Checksum)
+			}
+			packages = append(packages, strings.TrimSuffix(path.Base(source.URL), ".rpm"))
+		}
+	}
+	return packages, nil
+}
+
+// imageTypePackages returns the names of the packages an image type installs
+// for an empty blueprint
+func imageTypePackages(distroName, archName, imageTypeName string) ([]string, error) {
+	distros, err := distro.NewRegistry(fedora32.New(), fedora33.New(), rhel8.New(), rhel84.New(), rhel84.NewCentos())
+	if err != nil {
+		return nil, err
+	}
+	d := distros.GetDistro(distroName)
+	if d == nil {
+		return nil, fmt.Errorf("unknown distribution %s, use one of: %s", distroName, strings.Join(distros.List(), ", "))
+	}
+	arch, err := d.GetArch(archName)
+	if err != nil {
+		return nil, err
+	}
+	imageType, err := arch.GetImageType(imageTypeName)
+	if err != nil {
+		return nil, err
+	}
+	packages, _ := imageType.Packages(blueprint.Blueprint{})
+	return packages, nil
+}
+
+func main() {
+	var nameArg string
+	flag.StringVar(&nameArg, "name", "generated", "name of the blueprint")
+	var manifestArg bool
+	flag.BoolVar(&manifestArg, "manifest", false, "read an osbuild manifest instead of a package list")
+	var pinArg bool
+	flag.BoolVar(&pinArg, "pin", false, "pin packages to their versions")
+	var distroArg, archArg, imageTypeArg string
+	flag.StringVar(&distroArg, "distro", "", "leave out the packages the image type of this distribution installs anyway")
+	flag.StringVar(&archArg, "arch", "x86_64", "architecture of the image type given with -image-type")
+	flag.StringVar(&imageTypeArg, "image-type", "", "image type, see -distro")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] [file]\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Reads a package list, one name or NEVRA per line like printed by rpm -qa, or\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "'dnf repoquery --userinstalled' for a shorter blueprint, from file or stdin.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if (distroArg == "") != (imageTypeArg == "") {
+		fmt.Fprintln(os.Stderr, "-distro and -image-type must be given together")
+		os.Exit(2)
+	}
+
+	var input io.Reader = os.Stdin
+	if name := flag.Arg(0); name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not open input: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	var packages []string
+	var err error
+	if manifestArg {
+		packages, err = readManifestPackages(input)
+	} else {
+		packages, err = readPackageList(input)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not read packages: %v\n", err)
+		os.Exit(1)
+	}
+
+	var skip []string
+	if distroArg != "" {
+		skip, err = imageTypePackages(distroArg, archArg, imageTypeArg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not get the packages of the image type: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	bp, err := blueprint.NewFromPackages(nameArg, packages, pinArg, skip)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not create blueprint: %v\n", err)
+		os.Exit(1)
+	}
+
+	encoder := toml.NewEncoder(os.Stdout)
+	encoder.Indent = ""
+	err = encoder.Encode(bp)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not write blueprint: %v\n", err)
+		os.Exit(1)
+	}
+}
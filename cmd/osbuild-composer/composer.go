@@ -5,19 +5,25 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"path"
+	"time"
 
+	"github.com/osbuild/osbuild-composer/internal/auth"
+	"github.com/osbuild/osbuild-composer/internal/blueprint"
+	"github.com/osbuild/osbuild-composer/internal/certs"
 	"github.com/osbuild/osbuild-composer/internal/cloudapi"
 	"github.com/osbuild/osbuild-composer/internal/common"
+	"github.com/osbuild/osbuild-composer/internal/jobqueue"
+	"github.com/osbuild/osbuild-composer/internal/jobqueue/encryptedjobqueue"
 	"github.com/osbuild/osbuild-composer/internal/jobqueue/fsjobqueue"
 	"github.com/osbuild/osbuild-composer/internal/kojiapi"
 	"github.com/osbuild/osbuild-composer/internal/rpmmd"
 	"github.com/osbuild/osbuild-composer/internal/store"
+	"github.com/osbuild/osbuild-composer/internal/webhook"
 	"github.com/osbuild/osbuild-composer/internal/weldr"
 	"github.com/osbuild/osbuild-composer/internal/worker"
 
@@ -28,6 +34,14 @@ import (
 	"github.com/osbuild/osbuild-composer/internal/distro/rhel84"
 )
 
+// blueprintLibrarySyncInterval is how often the blueprint library directory
+// is checked for changes
+const blueprintLibrarySyncInterval = time.Minute
+
+// retentionInterval is how often composes exceeding the retention policy are
+// deleted
+const retentionInterval = 10 * time.Minute
+
 type Composer struct {
 	config   *ComposerConfigFile
 	stateDir string
@@ -42,7 +56,11 @@ type Composer struct {
 	api     *cloudapi.Server
 	koji    *kojiapi.Server
 
-	weldrListener, localWorkerListener, workerListener, apiListener net.Listener
+	// apiAuth authenticates the clients of the cloud API with bearer
+	// tokens, if they are configured
+	apiAuth *auth.Middleware
+
+	weldrListener, remoteWeldrListener, localWorkerListener, workerListener, apiListener net.Listener
 }
 
 func NewComposer(config *ComposerConfigFile, stateDir, cacheDir string, logger *log.Logger) (*Composer, error) {
@@ -68,15 +86,41 @@ func NewComposer(config *ComposerConfigFile, stateDir, cacheDir string, logger *
 		return nil, fmt.Errorf("Error loading distros: %v", err)
 	}
 
-	c.rpm = rpmmd.NewRPMMD(path.Join(c.cacheDir, "rpmmd"), "/usr/libexec/osbuild-composer/dnf-json")
+	c.rpm = rpmmd.NewRPMMDService(path.Join(c.cacheDir, "rpmmd"), "/usr/libexec/osbuild-composer/dnf-json", path.Join(c.cacheDir, "dnf-json.socket"))
+	c.rpm = rpmmd.WithDefaultProxy(c.rpm, config.Repositories.Proxy)
 
-	jobs, err := fsjobqueue.New(queueDir)
+	var jobs jobqueue.JobQueue
+	jobs, err = fsjobqueue.New(queueDir)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create jobqueue: %v", err)
 	}
 
+	// The arguments of jobs contain the credentials of upload targets
+	if keyFile := config.Secrets.KeyFile; keyFile != "" {
+		key, err := encryptedjobqueue.ReadKey(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the secrets key: %v", err)
+		}
+		jobs, err = encryptedjobqueue.New(jobs, key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create jobqueue: %v", err)
+		}
+	}
+
 	c.workers = worker.NewServer(c.logger, jobs, artifactsDir)
 
+	if len(config.Webhooks) > 0 {
+		var webhooks []webhook.Webhook
+		for _, w := range config.Webhooks {
+			webhooks = append(webhooks, webhook.Webhook{URL: w.URL, Secret: w.Secret, Events: w.Events})
+		}
+		notifier, err := webhook.NewNotifier(webhooks, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.workers.SetWebhooks(notifier)
+	}
+
 	return &c, nil
 }
 
@@ -115,22 +159,115 @@ func (c *Composer) InitWeldr(repoPaths []string, weldrListener net.Listener) err
 	store := store.New(&c.stateDir, arch, c.logger)
 	compatOutputDir := path.Join(c.stateDir, "outputs")
 
+	if dir := c.config.Weldr.BlueprintsDir; dir != "" {
+		c.syncBlueprintLibrary(store, dir)
+		go func() {
+			for range time.Tick(blueprintLibrarySyncInterval) {
+				c.syncBlueprintLibrary(store, dir)
+			}
+		}()
+	}
+
 	c.weldr = weldr.New(c.rpm, arch, hostDistro, repos[archName], c.logger, store, c.workers, compatOutputDir)
 
+	policy, err := c.retentionPolicy()
+	if err != nil {
+		return err
+	}
+	if policy.Enabled() {
+		go c.weldr.RunRetention(policy, retentionInterval)
+	}
+
 	c.weldrListener = weldrListener
 
 	return nil
 }
 
+// retentionPolicy returns the retention policy of weldr composes from the
+// configuration
+func (c *Composer) retentionPolicy() (weldr.RetentionPolicy, error) {
+	config := c.config.Weldr.Retention
+	policy := weldr.RetentionPolicy{
+		KeepLast: config.KeepLast,
+	}
+	var err error
+	if config.MaxAge != "" {
+		policy.MaxAge, err = time.ParseDuration(config.MaxAge)
+		if err != nil || policy.MaxAge <= 0 {
+			return policy, fmt.Errorf("Invalid weldr.retention.max_age %q, expected a duration like \"720h\"", config.MaxAge)
+		}
+	}
+	if config.MaxSize != "" {
+		policy.MaxSize, err = blueprint.ParseSize(config.MaxSize)
+		if err != nil {
+			return policy, fmt.Errorf("Invalid weldr.retention.max_size: %v", err)
+		}
+	}
+	if config.KeepLast < 0 {
+		return policy, fmt.Errorf("Invalid weldr.retention.keep_last %d", config.KeepLast)
+	}
+	return policy, nil
+}
+
+// syncBlueprintLibrary loads the blueprints of the library directory into
+// the store. Errors are only logged, because a broken file must not take
+// down the service.
+func (c *Composer) syncBlueprintLibrary(s *store.Store, dir string) {
+	err := s.SyncBlueprintLibrary(dir)
+	if err != nil {
+		log.Printf("Error loading blueprints from %s:\n%v", dir, err)
+	}
+}
+
+// InitRemoteWeldr serves the weldr API on l with TLS as well, which must be
+// called after InitWeldr. The weldr API has no other authentication, so
+// clients always need a certificate from the configured CA.
+func (c *Composer) InitRemoteWeldr(cert, key string, l net.Listener) error {
+	if len(c.config.Weldr.AllowedDomains) == 0 || c.config.Weldr.CA == "" {
+		return errors.New("the remote weldr API requires allowed_domains and ca to be set in the [weldr] section")
+	}
+
+	tlsConfig, err := createTLSConfig(&connectionConfig{
+		CACertFile:     c.config.Weldr.CA,
+		ServerKeyFile:  key,
+		ServerCertFile: cert,
+		AllowedDomains: c.config.Weldr.AllowedDomains,
+	})
+	if err != nil {
+		return fmt.Errorf("Error creating TLS configuration for remote weldr API: %v", err)
+	}
+
+	c.remoteWeldrListener = tls.NewListener(l, tlsConfig)
+
+	return nil
+}
+
 func (c *Composer) InitAPI(cert, key string, l net.Listener) error {
 	c.api = cloudapi.NewServer(c.workers, c.rpm, c.distros)
 	c.koji = kojiapi.NewServer(c.logger, c.workers, c.rpm, c.distros)
 
+	// Clients of the cloud API authenticate with bearer tokens instead of
+	// client certificates when tokens are configured. The other routes
+	// still require client certificates.
+	if jwt := c.config.CloudAPI.JWT; jwt.KeysURL != "" {
+		c.apiAuth = &auth.Middleware{
+			Validator: &auth.Validator{
+				Keys:     auth.NewKeySet(jwt.KeysURL, nil),
+				Issuer:   jwt.Issuer,
+				Audience: jwt.Audience,
+			},
+			IdentityClaim:    jwt.IdentityClaim,
+			TenantClaim:      jwt.TenantClaim,
+			AllowClientCerts: true,
+		}
+	}
+
 	tlsConfig, err := createTLSConfig(&connectionConfig{
-		CACertFile:     c.config.Koji.CA,
-		ServerKeyFile:  key,
-		ServerCertFile: cert,
-		AllowedDomains: c.config.Koji.AllowedDomains,
+		CACertFile:          c.config.Koji.CA,
+		ServerKeyFile:       key,
+		ServerCertFile:      cert,
+		AllowedDomains:      c.config.Koji.AllowedDomains,
+		OptionalClientCerts: c.apiAuth != nil,
 	})
 	if err != nil {
 		return fmt.Errorf("Error creating TLS configuration: %v", err)
@@ -148,6 +285,7 @@ func (c *Composer) InitLocalWorker(l net.Listener) {
 func (c *Composer) InitRemoteWorkers(cert, key string, l net.Listener) error {
 	tlsConfig, err := createTLSConfig(&connectionConfig{
 		CACertFile:     c.config.Worker.CA,
+		CRLFile:        c.config.Worker.CRL,
 		ServerKeyFile:  key,
 		ServerCertFile: cert,
 		AllowedDomains: c.config.Worker.AllowedDomains,
@@ -170,7 +308,7 @@ func (c *Composer) Start() error {
 		log.Fatal("neither the local worker socket nor the remote worker socket is enabled, osbuild-composer is useless without workers")
 	}
 
-	if c.apiListener == nil && c.weldrListener == nil {
+	if c.apiListener == nil && c.weldrListener == nil && c.remoteWeldrListener == nil {
 		log.Fatal("neither the weldr API socket nor the composer API socket is enabled, osbuild-composer is useless without one of these APIs enabled")
 	}
 
@@ -210,8 +348,15 @@ func (c *Composer) Start() error {
 			// Add a "/" here, because http.ServeMux expects the
 			// trailing slash for rooted subtrees, whereas the
 			// handler functions don't.
-			mux.Handle(apiRoute+"/", c.api.Handler(apiRoute))
-			mux.Handle(kojiRoute+"/", c.koji.Handler(kojiRoute))
+			if c.apiAuth != nil {
+				mux.Handle(apiRoute+"/", c.apiAuth.Handler(c.api.Handler(apiRoute)))
+				mux.Handle(kojiRoute+"/", auth.RequireClientCert(c.koji.Handler(kojiRoute)))
+				mux.Handle("/metrics", auth.RequireClientCert(c.metricsHandler()))
+			} else {
+				mux.Handle(apiRoute+"/", c.api.Handler(apiRoute))
+				mux.Handle(kojiRoute+"/", c.koji.Handler(kojiRoute))
+				mux.Handle("/metrics", c.metricsHandler())
+			}
 
 			s := &http.Server{
 				ErrorLog: c.logger,
@@ -234,10 +379,34 @@ func (c *Composer) Start() error {
 		}()
 	}
 
+	if c.remoteWeldrListener != nil {
+		go func() {
+			err := c.weldr.Serve(c.remoteWeldrListener)
+			if err != nil {
+				panic(err)
+			}
+		}()
+	}
+
 	// wait indefinitely
 	select {}
 }
 
+// metricsHandler serves the metrics of depsolving, workers and composes in
+// the Prometheus text format
+func (c *Composer) metricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		err := rpmmd.WriteMetrics(w)
+		if err == nil {
+			err = c.workers.WriteMetrics(w)
+		}
+		if err != nil {
+			log.Printf("Error writing metrics: %v", err)
+		}
+	})
+}
+
 func (c *Composer) ensureStateDirectory(name string, perm os.FileMode) (string, error) {
 	d := path.Join(c.stateDir, name)
 
@@ -257,33 +426,59 @@ type connectionConfig struct {
 	ServerKeyFile  string
 	ServerCertFile string
 	AllowedDomains []string
+
+	// CRLFile is the certificate revocation list which client
+	// certificates are checked against, if it is set
+	CRLFile string
+
+	// OptionalClientCerts allows clients without certificates to connect,
+	// which need to be authenticated in another way. Certificates which
+	// are given are still verified.
+	OptionalClientCerts bool
 }
 
 func createTLSConfig(c *connectionConfig) (*tls.Config, error) {
-	var roots *x509.CertPool
+	keyPair, err := certs.NewKeyPair(c.ServerCertFile, c.ServerKeyFile)
+	if err != nil {
+		return nil, err
+	}
 
+	var roots *certs.CertPool
 	if c.CACertFile != "" {
-		caCertPEM, err := ioutil.ReadFile(c.CACertFile)
+		roots, err = certs.NewCertPool(c.CACertFile)
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		roots = x509.NewCertPool()
-		ok := roots.AppendCertsFromPEM(caCertPEM)
-		if !ok {
-			panic("failed to parse root certificate")
+	var crl *certs.RevocationList
+	if c.CRLFile != "" {
+		crl, err = certs.NewRevocationList(c.CRLFile)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	cert, err := tls.LoadX509KeyPair(c.ServerCertFile, c.ServerKeyFile)
-	if err != nil {
-		return nil, err
+	clientAuth := tls.RequireAndVerifyClientCert
+	if c.OptionalClientCerts {
+		clientAuth = tls.VerifyClientCertIfGiven
 	}
-	return &tls.Config{
-		Certificates: []tls.Certificate{cert},
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-		ClientCAs:    roots,
+
+	config := &tls.Config{
+		GetCertificate: keyPair.GetCertificate,
+		ClientAuth:     clientAuth,
 		VerifyPeerCertificate: func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 && c.OptionalClientCerts {
+				return nil
+			}
+
+			if crl != nil {
+				err := crl.Check(verifiedChains)
+				if err != nil {
+					return err
+				}
+			}
+
 			for _, chain := range verifiedChains {
 				for _, domain := range c.AllowedDomains {
 					if chain[0].VerifyHostname(domain) == nil {
@@ -294,5 +489,16 @@ func createTLSConfig(c *connectionConfig) (*tls.Config, error) {
 
 			return errors.New("domain not in allowlist")
 		},
-	}, nil
+	}
+
+	// the CAs can only be replaced in the configuration of each connection
+	if roots != nil {
+		config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			conf := config.Clone()
+			conf.ClientCAs = roots.Pool()
+			return conf, nil
+		}
+	}
+
+	return config, nil
 }
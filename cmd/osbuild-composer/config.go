@@ -14,7 +14,40 @@ type ComposerConfigFile struct {
 	Worker struct {
 		AllowedDomains []string `toml:"allowed_domains"`
 		CA             string   `toml:"ca"`
+		CRL            string   `toml:"crl"`
 	} `toml:"worker"`
+	CloudAPI struct {
+		JWT struct {
+			KeysURL       string `toml:"keys_url"`
+			Issuer        string `toml:"issuer"`
+			Audience      string `toml:"audience"`
+			IdentityClaim string `toml:"identity_claim"`
+			TenantClaim   string `toml:"tenant_claim"`
+		} `toml:"jwt"`
+	} `toml:"cloudapi"`
+	Weldr struct {
+		BlueprintsDir string `toml:"blueprints_dir"`
+		// AllowedDomains and CA are only used for the remote weldr
+		// socket, which does not start unless both are set
+		AllowedDomains []string `toml:"allowed_domains"`
+		CA             string   `toml:"ca"`
+		Retention      struct {
+			MaxAge   string `toml:"max_age"`
+			MaxSize  string `toml:"max_size"`
+			KeepLast int    `toml:"keep_last"`
+		} `toml:"retention"`
+	} `toml:"weldr"`
+	Repositories struct {
+		Proxy string `toml:"proxy"`
+	} `toml:"repositories"`
+	Secrets struct {
+		KeyFile string `toml:"key_file"`
+	} `toml:"secrets"`
+	Webhooks []struct {
+		URL    string   `toml:"url"`
+		Secret string   `toml:"secret"`
+		Events []string `toml:"events"`
+	} `toml:"webhooks"`
 }
 
 func LoadConfig(name string) (*ComposerConfigFile, error) {
@@ -15,6 +15,18 @@ func TestEmpty(t *testing.T) {
 	require.Empty(t, config.Koji.CA)
 	require.Empty(t, config.Worker.AllowedDomains)
 	require.Empty(t, config.Worker.CA)
+	require.Empty(t, config.Worker.CRL)
+	require.Empty(t, config.CloudAPI.JWT.KeysURL)
+	require.Empty(t, config.CloudAPI.JWT.TenantClaim)
+	require.Empty(t, config.Weldr.BlueprintsDir)
+	require.Empty(t, config.Weldr.AllowedDomains)
+	require.Empty(t, config.Weldr.CA)
+	require.Empty(t, config.Weldr.Retention.MaxAge)
+	require.Empty(t, config.Weldr.Retention.MaxSize)
+	require.Zero(t, config.Weldr.Retention.KeepLast)
+	require.Empty(t, config.Repositories.Proxy)
+	require.Empty(t, config.Secrets.KeyFile)
+	require.Empty(t, config.Webhooks)
 }
 
 func TestNonExisting(t *testing.T) {
@@ -34,4 +46,29 @@ func TestConfig(t *testing.T) {
 
 	require.Equal(t, config.Worker.AllowedDomains, []string{"osbuild.org"})
 	require.Equal(t, config.Worker.CA, "/etc/osbuild-composer/ca-crt.pem")
+	require.Equal(t, config.Worker.CRL, "/etc/osbuild-composer/ca-crl.pem")
+
+	require.Equal(t, config.CloudAPI.JWT.KeysURL, "https://sso.example.com/auth/realms/osbuild/protocol/openid-connect/certs")
+	require.Equal(t, config.CloudAPI.JWT.Issuer, "https://sso.example.com/auth/realms/osbuild")
+	require.Equal(t, config.CloudAPI.JWT.Audience, "osbuild-composer")
+	require.Equal(t, config.CloudAPI.JWT.IdentityClaim, "preferred_username")
+	require.Equal(t, config.CloudAPI.JWT.TenantClaim, "org_id")
+
+	require.Equal(t, config.Weldr.BlueprintsDir, "/etc/osbuild-composer/blueprints")
+	require.Equal(t, config.Weldr.AllowedDomains, []string{"osbuild.org"})
+	require.Equal(t, config.Weldr.CA, "/etc/osbuild-composer/ca-crt.pem")
+	require.Equal(t, config.Weldr.Retention.MaxAge, "720h")
+	require.Equal(t, config.Weldr.Retention.MaxSize, "100 GiB")
+	require.Equal(t, config.Weldr.Retention.KeepLast, 5)
+
+	require.Equal(t, config.Repositories.Proxy, "http://proxy.example.com:3128")
+
+	require.Equal(t, config.Secrets.KeyFile, "/etc/osbuild-composer/secrets.key")
+
+	require.Len(t, config.Webhooks, 2)
+	require.Equal(t, config.Webhooks[0].URL, "https://ci.example.com/hooks/composer")
+	require.Equal(t, config.Webhooks[0].Secret, "s3cr3t")
+	require.Equal(t, config.Webhooks[0].Events, []string{"compose.finished", "compose.failed"})
+	require.Equal(t, config.Webhooks[1].URL, "http://localhost:8080/events")
+	require.Empty(t, config.Webhooks[1].Events)
 }
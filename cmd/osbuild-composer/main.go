@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"log"
+	"net"
 	"os"
 
 	"github.com/coreos/go-systemd/activation"
@@ -64,17 +65,35 @@ func main() {
 		log.Fatalf("Could not get listening sockets: " + err.Error())
 	}
 
-	if l, exists := listeners["osbuild-composer.socket"]; exists {
-		if len(l) != 1 {
-			log.Fatal("The osbuild-composer.socket unit is misconfigured. It should contain only one socket.")
+	weldrListeners, weldrExists := listeners["osbuild-composer.socket"]
+	remoteWeldrListeners, remoteWeldrExists := listeners["osbuild-remote-weldr.socket"]
+
+	if weldrExists || remoteWeldrExists {
+		var l net.Listener
+		if weldrExists {
+			if len(weldrListeners) != 1 {
+				log.Fatal("The osbuild-composer.socket unit is misconfigured. It should contain only one socket.")
+			}
+			l = weldrListeners[0]
 		}
 
-		err = composer.InitWeldr(repositoryConfigs, l[0])
+		err = composer.InitWeldr(repositoryConfigs, l)
 		if err != nil {
 			log.Fatalf("Error initializing weldr API: %v", err)
 		}
 	}
 
+	if remoteWeldrExists {
+		if len(remoteWeldrListeners) != 1 {
+			log.Fatal("The osbuild-remote-weldr.socket unit is misconfigured. It should contain only one socket.")
+		}
+
+		err = composer.InitRemoteWeldr(ServerCertFile, ServerKeyFile, remoteWeldrListeners[0])
+		if err != nil {
+			log.Fatalf("Error initializing remote weldr API: %v", err)
+		}
+	}
+
 	if l, exists := listeners["osbuild-local-worker.socket"]; exists {
 		if len(l) != 1 {
 			log.Fatal("The osbuild-local-worker.socket unit is misconfigured. It should contain only one socket.")
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -8,6 +9,10 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
 
 	"github.com/osbuild/osbuild-composer/internal/distro/fedora32"
 	"github.com/osbuild/osbuild-composer/internal/distro/fedora33"
@@ -20,11 +25,17 @@ import (
 )
 
 type repository struct {
-	BaseURL    string `json:"baseurl,omitempty"`
-	Metalink   string `json:"metalink,omitempty"`
-	MirrorList string `json:"mirrorlist,omitempty"`
-	GPGKey     string `json:"gpgkey,omitempty"`
-	CheckGPG   bool   `json:"check_gpg,omitempty"`
+	BaseURL        string   `json:"baseurl,omitempty"`
+	Metalink       string   `json:"metalink,omitempty"`
+	MirrorList     string   `json:"mirrorlist,omitempty"`
+	GPGKey         string   `json:"gpgkey,omitempty"`
+	GPGKeyChecksum string   `json:"gpgkey_checksum,omitempty"`
+	CheckGPG       bool     `json:"check_gpg,omitempty"`
+	Proxy          string   `json:"proxy,omitempty"`
+	Priority       int      `json:"priority,omitempty"`
+	ModuleHotfixes bool     `json:"module_hotfixes,omitempty"`
+	IncludePkgs    []string `json:"includepkgs,omitempty"`
+	ExcludePkgs    []string `json:"excludepkgs,omitempty"`
 }
 
 type composeRequest struct {
@@ -36,9 +47,69 @@ type composeRequest struct {
 }
 
 type rpmMD struct {
-	BuildPackages []rpmmd.PackageSpec `json:"build-packages"`
-	Packages      []rpmmd.PackageSpec `json:"packages"`
-	Checksums     map[string]string   `json:"checksums"`
+	Blueprint     *blueprint.Blueprint `json:"blueprint,omitempty"`
+	Distro        string               `json:"distro,omitempty"`
+	Arch          string               `json:"arch,omitempty"`
+	ImageType     string               `json:"image-type,omitempty"`
+	BuildPackages []rpmmd.PackageSpec  `json:"build-packages"`
+	Packages      []rpmmd.PackageSpec  `json:"packages"`
+	Checksums     map[string]string    `json:"checksums"`
+	Modules       []rpmmd.ModuleState  `json:"modules,omitempty"`
+	Mirrors       map[string]string    `json:"mirrors,omitempty"`
+	Sources       []rpmmd.PackageSpec  `json:"sources,omitempty"`
+}
+
+// readBlueprint reads a blueprint from a file. TOML is used for files ending
+// in .toml, as written for the weldr API, JSON otherwise.
+func readBlueprint(name string) (blueprint.Blueprint, error) {
+	var bp blueprint.Blueprint
+	file, err := ioutil.ReadFile(name)
+	if err != nil {
+		return bp, err
+	}
+	if filepath.Ext(name) == ".toml" {
+		err = toml.Unmarshal(file, &bp)
+	} else {
+		err = json.Unmarshal(file, &bp)
+	}
+	if err != nil {
+		return bp, err
+	}
+	return bp, bp.Initialize()
+}
+
+// readResolved reads resolved packages, as written with -rpmmd or by the
+// blueprint export API of weldr
+func readResolved(name string) (*rpmMD, error) {
+	file, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var resolved rpmMD
+	err = json.Unmarshal(file, &resolved)
+	if err != nil {
+		return nil, err
+	}
+	if len(resolved.BuildPackages) == 0 {
+		return nil, fmt.Errorf("%s contains no build packages", name)
+	}
+	return &resolved, nil
+}
+
+// variables collects NAME=VALUE flags
+type variables map[string]string
+
+func (v variables) String() string {
+	return fmt.Sprint(map[string]string(v))
+}
+
+func (v variables) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("expected NAME=VALUE, got %q", value)
+	}
+	v[parts[0]] = parts[1]
+	return nil
 }
 
 func main() {
@@ -46,6 +117,16 @@ func main() {
 	flag.BoolVar(&rpmmdArg, "rpmmd", false, "output rpmmd struct instead of pipeline manifest")
 	var seedArg int64
 	flag.Int64Var(&seedArg, "seed", 0, "seed for generating manifests (default: 0)")
+	var blueprintArg string
+	flag.StringVar(&blueprintArg, "blueprint", "", "TOML or JSON blueprint file, overrides the blueprint of the compose request")
+	var resolvedArg string
+	flag.StringVar(&resolvedArg, "resolved", "", "JSON file with resolved packages as written with -rpmmd or by the blueprint export API, skips depsolving")
+	var repoDirArg string
+	flag.StringVar(&repoDirArg, "repo-dir", "", "directory with a subdirectory for each repository, with its repodata and packages, used instead of the repositories of the compose request for depsolving without network access")
+	var sourcesArg bool
+	flag.BoolVar(&sourcesArg, "sources", false, "also list the source packages of the image packages in the rpmmd struct")
+	variablesArg := variables{}
+	flag.Var(variablesArg, "var", "NAME=VALUE to substitute for ${NAME} in the blueprint, can be repeated")
 	flag.Parse()
 
 	// Path to composeRequet or '-' for stdin
@@ -73,6 +154,47 @@ func main() {
 		}
 	}
 
+	if blueprintArg != "" {
+		bp, err := readBlueprint(blueprintArg)
+		if err != nil {
+			panic("Could not read blueprint: " + err.Error())
+		}
+		composeRequest.Blueprint = bp
+	}
+
+	var resolved *rpmMD
+	if resolvedArg != "" {
+		if blueprintArg != "" {
+			panic("-blueprint cannot be used with -resolved")
+		}
+		var err error
+		resolved, err = readResolved(resolvedArg)
+		if err != nil {
+			panic("Could not read resolved packages: " + err.Error())
+		}
+		if resolved.Blueprint != nil {
+			composeRequest.Blueprint = *resolved.Blueprint
+		}
+		// The compose request is optional for resolved packages
+		if composeRequest.Distro == "" {
+			composeRequest.Distro = resolved.Distro
+		}
+		if composeRequest.Arch == "" {
+			composeRequest.Arch = resolved.Arch
+		}
+		if composeRequest.ImageType == "" {
+			composeRequest.ImageType = resolved.ImageType
+		}
+	}
+
+	if composeRequest.Blueprint.HasVariables() || len(variablesArg) > 0 {
+		bp, err := composeRequest.Blueprint.Expand(variablesArg)
+		if err != nil {
+			panic("Could not expand blueprint variables: " + err.Error())
+		}
+		composeRequest.Blueprint = bp
+	}
+
 	distros, err := distro.NewRegistry(fedora32.New(), fedora33.New(), rhel8.New(), rhel84.New(), rhel84.NewCentos())
 	if err != nil {
 		panic(err)
@@ -108,49 +230,89 @@ func main() {
 	repos := make([]rpmmd.RepoConfig, len(composeRequest.Repositories))
 	for i, repo := range composeRequest.Repositories {
 		repos[i] = rpmmd.RepoConfig{
-			Name:       fmt.Sprintf("repo-%d", i),
-			BaseURL:    repo.BaseURL,
-			Metalink:   repo.Metalink,
-			MirrorList: repo.MirrorList,
-			GPGKey:     repo.GPGKey,
-			CheckGPG:   repo.CheckGPG,
+			Name:           fmt.Sprintf("repo-%d", i),
+			BaseURL:        repo.BaseURL,
+			Metalink:       repo.Metalink,
+			MirrorList:     repo.MirrorList,
+			GPGKey:         repo.GPGKey,
+			GPGKeyChecksum: repo.GPGKeyChecksum,
+			CheckGPG:       repo.CheckGPG,
+			Proxy:          repo.Proxy,
+			Priority:       repo.Priority,
+			ModuleHotfixes: repo.ModuleHotfixes,
+			IncludePkgs:    repo.IncludePkgs,
+			ExcludePkgs:    repo.ExcludePkgs,
 		}
 	}
 
-	packages, excludePkgs := imageType.Packages(composeRequest.Blueprint)
+	if repoDirArg != "" {
+		repos, err = rpmmd.LocalRepositories(repoDirArg)
+		if err != nil {
+			panic("Could not read local repositories: " + err.Error())
+		}
+	}
 
 	home, err := os.UserHomeDir()
 	if err != nil {
 		panic("os.UserHomeDir(): " + err.Error())
 	}
+	rpm := rpmmd.NewRPMMD(path.Join(home, ".cache/osbuild-composer/rpmmd"), "/usr/libexec/osbuild-composer/dnf-json")
 
-	rpmmd := rpmmd.NewRPMMD(path.Join(home, ".cache/osbuild-composer/rpmmd"), "/usr/libexec/osbuild-composer/dnf-json")
-	packageSpecs, checksums, err := rpmmd.Depsolve(packages, excludePkgs, repos, d.ModulePlatformID(), arch.Name())
-	if err != nil {
-		panic("Could not depsolve: " + err.Error())
-	}
+	var packageSpecs, buildPackageSpecs []rpmmd.PackageSpec
+	var checksums map[string]string
+	var modules []rpmmd.ModuleState
+	var mirrors map[string]string
+	var sources []rpmmd.PackageSpec
+	if resolved != nil {
+		packageSpecs = resolved.Packages
+		buildPackageSpecs = resolved.BuildPackages
+		checksums = resolved.Checksums
+		modules = resolved.Modules
+		mirrors = resolved.Mirrors
+		sources = resolved.Sources
+	} else {
+		packages, excludePkgs := imageType.Packages(composeRequest.Blueprint)
 
-	buildPkgs := imageType.BuildPackages()
-	buildPackageSpecs, _, err := rpmmd.Depsolve(buildPkgs, nil, repos, d.ModulePlatformID(), arch.Name())
-	if err != nil {
-		panic("Could not depsolve build packages: " + err.Error())
+		results, err := rpm.DepsolvePackageSets(context.Background(), map[string]rpmmd.PackageSet{
+			rpmmd.OSPackageSet:    {Include: packages, Exclude: excludePkgs, Modules: composeRequest.Blueprint.GetModuleStreams(), NoWeakDeps: !imageType.InstallWeakDeps(), WithSources: sourcesArg},
+			rpmmd.BuildPackageSet: {Include: imageType.BuildPackages()},
+		}, repos, d.ModulePlatformID(), arch.Name())
+		if err != nil {
+			panic("Could not depsolve: " + err.Error())
+		}
+		osResult := results[rpmmd.OSPackageSet]
+		packageSpecs, checksums, modules, mirrors, sources = osResult.Packages, osResult.Checksums, osResult.Modules, osResult.Mirrors, osResult.SourcePackages
+		buildPackageSpecs = results[rpmmd.BuildPackageSet].Packages
 	}
 
 	var bytes []byte
 	if rpmmdArg {
 		rpmMDInfo := rpmMD{
+			Blueprint:     &composeRequest.Blueprint,
+			Distro:        d.Name(),
+			Arch:          arch.Name(),
+			ImageType:     imageType.Name(),
 			BuildPackages: buildPackageSpecs,
 			Packages:      packageSpecs,
 			Checksums:     checksums,
+			Modules:       modules,
+			Mirrors:       mirrors,
+			Sources:       sources,
 		}
 		bytes, err = json.Marshal(rpmMDInfo)
 		if err != nil {
 			panic(err)
 		}
 	} else {
+		repos, err = rpm.ResolveGPGKeys(context.Background(), repos)
+		if err != nil {
+			panic("Could not fetch GPG keys: " + err.Error())
+		}
+
 		manifest, err := imageType.Manifest(composeRequest.Blueprint.Customizations,
 			distro.ImageOptions{
-				Size: imageType.Size(0),
+				Size:           imageType.Size(0),
+				EnabledModules: composeRequest.Blueprint.EnabledModules,
 			},
 			repos,
 			packageSpecs,
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"path"
 	"time"
@@ -18,11 +19,11 @@ import (
 
 func getManifest(bp blueprint.Blueprint, t distro.ImageType, a distro.Arch, d distro.Distro, rpmmd rpmmd.RPMMD, repos []rpmmd.RepoConfig) distro.Manifest {
 	packages, excludePackages := t.Packages(bp)
-	pkgs, _, err := rpmmd.Depsolve(packages, excludePackages, repos, d.ModulePlatformID(), a.Name())
+	pkgs, _, err := rpmmd.Depsolve(context.Background(), packages, excludePackages, nil, repos, d.ModulePlatformID(), a.Name())
 	if err != nil {
 		panic(err)
 	}
-	buildPkgs, _, err := rpmmd.Depsolve(t.BuildPackages(), nil, repos, d.ModulePlatformID(), a.Name())
+	buildPkgs, _, err := rpmmd.Depsolve(context.Background(), t.BuildPackages(), nil, nil, repos, d.ModulePlatformID(), a.Name())
 	if err != nil {
 		panic(err)
 	}
@@ -157,6 +158,7 @@ func main() {
 	}
 	err = s.PushCompose(id1,
 		getManifest(bp2, t1, a, d, rpmmd, repos),
+		nil,
 		t1,
 		&bp2,
 		0,
@@ -170,6 +172,7 @@ func main() {
 	}
 	err = s.PushCompose(id2,
 		getManifest(bp2, t2, a, d, rpmmd, repos),
+		nil,
 		t2,
 		&bp2,
 		0,
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -23,29 +24,80 @@ func main() {
 	var fileName string
 	var containerName string
 	var threads int
+	var resourceGroup string
+	var location string
+	var subscriptionID string
+	var tenantID string
+	var clientID string
+	var clientSecret string
+	var cloudName string
 	flag.StringVar(&storageAccount, "storage-account", "", "Azure storage account (mandatory)")
 	flag.StringVar(&storageAccessKey, "storage-access-key", "", "Azure storage access key (mandatory)")
 	flag.StringVar(&fileName, "image", "", "image to upload (mandatory)")
 	flag.StringVar(&containerName, "container", "", "name of storage container (see Azure docs for explanation, mandatory)")
 	flag.IntVar(&threads, "threads", 16, "number of threads for parallel upload")
+	flag.StringVar(&resourceGroup, "resource-group", "", "resource group to register a managed image in (optional)")
+	flag.StringVar(&location, "location", "", "location of the managed image (mandatory with -resource-group)")
+	flag.StringVar(&subscriptionID, "subscription-id", "", "Azure subscription ID (mandatory with -resource-group)")
+	flag.StringVar(&tenantID, "tenant-id", "", "Azure tenant ID of the service principal (mandatory with -resource-group)")
+	flag.StringVar(&clientID, "client-id", "", "client ID of the service principal (mandatory with -resource-group)")
+	flag.StringVar(&clientSecret, "client-secret", "", "client secret of the service principal (mandatory with -resource-group)")
+	flag.StringVar(&cloudName, "cloud", "", "Azure cloud, e.g. AzureUSGovernmentCloud or AzureChinaCloud (default is the public cloud)")
 	flag.Parse()
 
 	checkStringNotEmpty(storageAccount, "You need to specify storage account")
 	checkStringNotEmpty(storageAccessKey, "You need to specify storage access key")
 	checkStringNotEmpty(fileName, "You need to specify image file")
 	checkStringNotEmpty(containerName, "You need to specify container name")
+	if resourceGroup != "" {
+		checkStringNotEmpty(location, "You need to specify location")
+		checkStringNotEmpty(subscriptionID, "You need to specify subscription ID")
+		checkStringNotEmpty(tenantID, "You need to specify tenant ID")
+		checkStringNotEmpty(clientID, "You need to specify client ID")
+		checkStringNotEmpty(clientSecret, "You need to specify client secret")
+	}
+
+	cloud, err := azure.NewCloud(cloudName, azure.Cloud{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
 	fmt.Println("Image to upload is:", fileName)
 
-	err := azure.UploadImage(azure.Credentials{
+	credentials := azure.Credentials{
 		StorageAccount:   storageAccount,
 		StorageAccessKey: storageAccessKey,
-	}, azure.ImageMetadata{
+		Cloud:            cloud,
+	}
+	metadata := azure.ImageMetadata{
 		ImageName:     path.Base(fileName),
 		ContainerName: containerName,
-	}, fileName, threads)
+	}
+	err = azure.UploadImage(credentials, metadata, fileName, threads, nil, nil)
+	if err != nil {
+		fmt.Println("Error: ", err)
+		return
+	}
 
+	if resourceGroup == "" {
+		return
+	}
+	imageID, err := azure.RegisterImage(context.Background(), azure.ClientCredentials{
+		SubscriptionID: subscriptionID,
+		TenantID:       tenantID,
+		ClientID:       clientID,
+		ClientSecret:   clientSecret,
+		Cloud:          cloud,
+	}, azure.ImageRegistration{
+		ResourceGroup: resourceGroup,
+		Location:      location,
+		ImageName:     path.Base(fileName),
+		BlobURL:       azure.BlobURL(credentials, metadata),
+	})
 	if err != nil {
 		fmt.Println("Error: ", err)
+		return
 	}
+	fmt.Println("Registered image:", imageID)
 }
@@ -163,6 +163,18 @@ func (impl *KojiFinalizeJobImpl) Run(job worker.Job) error {
 				},
 			},
 		})
+		if i < len(args.KojiLogFilenames) && buildArgs.LogHash != "" {
+			images = append(images, koji.Image{
+				BuildRootID:  uint64(i),
+				Filename:     args.KojiLogFilenames[i],
+				FileSize:     buildArgs.LogSize,
+				Arch:         buildArgs.Arch,
+				ChecksumType: "md5",
+				MD5:          buildArgs.LogHash,
+				Type:         "log",
+				RPMs:         []koji.RPM{},
+			})
+		}
 	}
 
 	var result worker.KojiFinalizeJobResult
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"crypto/tls"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -21,7 +23,7 @@ type OSBuildKojiJobImpl struct {
 	KojiServers map[string]koji.GSSAPICredentials
 }
 
-func (impl *OSBuildKojiJobImpl) kojiUpload(file *os.File, server, directory, filename string) (string, uint64, error) {
+func (impl *OSBuildKojiJobImpl) kojiUpload(file io.Reader, server, directory, filename string) (string, uint64, error) {
 	// Koji for some reason needs TLS renegotiation enabled.
 	// Clone the default http transport and enable renegotiation.
 	transport := http.DefaultTransport.(*http.Transport).Clone()
@@ -98,6 +100,16 @@ func (impl *OSBuildKojiJobImpl) Run(job worker.Job) error {
 			result.ImageHash, result.ImageSize, err = impl.kojiUpload(f, args.KojiServer, args.KojiDirectory, args.KojiFilename)
 			if err != nil {
 				result.KojiError = err.Error()
+			} else if args.KojiLogFilename != "" {
+				// the log is imported into the build together with the image
+				var osbuildLog bytes.Buffer
+				err = result.OSBuildOutput.Write(&osbuildLog)
+				if err == nil {
+					result.LogHash, result.LogSize, err = impl.kojiUpload(&osbuildLog, args.KojiServer, args.KojiDirectory, args.KojiLogFilename)
+				}
+				if err != nil {
+					result.KojiError = err.Error()
+				}
 			}
 		}
 	}
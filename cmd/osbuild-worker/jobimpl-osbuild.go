@@ -1,24 +1,40 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/osbuild/osbuild-composer/internal/artifact"
 	"github.com/osbuild/osbuild-composer/internal/common"
 	"github.com/osbuild/osbuild-composer/internal/distro"
 	"github.com/osbuild/osbuild-composer/internal/osbuild"
 	"github.com/osbuild/osbuild-composer/internal/target"
 	"github.com/osbuild/osbuild-composer/internal/upload/awsupload"
 	"github.com/osbuild/osbuild-composer/internal/upload/azure"
+	"github.com/osbuild/osbuild-composer/internal/upload/gcp"
+	"github.com/osbuild/osbuild-composer/internal/upload/hook"
 	"github.com/osbuild/osbuild-composer/internal/upload/koji"
+	"github.com/osbuild/osbuild-composer/internal/upload/libvirt"
+	"github.com/osbuild/osbuild-composer/internal/upload/oci"
+	"github.com/osbuild/osbuild-composer/internal/upload/plugin"
+	"github.com/osbuild/osbuild-composer/internal/upload/progress"
+	"github.com/osbuild/osbuild-composer/internal/upload/pulp"
+	"github.com/osbuild/osbuild-composer/internal/upload/throttle"
 	"github.com/osbuild/osbuild-composer/internal/upload/vmware"
 	"github.com/osbuild/osbuild-composer/internal/worker"
 )
@@ -26,6 +42,24 @@ import (
 type OSBuildJobImpl struct {
 	Store       string
 	KojiServers map[string]koji.GSSAPICredentials
+	// ExportDir is the directory artifacts of export targets are copied
+	// to. Export targets fail when it is empty.
+	ExportDir string
+	// UploadLimiter limits the bandwidth of all uploads to cloud targets
+	// together, unless it is nil
+	UploadLimiter *throttle.Limiter
+	// Hooks are the commands hook targets can run, by name
+	Hooks map[string][]string
+	// LibvirtHosts are the URIs of the hosts libvirt targets import images
+	// into, by name
+	LibvirtHosts map[string]string
+	// DomainTemplates are the templates libvirt targets can define domains
+	// with, by name
+	DomainTemplates map[string]*template.Template
+	// Plugins are the executables plugin targets upload with, by name
+	Plugins map[string]*plugin.Plugin
+	// Signer signs the artifacts and their checksums, unless it is nil
+	Signer *artifact.Signer
 }
 
 func packageMetadataToSignature(pkg osbuild.RPMPackageMetadata) *string {
@@ -61,6 +95,141 @@ func osbuildStagesToRPMs(stages []osbuild.StageResult) []koji.RPM {
 	return rpms
 }
 
+// How often the progress of an upload is reported to composer
+const progressInterval = 5 * time.Second
+
+// How long the download URLs of images which are kept in object storage are
+// valid
+const downloadURLExpiry = 24 * time.Hour
+
+// reportProgress returns a progress.Func which reports the progress of an
+// upload to a target back to composer, at most every progressInterval and
+// when the upload is complete. Failed reports are only logged.
+func reportProgress(job worker.Job, t *target.Target) progress.Func {
+	var mu sync.Mutex
+	var last time.Time
+	return func(transferred, total int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		if transferred < total && time.Since(last) < progressInterval {
+			return
+		}
+		last = time.Now()
+		err := job.UpdateProgress(worker.UploadProgress{
+			Target:      t.Name,
+			TargetID:    t.Uuid,
+			Transferred: transferred,
+			Total:       total,
+		})
+		if err != nil {
+			log.Printf("Error reporting upload progress: %v", err)
+		}
+	}
+}
+
+// exportArtifact copies the artifact at src to filename in dir and returns the
+// path of the copy. The copy is written to a temporary file first, so that a
+// partially written artifact never shows up under its final name.
+func exportArtifact(src, dir, filename string) (string, error) {
+	if filename == "" || filename != filepath.Base(filename) || filename == "." || filename == ".." {
+		return "", fmt.Errorf("invalid export filename: %q", filename)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := ioutil.TempFile(dir, ".export-*")
+	if err != nil {
+		return "", fmt.Errorf("error creating file in export directory: %v", err)
+	}
+	defer func() {
+		// fails harmlessly after the rename
+		_ = os.Remove(out.Name())
+	}()
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return "", fmt.Errorf("error copying artifact to export directory: %v", err)
+	}
+	err = out.Close()
+	if err != nil {
+		return "", fmt.Errorf("error copying artifact to export directory: %v", err)
+	}
+
+	// ioutil.TempFile creates files readable by the owner only
+	err = os.Chmod(out.Name(), 0644)
+	if err != nil {
+		return "", err
+	}
+
+	exportPath := filepath.Join(dir, filename)
+	err = os.Rename(out.Name(), exportPath)
+	if err != nil {
+		return "", fmt.Errorf("error moving artifact into export directory: %v", err)
+	}
+
+	return exportPath, nil
+}
+
+// checksumArtifacts writes SHA256SUMS next to the artifacts in dir and, if
+// signer is not nil, signs the checksums and each artifact
+func checksumArtifacts(dir string, signer *artifact.Signer) (*worker.ArtifactChecksums, error) {
+	checksums, err := artifact.SHA256Sums(dir)
+	if err != nil {
+		return nil, err
+	}
+	sums, err := ioutil.ReadFile(path.Join(dir, artifact.SHA256SumsFilename))
+	if err != nil {
+		return nil, err
+	}
+	result := &worker.ArtifactChecksums{
+		SHA256:     checksums,
+		SHA256Sums: string(sums),
+	}
+	if signer == nil {
+		return result, nil
+	}
+
+	result.Signatures = make(map[string]string)
+	filenames := []string{artifact.SHA256SumsFilename}
+	for filename := range checksums {
+		filenames = append(filenames, filename)
+	}
+	for _, filename := range filenames {
+		signature, err := signer.Sign(context.Background(), path.Join(dir, filename))
+		if err != nil {
+			return nil, err
+		}
+		result.Signatures[filename] = signature
+	}
+	return result, nil
+}
+
+// exportChecksum writes the checksum of an exported artifact to
+// <filename>.sha256 next to it, in the format of sha256sum, and copies its
+// signature to <filename>.asc if it was signed
+func exportChecksum(checksums *worker.ArtifactChecksums, artifactFilename, dir, filename string) error {
+	if checksums == nil {
+		return nil
+	}
+	line := fmt.Sprintf("%s  %s\n", checksums.SHA256[artifactFilename], filename)
+	err := ioutil.WriteFile(filepath.Join(dir, filename+".sha256"), []byte(line), 0644)
+	if err != nil {
+		return fmt.Errorf("error writing checksum to export directory: %v", err)
+	}
+	if signature, ok := checksums.Signatures[artifactFilename]; ok {
+		err = ioutil.WriteFile(filepath.Join(dir, filename+artifact.SignatureSuffix), []byte(signature), 0644)
+		if err != nil {
+			return fmt.Errorf("error writing signature to export directory: %v", err)
+		}
+	}
+	return nil
+}
+
 func (impl *OSBuildJobImpl) Run(job worker.Job) error {
 	outputDirectory, err := ioutil.TempDir("/var/tmp", "osbuild-worker-*")
 	if err != nil {
@@ -88,6 +257,20 @@ func (impl *OSBuildJobImpl) Run(job worker.Job) error {
 
 	end_time := time.Now()
 
+	var r []error
+	var targetResults []*target.TargetResult
+
+	// The checksums are of the artifacts osbuild built. Images uploaded as
+	// stream-optimized VMDKs are converted while uploading and differ.
+	var checksums *worker.ArtifactChecksums
+	if osbuildOutput.Success {
+		checksums, err = checksumArtifacts(outputDirectory, impl.Signer)
+		if err != nil {
+			// uploads continue, but the job fails and hooks are not run
+			r = append(r, fmt.Errorf("cannot checksum the artifacts: %v", err))
+		}
+	}
+
 	if osbuildOutput.Success && args.ImageName != "" {
 		var f *os.File
 		imagePath := path.Join(outputDirectory, args.ImageName)
@@ -106,11 +289,44 @@ func (impl *OSBuildJobImpl) Run(job worker.Job) error {
 		if err != nil {
 			return err
 		}
-	}
 
-	var r []error
+		// the checksums and signatures are kept with the image
+		if checksums != nil {
+			names := []string{artifact.SHA256SumsFilename}
+			if checksums.Signatures != nil {
+				names = append(names, artifact.SHA256SumsFilename+artifact.SignatureSuffix, args.ImageName+artifact.SignatureSuffix)
+			}
+			for _, name := range names {
+				f, err := os.Open(path.Join(outputDirectory, name))
+				if err != nil {
+					return err
+				}
+				err = job.UploadArtifact(name, f)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
 
+	// Hooks run after all other targets, so that they can pass on their
+	// results
+	targets := make([]*target.Target, 0, len(args.Targets))
+	var hooks []*target.Target
 	for _, t := range args.Targets {
+		if _, ok := t.Options.(*target.HookTargetOptions); ok {
+			hooks = append(hooks, t)
+		} else {
+			targets = append(targets, t)
+		}
+	}
+	targets = append(targets, hooks...)
+
+	// The errors of each target are appended to r, starting at the index
+	// recorded for it in targetErrorsStart
+	targetErrorsStart := make([]int, len(targets))
+	for i, t := range targets {
+		targetErrorsStart[i] = len(r)
 		switch options := t.Options.(type) {
 		case *target.LocalTargetOptions:
 			if !osbuildOutput.Success {
@@ -142,36 +358,108 @@ func (impl *OSBuildJobImpl) Run(job worker.Job) error {
 			if !osbuildOutput.Success {
 				continue
 			}
-			a, err := awsupload.New(options.Region, options.AccessKeyID, options.SecretAccessKey)
+			// S3-compatible object stores only store the image
+			uploadOnly := options.UploadOnly || options.Endpoint != ""
+			if uploadOnly && (len(options.ShareWithAccounts) > 0 || len(options.CopyToRegions) > 0) {
+				r = append(r, errors.New("images which are not registered as AMIs cannot be shared or copied"))
+				continue
+			}
+
+			var a *awsupload.AWS
+			if options.Endpoint != "" {
+				a, err = awsupload.NewForEndpoint(awsupload.S3Endpoint{
+					URL:            options.Endpoint,
+					ForcePathStyle: options.ForcePathStyle,
+					Insecure:       options.Insecure,
+				}, options.Region, options.AccessKeyID, options.SecretAccessKey)
+			} else {
+				// fail before the upload, not after it
+				err = awsupload.CheckRegions(options.Region, options.CopyToRegions)
+				if err == nil {
+					a, err = awsupload.New(options.Region, options.AccessKeyID, options.SecretAccessKey)
+				}
+			}
 			if err != nil {
 				r = append(r, err)
 				continue
 			}
 
+			a.Progress = reportProgress(job, t)
+			a.Limiter = impl.UploadLimiter
+
 			key := options.Key
 			if key == "" {
 				key = uuid.New().String()
 			}
 
-			_, err = a.Upload(path.Join(outputDirectory, options.Filename), options.Bucket, key)
+			uploadOutput, err := a.Upload(path.Join(outputDirectory, options.Filename), options.Bucket, key)
 			if err != nil {
 				r = append(r, err)
 				continue
 			}
 
-			/* TODO: communicate back the AMI */
-			_, err = a.Register(t.ImageName, options.Bucket, key, options.ShareWithAccounts, common.CurrentArch())
+			if uploadOnly {
+				downloadURL, err := a.PresignedURL(options.Bucket, key, downloadURLExpiry)
+				if err != nil {
+					log.Printf("Error creating the download URL of the image: %v", err)
+				}
+				targetResults = append(targetResults, target.NewAWSTargetResult(&target.AWSTargetResultOptions{
+					Region:      options.Region,
+					URL:         uploadOutput.Location,
+					DownloadURL: downloadURL,
+				}))
+				continue
+			}
+
+			ami, err := a.Register(t.ImageName, options.Bucket, key, options.ShareWithAccounts, common.CurrentArch())
 			if err != nil {
 				r = append(r, err)
 				continue
 			}
+			if ami == nil {
+				r = append(r, fmt.Errorf("No AMI returned for %s", t.ImageName))
+				continue
+			}
+
+			var copies []target.AWSTargetResultOptions
+			if len(options.CopyToRegions) > 0 {
+				copied, err := a.CopyImage(t.ImageName, *ami, options.CopyToRegions, options.ShareWithAccounts)
+				if err != nil {
+					r = append(r, err)
+					continue
+				}
+				for _, region := range options.CopyToRegions {
+					copies = append(copies, target.AWSTargetResultOptions{
+						Ami:    copied[region],
+						Region: region,
+						ARN:    a.ImageARN(region, copied[region]),
+					})
+				}
+			}
+
+			targetResults = append(targetResults, target.NewAWSTargetResult(&target.AWSTargetResultOptions{
+				Ami:    *ami,
+				Region: options.Region,
+				ARN:    a.ImageARN(options.Region, *ami),
+				Copies: copies,
+			}))
 		case *target.AzureTargetOptions:
 			if !osbuildOutput.Success {
 				continue
 			}
+			cloud, err := azure.NewCloud(options.Cloud, azure.Cloud{
+				ActiveDirectoryEndpoint: options.ActiveDirectoryEndpoint,
+				ResourceManagerEndpoint: options.ResourceManagerEndpoint,
+				StorageEndpointSuffix:   options.StorageEndpointSuffix,
+			})
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
 			credentials := azure.Credentials{
 				StorageAccount:   options.StorageAccount,
 				StorageAccessKey: options.StorageAccessKey,
+				Cloud:            cloud,
 			}
 			metadata := azure.ImageMetadata{
 				ContainerName: options.Container,
@@ -179,17 +467,444 @@ func (impl *OSBuildJobImpl) Run(job worker.Job) error {
 			}
 
 			const azureMaxUploadGoroutines = 4
-			err := azure.UploadImage(
+			err = azure.UploadImage(
 				credentials,
 				metadata,
 				path.Join(outputDirectory, options.Filename),
 				azureMaxUploadGoroutines,
+				reportProgress(job, t),
+				impl.UploadLimiter,
 			)
 
 			if err != nil {
 				r = append(r, err)
 				continue
 			}
+
+			// the VHD is kept after registering the image, so it can
+			// be downloaded in any case
+			downloadURL, err := azure.DownloadURL(credentials, metadata, downloadURLExpiry)
+			if err != nil {
+				log.Printf("Error creating the download URL of the image: %v", err)
+			}
+
+			if options.ResourceGroup == "" {
+				targetResults = append(targetResults, target.NewAzureTargetResult(&target.AzureTargetResultOptions{
+					DownloadURL: downloadURL,
+				}))
+				continue
+			}
+			clientCredentials := azure.ClientCredentials{
+				SubscriptionID: options.SubscriptionID,
+				TenantID:       options.TenantID,
+				ClientID:       options.ClientID,
+				ClientSecret:   options.ClientSecret,
+				Cloud:          cloud,
+			}
+			imageID, err := azure.RegisterImage(context.Background(), clientCredentials, azure.ImageRegistration{
+				ResourceGroup: options.ResourceGroup,
+				Location:      options.Location,
+				ImageName:     t.ImageName,
+				BlobURL:       azure.BlobURL(credentials, metadata),
+			})
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+
+			if len(options.ShareWith) > 0 {
+				err = azure.ShareImage(context.Background(), clientCredentials, imageID, options.ShareWith)
+				if err != nil {
+					r = append(r, err)
+					continue
+				}
+			}
+
+			var versionID string
+			if options.Gallery != "" {
+				versionID, err = azure.PublishToGallery(context.Background(), clientCredentials, imageID, azure.GalleryImageVersion{
+					ResourceGroup: options.ResourceGroup,
+					Location:      options.Location,
+					Gallery:       options.Gallery,
+					Definition:    options.GalleryImageDefinition,
+					Version:       options.GalleryImageVersion,
+					TargetRegions: options.TargetRegions,
+				})
+				if err != nil {
+					r = append(r, err)
+					continue
+				}
+			}
+
+			targetResults = append(targetResults, target.NewAzureTargetResult(&target.AzureTargetResultOptions{
+				ImageID:               imageID,
+				GalleryImageVersionID: versionID,
+				DownloadURL:           downloadURL,
+			}))
+		case *target.GCPTargetOptions:
+			if !osbuildOutput.Success {
+				continue
+			}
+			g, err := gcp.New(options.Credentials)
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+			g.Progress = reportProgress(job, t)
+			g.Limiter = impl.UploadLimiter
+
+			project := options.Project
+			if project == "" {
+				project, err = g.ProjectID(context.Background())
+				if err != nil {
+					r = append(r, err)
+					continue
+				}
+			}
+
+			err = g.Upload(context.Background(), path.Join(outputDirectory, options.Filename), options.Bucket, options.Object)
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+
+			selfLink, err := g.ImportImage(context.Background(), gcp.ImageImport{
+				Project: project,
+				Name:    t.ImageName,
+				Bucket:  options.Bucket,
+				Object:  options.Object,
+				Family:  options.Family,
+				Labels:  options.Labels,
+
+				GuestOSFeatures: options.GuestOSFeatures,
+				Licenses:        options.Licenses,
+				ShieldedVM:      options.ShieldedVM,
+				ConfidentialVM:  options.ConfidentialVM,
+			})
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+
+			if len(options.ShareWith) > 0 {
+				err = g.ShareImage(context.Background(), project, t.ImageName, options.ShareWith)
+				if err != nil {
+					r = append(r, err)
+					continue
+				}
+			}
+
+			targetResults = append(targetResults, target.NewGCPTargetResult(&target.GCPTargetResultOptions{
+				ImageName: t.ImageName,
+				Project:   project,
+				SelfLink:  selfLink,
+			}))
+		case *target.OCITargetOptions:
+			if !osbuildOutput.Success {
+				continue
+			}
+			o, err := oci.New(oci.Credentials{
+				User:        options.User,
+				Tenancy:     options.Tenancy,
+				Region:      options.Region,
+				Fingerprint: options.Fingerprint,
+				PrivateKey:  options.PrivateKey,
+			})
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+			o.Progress = reportProgress(job, t)
+			o.Limiter = impl.UploadLimiter
+
+			namespace := options.Namespace
+			if namespace == "" {
+				namespace, err = o.Namespace(context.Background())
+				if err != nil {
+					r = append(r, err)
+					continue
+				}
+			}
+
+			err = o.Upload(context.Background(), path.Join(outputDirectory, options.Filename), namespace, options.Bucket, options.Object)
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+
+			imageID, err := o.ImportImage(context.Background(), oci.ImageImport{
+				Compartment: options.Compartment,
+				Name:        t.ImageName,
+				Namespace:   namespace,
+				Bucket:      options.Bucket,
+				Object:      options.Object,
+			})
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+
+			downloadURL, err := o.DownloadURL(context.Background(), namespace, options.Bucket, options.Object, downloadURLExpiry)
+			if err != nil {
+				log.Printf("Error creating the download URL of the image: %v", err)
+			}
+
+			targetResults = append(targetResults, target.NewOCITargetResult(&target.OCITargetResultOptions{
+				ImageID:     imageID,
+				Region:      options.Region,
+				DownloadURL: downloadURL,
+			}))
+		case *target.VMWareTargetOptions:
+			if !osbuildOutput.Success {
+				continue
+			}
+			// vSphere only imports stream optimized disks
+			f, err := vmware.OpenAsStreamOptimizedVmdk(path.Join(outputDirectory, options.Filename))
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+			imagePath := f.Name()
+			f.Close()
+
+			v, err := vmware.Connect(context.Background(), vmware.Credentials{
+				Host:     options.Host,
+				Username: options.Username,
+				Password: options.Password,
+				Insecure: options.Insecure,
+			})
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+			defer func() {
+				err := v.Logout(context.Background())
+				if err != nil {
+					log.Printf("vSphere logout failed: %v", err)
+				}
+			}()
+
+			var imageID string
+			if options.ContentLibrary != "" {
+				imageID, err = v.ImportToLibrary(context.Background(), imagePath, vmware.LibraryImport{
+					Name:    t.ImageName,
+					Library: options.ContentLibrary,
+				})
+			} else {
+				imageID, err = v.ImportToDatastore(context.Background(), imagePath, vmware.DatastoreImport{
+					Name:       t.ImageName,
+					Datacenter: options.Datacenter,
+					Cluster:    options.Cluster,
+					Datastore:  options.Datastore,
+					Folder:     options.Folder,
+					Template:   options.Template,
+					Network:    options.Network,
+				})
+			}
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+
+			targetResults = append(targetResults, target.NewVMWareTargetResult(&target.VMWareTargetResultOptions{
+				ImageID: imageID,
+			}))
+		case *target.PulpTargetOptions:
+			if !osbuildOutput.Success {
+				continue
+			}
+			p := pulp.New(options.Server, pulp.Credentials{
+				Username: options.Username,
+				Password: options.Password,
+			})
+			p.Progress = reportProgress(job, t)
+			p.Limiter = impl.UploadLimiter
+
+			repository, err := p.Repository(context.Background(), options.ContentType, options.Repository)
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+
+			imagePath := path.Join(outputDirectory, options.Filename)
+			var version string
+			if options.ContentType == pulp.OSTree {
+				version, err = p.ImportCommit(context.Background(), imagePath, repository)
+			} else {
+				version, err = p.UploadFile(context.Background(), imagePath, repository, options.RelativePath)
+			}
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+
+			var baseURL string
+			if options.BasePath != "" {
+				baseURL, err = p.Distribute(context.Background(), options.ContentType, repository, options.BasePath)
+				if err != nil {
+					r = append(r, err)
+					continue
+				}
+			}
+
+			targetResults = append(targetResults, target.NewPulpTargetResult(&target.PulpTargetResultOptions{
+				RepositoryVersion: version,
+				BaseURL:           baseURL,
+			}))
+		case *target.ExportTargetOptions:
+			if !osbuildOutput.Success {
+				continue
+			}
+			if impl.ExportDir == "" {
+				r = append(r, errors.New("exporting images is not configured on this worker"))
+				continue
+			}
+
+			exportPath, err := exportArtifact(path.Join(outputDirectory, options.Filename), impl.ExportDir, options.ExportFilename)
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+			err = exportChecksum(checksums, options.Filename, impl.ExportDir, options.ExportFilename)
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+
+			targetResults = append(targetResults, target.NewExportTargetResult(&target.ExportTargetResultOptions{
+				Path: exportPath,
+			}))
+		case *target.LibvirtTargetOptions:
+			if !osbuildOutput.Success {
+				continue
+			}
+			uri, exists := impl.LibvirtHosts[options.Host]
+			if !exists {
+				r = append(r, fmt.Errorf("libvirt host %s is not configured on this worker", options.Host))
+				continue
+			}
+			var domainTemplate *template.Template
+			if options.DomainTemplate != "" {
+				domainTemplate, exists = impl.DomainTemplates[options.DomainTemplate]
+				if !exists {
+					r = append(r, fmt.Errorf("domain template %s is not configured on this worker", options.DomainTemplate))
+					continue
+				}
+			}
+			l, err := libvirt.New(uri)
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+			l.Progress = reportProgress(job, t)
+			l.Limiter = impl.UploadLimiter
+
+			volume, err := l.ImportVolume(context.Background(), path.Join(outputDirectory, options.Filename), options.Pool, options.Volume)
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+
+			var domainUUID string
+			if options.Domain != "" {
+				domain := libvirt.Domain{
+					Name:    options.Domain,
+					Memory:  options.Memory,
+					VCPUs:   options.VCPUs,
+					Network: options.Network,
+					Pool:    volume.Pool,
+					Volume:  volume.Name,
+					Format:  volume.Format,
+					Path:    volume.Path,
+				}
+				if domain.Memory == 0 {
+					domain.Memory = 2048
+				}
+				if domain.VCPUs == 0 {
+					domain.VCPUs = 2
+				}
+				if domain.Network == "" {
+					domain.Network = "default"
+				}
+				domainXML, err := libvirt.DomainXML(domainTemplate, domain)
+				if err != nil {
+					r = append(r, err)
+					continue
+				}
+				domainUUID, err = l.DefineDomain(context.Background(), domainXML, options.Start)
+				if err != nil {
+					r = append(r, err)
+					continue
+				}
+			}
+
+			targetResults = append(targetResults, target.NewLibvirtTargetResult(&target.LibvirtTargetResultOptions{
+				VolumePath: volume.Path,
+				DomainUUID: domainUUID,
+			}))
+		case *target.PluginTargetOptions:
+			if !osbuildOutput.Success {
+				continue
+			}
+			p, exists := impl.Plugins[options.Plugin]
+			if !exists {
+				r = append(r, fmt.Errorf("plugin %s is not installed on this worker", options.Plugin))
+				continue
+			}
+
+			result, err := p.Upload(context.Background(), &plugin.Request{
+				JobID:     job.Id(),
+				ImageName: t.ImageName,
+				Path:      path.Join(outputDirectory, options.Filename),
+				Options:   options.Options,
+			}, reportProgress(job, t))
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+
+			targetResults = append(targetResults, target.NewPluginTargetResult(&target.PluginTargetResultOptions{
+				Plugin:  options.Plugin,
+				ImageID: result.ImageID,
+				URL:     result.URL,
+			}))
+		case *target.HookTargetOptions:
+			if !osbuildOutput.Success {
+				continue
+			}
+			// hooks roll out images, which they must not do when any
+			// target before them failed
+			if len(r) > 0 {
+				r = append(r, errors.New("the hook was not run, because an earlier step of the job failed"))
+				continue
+			}
+
+			payload, err := hook.NewPayload(job.Id(), path.Join(outputDirectory, options.Filename), targetResults)
+			if err != nil {
+				r = append(r, err)
+				continue
+			}
+
+			if options.URL != "" {
+				err = hook.NewWebhook(options.URL, options.Headers).Send(context.Background(), payload)
+				if err != nil {
+					r = append(r, err)
+					continue
+				}
+			}
+
+			if options.Command != "" {
+				command, exists := impl.Hooks[options.Command]
+				if !exists {
+					r = append(r, fmt.Errorf("hook command %s is not configured on this worker", options.Command))
+					continue
+				}
+				err = hook.Run(context.Background(), command, payload)
+				if err != nil {
+					r = append(r, err)
+					continue
+				}
+			}
 		case *target.KojiTargetOptions:
 			// Koji for some reason needs TLS renegotiation enabled.
 			// Clone the default http transport and enable renegotiation.
@@ -305,6 +1020,26 @@ func (impl *OSBuildJobImpl) Run(job worker.Job) error {
 		targetErrors = append(targetErrors, err.Error())
 	}
 
+	var targetStatuses []worker.TargetStatus
+	for i, t := range targets {
+		end := len(r)
+		if i+1 < len(targets) {
+			end = targetErrorsStart[i+1]
+		}
+		status := worker.TargetStatus{
+			Target: t.Uuid,
+			State:  worker.TargetSuccess,
+		}
+		if !osbuildOutput.Success {
+			status.State = worker.TargetFailure
+			status.Error = "the image build failed"
+		} else if end > targetErrorsStart[i] {
+			status.State = worker.TargetFailure
+			status.Error = strings.Join(targetErrors[targetErrorsStart[i]:end], "; ")
+		}
+		targetStatuses = append(targetStatuses, status)
+	}
+
 	var uploadstatus string = "failure"
 	if len(targetErrors) == 0 {
 		uploadstatus = "success"
@@ -315,6 +1050,10 @@ func (impl *OSBuildJobImpl) Run(job worker.Job) error {
 		OSBuildOutput: osbuildOutput,
 		TargetErrors:  targetErrors,
 		UploadStatus:  uploadstatus,
+		TargetResults: targetResults,
+
+		TargetStatuses: targetStatuses,
+		Checksums:      checksums,
 	})
 	if err != nil {
 		return fmt.Errorf("Error reporting job result: %v", err)
@@ -11,20 +11,43 @@ import (
 	"log"
 	"os"
 	"path"
+	"text/template"
 	"time"
 
 	"github.com/BurntSushi/toml"
 
+	"github.com/osbuild/osbuild-composer/internal/artifact"
+	"github.com/osbuild/osbuild-composer/internal/blueprint"
+	"github.com/osbuild/osbuild-composer/internal/certs"
+	"github.com/osbuild/osbuild-composer/internal/rpmmd"
 	"github.com/osbuild/osbuild-composer/internal/upload/koji"
+	"github.com/osbuild/osbuild-composer/internal/upload/libvirt"
+	"github.com/osbuild/osbuild-composer/internal/upload/plugin"
+	"github.com/osbuild/osbuild-composer/internal/upload/throttle"
 	"github.com/osbuild/osbuild-composer/internal/worker"
 )
 
 const configFile = "/etc/osbuild-worker/osbuild-worker.toml"
 
+// defaultRPMMDCache is the rpmmd cache of osbuild-composer, which the worker
+// cleans up when it runs on the same host
+const defaultRPMMDCache = "/var/cache/osbuild-composer/rpmmd"
+
+// The directory plugin targets are discovered in, unless another one is
+// configured
+const defaultPluginDir = "/etc/osbuild-worker/plugins"
+
+// rpmmdCacheCleanInterval is how often the rpmmd cache is cleaned up while
+// the worker waits for jobs
+const rpmmdCacheCleanInterval = time.Hour
+
 type connectionConfig struct {
 	CACertFile     string
 	ClientKeyFile  string
 	ClientCertFile string
+	// CRLFile is the certificate revocation list which the certificate
+	// of composer is checked against, if it is set
+	CRLFile string
 }
 
 // Represents the implementation of a job type as defined by the worker API.
@@ -44,15 +67,28 @@ func createTLSConfig(config *connectionConfig) (*tls.Config, error) {
 		return nil, errors.New("failed to append root certificate")
 	}
 
-	cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+	// rotated client certificates are used for new connections
+	keyPair, err := certs.NewKeyPair(config.ClientCertFile, config.ClientKeyFile)
 	if err != nil {
 		return nil, err
 	}
 
-	return &tls.Config{
-		RootCAs:      roots,
-		Certificates: []tls.Certificate{cert},
-	}, nil
+	conf := &tls.Config{
+		RootCAs:              roots,
+		GetClientCertificate: keyPair.GetClientCertificate,
+	}
+
+	if config.CRLFile != "" {
+		crl, err := certs.NewRevocationList(config.CRLFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			return crl.Check(verifiedChains)
+		}
+	}
+
+	return conf, nil
 }
 
 // Regularly ask osbuild-composer if the compose we're currently working on was
@@ -75,6 +111,28 @@ func WatchJob(ctx context.Context, job worker.Job) {
 	}
 }
 
+// rpmmdCacheCleaner removes old and least recently used metadata from an
+// rpmmd cache, after each job and periodically while the worker is idle
+type rpmmdCacheCleaner struct {
+	Path    string
+	MaxSize uint64
+	MaxAge  time.Duration
+}
+
+func (c *rpmmdCacheCleaner) Clean() {
+	err := rpmmd.CleanCache(c.Path, c.MaxSize, c.MaxAge)
+	if err != nil {
+		log.Printf("Could not clean up the rpmmd cache: %v", err)
+	}
+}
+
+// Run cleans the cache every interval, forever
+func (c *rpmmdCacheCleaner) Run(interval time.Duration) {
+	for range time.Tick(interval) {
+		c.Clean()
+	}
+}
+
 func main() {
 	var config struct {
 		KojiServers map[string]struct {
@@ -83,6 +141,42 @@ func main() {
 				KeyTab    string `toml:"keytab"`
 			} `toml:"kerberos,omitempty"`
 		} `toml:"koji"`
+		RPMMDCache struct {
+			Path    string `toml:"path"`
+			MaxSize string `toml:"max_size"`
+			MaxAge  string `toml:"max_age"`
+		} `toml:"rpmmd_cache"`
+		Export struct {
+			Directory string `toml:"directory"`
+		} `toml:"export"`
+		Upload struct {
+			MaxBandwidth string `toml:"max_bandwidth"`
+		} `toml:"upload"`
+		Hooks map[string]struct {
+			Command []string `toml:"command"`
+		} `toml:"hooks"`
+		Libvirt struct {
+			Hosts map[string]struct {
+				URI string `toml:"uri"`
+			} `toml:"hosts"`
+			DomainTemplates map[string]struct {
+				File string `toml:"file"`
+			} `toml:"domain_templates"`
+		} `toml:"libvirt"`
+		Plugins struct {
+			Directory string `toml:"directory"`
+			Timeout   string `toml:"timeout"`
+		} `toml:"plugins"`
+		Signing struct {
+			Key     string `toml:"key"`
+			Homedir string `toml:"homedir"`
+		} `toml:"signing"`
+		Composer struct {
+			CRL string `toml:"crl"`
+			// Channels are the channels of the tenants whose jobs the
+			// worker runs, all of them if it is empty
+			Channels []string `toml:"channels"`
+		} `toml:"composer"`
 	}
 	var unix bool
 	flag.BoolVar(&unix, "unix", false, "Interpret 'address' as a path to a unix domain socket instead of a network address")
@@ -118,6 +212,94 @@ func main() {
 	}
 	store := path.Join(cacheDirectory, "osbuild-store")
 
+	// The rpmmd cache is only cleaned up when a maximal size or age is
+	// configured
+	var cacheCleaner *rpmmdCacheCleaner
+	if config.RPMMDCache.MaxSize != "" || config.RPMMDCache.MaxAge != "" {
+		cacheCleaner = &rpmmdCacheCleaner{Path: config.RPMMDCache.Path}
+		if cacheCleaner.Path == "" {
+			cacheCleaner.Path = defaultRPMMDCache
+		}
+		if config.RPMMDCache.MaxSize != "" {
+			cacheCleaner.MaxSize, err = blueprint.ParseSize(config.RPMMDCache.MaxSize)
+			if err != nil {
+				log.Fatalf("Invalid rpmmd_cache.max_size: %v", err)
+			}
+		}
+		if config.RPMMDCache.MaxAge != "" {
+			cacheCleaner.MaxAge, err = time.ParseDuration(config.RPMMDCache.MaxAge)
+			if err != nil || cacheCleaner.MaxAge <= 0 {
+				log.Fatalf("Invalid rpmmd_cache.max_age %q, expected a duration like \"720h\"", config.RPMMDCache.MaxAge)
+			}
+		}
+		go cacheCleaner.Run(rpmmdCacheCleanInterval)
+	}
+
+	// The bandwidth is given in bytes per second, e.g. "10 MiB"
+	var uploadLimiter *throttle.Limiter
+	if config.Upload.MaxBandwidth != "" {
+		bandwidth, err := blueprint.ParseSize(config.Upload.MaxBandwidth)
+		if err != nil {
+			log.Fatalf("Invalid upload.max_bandwidth %q, expected bytes per second like \"10 MiB\"", config.Upload.MaxBandwidth)
+		}
+		uploadLimiter = throttle.New(int64(bandwidth))
+	}
+
+	hooks := make(map[string][]string)
+	for name, hook := range config.Hooks {
+		if len(hook.Command) == 0 {
+			log.Fatalf("Hook %s has no command", name)
+		}
+		hooks[name] = hook.Command
+	}
+
+	libvirtHosts := make(map[string]string)
+	for name, host := range config.Libvirt.Hosts {
+		err := libvirt.ValidateURI(host.URI)
+		if err != nil {
+			log.Fatalf("Invalid libvirt host %s: %v", name, err)
+		}
+		libvirtHosts[name] = host.URI
+	}
+
+	domainTemplates := make(map[string]*template.Template)
+	for name, domainTemplate := range config.Libvirt.DomainTemplates {
+		text, err := ioutil.ReadFile(domainTemplate.File)
+		if err != nil {
+			log.Fatalf("Could not read domain template %s: %v", name, err)
+		}
+		domainTemplates[name], err = libvirt.ParseDomainTemplate(string(text))
+		if err != nil {
+			log.Fatalf("Invalid domain template %s: %v", name, err)
+		}
+	}
+
+	pluginDir := config.Plugins.Directory
+	if pluginDir == "" {
+		pluginDir = defaultPluginDir
+	}
+	plugins, err := plugin.Discover(pluginDir)
+	if err != nil {
+		log.Fatalf("Could not discover plugins: %v", err)
+	}
+	var pluginTimeout time.Duration
+	if config.Plugins.Timeout != "" {
+		pluginTimeout, err = time.ParseDuration(config.Plugins.Timeout)
+		if err != nil || pluginTimeout <= 0 {
+			log.Fatalf("Invalid plugins.timeout %q, expected a duration like \"2h\"", config.Plugins.Timeout)
+		}
+	}
+	for name, p := range plugins {
+		p.Timeout = pluginTimeout
+		log.Printf("Found upload plugin %s", name)
+	}
+
+	// Artifacts are only signed when a key is configured
+	var signer *artifact.Signer
+	if config.Signing.Key != "" {
+		signer = artifact.NewSigner(config.Signing.Key, config.Signing.Homedir)
+	}
+
 	kojiServers := make(map[string]koji.GSSAPICredentials)
 	for server, creds := range config.KojiServers {
 		if creds.Kerberos == nil {
@@ -138,6 +320,7 @@ func main() {
 			CACertFile:     "/etc/osbuild-composer/ca-crt.pem",
 			ClientKeyFile:  "/etc/osbuild-composer/worker-key.pem",
 			ClientCertFile: "/etc/osbuild-composer/worker-crt.pem",
+			CRLFile:        config.Composer.CRL,
 		})
 		if err != nil {
 			log.Fatalf("Error creating TLS config: %v", err)
@@ -151,8 +334,15 @@ func main() {
 
 	jobImpls := map[string]JobImplementation{
 		"osbuild": &OSBuildJobImpl{
-			Store:       store,
-			KojiServers: kojiServers,
+			Store:           store,
+			KojiServers:     kojiServers,
+			ExportDir:       config.Export.Directory,
+			UploadLimiter:   uploadLimiter,
+			Hooks:           hooks,
+			LibvirtHosts:    libvirtHosts,
+			DomainTemplates: domainTemplates,
+			Plugins:         plugins,
+			Signer:          signer,
 		},
 		"osbuild-koji": &OSBuildKojiJobImpl{
 			Store:       store,
@@ -173,7 +363,7 @@ func main() {
 
 	for {
 		fmt.Println("Waiting for a new job...")
-		job, err := client.RequestJob(acceptedJobTypes)
+		job, err := client.RequestJob(acceptedJobTypes, config.Composer.Channels)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -193,9 +383,12 @@ func main() {
 		cancelWatcher()
 		if err != nil {
 			log.Printf("Job %s failed: %v", job.Id(), err)
-			continue
+		} else {
+			log.Printf("Job %s finished", job.Id())
 		}
 
-		log.Printf("Job %s finished", job.Id())
+		if cacheCleaner != nil {
+			cacheCleaner.Clean()
+		}
 	}
 }
@@ -5,6 +5,9 @@ import (
 	"github.com/osbuild/osbuild-composer/internal/osbuild"
 	"github.com/osbuild/osbuild-composer/internal/upload/koji"
 	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -99,3 +102,32 @@ func Test_osbuildStagesToRPMs(t *testing.T) {
 	// if neither GPG nor PGP is set, the signature is nil
 	require.Nil(t, rpms[2].Signature)
 }
+
+func Test_exportArtifact(t *testing.T) {
+	src, err := ioutil.TempDir("", "osbuild-worker-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(src)
+	dir, err := ioutil.TempDir("", "osbuild-worker-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	artifact := filepath.Join(src, "disk.qcow2")
+	require.NoError(t, ioutil.WriteFile(artifact, []byte("image"), 0600))
+
+	exportPath, err := exportArtifact(artifact, dir, "bp-0.0.1-disk.qcow2")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "bp-0.0.1-disk.qcow2"), exportPath)
+	content, err := ioutil.ReadFile(exportPath)
+	require.NoError(t, err)
+	require.Equal(t, "image", string(content))
+
+	// no temporary files are left behind
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	for _, filename := range []string{"", ".", "..", "../disk.qcow2", "sub/disk.qcow2"} {
+		_, err = exportArtifact(artifact, dir, filename)
+		require.Error(t, err, filename)
+	}
+}
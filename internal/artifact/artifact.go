@@ -0,0 +1,128 @@
+// Package artifact computes the checksums of the artifacts osbuild built and
+// signs them with GPG, so that users can verify the images they received.
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SHA256SumsFilename is the name of the checksum file, which is written next
+// to the artifacts
+const SHA256SumsFilename = "SHA256SUMS"
+
+// SignatureSuffix is appended to the name of a file to get the name of its
+// detached signature
+const SignatureSuffix = ".asc"
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// SHA256Sums hashes the regular files in dir and writes their checksums to
+// SHA256SUMS in dir, in the format of sha256sum. It returns the checksums by
+// filename. Directories and earlier checksum and signature files are skipped.
+func SHA256Sums(dir string) (map[string]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string)
+	var filenames []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Mode().IsRegular() || name == SHA256SumsFilename || strings.HasSuffix(name, SignatureSuffix) {
+			continue
+		}
+		checksum, err := sha256File(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("cannot hash %s: %v", name, err)
+		}
+		checksums[name] = checksum
+		filenames = append(filenames, name)
+	}
+
+	sort.Strings(filenames)
+	var sums bytes.Buffer
+	for _, name := range filenames {
+		fmt.Fprintf(&sums, "%s  %s\n", checksums[name], name)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, SHA256SumsFilename), sums.Bytes(), 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return checksums, nil
+}
+
+// Signer creates detached, ASCII-armored signatures by running gpg
+type Signer struct {
+	// Key is the ID, fingerprint or user ID of the secret key to sign with
+	Key string
+	// Homedir is the GnuPG home directory containing the key, or the
+	// default one of gpg if it is empty
+	Homedir string
+
+	// The gpg command, which is only changed by tests
+	gpg string
+}
+
+// NewSigner returns a signer using the secret key key from the GnuPG home
+// directory homedir. The key must not be protected by a passphrase.
+func NewSigner(key, homedir string) *Signer {
+	return &Signer{
+		Key:     key,
+		Homedir: homedir,
+		gpg:     "gpg",
+	}
+}
+
+// Sign writes the detached signature of the file at path to path.asc and
+// returns the signature
+func (s *Signer) Sign(ctx context.Context, path string) (string, error) {
+	signaturePath := path + SignatureSuffix
+	args := []string{"--batch", "--yes", "--pinentry-mode", "loopback", "--passphrase", ""}
+	if s.Homedir != "" {
+		args = append(args, "--homedir", s.Homedir)
+	}
+	args = append(args, "--local-user", s.Key, "--armor", "--detach-sign", "--output", signaturePath, path)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.gpg, args...)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("cannot sign %s: %s", filepath.Base(path), msg)
+		}
+		return "", fmt.Errorf("cannot sign %s: %v", filepath.Base(path), err)
+	}
+
+	signature, err := ioutil.ReadFile(signaturePath)
+	if err != nil {
+		return "", err
+	}
+	return string(signature), nil
+}
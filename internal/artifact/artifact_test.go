@@ -0,0 +1,87 @@
+package artifact
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSHA256Sums(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifact-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "disk.qcow2"), []byte("disk"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "commit.tar"), nil, 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "disk.qcow2.asc"), []byte("signature"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "repo"), 0700))
+
+	checksums, err := SHA256Sums(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"commit.tar": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		"disk.qcow2": "1044dec7206e8d7c9fbb4ae8f766668406d2567fc7fc1a160a9d4700fcf8f8e9",
+	}, checksums)
+
+	sums, err := ioutil.ReadFile(filepath.Join(dir, SHA256SumsFilename))
+	require.NoError(t, err)
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  commit.tar\n"+
+		"1044dec7206e8d7c9fbb4ae8f766668406d2567fc7fc1a160a9d4700fcf8f8e9  disk.qcow2\n", string(sums))
+
+	// the checksum file itself is not hashed when run again
+	again, err := SHA256Sums(dir)
+	require.NoError(t, err)
+	assert.Equal(t, checksums, again)
+}
+
+// fakeGPG writes a script to dir which logs its arguments and writes a fake
+// signature, or fails for the key "missing"
+func fakeGPG(t *testing.T, dir string) string {
+	script := `#!/bin/sh
+echo "$@" >> "` + dir + `/log"
+while [ $# -gt 1 ]; do
+	case "$1" in
+	--local-user) if [ "$2" = missing ]; then echo "gpg: skipped \"missing\": No secret key" >&2; exit 2; fi ;;
+	--output) output="$2" ;;
+	esac
+	shift
+done
+echo "-----BEGIN PGP SIGNATURE-----" > "$output"
+`
+	gpg := filepath.Join(dir, "gpg")
+	require.NoError(t, ioutil.WriteFile(gpg, []byte(script), 0700))
+	return gpg
+}
+
+func TestSign(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifact-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	image := filepath.Join(dir, "disk.qcow2")
+	require.NoError(t, ioutil.WriteFile(image, []byte("disk"), 0600))
+
+	s := NewSigner("releng@example.com", "/etc/osbuild-worker/gnupg")
+	s.gpg = fakeGPG(t, dir)
+
+	signature, err := s.Sign(context.Background(), image)
+	require.NoError(t, err)
+	assert.Equal(t, "-----BEGIN PGP SIGNATURE-----\n", signature)
+	written, err := ioutil.ReadFile(image + SignatureSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, signature, string(written))
+
+	log, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+	require.NoError(t, err)
+	assert.Equal(t, "--batch --yes --pinentry-mode loopback --passphrase  --homedir /etc/osbuild-worker/gnupg "+
+		"--local-user releng@example.com --armor --detach-sign --output "+image+".asc "+image, strings.TrimSpace(string(log)))
+
+	s.Key = "missing"
+	_, err = s.Sign(context.Background(), image)
+	assert.EqualError(t, err, "cannot sign disk.qcow2: gpg: skipped \"missing\": No secret key")
+}
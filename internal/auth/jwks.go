@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// keysRefreshInterval is how long keys are cached
+	keysRefreshInterval = time.Hour
+	// keysMinRefreshInterval limits how often keys are fetched because a
+	// token was signed with an unknown key, e.g. right after the keys were
+	// rotated
+	keysMinRefreshInterval = time.Minute
+)
+
+// jwk is a JSON web key, as defined in RFC 7517. Only the members of the
+// public keys of RSA and elliptic curves are included.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey returns the public key of k, or nil if it is not a signing key
+// of a supported type
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	if k.Use != "" && k.Use != "sig" {
+		return nil, nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus of key %s: %v", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent of key %s: %v", k.Kid, err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid exponent of key %s", k.Kid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate of key %s: %v", k.Kid, err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate of key %s: %v", k.Kid, err)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("key %s is not on curve %s", k.Kid, k.Crv)
+		}
+		return key, nil
+	}
+
+	return nil, nil
+}
+
+// KeySet is the set of keys the tokens of an identity provider are signed
+// with, which is fetched from a JSON web key set (JWKS) URL
+type KeySet struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	keys    map[string][]crypto.PublicKey
+	fetched time.Time
+}
+
+// NewKeySet returns the key set at url. The keys are fetched when they are
+// needed first.
+func NewKeySet(url string, client *http.Client) *KeySet {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &KeySet{url: url, client: client}
+}
+
+// Keys returns the keys with ID kid, or all keys if it is empty. The keys are
+// fetched again when they are older than an hour, or when there is no key
+// with ID kid.
+func (s *KeySet) Keys(kid string) ([]crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	age := time.Since(s.fetched)
+	_, known := s.keys[kid]
+	if s.keys == nil || age > keysRefreshInterval || (kid != "" && !known && age > keysMinRefreshInterval) {
+		keys, err := s.fetch()
+		s.fetched = time.Now()
+		if err != nil {
+			if s.keys == nil {
+				return nil, err
+			}
+			log.Printf("Cannot refresh the keys from %s, the previous ones are still used: %v", s.url, err)
+		} else {
+			s.keys = keys
+		}
+	}
+
+	if kid != "" {
+		return s.keys[kid], nil
+	}
+	var all []crypto.PublicKey
+	for _, keys := range s.keys {
+		all = append(all, keys...)
+	}
+	return all, nil
+}
+
+func (s *KeySet) fetch() (map[string][]crypto.PublicKey, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch keys: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot fetch keys: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&set)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse keys: %v", err)
+	}
+
+	keys := make(map[string][]crypto.PublicKey)
+	for i := range set.Keys {
+		key, err := set.Keys[i].publicKey()
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			keys[set.Keys[i].Kid] = append(keys[set.Keys[i].Kid], key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("there are no signing keys")
+	}
+	return keys, nil
+}
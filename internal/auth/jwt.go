@@ -0,0 +1,211 @@
+// Package auth authenticates clients of the HTTP APIs of composer with
+// bearer tokens, which are JSON web tokens (JWT) issued by an identity
+// provider, e.g. an OAuth2 or OpenID Connect single sign-on service.
+//
+// Tokens must be signed with one of the keys of the identity provider, which
+// are fetched from its JSON web key set (JWKS) URL. Only asymmetric
+// signatures are supported: RS256, RS384, RS512, ES256, ES384 and ES512.
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256" // for crypto.SHA256 and crypto.SHA384
+	_ "crypto/sha512" // for crypto.SHA512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// leeway is how much the clocks of composer and the identity provider may
+// differ
+const leeway = time.Minute
+
+// Claims are the claims of a token, by name
+type Claims map[string]interface{}
+
+// String returns the claim name if it is a string
+func (c Claims) String(name string) (string, bool) {
+	s, ok := c[name].(string)
+	return s, ok
+}
+
+// time returns the claim name if it is a NumericDate
+func (c Claims) time(name string) (time.Time, bool, error) {
+	v, ok := c[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("claim %s is not a date", name)
+	}
+	seconds, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("claim %s is not a date", name)
+	}
+	return time.Unix(int64(seconds), 0), true, nil
+}
+
+// audience returns the audiences of the token, which are either a string or
+// an array of strings
+func (c Claims) audience() []string {
+	switch aud := c["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []interface{}:
+		var audience []string
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+		return audience
+	}
+	return nil
+}
+
+// Validator validates tokens
+type Validator struct {
+	Keys *KeySet
+	// Issuer is the issuer tokens must have, unless it is empty
+	Issuer string
+	// Audience must be one of the audiences of tokens, unless it is empty
+	Audience string
+}
+
+type signatureAlgorithm struct {
+	hash crypto.Hash
+	// ecdsa is whether the algorithm is ECDSA instead of RSA
+	ecdsa bool
+}
+
+var algorithms = map[string]signatureAlgorithm{
+	"RS256": {crypto.SHA256, false},
+	"RS384": {crypto.SHA384, false},
+	"RS512": {crypto.SHA512, false},
+	"ES256": {crypto.SHA256, true},
+	"ES384": {crypto.SHA384, true},
+	"ES512": {crypto.SHA512, true},
+}
+
+// Validate returns the claims of token if it is signed by one of the keys,
+// is valid now, and has the issuer and audience of the validator
+func (v *Validator) Validate(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is malformed")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, fmt.Errorf("token has an invalid header: %v", err)
+	}
+	alg, ok := algorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("token is signed with an unsupported algorithm %q", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("token has an invalid signature: %v", err)
+	}
+	keys, err := v.Keys.Keys(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	h := alg.hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	digest := h.Sum(nil)
+	verified := false
+	for _, key := range keys {
+		if verify(key, alg, digest, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("token is not signed by the identity provider")
+	}
+
+	var claims Claims
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, fmt.Errorf("token has invalid claims: %v", err)
+	}
+
+	now := time.Now()
+	exp, ok, err := claims.time("exp")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("token does not expire")
+	}
+	if now.After(exp.Add(leeway)) {
+		return nil, errors.New("token has expired")
+	}
+	nbf, ok, err := claims.time("nbf")
+	if err != nil {
+		return nil, err
+	}
+	if ok && now.Add(leeway).Before(nbf) {
+		return nil, errors.New("token is not valid yet")
+	}
+
+	if v.Issuer != "" {
+		if iss, _ := claims.String("iss"); iss != v.Issuer {
+			return nil, fmt.Errorf("token was issued by %q", iss)
+		}
+	}
+	if v.Audience != "" {
+		found := false
+		for _, aud := range claims.audience() {
+			if aud == v.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("token is not meant for composer")
+		}
+	}
+
+	return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func verify(key crypto.PublicKey, alg signatureAlgorithm, digest, signature []byte) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return !alg.ecdsa && rsa.VerifyPKCS1v15(key, alg.hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		// the signature is r and s, each padded to the size of the curve
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if !alg.ecdsa || len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
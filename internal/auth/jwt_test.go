@@ -0,0 +1,298 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func sign(t *testing.T, key crypto.Signer, alg, kid string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	input := b64(header) + "." + b64(payload)
+
+	digest := algorithms[alg].hash.New()
+	digest.Write([]byte(input))
+
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, algorithms[alg].hash, digest.Sum(nil))
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+		require.NoError(t, err)
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		copy(signature[size-len(r.Bytes()):size], r.Bytes())
+		copy(signature[2*size-len(s.Bytes()):], s.Bytes())
+	}
+	return input + "." + b64(signature)
+}
+
+type testProvider struct {
+	rsaKey  *rsa.PrivateKey
+	ecKey   *ecdsa.PrivateKey
+	server  *httptest.Server
+	fetches int32
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	p := &testProvider{rsaKey: rsaKey, ecKey: ecKey}
+	p.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&p.fetches, 1)
+		err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{
+				{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+				{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes())},
+				{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+				{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+			},
+		})
+		require.NoError(t, err)
+	}))
+	return p
+}
+
+func (p *testProvider) validator() *Validator {
+	return &Validator{
+		Keys:     NewKeySet(p.server.URL, nil),
+		Issuer:   "https://sso.example.com",
+		Audience: "composer",
+	}
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss": "https://sso.example.com",
+		"aud": []string{"account", "composer"},
+		"sub": "user-42",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"nbf": time.Now().Add(-time.Minute).Unix(),
+	}
+}
+
+func TestValidate(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.server.Close()
+	v := p.validator()
+
+	for _, alg := range []string{"RS256", "RS512"} {
+		claims, err := v.Validate(sign(t, p.rsaKey, alg, "rsa", validClaims()))
+		require.NoError(t, err, alg)
+		sub, _ := claims.String("sub")
+		require.Equal(t, "user-42", sub)
+	}
+	_, err := v.Validate(sign(t, p.ecKey, "ES256", "ec", validClaims()))
+	require.NoError(t, err)
+	// without a key ID, all keys are tried
+	_, err = v.Validate(sign(t, p.ecKey, "ES256", "", validClaims()))
+	require.NoError(t, err)
+
+	// the keys were only fetched once
+	require.Equal(t, int32(1), atomic.LoadInt32(&p.fetches))
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, err = v.Validate(sign(t, other, "RS256", "rsa", validClaims()))
+	require.EqualError(t, err, "token is not signed by the identity provider")
+	_, err = v.Validate(sign(t, p.rsaKey, "RS256", "ec", validClaims()))
+	require.EqualError(t, err, "token is not signed by the identity provider")
+	_, err = v.Validate(sign(t, p.rsaKey, "RS256", "enc", validClaims()))
+	require.EqualError(t, err, "token is not signed by the identity provider")
+
+	// unknown keys are fetched again, but only once a minute
+	_, err = v.Validate(sign(t, p.rsaKey, "RS256", "rotated", validClaims()))
+	require.EqualError(t, err, "token is not signed by the identity provider")
+	require.Equal(t, int32(1), atomic.LoadInt32(&p.fetches))
+
+	token := sign(t, p.rsaKey, "RS256", "rsa", validClaims())
+	parts := strings.Split(token, ".")
+	header := b64([]byte(`{"alg":"HS256","kid":"hmac"}`))
+	_, err = v.Validate(header + "." + parts[1] + "." + parts[2])
+	require.EqualError(t, err, `token is signed with an unsupported algorithm "HS256"`)
+	header = b64([]byte(`{"alg":"none"}`))
+	_, err = v.Validate(header + "." + parts[1] + ".")
+	require.EqualError(t, err, `token is signed with an unsupported algorithm "none"`)
+	// the claims are signed
+	_, err = v.Validate(parts[0] + "." + b64([]byte(`{"sub":"admin"}`)) + "." + parts[2])
+	require.EqualError(t, err, "token is not signed by the identity provider")
+	_, err = v.Validate("not a token")
+	require.EqualError(t, err, "token is malformed")
+}
+
+func TestValidateClaims(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.server.Close()
+	v := p.validator()
+
+	cases := []struct {
+		name   string
+		modify func(claims map[string]interface{})
+		err    string
+	}{
+		{"expired", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, "token has expired"},
+		{"no expiry", func(c map[string]interface{}) { delete(c, "exp") }, "token does not expire"},
+		{"not yet valid", func(c map[string]interface{}) { c["nbf"] = time.Now().Add(time.Hour).Unix() }, "token is not valid yet"},
+		{"issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }, `token was issued by "https://evil.example.com"`},
+		{"audience", func(c map[string]interface{}) { c["aud"] = "account" }, "token is not meant for composer"},
+		{"no audience", func(c map[string]interface{}) { delete(c, "aud") }, "token is not meant for composer"},
+		{"string audience", func(c map[string]interface{}) { c["aud"] = "composer" }, ""},
+		{"invalid date", func(c map[string]interface{}) { c["exp"] = "tomorrow" }, "claim exp is not a date"},
+	}
+	for _, c := range cases {
+		claims := validClaims()
+		c.modify(claims)
+		_, err := v.Validate(sign(t, p.rsaKey, "RS256", "rsa", claims))
+		if c.err == "" {
+			require.NoError(t, err, c.name)
+		} else {
+			require.EqualError(t, err, c.err, c.name)
+		}
+	}
+}
+
+func TestKeySetUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := NewKeySet(server.URL, nil).Keys("")
+	require.EqualError(t, err, "cannot fetch keys: 404 Not Found")
+}
+
+func TestMiddleware(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.server.Close()
+
+	m := &Middleware{Validator: p.validator(), IdentityClaim: "email"}
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(Identity(r.Context())))
+		require.NoError(t, err)
+	}))
+
+	request := func(authorization string, state *tls.ConnectionState) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/composer/v1/version", nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		r.TLS = state
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	claims := validClaims()
+	claims["email"] = "user@example.com"
+	w := request("Bearer "+sign(t, p.rsaKey, "RS256", "rsa", claims), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "user@example.com", w.Body.String())
+
+	// without the identity claim
+	w = request("Bearer "+sign(t, p.rsaKey, "RS256", "rsa", validClaims()), nil)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, `Bearer realm="osbuild-composer", error="invalid_token"`, w.Header().Get("WWW-Authenticate"))
+
+	w = request("Basic dXNlcjpwYXNz", nil)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, `Bearer realm="osbuild-composer", error="invalid_request"`, w.Header().Get("WWW-Authenticate"))
+
+	state := &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "worker.example.com"}}}},
+	}
+	w = request("", state)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, `Bearer realm="osbuild-composer"`, w.Header().Get("WWW-Authenticate"))
+
+	m.AllowClientCerts = true
+	w = request("", state)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "worker.example.com", w.Body.String())
+	w = request("", &tls.ConnectionState{})
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestMiddlewareTenant(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.server.Close()
+
+	m := &Middleware{Validator: p.validator(), TenantClaim: "org_id", AllowClientCerts: true}
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(Identity(r.Context()) + "@" + Tenant(r.Context())))
+		require.NoError(t, err)
+	}))
+
+	request := func(authorization string, state *tls.ConnectionState) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/composer/v1/version", nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		r.TLS = state
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	claims := validClaims()
+	claims["org_id"] = "acme"
+	w := request("Bearer "+sign(t, p.rsaKey, "RS256", "rsa", claims), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "user-42@acme", w.Body.String())
+
+	claims["org_id"] = 12345
+	w = request("Bearer "+sign(t, p.rsaKey, "RS256", "rsa", claims), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "user-42@12345", w.Body.String())
+
+	// tokens must have a tenant
+	w = request("Bearer "+sign(t, p.rsaKey, "RS256", "rsa", validClaims()), nil)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// clients with certificates do not belong to a tenant
+	state := &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "worker.example.com"}}}},
+	}
+	w = request("", state)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "worker.example.com@", w.Body.String())
+}
+
+func TestRequireClientCert(t *testing.T) {
+	handler := RequireClientCert(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type contextKey int
+
+const (
+	identityKey contextKey = iota
+	tenantKey
+)
+
+// Identity returns the identity of the client of a request, which is empty
+// if it was not authenticated
+func Identity(ctx context.Context) string {
+	identity, _ := ctx.Value(identityKey).(string)
+	return identity
+}
+
+// Tenant returns the tenant the client of a request belongs to, which is
+// empty if tenants are not configured or the client was authenticated with a
+// certificate
+func Tenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey).(string)
+	return tenant
+}
+
+// Middleware authenticates the requests to a handler
+type Middleware struct {
+	Validator *Validator
+	// IdentityClaim is the claim which identifies clients, "sub" if it
+	// is empty
+	IdentityClaim string
+	// AllowClientCerts allows requests without a token from clients which
+	// have a verified TLS client certificate, which are identified by its
+	// common name
+	AllowClientCerts bool
+	// TenantClaim is the claim which identifies the tenant of clients,
+	// e.g. their organization. Tokens without it are rejected, unless it
+	// is empty.
+	TenantClaim string
+}
+
+// Handler returns a handler which passes requests with a valid bearer token
+// on to next, with the identity and tenant of their client in their context.
+// Other requests are rejected.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, tenant, ok := m.authenticate(w, r)
+		if !ok {
+			return
+		}
+		ctx := context.WithValue(r.Context(), identityKey, identity)
+		ctx = context.WithValue(ctx, tenantKey, tenant)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (m *Middleware) authenticate(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		if m.AllowClientCerts && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			return r.TLS.VerifiedChains[0][0].Subject.CommonName, "", true
+		}
+		unauthorized(w, "")
+		return "", "", false
+	}
+
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		unauthorized(w, "invalid_request")
+		return "", "", false
+	}
+
+	claims, err := m.Validator.Validate(strings.TrimSpace(parts[1]))
+	if err != nil {
+		log.Printf("Rejected a request to %s: %v", r.URL.Path, err)
+		unauthorized(w, "invalid_token")
+		return "", "", false
+	}
+
+	claim := m.IdentityClaim
+	if claim == "" {
+		claim = "sub"
+	}
+	identity, _ := claims.String(claim)
+	if identity == "" {
+		log.Printf("Rejected a request to %s: token has no %s claim", r.URL.Path, claim)
+		unauthorized(w, "invalid_token")
+		return "", "", false
+	}
+
+	var tenant string
+	if m.TenantClaim != "" {
+		// organization IDs are often numbers
+		if n, ok := claims[m.TenantClaim].(json.Number); ok {
+			tenant = n.String()
+		} else {
+			tenant, _ = claims.String(m.TenantClaim)
+		}
+		if tenant == "" {
+			log.Printf("Rejected a request to %s: token has no %s claim", r.URL.Path, m.TenantClaim)
+			unauthorized(w, "invalid_token")
+			return "", "", false
+		}
+	}
+	return identity, tenant, true
+}
+
+// unauthorized rejects a request as described in RFC 6750
+func unauthorized(w http.ResponseWriter, errorCode string) {
+	challenge := `Bearer realm="osbuild-composer"`
+	if errorCode != "" {
+		challenge += `, error="` + errorCode + `"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// RequireClientCert returns a handler which only passes requests from
+// clients with a verified TLS client certificate on to next
+func RequireClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			http.Error(w, "A client certificate is required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
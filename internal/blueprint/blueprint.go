@@ -3,7 +3,7 @@ package blueprint
 
 import (
 	"encoding/json"
-	"fmt"
+	"strings"
 
 	"github.com/coreos/go-semver/semver"
 )
@@ -16,7 +16,14 @@ type Blueprint struct {
 	Packages       []Package       `json:"packages" toml:"packages"`
 	Modules        []Package       `json:"modules" toml:"modules"`
 	Groups         []Group         `json:"groups" toml:"groups"`
+	EnabledModules []ModuleStream  `json:"enabled_modules,omitempty" toml:"enabled_modules,omitempty"`
+	Excludes       []string        `json:"exclude_packages,omitempty" toml:"exclude_packages,omitempty"`
+	Containers     []Container     `json:"containers,omitempty" toml:"containers,omitempty"`
 	Customizations *Customizations `json:"customizations,omitempty" toml:"customizations,omitempty"`
+
+	// Default values of the variables referenced in the blueprint, see
+	// Expand()
+	Variables map[string]string `json:"variables,omitempty" toml:"variables,omitempty"`
 }
 
 type Change struct {
@@ -55,6 +62,7 @@ func (b *Blueprint) DeepCopy() Blueprint {
 }
 
 // Initialize ensures that the blueprint has sane defaults for any missing fields
+// and validates it
 func (b *Blueprint) Initialize() error {
 	if b.Packages == nil {
 		b.Packages = []Package{}
@@ -68,10 +76,8 @@ func (b *Blueprint) Initialize() error {
 	if b.Version == "" {
 		b.Version = "0.0.0"
 	}
-	// Return an error if the version is not valid
-	_, err := semver.NewVersion(b.Version)
-	if err != nil {
-		return fmt.Errorf("Invalid 'version', must use Semantic Versioning: %s", err.Error())
+	if errs := b.Validate(); len(errs) > 0 {
+		return errs
 	}
 	return nil
 }
@@ -106,9 +112,22 @@ func (b *Blueprint) GetPackages() []string {
 	return packages
 }
 
+// GetExcludedPackages returns the packages, or globs of them, which must not
+// be installed even if they are pulled in by the image type or a dependency
+func (b *Blueprint) GetExcludedPackages() []string {
+	return append([]string{}, b.Excludes...)
+}
+
+// IsGroup returns true if the package entry refers to a comps group or
+// environment, e.g. @core, rather than to a single package
+func (p Package) IsGroup() bool {
+	return strings.HasPrefix(p.Name, "@")
+}
+
 func (p Package) ToNameVersion() string {
 	// Omit version to prevent all packages with prefix of name to be installed
-	if p.Version == "*" || p.Version == "" {
+	// Groups have no version, they are passed to the depsolver as-is
+	if p.Version == "*" || p.Version == "" || p.IsGroup() {
 		return p.Name
 	}
 
@@ -43,6 +43,12 @@ func TestBlueprintInitialize(t *testing.T) {
 		{Blueprint{Name: "bp-test-5", Description: "Invalid version 5", Version: "foo"}, true},
 		{Blueprint{Name: "bp-test-7", Description: "Zero version", Version: "0.0.0"}, false},
 		{Blueprint{Name: "bp-test-8", Description: "X.Y.Z version", Version: "2.1.3"}, false},
+		{Blueprint{Name: "bp-test-9", Description: "Duplicate user", Version: "1.0.0", Customizations: &Customizations{
+			User: []UserCustomization{{Name: "user"}, {Name: "user"}},
+		}}, true},
+		{Blueprint{Name: "bp-test-10", Description: "Unpinned container", Version: "1.0.0", Containers: []Container{
+			{Source: "registry.example.com/app:latest"},
+		}}, true},
 	}
 
 	for _, c := range cases {
@@ -84,12 +90,14 @@ func TestGetPackages(t *testing.T) {
 		Description: "Testing GetPackages function",
 		Version:     "0.0.1",
 		Packages: []Package{
-			{Name: "tmux", Version: "1.2"}},
+			{Name: "tmux", Version: "1.2"},
+			{Name: "@core", Version: "*"},
+			{Name: "@workstation-product-environment"}},
 		Modules: []Package{
 			{Name: "openssh-server", Version: "*"}},
 		Groups: []Group{
 			{Name: "anaconda-tools"}},
 	}
 	Received_packages := bp.GetPackages()
-	assert.ElementsMatch(t, []string{"tmux-1.2", "openssh-server", "@anaconda-tools"}, Received_packages)
+	assert.ElementsMatch(t, []string{"tmux-1.2", "@core", "@workstation-product-environment", "openssh-server", "@anaconda-tools"}, Received_packages)
 }
@@ -0,0 +1,63 @@
+package blueprint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Container images are pulled by digest only, so that the content of a
+// compose does not depend on when it was built.
+var containerDigestRegex = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// A Container specifies a container image to pre-load into the image's
+// container storage.
+type Container struct {
+	// Source is the image reference, pinned by digest:
+	// registry.example.com/app@sha256:...
+	Source string `json:"source" toml:"source"`
+	// Name is the name the image is stored under, defaults to the source
+	// repository
+	Name      string `json:"name,omitempty" toml:"name,omitempty"`
+	TLSVerify *bool  `json:"tls-verify,omitempty" toml:"tls-verify,omitempty"`
+}
+
+// Reference splits the source of the container into the repository and
+// the digest of the image.
+func (c Container) Reference() (string, string, error) {
+	i := strings.LastIndex(c.Source, "@")
+	if i <= 0 {
+		return "", "", fmt.Errorf("container source %q must be pinned by digest (name@sha256:...)", c.Source)
+	}
+	name, digest := c.Source[:i], c.Source[i+1:]
+	if !containerDigestRegex.MatchString(digest) {
+		return "", "", fmt.Errorf("container source %q has an invalid digest %q", c.Source, digest)
+	}
+	return name, digest, nil
+}
+
+// LocalName returns the name the image is stored under in the image.
+func (c Container) LocalName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	name, _, _ := c.Reference()
+	return name
+}
+
+func validateContainers(containers []Container) ValidationErrors {
+	var errs ValidationErrors
+	names := make(map[string]bool)
+	for i, c := range containers {
+		if _, _, err := c.Reference(); err != nil {
+			errs.add(fmt.Sprintf("containers[%d].source", i), err.Error(), "pin the image with the digest shown by 'skopeo inspect'")
+			continue
+		}
+		name := c.LocalName()
+		if names[name] {
+			errs.add(fmt.Sprintf("containers[%d].name", i), fmt.Sprintf("duplicate container name %q", name), "")
+		}
+		names[name] = true
+	}
+	return errs
+}
@@ -0,0 +1,45 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestContainerReference(t *testing.T) {
+	name, digest, err := Container{Source: "registry.example.com/app@" + testDigest}.Reference()
+	require.NoError(t, err)
+	assert.Equal(t, "registry.example.com/app", name)
+	assert.Equal(t, testDigest, digest)
+
+	for _, source := range []string{
+		"registry.example.com/app:latest",
+		"registry.example.com/app@sha256:abc",
+		"@" + testDigest,
+	} {
+		_, _, err := Container{Source: source}.Reference()
+		assert.Errorf(t, err, "source %q", source)
+	}
+}
+
+func TestContainerLocalName(t *testing.T) {
+	assert.Equal(t, "registry.example.com/app", Container{Source: "registry.example.com/app@" + testDigest}.LocalName())
+	assert.Equal(t, "localhost/app", Container{Source: "registry.example.com/app@" + testDigest, Name: "localhost/app"}.LocalName())
+}
+
+func TestValidateContainers(t *testing.T) {
+	assert.Empty(t, validateContainers([]Container{
+		{Source: "registry.example.com/app@" + testDigest},
+		{Source: "registry.example.com/app@" + testDigest, Name: "localhost/app"},
+	}))
+	assert.Len(t, validateContainers([]Container{
+		{Source: "registry.example.com/app@" + testDigest},
+		{Source: "registry.example.com/app@" + testDigest},
+	}), 1)
+	assert.Len(t, validateContainers([]Container{
+		{Source: "registry.example.com/app:latest"},
+	}), 1)
+}
@@ -1,15 +1,41 @@
 package blueprint
 
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
 type Customizations struct {
-	Hostname *string                `json:"hostname,omitempty" toml:"hostname,omitempty"`
-	Kernel   *KernelCustomization   `json:"kernel,omitempty" toml:"kernel,omitempty"`
-	SSHKey   []SSHKeyCustomization  `json:"sshkey,omitempty" toml:"sshkey,omitempty"`
-	User     []UserCustomization    `json:"user,omitempty" toml:"user,omitempty"`
-	Group    []GroupCustomization   `json:"group,omitempty" toml:"group,omitempty"`
-	Timezone *TimezoneCustomization `json:"timezone,omitempty" toml:"timezone,omitempty"`
-	Locale   *LocaleCustomization   `json:"locale,omitempty" toml:"locale,omitempty"`
-	Firewall *FirewallCustomization `json:"firewall,omitempty" toml:"firewall,omitempty"`
-	Services *ServicesCustomization `json:"services,omitempty" toml:"services,omitempty"`
+	Hostname     *string                    `json:"hostname,omitempty" toml:"hostname,omitempty"`
+	Kernel       *KernelCustomization       `json:"kernel,omitempty" toml:"kernel,omitempty"`
+	SSHKey       []SSHKeyCustomization      `json:"sshkey,omitempty" toml:"sshkey,omitempty"`
+	User         []UserCustomization        `json:"user,omitempty" toml:"user,omitempty"`
+	Group        []GroupCustomization       `json:"group,omitempty" toml:"group,omitempty"`
+	Timezone     *TimezoneCustomization     `json:"timezone,omitempty" toml:"timezone,omitempty"`
+	Locale       *LocaleCustomization       `json:"locale,omitempty" toml:"locale,omitempty"`
+	Firewall     *FirewallCustomization     `json:"firewall,omitempty" toml:"firewall,omitempty"`
+	Services     *ServicesCustomization     `json:"services,omitempty" toml:"services,omitempty"`
+	Directories  []DirectoryCustomization   `json:"directories,omitempty" toml:"directories,omitempty"`
+	Files        []FileCustomization        `json:"files,omitempty" toml:"files,omitempty"`
+	Repositories []RepositoryCustomization  `json:"repositories,omitempty" toml:"repositories,omitempty"`
+	OpenSCAP     *OpenSCAPCustomization     `json:"openscap,omitempty" toml:"openscap,omitempty"`
+	Ignition     *IgnitionCustomization     `json:"ignition,omitempty" toml:"ignition,omitempty"`
+	Subscription *SubscriptionCustomization `json:"subscription,omitempty" toml:"subscription,omitempty"`
+	FirstBoot    *FirstBootCustomization    `json:"firstboot,omitempty" toml:"firstboot,omitempty"`
+	Bootloader   *BootloaderCustomization   `json:"bootloader,omitempty" toml:"bootloader,omitempty"`
+	Disk         *DiskCustomization         `json:"disk,omitempty" toml:"disk,omitempty"`
+	CACerts      *CACustomization           `json:"cacerts,omitempty" toml:"cacerts,omitempty"`
+	DNF          *DNFCustomization          `json:"dnf,omitempty" toml:"dnf,omitempty"`
 }
 
 type KernelCustomization struct {
@@ -31,6 +57,7 @@ type UserCustomization struct {
 	Groups      []string `json:"groups,omitempty" toml:"groups,omitempty"`
 	UID         *int     `json:"uid,omitempty" toml:"uid,omitempty"`
 	GID         *int     `json:"gid,omitempty" toml:"gid,omitempty"`
+	ExpireDate  *int     `json:"expiredate,omitempty" toml:"expiredate,omitempty"` // days since the epoch
 }
 
 type GroupCustomization struct {
@@ -63,6 +90,324 @@ type ServicesCustomization struct {
 	Disabled []string `json:"disabled,omitempty" toml:"disabled,omitempty"`
 }
 
+// hostnameRegex matches a static hostname as accepted by hostnamectl: dot
+// separated labels of alphanumerics and dashes, not starting or ending with a
+// dash.
+var hostnameRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+// A DirectoryCustomization creates a directory in the image. User and Group
+// may be names or numeric ids, Mode is an octal string such as "0755".
+type DirectoryCustomization struct {
+	Path          string `json:"path" toml:"path"`
+	User          string `json:"user,omitempty" toml:"user,omitempty"`
+	Group         string `json:"group,omitempty" toml:"group,omitempty"`
+	Mode          string `json:"mode,omitempty" toml:"mode,omitempty"`
+	EnsureParents bool   `json:"ensure_parents,omitempty" toml:"ensure_parents,omitempty"`
+}
+
+// A FileCustomization embeds a small file in the image. Data is taken as-is,
+// unless Encoding is "base64".
+type FileCustomization struct {
+	Path     string `json:"path" toml:"path"`
+	User     string `json:"user,omitempty" toml:"user,omitempty"`
+	Group    string `json:"group,omitempty" toml:"group,omitempty"`
+	Mode     string `json:"mode,omitempty" toml:"mode,omitempty"`
+	Data     string `json:"data,omitempty" toml:"data,omitempty"`
+	Encoding string `json:"encoding,omitempty" toml:"encoding,omitempty"`
+}
+
+// maxFileSize limits the size of a single file customization, as its content
+// ends up inline in the manifest
+const maxFileSize = 512 * 1024
+
+// Content returns the decoded content of the file.
+func (f FileCustomization) Content() ([]byte, error) {
+	switch f.Encoding {
+	case "":
+		return []byte(f.Data), nil
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(f.Data)
+		if err != nil {
+			return nil, &CustomizationError{fmt.Sprintf("invalid base64 data for file %q: %v", f.Path, err)}
+		}
+		return data, nil
+	default:
+		return nil, &CustomizationError{fmt.Sprintf("unknown encoding %q for file %q", f.Encoding, f.Path)}
+	}
+}
+
+// An OpenSCAPCustomization remediates the image against the given profile.
+// If DataStream is empty, the distribution's default SCAP Security Guide
+// datastream is used.
+type OpenSCAPCustomization struct {
+	DataStream string `json:"datastream,omitempty" toml:"datastream,omitempty"`
+	ProfileID  string `json:"profile_id" toml:"profile_id"`
+}
+
+// A SubscriptionCustomization registers RHEL images with Red Hat
+// Subscription Management on first boot, using the activation key of the
+// given organization. ServerURL and BaseURL default to the Red Hat hosted
+// services.
+type SubscriptionCustomization struct {
+	Organization  int    `json:"organization" toml:"organization"`
+	ActivationKey string `json:"activation_key" toml:"activation_key"`
+	ServerURL     string `json:"server_url,omitempty" toml:"server_url,omitempty"`
+	BaseURL       string `json:"base_url,omitempty" toml:"base_url,omitempty"`
+	Insights      bool   `json:"insights,omitempty" toml:"insights,omitempty"`
+}
+
+func (s *SubscriptionCustomization) validate() error {
+	if s.Organization <= 0 {
+		return &CustomizationError{"subscription organization must be a positive number"}
+	}
+	if s.ActivationKey == "" {
+		return &CustomizationError{"subscription activation_key must not be empty"}
+	}
+	return nil
+}
+
+// An IgnitionCustomization configures the image to be provisioned by
+// Ignition on first boot, either from a config embedded in the image or from
+// a config fetched from a URL.
+type IgnitionCustomization struct {
+	Embedded  *EmbeddedIgnitionCustomization  `json:"embedded,omitempty" toml:"embedded,omitempty"`
+	FirstBoot *FirstBootIgnitionCustomization `json:"firstboot,omitempty" toml:"firstboot,omitempty"`
+}
+
+// EmbeddedIgnitionCustomization holds a base64-encoded Ignition config
+type EmbeddedIgnitionCustomization struct {
+	Config string `json:"config" toml:"config"`
+}
+
+type FirstBootIgnitionCustomization struct {
+	ProvisioningURL string `json:"url" toml:"url"`
+}
+
+// ignitionConfigPath is where an embedded Ignition config is installed. Base
+// configs are merged by Ignition regardless of the platform.
+const ignitionConfigPath = "/usr/lib/ignition/base.d/50-embedded.ign"
+
+func (i *IgnitionCustomization) validate() error {
+	if (i.Embedded == nil) == (i.FirstBoot == nil) {
+		return &CustomizationError{"Ignition needs exactly one of embedded or firstboot"}
+	}
+	if i.Embedded != nil {
+		if _, err := base64.StdEncoding.DecodeString(i.Embedded.Config); err != nil || i.Embedded.Config == "" {
+			return &CustomizationError{"embedded Ignition config must be non-empty and base64-encoded"}
+		}
+	}
+	if i.FirstBoot != nil {
+		u, err := url.Parse(i.FirstBoot.ProvisioningURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return &CustomizationError{fmt.Sprintf("invalid Ignition firstboot url %q", i.FirstBoot.ProvisioningURL)}
+		}
+	}
+	return nil
+}
+
+// KernelArgs returns the kernel arguments needed to run Ignition on the first
+// boot of the image.
+func (i *IgnitionCustomization) KernelArgs() []string {
+	args := []string{"ignition.firstboot", "ignition.platform.id=metal"}
+	if i.FirstBoot != nil {
+		args = append(args, "ignition.config.url="+i.FirstBoot.ProvisioningURL)
+	}
+	return args
+}
+
+// A FirstBootCustomization installs scripts which are run once, in order,
+// on the first boot of the image. The unit running them disables itself
+// afterwards.
+type FirstBootCustomization struct {
+	Scripts        []FirstBootScript `json:"scripts" toml:"scripts"`
+	WaitForNetwork bool              `json:"wait_for_network,omitempty" toml:"wait_for_network,omitempty"`
+}
+
+// A FirstBootScript is an executable, usually starting with a shebang line
+type FirstBootScript struct {
+	Name string `json:"name" toml:"name"`
+	Data string `json:"data" toml:"data"`
+}
+
+// firstBootScriptsDir is where the first-boot scripts are installed
+const firstBootScriptsDir = "/usr/local/libexec/first-boot"
+
+var firstBootScriptNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+func (f *FirstBootCustomization) validate(prefix string) ValidationErrors {
+	var errs ValidationErrors
+	if len(f.Scripts) == 0 {
+		errs.add(prefix+".scripts", "firstboot needs at least one script", "")
+	}
+	names := map[string]bool{}
+	for i, script := range f.Scripts {
+		field := fmt.Sprintf("%s.scripts[%d]", prefix, i)
+		if !firstBootScriptNameRegex.MatchString(script.Name) {
+			errs.add(field+".name", fmt.Sprintf("invalid firstboot script name %q", script.Name), "use letters, digits, '.', '_' and '-'")
+		} else if names[script.Name] {
+			errs.add(field+".name", fmt.Sprintf("duplicate firstboot script %q", script.Name), "")
+		}
+		names[script.Name] = true
+		if !strings.HasPrefix(script.Data, "#!") {
+			errs.add(field+".data", fmt.Sprintf("firstboot script %q must start with an interpreter line", script.Name), "for example #!/bin/bash")
+		}
+	}
+	return errs
+}
+
+// Commands returns the commands running the scripts on first boot
+func (f *FirstBootCustomization) Commands() []string {
+	commands := make([]string, len(f.Scripts))
+	for i, script := range f.Scripts {
+		commands[i] = path.Join(firstBootScriptsDir, script.Name)
+	}
+	return commands
+}
+
+// A BootloaderCustomization overrides the image type's GRUB settings.
+// Timeout is in seconds, DefaultEntry is used as GRUB_DEFAULT, i.e. either
+// "saved", the index or the title of a boot entry.
+type BootloaderCustomization struct {
+	Timeout      *int                        `json:"timeout,omitempty" toml:"timeout,omitempty"`
+	DefaultEntry string                      `json:"default_entry,omitempty" toml:"default_entry,omitempty"`
+	Serial       *SerialConsoleCustomization `json:"serial,omitempty" toml:"serial,omitempty"`
+}
+
+// A SerialConsoleCustomization enables GRUB and the kernel console on a
+// serial port, in addition to the regular console. Speed defaults to 115200.
+type SerialConsoleCustomization struct {
+	Device string `json:"device" toml:"device"`
+	Speed  int    `json:"speed,omitempty" toml:"speed,omitempty"`
+}
+
+var serialDeviceRegex = regexp.MustCompile(`^ttyS([0-9]|[1-9][0-9])$`)
+
+var serialSpeeds = map[int]bool{9600: true, 19200: true, 38400: true, 57600: true, 115200: true}
+
+func (b *BootloaderCustomization) validate(prefix string) ValidationErrors {
+	var errs ValidationErrors
+	if b.Timeout != nil && *b.Timeout < 0 {
+		errs.add(prefix+".timeout", fmt.Sprintf("invalid bootloader timeout %d", *b.Timeout), "use the number of seconds, 0 boots the default entry immediately")
+	}
+	if strings.ContainsAny(b.DefaultEntry, "\"'\n") {
+		errs.add(prefix+".default_entry", fmt.Sprintf("invalid default boot entry %q", b.DefaultEntry), "")
+	}
+	if b.Serial != nil {
+		if !serialDeviceRegex.MatchString(b.Serial.Device) {
+			errs.add(prefix+".serial.device", fmt.Sprintf("invalid serial device %q", b.Serial.Device), "for example ttyS0")
+		}
+		if b.Serial.Speed != 0 && !serialSpeeds[b.Serial.Speed] {
+			errs.add(prefix+".serial.speed", fmt.Sprintf("invalid serial speed %d", b.Serial.Speed), "for example 115200")
+		}
+	}
+	return errs
+}
+
+func (s *SerialConsoleCustomization) speed() int {
+	if s.Speed == 0 {
+		return 115200
+	}
+	return s.Speed
+}
+
+// KernelArgs returns the kernel arguments needed for the bootloader settings
+func (b *BootloaderCustomization) KernelArgs() []string {
+	if b.Serial == nil {
+		return nil
+	}
+	return []string{fmt.Sprintf("console=%s,%dn8", b.Serial.Device, b.Serial.speed())}
+}
+
+// SerialCommand returns the GRUB command setting up the serial console, or
+// an empty string if there is none
+func (b *BootloaderCustomization) SerialCommand() string {
+	if b.Serial == nil {
+		return ""
+	}
+	unit := strings.TrimPrefix(b.Serial.Device, "ttyS")
+	return fmt.Sprintf("serial --unit=%s --speed=%d", unit, b.Serial.speed())
+}
+
+// A CACustomization adds PEM-encoded certificates to the trust store of the
+// image, e.g. the CA of a TLS-intercepting proxy.
+type CACustomization struct {
+	PEMCerts []string `json:"pem_certs" toml:"pem_certs"`
+}
+
+// caAnchorsDir is where additional trust anchors are installed
+const caAnchorsDir = "/etc/pki/ca-trust/source/anchors"
+
+func (ca *CACustomization) validate(prefix string) ValidationErrors {
+	var errs ValidationErrors
+	if len(ca.PEMCerts) == 0 {
+		errs.add(prefix+".pem_certs", "cacerts needs at least one certificate", "")
+	}
+	for i, cert := range ca.PEMCerts {
+		if _, err := parseCertificate(cert); err != nil {
+			errs.add(fmt.Sprintf("%s.pem_certs[%d]", prefix, i), err.Error(), "use a certificate starting with -----BEGIN CERTIFICATE-----")
+		}
+	}
+	return errs
+}
+
+func parseCertificate(data string) (*x509.Certificate, error) {
+	block, rest := pem.Decode([]byte(data))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("not a PEM-encoded certificate")
+	}
+	if len(strings.TrimSpace(string(rest))) > 0 {
+		return nil, errors.New("only a single certificate is allowed per entry")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %v", err)
+	}
+	return cert, nil
+}
+
+// files returns the certificates as files in the anchors directory, named
+// after their fingerprint
+func (ca *CACustomization) files() []FileCustomization {
+	files := []FileCustomization{}
+	for _, data := range ca.PEMCerts {
+		cert, err := parseCertificate(data)
+		if err != nil {
+			continue
+		}
+		fingerprint := sha256.Sum256(cert.Raw)
+		files = append(files, FileCustomization{
+			Path: path.Join(caAnchorsDir, hex.EncodeToString(fingerprint[:8])+".pem"),
+			Mode: "0644",
+			Data: data,
+		})
+	}
+	return files
+}
+
+// A DNFCustomization sets options of dnf.conf in the image. It does not
+// affect how the image is built.
+type DNFCustomization struct {
+	InstallWeakDeps *bool    `json:"install_weak_deps,omitempty" toml:"install_weak_deps,omitempty"`
+	Exclude         []string `json:"exclude,omitempty" toml:"exclude,omitempty"`
+	Proxy           string   `json:"proxy,omitempty" toml:"proxy,omitempty"`
+}
+
+func (d *DNFCustomization) validate(prefix string) ValidationErrors {
+	var errs ValidationErrors
+	for i, pattern := range d.Exclude {
+		if pattern == "" || strings.ContainsAny(pattern, " \t\n,") {
+			errs.add(fmt.Sprintf("%s.exclude[%d]", prefix, i), fmt.Sprintf("invalid exclude pattern %q", pattern), "use a single package name or glob per entry")
+		}
+	}
+	if d.Proxy != "" {
+		u, err := url.Parse(d.Proxy)
+		if err != nil || u.Host == "" || !(u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "socks5" || u.Scheme == "socks5h") {
+			errs.add(prefix+".proxy", fmt.Sprintf("invalid proxy %q", d.Proxy), "for example http://proxy.example.com:3128")
+		}
+	}
+	return errs
+}
+
 type CustomizationError struct {
 	Message string
 }
@@ -71,6 +416,200 @@ func (e *CustomizationError) Error() string {
 	return e.Message
 }
 
+// Validate checks the customizations for conflicts which would otherwise
+// only show up when building the image. Only the first problem is reported,
+// use validate() to get all of them.
+func (c *Customizations) Validate() error {
+	if errs := c.validate("customizations"); len(errs) > 0 {
+		return &CustomizationError{errs[0].Reason}
+	}
+	return nil
+}
+
+// validate returns all problems of the customizations, with field paths
+// below prefix.
+func (c *Customizations) validate(prefix string) ValidationErrors {
+	if c == nil {
+		return nil
+	}
+
+	var errs ValidationErrors
+
+	if c.Hostname != nil {
+		if len(*c.Hostname) > 64 || !hostnameRegex.MatchString(*c.Hostname) {
+			errs.add(prefix+".hostname", fmt.Sprintf("invalid hostname %q", *c.Hostname),
+				"use at most 64 characters of letters, digits, '-' and '.'")
+		}
+	}
+
+	names := map[string]bool{}
+	uids := map[int]string{}
+	for i, user := range c.User {
+		field := fmt.Sprintf("%s.user[%d]", prefix, i)
+		if user.Name == "" {
+			errs.add(field+".name", "user name must not be empty", "")
+		} else if names[user.Name] {
+			errs.add(field+".name", fmt.Sprintf("duplicate user %q", user.Name), "merge the settings into a single [[customizations.user]] entry")
+		}
+		names[user.Name] = true
+
+		if user.UID != nil {
+			if *user.UID < 0 {
+				errs.add(field+".uid", fmt.Sprintf("invalid uid %d for user %q", *user.UID, user.Name), "")
+			} else if other, exists := uids[*user.UID]; exists {
+				errs.add(field+".uid", fmt.Sprintf("users %q and %q have the same uid %d", other, user.Name, *user.UID), "")
+			} else {
+				uids[*user.UID] = user.Name
+			}
+		}
+		if user.GID != nil && *user.GID < 0 {
+			errs.add(field+".gid", fmt.Sprintf("invalid gid %d for user %q", *user.GID, user.Name), "")
+		}
+		if user.Shell != nil && !path.IsAbs(*user.Shell) {
+			errs.add(field+".shell", fmt.Sprintf("shell %q for user %q must be an absolute path", *user.Shell, user.Name), "for example /bin/bash")
+		}
+		if user.ExpireDate != nil && *user.ExpireDate < 0 {
+			errs.add(field+".expiredate", fmt.Sprintf("invalid expiration date %d for user %q", *user.ExpireDate, user.Name), "use the number of days since 1970-01-01")
+		}
+	}
+
+	ids := map[string]bool{}
+	for i, repo := range c.Repositories {
+		field := fmt.Sprintf("%s.repositories[%d]", prefix, i)
+		if err := repo.validate(); err != nil {
+			errs.add(field, err.Error(), "")
+		} else if ids[repo.Id] {
+			errs.add(field+".id", fmt.Sprintf("duplicate repository %q", repo.Id), "")
+		}
+		ids[repo.Id] = true
+	}
+
+	if c.OpenSCAP != nil {
+		if c.OpenSCAP.ProfileID == "" {
+			errs.add(prefix+".openscap.profile_id", "OpenSCAP profile_id must not be empty", "for example xccdf_org.ssgproject.content_profile_cis")
+		}
+		if c.OpenSCAP.DataStream != "" && !path.IsAbs(c.OpenSCAP.DataStream) {
+			errs.add(prefix+".openscap.datastream", fmt.Sprintf("OpenSCAP datastream %q must be an absolute path", c.OpenSCAP.DataStream), "")
+		}
+	}
+
+	if c.Ignition != nil {
+		if err := c.Ignition.validate(); err != nil {
+			errs.add(prefix+".ignition", err.Error(), "")
+		}
+	}
+
+	if c.Subscription != nil {
+		if err := c.Subscription.validate(); err != nil {
+			errs.add(prefix+".subscription", err.Error(), "")
+		}
+	}
+
+	if c.FirstBoot != nil {
+		errs = append(errs, c.FirstBoot.validate(prefix+".firstboot")...)
+	}
+
+	if c.Bootloader != nil {
+		errs = append(errs, c.Bootloader.validate(prefix+".bootloader")...)
+	}
+
+	if c.Disk != nil {
+		errs = append(errs, c.Disk.validate(prefix+".disk")...)
+	}
+
+	if c.CACerts != nil {
+		errs = append(errs, c.CACerts.validate(prefix+".cacerts")...)
+	}
+
+	if c.DNF != nil {
+		errs = append(errs, c.DNF.validate(prefix+".dnf")...)
+	}
+
+	errs = append(errs, c.validateFilesystem(prefix)...)
+
+	gids := map[int]string{}
+	for i, group := range c.Group {
+		if group.GID == nil {
+			continue
+		}
+		field := fmt.Sprintf("%s.group[%d].gid", prefix, i)
+		if *group.GID < 0 {
+			errs.add(field, fmt.Sprintf("invalid gid %d for group %q", *group.GID, group.Name), "")
+		} else if other, exists := gids[*group.GID]; exists {
+			errs.add(field, fmt.Sprintf("groups %q and %q have the same gid %d", other, group.Name, *group.GID), "")
+		} else {
+			gids[*group.GID] = group.Name
+		}
+	}
+
+	for i, key := range c.SSHKey {
+		field := fmt.Sprintf("%s.sshkey[%d]", prefix, i)
+		if key.User == "" {
+			errs.add(field+".user", "sshkey user must not be empty", "")
+		}
+		if err := validateSSHKey(key.Key); err != nil {
+			errs.add(field+".key", err.Error(), sshKeySuggestion)
+		}
+	}
+	for i, user := range c.User {
+		if user.Key != nil {
+			if err := validateSSHKey(*user.Key); err != nil {
+				errs.add(fmt.Sprintf("%s.user[%d].key", prefix, i), err.Error(), sshKeySuggestion)
+			}
+		}
+	}
+
+	return errs
+}
+
+func (c *Customizations) validateFilesystem(prefix string) ValidationErrors {
+	var errs ValidationErrors
+	paths := map[string]bool{}
+	checkPath := func(field, p string) {
+		if !path.IsAbs(p) || path.Clean(p) != p || p == "/" {
+			errs.add(field, fmt.Sprintf("path %q must be an absolute, normalized path below /", p), "for example /etc/myapp")
+		} else if paths[p] {
+			errs.add(field, fmt.Sprintf("path %q is customized more than once", p), "")
+		}
+		paths[p] = true
+	}
+	checkMode := func(field, p, mode string, max uint64) {
+		if mode == "" {
+			return
+		}
+		if m, err := strconv.ParseUint(mode, 8, 32); err != nil || m > max {
+			errs.add(field, fmt.Sprintf("invalid mode %q for path %q", mode, p), "use an octal mode such as 0644")
+		}
+	}
+
+	// GetDirectories() and GetFiles() include entries implied by other
+	// customizations, which have no field of their own
+	fieldName := func(kind string, i, n int, sub string) string {
+		if i < n {
+			return fmt.Sprintf("%s.%s[%d].%s", prefix, kind, i, sub)
+		}
+		return prefix
+	}
+
+	for i, dir := range c.GetDirectories() {
+		checkPath(fieldName("directories", i, len(c.Directories), "path"), dir.Path)
+		checkMode(fieldName("directories", i, len(c.Directories), "mode"), dir.Path, dir.Mode, 07777)
+	}
+
+	for i, file := range c.GetFiles() {
+		checkPath(fieldName("files", i, len(c.Files), "path"), file.Path)
+		checkMode(fieldName("files", i, len(c.Files), "mode"), file.Path, file.Mode, 07777)
+		data, err := file.Content()
+		if err != nil {
+			errs.add(fieldName("files", i, len(c.Files), "data"), err.Error(), "")
+		} else if len(data) > maxFileSize {
+			errs.add(fieldName("files", i, len(c.Files), "data"), fmt.Sprintf("file %q is larger than %d bytes", file.Path, maxFileSize), "install large files with a package instead")
+		}
+	}
+
+	return errs
+}
+
 func (c *Customizations) GetHostname() *string {
 	if c == nil {
 		return nil
@@ -176,3 +715,136 @@ func (c *Customizations) GetServices() *ServicesCustomization {
 
 	return c.Services
 }
+
+func (c *Customizations) GetDirectories() []DirectoryCustomization {
+	if c == nil {
+		return nil
+	}
+
+	if (c.Ignition == nil || c.Ignition.Embedded == nil) && c.FirstBoot == nil && c.CACerts == nil {
+		return c.Directories
+	}
+
+	dirs := append([]DirectoryCustomization{}, c.Directories...)
+	if c.Ignition != nil && c.Ignition.Embedded != nil {
+		dirs = append(dirs, DirectoryCustomization{
+			Path:          path.Dir(ignitionConfigPath),
+			EnsureParents: true,
+		})
+	}
+	if c.FirstBoot != nil {
+		dirs = append(dirs, DirectoryCustomization{
+			Path:          firstBootScriptsDir,
+			EnsureParents: true,
+		})
+	}
+	if c.CACerts != nil {
+		dirs = append(dirs, DirectoryCustomization{
+			Path:          caAnchorsDir,
+			EnsureParents: true,
+		})
+	}
+
+	return dirs
+}
+
+func (c *Customizations) GetFiles() []FileCustomization {
+	if c == nil {
+		return nil
+	}
+
+	// repositories, embedded Ignition configs, first-boot scripts and CA
+	// certificates are just files as well
+	if len(c.Repositories) == 0 && (c.Ignition == nil || c.Ignition.Embedded == nil) && c.FirstBoot == nil && c.CACerts == nil {
+		return c.Files
+	}
+
+	files := append([]FileCustomization{}, c.Files...)
+	files = append(files, repositoryFiles(c.Repositories)...)
+	if c.Ignition != nil && c.Ignition.Embedded != nil {
+		files = append(files, FileCustomization{
+			Path:     ignitionConfigPath,
+			Mode:     "0600",
+			Data:     c.Ignition.Embedded.Config,
+			Encoding: "base64",
+		})
+	}
+	if c.FirstBoot != nil {
+		for _, script := range c.FirstBoot.Scripts {
+			files = append(files, FileCustomization{
+				Path: path.Join(firstBootScriptsDir, script.Name),
+				Mode: "0755",
+				Data: script.Data,
+			})
+		}
+	}
+	if c.CACerts != nil {
+		files = append(files, c.CACerts.files()...)
+	}
+
+	return files
+}
+
+func (c *Customizations) GetOpenSCAP() *OpenSCAPCustomization {
+	if c == nil {
+		return nil
+	}
+
+	return c.OpenSCAP
+}
+
+func (c *Customizations) GetIgnition() *IgnitionCustomization {
+	if c == nil {
+		return nil
+	}
+
+	return c.Ignition
+}
+
+func (c *Customizations) GetFirstBoot() *FirstBootCustomization {
+	if c == nil {
+		return nil
+	}
+
+	return c.FirstBoot
+}
+
+func (c *Customizations) GetBootloader() *BootloaderCustomization {
+	if c == nil {
+		return nil
+	}
+
+	return c.Bootloader
+}
+
+func (c *Customizations) GetDisk() *DiskCustomization {
+	if c == nil {
+		return nil
+	}
+
+	return c.Disk
+}
+
+func (c *Customizations) GetCACerts() *CACustomization {
+	if c == nil {
+		return nil
+	}
+
+	return c.CACerts
+}
+
+func (c *Customizations) GetDNF() *DNFCustomization {
+	if c == nil {
+		return nil
+	}
+
+	return c.DNF
+}
+
+func (c *Customizations) GetSubscription() *SubscriptionCustomization {
+	if c == nil {
+		return nil
+	}
+
+	return c.Subscription
+}
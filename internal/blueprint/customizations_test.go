@@ -2,6 +2,7 @@ package blueprint
 
 import (
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -265,3 +266,277 @@ func TestNilGetTimezoneSettings(t *testing.T) {
 	assert.Nil(t, retTimezone)
 	assert.Nil(t, retNTPServers)
 }
+
+func TestValidateUsers(t *testing.T) {
+	uid := 1000
+	otherUID := 1001
+	gid := 1000
+	negative := -1
+	shell := "/bin/zsh"
+	relativeShell := "zsh"
+	expireDate := 18628
+
+	cases := []struct {
+		Name           string
+		Customizations *Customizations
+		ExpectedError  string
+	}{
+		{"nil customizations", nil, ""},
+		{"valid users", &Customizations{
+			User: []UserCustomization{
+				{Name: "alice", UID: &uid, GID: &gid, Shell: &shell, ExpireDate: &expireDate},
+				{Name: "bob", UID: &otherUID},
+			},
+			Group: []GroupCustomization{{Name: "admins", GID: &gid}},
+		}, ""},
+		{"empty user name", &Customizations{
+			User: []UserCustomization{{Name: ""}},
+		}, "user name must not be empty"},
+		{"duplicate user name", &Customizations{
+			User: []UserCustomization{{Name: "alice"}, {Name: "alice"}},
+		}, `duplicate user "alice"`},
+		{"duplicate uid", &Customizations{
+			User: []UserCustomization{{Name: "alice", UID: &uid}, {Name: "bob", UID: &uid}},
+		}, `users "alice" and "bob" have the same uid 1000`},
+		{"negative uid", &Customizations{
+			User: []UserCustomization{{Name: "alice", UID: &negative}},
+		}, `invalid uid -1 for user "alice"`},
+		{"relative shell", &Customizations{
+			User: []UserCustomization{{Name: "alice", Shell: &relativeShell}},
+		}, `shell "zsh" for user "alice" must be an absolute path`},
+		{"negative expiration date", &Customizations{
+			User: []UserCustomization{{Name: "alice", ExpireDate: &negative}},
+		}, `invalid expiration date -1 for user "alice"`},
+		{"duplicate gid", &Customizations{
+			Group: []GroupCustomization{{Name: "admins", GID: &gid}, {Name: "users", GID: &gid}},
+		}, `groups "admins" and "users" have the same gid 1000`},
+	}
+
+	for _, c := range cases {
+		err := c.Customizations.Validate()
+		if c.ExpectedError == "" {
+			assert.NoErrorf(t, err, c.Name)
+		} else {
+			assert.EqualErrorf(t, err, c.ExpectedError, c.Name)
+			assert.IsTypef(t, &CustomizationError{}, err, c.Name)
+		}
+	}
+}
+
+func TestValidateHostname(t *testing.T) {
+	valid := []string{"localhost", "my-host", "my-host.example.com", "a1"}
+	invalid := []string{"", "-host", "host-", "my_host", "my host", "host..example.com", strings.Repeat("a", 65)}
+
+	for _, hostname := range valid {
+		h := hostname
+		assert.NoErrorf(t, (&Customizations{Hostname: &h}).Validate(), "hostname %q", hostname)
+	}
+	for _, hostname := range invalid {
+		h := hostname
+		assert.Errorf(t, (&Customizations{Hostname: &h}).Validate(), "hostname %q", hostname)
+	}
+}
+
+func TestFileContent(t *testing.T) {
+	data, err := FileCustomization{Path: "/etc/motd", Data: "hello\n"}.Content()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello\n"), data)
+
+	data, err = FileCustomization{Path: "/etc/motd", Data: "aGVsbG8K", Encoding: "base64"}.Content()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello\n"), data)
+
+	_, err = FileCustomization{Path: "/etc/motd", Data: "not base64!", Encoding: "base64"}.Content()
+	assert.Error(t, err)
+
+	_, err = FileCustomization{Path: "/etc/motd", Data: "hello", Encoding: "rot13"}.Content()
+	assert.EqualError(t, err, `unknown encoding "rot13" for file "/etc/motd"`)
+}
+
+func TestValidateFilesystem(t *testing.T) {
+	cases := []struct {
+		Name           string
+		Customizations Customizations
+		ExpectedError  string
+	}{
+		{"valid", Customizations{
+			Directories: []DirectoryCustomization{{Path: "/etc/myapp", Mode: "0750", User: "root", Group: "wheel", EnsureParents: true}},
+			Files:       []FileCustomization{{Path: "/etc/myapp/config", Mode: "0640", Data: "key=value"}},
+		}, ""},
+		{"relative path", Customizations{
+			Files: []FileCustomization{{Path: "etc/motd"}},
+		}, `path "etc/motd" must be an absolute, normalized path below /`},
+		{"unclean path", Customizations{
+			Directories: []DirectoryCustomization{{Path: "/etc/../root"}},
+		}, `path "/etc/../root" must be an absolute, normalized path below /`},
+		{"root", Customizations{
+			Directories: []DirectoryCustomization{{Path: "/"}},
+		}, `path "/" must be an absolute, normalized path below /`},
+		{"duplicate path", Customizations{
+			Directories: []DirectoryCustomization{{Path: "/etc/motd"}},
+			Files:       []FileCustomization{{Path: "/etc/motd"}},
+		}, `path "/etc/motd" is customized more than once`},
+		{"invalid mode", Customizations{
+			Files: []FileCustomization{{Path: "/etc/motd", Mode: "0999"}},
+		}, `invalid mode "0999" for path "/etc/motd"`},
+		{"too large", Customizations{
+			Files: []FileCustomization{{Path: "/etc/motd", Data: strings.Repeat("a", maxFileSize+1)}},
+		}, `file "/etc/motd" is larger than 524288 bytes`},
+	}
+
+	for _, c := range cases {
+		err := c.Customizations.Validate()
+		if c.ExpectedError == "" {
+			assert.NoErrorf(t, err, c.Name)
+		} else {
+			assert.EqualErrorf(t, err, c.ExpectedError, c.Name)
+		}
+	}
+}
+
+func TestValidateOpenSCAP(t *testing.T) {
+	assert.NoError(t, (&Customizations{OpenSCAP: &OpenSCAPCustomization{ProfileID: "cis"}}).Validate())
+	assert.EqualError(t, (&Customizations{OpenSCAP: &OpenSCAPCustomization{}}).Validate(), "OpenSCAP profile_id must not be empty")
+	assert.EqualError(t, (&Customizations{OpenSCAP: &OpenSCAPCustomization{ProfileID: "cis", DataStream: "ds.xml"}}).Validate(), `OpenSCAP datastream "ds.xml" must be an absolute path`)
+}
+
+func TestIgnition(t *testing.T) {
+	firstboot := &IgnitionCustomization{
+		FirstBoot: &FirstBootIgnitionCustomization{ProvisioningURL: "http://example.com/config.ign"},
+	}
+	assert.NoError(t, (&Customizations{Ignition: firstboot}).Validate())
+	assert.Equal(t, []string{"ignition.firstboot", "ignition.platform.id=metal", "ignition.config.url=http://example.com/config.ign"}, firstboot.KernelArgs())
+	assert.Empty(t, (&Customizations{Ignition: firstboot}).GetFiles())
+
+	embedded := &IgnitionCustomization{
+		Embedded: &EmbeddedIgnitionCustomization{Config: "eyJpZ25pdGlvbiI6IHt9fQ=="},
+	}
+	c := &Customizations{Ignition: embedded}
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, []string{"ignition.firstboot", "ignition.platform.id=metal"}, embedded.KernelArgs())
+	assert.Equal(t, []DirectoryCustomization{{Path: "/usr/lib/ignition/base.d", EnsureParents: true}}, c.GetDirectories())
+	assert.Equal(t, []FileCustomization{{Path: "/usr/lib/ignition/base.d/50-embedded.ign", Mode: "0600", Data: "eyJpZ25pdGlvbiI6IHt9fQ==", Encoding: "base64"}}, c.GetFiles())
+
+	assert.EqualError(t, (&Customizations{Ignition: &IgnitionCustomization{}}).Validate(), "Ignition needs exactly one of embedded or firstboot")
+	assert.EqualError(t, (&Customizations{Ignition: &IgnitionCustomization{
+		Embedded: &EmbeddedIgnitionCustomization{Config: "not base64"},
+	}}).Validate(), "embedded Ignition config must be non-empty and base64-encoded")
+	assert.EqualError(t, (&Customizations{Ignition: &IgnitionCustomization{
+		FirstBoot: &FirstBootIgnitionCustomization{ProvisioningURL: "config.ign"},
+	}}).Validate(), `invalid Ignition firstboot url "config.ign"`)
+}
+
+func TestSubscription(t *testing.T) {
+	subscription := &SubscriptionCustomization{Organization: 123, ActivationKey: "key"}
+	assert.NoError(t, (&Customizations{Subscription: subscription}).Validate())
+	assert.Equal(t, subscription, (&Customizations{Subscription: subscription}).GetSubscription())
+	assert.Nil(t, (*Customizations)(nil).GetSubscription())
+
+	assert.EqualError(t, (&Customizations{Subscription: &SubscriptionCustomization{ActivationKey: "key"}}).Validate(), "subscription organization must be a positive number")
+	assert.EqualError(t, (&Customizations{Subscription: &SubscriptionCustomization{Organization: 123}}).Validate(), "subscription activation_key must not be empty")
+}
+
+func TestFirstBoot(t *testing.T) {
+	c := &Customizations{FirstBoot: &FirstBootCustomization{
+		Scripts: []FirstBootScript{
+			{Name: "10-setup", Data: "#!/bin/bash\necho setup\n"},
+			{Name: "20-enroll.py", Data: "#!/usr/bin/python3\n"},
+		},
+	}}
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, []string{"/usr/local/libexec/first-boot/10-setup", "/usr/local/libexec/first-boot/20-enroll.py"}, c.FirstBoot.Commands())
+	assert.Equal(t, []DirectoryCustomization{{Path: "/usr/local/libexec/first-boot", EnsureParents: true}}, c.GetDirectories())
+	assert.Equal(t, []FileCustomization{
+		{Path: "/usr/local/libexec/first-boot/10-setup", Mode: "0755", Data: "#!/bin/bash\necho setup\n"},
+		{Path: "/usr/local/libexec/first-boot/20-enroll.py", Mode: "0755", Data: "#!/usr/bin/python3\n"},
+	}, c.GetFiles())
+
+	errs := (&Customizations{FirstBoot: &FirstBootCustomization{
+		Scripts: []FirstBootScript{
+			{Name: "../setup", Data: "#!/bin/sh\n"},
+			{Name: "run", Data: "echo hello"},
+			{Name: "run", Data: "#!/bin/sh\n"},
+		},
+	}}).validate("customizations")
+	assert.Equal(t, ValidationErrors{
+		{Field: "customizations.firstboot.scripts[0].name", Reason: `invalid firstboot script name "../setup"`, Suggestion: "use letters, digits, '.', '_' and '-'"},
+		{Field: "customizations.firstboot.scripts[1].data", Reason: `firstboot script "run" must start with an interpreter line`, Suggestion: "for example #!/bin/bash"},
+		{Field: "customizations.firstboot.scripts[2].name", Reason: `duplicate firstboot script "run"`},
+		{Field: "customizations", Reason: `path "/usr/local/libexec/first-boot/run" is customized more than once`},
+	}, errs)
+
+	assert.EqualError(t, (&Customizations{FirstBoot: &FirstBootCustomization{}}).Validate(), "firstboot needs at least one script")
+}
+
+func TestBootloader(t *testing.T) {
+	timeout := 5
+	b := &BootloaderCustomization{Timeout: &timeout, DefaultEntry: "saved", Serial: &SerialConsoleCustomization{Device: "ttyS1"}}
+	c := &Customizations{Bootloader: b}
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, b, c.GetBootloader())
+	assert.Equal(t, []string{"console=ttyS1,115200n8"}, b.KernelArgs())
+	assert.Equal(t, "serial --unit=1 --speed=115200", b.SerialCommand())
+
+	b = &BootloaderCustomization{Timeout: &timeout}
+	assert.Empty(t, b.KernelArgs())
+	assert.Equal(t, "", b.SerialCommand())
+
+	negative := -1
+	errs := (&Customizations{Bootloader: &BootloaderCustomization{
+		Timeout:      &negative,
+		DefaultEntry: `"; reboot`,
+		Serial:       &SerialConsoleCustomization{Device: "/dev/ttyS0", Speed: 1234},
+	}}).validate("customizations")
+	fields := make([]string, len(errs))
+	for i, e := range errs {
+		fields[i] = e.Field
+	}
+	assert.Equal(t, []string{
+		"customizations.bootloader.timeout",
+		"customizations.bootloader.default_entry",
+		"customizations.bootloader.serial.device",
+		"customizations.bootloader.serial.speed",
+	}, fields)
+}
+
+const testCACert = `-----BEGIN CERTIFICATE-----
+MIIBjTCCATOgAwIBAgIUTlarKT0+1A1h4x+7F/Agz8Zw9AowCgYIKoZIzj0EAwIw
+GzEZMBcGA1UEAwwQRXhhbXBsZSBQcm94eSBDQTAgFw0yNjEwMTUxMDUzNTJaGA8y
+MTI2MDkyMTEwNTM1MlowGzEZMBcGA1UEAwwQRXhhbXBsZSBQcm94eSBDQTBZMBMG
+ByqGSM49AgEGCCqGSM49AwEHA0IABFRZ7L9RAVcOESU4wl1NDM4x1Q9HGI9Ic1CY
+G1I+sxw8ZHZYsAvV/mtJOt48bpbZ6QB17IPHbXGRppJ/409iVeqjUzBRMB0GA1Ud
+DgQWBBSUhIfUp2eDsvlihgOzc/CWiUhX8jAfBgNVHSMEGDAWgBSUhIfUp2eDsvli
+hgOzc/CWiUhX8jAPBgNVHRMBAf8EBTADAQH/MAoGCCqGSM49BAMCA0gAMEUCIQDE
+cp3q5mUwIov723BLTLhY30W4HDiMdUg3T9PhuIEDAgIgd0yBkYnIELY8/xq+vSm9
+uDz9jvspKmLS6DcmYpecGZw=
+-----END CERTIFICATE-----
+`
+
+func TestCACerts(t *testing.T) {
+	c := &Customizations{CACerts: &CACustomization{PEMCerts: []string{testCACert}}}
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, []DirectoryCustomization{{Path: "/etc/pki/ca-trust/source/anchors", EnsureParents: true}}, c.GetDirectories())
+	assert.Equal(t, []FileCustomization{{Path: "/etc/pki/ca-trust/source/anchors/e79944fb7b650f26.pem", Mode: "0644", Data: testCACert}}, c.GetFiles())
+
+	assert.EqualError(t, (&Customizations{CACerts: &CACustomization{}}).Validate(), "cacerts needs at least one certificate")
+	assert.EqualError(t, (&Customizations{CACerts: &CACustomization{PEMCerts: []string{"not a cert"}}}).Validate(), "not a PEM-encoded certificate")
+	assert.EqualError(t, (&Customizations{CACerts: &CACustomization{PEMCerts: []string{testCACert + testCACert}}}).Validate(), "only a single certificate is allowed per entry")
+	broken := strings.Replace(testCACert, "MIIB", "MIIA", 1)
+	assert.Error(t, (&Customizations{CACerts: &CACustomization{PEMCerts: []string{broken}}}).Validate())
+}
+
+func TestDNF(t *testing.T) {
+	weakDeps := false
+	c := &Customizations{DNF: &DNFCustomization{
+		InstallWeakDeps: &weakDeps,
+		Exclude:         []string{"kernel-debug*", "firefox"},
+		Proxy:           "http://proxy.example.com:3128",
+	}}
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, c.DNF, c.GetDNF())
+	assert.Nil(t, (*Customizations)(nil).GetDNF())
+
+	assert.EqualError(t, (&Customizations{DNF: &DNFCustomization{Exclude: []string{"a b"}}}).Validate(), `invalid exclude pattern "a b"`)
+	assert.EqualError(t, (&Customizations{DNF: &DNFCustomization{Proxy: "proxy.example.com"}}).Validate(), `invalid proxy "proxy.example.com"`)
+	assert.EqualError(t, (&Customizations{DNF: &DNFCustomization{Proxy: "ftp://proxy.example.com"}}).Validate(), `invalid proxy "ftp://proxy.example.com"`)
+}
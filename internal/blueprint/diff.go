@@ -0,0 +1,206 @@
+package blueprint
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// DiffKind is the kind of a Difference between two blueprints
+type DiffKind string
+
+const (
+	DiffAdded   DiffKind = "added"
+	DiffRemoved DiffKind = "removed"
+	DiffChanged DiffKind = "changed"
+)
+
+// A Difference is a single change between two blueprints.
+//
+// Section names the part of the blueprint which changed, as used by the
+// weldr diff API: Description, Version, Module, Package, Group,
+// ExcludePackage, EnabledModule, Container, Variable or
+// Customizations.<key>, e.g. Customizations.hostname. Name identifies the
+// item of list sections and is empty otherwise. Old is nil for added items, New for removed ones.
+type Difference struct {
+	Kind    DiffKind
+	Section string
+	Name    string
+	Old     interface{}
+	New     interface{}
+}
+
+// Diff returns the differences between the blueprints old and new, in a
+// stable order.
+func Diff(old, new *Blueprint) []Difference {
+	var diffs []Difference
+
+	if old.Description != new.Description {
+		diffs = append(diffs, Difference{DiffChanged, "Description", "", old.Description, new.Description})
+	}
+	if old.Version != new.Version {
+		diffs = append(diffs, Difference{DiffChanged, "Version", "", old.Version, new.Version})
+	}
+
+	diffs = append(diffs, diffPackages("Module", old.Modules, new.Modules)...)
+	diffs = append(diffs, diffPackages("Package", old.Packages, new.Packages)...)
+
+	oldGroups := make([]namedItem, len(old.Groups))
+	for i, g := range old.Groups {
+		oldGroups[i] = namedItem{g.Name, g}
+	}
+	newGroups := make([]namedItem, len(new.Groups))
+	for i, g := range new.Groups {
+		newGroups[i] = namedItem{g.Name, g}
+	}
+	diffs = append(diffs, diffItems("Group", oldGroups, newGroups)...)
+
+	oldExcludes := make([]namedItem, len(old.Excludes))
+	for i, name := range old.Excludes {
+		oldExcludes[i] = namedItem{name, name}
+	}
+	newExcludes := make([]namedItem, len(new.Excludes))
+	for i, name := range new.Excludes {
+		newExcludes[i] = namedItem{name, name}
+	}
+	diffs = append(diffs, diffItems("ExcludePackage", oldExcludes, newExcludes)...)
+
+	oldModules := make([]namedItem, len(old.EnabledModules))
+	for i, m := range old.EnabledModules {
+		oldModules[i] = namedItem{m.Name, m}
+	}
+	newModules := make([]namedItem, len(new.EnabledModules))
+	for i, m := range new.EnabledModules {
+		newModules[i] = namedItem{m.Name, m}
+	}
+	diffs = append(diffs, diffItems("EnabledModule", oldModules, newModules)...)
+
+	oldContainers := make([]namedItem, len(old.Containers))
+	for i, c := range old.Containers {
+		oldContainers[i] = namedItem{c.LocalName(), c}
+	}
+	newContainers := make([]namedItem, len(new.Containers))
+	for i, c := range new.Containers {
+		newContainers[i] = namedItem{c.LocalName(), c}
+	}
+	diffs = append(diffs, diffItems("Container", oldContainers, newContainers)...)
+
+	diffs = append(diffs, diffCustomizations(old.Customizations, new.Customizations)...)
+	diffs = append(diffs, diffItems("Variable", variableItems(old.Variables), variableItems(new.Variables))...)
+
+	return diffs
+}
+
+// variableItems returns the variables sorted by name
+func variableItems(variables map[string]string) []namedItem {
+	items := make([]namedItem, 0, len(variables))
+	for name, value := range variables {
+		items = append(items, namedItem{name, value})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].name < items[j].name
+	})
+	return items
+}
+
+type namedItem struct {
+	name  string
+	value interface{}
+}
+
+func diffPackages(section string, old, new []Package) []Difference {
+	oldItems := make([]namedItem, len(old))
+	for i, p := range old {
+		oldItems[i] = namedItem{p.Name, p}
+	}
+	newItems := make([]namedItem, len(new))
+	for i, p := range new {
+		newItems[i] = namedItem{p.Name, p}
+	}
+	return diffItems(section, oldItems, newItems)
+}
+
+// diffItems compares two lists of items identified by their name. Added and
+// changed items are returned in the order of new, followed by the removed
+// ones sorted by name.
+func diffItems(section string, old, new []namedItem) []Difference {
+	var diffs []Difference
+
+	oldMap := make(map[string]interface{}, len(old))
+	for _, item := range old {
+		oldMap[item.name] = item.value
+	}
+
+	for _, item := range new {
+		oldValue, found := oldMap[item.name]
+		if !found {
+			diffs = append(diffs, Difference{DiffAdded, section, item.name, nil, item.value})
+			continue
+		}
+		delete(oldMap, item.name)
+		if !reflect.DeepEqual(oldValue, item.value) {
+			diffs = append(diffs, Difference{DiffChanged, section, item.name, oldValue, item.value})
+		}
+	}
+
+	removed := make([]string, 0, len(oldMap))
+	for name := range oldMap {
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		diffs = append(diffs, Difference{DiffRemoved, section, name, oldMap[name], nil})
+	}
+
+	return diffs
+}
+
+// diffCustomizations compares customizations by their top-level keys, with
+// the values in their JSON representation.
+func diffCustomizations(old, new *Customizations) []Difference {
+	oldMap := customizationsMap(old)
+	newMap := customizationsMap(new)
+
+	keys := make([]string, 0, len(oldMap)+len(newMap))
+	for key := range oldMap {
+		keys = append(keys, key)
+	}
+	for key := range newMap {
+		if _, ok := oldMap[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var diffs []Difference
+	for _, key := range keys {
+		section := "Customizations." + key
+		oldValue, inOld := oldMap[key]
+		newValue, inNew := newMap[key]
+		switch {
+		case !inOld:
+			diffs = append(diffs, Difference{DiffAdded, section, "", nil, newValue})
+		case !inNew:
+			diffs = append(diffs, Difference{DiffRemoved, section, "", oldValue, nil})
+		case !reflect.DeepEqual(oldValue, newValue):
+			diffs = append(diffs, Difference{DiffChanged, section, "", oldValue, newValue})
+		}
+	}
+	return diffs
+}
+
+func customizationsMap(c *Customizations) map[string]interface{} {
+	m := map[string]interface{}{}
+	if c == nil {
+		return m
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
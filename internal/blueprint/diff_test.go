@@ -0,0 +1,53 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	hostname := "foo"
+	old := Blueprint{
+		Name:        "test",
+		Description: "old",
+		Version:     "0.0.1",
+		Packages:    []Package{{Name: "httpd", Version: "2.4.*"}, {Name: "tmux", Version: "*"}, {Name: "bash", Version: "*"}},
+		Groups:      []Group{{Name: "core"}},
+		Customizations: &Customizations{
+			Hostname: &hostname,
+			Kernel:   &KernelCustomization{Append: "nosmt"},
+		},
+	}
+	new := Blueprint{
+		Name:        "test",
+		Description: "old",
+		Version:     "0.0.2",
+		Packages:    []Package{{Name: "httpd", Version: "2.4.46"}, {Name: "vim", Version: "*"}},
+		Modules:     []Package{{Name: "nodejs", Version: "*"}},
+		Groups:      []Group{{Name: "core"}},
+		Excludes:    []string{"plymouth"},
+		Customizations: &Customizations{
+			Kernel:   &KernelCustomization{Append: "nosmt=force"},
+			Timezone: &TimezoneCustomization{NTPServers: []string{"0.pool.ntp.org"}},
+		},
+		Variables: map[string]string{"ENV": "dev"},
+	}
+
+	assert.Equal(t, []Difference{
+		{DiffChanged, "Version", "", "0.0.1", "0.0.2"},
+		{DiffAdded, "Module", "nodejs", nil, Package{Name: "nodejs", Version: "*"}},
+		{DiffChanged, "Package", "httpd", Package{Name: "httpd", Version: "2.4.*"}, Package{Name: "httpd", Version: "2.4.46"}},
+		{DiffAdded, "Package", "vim", nil, Package{Name: "vim", Version: "*"}},
+		{DiffRemoved, "Package", "bash", Package{Name: "bash", Version: "*"}, nil},
+		{DiffRemoved, "Package", "tmux", Package{Name: "tmux", Version: "*"}, nil},
+		{DiffAdded, "ExcludePackage", "plymouth", nil, "plymouth"},
+		{DiffRemoved, "Customizations.hostname", "", "foo", nil},
+		{DiffChanged, "Customizations.kernel", "", map[string]interface{}{"append": "nosmt"}, map[string]interface{}{"append": "nosmt=force"}},
+		{DiffAdded, "Customizations.timezone", "", nil, map[string]interface{}{"ntpservers": []interface{}{"0.pool.ntp.org"}}},
+		{DiffAdded, "Variable", "ENV", nil, "dev"},
+	}, Diff(&old, &new))
+
+	assert.Empty(t, Diff(&old, &old))
+	assert.Empty(t, Diff(&Blueprint{}, &Blueprint{Customizations: &Customizations{}}))
+}
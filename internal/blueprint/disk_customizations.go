@@ -0,0 +1,157 @@
+package blueprint
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A DiskCustomization replaces the default partition table of an image type.
+// Type is the partition table type, gpt or dos, and defaults to the one of
+// the image type. Partitions are created in the given order.
+type DiskCustomization struct {
+	Type       string                   `json:"type,omitempty" toml:"type,omitempty"`
+	Partitions []PartitionCustomization `json:"partitions" toml:"partitions"`
+}
+
+// A PartitionCustomization describes a single partition. Size is given with
+// an optional unit, e.g. "512 MiB" or "10 GB", and may be omitted for the
+// last partition, which then fills the rest of the disk. Type is one of esp,
+// bios-boot, prep or linux, the default. Partitions without a mountpoint are
+// left unformatted, which is needed for the bios-boot and prep partitions.
+type PartitionCustomization struct {
+	Mountpoint string `json:"mountpoint,omitempty" toml:"mountpoint,omitempty"`
+	Size       string `json:"size,omitempty" toml:"size,omitempty"`
+	Type       string `json:"type,omitempty" toml:"type,omitempty"`
+	FSType     string `json:"fs_type,omitempty" toml:"fs_type,omitempty"`
+	Label      string `json:"label,omitempty" toml:"label,omitempty"`
+}
+
+// The partition types which can be requested by name
+const (
+	PartitionTypeESP      = "esp"
+	PartitionTypeBIOSBoot = "bios-boot"
+	PartitionTypePReP     = "prep"
+	PartitionTypeLinux    = "linux"
+)
+
+// fsTypes are the supported filesystem types, with the maximal length of
+// their labels
+var fsTypes = map[string]int{
+	"xfs":  12,
+	"ext4": 16,
+	"vfat": 11,
+}
+
+var sizeRegex = regexp.MustCompile(`^([0-9]+) *([a-zA-Z]*)$`)
+
+var sizeUnits = map[string]uint64{
+	"":    1,
+	"B":   1,
+	"kB":  1000,
+	"KiB": 1024,
+	"MB":  1000 * 1000,
+	"MiB": 1024 * 1024,
+	"GB":  1000 * 1000 * 1000,
+	"GiB": 1024 * 1024 * 1024,
+	"TB":  1000 * 1000 * 1000 * 1000,
+	"TiB": 1024 * 1024 * 1024 * 1024,
+}
+
+// ParseSize parses a size with an optional unit into bytes
+func ParseSize(size string) (uint64, error) {
+	match := sizeRegex.FindStringSubmatch(strings.TrimSpace(size))
+	if match == nil {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	unit, ok := sizeUnits[match[2]]
+	if !ok {
+		return 0, fmt.Errorf("invalid unit %q in size %q", match[2], size)
+	}
+	n, err := strconv.ParseUint(match[1], 10, 64)
+	if err != nil || n == 0 || n > (1<<63)/unit {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return n * unit, nil
+}
+
+// GetType returns the type of the partition, inferring esp for the
+// partition mounted at /boot/efi
+func (p PartitionCustomization) GetType() string {
+	if p.Type != "" {
+		return p.Type
+	}
+	if p.Mountpoint == "/boot/efi" {
+		return PartitionTypeESP
+	}
+	return PartitionTypeLinux
+}
+
+func (d *DiskCustomization) validate(prefix string) ValidationErrors {
+	var errs ValidationErrors
+
+	if d.Type != "" && d.Type != "gpt" && d.Type != "dos" {
+		errs.add(prefix+".type", fmt.Sprintf("invalid partition table type %q", d.Type), "use gpt or dos")
+	}
+	if len(d.Partitions) == 0 {
+		errs.add(prefix+".partitions", "disk needs at least one partition", "")
+	}
+
+	mountpoints := map[string]bool{}
+	for i, p := range d.Partitions {
+		field := fmt.Sprintf("%s.partitions[%d]", prefix, i)
+
+		if p.Size == "" {
+			if i != len(d.Partitions)-1 {
+				errs.add(field+".size", "only the last partition can omit its size", "")
+			}
+		} else if _, err := ParseSize(p.Size); err != nil {
+			errs.add(field+".size", err.Error(), `for example "512 MiB"`)
+		}
+
+		partitionType := p.GetType()
+		switch partitionType {
+		case PartitionTypeESP:
+			if p.Mountpoint != "/boot/efi" || p.FSType != "vfat" {
+				errs.add(field, "the EFI system partition must be a vfat filesystem mounted at /boot/efi", "")
+			}
+		case PartitionTypeBIOSBoot, PartitionTypePReP:
+			if p.Mountpoint != "" || p.FSType != "" {
+				errs.add(field, fmt.Sprintf("%s partitions must not contain a filesystem", partitionType), "remove mountpoint and fs_type")
+			}
+		case PartitionTypeLinux:
+		default:
+			errs.add(field+".type", fmt.Sprintf("invalid partition type %q", p.Type), "use one of esp, bios-boot, prep or linux")
+		}
+
+		if p.Mountpoint == "" {
+			if p.FSType != "" || p.Label != "" {
+				errs.add(field+".mountpoint", "partitions with a filesystem need a mountpoint", "")
+			}
+			continue
+		}
+		if !path.IsAbs(p.Mountpoint) || path.Clean(p.Mountpoint) != p.Mountpoint {
+			errs.add(field+".mountpoint", fmt.Sprintf("mountpoint %q must be an absolute, normalized path", p.Mountpoint), "")
+		} else if mountpoints[p.Mountpoint] {
+			errs.add(field+".mountpoint", fmt.Sprintf("duplicate mountpoint %q", p.Mountpoint), "")
+		}
+		mountpoints[p.Mountpoint] = true
+
+		maxLabel, ok := fsTypes[p.FSType]
+		if !ok {
+			errs.add(field+".fs_type", fmt.Sprintf("invalid filesystem type %q for %q", p.FSType, p.Mountpoint), "use one of xfs, ext4 or vfat")
+		} else if len(p.Label) > maxLabel {
+			errs.add(field+".label", fmt.Sprintf("label %q is longer than the %d characters %s supports", p.Label, maxLabel, p.FSType), "")
+		}
+		if p.Mountpoint == "/" && p.FSType == "vfat" {
+			errs.add(field+".fs_type", "the root filesystem cannot be vfat", "use xfs or ext4")
+		}
+	}
+	if len(d.Partitions) > 0 && !mountpoints["/"] {
+		errs.add(prefix+".partitions", "disk needs a partition mounted at /", "")
+	}
+
+	return errs
+}
@@ -0,0 +1,74 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]uint64{
+		"512":     512,
+		"512 B":   512,
+		"1 kB":    1000,
+		"4KiB":    4096,
+		"200 MiB": 200 * 1024 * 1024,
+		"10 GB":   10 * 1000 * 1000 * 1000,
+		"2 GiB":   2 * 1024 * 1024 * 1024,
+	}
+	for input, expected := range cases {
+		size, err := ParseSize(input)
+		require.NoErrorf(t, err, input)
+		assert.Equalf(t, expected, size, input)
+	}
+
+	for _, input := range []string{"", "0", "-1 GiB", "1.5 GiB", "1 gigabyte", "GiB", "99999999999 TiB"} {
+		_, err := ParseSize(input)
+		assert.Errorf(t, err, input)
+	}
+}
+
+func TestValidateDisk(t *testing.T) {
+	valid := &DiskCustomization{
+		Type: "gpt",
+		Partitions: []PartitionCustomization{
+			{Size: "1 MiB", Type: "bios-boot"},
+			{Size: "200 MiB", Mountpoint: "/boot/efi", FSType: "vfat"},
+			{Size: "1 GiB", Mountpoint: "/boot", FSType: "ext4", Label: "boot"},
+			{Mountpoint: "/", FSType: "xfs", Label: "root"},
+		},
+	}
+	assert.NoError(t, (&Customizations{Disk: valid}).Validate())
+	assert.Equal(t, PartitionTypeESP, valid.Partitions[1].GetType())
+	assert.Equal(t, PartitionTypeLinux, valid.Partitions[2].GetType())
+
+	errs := (&Customizations{Disk: &DiskCustomization{
+		Type: "mbr",
+		Partitions: []PartitionCustomization{
+			{Mountpoint: "/boot/efi", FSType: "ext4"},
+			{Size: "1 MiB", Type: "prep", Mountpoint: "/prep", FSType: "xfs"},
+			{Size: "1 GiB", Type: "swap"},
+			{Size: "lots", Mountpoint: "/var/", FSType: "xfs", Label: "a-very-long-label"},
+			{Size: "10 GiB", Mountpoint: "/home", FSType: "btrfs"},
+		},
+	}}).validate("customizations")
+	fields := make([]string, len(errs))
+	for i, e := range errs {
+		fields[i] = e.Field
+	}
+	assert.Equal(t, []string{
+		"customizations.disk.type",
+		"customizations.disk.partitions[0].size",
+		"customizations.disk.partitions[0]",
+		"customizations.disk.partitions[1]",
+		"customizations.disk.partitions[2].type",
+		"customizations.disk.partitions[3].size",
+		"customizations.disk.partitions[3].mountpoint",
+		"customizations.disk.partitions[3].label",
+		"customizations.disk.partitions[4].fs_type",
+		"customizations.disk.partitions",
+	}, fields)
+
+	assert.EqualError(t, (&Customizations{Disk: &DiskCustomization{}}).Validate(), "disk needs at least one partition")
+}
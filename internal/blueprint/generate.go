@@ -0,0 +1,96 @@
+package blueprint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// rpmArches are the architectures recognized at the end of a NEVRA
+var rpmArches = []string{"x86_64", "aarch64", "ppc64le", "s390x", "i686", "noarch"}
+
+// ParseNEVRA splits a full package name as printed by rpm -qa, e.g.
+// "bash-5.0.17-1.fc32.x86_64", into its name and [epoch:]version-release.
+// Strings which do not end with a known architecture are not NEVRAs, and
+// ok is false.
+func ParseNEVRA(nevra string) (name, evr string, ok bool) {
+	var rest string
+	for _, arch := range rpmArches {
+		if strings.HasSuffix(nevra, "."+arch) {
+			rest = strings.TrimSuffix(nevra, "."+arch)
+			break
+		}
+	}
+	if rest == "" {
+		return "", "", false
+	}
+
+	releaseDash := strings.LastIndex(rest, "-")
+	if releaseDash <= 0 {
+		return "", "", false
+	}
+	versionDash := strings.LastIndex(rest[:releaseDash], "-")
+	if versionDash <= 0 {
+		return "", "", false
+	}
+	return rest[:versionDash], rest[versionDash+1:], true
+}
+
+// NewFromPackages returns a blueprint which installs the given packages, for
+// migrating existing systems. Packages are names or NEVRAs, e.g. the lines
+// printed by rpm -qa. NEVRAs are pinned to their version if pin is true.
+// Packages in skip, for example the ones the image type installs anyway, and
+// the pseudo packages of imported GPG keys are left out.
+func NewFromPackages(name string, packages []string, pin bool, skip []string) (Blueprint, error) {
+	skipped := make(map[string]bool, len(skip))
+	for _, s := range skip {
+		skipped[s] = true
+	}
+
+	versions := make(map[string]string)
+	for _, p := range packages {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		pkgName, evr, ok := ParseNEVRA(p)
+		if !ok {
+			pkgName, evr = p, ""
+		}
+		// rpm -qa prints GPG keys without an architecture
+		if pkgName == "gpg-pubkey" || strings.HasPrefix(pkgName, "gpg-pubkey-") || skipped[pkgName] {
+			continue
+		}
+		if strings.ContainsAny(pkgName, " \t") {
+			return Blueprint{}, fmt.Errorf("invalid package %q", p)
+		}
+
+		version := "*"
+		if pin && evr != "" {
+			version = evr
+		}
+		if old, ok := versions[pkgName]; ok && old != version {
+			// multilib or installonly packages: only the name is kept
+			version = "*"
+		}
+		versions[pkgName] = version
+	}
+
+	names := make([]string, 0, len(versions))
+	for pkgName := range versions {
+		names = append(names, pkgName)
+	}
+	sort.Strings(names)
+
+	bp := Blueprint{
+		Name:        name,
+		Description: "Generated from a package list",
+		Version:     "0.0.1",
+		Packages:    make([]Package, 0, len(names)),
+	}
+	for _, pkgName := range names {
+		bp.Packages = append(bp.Packages, Package{Name: pkgName, Version: versions[pkgName]})
+	}
+
+	return bp, bp.Initialize()
+}
@@ -0,0 +1,63 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNEVRA(t *testing.T) {
+	for _, c := range []struct {
+		nevra, name, evr string
+		ok               bool
+	}{
+		{"bash-5.0.17-1.fc32.x86_64", "bash", "5.0.17-1.fc32", true},
+		{"xorg-x11-server-Xorg-1.20.8-1.fc32.x86_64", "xorg-x11-server-Xorg", "1.20.8-1.fc32", true},
+		{"perl-Errno-1:1.30-461.fc32.noarch", "perl-Errno", "1:1.30-461.fc32", true},
+		{"xorg-x11-server-Xorg", "", "", false},
+		{"bash.x86_64", "", "", false},
+	} {
+		name, evr, ok := ParseNEVRA(c.nevra)
+		assert.Equal(t, c.ok, ok, c.nevra)
+		assert.Equal(t, c.name, name, c.nevra)
+		assert.Equal(t, c.evr, evr, c.nevra)
+	}
+}
+
+func TestNewFromPackages(t *testing.T) {
+	packages := []string{
+		"bash-5.0.17-1.fc32.x86_64",
+		"",
+		"gpg-pubkey-12c944d0-5d5156ab",
+		"kernel-core-5.8.15-201.fc32.x86_64",
+		"kernel-core-5.8.18-200.fc32.x86_64",
+		"glibc-2.31-4.fc32.x86_64",
+		"glibc-2.31-4.fc32.i686",
+		"tmux",
+		"httpd-2.4.46-1.fc32.x86_64",
+	}
+
+	bp, err := NewFromPackages("golden", packages, false, []string{"bash"})
+	require.NoError(t, err)
+	assert.Equal(t, "golden", bp.Name)
+	assert.Equal(t, []Package{
+		{Name: "glibc", Version: "*"},
+		{Name: "httpd", Version: "*"},
+		{Name: "kernel-core", Version: "*"},
+		{Name: "tmux", Version: "*"},
+	}, bp.Packages)
+
+	bp, err = NewFromPackages("golden", packages, true, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []Package{
+		{Name: "bash", Version: "5.0.17-1.fc32"},
+		{Name: "glibc", Version: "2.31-4.fc32"},
+		{Name: "httpd", Version: "2.4.46-1.fc32"},
+		{Name: "kernel-core", Version: "*"},
+		{Name: "tmux", Version: "*"},
+	}, bp.Packages)
+
+	_, err = NewFromPackages("golden", []string{"bash zsh"}, false, nil)
+	assert.EqualError(t, err, `invalid package "bash zsh"`)
+}
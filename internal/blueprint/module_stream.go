@@ -0,0 +1,52 @@
+package blueprint
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var moduleStreamRegex = regexp.MustCompile(`^[a-zA-Z0-9._+-]+$`)
+
+// A ModuleStream specifies a DNF module stream to enable in the image, e.g.
+// nodejs:16. Packages provided by the module are installed from the enabled
+// stream instead of the default one.
+type ModuleStream struct {
+	Name   string `json:"name" toml:"name"`
+	Stream string `json:"stream" toml:"stream"`
+}
+
+// Spec returns the module stream in the name:stream format understood by
+// DNF.
+func (m ModuleStream) Spec() string {
+	return m.Name + ":" + m.Stream
+}
+
+// GetModuleStreams returns the specs of all module streams which have to be
+// enabled before depsolving the blueprint's packages.
+func (b *Blueprint) GetModuleStreams() []string {
+	specs := []string{}
+	for _, m := range b.EnabledModules {
+		specs = append(specs, m.Spec())
+	}
+	return specs
+}
+
+func validateModuleStreams(modules []ModuleStream) ValidationErrors {
+	var errs ValidationErrors
+	names := make(map[string]bool)
+	for i, m := range modules {
+		if !moduleStreamRegex.MatchString(m.Name) {
+			errs.add(fmt.Sprintf("enabled_modules[%d].name", i), fmt.Sprintf("invalid module name %q", m.Name), "")
+			continue
+		}
+		if !moduleStreamRegex.MatchString(m.Stream) {
+			errs.add(fmt.Sprintf("enabled_modules[%d].stream", i), fmt.Sprintf("invalid stream %q for module %q", m.Stream, m.Name), "for example 16 for nodejs:16")
+			continue
+		}
+		if names[m.Name] {
+			errs.add(fmt.Sprintf("enabled_modules[%d].name", i), fmt.Sprintf("module %q is enabled more than once, only one stream of a module can be enabled", m.Name), "")
+		}
+		names[m.Name] = true
+	}
+	return errs
+}
@@ -0,0 +1,43 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetModuleStreams(t *testing.T) {
+	bp := Blueprint{
+		EnabledModules: []ModuleStream{
+			{Name: "nodejs", Stream: "16"},
+			{Name: "postgresql", Stream: "13"},
+		},
+	}
+	assert.Equal(t, []string{"nodejs:16", "postgresql:13"}, bp.GetModuleStreams())
+	assert.Equal(t, []string{}, (&Blueprint{}).GetModuleStreams())
+}
+
+func TestValidateModuleStreams(t *testing.T) {
+	assert.Empty(t, validateModuleStreams([]ModuleStream{
+		{Name: "nodejs", Stream: "16"},
+		{Name: "perl-DBI", Stream: "1.641"},
+	}))
+
+	errs := validateModuleStreams([]ModuleStream{
+		{Name: "nodejs", Stream: "16"},
+		{Name: "nodejs", Stream: "14"},
+		{Name: "", Stream: "1"},
+		{Name: "ruby", Stream: ""},
+		{Name: "php", Stream: "7.4 8.0"},
+	})
+	fields := make([]string, len(errs))
+	for i, e := range errs {
+		fields[i] = e.Field
+	}
+	assert.Equal(t, []string{
+		"enabled_modules[1].name",
+		"enabled_modules[2].name",
+		"enabled_modules[3].stream",
+		"enabled_modules[4].stream",
+	}, fields)
+}
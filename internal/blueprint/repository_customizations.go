@@ -0,0 +1,209 @@
+package blueprint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/osbuild/osbuild-composer/internal/copr"
+)
+
+// A RepositoryCustomization describes a yum repository which is configured in
+// the image. It does not take part in building the image. GPGKeys may contain
+// either URLs or ASCII-armored keys, the latter are installed into
+// /etc/pki/rpm-gpg. A single baseurl may be a COPR repository in the
+// copr:owner/project format, which is expanded for the distribution of the
+// image.
+type RepositoryCustomization struct {
+	Id           string   `json:"id" toml:"id"`
+	Name         string   `json:"name,omitempty" toml:"name,omitempty"`
+	Filename     string   `json:"filename,omitempty" toml:"filename,omitempty"`
+	BaseURLs     []string `json:"baseurls,omitempty" toml:"baseurls,omitempty"`
+	Metalink     string   `json:"metalink,omitempty" toml:"metalink,omitempty"`
+	Mirrorlist   string   `json:"mirrorlist,omitempty" toml:"mirrorlist,omitempty"`
+	GPGKeys      []string `json:"gpgkeys,omitempty" toml:"gpgkeys,omitempty"`
+	GPGCheck     *bool    `json:"gpgcheck,omitempty" toml:"gpgcheck,omitempty"`
+	RepoGPGCheck *bool    `json:"repo_gpgcheck,omitempty" toml:"repo_gpgcheck,omitempty"`
+	Enabled      *bool    `json:"enabled,omitempty" toml:"enabled,omitempty"`
+	SSLVerify    *bool    `json:"sslverify,omitempty" toml:"sslverify,omitempty"`
+}
+
+const (
+	repoFileDir = "/etc/yum.repos.d"
+	gpgKeyDir   = "/etc/pki/rpm-gpg"
+)
+
+var repoIdRegex = regexp.MustCompile(`^[\w.:-]+$`)
+
+func (r RepositoryCustomization) validate() error {
+	if !repoIdRegex.MatchString(r.Id) {
+		return &CustomizationError{fmt.Sprintf("invalid repository id %q", r.Id)}
+	}
+	if len(r.BaseURLs) == 0 && r.Metalink == "" && r.Mirrorlist == "" {
+		return &CustomizationError{fmt.Sprintf("repository %q needs one of baseurls, metalink or mirrorlist", r.Id)}
+	}
+	for _, url := range r.BaseURLs {
+		if !copr.IsSpec(url) {
+			continue
+		}
+		if len(r.BaseURLs) != 1 {
+			return &CustomizationError{fmt.Sprintf("COPR repository %q must be the only baseurl of repository %q", url, r.Id)}
+		}
+		if _, err := copr.Parse(url); err != nil {
+			return &CustomizationError{err.Error()}
+		}
+	}
+	if r.Filename != "" && (!repoIdRegex.MatchString(r.Filename) || !strings.HasSuffix(r.Filename, ".repo")) {
+		return &CustomizationError{fmt.Sprintf("invalid filename %q for repository %q, must end in .repo", r.Filename, r.Id)}
+	}
+	return nil
+}
+
+// isCopr returns whether the repository uses the copr:owner/project shorthand
+func (r RepositoryCustomization) isCopr() bool {
+	return len(r.BaseURLs) == 1 && copr.IsSpec(r.BaseURLs[0])
+}
+
+// WithCoprRepositories returns the customizations with the COPR repositories
+// expanded for distro: their baseurl points to the chroot of distro and, if
+// not configured otherwise, the key of the COPR project is used for checking
+// the packages. c itself is returned if there are no COPR repositories.
+func (c *Customizations) WithCoprRepositories(distro string) (*Customizations, error) {
+	if c == nil {
+		return nil, nil
+	}
+	expanded := *c
+	expanded.Repositories = make([]RepositoryCustomization, len(c.Repositories))
+	found := false
+	for i, repo := range c.Repositories {
+		if !repo.isCopr() {
+			expanded.Repositories[i] = repo
+			continue
+		}
+		found = true
+		r, err := copr.Parse(repo.BaseURLs[0])
+		if err != nil {
+			return nil, err
+		}
+		chroot, err := copr.Chroot(distro)
+		if err != nil {
+			return nil, err
+		}
+		repo.BaseURLs = []string{r.BaseURL(chroot, "$basearch")}
+		if len(repo.GPGKeys) == 0 {
+			repo.GPGKeys = []string{r.GPGKey()}
+			if repo.GPGCheck == nil {
+				gpgCheck := true
+				repo.GPGCheck = &gpgCheck
+			}
+		}
+		expanded.Repositories[i] = repo
+	}
+	if !found {
+		return c, nil
+	}
+	return &expanded, nil
+}
+
+func (r RepositoryCustomization) getFilename() string {
+	if r.Filename != "" {
+		return r.Filename
+	}
+	return r.Id + ".repo"
+}
+
+func isArmoredGPGKey(key string) bool {
+	return strings.HasPrefix(strings.TrimSpace(key), "-----BEGIN PGP PUBLIC KEY BLOCK-----")
+}
+
+// gpgKeyFiles returns the repository's GPG keys which need to be installed in
+// the image and the paths or URLs under which dnf finds all of its keys.
+func (r RepositoryCustomization) gpgKeyFiles() ([]FileCustomization, []string) {
+	var files []FileCustomization
+	var keys []string
+	for i, key := range r.GPGKeys {
+		if !isArmoredGPGKey(key) {
+			keys = append(keys, key)
+			continue
+		}
+		path := fmt.Sprintf("%s/RPM-GPG-KEY-%s-%d", gpgKeyDir, r.Id, i)
+		files = append(files, FileCustomization{
+			Path: path,
+			Mode: "0644",
+			Data: key,
+		})
+		keys = append(keys, "file://"+path)
+	}
+	return files, keys
+}
+
+func boolOption(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
+
+func (r RepositoryCustomization) section(gpgKeys []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]\n", r.Id)
+	name := r.Name
+	if name == "" {
+		name = r.Id
+	}
+	fmt.Fprintf(&b, "name=%s\n", name)
+	if len(r.BaseURLs) > 0 {
+		fmt.Fprintf(&b, "baseurl=%s\n", strings.Join(r.BaseURLs, " "))
+	}
+	if r.Metalink != "" {
+		fmt.Fprintf(&b, "metalink=%s\n", r.Metalink)
+	}
+	if r.Mirrorlist != "" {
+		fmt.Fprintf(&b, "mirrorlist=%s\n", r.Mirrorlist)
+	}
+	if r.Enabled != nil {
+		fmt.Fprintf(&b, "enabled=%s\n", boolOption(*r.Enabled))
+	}
+	if r.GPGCheck != nil {
+		fmt.Fprintf(&b, "gpgcheck=%s\n", boolOption(*r.GPGCheck))
+	}
+	if r.RepoGPGCheck != nil {
+		fmt.Fprintf(&b, "repo_gpgcheck=%s\n", boolOption(*r.RepoGPGCheck))
+	}
+	if r.SSLVerify != nil {
+		fmt.Fprintf(&b, "sslverify=%s\n", boolOption(*r.SSLVerify))
+	}
+	if len(gpgKeys) > 0 {
+		fmt.Fprintf(&b, "gpgkey=%s\n", strings.Join(gpgKeys, " "))
+	}
+	return b.String()
+}
+
+// repositoryFiles renders the repositories into .repo files and GPG key
+// files. Repositories with the same filename share a file.
+func repositoryFiles(repos []RepositoryCustomization) []FileCustomization {
+	var files []FileCustomization
+	var filenames []string
+	sections := map[string][]string{}
+
+	for _, repo := range repos {
+		keyFiles, keys := repo.gpgKeyFiles()
+		files = append(files, keyFiles...)
+
+		filename := repo.getFilename()
+		if _, exists := sections[filename]; !exists {
+			filenames = append(filenames, filename)
+		}
+		sections[filename] = append(sections[filename], repo.section(keys))
+	}
+
+	for _, filename := range filenames {
+		files = append(files, FileCustomization{
+			Path: repoFileDir + "/" + filename,
+			Mode: "0644",
+			Data: strings.Join(sections[filename], "\n"),
+		})
+	}
+
+	return files
+}
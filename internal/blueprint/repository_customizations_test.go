@@ -0,0 +1,117 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryFiles(t *testing.T) {
+	enabled := true
+	gpgCheck := false
+	key := "-----BEGIN PGP PUBLIC KEY BLOCK-----\nkey\n-----END PGP PUBLIC KEY BLOCK-----\n"
+
+	c := Customizations{
+		Files: []FileCustomization{{Path: "/etc/motd", Data: "hi"}},
+		Repositories: []RepositoryCustomization{
+			{
+				Id:       "example",
+				Name:     "Example",
+				BaseURLs: []string{"https://example.com/repo", "https://mirror.example.com/repo"},
+				GPGKeys:  []string{key, "https://example.com/key.asc"},
+				Enabled:  &enabled,
+			},
+			{
+				Id:       "example-debug",
+				Filename: "example.repo",
+				Metalink: "https://example.com/metalink",
+				GPGCheck: &gpgCheck,
+			},
+		},
+	}
+
+	assert.Equal(t, []FileCustomization{
+		{Path: "/etc/motd", Data: "hi"},
+		{Path: "/etc/pki/rpm-gpg/RPM-GPG-KEY-example-0", Mode: "0644", Data: key},
+		{Path: "/etc/yum.repos.d/example.repo", Mode: "0644", Data: "[example]\n" +
+			"name=Example\n" +
+			"baseurl=https://example.com/repo https://mirror.example.com/repo\n" +
+			"enabled=1\n" +
+			"gpgkey=file:///etc/pki/rpm-gpg/RPM-GPG-KEY-example-0 https://example.com/key.asc\n" +
+			"\n" +
+			"[example-debug]\n" +
+			"name=example-debug\n" +
+			"metalink=https://example.com/metalink\n" +
+			"gpgcheck=0\n"},
+	}, c.GetFiles())
+	assert.NoError(t, c.Validate())
+}
+
+func TestValidateRepositories(t *testing.T) {
+	cases := []struct {
+		Name          string
+		Repositories  []RepositoryCustomization
+		ExpectedError string
+	}{
+		{"missing id", []RepositoryCustomization{{BaseURLs: []string{"https://example.com"}}}, `invalid repository id ""`},
+		{"invalid id", []RepositoryCustomization{{Id: "my repo", BaseURLs: []string{"https://example.com"}}}, `invalid repository id "my repo"`},
+		{"no urls", []RepositoryCustomization{{Id: "repo"}}, `repository "repo" needs one of baseurls, metalink or mirrorlist`},
+		{"invalid filename", []RepositoryCustomization{{Id: "repo", Mirrorlist: "https://example.com", Filename: "../repo.repo"}}, `invalid filename "../repo.repo" for repository "repo", must end in .repo`},
+		{"duplicate id", []RepositoryCustomization{
+			{Id: "repo", Mirrorlist: "https://example.com"},
+			{Id: "repo", Mirrorlist: "https://example.org"},
+		}, `duplicate repository "repo"`},
+		{"invalid copr", []RepositoryCustomization{{Id: "repo", BaseURLs: []string{"copr:project"}}}, `invalid COPR repository "copr:project", expected copr:owner/project`},
+		{"copr with other baseurls", []RepositoryCustomization{{Id: "repo", BaseURLs: []string{"copr:user/project", "https://example.com"}}}, `COPR repository "copr:user/project" must be the only baseurl of repository "repo"`},
+	}
+
+	for _, c := range cases {
+		err := (&Customizations{Repositories: c.Repositories}).Validate()
+		assert.EqualErrorf(t, err, c.ExpectedError, c.Name)
+	}
+
+	// a repository file must not clash with an explicitly customized file
+	err := (&Customizations{
+		Files:        []FileCustomization{{Path: "/etc/yum.repos.d/repo.repo"}},
+		Repositories: []RepositoryCustomization{{Id: "repo", Mirrorlist: "https://example.com"}},
+	}).Validate()
+	assert.EqualError(t, err, `path "/etc/yum.repos.d/repo.repo" is customized more than once`)
+}
+
+func TestWithCoprRepositories(t *testing.T) {
+	gpgCheck := false
+	c := &Customizations{
+		Repositories: []RepositoryCustomization{
+			{Id: "example", BaseURLs: []string{"https://example.com/repo"}},
+			{Id: "tools", BaseURLs: []string{"copr:@group/tools"}},
+			{Id: "unsigned", BaseURLs: []string{"copr:user/unsigned"}, GPGCheck: &gpgCheck},
+		},
+	}
+	require.NoError(t, c.Validate())
+
+	expanded, err := c.WithCoprRepositories("rhel-84")
+	require.NoError(t, err)
+	require.Len(t, expanded.Repositories, 3)
+	assert.Equal(t, c.Repositories[0], expanded.Repositories[0])
+	assert.Equal(t, []string{"https://download.copr.fedorainfracloud.org/results/@group/tools/epel-8-$basearch/"}, expanded.Repositories[1].BaseURLs)
+	assert.Equal(t, []string{"https://download.copr.fedorainfracloud.org/results/@group/tools/pubkey.gpg"}, expanded.Repositories[1].GPGKeys)
+	assert.True(t, *expanded.Repositories[1].GPGCheck)
+	assert.False(t, *expanded.Repositories[2].GPGCheck)
+
+	// the original customizations are left alone
+	assert.Equal(t, []string{"copr:@group/tools"}, c.Repositories[1].BaseURLs)
+
+	_, err = c.WithCoprRepositories("test-distro")
+	assert.EqualError(t, err, `COPR has no chroot for distribution "test-distro"`)
+
+	// without COPR repositories, any distribution works
+	plain := &Customizations{Repositories: c.Repositories[:1]}
+	expanded, err = plain.WithCoprRepositories("test-distro")
+	require.NoError(t, err)
+	assert.Same(t, plain, expanded)
+
+	expanded, err = (*Customizations)(nil).WithCoprRepositories("fedora-33")
+	require.NoError(t, err)
+	assert.Nil(t, expanded)
+}
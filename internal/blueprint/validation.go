@@ -0,0 +1,309 @@
+package blueprint
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/coreos/go-semver/semver"
+)
+
+// A ValidationError describes a problem with a single field of a blueprint.
+// Field is the path to the field, e.g. customizations.user[0].name, and
+// Suggestion an optional hint on how to fix the problem.
+type ValidationError struct {
+	Field      string `json:"field"`
+	Reason     string `json:"reason"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Reason
+	}
+	return e.Field + ": " + e.Reason
+}
+
+// ValidationErrors are all problems found in a blueprint
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (errs *ValidationErrors) add(field, reason, suggestion string) {
+	*errs = append(*errs, ValidationError{field, reason, suggestion})
+}
+
+// Validate checks the whole blueprint and returns all problems found in it,
+// or nil if there are none.
+func (b *Blueprint) Validate() ValidationErrors {
+	var errs ValidationErrors
+
+	// Validate blueprints with variables with their default values or a
+	// placeholder in place of the variables
+	if b.HasVariables() {
+		errs = append(errs, b.validateVariables()...)
+		bp := b.placeholderCopy()
+		return append(errs, bp.Validate()...)
+	}
+	if len(b.Variables) > 0 {
+		errs = append(errs, b.validateVariables()...)
+	}
+
+	if b.Version != "" {
+		if _, err := semver.NewVersion(b.Version); err != nil {
+			errs.add("version", "Invalid 'version', must use Semantic Versioning: "+err.Error(), "for example 0.0.1")
+		}
+	}
+	for i, pkg := range b.Packages {
+		if pkg.Name == "" {
+			errs.add(fmt.Sprintf("packages[%d].name", i), "package name must not be empty", "")
+		} else if pkg.IsGroup() {
+			if pkg.Name == "@" {
+				errs.add(fmt.Sprintf("packages[%d].name", i), "package group name must not be empty", "for example @core")
+			}
+			if pkg.Version != "" && pkg.Version != "*" {
+				errs.add(fmt.Sprintf("packages[%d].version", i), fmt.Sprintf("package group %q cannot have a version", pkg.Name), `remove the version or set it to "*"`)
+			}
+		}
+	}
+	for i, name := range b.Excludes {
+		if name == "" {
+			errs.add(fmt.Sprintf("exclude_packages[%d]", i), "excluded package name must not be empty", "")
+			continue
+		}
+		for _, pkg := range b.Packages {
+			if pkg.Name == name {
+				errs.add(fmt.Sprintf("exclude_packages[%d]", i), fmt.Sprintf("package %q is both included and excluded", name), "remove it from one of the lists")
+				break
+			}
+		}
+	}
+	for i, module := range b.Modules {
+		if module.Name == "" {
+			errs.add(fmt.Sprintf("modules[%d].name", i), "module name must not be empty", "")
+		}
+	}
+	for i, group := range b.Groups {
+		if group.Name == "" {
+			errs.add(fmt.Sprintf("groups[%d].name", i), "group name must not be empty", "")
+		}
+	}
+	errs = append(errs, validateModuleStreams(b.EnabledModules)...)
+	errs = append(errs, validateContainers(b.Containers)...)
+	errs = append(errs, b.Customizations.validate("customizations")...)
+
+	return errs
+}
+
+// DecodeJSON decodes a blueprint from JSON. Keys which do not correspond to
+// any field of a blueprint are returned as validation errors.
+func DecodeJSON(data []byte) (Blueprint, ValidationErrors, error) {
+	var bp Blueprint
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&bp); err != nil {
+		return bp, nil, err
+	}
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return bp, nil, err
+	}
+	return bp, unknownKeys(raw, reflect.TypeOf(bp), "json", ""), nil
+}
+
+// DecodeTOML decodes a blueprint from TOML. Keys which do not correspond to
+// any field of a blueprint are returned as validation errors.
+func DecodeTOML(data []byte) (Blueprint, ValidationErrors, error) {
+	var bp Blueprint
+	if err := toml.Unmarshal(data, &bp); err != nil {
+		return bp, nil, err
+	}
+	var raw map[string]interface{}
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return bp, nil, err
+	}
+	return bp, unknownKeys(raw, reflect.TypeOf(bp), "toml", ""), nil
+}
+
+// unknownKeys walks the raw decoded document along the type t and reports
+// all keys of objects which do not map to a field. Keys are matched
+// case-insensitively, like both decoders do. Type mismatches are left to the
+// decoders.
+func unknownKeys(raw interface{}, t reflect.Type, tag, field string) ValidationErrors {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var errs ValidationErrors
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get(tag), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			fields[name] = f.Type
+		}
+
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			path := key
+			if field != "" {
+				path = field + "." + key
+			}
+			ft, ok := lookupField(fields, key)
+			if !ok {
+				errs.add(path, fmt.Sprintf("unknown key %q", key), suggestKey(key, fields))
+				continue
+			}
+			errs = append(errs, unknownKeys(object[key], ft, tag, path)...)
+		}
+
+	case reflect.Slice:
+		v := reflect.ValueOf(raw)
+		if raw == nil || v.Kind() != reflect.Slice {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, unknownKeys(v.Index(i).Interface(), t.Elem(), tag, fmt.Sprintf("%s[%d]", field, i))...)
+		}
+	}
+
+	return errs
+}
+
+func lookupField(fields map[string]reflect.Type, key string) (reflect.Type, bool) {
+	if t, ok := fields[key]; ok {
+		return t, true
+	}
+	for name, t := range fields {
+		if strings.EqualFold(name, key) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// suggestKey returns a hint naming the known key closest to key, if there is
+// one which looks like a typo of it.
+func suggestKey(key string, fields map[string]reflect.Type) string {
+	best, bestDistance := "", 3
+	for name := range fields {
+		d := editDistance(strings.ToLower(key), strings.ToLower(name))
+		if d < bestDistance || (d == bestDistance && best != "" && name < best) {
+			best, bestDistance = name, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf("did you mean %q?", best)
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+var sshKeyTypes = map[string]bool{
+	"ssh-rsa":                            true,
+	"ssh-dss":                            true,
+	"ssh-ed25519":                        true,
+	"ecdsa-sha2-nistp256":                true,
+	"ecdsa-sha2-nistp384":                true,
+	"ecdsa-sha2-nistp521":                true,
+	"sk-ssh-ed25519@openssh.com":         true,
+	"sk-ecdsa-sha2-nistp256@openssh.com": true,
+}
+
+const sshKeySuggestion = "use a public key in authorized_keys format, such as the content of ~/.ssh/id_ed25519.pub"
+
+// validateSSHKey checks that keys contains one or more public keys in
+// authorized_keys format, one per line.
+func validateSSHKey(keys string) error {
+	found := false
+	for _, line := range strings.Split(keys, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		found = true
+		if !isSSHPublicKey(line) {
+			return errors.New("not a valid SSH public key")
+		}
+	}
+	if !found {
+		return errors.New("SSH key must not be empty")
+	}
+	return nil
+}
+
+// isSSHPublicKey checks that the key blob following the key type, which may
+// be preceded by options, is base64-encoded and of the same type.
+func isSSHPublicKey(line string) bool {
+	fields := strings.Fields(line)
+	for i := 0; i < len(fields)-1; i++ {
+		keyType := fields[i]
+		if !sshKeyTypes[keyType] {
+			continue
+		}
+		blob, err := base64.StdEncoding.DecodeString(fields[i+1])
+		if err != nil || len(blob) < 4 {
+			return false
+		}
+		n := binary.BigEndian.Uint32(blob)
+		return uint64(n) == uint64(len(keyType)) && len(blob) >= 4+len(keyType) && string(blob[4:4+len(keyType)]) == keyType
+	}
+	return false
+}
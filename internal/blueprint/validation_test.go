@@ -0,0 +1,119 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSSHKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGmSi2DTbgxGj4M3dPBaBFM5HvyZ5PF+2d7xPtKwqAJ9 user@example.com"
+
+func TestBlueprintValidate(t *testing.T) {
+	hostname := "my_host"
+	bp := Blueprint{
+		Name:     "test",
+		Version:  "1.0",
+		Packages: []Package{{Name: "tmux"}, {Name: ""}},
+		Customizations: &Customizations{
+			Hostname: &hostname,
+			SSHKey:   []SSHKeyCustomization{{User: "root", Key: "not a key"}},
+		},
+	}
+
+	errs := bp.Validate()
+	require.Len(t, errs, 4)
+	assert.Equal(t, "version", errs[0].Field)
+	assert.Equal(t, "packages[1].name", errs[1].Field)
+	assert.Equal(t, ValidationError{
+		Field:      "customizations.hostname",
+		Reason:     `invalid hostname "my_host"`,
+		Suggestion: "use at most 64 characters of letters, digits, '-' and '.'",
+	}, errs[2])
+	assert.Equal(t, "customizations.sshkey[0].key", errs[3].Field)
+	assert.Equal(t, `customizations.sshkey[0].key: not a valid SSH public key`, errs[3].Error())
+
+	bp = Blueprint{Name: "test", Customizations: &Customizations{
+		SSHKey: []SSHKeyCustomization{{User: "root", Key: testSSHKey}},
+	}}
+	assert.Nil(t, bp.Validate())
+}
+
+func TestValidatePackageGroups(t *testing.T) {
+	bp := Blueprint{Name: "test", Packages: []Package{{Name: "@core"}, {Name: "@standard", Version: "*"}}}
+	assert.Nil(t, bp.Validate())
+
+	bp = Blueprint{Name: "test", Packages: []Package{{Name: "@"}, {Name: "@core", Version: "1.0"}}}
+	errs := bp.Validate()
+	assert.Equal(t, ValidationErrors{
+		{Field: "packages[0].name", Reason: "package group name must not be empty", Suggestion: "for example @core"},
+		{Field: "packages[1].version", Reason: `package group "@core" cannot have a version`, Suggestion: `remove the version or set it to "*"`},
+	}, errs)
+}
+
+func TestValidateExcludes(t *testing.T) {
+	bp := Blueprint{Name: "test", Packages: []Package{{Name: "tmux"}}, Excludes: []string{"firewalld", "plymouth*"}}
+	assert.Nil(t, bp.Validate())
+
+	bp = Blueprint{Name: "test", Packages: []Package{{Name: "tmux"}}, Excludes: []string{"", "tmux"}}
+	assert.Equal(t, ValidationErrors{
+		{Field: "exclude_packages[0]", Reason: "excluded package name must not be empty"},
+		{Field: "exclude_packages[1]", Reason: `package "tmux" is both included and excluded`, Suggestion: "remove it from one of the lists"},
+	}, bp.Validate())
+}
+
+func TestValidateSSHKey(t *testing.T) {
+	valid := []string{
+		testSSHKey,
+		`no-port-forwarding,command="/bin/true" ` + testSSHKey,
+		testSSHKey + "\n\n# second key\n" + testSSHKey,
+	}
+	invalid := []string{
+		"",
+		"ssh-ed25519",
+		"ssh-ed25519 not-base64!",
+		// key blob of type ssh-ed25519
+		"ssh-rsa AAAAC3NzaC1lZDI1NTE5AAAAIGmSi2DTbgxGj4M3dPBaBFM5HvyZ5PF+2d7xPtKwqAJ9",
+		testSSHKey + "\nfoo",
+	}
+	for _, key := range valid {
+		assert.NoErrorf(t, validateSSHKey(key), "key %q", key)
+	}
+	for _, key := range invalid {
+		assert.Errorf(t, validateSSHKey(key), "key %q", key)
+	}
+}
+
+func TestDecodeUnknownKeys(t *testing.T) {
+	bp, errs, err := DecodeTOML([]byte(`
+name = "test"
+packges = []
+
+[[packages]]
+name = "tmux"
+verison = "*"
+
+[customizations]
+hostname = "host"
+
+[customizations.kernel]
+append = "nosmt"
+foo = "bar"
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "test", bp.Name)
+	assert.Equal(t, ValidationErrors{
+		{Field: "customizations.kernel.foo", Reason: `unknown key "foo"`},
+		{Field: "packages[0].verison", Reason: `unknown key "verison"`, Suggestion: `did you mean "version"?`},
+		{Field: "packges", Reason: `unknown key "packges"`, Suggestion: `did you mean "packages"?`},
+	}, errs)
+
+	_, errs, err = DecodeJSON([]byte(`{"name":"test","Description":"case-insensitive","customizations":{"user":[{"name":"alice","pasword":"x"}]}}`))
+	require.NoError(t, err)
+	assert.Equal(t, ValidationErrors{
+		{Field: "customizations.user[0].pasword", Reason: `unknown key "pasword"`, Suggestion: `did you mean "password"?`},
+	}, errs)
+
+	_, _, err = DecodeJSON([]byte(`{"name":`))
+	assert.Error(t, err)
+}
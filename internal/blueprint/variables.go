@@ -0,0 +1,174 @@
+package blueprint
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Variables are referenced as ${NAME} in any string of a blueprint. $${NAME}
+// is not substituted and results in a literal ${NAME}.
+var variableRegex = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+
+var variableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validationPlaceholder is substituted for variables without a default value
+// when validating a blueprint. Values supplied when expanding the blueprint
+// are validated again.
+const validationPlaceholder = "x"
+
+// HasVariables returns true if any string of the blueprint references a
+// variable
+func (b *Blueprint) HasVariables() bool {
+	found := false
+	walkStrings(b.toMap(), func(s string) string {
+		for _, match := range variableRegex.FindAllString(s, -1) {
+			if !strings.HasPrefix(match, "$$") {
+				found = true
+			}
+		}
+		return s
+	})
+	return found
+}
+
+// Expand returns a copy of the blueprint with all variables replaced by
+// their values in vars, or by their default values in the variables section
+// of the blueprint. The expanded blueprint is validated.
+func (b *Blueprint) Expand(vars map[string]string) (Blueprint, error) {
+	values := make(map[string]string, len(b.Variables)+len(vars))
+	for name, value := range b.Variables {
+		values[name] = value
+	}
+	for name, value := range vars {
+		if !variableNameRegex.MatchString(name) {
+			return Blueprint{}, fmt.Errorf("invalid variable name %q", name)
+		}
+		values[name] = value
+	}
+
+	var undefined []string
+	bp := b.substitute(func(name string) string {
+		value, ok := values[name]
+		if !ok {
+			undefined = append(undefined, name)
+		}
+		return value
+	})
+	if len(undefined) > 0 {
+		sort.Strings(undefined)
+		return Blueprint{}, fmt.Errorf("undefined variables: %s", strings.Join(uniqueStrings(undefined), ", "))
+	}
+	bp.Variables = nil
+
+	if errs := bp.Validate(); len(errs) > 0 {
+		return Blueprint{}, errs
+	}
+	return bp, nil
+}
+
+func (b *Blueprint) validateVariables() ValidationErrors {
+	var errs ValidationErrors
+
+	names := make([]string, 0, len(b.Variables))
+	for name := range b.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !variableNameRegex.MatchString(name) {
+			errs.add("variables."+name, fmt.Sprintf("invalid variable name %q", name), "use letters, digits and underscores")
+		}
+	}
+
+	walkStrings(b.toMap(), func(s string) string {
+		for _, match := range variableRegex.FindAllStringSubmatch(s, -1) {
+			if !strings.HasPrefix(match[0], "$$") && !variableNameRegex.MatchString(match[1]) {
+				errs.add("", fmt.Sprintf("invalid variable reference %q", match[0]), "use ${NAME}, or $${NAME} for a literal ${NAME}")
+			}
+		}
+		return s
+	})
+
+	return errs
+}
+
+// placeholderCopy returns a copy of the blueprint with all variables
+// replaced by their default value or a placeholder, for validation
+func (b *Blueprint) placeholderCopy() Blueprint {
+	return b.substitute(func(name string) string {
+		if value, ok := b.Variables[name]; ok {
+			return value
+		}
+		return validationPlaceholder
+	})
+}
+
+func (b *Blueprint) substitute(lookup func(name string) string) Blueprint {
+	m := walkStrings(b.toMap(), func(s string) string {
+		return variableRegex.ReplaceAllStringFunc(s, func(match string) string {
+			if strings.HasPrefix(match, "$$") {
+				return match[1:]
+			}
+			return lookup(match[2 : len(match)-1])
+		})
+	})
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	var bp Blueprint
+	err = json.Unmarshal(data, &bp)
+	if err != nil {
+		panic(err)
+	}
+	return bp
+}
+
+// toMap returns the JSON representation of the blueprint without its
+// variables section
+func (b *Blueprint) toMap() interface{} {
+	bp := *b
+	bp.Variables = nil
+	data, err := json.Marshal(bp)
+	if err != nil {
+		panic(err)
+	}
+	var m interface{}
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// walkStrings replaces every string value in a decoded JSON document by the
+// result of f. Object keys are left alone.
+func walkStrings(v interface{}, f func(string) string) interface{} {
+	switch value := v.(type) {
+	case string:
+		return f(value)
+	case []interface{}:
+		for i := range value {
+			value[i] = walkStrings(value[i], f)
+		}
+	case map[string]interface{}:
+		for key := range value {
+			value[key] = walkStrings(value[key], f)
+		}
+	}
+	return v
+}
+
+func uniqueStrings(sorted []string) []string {
+	var unique []string
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,55 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpand(t *testing.T) {
+	hostname := "${HOSTNAME_PREFIX}-web"
+	bp := Blueprint{
+		Name:     "web",
+		Version:  "0.0.1",
+		Packages: []Package{{Name: "httpd", Version: "${HTTPD_VERSION}"}},
+		Customizations: &Customizations{
+			Hostname: &hostname,
+			Kernel:   &KernelCustomization{Append: "$${NOT_A_VARIABLE}"},
+		},
+		Variables: map[string]string{"HTTPD_VERSION": "*"},
+	}
+	require.True(t, bp.HasVariables())
+	require.Empty(t, bp.Validate())
+
+	expanded, err := bp.Expand(map[string]string{"HOSTNAME_PREFIX": "prod"})
+	require.NoError(t, err)
+	assert.Equal(t, "prod-web", *expanded.Customizations.GetHostname())
+	assert.Equal(t, "*", expanded.Packages[0].Version)
+	assert.Equal(t, "${NOT_A_VARIABLE}", expanded.Customizations.GetKernel().Append)
+	assert.Nil(t, expanded.Variables)
+
+	// the original blueprint is unchanged
+	assert.Equal(t, "${HOSTNAME_PREFIX}-web", *bp.Customizations.Hostname)
+
+	// values override defaults
+	expanded, err = bp.Expand(map[string]string{"HOSTNAME_PREFIX": "prod", "HTTPD_VERSION": "2.4.46"})
+	require.NoError(t, err)
+	assert.Equal(t, "2.4.46", expanded.Packages[0].Version)
+
+	_, err = bp.Expand(nil)
+	assert.EqualError(t, err, "undefined variables: HOSTNAME_PREFIX")
+	_, err = bp.Expand(map[string]string{"HOSTNAME_PREFIX": "-prod"})
+	assert.Error(t, err)
+	_, err = bp.Expand(map[string]string{"HOSTNAME PREFIX": "prod"})
+	assert.EqualError(t, err, `invalid variable name "HOSTNAME PREFIX"`)
+}
+
+func TestValidateVariables(t *testing.T) {
+	hostname := "${1PREFIX}-web"
+	bp := Blueprint{
+		Customizations: &Customizations{Hostname: &hostname},
+		Variables:      map[string]string{"not-valid": "x"},
+	}
+	assert.EqualError(t, bp.Validate(), `variables.not-valid: invalid variable name "not-valid"; invalid variable reference "${1PREFIX}"`)
+}
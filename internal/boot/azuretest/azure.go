@@ -87,7 +87,7 @@ func UploadImageToAzure(c *azureCredentials, imagePath string, imageName string)
 		ContainerName: c.ContainerName,
 		ImageName:     imageName,
 	}
-	err := azure.UploadImage(c.Credentials, metadata, imagePath, 16)
+	err := azure.UploadImage(c.Credentials, metadata, imagePath, 16, nil, nil)
 	if err != nil {
 		return fmt.Errorf("upload to azure failed: %v", err)
 	}
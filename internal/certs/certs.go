@@ -0,0 +1,224 @@
+// Package certs loads the certificates of TLS connections and reloads them
+// when their files change, so that certificates can be rotated without
+// restarting the services using them.
+//
+// Files are checked for modifications whenever the certificates are used,
+// e.g. on each TLS handshake. When files fail to load, e.g. because a key
+// was replaced, but the certificate was not yet, the previous certificates
+// are kept until the files are modified again.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reloader calls load again when any of its files were modified since they
+// were loaded last
+type reloader struct {
+	mu       sync.Mutex
+	paths    []string
+	modTimes []time.Time
+	load     func() error
+}
+
+func newReloader(load func() error, paths ...string) (*reloader, error) {
+	r := &reloader{
+		paths:    paths,
+		modTimes: modTimes(paths),
+		load:     load,
+	}
+	err := load()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// modTimes returns the modification times of files, which are zero for
+// files which cannot be read
+func modTimes(paths []string) []time.Time {
+	times := make([]time.Time, len(paths))
+	for i, path := range paths {
+		info, err := os.Stat(path)
+		if err == nil {
+			times[i] = info.ModTime()
+		}
+	}
+	return times
+}
+
+// do calls f while the loaded values cannot change, after reloading them if
+// needed
+func (r *reloader) do(f func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	times := modTimes(r.paths)
+	for i := range times {
+		if !times[i].Equal(r.modTimes[i]) {
+			r.modTimes = times
+			err := r.load()
+			if err != nil {
+				log.Printf("Cannot reload %s, the previous version is still used: %v", strings.Join(r.paths, " and "), err)
+			} else {
+				log.Printf("Reloaded %s", strings.Join(r.paths, " and "))
+			}
+			break
+		}
+	}
+
+	f()
+}
+
+// KeyPair is a certificate and its private key
+type KeyPair struct {
+	reloader *reloader
+	cert     *tls.Certificate
+}
+
+// NewKeyPair loads a key pair from PEM files
+func NewKeyPair(certFile, keyFile string) (*KeyPair, error) {
+	k := &KeyPair{}
+	var err error
+	k.reloader, err = newReloader(func() error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		k.cert = &cert
+		return nil
+	}, certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Certificate returns the current certificate
+func (k *KeyPair) Certificate() *tls.Certificate {
+	var cert *tls.Certificate
+	k.reloader.do(func() {
+		cert = k.cert
+	})
+	return cert
+}
+
+// GetCertificate can be used as GetCertificate of a tls.Config of servers
+func (k *KeyPair) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return k.Certificate(), nil
+}
+
+// GetClientCertificate can be used as GetClientCertificate of a tls.Config
+// of clients
+func (k *KeyPair) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return k.Certificate(), nil
+}
+
+// CertPool is a set of CA certificates
+type CertPool struct {
+	reloader *reloader
+	pool     *x509.CertPool
+}
+
+// NewCertPool loads the CA certificates in a PEM file
+func NewCertPool(file string) (*CertPool, error) {
+	p := &CertPool{}
+	var err error
+	p.reloader, err = newReloader(func() error {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates in %s", file)
+		}
+		p.pool = pool
+		return nil
+	}, file)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Pool returns the current certificates
+func (p *CertPool) Pool() *x509.CertPool {
+	var pool *x509.CertPool
+	p.reloader.do(func() {
+		pool = p.pool
+	})
+	return pool
+}
+
+// RevocationList is a certificate revocation list (CRL)
+type RevocationList struct {
+	reloader *reloader
+	crl      *pkix.CertificateList
+	// revoked contains the serial numbers of the revoked certificates
+	revoked map[string]bool
+}
+
+// NewRevocationList loads a CRL from a PEM or DER file
+func NewRevocationList(file string) (*RevocationList, error) {
+	r := &RevocationList{}
+	var err error
+	r.reloader, err = newReloader(func() error {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		crl, err := x509.ParseCRL(data)
+		if err != nil {
+			return fmt.Errorf("cannot parse %s: %v", file, err)
+		}
+		r.crl = crl
+		r.revoked = make(map[string]bool)
+		for _, cert := range crl.TBSCertList.RevokedCertificates {
+			r.revoked[cert.SerialNumber.String()] = true
+		}
+		return nil
+	}, file)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Check returns an error if any of the certificates in verifiedChains was
+// revoked by the issuer of the CRL, or if the CRL has expired. It can be
+// called from VerifyPeerCertificate of a tls.Config.
+func (r *RevocationList) Check(verifiedChains [][]*x509.Certificate) error {
+	var err error
+	r.reloader.do(func() {
+		if r.crl.HasExpired(time.Now()) {
+			err = errors.New("the certificate revocation list has expired")
+			return
+		}
+
+		for _, chain := range verifiedChains {
+			for i := 0; i < len(chain)-1; i++ {
+				cert, issuer := chain[i], chain[i+1]
+				if !r.revoked[cert.SerialNumber.String()] {
+					continue
+				}
+				// serial numbers are only unique per issuer
+				if issuer.CheckCRLSignature(r.crl) == nil {
+					err = fmt.Errorf("the certificate of %s has been revoked", cert.Subject.CommonName)
+					return
+				}
+			}
+		}
+	})
+	return err
+}
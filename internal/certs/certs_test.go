@@ -0,0 +1,164 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// newTestCert returns a certificate for name, which is self-signed if
+// issuer is nil
+func newTestCert(t *testing.T, name string, serial int64, issuer *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  issuer == nil,
+	}
+	parent, parentKey := template, key
+	if issuer != nil {
+		parent, parentKey = issuer.cert, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert, key, der}
+}
+
+// write writes the certificate and key of c as PEM files and sets their
+// modification time to modTime
+func (c *testCert) write(t *testing.T, certFile, keyFile string, modTime time.Time) {
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), modTime)
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), modTime)
+}
+
+func writeFile(t *testing.T, name string, data []byte, modTime time.Time) {
+	require.NoError(t, ioutil.WriteFile(name, data, 0600))
+	require.NoError(t, os.Chtimes(name, modTime, modTime))
+}
+
+func TestKeyPair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certFile := filepath.Join(dir, "crt.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	now := time.Now()
+
+	first := newTestCert(t, "first", 1, nil)
+	first.write(t, certFile, keyFile, now)
+	keyPair, err := NewKeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	require.Equal(t, first.der, keyPair.Certificate().Certificate[0])
+
+	// rotated
+	second := newTestCert(t, "second", 2, nil)
+	second.write(t, certFile, keyFile, now.Add(time.Minute))
+	cert, err := keyPair.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	require.Equal(t, second.der, cert.Certificate[0])
+
+	// the key does not match the certificate yet
+	third := newTestCert(t, "third", 3, nil)
+	third.write(t, certFile, filepath.Join(dir, "other-key.pem"), now.Add(2*time.Minute))
+	cert, err = keyPair.GetClientCertificate(&tls.CertificateRequestInfo{})
+	require.NoError(t, err)
+	require.Equal(t, second.der, cert.Certificate[0])
+
+	third.write(t, certFile, keyFile, now.Add(3*time.Minute))
+	require.Equal(t, third.der, keyPair.Certificate().Certificate[0])
+
+	_, err = NewKeyPair(filepath.Join(dir, "missing.pem"), keyFile)
+	require.Error(t, err)
+}
+
+func TestCertPool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "ca.pem")
+	now := time.Now()
+
+	first := newTestCert(t, "first", 1, nil)
+	first.write(t, file, filepath.Join(dir, "key.pem"), now)
+	pool, err := NewCertPool(file)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{first.cert.RawSubject}, pool.Pool().Subjects())
+
+	second := newTestCert(t, "second", 2, nil)
+	second.write(t, file, filepath.Join(dir, "key.pem"), now.Add(time.Minute))
+	require.Equal(t, [][]byte{second.cert.RawSubject}, pool.Pool().Subjects())
+
+	writeFile(t, file, []byte("garbage"), now.Add(2*time.Minute))
+	require.Equal(t, [][]byte{second.cert.RawSubject}, pool.Pool().Subjects())
+
+	_, err = NewCertPool(file)
+	require.EqualError(t, err, "no certificates in "+file)
+}
+
+func TestRevocationList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, "ca", 1, nil)
+	otherCA := newTestCert(t, "other-ca", 1, nil)
+	revoked := newTestCert(t, "revoked", 2, ca)
+	valid := newTestCert(t, "valid", 3, ca)
+	// has the serial number of a revoked certificate of another issuer
+	other := newTestCert(t, "other", 2, otherCA)
+
+	file := filepath.Join(dir, "crl.pem")
+	now := time.Now()
+	writeCRL := func(nextUpdate, modTime time.Time) {
+		crl, err := ca.cert.CreateCRL(rand.Reader, ca.key, []pkix.RevokedCertificate{
+			{SerialNumber: revoked.cert.SerialNumber, RevocationTime: now},
+		}, now.Add(-time.Hour), nextUpdate)
+		require.NoError(t, err)
+		writeFile(t, file, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}), modTime)
+	}
+
+	writeCRL(now.Add(time.Hour), now)
+	crl, err := NewRevocationList(file)
+	require.NoError(t, err)
+
+	require.NoError(t, crl.Check([][]*x509.Certificate{{valid.cert, ca.cert}}))
+	require.NoError(t, crl.Check([][]*x509.Certificate{{other.cert, otherCA.cert}}))
+	require.EqualError(t, crl.Check([][]*x509.Certificate{{revoked.cert, ca.cert}}), "the certificate of revoked has been revoked")
+
+	writeCRL(now.Add(-time.Minute), now.Add(time.Minute))
+	require.EqualError(t, crl.Check([][]*x509.Certificate{{valid.cert, ca.cert}}), "the certificate revocation list has expired")
+
+	writeFile(t, file, []byte("garbage"), now.Add(2*time.Minute))
+	_, err = NewRevocationList(file)
+	require.Error(t, err)
+}
@@ -0,0 +1,6 @@
+//go:generate go run github.com/deepmap/oapi-codegen/cmd/oapi-codegen --package=cloudapi --generate types,client -o cloudapi.gen.go ../../cloudapi/openapi.yml
+
+// Package cloudapi is a client of the cloud API of osbuild-composer, which is
+// generated from its OpenAPI document. The base URL of clients must include
+// the path the API is served at, e.g. https://composer.example.com/api/composer/v1.
+package cloudapi
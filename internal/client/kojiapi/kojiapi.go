@@ -0,0 +1,6 @@
+//go:generate go run github.com/deepmap/oapi-codegen/cmd/oapi-codegen --package=kojiapi --generate types,client -o kojiapi.gen.go ../../kojiapi/api/openapi.yml
+
+// Package kojiapi is a client of the koji API of osbuild-composer, which is
+// generated from its OpenAPI document. The base URL of clients must include
+// the path the API is served at, e.g. https://composer.example.com/api/composer-koji/v1.
+package kojiapi
@@ -8,28 +8,33 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"github.com/deepmap/oapi-codegen/pkg/runtime"
 	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/go-chi/chi"
-	"io"
-	"io/ioutil"
 	"net/http"
-	"net/url"
 	"strings"
 )
 
+// AWSImageCopy defines model for AWSImageCopy.
+type AWSImageCopy struct {
+	AmiId  string `json:"ami_id"`
+	Region string `json:"region"`
+}
+
 // AWSUploadRequestOptions defines model for AWSUploadRequestOptions.
 type AWSUploadRequestOptions struct {
-	Ec2    AWSUploadRequestOptionsEc2 `json:"ec2"`
-	Region string                     `json:"region"`
-	S3     AWSUploadRequestOptionsS3  `json:"s3"`
+	Ec2    *AWSUploadRequestOptionsEc2 `json:"ec2,omitempty"`
+	Region string                      `json:"region"`
+	S3     AWSUploadRequestOptionsS3   `json:"s3"`
 }
 
 // AWSUploadRequestOptionsEc2 defines model for AWSUploadRequestOptionsEc2.
 type AWSUploadRequestOptionsEc2 struct {
-	AccessKeyId       string    `json:"access_key_id"`
+	AccessKeyId string `json:"access_key_id"`
+
+	// Regions the AMI is copied to after it is registered
+	CopyToRegions     *[]string `json:"copy_to_regions,omitempty"`
 	SecretAccessKey   string    `json:"secret_access_key"`
 	ShareWithAccounts *[]string `json:"share_with_accounts,omitempty"`
 	SnapshotName      *string   `json:"snapshot_name,omitempty"`
@@ -42,6 +47,19 @@ type AWSUploadRequestOptionsS3 struct {
 	SecretAccessKey string `json:"secret_access_key"`
 }
 
+// AWSUploadStatus defines model for AWSUploadStatus.
+type AWSUploadStatus struct {
+	AmiId *string `json:"ami_id,omitempty"`
+
+	// The AMIs copied to other regions
+	Copies *[]AWSImageCopy `json:"copies,omitempty"`
+
+	// Presigned URL of the image in S3, which is only set when the image
+	// was not registered as an AMI. It expires after a day.
+	DownloadUrl *string `json:"download_url,omitempty"`
+	Region      *string `json:"region,omitempty"`
+}
+
 // ComposeRequest defines model for ComposeRequest.
 type ComposeRequest struct {
 	Customizations *Customizations `json:"customizations,omitempty"`
@@ -65,6 +83,19 @@ type Customizations struct {
 	Subscription *Subscription `json:"subscription,omitempty"`
 }
 
+// ImageChecksums defines model for ImageChecksums.
+type ImageChecksums struct {
+
+	// SHA256 checksums by filename
+	Sha256 map[string]interface{} `json:"sha256"`
+
+	// Content of SHA256SUMS, in the format of sha256sum
+	Sha256sums string `json:"sha256sums"`
+
+	// Signatures by the name of the signed file, including SHA256SUMS
+	Signatures *map[string]interface{} `json:"signatures,omitempty"`
+}
+
 // ImageRequest defines model for ImageRequest.
 type ImageRequest struct {
 	Architecture   string          `json:"architecture"`
@@ -75,8 +106,12 @@ type ImageRequest struct {
 
 // ImageStatus defines model for ImageStatus.
 type ImageStatus struct {
-	Status       string        `json:"status"`
-	UploadStatus *UploadStatus `json:"upload_status,omitempty"`
+
+	// Checksums of the artifacts of the build and, if the worker signs
+	// artifacts, their detached ASCII-armored GPG signatures
+	Checksums    *ImageChecksums `json:"checksums,omitempty"`
+	Status       string          `json:"status"`
+	UploadStatus *UploadStatus   `json:"upload_status,omitempty"`
 }
 
 // Repository defines model for Repository.
@@ -96,6 +131,12 @@ type Subscription struct {
 	ServerUrl     string `json:"server-url"`
 }
 
+// UploadProgress defines model for UploadProgress.
+type UploadProgress struct {
+	Total       int64 `json:"total"`
+	Transferred int64 `json:"transferred"`
+}
+
 // UploadRequest defines model for UploadRequest.
 type UploadRequest struct {
 	Options interface{} `json:"options"`
@@ -104,8 +145,15 @@ type UploadRequest struct {
 
 // UploadStatus defines model for UploadStatus.
 type UploadStatus struct {
-	Status string      `json:"status"`
-	Type   UploadTypes `json:"type"`
+
+	// Why the upload failed
+	Error   *string      `json:"error,omitempty"`
+	Options *interface{} `json:"options,omitempty"`
+
+	// Progress of a running upload, in bytes
+	Progress *UploadProgress `json:"progress,omitempty"`
+	Status   string          `json:"status"`
+	Type     UploadTypes     `json:"type"`
 }
 
 // UploadTypes defines model for UploadTypes.
@@ -127,566 +175,6 @@ type ComposeJSONBody ComposeRequest
 // ComposeRequestBody defines body for Compose for application/json ContentType.
 type ComposeJSONRequestBody ComposeJSONBody
 
-// RequestEditorFn  is the function signature for the RequestEditor callback function
-type RequestEditorFn func(ctx context.Context, req *http.Request) error
-
-// Doer performs HTTP requests.
-//
-// The standard http.Client implements this interface.
-type HttpRequestDoer interface {
-	Do(req *http.Request) (*http.Response, error)
-}
-
-// Client which conforms to the OpenAPI3 specification for this service.
-type Client struct {
-	// The endpoint of the server conforming to this interface, with scheme,
-	// https://api.deepmap.com for example.
-	Server string
-
-	// Doer for performing requests, typically a *http.Client with any
-	// customized settings, such as certificate chains.
-	Client HttpRequestDoer
-
-	// A callback for modifying requests which are generated before sending over
-	// the network.
-	RequestEditor RequestEditorFn
-}
-
-// ClientOption allows setting custom parameters during construction
-type ClientOption func(*Client) error
-
-// Creates a new Client, with reasonable defaults
-func NewClient(server string, opts ...ClientOption) (*Client, error) {
-	// create a client with sane default values
-	client := Client{
-		Server: server,
-	}
-	// mutate client and add all optional params
-	for _, o := range opts {
-		if err := o(&client); err != nil {
-			return nil, err
-		}
-	}
-	// ensure the server URL always has a trailing slash
-	if !strings.HasSuffix(client.Server, "/") {
-		client.Server += "/"
-	}
-	// create httpClient, if not already present
-	if client.Client == nil {
-		client.Client = http.DefaultClient
-	}
-	return &client, nil
-}
-
-// WithHTTPClient allows overriding the default Doer, which is
-// automatically created using http.Client. This is useful for tests.
-func WithHTTPClient(doer HttpRequestDoer) ClientOption {
-	return func(c *Client) error {
-		c.Client = doer
-		return nil
-	}
-}
-
-// WithRequestEditorFn allows setting up a callback function, which will be
-// called right before sending the request. This can be used to mutate the request.
-func WithRequestEditorFn(fn RequestEditorFn) ClientOption {
-	return func(c *Client) error {
-		c.RequestEditor = fn
-		return nil
-	}
-}
-
-// The interface specification for the client above.
-type ClientInterface interface {
-	// Compose request  with any body
-	ComposeWithBody(ctx context.Context, contentType string, body io.Reader) (*http.Response, error)
-
-	Compose(ctx context.Context, body ComposeJSONRequestBody) (*http.Response, error)
-
-	// ComposeStatus request
-	ComposeStatus(ctx context.Context, id string) (*http.Response, error)
-
-	// GetOpenapiJson request
-	GetOpenapiJson(ctx context.Context) (*http.Response, error)
-
-	// GetVersion request
-	GetVersion(ctx context.Context) (*http.Response, error)
-}
-
-func (c *Client) ComposeWithBody(ctx context.Context, contentType string, body io.Reader) (*http.Response, error) {
-	req, err := NewComposeRequestWithBody(c.Server, contentType, body)
-	if err != nil {
-		return nil, err
-	}
-	req = req.WithContext(ctx)
-	if c.RequestEditor != nil {
-		err = c.RequestEditor(ctx, req)
-		if err != nil {
-			return nil, err
-		}
-	}
-	return c.Client.Do(req)
-}
-
-func (c *Client) Compose(ctx context.Context, body ComposeJSONRequestBody) (*http.Response, error) {
-	req, err := NewComposeRequest(c.Server, body)
-	if err != nil {
-		return nil, err
-	}
-	req = req.WithContext(ctx)
-	if c.RequestEditor != nil {
-		err = c.RequestEditor(ctx, req)
-		if err != nil {
-			return nil, err
-		}
-	}
-	return c.Client.Do(req)
-}
-
-func (c *Client) ComposeStatus(ctx context.Context, id string) (*http.Response, error) {
-	req, err := NewComposeStatusRequest(c.Server, id)
-	if err != nil {
-		return nil, err
-	}
-	req = req.WithContext(ctx)
-	if c.RequestEditor != nil {
-		err = c.RequestEditor(ctx, req)
-		if err != nil {
-			return nil, err
-		}
-	}
-	return c.Client.Do(req)
-}
-
-func (c *Client) GetOpenapiJson(ctx context.Context) (*http.Response, error) {
-	req, err := NewGetOpenapiJsonRequest(c.Server)
-	if err != nil {
-		return nil, err
-	}
-	req = req.WithContext(ctx)
-	if c.RequestEditor != nil {
-		err = c.RequestEditor(ctx, req)
-		if err != nil {
-			return nil, err
-		}
-	}
-	return c.Client.Do(req)
-}
-
-func (c *Client) GetVersion(ctx context.Context) (*http.Response, error) {
-	req, err := NewGetVersionRequest(c.Server)
-	if err != nil {
-		return nil, err
-	}
-	req = req.WithContext(ctx)
-	if c.RequestEditor != nil {
-		err = c.RequestEditor(ctx, req)
-		if err != nil {
-			return nil, err
-		}
-	}
-	return c.Client.Do(req)
-}
-
-// NewComposeRequest calls the generic Compose builder with application/json body
-func NewComposeRequest(server string, body ComposeJSONRequestBody) (*http.Request, error) {
-	var bodyReader io.Reader
-	buf, err := json.Marshal(body)
-	if err != nil {
-		return nil, err
-	}
-	bodyReader = bytes.NewReader(buf)
-	return NewComposeRequestWithBody(server, "application/json", bodyReader)
-}
-
-// NewComposeRequestWithBody generates requests for Compose with any type of body
-func NewComposeRequestWithBody(server string, contentType string, body io.Reader) (*http.Request, error) {
-	var err error
-
-	queryUrl, err := url.Parse(server)
-	if err != nil {
-		return nil, err
-	}
-
-	basePath := fmt.Sprintf("/compose")
-	if basePath[0] == '/' {
-		basePath = basePath[1:]
-	}
-
-	queryUrl, err = queryUrl.Parse(basePath)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest("POST", queryUrl.String(), body)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Add("Content-Type", contentType)
-	return req, nil
-}
-
-// NewComposeStatusRequest generates requests for ComposeStatus
-func NewComposeStatusRequest(server string, id string) (*http.Request, error) {
-	var err error
-
-	var pathParam0 string
-
-	pathParam0, err = runtime.StyleParam("simple", false, "id", id)
-	if err != nil {
-		return nil, err
-	}
-
-	queryUrl, err := url.Parse(server)
-	if err != nil {
-		return nil, err
-	}
-
-	basePath := fmt.Sprintf("/compose/%s", pathParam0)
-	if basePath[0] == '/' {
-		basePath = basePath[1:]
-	}
-
-	queryUrl, err = queryUrl.Parse(basePath)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest("GET", queryUrl.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-
-	return req, nil
-}
-
-// NewGetOpenapiJsonRequest generates requests for GetOpenapiJson
-func NewGetOpenapiJsonRequest(server string) (*http.Request, error) {
-	var err error
-
-	queryUrl, err := url.Parse(server)
-	if err != nil {
-		return nil, err
-	}
-
-	basePath := fmt.Sprintf("/openapi.json")
-	if basePath[0] == '/' {
-		basePath = basePath[1:]
-	}
-
-	queryUrl, err = queryUrl.Parse(basePath)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest("GET", queryUrl.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-
-	return req, nil
-}
-
-// NewGetVersionRequest generates requests for GetVersion
-func NewGetVersionRequest(server string) (*http.Request, error) {
-	var err error
-
-	queryUrl, err := url.Parse(server)
-	if err != nil {
-		return nil, err
-	}
-
-	basePath := fmt.Sprintf("/version")
-	if basePath[0] == '/' {
-		basePath = basePath[1:]
-	}
-
-	queryUrl, err = queryUrl.Parse(basePath)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest("GET", queryUrl.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-
-	return req, nil
-}
-
-// ClientWithResponses builds on ClientInterface to offer response payloads
-type ClientWithResponses struct {
-	ClientInterface
-}
-
-// NewClientWithResponses creates a new ClientWithResponses, which wraps
-// Client with return type handling
-func NewClientWithResponses(server string, opts ...ClientOption) (*ClientWithResponses, error) {
-	client, err := NewClient(server, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return &ClientWithResponses{client}, nil
-}
-
-// WithBaseURL overrides the baseURL.
-func WithBaseURL(baseURL string) ClientOption {
-	return func(c *Client) error {
-		newBaseURL, err := url.Parse(baseURL)
-		if err != nil {
-			return err
-		}
-		c.Server = newBaseURL.String()
-		return nil
-	}
-}
-
-// ClientWithResponsesInterface is the interface specification for the client with responses above.
-type ClientWithResponsesInterface interface {
-	// Compose request  with any body
-	ComposeWithBodyWithResponse(ctx context.Context, contentType string, body io.Reader) (*ComposeResponse, error)
-
-	ComposeWithResponse(ctx context.Context, body ComposeJSONRequestBody) (*ComposeResponse, error)
-
-	// ComposeStatus request
-	ComposeStatusWithResponse(ctx context.Context, id string) (*ComposeStatusResponse, error)
-
-	// GetOpenapiJson request
-	GetOpenapiJsonWithResponse(ctx context.Context) (*GetOpenapiJsonResponse, error)
-
-	// GetVersion request
-	GetVersionWithResponse(ctx context.Context) (*GetVersionResponse, error)
-}
-
-type ComposeResponse struct {
-	Body         []byte
-	HTTPResponse *http.Response
-	JSON201      *ComposeResult
-}
-
-// Status returns HTTPResponse.Status
-func (r ComposeResponse) Status() string {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.Status
-	}
-	return http.StatusText(0)
-}
-
-// StatusCode returns HTTPResponse.StatusCode
-func (r ComposeResponse) StatusCode() int {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.StatusCode
-	}
-	return 0
-}
-
-type ComposeStatusResponse struct {
-	Body         []byte
-	HTTPResponse *http.Response
-	JSON200      *ComposeStatus
-}
-
-// Status returns HTTPResponse.Status
-func (r ComposeStatusResponse) Status() string {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.Status
-	}
-	return http.StatusText(0)
-}
-
-// StatusCode returns HTTPResponse.StatusCode
-func (r ComposeStatusResponse) StatusCode() int {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.StatusCode
-	}
-	return 0
-}
-
-type GetOpenapiJsonResponse struct {
-	Body         []byte
-	HTTPResponse *http.Response
-}
-
-// Status returns HTTPResponse.Status
-func (r GetOpenapiJsonResponse) Status() string {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.Status
-	}
-	return http.StatusText(0)
-}
-
-// StatusCode returns HTTPResponse.StatusCode
-func (r GetOpenapiJsonResponse) StatusCode() int {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.StatusCode
-	}
-	return 0
-}
-
-type GetVersionResponse struct {
-	Body         []byte
-	HTTPResponse *http.Response
-	JSON200      *Version
-}
-
-// Status returns HTTPResponse.Status
-func (r GetVersionResponse) Status() string {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.Status
-	}
-	return http.StatusText(0)
-}
-
-// StatusCode returns HTTPResponse.StatusCode
-func (r GetVersionResponse) StatusCode() int {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.StatusCode
-	}
-	return 0
-}
-
-// ComposeWithBodyWithResponse request with arbitrary body returning *ComposeResponse
-func (c *ClientWithResponses) ComposeWithBodyWithResponse(ctx context.Context, contentType string, body io.Reader) (*ComposeResponse, error) {
-	rsp, err := c.ComposeWithBody(ctx, contentType, body)
-	if err != nil {
-		return nil, err
-	}
-	return ParseComposeResponse(rsp)
-}
-
-func (c *ClientWithResponses) ComposeWithResponse(ctx context.Context, body ComposeJSONRequestBody) (*ComposeResponse, error) {
-	rsp, err := c.Compose(ctx, body)
-	if err != nil {
-		return nil, err
-	}
-	return ParseComposeResponse(rsp)
-}
-
-// ComposeStatusWithResponse request returning *ComposeStatusResponse
-func (c *ClientWithResponses) ComposeStatusWithResponse(ctx context.Context, id string) (*ComposeStatusResponse, error) {
-	rsp, err := c.ComposeStatus(ctx, id)
-	if err != nil {
-		return nil, err
-	}
-	return ParseComposeStatusResponse(rsp)
-}
-
-// GetOpenapiJsonWithResponse request returning *GetOpenapiJsonResponse
-func (c *ClientWithResponses) GetOpenapiJsonWithResponse(ctx context.Context) (*GetOpenapiJsonResponse, error) {
-	rsp, err := c.GetOpenapiJson(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return ParseGetOpenapiJsonResponse(rsp)
-}
-
-// GetVersionWithResponse request returning *GetVersionResponse
-func (c *ClientWithResponses) GetVersionWithResponse(ctx context.Context) (*GetVersionResponse, error) {
-	rsp, err := c.GetVersion(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return ParseGetVersionResponse(rsp)
-}
-
-// ParseComposeResponse parses an HTTP response from a ComposeWithResponse call
-func ParseComposeResponse(rsp *http.Response) (*ComposeResponse, error) {
-	bodyBytes, err := ioutil.ReadAll(rsp.Body)
-	defer rsp.Body.Close()
-	if err != nil {
-		return nil, err
-	}
-
-	response := &ComposeResponse{
-		Body:         bodyBytes,
-		HTTPResponse: rsp,
-	}
-
-	switch {
-	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 201:
-		var dest ComposeResult
-		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
-			return nil, err
-		}
-		response.JSON201 = &dest
-
-	}
-
-	return response, nil
-}
-
-// ParseComposeStatusResponse parses an HTTP response from a ComposeStatusWithResponse call
-func ParseComposeStatusResponse(rsp *http.Response) (*ComposeStatusResponse, error) {
-	bodyBytes, err := ioutil.ReadAll(rsp.Body)
-	defer rsp.Body.Close()
-	if err != nil {
-		return nil, err
-	}
-
-	response := &ComposeStatusResponse{
-		Body:         bodyBytes,
-		HTTPResponse: rsp,
-	}
-
-	switch {
-	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
-		var dest ComposeStatus
-		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
-			return nil, err
-		}
-		response.JSON200 = &dest
-
-	}
-
-	return response, nil
-}
-
-// ParseGetOpenapiJsonResponse parses an HTTP response from a GetOpenapiJsonWithResponse call
-func ParseGetOpenapiJsonResponse(rsp *http.Response) (*GetOpenapiJsonResponse, error) {
-	bodyBytes, err := ioutil.ReadAll(rsp.Body)
-	defer rsp.Body.Close()
-	if err != nil {
-		return nil, err
-	}
-
-	response := &GetOpenapiJsonResponse{
-		Body:         bodyBytes,
-		HTTPResponse: rsp,
-	}
-
-	switch {
-	}
-
-	return response, nil
-}
-
-// ParseGetVersionResponse parses an HTTP response from a GetVersionWithResponse call
-func ParseGetVersionResponse(rsp *http.Response) (*GetVersionResponse, error) {
-	bodyBytes, err := ioutil.ReadAll(rsp.Body)
-	defer rsp.Body.Close()
-	if err != nil {
-		return nil, err
-	}
-
-	response := &GetVersionResponse{
-		Body:         bodyBytes,
-		HTTPResponse: rsp,
-	}
-
-	switch {
-	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
-		var dest Version
-		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
-			return nil, err
-		}
-		response.JSON200 = &dest
-
-	}
-
-	return response, nil
-}
-
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
 	// Create compose
@@ -695,6 +183,9 @@ type ServerInterface interface {
 	// The status of a compose
 	// (GET /compose/{id})
 	ComposeStatus(w http.ResponseWriter, r *http.Request, id string)
+	// The software bill of materials of a compose
+	// (GET /compose/{id}/sbom)
+	ComposeSbom(w http.ResponseWriter, r *http.Request, id string)
 	// get the openapi json specification
 	// (GET /openapi.json)
 	GetOpenapiJson(w http.ResponseWriter, r *http.Request)
@@ -712,6 +203,8 @@ type ServerInterfaceWrapper struct {
 func (siw *ServerInterfaceWrapper) Compose(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	ctx = context.WithValue(ctx, "bearerAuth.Scopes", []string{""})
+
 	siw.Handler.Compose(w, r.WithContext(ctx))
 }
 
@@ -730,13 +223,37 @@ func (siw *ServerInterfaceWrapper) ComposeStatus(w http.ResponseWriter, r *http.
 		return
 	}
 
+	ctx = context.WithValue(ctx, "bearerAuth.Scopes", []string{""})
+
 	siw.Handler.ComposeStatus(w, r.WithContext(ctx), id)
 }
 
+// ComposeSbom operation middleware
+func (siw *ServerInterfaceWrapper) ComposeSbom(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameter("simple", false, "id", chi.URLParam(r, "id"), &id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid format for parameter id: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	ctx = context.WithValue(ctx, "bearerAuth.Scopes", []string{""})
+
+	siw.Handler.ComposeSbom(w, r.WithContext(ctx), id)
+}
+
 // GetOpenapiJson operation middleware
 func (siw *ServerInterfaceWrapper) GetOpenapiJson(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	ctx = context.WithValue(ctx, "bearerAuth.Scopes", []string{""})
+
 	siw.Handler.GetOpenapiJson(w, r.WithContext(ctx))
 }
 
@@ -744,6 +261,8 @@ func (siw *ServerInterfaceWrapper) GetOpenapiJson(w http.ResponseWriter, r *http
 func (siw *ServerInterfaceWrapper) GetVersion(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	ctx = context.WithValue(ctx, "bearerAuth.Scopes", []string{""})
+
 	siw.Handler.GetVersion(w, r.WithContext(ctx))
 }
 
@@ -764,6 +283,9 @@ func HandlerFromMux(si ServerInterface, r chi.Router) http.Handler {
 	r.Group(func(r chi.Router) {
 		r.Get("/compose/{id}", wrapper.ComposeStatus)
 	})
+	r.Group(func(r chi.Router) {
+		r.Get("/compose/{id}/sbom", wrapper.ComposeSbom)
+	})
 	r.Group(func(r chi.Router) {
 		r.Get("/openapi.json", wrapper.GetOpenapiJson)
 	})
@@ -777,33 +299,48 @@ func HandlerFromMux(si ServerInterface, r chi.Router) http.Handler {
 // Base64 encoded, gzipped, json marshaled Swagger object
 var swaggerSpec = []string{
 
-	"H4sIAAAAAAAC/8RXaW/buhL9KwTf+yhbju2kqYHiIU3dwl2Som77WvQaAS2NLTYSqZKjOLmF//sFqSWi",
-	"qaxIcT/FEclZzpw5HP6mkcxyKUCgppPfVEcJZMz+PPr//EueShZ/gl8FaDzNkUthl3Ilc1DIwf4H0dD8",
-	"+a+CFZ3Q/4TXFsPKXHiDrWk0pNuAKlhzKaypS5blKdAJhaK3AY29PRpQvMrNJ42Ki7U5oEePdDgf0a11",
-	"+KvgCmI6+VE7t0YDm8ui8SiXPyFC4/GWBDw8WBSB1mfncHXGYzero3ezo9np/PXpq5OTZ9NvRx8+vp92",
-	"JgiRAjy7tuSa2bxlqfr2BcXr6YdZ+O7Zh1fTkzfh8uPlpxU//l7ZfTf9TgO6kipjSCc0Z1pvpIo73SVM",
-	"wdmGY2JcyqIiQ+PwB90bjsb7B88Onw/2LEAcIbN7PFvVB6YUu7K2Bct1IvFMsAzcNLKrXr3qR7VTJhfU",
-	"LoQeULb56I9UbVlE54BejtXnf7vMDwa0SagL2WPTcxoqXH04o0KjzPjfrBGN29r12N29DWjMTdzLAj1l",
-	"UAmkvcMuOHnG1nCmypCsz4amtzmfmWN1Ih6Dd2Bz4vJc3oqULtIOoHbJtjccgWm1Hhw+X/b2hvGox8b7",
-	"B73x8OBgf388HgwGg3bBi4LfXWwe08V1KHNkWHQIeZmMblbvBK0y5Hlr27F+PTK4jnMWnbM17IpOLjWu",
-	"FegHCk6x1JHiec2c27KYt/dutx3Vc8jhi4aKEo4QYaF2tO3y8ODsYHwzS8vP7RMs413bFeRSc5SqLtJ9",
-	"KP2pPnTVhVBhJfHhjeJI6Z2d4mDjpL2TlB/Qogb+JqZecxREkRlvurDCZTqD8bR0mYOIDYpGyHha/Sx9",
-	"lb/N3a8RLNSLoFWLa2tePapY79clJWI3tEmrQVr18nJdMg2FSl2yJIi5noRhFIu+gjhh2I9kFkZSIAgM",
-	"jUqFRigPw8OwpGJo7EgdSh068qHSriwzQJZycd7tNeNKSaX7K4ilYrmSplv6Uq3D+tz/TIVflOu90fCv",
-	"YjAYHhhGvGga484QrJOUa3xwEM1JN4zRY8JQic5aurOUMgUm/DHSbOuS//mOHO1OHcgvrCz2vOvfjEf2",
-	"Uu6Vt/G9RjlT5V4nXXy23CN7LjRfJzvjIKoCAg+QgEq1ZqJSeefAcDAejIbj5gwXCGtQ5QikLkD5EbdV",
-	"vG/AbQV+53XnBBLsguw4bSHWyrarkK76eZWU188jKeB0RSc/HvVEodtFo6z3EZfPVzn42lLpbB3Uzfk8",
-	"lcKqQohKRm+4oR+fTBVLZWjRxF7uboXINrozgK+gdGf7XVwv3M6oeuNiu7VdsZLmTAyt1qZzUBc8AoKS",
-	"2PuGMBETLjSyNCX2+tN9GtCURyC0BaR8EtGjnEUJkGHfDHa2ExqR22w2fWaXrbJVZ3X4fnY8PZlPe8P+",
-	"oJ9gllqYOdrWOZ2/tO6raU+RKJVFTFhu5osmY7pnWzYHYRYmdNQf9M1jO2eYWGzKKpWBmknMT/hYAUMg",
-	"jAjYkGp3QHJpriDO0vSKRFJorpGLNZErouECFKuxsPCUtykBFiUGN0yAKxKDOVIOi33LYlD2v1lsvFZh",
-	"lQUCjS9lbJWzuvysrOZ5yiN7JvypywKXTLvzJeK+a7YuEYzy2Q86l6YOxtpwsPf03u1bwTrfgbzcQBKm",
-	"iUamEGLLVV1kGTPTQ12Uunhmsa5k+JvHWxPCGjqq+QbQ4E/KbjP1YqTqaiKVNZgCQlyb7pPPCdeEiygt",
-	"YtBkkwAmoMxeIZFwJFYxIIY4sLVmqZbEDAjE9I+5d7gUhC1lUTpWNusbCz6vVSBnimWAoLTVWDeL2SsT",
-	"eRVinQtKsrYvcC7s9YkJDerms68nt8JBq1pP/jBbePQZPDV9mnnTo4+LixGAsece4RLDPGV8x/FuIp7x",
-	"mbhgKW/4QXhcOhg/lYMv4lzIjXAcONz/vENfpwkqqevXkFZN4HLtDeBpue+ttrNDV63cqBRgoYQmaLoh",
-	"llGRmTzdwNZVb1UxEBMD0TlEfFVV2jCFrQ2j7extLpqAhq37qbNna7u6unrq/YGf1tdm6Y/Rr3bRUTrm",
-	"hdgNkL9ru/0nAAD//+8m7NSkFgAA",
+	"H4sIAAAAAAAC/9xaa2/bONb+K4Te96Ms2/I1AQYDT5p23TZNUKfTDhojoKhjixOJVEkqrlvkvy9ISrJk",
+	"yXHSdhbY7ZdK4uXcnvOcQzrfHcKTlDNgSjqn3x1JIkiweZx9XMwTvIYznm71eyp4CkJRMKM4obc01E/w",
+	"FSdpDM6p/tbphX0cjMgUT/rBAPzVieM6apvqYakEZWvnwXUErCln9cWZ7ACWqtNvLjArvmRUQOicfi5E",
+	"l9ssywU8+BuI0hJmHxcf0pjj8D18yUCqy1RRzozmIUgiqHl3Tp3rCBDVZiIqUWaWQIgUR4sBwixEWoZU",
+	"ICBEWCLM0Oxi7qGPVEU8UwiIf8O43dtFqrrXHaQKUVbsg1HIN0xvjz68f6snCFCZYBDeMMqkAhx6N8xx",
+	"99wMxNf//b+AlXPq/F93F61uHqruAVPPiX/I1ZB1NtDuateRgx8UuBg0IpULN5s+I0rn1ug9wBECUt7e",
+	"wbaBu9mb+Wx+uXh5+eLdu8n5p9nF1dvzNtMIT7e3it9atVrA8N4OmEDOLuY6SoSn1AICrxQIRJWNXYEK",
+	"x90p8rkC4qXrUAWJEdJQJP+AhcBb/S6BCFC3OxPr9m1e41h8+qDYy/OLeffN5OLF+btX3eDq6/sVPfsr",
+	"N/jN+V+O66y4SLByTp0US7nhImwNcYQF3G6oirRInuW5X7Gj7w+Go/FketLr+880heFURlzdMpxA3Yxk",
+	"2ylGj+d4LdptHnoGnhaDfwROQUbuQDVszD+3+f0/GeZnO7Q06FHPLhRWmXxGPSDTQW9yMphMRqOTUTgM",
+	"DiQmhZZ8vLZ5WM1CriIQqMjgCjKPMNaulLWAtqDm20zETS2uBEi6ZmCZm6+qPK/53UWbiJJI8wJn8RZJ",
+	"UGgTAdvNu2EbLBHj6kA5mSsEX1MqQOYsg1GIt7Ye7NwZKZXK0263xJgnB17J5R5O8DfO8EZ6hCfWCxJE",
+	"B6e0E46CAR4G/u+fOrPkW2dB1wyrTMBvnuc9tT4/UjQeWgBzZuXnidjEC8mk4gn9hsu6/FgAz+qzdcio",
+	"lh5kqqGoiCDuTNvMMrG4FVYlI/NJ6DHQKQxpoGcvz2p6NUQuH/OUzOIWR+0nVd8fgObmDkxPgk7fDwcd",
+	"PByNO0N/PB6NhsNer9erMkSW0ePsQENnuVPlUI5bY2Q5etRp+UYNadV9jNwGGOqCU0zu8Br2q1TKpVoL",
+	"kM+sUFlQye7HrVhU57bi3PJKBOROZkkLhZVDBXFgoegKE1V+CDIah7pHdBG1XzZc3IFAmnTkDSsXmAaT",
+	"ChSCwiSCEM0WZ/N5B4uEaz55dfUKySKzZUsvKSPsj8b6CYch1frh+Ko2o+G7ui2Lf8380RiR0qRgi1Y0",
+	"BlPqK0z1XWfBnfeF8I2vEdsbDkMgE783hmk4ISerIBhimK4m4/F4PB32xqE/Gk9WZLIifdwf9/BJOJz0",
+	"eiuymq6mcOK0+d0ac8DnnClgSjvYqrz4cLFwNVlr79rM0IPlHlXlf1pdhHbWmyg0+4AySj8TjHITHQZt",
+	"mA5DAaq8YOnoaMNJnIWUrSvucBo+3UvSHC01Ty8PJcBBmseCRFQB0YrWeezrdHw7Hh6maft5r51oL1Yp",
+	"l1RxUbDUUzj9fbGotR+w58DnV4pa83m0VNR8UzN7z6imQsvC8YeomlQZ6ShP7/hLo7PcEliWGChkpkvU",
+	"VQXT2GqbAtOIMl0jjfNHq6Z9Ljod/bas5tdut0YoczOfVmFq/WgDvbviUgl1w00BlpD3fM0+i4TMExBG",
+	"WOUdlWGVrq7wXd1kTLvTrkVxV+/DZZfLbq30irjNygQUjim7a5eaUCG4kN4KQi5wKrhONI+LdbdY97sG",
+	"x292vDPwb7Jezx9rMP1W5tRRFYyQmEr1bCXKlXU1Bj+ihohkUqG6gPMYMGsE00xr457FXinfP+Ipem9a",
+	"ik7jrKXPouYE1LFHnyedm3WUO61waaLlCdZTJuk62jt7K5GB23CI63CxxizvkGoL/N6wN/CH5RrKFKxB",
+	"2POmuAfR1LjaAXnauRXFj7aKNUXcfSfXhFY8VrG2LZA2l68E1+2cbDuC2RFd3jASGWO6mlm6MIU92CpD",
+	"lHUEKK5w3fZ+r/hXiQ9lqlqJKh5UAjO5AmFsr2wzHDx9lz3/Vbd0cw0Pu+RgYeW7C03O4HLlnH7+oVs7",
+	"52FZ1qmn8O31NoUm3eZVq1DqsD2H6hUIwUUz7h8j29rYSCNdfupXbg7BTJ+r8wnlifsU2ZsNFAKj0JrN",
+	"P+7BouZoz6UVzB73XonwH6izOeqdZYstPxu/XJd8o2UZLju7oiLeyFYF/gQhW0n4fjfwOK8UE5cP9qYs",
+	"E1RtF1r3vFQDFiBmmYp2by+L3Hv98dpx7Y8XhjfN6C7omqKdhwfDuSvehNkCxD0lgBTfncYQZVLhOLZ4",
+	"kvqeJKYEmDSOtrebzizVJzHke/rIbXi2LKGbzcbDZtjUzXyt7L6dn52/W5x3fK/nRSqJTfioMmC+XPxh",
+	"xOfncIFIzLMQ4ZQ67s6TTt/CF5geOHUGXs/TtzIpVpFxVXH5o59TLlXT4DMBWAHCiMEG5bNdlHIFTFEc",
+	"x1tEOJNUKk2z+qQE9yBw4QvjnjzjAJNI+604muol9hjvGUIAYd7moZaaq2UDD1L9wUNTl/PWSj/iNI0p",
+	"MWu6f0sLHIvgo3dE9RunhzrAdF01H2TKdRz0bn6v/+ulm1scI3z/UGomoAhLJBUWCkKTAzJLEiy2u6AU",
+	"wdODRSS732n4oFVYQ0s0X4Gyhz6TxfUayYXZMAYFYbG1h64jKvNjIUh9WWluVbkwd5RUIcNEEELo2h+v",
+	"YsmRbj+Rzh+dc5QzhAOeWcHCWH0w4IuCXVIscAIKhDRkW7di/kJrnqtY2KI4WpvLdMpMc6Yixy2SL/8N",
+	"sBphtxKtX35ltmzAp/er4VOeZhrwqftFE8CwIV7BV9VNY0z3BO8b0th8zu5xTEt8IBpaAcNfJeADu2N8",
+	"w2oCati/3oPvwSToyoAnRzNh8cflRXEVUlwe1jYub4NeLy7f7a6Ebtji6sUn5Hu+V5CwRMTkZYgCWHEB",
+	"OdOVu25AgP2xN8L3gBhnYC/u21Mh4MnTEkErR0rC/B+A//51U/NGS7s+5CRL8su7spv774d7wcP6bZP/",
+	"8YAGaVsa8JXaYAEooHGs3ZBgBYLiuC018i7AK9ydZ0Ude69AXdp5r6U5tLXFsW6B/eME/Us4lWVM9pRd",
+	"58mW64C0DkimQOgqR4HjOgqvNcbNpYft7bqVlrA1iYt9Zd6VFfPdpll/lkP/GDMXIlrCjBsqtjuoOavS",
+	"4hoCqDa3n5cP7veH5cO/BwA6neyqmyMAAA==",
 }
 
 // GetSwagger returns the Swagger specification corresponding to the generated code
@@ -1,11 +1,13 @@
-//go:generate go run github.com/deepmap/oapi-codegen/cmd/oapi-codegen --package=cloudapi --generate types,spec,chi-server,client -o openapi.gen.go openapi.yml
+//go:generate go run github.com/deepmap/oapi-codegen/cmd/oapi-codegen --package=cloudapi --generate types,spec,chi-server -o openapi.gen.go openapi.yml
 
 package cloudapi
 
 import (
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"math"
 	"math/big"
 	"net/http"
@@ -13,9 +15,11 @@ import (
 	"github.com/go-chi/chi"
 	"github.com/google/uuid"
 
+	"github.com/osbuild/osbuild-composer/internal/auth"
 	"github.com/osbuild/osbuild-composer/internal/blueprint"
 	"github.com/osbuild/osbuild-composer/internal/distro"
 	"github.com/osbuild/osbuild-composer/internal/rpmmd"
+	"github.com/osbuild/osbuild-composer/internal/sbom"
 	"github.com/osbuild/osbuild-composer/internal/target"
 	"github.com/osbuild/osbuild-composer/internal/worker"
 )
@@ -92,8 +96,10 @@ func (server *Server) Compose(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type imageRequest struct {
-		manifest distro.Manifest
-		arch     string
+		manifest  distro.Manifest
+		arch      string
+		packages  []rpmmd.PackageSpec
+		imageType string
 	}
 	imageRequests := make([]imageRequest, len(request.ImageRequests))
 	var targets []*target.Target
@@ -105,6 +111,11 @@ func (server *Server) Compose(w http.ResponseWriter, r *http.Request) {
 	}
 	manifestSeed := bigSeed.Int64()
 
+	// the packages of all images are resolved together, so that images
+	// with different architectures or repositories are resolved
+	// concurrently
+	imageTypes := make([]distro.ImageType, len(request.ImageRequests))
+	depsolveRequests := make([]rpmmd.DepsolveRequest, len(request.ImageRequests))
 	for i, ir := range request.ImageRequests {
 		arch, err := distribution.GetArch(ir.Architecture)
 		if err != nil {
@@ -133,17 +144,40 @@ func (server *Server) Compose(w http.ResponseWriter, r *http.Request) {
 		}
 
 		packageSpecs, excludePackageSpecs := imageType.Packages(bp)
-		packages, _, err := server.rpmMetadata.Depsolve(packageSpecs, excludePackageSpecs, repositories, distribution.ModulePlatformID(), arch.Name())
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to depsolve base packages for %s/%s/%s: %s", ir.ImageType, ir.Architecture, request.Distribution, err), http.StatusInternalServerError)
-			return
+		imageTypes[i] = imageType
+		depsolveRequests[i] = rpmmd.DepsolveRequest{
+			Sets: map[string]rpmmd.PackageSet{
+				rpmmd.OSPackageSet:    {Include: packageSpecs, Exclude: excludePackageSpecs, NoWeakDeps: !imageType.InstallWeakDeps()},
+				rpmmd.BuildPackageSet: {Include: imageType.BuildPackages()},
+			},
+			Repos:            repositories,
+			ModulePlatformID: distribution.ModulePlatformID(),
+			Arch:             arch.Name(),
 		}
-		buildPackageSpecs := imageType.BuildPackages()
-		buildPackages, _, err := server.rpmMetadata.Depsolve(buildPackageSpecs, nil, repositories, distribution.ModulePlatformID(), arch.Name())
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to depsolve build packages for %s/%s/%s: %s", ir.ImageType, ir.Architecture, request.Distribution, err), http.StatusInternalServerError)
-			return
+	}
+
+	depsolved, err := rpmmd.DepsolveAll(r.Context(), server.rpmMetadata, depsolveRequests)
+	if err != nil {
+		ir := request.ImageRequests[0]
+		var requestErr *rpmmd.DepsolveRequestError
+		if errors.As(err, &requestErr) {
+			ir = request.ImageRequests[requestErr.Index]
+		}
+		kind := "base"
+		var setErr *rpmmd.PackageSetError
+		if errors.As(err, &setErr) && setErr.Name == rpmmd.BuildPackageSet {
+			kind = "build"
 		}
+		http.Error(w, fmt.Sprintf("Failed to depsolve %s packages for %s/%s/%s: %s", kind, ir.ImageType, ir.Architecture, request.Distribution, err), http.StatusInternalServerError)
+		return
+	}
+
+	for i, ir := range request.ImageRequests {
+		imageType := imageTypes[i]
+		repositories := depsolveRequests[i].Repos
+		results := depsolved[i]
+		packages := results[rpmmd.OSPackageSet].Packages
+		buildPackages := results[rpmmd.BuildPackageSet].Packages
 
 		imageOptions := distro.ImageOptions{Size: imageType.Size(0)}
 		if request.Customizations != nil && request.Customizations.Subscription != nil {
@@ -153,9 +187,16 @@ func (server *Server) Compose(w http.ResponseWriter, r *http.Request) {
 				ServerUrl:     request.Customizations.Subscription.ServerUrl,
 				BaseUrl:       request.Customizations.Subscription.BaseUrl,
 				Insights:      request.Customizations.Subscription.Insights,
+				APIType:       "cloudapi",
 			}
 		}
 
+		repositories, err = server.rpmMetadata.ResolveGPGKeys(r.Context(), repositories)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to fetch GPG keys for %s/%s/%s: %s", ir.ImageType, ir.Architecture, request.Distribution, err), http.StatusBadRequest)
+			return
+		}
+
 		manifest, err := imageType.Manifest(nil, imageOptions, repositories, packages, buildPackages, manifestSeed)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Failed to get manifest for for %s/%s/%s: %s", ir.ImageType, ir.Architecture, request.Distribution, err), http.StatusBadRequest)
@@ -163,7 +204,9 @@ func (server *Server) Compose(w http.ResponseWriter, r *http.Request) {
 		}
 
 		imageRequests[i].manifest = manifest
-		imageRequests[i].arch = arch.Name()
+		imageRequests[i].arch = imageType.Arch().Name()
+		imageRequests[i].packages = packages
+		imageRequests[i].imageType = imageType.Name()
 
 		if len(ir.UploadRequests) != 1 {
 			http.Error(w, "Only compose requests with a single upload target are currently supported", http.StatusBadRequest)
@@ -184,9 +227,18 @@ func (server *Server) Compose(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			// Without ec2 options, the image is only uploaded to S3
+			ec2 := awsUploadOptions.Ec2
+			if ec2 == nil {
+				ec2 = &AWSUploadRequestOptionsEc2{}
+			}
 			var share []string
-			if awsUploadOptions.Ec2.ShareWithAccounts != nil {
-				share = *awsUploadOptions.Ec2.ShareWithAccounts
+			if ec2.ShareWithAccounts != nil {
+				share = *ec2.ShareWithAccounts
+			}
+			var copyTo []string
+			if ec2.CopyToRegions != nil {
+				copyTo = *ec2.CopyToRegions
 			}
 			key := fmt.Sprintf("composer-api-%s", uuid.New().String())
 			t := target.NewAWSTarget(&target.AWSTargetOptions{
@@ -197,9 +249,11 @@ func (server *Server) Compose(w http.ResponseWriter, r *http.Request) {
 				Bucket:            awsUploadOptions.S3.Bucket,
 				Key:               key,
 				ShareWithAccounts: share,
+				CopyToRegions:     copyTo,
+				UploadOnly:        awsUploadOptions.Ec2 == nil,
 			})
-			if awsUploadOptions.Ec2.SnapshotName != nil {
-				t.ImageName = *awsUploadOptions.Ec2.SnapshotName
+			if ec2.SnapshotName != nil {
+				t.ImageName = *ec2.SnapshotName
 			} else {
 				t.ImageName = key
 			}
@@ -220,14 +274,21 @@ func (server *Server) Compose(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the jobs of each tenant are queued in their own channel
 	id, err := server.workers.EnqueueOSBuild(ir.arch, &worker.OSBuildJob{
-		Manifest: ir.manifest,
-		Targets:  targets,
-	})
+		Manifest:  ir.manifest,
+		Targets:   targets,
+		Packages:  ir.packages,
+		Distro:    request.Distribution,
+		ImageType: ir.imageType,
+	}, auth.Tenant(r.Context()))
 	if err != nil {
 		http.Error(w, "Failed to enqueue manifest", http.StatusInternalServerError)
 		return
 	}
+	if identity := auth.Identity(r.Context()); identity != "" {
+		log.Printf("Compose %s was requested by %s", id, identity)
+	}
 
 	var response ComposeResult
 	response.Id = id.String()
@@ -247,6 +308,10 @@ func (server *Server) ComposeStatus(w http.ResponseWriter, r *http.Request, id s
 		return
 	}
 
+	if !server.ownsCompose(w, r, jobId) {
+		return
+	}
+
 	var result worker.OSBuildJobResult
 	status, _, err := server.workers.JobStatus(jobId, &result)
 	if err != nil {
@@ -254,13 +319,62 @@ func (server *Server) ComposeStatus(w http.ResponseWriter, r *http.Request, id s
 		return
 	}
 
+	var job worker.OSBuildJob
+	_, _, _, err = server.workers.Job(jobId, &job)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Job %s not found: %s", id, err), http.StatusNotFound)
+		return
+	}
+
+	// compose requests have a single upload target
+	progress := server.workers.JobProgress(jobId)
+	uploadStatus := &UploadStatus{
+		Status: result.UploadStatus,
+		Type:   "aws",
+	}
+	if statuses := worker.TargetStatuses(job.Targets, status, &result, progress); len(statuses) > 0 {
+		uploadStatus.Status = string(statuses[0].State)
+		if statuses[0].Error != "" {
+			uploadStatus.Error = &statuses[0].Error
+		}
+	}
+	for _, tr := range result.TargetResults {
+		if options, ok := tr.Options.(*target.AWSTargetResultOptions); ok {
+			awsUploadStatus := AWSUploadStatus{
+				Region: &options.Region,
+			}
+			if options.Ami != "" {
+				awsUploadStatus.AmiId = &options.Ami
+			}
+			if options.DownloadURL != "" {
+				awsUploadStatus.DownloadUrl = &options.DownloadURL
+			}
+			if len(options.Copies) > 0 {
+				var copies []AWSImageCopy
+				for _, c := range options.Copies {
+					copies = append(copies, AWSImageCopy{
+						AmiId:  c.Ami,
+						Region: c.Region,
+					})
+				}
+				awsUploadStatus.Copies = &copies
+			}
+			var awsStatus interface{} = awsUploadStatus
+			uploadStatus.Options = &awsStatus
+		}
+	}
+	if progress != nil {
+		uploadStatus.Progress = &UploadProgress{
+			Transferred: progress.Transferred,
+			Total:       progress.Total,
+		}
+	}
+
 	response := ComposeStatus{
 		ImageStatus: ImageStatus{
-			Status: composeStatusFromJobStatus(status, &result),
-			UploadStatus: &UploadStatus{
-				Status: result.UploadStatus,
-				Type:   "aws",
-			},
+			Status:       composeStatusFromJobStatus(status, &result),
+			UploadStatus: uploadStatus,
+			Checksums:    imageChecksums(result.Checksums),
 		},
 	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -270,6 +384,82 @@ func (server *Server) ComposeStatus(w http.ResponseWriter, r *http.Request, id s
 	}
 }
 
+// imageChecksums converts the checksums a worker reported, which older
+// workers do not report
+func imageChecksums(checksums *worker.ArtifactChecksums) *ImageChecksums {
+	if checksums == nil {
+		return nil
+	}
+	result := &ImageChecksums{
+		Sha256:     make(map[string]interface{}),
+		Sha256sums: checksums.SHA256Sums,
+	}
+	for filename, checksum := range checksums.SHA256 {
+		result.Sha256[filename] = checksum
+	}
+	if len(checksums.Signatures) > 0 {
+		signatures := make(map[string]interface{})
+		for filename, signature := range checksums.Signatures {
+			signatures[filename] = signature
+		}
+		result.Signatures = &signatures
+	}
+	return result
+}
+
+// ComposeSbom handles a /compose/{id}/sbom GET request
+func (server *Server) ComposeSbom(w http.ResponseWriter, r *http.Request, id string) {
+	jobId, err := uuid.Parse(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid format for parameter id: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if !server.ownsCompose(w, r, jobId) {
+		return
+	}
+
+	var job worker.OSBuildJob
+	_, _, _, err = server.workers.Job(jobId, &job)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Job %s not found: %s", id, err), http.StatusNotFound)
+		return
+	}
+	if len(job.Packages) == 0 {
+		http.Error(w, fmt.Sprintf("Compose %s has no SBOM, because it was created before its packages were kept", id), http.StatusNotFound)
+		return
+	}
+
+	status, _, err := server.workers.JobStatus(jobId, &worker.OSBuildJobResult{})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Job %s not found: %s", id, err), http.StatusNotFound)
+		return
+	}
+
+	doc := sbom.NewDocument(jobId, "compose-"+id, job.Distro, job.Packages, status.Queued)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	err = json.NewEncoder(w).Encode(doc)
+	if err != nil {
+		panic("Failed to write response")
+	}
+}
+
+// ownsCompose returns whether the compose with jobId belongs to the tenant of
+// the client of r. Composes of other tenants are reported as not found, so
+// that clients cannot find out whether they exist.
+func (server *Server) ownsCompose(w http.ResponseWriter, r *http.Request, jobId uuid.UUID) bool {
+	channel, err := server.workers.JobChannel(jobId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Job %s not found: %s", jobId, err), http.StatusNotFound)
+		return false
+	}
+	if channel != auth.Tenant(r.Context()) {
+		http.Error(w, fmt.Sprintf("Job %s not found", jobId), http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
 func composeStatusFromJobStatus(js *worker.JobStatus, result *worker.OSBuildJobResult) string {
 	if js.Canceled {
 		return StatusFailure
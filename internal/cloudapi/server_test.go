@@ -0,0 +1,112 @@
+package cloudapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/osbuild-composer/internal/client/cloudapi"
+	server "github.com/osbuild/osbuild-composer/internal/cloudapi"
+	distro_mock "github.com/osbuild/osbuild-composer/internal/mocks/distro"
+	rpmmd_mock "github.com/osbuild/osbuild-composer/internal/mocks/rpmmd"
+	"github.com/osbuild/osbuild-composer/internal/worker"
+)
+
+func newTestClient(t *testing.T, dir string) (*cloudapi.ClientWithResponses, func()) {
+	client, _, done := newTestClientWithWorkers(t, dir)
+	return client, done
+}
+
+func newTestClientWithWorkers(t *testing.T, dir string) (*cloudapi.ClientWithResponses, *worker.Server, func()) {
+	rpmFixture := rpmmd_mock.BaseFixture(dir)
+	rpm := rpmmd_mock.NewRPMMDMock(rpmFixture)
+
+	distros, err := distro_mock.NewDefaultRegistry()
+	require.NoError(t, err)
+
+	s := httptest.NewServer(server.NewServer(rpmFixture.Workers, rpm, distros).Handler("/api/composer/v1"))
+	client, err := cloudapi.NewClientWithResponses(s.URL + "/api/composer/v1")
+	require.NoError(t, err)
+	return client, rpmFixture.Workers, s.Close
+}
+
+func TestVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "osbuild-composer-test-cloudapi-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	client, done := newTestClient(t, dir)
+	defer done()
+
+	resp, err := client.GetVersionWithResponse(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+	require.Equal(t, &cloudapi.Version{Version: "1"}, resp.JSON200)
+}
+
+func TestOpenapiJson(t *testing.T) {
+	dir, err := ioutil.TempDir("", "osbuild-composer-test-cloudapi-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	client, done := newTestClient(t, dir)
+	defer done()
+
+	resp, err := client.GetOpenapiJsonWithResponse(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+
+	var spec struct {
+		Paths map[string]interface{} `json:"paths"`
+	}
+	err = json.Unmarshal(resp.Body, &spec)
+	require.NoError(t, err)
+	require.Contains(t, spec.Paths, "/compose")
+	require.Contains(t, spec.Paths, "/openapi.json")
+}
+
+func TestComposeStatusUnknown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "osbuild-composer-test-cloudapi-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	client, done := newTestClient(t, dir)
+	defer done()
+
+	resp, err := client.ComposeStatusWithResponse(context.Background(), "f1e1e6a9-5c1a-4e9e-8a36-d1e4b4bfa5f7")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode())
+}
+
+func TestComposeStatusOfOtherTenants(t *testing.T) {
+	dir, err := ioutil.TempDir("", "osbuild-composer-test-cloudapi-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	client, workers, done := newTestClientWithWorkers(t, dir)
+	defer done()
+
+	// clients without a tenant only see the composes of the default channel
+	own, err := workers.EnqueueOSBuild("x86_64", &worker.OSBuildJob{}, "")
+	require.NoError(t, err)
+	resp, err := client.ComposeStatusWithResponse(context.Background(), own.String())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+	require.Equal(t, "pending", resp.JSON200.ImageStatus.Status)
+
+	other, err := workers.EnqueueOSBuild("x86_64", &worker.OSBuildJob{}, "acme")
+	require.NoError(t, err)
+	resp, err = client.ComposeStatusWithResponse(context.Background(), other.String())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode())
+
+	sbom, err := client.ComposeSbomWithResponse(context.Background(), other.String())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, sbom.StatusCode())
+}
@@ -0,0 +1,76 @@
+// Package copr expands the copr:owner/project shorthand for repositories
+// hosted on Fedora COPR into their URLs.
+package copr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Prefix marks a COPR repository in places which otherwise take URLs
+const Prefix = "copr:"
+
+const (
+	hostname   = "copr.fedorainfracloud.org"
+	resultsURL = "https://download.copr.fedorainfracloud.org/results"
+)
+
+// Owners are users or groups, the latter prefixed with @
+var specRegex = regexp.MustCompile(`^copr:(@?[\w.-]+)/([\w.+-]+)$`)
+
+// A Repo is a COPR project of a user or a group
+type Repo struct {
+	Owner   string
+	Project string
+}
+
+// IsSpec returns whether s uses the copr: shorthand, even if it is invalid
+func IsSpec(s string) bool {
+	return strings.HasPrefix(s, Prefix)
+}
+
+// Parse parses a copr:owner/project specification
+func Parse(spec string) (Repo, error) {
+	match := specRegex.FindStringSubmatch(spec)
+	if match == nil {
+		return Repo{}, fmt.Errorf("invalid COPR repository %q, expected copr:owner/project", spec)
+	}
+	return Repo{match[1], match[2]}, nil
+}
+
+// Chroot returns the COPR chroot prefix of a distribution, e.g. fedora-33 for
+// fedora-33 and epel-8 for rhel-84 and centos-8. COPR builds for RHEL and
+// CentOS in the EPEL chroots of their major version.
+func Chroot(distro string) (string, error) {
+	i := strings.LastIndex(distro, "-")
+	if i < 0 || i == len(distro)-1 {
+		return "", fmt.Errorf("COPR has no chroot for distribution %q", distro)
+	}
+	name, version := distro[:i], distro[i+1:]
+	switch name {
+	case "fedora":
+		return distro, nil
+	case "rhel", "centos":
+		// rhel-84 is RHEL 8.4
+		return "epel-" + version[:1], nil
+	}
+	return "", fmt.Errorf("COPR has no chroot for distribution %q", distro)
+}
+
+// ID returns the repository id dnf copr uses for the repository
+func (r Repo) ID() string {
+	return strings.Join([]string{"copr", hostname, strings.Replace(r.Owner, "@", "group_", 1), r.Project}, ":")
+}
+
+// BaseURL returns the URL of the repository for a chroot and architecture.
+// The architecture may be a dnf variable such as $basearch.
+func (r Repo) BaseURL(chroot, arch string) string {
+	return fmt.Sprintf("%s/%s/%s/%s-%s/", resultsURL, r.Owner, r.Project, chroot, arch)
+}
+
+// GPGKey returns the URL of the key the packages of the repository are
+// signed with
+func (r Repo) GPGKey() string {
+	return fmt.Sprintf("%s/%s/%s/pubkey.gpg", resultsURL, r.Owner, r.Project)
+}
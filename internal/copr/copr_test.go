@@ -0,0 +1,51 @@
+package copr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	repo, err := Parse("copr:user/project")
+	require.NoError(t, err)
+	assert.Equal(t, Repo{"user", "project"}, repo)
+
+	repo, err = Parse("copr:@group/my.project")
+	require.NoError(t, err)
+	assert.Equal(t, Repo{"@group", "my.project"}, repo)
+
+	for _, spec := range []string{"copr:user", "copr:user/", "copr:/project", "copr:user/project/x", "user/project"} {
+		_, err := Parse(spec)
+		assert.Errorf(t, err, "%s", spec)
+	}
+}
+
+func TestChroot(t *testing.T) {
+	for distro, chroot := range map[string]string{
+		"fedora-33": "fedora-33",
+		"rhel-8":    "epel-8",
+		"rhel-84":   "epel-8",
+		"centos-8":  "epel-8",
+	} {
+		actual, err := Chroot(distro)
+		require.NoError(t, err)
+		assert.Equal(t, chroot, actual)
+	}
+
+	_, err := Chroot("test-distro")
+	assert.EqualError(t, err, `COPR has no chroot for distribution "test-distro"`)
+	_, err = Chroot("fedora")
+	assert.Error(t, err)
+	_, err = Chroot("rhel-")
+	assert.Error(t, err)
+}
+
+func TestRepo(t *testing.T) {
+	repo := Repo{"@group", "project"}
+	assert.Equal(t, "copr:copr.fedorainfracloud.org:group_group:project", repo.ID())
+	assert.Equal(t, "https://download.copr.fedorainfracloud.org/results/@group/project/epel-8-x86_64/", repo.BaseURL("epel-8", "x86_64"))
+	assert.Equal(t, "https://download.copr.fedorainfracloud.org/results/@group/project/fedora-33-$basearch/", repo.BaseURL("fedora-33", "$basearch"))
+	assert.Equal(t, "https://download.copr.fedorainfracloud.org/results/@group/project/pubkey.gpg", repo.GPGKey())
+}
@@ -5,11 +5,38 @@
 package disk
 
 import (
+	"fmt"
 	"sort"
 
 	"github.com/osbuild/osbuild-composer/internal/osbuild"
 )
 
+// Well-known partition types, by partition table type and the name used in
+// blueprints
+var partitionTypes = map[string]map[string]string{
+	"gpt": {
+		"bios-boot": "21686148-6449-6E6F-744E-656564454649",
+		"esp":       "C12A7328-F81F-11D2-BA4B-00A0C93EC93B",
+		"prep":      "9E1A2D38-C612-4316-AA26-8B49521E5A8B",
+		"linux":     "0FC63DAF-8483-4772-8E79-3D69D8477DE4",
+	},
+	"dos": {
+		"esp":   "ef",
+		"prep":  "41",
+		"linux": "83",
+	},
+}
+
+// PartitionTypeID returns the type identifier of the partition type name in
+// a partition table of type ptType, e.g. the GUID for gpt.
+func PartitionTypeID(name, ptType string) (string, error) {
+	id, ok := partitionTypes[ptType][name]
+	if !ok {
+		return "", fmt.Errorf("partition type %q is not supported in %s partition tables", name, ptType)
+	}
+	return id, nil
+}
+
 type PartitionTable struct {
 	// Size of the disk.
 	Size uint64
@@ -99,6 +126,18 @@ func (pt PartitionTable) RootPartition() *Partition {
 	return nil
 }
 
+// Returns the partition whose filesystem is mounted at /boot, or nil if /boot
+// is part of the root filesystem.
+func (pt PartitionTable) BootPartition() *Partition {
+	for _, p := range pt.Partitions {
+		if p.Filesystem != nil && p.Filesystem.Mountpoint == "/boot" {
+			return &p
+		}
+	}
+
+	return nil
+}
+
 // Converts Partition to osbuild.QEMUPartition that encodes the same partition.
 func (p Partition) QEMUPartition() osbuild.QEMUPartition {
 	var fs *osbuild.QEMUFilesystem
@@ -0,0 +1,73 @@
+package distro
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/osbuild/osbuild-composer/internal/rpmmd"
+)
+
+// BuildInfoPath is where the build information of an image is written to
+const BuildInfoPath = "/etc/osbuild-composer/build-info.json"
+
+// BuildInfo is the provenance of an image. It is written into the image, so
+// that running instances can be traced back to the compose which built them.
+type BuildInfo struct {
+	ComposeID        uuid.UUID `json:"compose_id"`
+	BlueprintName    string    `json:"blueprint_name"`
+	BlueprintVersion string    `json:"blueprint_version"`
+	Distro           string    `json:"distro"`
+	Arch             string    `json:"arch"`
+	ImageType        string    `json:"image_type"`
+	Created          time.Time `json:"created"`
+	// PackagesSHA256 is the checksum of the lockfile of the packages in
+	// the image, see PackagesChecksum
+	PackagesSHA256 string `json:"packages_sha256"`
+}
+
+// NewBuildInfo returns the build information of an image of imageType,
+// which is built by compose composeID from a blueprint and contains
+// packages
+func NewBuildInfo(composeID uuid.UUID, blueprintName, blueprintVersion string, imageType ImageType, packages []rpmmd.PackageSpec, created time.Time) *BuildInfo {
+	return &BuildInfo{
+		ComposeID:        composeID,
+		BlueprintName:    blueprintName,
+		BlueprintVersion: blueprintVersion,
+		Distro:           imageType.Arch().Distro().Name(),
+		Arch:             imageType.Arch().Name(),
+		ImageType:        imageType.Name(),
+		Created:          created.UTC().Truncate(time.Second),
+		PackagesSHA256:   PackagesChecksum(packages),
+	}
+}
+
+// PackagesChecksum returns the SHA256 of the lockfile of packages, which
+// has a line with the NEVRA and the checksum of each package, sorted by
+// NEVRA. It does not depend on the order of the packages.
+func PackagesChecksum(packages []rpmmd.PackageSpec) string {
+	lines := make([]string, 0, len(packages))
+	for _, pkg := range packages {
+		lines = append(lines, pkg.GetNEVRA()+" "+pkg.Checksum+"\n")
+	}
+	sort.Strings(lines)
+
+	hash := sha256.New()
+	for _, line := range lines {
+		_, _ = hash.Write([]byte(line))
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// Content returns the build information as it is written to the image
+func (b *BuildInfo) Content() ([]byte, error) {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,54 @@
+package distro_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/osbuild-composer/internal/distro"
+	"github.com/osbuild/osbuild-composer/internal/distro/fedora33"
+	"github.com/osbuild/osbuild-composer/internal/rpmmd"
+)
+
+func TestPackagesChecksum(t *testing.T) {
+	bash := rpmmd.PackageSpec{Name: "bash", Version: "5.0.17", Release: "2.fc33", Arch: "x86_64", Checksum: "sha256:aaaa"}
+	kernel := rpmmd.PackageSpec{Name: "kernel", Epoch: 1, Version: "5.8.15", Release: "301.fc33", Arch: "x86_64", Checksum: "sha256:bbbb"}
+
+	checksum := distro.PackagesChecksum([]rpmmd.PackageSpec{bash, kernel})
+	require.Len(t, checksum, 64)
+	require.Equal(t, checksum, distro.PackagesChecksum([]rpmmd.PackageSpec{kernel, bash}))
+
+	kernel.Checksum = "sha256:cccc"
+	require.NotEqual(t, checksum, distro.PackagesChecksum([]rpmmd.PackageSpec{bash, kernel}))
+}
+
+func TestBuildInfo(t *testing.T) {
+	arch, err := fedora33.New().GetArch("x86_64")
+	require.NoError(t, err)
+	imageType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	id := uuid.MustParse("e7fdd5f4-49fb-4d3b-9a2c-4f33e1d6bf7b")
+	created := time.Date(2021, 2, 3, 4, 5, 6, 7, time.FixedZone("CET", 3600))
+	info := distro.NewBuildInfo(id, "base", "0.0.1", imageType, nil, created)
+
+	content, err := info.Content()
+	require.NoError(t, err)
+	require.Equal(t, byte('\n'), content[len(content)-1])
+
+	var written map[string]interface{}
+	require.NoError(t, json.Unmarshal(content, &written))
+	require.Equal(t, map[string]interface{}{
+		"compose_id":        id.String(),
+		"blueprint_name":    "base",
+		"blueprint_version": "0.0.1",
+		"distro":            "fedora-33",
+		"arch":              "x86_64",
+		"image_type":        "qcow2",
+		"created":           "2021-02-03T03:05:06Z",
+		"packages_sha256":   distro.PackagesChecksum(nil),
+	}, written)
+}
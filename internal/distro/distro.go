@@ -76,6 +76,11 @@ type ImageType interface {
 	// Returns the build packages for the output type.
 	BuildPackages() []string
 
+	// Returns whether the weak dependencies (Recommends and Supplements) of
+	// the packages are installed into images of this type. Minimal image
+	// types don't install them.
+	InstallWeakDeps() bool
+
 	// Returns an osbuild manifest, containing the sources and pipeline necessary
 	// to build an image, given output format with all packages and customizations
 	// specified in the given blueprint.
@@ -84,9 +89,13 @@ type ImageType interface {
 
 // The ImageOptions specify options for a specific image build
 type ImageOptions struct {
-	OSTree       OSTreeImageOptions
-	Size         uint64
-	Subscription *SubscriptionImageOptions
+	OSTree         OSTreeImageOptions
+	Size           uint64
+	Subscription   *SubscriptionImageOptions
+	Containers     []blueprint.Container
+	EnabledModules []blueprint.ModuleStream
+	// BuildInfo is written to BuildInfoPath in the image, unless it is nil
+	BuildInfo *BuildInfo
 }
 
 // The OSTreeImageOptions specify ostree-specific image options
@@ -98,12 +107,14 @@ type OSTreeImageOptions struct {
 // The SubscriptionImageOptions specify subscription-specific image options
 // ServerUrl denotes the host to register the system with
 // BaseUrl specifies the repository URL for DNF
+// APIType is reported as an RHSM fact of the image
 type SubscriptionImageOptions struct {
 	Organization  int
 	ActivationKey string
 	ServerUrl     string
 	BaseUrl       string
 	Insights      bool
+	APIType       string
 }
 
 // A Manifest is an opaque JSON object, which is a valid input to osbuild
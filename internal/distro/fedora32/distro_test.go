@@ -1,13 +1,17 @@
 package fedora32_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/osbuild/osbuild-composer/internal/blueprint"
 	"github.com/osbuild/osbuild-composer/internal/distro"
 	"github.com/osbuild/osbuild-composer/internal/distro/distro_test_common"
 	"github.com/osbuild/osbuild-composer/internal/distro/fedora32"
+	"github.com/osbuild/osbuild-composer/internal/osbuild"
+	"github.com/osbuild/osbuild-composer/internal/rpmmd"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFilenameFromType(t *testing.T) {
@@ -365,3 +369,31 @@ func TestFedora32_ModulePlatformID(t *testing.T) {
 	distro := fedora32.New()
 	assert.Equal(t, "platform:f32", distro.ModulePlatformID())
 }
+
+func TestDistro_ManifestSources(t *testing.T) {
+	arch, err := fedora32.New().GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	packages := []rpmmd.PackageSpec{
+		{Name: "bash", RemoteLocation: "https://example.com/bash.rpm", Checksum: "sha256:01"},
+		{Name: "entitled", RemoteLocation: "https://cdn.example.com/entitled.rpm", Checksum: "sha256:02", Secrets: "org.osbuild.rhsm", Proxy: "http://proxy.example.com:3128"},
+		{Name: "lab", RemoteLocation: "https://mirror.lab/lab.rpm", Checksum: "sha256:03", IgnoreSSL: true},
+		{Name: "internal", RemoteLocation: "https://mirror.internal/internal.rpm", Checksum: "sha256:04", SSLCACert: "/etc/pki/tls/certs/internal.pem"},
+		{Name: "mirrored", RemoteLocation: "https://one.example.com/mirrored.rpm", Checksum: "sha256:05", Mirrors: []string{"https://two.example.com/mirrored.rpm"}},
+	}
+	manifest, err := imgType.Manifest(nil, distro.ImageOptions{Size: imgType.Size(0)}, nil, packages, nil, 0)
+	require.NoError(t, err)
+	var m osbuild.Manifest
+	require.NoError(t, json.Unmarshal(manifest, &m))
+
+	files := m.Sources["org.osbuild.files"].(*osbuild.FilesSource)
+	assert.Equal(t, map[string]osbuild.FileSource{
+		"sha256:01": {URL: "https://example.com/bash.rpm"},
+		"sha256:02": {URL: "https://cdn.example.com/entitled.rpm", Secrets: &osbuild.Secret{Name: "org.osbuild.rhsm"}, Proxy: "http://proxy.example.com:3128"},
+		"sha256:03": {URL: "https://mirror.lab/lab.rpm", Insecure: true},
+		"sha256:04": {URL: "https://mirror.internal/internal.rpm", CACert: "/etc/pki/tls/certs/internal.pem"},
+		"sha256:05": {URL: "https://one.example.com/mirrored.rpm", Mirrors: []string{"https://two.example.com/mirrored.rpm"}},
+	}, files.URLs)
+}
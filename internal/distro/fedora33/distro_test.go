@@ -371,3 +371,20 @@ func TestFedora33_ModulePlatformID(t *testing.T) {
 	distro := fedora33.New()
 	assert.Equal(t, "platform:f33", distro.ModulePlatformID())
 }
+
+func TestImageType_InstallWeakDeps(t *testing.T) {
+	d := fedora33.New()
+	for _, archLabel := range d.ListArches() {
+		arch, err := d.GetArch(archLabel)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		for _, itLabel := range arch.ListImageTypes() {
+			it, err := arch.GetImageType(itLabel)
+			if !assert.NoError(t, err) {
+				continue
+			}
+			assert.Equalf(t, itLabel != "fedora-iot-commit", it.InstallWeakDeps(), "%s/%s", archLabel, itLabel)
+		}
+	}
+}
@@ -91,6 +91,10 @@ func (t *imageType) BuildPackages() []string {
 	return nil
 }
 
+func (t *imageType) InstallWeakDeps() bool {
+	return true
+}
+
 func (t *imageType) Manifest(c *blueprint.Customizations,
 	options distro.ImageOptions,
 	repos []rpmmd.RepoConfig,
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
 	"sort"
+	"strings"
 
 	"github.com/osbuild/osbuild-composer/internal/distro"
 	"github.com/osbuild/osbuild-composer/internal/osbuild"
@@ -18,6 +20,7 @@ import (
 
 const name = "rhel-8"
 const modulePlatformID = "platform:el8"
+const oscapDatastream = "/usr/share/xml/scap/ssg/content/ssg-rhel8-ds.xml"
 
 type distribution struct {
 	arches        map[string]architecture
@@ -48,6 +51,7 @@ type imageType struct {
 	kernelOptions    string
 	bootable         bool
 	rpmOstree        bool
+	noWeakDeps       bool
 	defaultSize      uint64
 	assembler        func(uefi bool, options distro.ImageOptions, arch distro.Arch) *osbuild.Assembler
 }
@@ -130,6 +134,7 @@ func (a *architecture) setImageTypes(imageTypes ...imageType) {
 			kernelOptions:    it.kernelOptions,
 			bootable:         it.bootable,
 			rpmOstree:        it.rpmOstree,
+			noWeakDeps:       it.noWeakDeps,
 			defaultSize:      it.defaultSize,
 			assembler:        it.assembler,
 		}
@@ -162,15 +167,21 @@ func (t *imageType) Size(size uint64) uint64 {
 
 func (t *imageType) Packages(bp blueprint.Blueprint) ([]string, []string) {
 	packages := append(t.packages, bp.GetPackages()...)
-	timezone, _ := bp.Customizations.GetTimezoneSettings()
-	if timezone != nil {
+	timezone, ntpServers := bp.Customizations.GetTimezoneSettings()
+	if timezone != nil || len(ntpServers) > 0 {
 		packages = append(packages, "chrony")
 	}
+	if bp.Customizations.GetOpenSCAP() != nil {
+		packages = append(packages, "openscap-scanner", "scap-security-guide")
+	}
+	if bp.Customizations.GetIgnition() != nil {
+		packages = append(packages, "ignition")
+	}
 	if t.bootable {
 		packages = append(packages, t.arch.bootloaderPackages...)
 	}
 
-	return packages, t.excludedPackages
+	return packages, append(bp.GetExcludedPackages(), t.excludedPackages...)
 }
 
 func (t *imageType) BuildPackages() []string {
@@ -181,20 +192,45 @@ func (t *imageType) BuildPackages() []string {
 	return packages
 }
 
+func (t *imageType) InstallWeakDeps() bool {
+	return !t.noWeakDeps
+}
+
 func (t *imageType) Manifest(c *blueprint.Customizations,
 	options distro.ImageOptions,
 	repos []rpmmd.RepoConfig,
 	packageSpecs,
 	buildPackageSpecs []rpmmd.PackageSpec,
 	seed int64) (distro.Manifest, error) {
+	c, err := c.WithCoprRepositories(name)
+	if err != nil {
+		return distro.Manifest{}, err
+	}
+
 	pipeline, err := t.pipeline(c, options, repos, packageSpecs, buildPackageSpecs)
 	if err != nil {
 		return distro.Manifest{}, err
 	}
 
+	sources := sources(append(packageSpecs, buildPackageSpecs...))
+	if files := c.GetFiles(); len(files) > 0 || options.BuildInfo != nil {
+		inline, err := inlineSource(files, options.BuildInfo)
+		if err != nil {
+			return distro.Manifest{}, err
+		}
+		(*sources)["org.osbuild.inline"] = inline
+	}
+	if len(options.Containers) > 0 {
+		skopeo, err := skopeoSource(options.Containers)
+		if err != nil {
+			return distro.Manifest{}, err
+		}
+		(*sources)["org.osbuild.skopeo"] = skopeo
+	}
+
 	return json.Marshal(
 		osbuild.Manifest{
-			Sources:  *sources(append(packageSpecs, buildPackageSpecs...)),
+			Sources:  *sources,
 			Pipeline: *pipeline,
 		},
 	)
@@ -214,11 +250,15 @@ func sources(packages []rpmmd.PackageSpec) *osbuild.Sources {
 	}
 	for _, pkg := range packages {
 		fileSource := osbuild.FileSource{
-			URL: pkg.RemoteLocation,
+			URL:      pkg.RemoteLocation,
+			Proxy:    pkg.Proxy,
+			Insecure: pkg.IgnoreSSL,
+			CACert:   pkg.SSLCACert,
+			Mirrors:  pkg.Mirrors,
 		}
-		if pkg.Secrets == "org.osbuild.rhsm" {
+		if pkg.Secrets != "" {
 			fileSource.Secrets = &osbuild.Secret{
-				Name: "org.osbuild.rhsm",
+				Name: pkg.Secrets,
 			}
 		}
 		files.URLs[pkg.Checksum] = fileSource
@@ -228,19 +268,94 @@ func sources(packages []rpmmd.PackageSpec) *osbuild.Sources {
 	}
 }
 
+func inlineSource(files []blueprint.FileCustomization, buildInfo *distro.BuildInfo) (*osbuild.InlineSource, error) {
+	source := osbuild.NewInlineSource()
+	for _, file := range files {
+		data, err := file.Content()
+		if err != nil {
+			return nil, err
+		}
+		source.AddItem(data)
+	}
+	if buildInfo != nil {
+		data, err := buildInfo.Content()
+		if err != nil {
+			return nil, err
+		}
+		source.AddItem(data)
+	}
+	return source, nil
+}
+
+func skopeoSource(containers []blueprint.Container) (*osbuild.SkopeoSource, error) {
+	source := osbuild.NewSkopeoSource()
+	for _, container := range containers {
+		name, digest, err := container.Reference()
+		if err != nil {
+			return nil, err
+		}
+		source.AddItem(name, digest, container.TLSVerify)
+	}
+	return source, nil
+}
+
 func (t *imageType) pipeline(c *blueprint.Customizations, options distro.ImageOptions, repos []rpmmd.RepoConfig, packageSpecs, buildPackageSpecs []rpmmd.PackageSpec) (*osbuild.Pipeline, error) {
 
 	if kernelOpts := c.GetKernel(); kernelOpts != nil && kernelOpts.Append != "" && t.rpmOstree {
 		return nil, fmt.Errorf("kernel boot parameter customizations are not supported for ostree types")
 	}
 
+	if c.GetOpenSCAP() != nil && t.rpmOstree {
+		return nil, fmt.Errorf("OpenSCAP customizations are not supported for ostree types")
+	}
+
+	if len(options.Containers) > 0 && t.rpmOstree {
+		return nil, fmt.Errorf("embedding containers is not supported for ostree types")
+	}
+
+	if len(options.EnabledModules) > 0 && t.rpmOstree {
+		return nil, fmt.Errorf("enabling module streams is not supported for ostree types")
+	}
+
+	if c.GetFirstBoot() != nil && t.rpmOstree {
+		return nil, fmt.Errorf("first-boot scripts are not supported for ostree types")
+	}
+
+	if c.GetBootloader() != nil && (!t.bootable || t.arch.Name() == "s390x") {
+		return nil, fmt.Errorf("bootloader customizations are only supported for bootable image types using GRUB")
+	}
+
+	if c.GetIgnition() != nil && (t.rpmOstree || !t.bootable) {
+		return nil, fmt.Errorf("Ignition customizations are only supported for bootable, non-ostree image types")
+	}
+
+	// the partition tables of this distribution are fixed in the assemblers
+	if c.GetDisk() != nil {
+		return nil, fmt.Errorf("disk customizations are not supported for %s", t.name)
+	}
+
+	kernelOptions := t.kernelOptions
+	if ignition := c.GetIgnition(); ignition != nil {
+		kernelOptions += " " + strings.Join(ignition.KernelArgs(), " ")
+	}
+
 	p := &osbuild.Pipeline{}
 	p.SetBuild(t.buildPipeline(repos, *t.arch, buildPackageSpecs), "org.osbuild.rhel82")
 
 	if t.arch.Name() == "s390x" {
+		cmdline := "net.ifnames=0 crashkernel=auto"
+		if ignition := c.GetIgnition(); ignition != nil {
+			cmdline += " " + strings.Join(ignition.KernelArgs(), " ")
+		}
+		if kernel := c.GetKernel(); kernel != nil && kernel.Append != "" {
+			cmdline += " " + kernel.Append
+		}
+
+		// s390x does not use grub2, so the kernel-cmdline stage is the only
+		// place where the blueprint's kernel arguments can be applied
 		p.AddStage(osbuild.NewKernelCmdlineStage(&osbuild.KernelCmdlineStageOptions{
 			RootFsUUID: "0bd700f8-090f-4556-b797-b340297ea1bd",
-			KernelOpts: "net.ifnames=0 crashkernel=auto",
+			KernelOpts: cmdline,
 		}))
 	}
 
@@ -250,7 +365,7 @@ func (t *imageType) pipeline(c *blueprint.Customizations, options distro.ImageOp
 	if t.bootable {
 		p.AddStage(osbuild.NewFSTabStage(t.fsTabStageOptions(t.arch.uefi)))
 		if t.arch.Name() != "s390x" {
-			p.AddStage(osbuild.NewGRUB2Stage(t.grub2StageOptions(t.kernelOptions, c.GetKernel(), t.arch.uefi)))
+			p.AddStage(osbuild.NewGRUB2Stage(t.grub2StageOptions(kernelOptions, c.GetKernel(), c.GetBootloader(), t.arch.uefi)))
 		}
 	}
 
@@ -305,6 +420,76 @@ func (t *imageType) pipeline(c *blueprint.Customizations, options distro.ImageOp
 		p.AddStage(osbuild.NewZiplStage(&osbuild.ZiplStageOptions{}))
 	}
 
+	if dirs, files := c.GetDirectories(), c.GetFiles(); len(dirs) > 0 || len(files) > 0 {
+		stages, err := t.fileStages(dirs, files)
+		if err != nil {
+			return nil, err
+		}
+		for _, stage := range stages {
+			p.AddStage(stage)
+		}
+	}
+
+	if options.BuildInfo != nil {
+		stages, err := buildInfoStages(options.BuildInfo)
+		if err != nil {
+			return nil, err
+		}
+		for _, stage := range stages {
+			p.AddStage(stage)
+		}
+	}
+
+	if c.GetCACerts() != nil {
+		p.AddStage(osbuild.NewUpdateCATrustStage())
+	}
+
+	if dnf := c.GetDNF(); dnf != nil {
+		p.AddStage(osbuild.NewDNFConfigStage(&osbuild.DNFConfigStageOptions{
+			Config: &osbuild.DNFConfig{
+				Main: &osbuild.DNFConfigMain{
+					InstallWeakDeps: dnf.InstallWeakDeps,
+					Exclude:         dnf.Exclude,
+					Proxy:           dnf.Proxy,
+				},
+			},
+		}))
+	}
+
+	if oscap := c.GetOpenSCAP(); oscap != nil {
+		datastream := oscap.DataStream
+		if datastream == "" {
+			datastream = oscapDatastream
+		}
+		p.AddStage(osbuild.NewOscapRemediationStage(&osbuild.OscapRemediationStageOptions{
+			Config: osbuild.OscapConfig{
+				Datastream: datastream,
+				ProfileID:  oscap.ProfileID,
+			},
+		}))
+	}
+
+	// record the enabled streams, so that dnf in the image keeps
+	// installing and updating from them
+	for _, module := range options.EnabledModules {
+		p.AddStage(osbuild.NewDNFModuleConfigStage(&osbuild.DNFModuleConfigStageOptions{
+			Conf: osbuild.DNFModuleConfig{
+				Name:     module.Name,
+				Stream:   module.Stream,
+				State:    "enabled",
+				Profiles: []string{},
+			},
+		}))
+	}
+
+	if len(options.Containers) > 0 {
+		p.AddStage(osbuild.NewSkopeoStage(t.skopeoStageOptions(options.Containers)))
+	}
+
+	if firstBoot := firstBootStageOptions(options.Subscription, c.GetFirstBoot()); firstBoot != nil {
+		p.AddStage(osbuild.NewFirstBootStage(firstBoot))
+	}
+
 	p.AddStage(osbuild.NewSELinuxStage(t.selinuxStageOptions()))
 
 	if t.rpmOstree {
@@ -317,18 +502,9 @@ func (t *imageType) pipeline(c *blueprint.Customizations, options distro.ImageOp
 	}
 
 	if options.Subscription != nil {
-		commands := []string{
-			fmt.Sprintf("/usr/sbin/subscription-manager register --org=%d --activationkey=%s --serverurl %s --baseurl %s", options.Subscription.Organization, options.Subscription.ActivationKey, options.Subscription.ServerUrl, options.Subscription.BaseUrl),
+		for _, stage := range subscriptionStages(options.Subscription) {
+			p.AddStage(stage)
 		}
-		if options.Subscription.Insights {
-			commands = append(commands, "/usr/bin/insights-client --register")
-		}
-
-		p.AddStage(osbuild.NewFirstBootStage(&osbuild.FirstBootStageOptions{
-			Commands:       commands,
-			WaitForNetwork: true,
-		},
-		))
 	} else {
 		// RHSM DNF plugins should be by default disabled on RHEL Guest KVM images
 		if t.Name() == "qcow2" {
@@ -350,6 +526,69 @@ func (t *imageType) pipeline(c *blueprint.Customizations, options distro.ImageOp
 	return p, nil
 }
 
+// subscriptionCommands register the image on first boot
+func subscriptionCommands(subscription *distro.SubscriptionImageOptions) []string {
+	register := fmt.Sprintf("/usr/sbin/subscription-manager register --org=%d --activationkey=%s", subscription.Organization, subscription.ActivationKey)
+	if subscription.ServerUrl != "" {
+		register += " --serverurl " + subscription.ServerUrl
+	}
+	if subscription.BaseUrl != "" {
+		register += " --baseurl " + subscription.BaseUrl
+	}
+	commands := []string{register}
+	if subscription.Insights {
+		commands = append(commands, "/usr/bin/insights-client --register")
+	}
+	return commands
+}
+
+// firstBootStageOptions returns the commands to run on the first boot, those
+// registering the image before the ones from the blueprint, or nil if there
+// are none
+func firstBootStageOptions(subscription *distro.SubscriptionImageOptions, firstBoot *blueprint.FirstBootCustomization) *osbuild.FirstBootStageOptions {
+	if subscription == nil && firstBoot == nil {
+		return nil
+	}
+	options := &osbuild.FirstBootStageOptions{}
+	if subscription != nil {
+		options.Commands = subscriptionCommands(subscription)
+		options.WaitForNetwork = true
+	}
+	if firstBoot != nil {
+		options.Commands = append(options.Commands, firstBoot.Commands()...)
+		options.WaitForNetwork = options.WaitForNetwork || firstBoot.WaitForNetwork
+	}
+	return options
+}
+
+// subscriptionStages let the Subscription Manager manage the repositories of
+// the registered system
+func subscriptionStages(subscription *distro.SubscriptionImageOptions) []*osbuild.Stage {
+	manageRepos := true
+	return []*osbuild.Stage{
+		osbuild.NewRHSMStage(&osbuild.RHSMStageOptions{
+			DnfPlugins: &osbuild.RHSMStageOptionsDnfPlugins{
+				ProductID: &osbuild.RHSMStageOptionsDnfPlugin{
+					Enabled: true,
+				},
+				SubscriptionManager: &osbuild.RHSMStageOptionsDnfPlugin{
+					Enabled: true,
+				},
+			},
+			SubMan: &osbuild.RHSMStageOptionsSubMan{
+				Rhsm: &osbuild.SubManConfigRHSMSection{
+					ManageRepos: &manageRepos,
+				},
+			},
+		}),
+		osbuild.NewRHSMFactsStage(&osbuild.RHSMFactsStageOptions{
+			Facts: osbuild.RHSMFacts{
+				APIType: subscription.APIType,
+			},
+		}),
+	}
+}
+
 func (t *imageType) buildPipeline(repos []rpmmd.RepoConfig, arch architecture, buildPackageSpecs []rpmmd.PackageSpec) *osbuild.Pipeline {
 	p := &osbuild.Pipeline{}
 	p.AddStage(osbuild.NewRPMStage(t.rpmStageOptions(arch, repos, buildPackageSpecs)))
@@ -407,6 +646,7 @@ func (t *imageType) userStageOptions(users []blueprint.UserCustomization) (*osbu
 
 		user.UID = c.UID
 		user.GID = c.GID
+		user.ExpireDate = c.ExpireDate
 
 		options.Users[c.Name] = user
 	}
@@ -444,6 +684,104 @@ func (t *imageType) firewallStageOptions(firewall *blueprint.FirewallCustomizati
 	return &options
 }
 
+func (t *imageType) skopeoStageOptions(containers []blueprint.Container) *osbuild.SkopeoStageOptions {
+	options := osbuild.SkopeoStageOptions{
+		Destination: osbuild.SkopeoDestination{
+			Type: "containers-storage",
+		},
+	}
+	for _, container := range containers {
+		// validated when computing the sources
+		_, digest, _ := container.Reference()
+		options.Images = append(options.Images, osbuild.SkopeoStageImage{
+			Digest: digest,
+			Name:   container.LocalName(),
+		})
+	}
+	return &options
+}
+
+// fileStages creates the directories and files from the blueprint and sets
+// their ownership and permissions. File contents are taken from the inline
+// source created by inlineSource().
+func (t *imageType) fileStages(dirs []blueprint.DirectoryCustomization, files []blueprint.FileCustomization) ([]*osbuild.Stage, error) {
+	var stages []*osbuild.Stage
+	chown := &osbuild.ChownStageOptions{Items: map[string]osbuild.ChownStagePathOptions{}}
+	chmod := &osbuild.ChmodStageOptions{Items: map[string]osbuild.ChmodStagePathOptions{}}
+
+	setAttributes := func(path, user, group, mode string) {
+		if user != "" || group != "" {
+			chown.Items[path] = osbuild.ChownStagePathOptions{User: user, Group: group}
+		}
+		if mode != "" {
+			chmod.Items[path] = osbuild.ChmodStagePathOptions{Mode: mode}
+		}
+	}
+
+	if len(dirs) > 0 {
+		mkdir := &osbuild.MkdirStageOptions{}
+		for _, dir := range dirs {
+			mkdir.Paths = append(mkdir.Paths, osbuild.MkdirStagePath{
+				Path:    dir.Path,
+				Parents: dir.EnsureParents,
+				ExistOk: true,
+			})
+			setAttributes(dir.Path, dir.User, dir.Group, dir.Mode)
+		}
+		stages = append(stages, osbuild.NewMkdirStage(mkdir))
+	}
+
+	if len(files) > 0 {
+		copyOptions := &osbuild.CopyStageOptions{}
+		for _, file := range files {
+			data, err := file.Content()
+			if err != nil {
+				return nil, err
+			}
+			copyOptions.Paths = append(copyOptions.Paths, osbuild.CopyStagePath{
+				From: osbuild.InlineChecksum(data),
+				To:   file.Path,
+			})
+			setAttributes(file.Path, file.User, file.Group, file.Mode)
+		}
+		stages = append(stages, osbuild.NewCopyStage(copyOptions))
+	}
+
+	if len(chown.Items) > 0 {
+		stages = append(stages, osbuild.NewChownStage(chown))
+	}
+	if len(chmod.Items) > 0 {
+		stages = append(stages, osbuild.NewChmodStage(chmod))
+	}
+
+	return stages, nil
+}
+
+// buildInfoStages write the build information of the image to
+// distro.BuildInfoPath. Its content is taken from the inline source created
+// by inlineSource().
+func buildInfoStages(buildInfo *distro.BuildInfo) ([]*osbuild.Stage, error) {
+	data, err := buildInfo.Content()
+	if err != nil {
+		return nil, err
+	}
+	return []*osbuild.Stage{
+		osbuild.NewMkdirStage(&osbuild.MkdirStageOptions{
+			Paths: []osbuild.MkdirStagePath{{
+				Path:    path.Dir(distro.BuildInfoPath),
+				Parents: true,
+				ExistOk: true,
+			}},
+		}),
+		osbuild.NewCopyStage(&osbuild.CopyStageOptions{
+			Paths: []osbuild.CopyStagePath{{
+				From: osbuild.InlineChecksum(data),
+				To:   distro.BuildInfoPath,
+			}},
+		}),
+	}, nil
+}
+
 func (t *imageType) systemdStageOptions(enabledServices, disabledServices []string, s *blueprint.ServicesCustomization, target string) *osbuild.SystemdStageOptions {
 	if s != nil {
 		enabledServices = append(enabledServices, s.Enabled...)
@@ -465,12 +803,17 @@ func (t *imageType) fsTabStageOptions(uefi bool) *osbuild.FSTabStageOptions {
 	return &options
 }
 
-func (t *imageType) grub2StageOptions(kernelOptions string, kernel *blueprint.KernelCustomization, uefi bool) *osbuild.GRUB2StageOptions {
+func (t *imageType) grub2StageOptions(kernelOptions string, kernel *blueprint.KernelCustomization, bootloader *blueprint.BootloaderCustomization, uefi bool) *osbuild.GRUB2StageOptions {
 	id := uuid.MustParse("0bd700f8-090f-4556-b797-b340297ea1bd")
 
 	if kernel != nil {
 		kernelOptions += " " + kernel.Append
 	}
+	if bootloader != nil {
+		if args := bootloader.KernelArgs(); len(args) > 0 {
+			kernelOptions += " " + strings.Join(args, " ")
+		}
+	}
 
 	var uefiOptions *osbuild.GRUB2UEFI
 	if uefi {
@@ -484,12 +827,26 @@ func (t *imageType) grub2StageOptions(kernelOptions string, kernel *blueprint.Ke
 		legacy = t.arch.legacy
 	}
 
-	return &osbuild.GRUB2StageOptions{
+	options := &osbuild.GRUB2StageOptions{
 		RootFilesystemUUID: id,
 		KernelOptions:      kernelOptions,
 		Legacy:             legacy,
 		UEFI:               uefiOptions,
 	}
+
+	if bootloader != nil {
+		options.Config = &osbuild.GRUB2Config{
+			Default: bootloader.DefaultEntry,
+			Timeout: bootloader.Timeout,
+		}
+		if serial := bootloader.SerialCommand(); serial != "" {
+			options.Config.Serial = serial
+			options.Config.TerminalInput = []string{"serial", "console"}
+			options.Config.TerminalOutput = []string{"serial", "console"}
+		}
+	}
+
+	return options
 }
 
 func (t *imageType) selinuxStageOptions() *osbuild.SELinuxStageOptions {
@@ -682,7 +1039,8 @@ func New() distro.Distro {
 			"greenboot-rpm-ostree-grub2-check-fallback", "greenboot-status", "greenboot-task-runner",
 			"redboot-auto-reboot", "redboot-task-runner",
 		},
-		rpmOstree: true,
+		rpmOstree:  true,
+		noWeakDeps: true,
 		assembler: func(uefi bool, options distro.ImageOptions, arch distro.Arch) *osbuild.Assembler {
 			return ostreeCommitAssembler(options, arch)
 		},
@@ -736,7 +1094,8 @@ func New() distro.Distro {
 			"greenboot-rpm-ostree-grub2-check-fallback", "greenboot-status", "greenboot-task-runner",
 			"redboot-auto-reboot", "redboot-task-runner",
 		},
-		rpmOstree: true,
+		rpmOstree:  true,
+		noWeakDeps: true,
 		assembler: func(uefi bool, options distro.ImageOptions, arch distro.Arch) *osbuild.Assembler {
 			return ostreeCommitAssembler(options, arch)
 		},
@@ -938,6 +1297,7 @@ func New() distro.Distro {
 			"selinux-policy-targeted",
 		},
 		bootable:      false,
+		noWeakDeps:    true,
 		kernelOptions: "ro net.ifnames=0",
 		assembler: func(uefi bool, options distro.ImageOptions, arch distro.Arch) *osbuild.Assembler {
 			return tarAssembler("root.tar.xz", "xz")
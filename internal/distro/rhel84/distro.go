@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"path"
 	"sort"
+	"strings"
 
 	"github.com/osbuild/osbuild-composer/internal/disk"
 	"github.com/osbuild/osbuild-composer/internal/distro"
@@ -22,6 +24,8 @@ import (
 const name = "rhel-84"
 const centosName = "centos-8"
 const modulePlatformID = "platform:el8"
+const oscapDatastream = "/usr/share/xml/scap/ssg/content/ssg-rhel8-ds.xml"
+const centosOscapDatastream = "/usr/share/xml/scap/ssg/content/ssg-centos8-ds.xml"
 
 type distribution struct {
 	arches        map[string]architecture
@@ -53,6 +57,7 @@ type imageType struct {
 	kernelOptions           string
 	bootable                bool
 	rpmOstree               bool
+	noWeakDeps              bool
 	defaultSize             uint64
 	partitionTableGenerator func(imageOptions distro.ImageOptions, arch distro.Arch, rng *rand.Rand) disk.PartitionTable
 	assembler               func(pt *disk.PartitionTable, options distro.ImageOptions, arch distro.Arch) *osbuild.Assembler
@@ -142,6 +147,7 @@ func (a *architecture) addImageTypes(imageTypes ...imageType) {
 			kernelOptions:           it.kernelOptions,
 			bootable:                it.bootable,
 			rpmOstree:               it.rpmOstree,
+			noWeakDeps:              it.noWeakDeps,
 			defaultSize:             it.defaultSize,
 			partitionTableGenerator: it.partitionTableGenerator,
 			assembler:               it.assembler,
@@ -175,10 +181,16 @@ func (t *imageType) Size(size uint64) uint64 {
 
 func (t *imageType) Packages(bp blueprint.Blueprint) ([]string, []string) {
 	packages := append(t.packages, bp.GetPackages()...)
-	timezone, _ := bp.Customizations.GetTimezoneSettings()
-	if timezone != nil {
+	timezone, ntpServers := bp.Customizations.GetTimezoneSettings()
+	if timezone != nil || len(ntpServers) > 0 {
 		packages = append(packages, "chrony")
 	}
+	if bp.Customizations.GetOpenSCAP() != nil {
+		packages = append(packages, "openscap-scanner", "scap-security-guide")
+	}
+	if bp.Customizations.GetIgnition() != nil {
+		packages = append(packages, "ignition")
+	}
 	if t.bootable {
 		packages = append(packages, t.arch.bootloaderPackages...)
 	}
@@ -188,7 +200,7 @@ func (t *imageType) Packages(bp blueprint.Blueprint) ([]string, []string) {
 		packages = removePackage(packages, "insights-client")
 	}
 
-	return packages, t.excludedPackages
+	return packages, append(bp.GetExcludedPackages(), t.excludedPackages...)
 }
 
 func (t *imageType) BuildPackages() []string {
@@ -199,12 +211,21 @@ func (t *imageType) BuildPackages() []string {
 	return packages
 }
 
+func (t *imageType) InstallWeakDeps() bool {
+	return !t.noWeakDeps
+}
+
 func (t *imageType) Manifest(c *blueprint.Customizations,
 	options distro.ImageOptions,
 	repos []rpmmd.RepoConfig,
 	packageSpecs,
 	buildPackageSpecs []rpmmd.PackageSpec,
 	seed int64) (distro.Manifest, error) {
+	c, err := c.WithCoprRepositories(t.arch.distro.Name())
+	if err != nil {
+		return distro.Manifest{}, err
+	}
+
 	source := rand.NewSource(seed)
 	rng := rand.New(source)
 	pipeline, err := t.pipeline(c, options, repos, packageSpecs, buildPackageSpecs, rng)
@@ -212,9 +233,25 @@ func (t *imageType) Manifest(c *blueprint.Customizations,
 		return distro.Manifest{}, err
 	}
 
+	sources := sources(append(packageSpecs, buildPackageSpecs...))
+	if files := c.GetFiles(); len(files) > 0 || options.BuildInfo != nil {
+		inline, err := inlineSource(files, options.BuildInfo)
+		if err != nil {
+			return distro.Manifest{}, err
+		}
+		(*sources)["org.osbuild.inline"] = inline
+	}
+	if len(options.Containers) > 0 {
+		skopeo, err := skopeoSource(options.Containers)
+		if err != nil {
+			return distro.Manifest{}, err
+		}
+		(*sources)["org.osbuild.skopeo"] = skopeo
+	}
+
 	return json.Marshal(
 		osbuild.Manifest{
-			Sources:  *sources(append(packageSpecs, buildPackageSpecs...)),
+			Sources:  *sources,
 			Pipeline: *pipeline,
 		},
 	)
@@ -237,11 +274,15 @@ func sources(packages []rpmmd.PackageSpec) *osbuild.Sources {
 	}
 	for _, pkg := range packages {
 		fileSource := osbuild.FileSource{
-			URL: pkg.RemoteLocation,
+			URL:      pkg.RemoteLocation,
+			Proxy:    pkg.Proxy,
+			Insecure: pkg.IgnoreSSL,
+			CACert:   pkg.SSLCACert,
+			Mirrors:  pkg.Mirrors,
 		}
-		if pkg.Secrets == "org.osbuild.rhsm" {
+		if pkg.Secrets != "" {
 			fileSource.Secrets = &osbuild.Secret{
-				Name: "org.osbuild.rhsm",
+				Name: pkg.Secrets,
 			}
 		}
 		files.URLs[pkg.Checksum] = fileSource
@@ -251,16 +292,85 @@ func sources(packages []rpmmd.PackageSpec) *osbuild.Sources {
 	}
 }
 
+func inlineSource(files []blueprint.FileCustomization, buildInfo *distro.BuildInfo) (*osbuild.InlineSource, error) {
+	source := osbuild.NewInlineSource()
+	for _, file := range files {
+		data, err := file.Content()
+		if err != nil {
+			return nil, err
+		}
+		source.AddItem(data)
+	}
+	if buildInfo != nil {
+		data, err := buildInfo.Content()
+		if err != nil {
+			return nil, err
+		}
+		source.AddItem(data)
+	}
+	return source, nil
+}
+
+func skopeoSource(containers []blueprint.Container) (*osbuild.SkopeoSource, error) {
+	source := osbuild.NewSkopeoSource()
+	for _, container := range containers {
+		name, digest, err := container.Reference()
+		if err != nil {
+			return nil, err
+		}
+		source.AddItem(name, digest, container.TLSVerify)
+	}
+	return source, nil
+}
+
 func (t *imageType) pipeline(c *blueprint.Customizations, options distro.ImageOptions, repos []rpmmd.RepoConfig, packageSpecs, buildPackageSpecs []rpmmd.PackageSpec, rng *rand.Rand) (*osbuild.Pipeline, error) {
 
 	if kernelOpts := c.GetKernel(); kernelOpts != nil && kernelOpts.Append != "" && t.rpmOstree {
 		return nil, fmt.Errorf("kernel boot parameter customizations are not supported for ostree types")
 	}
 
+	if c.GetOpenSCAP() != nil && t.rpmOstree {
+		return nil, fmt.Errorf("OpenSCAP customizations are not supported for ostree types")
+	}
+
+	if len(options.Containers) > 0 && t.rpmOstree {
+		return nil, fmt.Errorf("embedding containers is not supported for ostree types")
+	}
+
+	if len(options.EnabledModules) > 0 && t.rpmOstree {
+		return nil, fmt.Errorf("enabling module streams is not supported for ostree types")
+	}
+
+	if c.GetFirstBoot() != nil && t.rpmOstree {
+		return nil, fmt.Errorf("first-boot scripts are not supported for ostree types")
+	}
+
+	if c.GetBootloader() != nil && (!t.bootable || t.arch.Name() == "s390x") {
+		return nil, fmt.Errorf("bootloader customizations are only supported for bootable image types using GRUB")
+	}
+
+	if c.GetIgnition() != nil && (t.rpmOstree || !t.bootable) {
+		return nil, fmt.Errorf("Ignition customizations are only supported for bootable, non-ostree image types")
+	}
+
+	kernelOptions := t.kernelOptions
+	if ignition := c.GetIgnition(); ignition != nil {
+		kernelOptions += " " + strings.Join(ignition.KernelArgs(), " ")
+	}
+
 	var pt *disk.PartitionTable
 	if t.partitionTableGenerator != nil {
 		table := t.partitionTableGenerator(options, t.arch, rng)
+		if customDisk := c.GetDisk(); customDisk != nil {
+			var err error
+			table, err = customPartitionTable(customDisk, table, rng)
+			if err != nil {
+				return nil, err
+			}
+		}
 		pt = &table
+	} else if c.GetDisk() != nil {
+		return nil, fmt.Errorf("disk customizations are not supported for %s", t.name)
 	}
 
 	p := &osbuild.Pipeline{}
@@ -280,9 +390,16 @@ func (t *imageType) pipeline(c *blueprint.Customizations, options distro.ImageOp
 			panic("s390x image must have a root partition, this is a programming error")
 		}
 
+		cmdline := kernelOptions
+		if kernel := c.GetKernel(); kernel != nil && kernel.Append != "" {
+			cmdline += " " + kernel.Append
+		}
+
+		// s390x does not use grub2, so the kernel-cmdline stage is the only
+		// place where the blueprint's kernel arguments can be applied
 		p.AddStage(osbuild.NewKernelCmdlineStage(&osbuild.KernelCmdlineStageOptions{
 			RootFsUUID: rootPartition.Filesystem.UUID,
-			KernelOpts: t.kernelOptions,
+			KernelOpts: cmdline,
 		}))
 	}
 
@@ -295,7 +412,7 @@ func (t *imageType) pipeline(c *blueprint.Customizations, options distro.ImageOp
 
 	if t.bootable {
 		if t.arch.Name() != "s390x" {
-			p.AddStage(osbuild.NewGRUB2Stage(t.grub2StageOptions(pt, t.kernelOptions, c.GetKernel(), t.arch.uefi, t.arch.legacy)))
+			p.AddStage(osbuild.NewGRUB2Stage(t.grub2StageOptions(pt, kernelOptions, c.GetKernel(), c.GetBootloader(), t.arch.uefi, t.arch.legacy)))
 		}
 	}
 
@@ -352,6 +469,80 @@ func (t *imageType) pipeline(c *blueprint.Customizations, options distro.ImageOp
 		p.AddStage(osbuild.NewZiplStage(&osbuild.ZiplStageOptions{}))
 	}
 
+	if dirs, files := c.GetDirectories(), c.GetFiles(); len(dirs) > 0 || len(files) > 0 {
+		stages, err := t.fileStages(dirs, files)
+		if err != nil {
+			return nil, err
+		}
+		for _, stage := range stages {
+			p.AddStage(stage)
+		}
+	}
+
+	if options.BuildInfo != nil {
+		stages, err := buildInfoStages(options.BuildInfo)
+		if err != nil {
+			return nil, err
+		}
+		for _, stage := range stages {
+			p.AddStage(stage)
+		}
+	}
+
+	if c.GetCACerts() != nil {
+		p.AddStage(osbuild.NewUpdateCATrustStage())
+	}
+
+	if dnf := c.GetDNF(); dnf != nil {
+		p.AddStage(osbuild.NewDNFConfigStage(&osbuild.DNFConfigStageOptions{
+			Config: &osbuild.DNFConfig{
+				Main: &osbuild.DNFConfigMain{
+					InstallWeakDeps: dnf.InstallWeakDeps,
+					Exclude:         dnf.Exclude,
+					Proxy:           dnf.Proxy,
+				},
+			},
+		}))
+	}
+
+	if oscap := c.GetOpenSCAP(); oscap != nil {
+		datastream := oscap.DataStream
+		if datastream == "" {
+			if t.arch.distro.isCentos {
+				datastream = centosOscapDatastream
+			} else {
+				datastream = oscapDatastream
+			}
+		}
+		p.AddStage(osbuild.NewOscapRemediationStage(&osbuild.OscapRemediationStageOptions{
+			Config: osbuild.OscapConfig{
+				Datastream: datastream,
+				ProfileID:  oscap.ProfileID,
+			},
+		}))
+	}
+
+	// record the enabled streams, so that dnf in the image keeps
+	// installing and updating from them
+	for _, module := range options.EnabledModules {
+		p.AddStage(osbuild.NewDNFModuleConfigStage(&osbuild.DNFModuleConfigStageOptions{
+			Conf: osbuild.DNFModuleConfig{
+				Name:     module.Name,
+				Stream:   module.Stream,
+				State:    "enabled",
+				Profiles: []string{},
+			},
+		}))
+	}
+
+	if len(options.Containers) > 0 {
+		p.AddStage(osbuild.NewSkopeoStage(t.skopeoStageOptions(options.Containers)))
+	}
+
+	if firstBoot := firstBootStageOptions(options.Subscription, c.GetFirstBoot()); firstBoot != nil {
+		p.AddStage(osbuild.NewFirstBootStage(firstBoot))
+	}
+
 	p.AddStage(osbuild.NewSELinuxStage(t.selinuxStageOptions()))
 
 	// These are the current defaults for the sysconfig stage. This can be changed to be image type exclusive if different configs are needed.
@@ -376,18 +567,9 @@ func (t *imageType) pipeline(c *blueprint.Customizations, options distro.ImageOp
 	}
 
 	if options.Subscription != nil {
-		commands := []string{
-			fmt.Sprintf("/usr/sbin/subscription-manager register --org=%d --activationkey=%s --serverurl %s --baseurl %s", options.Subscription.Organization, options.Subscription.ActivationKey, options.Subscription.ServerUrl, options.Subscription.BaseUrl),
-		}
-		if options.Subscription.Insights {
-			commands = append(commands, "/usr/bin/insights-client --register")
+		for _, stage := range subscriptionStages(options.Subscription) {
+			p.AddStage(stage)
 		}
-
-		p.AddStage(osbuild.NewFirstBootStage(&osbuild.FirstBootStageOptions{
-			Commands:       commands,
-			WaitForNetwork: true,
-		},
-		))
 	} else {
 		// RHSM DNF plugins should be by default disabled on RHEL Guest KVM images
 		if t.Name() == "qcow2" {
@@ -409,6 +591,69 @@ func (t *imageType) pipeline(c *blueprint.Customizations, options distro.ImageOp
 	return p, nil
 }
 
+// subscriptionCommands register the image on first boot
+func subscriptionCommands(subscription *distro.SubscriptionImageOptions) []string {
+	register := fmt.Sprintf("/usr/sbin/subscription-manager register --org=%d --activationkey=%s", subscription.Organization, subscription.ActivationKey)
+	if subscription.ServerUrl != "" {
+		register += " --serverurl " + subscription.ServerUrl
+	}
+	if subscription.BaseUrl != "" {
+		register += " --baseurl " + subscription.BaseUrl
+	}
+	commands := []string{register}
+	if subscription.Insights {
+		commands = append(commands, "/usr/bin/insights-client --register")
+	}
+	return commands
+}
+
+// firstBootStageOptions returns the commands to run on the first boot, those
+// registering the image before the ones from the blueprint, or nil if there
+// are none
+func firstBootStageOptions(subscription *distro.SubscriptionImageOptions, firstBoot *blueprint.FirstBootCustomization) *osbuild.FirstBootStageOptions {
+	if subscription == nil && firstBoot == nil {
+		return nil
+	}
+	options := &osbuild.FirstBootStageOptions{}
+	if subscription != nil {
+		options.Commands = subscriptionCommands(subscription)
+		options.WaitForNetwork = true
+	}
+	if firstBoot != nil {
+		options.Commands = append(options.Commands, firstBoot.Commands()...)
+		options.WaitForNetwork = options.WaitForNetwork || firstBoot.WaitForNetwork
+	}
+	return options
+}
+
+// subscriptionStages let the Subscription Manager manage the repositories of
+// the registered system
+func subscriptionStages(subscription *distro.SubscriptionImageOptions) []*osbuild.Stage {
+	manageRepos := true
+	return []*osbuild.Stage{
+		osbuild.NewRHSMStage(&osbuild.RHSMStageOptions{
+			DnfPlugins: &osbuild.RHSMStageOptionsDnfPlugins{
+				ProductID: &osbuild.RHSMStageOptionsDnfPlugin{
+					Enabled: true,
+				},
+				SubscriptionManager: &osbuild.RHSMStageOptionsDnfPlugin{
+					Enabled: true,
+				},
+			},
+			SubMan: &osbuild.RHSMStageOptionsSubMan{
+				Rhsm: &osbuild.SubManConfigRHSMSection{
+					ManageRepos: &manageRepos,
+				},
+			},
+		}),
+		osbuild.NewRHSMFactsStage(&osbuild.RHSMFactsStageOptions{
+			Facts: osbuild.RHSMFacts{
+				APIType: subscription.APIType,
+			},
+		}),
+	}
+}
+
 func (t *imageType) buildPipeline(repos []rpmmd.RepoConfig, arch architecture, buildPackageSpecs []rpmmd.PackageSpec) *osbuild.Pipeline {
 	p := &osbuild.Pipeline{}
 	p.AddStage(osbuild.NewRPMStage(t.rpmStageOptions(arch, repos, buildPackageSpecs)))
@@ -466,6 +711,7 @@ func (t *imageType) userStageOptions(users []blueprint.UserCustomization) (*osbu
 
 		user.UID = c.UID
 		user.GID = c.GID
+		user.ExpireDate = c.ExpireDate
 
 		options.Users[c.Name] = user
 	}
@@ -503,6 +749,104 @@ func (t *imageType) firewallStageOptions(firewall *blueprint.FirewallCustomizati
 	return &options
 }
 
+func (t *imageType) skopeoStageOptions(containers []blueprint.Container) *osbuild.SkopeoStageOptions {
+	options := osbuild.SkopeoStageOptions{
+		Destination: osbuild.SkopeoDestination{
+			Type: "containers-storage",
+		},
+	}
+	for _, container := range containers {
+		// validated when computing the sources
+		_, digest, _ := container.Reference()
+		options.Images = append(options.Images, osbuild.SkopeoStageImage{
+			Digest: digest,
+			Name:   container.LocalName(),
+		})
+	}
+	return &options
+}
+
+// fileStages creates the directories and files from the blueprint and sets
+// their ownership and permissions. File contents are taken from the inline
+// source created by inlineSource().
+func (t *imageType) fileStages(dirs []blueprint.DirectoryCustomization, files []blueprint.FileCustomization) ([]*osbuild.Stage, error) {
+	var stages []*osbuild.Stage
+	chown := &osbuild.ChownStageOptions{Items: map[string]osbuild.ChownStagePathOptions{}}
+	chmod := &osbuild.ChmodStageOptions{Items: map[string]osbuild.ChmodStagePathOptions{}}
+
+	setAttributes := func(path, user, group, mode string) {
+		if user != "" || group != "" {
+			chown.Items[path] = osbuild.ChownStagePathOptions{User: user, Group: group}
+		}
+		if mode != "" {
+			chmod.Items[path] = osbuild.ChmodStagePathOptions{Mode: mode}
+		}
+	}
+
+	if len(dirs) > 0 {
+		mkdir := &osbuild.MkdirStageOptions{}
+		for _, dir := range dirs {
+			mkdir.Paths = append(mkdir.Paths, osbuild.MkdirStagePath{
+				Path:    dir.Path,
+				Parents: dir.EnsureParents,
+				ExistOk: true,
+			})
+			setAttributes(dir.Path, dir.User, dir.Group, dir.Mode)
+		}
+		stages = append(stages, osbuild.NewMkdirStage(mkdir))
+	}
+
+	if len(files) > 0 {
+		copyOptions := &osbuild.CopyStageOptions{}
+		for _, file := range files {
+			data, err := file.Content()
+			if err != nil {
+				return nil, err
+			}
+			copyOptions.Paths = append(copyOptions.Paths, osbuild.CopyStagePath{
+				From: osbuild.InlineChecksum(data),
+				To:   file.Path,
+			})
+			setAttributes(file.Path, file.User, file.Group, file.Mode)
+		}
+		stages = append(stages, osbuild.NewCopyStage(copyOptions))
+	}
+
+	if len(chown.Items) > 0 {
+		stages = append(stages, osbuild.NewChownStage(chown))
+	}
+	if len(chmod.Items) > 0 {
+		stages = append(stages, osbuild.NewChmodStage(chmod))
+	}
+
+	return stages, nil
+}
+
+// buildInfoStages write the build information of the image to
+// distro.BuildInfoPath. Its content is taken from the inline source created
+// by inlineSource().
+func buildInfoStages(buildInfo *distro.BuildInfo) ([]*osbuild.Stage, error) {
+	data, err := buildInfo.Content()
+	if err != nil {
+		return nil, err
+	}
+	return []*osbuild.Stage{
+		osbuild.NewMkdirStage(&osbuild.MkdirStageOptions{
+			Paths: []osbuild.MkdirStagePath{{
+				Path:    path.Dir(distro.BuildInfoPath),
+				Parents: true,
+				ExistOk: true,
+			}},
+		}),
+		osbuild.NewCopyStage(&osbuild.CopyStageOptions{
+			Paths: []osbuild.CopyStagePath{{
+				From: osbuild.InlineChecksum(data),
+				To:   distro.BuildInfoPath,
+			}},
+		}),
+	}, nil
+}
+
 func (t *imageType) systemdStageOptions(enabledServices, disabledServices []string, s *blueprint.ServicesCustomization, target string) *osbuild.SystemdStageOptions {
 	if s != nil {
 		enabledServices = append(enabledServices, s.Enabled...)
@@ -515,7 +859,7 @@ func (t *imageType) systemdStageOptions(enabledServices, disabledServices []stri
 	}
 }
 
-func (t *imageType) grub2StageOptions(pt *disk.PartitionTable, kernelOptions string, kernel *blueprint.KernelCustomization, uefi bool, legacy string) *osbuild.GRUB2StageOptions {
+func (t *imageType) grub2StageOptions(pt *disk.PartitionTable, kernelOptions string, kernel *blueprint.KernelCustomization, bootloader *blueprint.BootloaderCustomization, uefi bool, legacy string) *osbuild.GRUB2StageOptions {
 	if pt == nil {
 		panic("partition table must be defined for grub2 stage, this is a programming error")
 	}
@@ -526,9 +870,20 @@ func (t *imageType) grub2StageOptions(pt *disk.PartitionTable, kernelOptions str
 
 	id := uuid.MustParse(rootPartition.Filesystem.UUID)
 
+	var bootID *uuid.UUID
+	if bootPartition := pt.BootPartition(); bootPartition != nil {
+		id := uuid.MustParse(bootPartition.Filesystem.UUID)
+		bootID = &id
+	}
+
 	if kernel != nil {
 		kernelOptions += " " + kernel.Append
 	}
+	if bootloader != nil {
+		if args := bootloader.KernelArgs(); len(args) > 0 {
+			kernelOptions += " " + strings.Join(args, " ")
+		}
+	}
 
 	var uefiOptions *osbuild.GRUB2UEFI
 	if uefi {
@@ -547,12 +902,27 @@ func (t *imageType) grub2StageOptions(pt *disk.PartitionTable, kernelOptions str
 		legacy = t.arch.legacy
 	}
 
-	return &osbuild.GRUB2StageOptions{
+	options := &osbuild.GRUB2StageOptions{
 		RootFilesystemUUID: id,
+		BootFilesystemUUID: bootID,
 		KernelOptions:      kernelOptions,
 		Legacy:             legacy,
 		UEFI:               uefiOptions,
 	}
+
+	if bootloader != nil {
+		options.Config = &osbuild.GRUB2Config{
+			Default: bootloader.DefaultEntry,
+			Timeout: bootloader.Timeout,
+		}
+		if serial := bootloader.SerialCommand(); serial != "" {
+			options.Config.Serial = serial
+			options.Config.TerminalInput = []string{"serial", "console"}
+			options.Config.TerminalOutput = []string{"serial", "console"}
+		}
+	}
+
+	return options
 }
 
 func (t *imageType) selinuxStageOptions() *osbuild.SELinuxStageOptions {
@@ -690,6 +1060,126 @@ func defaultPartitionTable(imageOptions distro.ImageOptions, arch distro.Arch, r
 	panic("unknown arch: " + arch.Name())
 }
 
+// firmwarePartitionTypes are the partition types needed to boot, which a
+// custom partition table must keep from the default one
+var firmwarePartitionTypes = []string{blueprint.PartitionTypeBIOSBoot, blueprint.PartitionTypeESP, blueprint.PartitionTypePReP}
+
+// Partition starts and sizes are counted in sectors and aligned to 1 MiB
+const (
+	sectorSize       = 512
+	partitionAlign   = 2048
+	minLastPartition = 1024 * 1024 * 1024
+)
+
+// customPartitionTable creates the partition table described in the blueprint.
+// It must be of the same type and contain the same firmware partitions as the
+// default table of the image type. The disk is grown if the partitions do not
+// fit, leaving at least 1 GiB for a last partition without a size.
+func customPartitionTable(customDisk *blueprint.DiskCustomization, defaultTable disk.PartitionTable, rng *rand.Rand) (disk.PartitionTable, error) {
+	ptType := defaultTable.Type
+	if customDisk.Type != "" && customDisk.Type != ptType {
+		return disk.PartitionTable{}, fmt.Errorf("the partition table must be of type %s for this image type", ptType)
+	}
+
+	present := map[string]bool{}
+	for _, p := range customDisk.Partitions {
+		present[p.GetType()] = true
+	}
+	for _, name := range firmwarePartitionTypes {
+		id, err := disk.PartitionTypeID(name, ptType)
+		if err != nil {
+			continue
+		}
+		for _, p := range defaultTable.Partitions {
+			if p.Type == id && !present[name] {
+				return disk.PartitionTable{}, fmt.Errorf("the partition table needs a partition of type %s for this image type", name)
+			}
+		}
+	}
+
+	hasPReP := present[blueprint.PartitionTypePReP]
+	bootMountpoint := "/"
+	for _, p := range customDisk.Partitions {
+		if p.Mountpoint == "/boot" {
+			bootMountpoint = "/boot"
+		}
+	}
+
+	table := disk.PartitionTable{
+		Size: defaultTable.Size,
+		UUID: defaultTable.UUID,
+		Type: ptType,
+	}
+	start := uint64(partitionAlign)
+	for _, p := range customDisk.Partitions {
+		partitionType := p.GetType()
+		typeID, err := disk.PartitionTypeID(partitionType, ptType)
+		if err != nil {
+			return disk.PartitionTable{}, err
+		}
+
+		partition := disk.Partition{
+			Start: start,
+			Type:  typeID,
+			// firmware partitions are flagged bootable, dos tables without
+			// one mark the partition holding the kernel
+			Bootable: partitionType == blueprint.PartitionTypeBIOSBoot || partitionType == blueprint.PartitionTypePReP ||
+				(ptType == "dos" && !hasPReP && p.Mountpoint == bootMountpoint),
+		}
+		if ptType == "gpt" {
+			partition.UUID = uuid.Must(newRandomUUIDFromReader(rng)).String()
+		}
+
+		if p.Size != "" {
+			size, err := blueprint.ParseSize(p.Size)
+			if err != nil {
+				return disk.PartitionTable{}, err
+			}
+			sectors := (size + sectorSize - 1) / sectorSize
+			partition.Size = (sectors + partitionAlign - 1) / partitionAlign * partitionAlign
+			start += partition.Size
+		}
+
+		if p.Mountpoint != "" {
+			fs := &disk.Filesystem{
+				Type:         p.FSType,
+				Label:        p.Label,
+				Mountpoint:   p.Mountpoint,
+				FSTabOptions: "defaults",
+			}
+			switch {
+			case p.FSType == "vfat":
+				fs.UUID = fmt.Sprintf("%04X-%04X", rng.Intn(0x10000), rng.Intn(0x10000))
+				fs.FSTabOptions = "defaults,uid=0,gid=0,umask=077,shortname=winnt"
+				fs.FSTabPassNo = 2
+			case p.FSType == "xfs":
+				// xfs is checked on mount
+				fs.UUID = uuid.Must(newRandomUUIDFromReader(rng)).String()
+			case p.Mountpoint == "/":
+				fs.UUID = uuid.Must(newRandomUUIDFromReader(rng)).String()
+				fs.FSTabPassNo = 1
+			default:
+				fs.UUID = uuid.Must(newRandomUUIDFromReader(rng)).String()
+				fs.FSTabPassNo = 2
+			}
+			partition.Filesystem = fs
+		}
+
+		table.Partitions = append(table.Partitions, partition)
+	}
+
+	// leave room for the backup GPT at the end of the disk
+	needed := start*sectorSize + partitionAlign*sectorSize
+	if table.Partitions[len(table.Partitions)-1].Size == 0 {
+		needed += minLastPartition
+	}
+	if table.Size < needed {
+		table.Size = needed
+	}
+
+	return table, nil
+}
+
 func qemuAssembler(pt *disk.PartitionTable, format string, filename string, imageOptions distro.ImageOptions, arch distro.Arch) *osbuild.Assembler {
 	options := pt.QEMUAssemblerOptions()
 
@@ -825,7 +1315,8 @@ func newDistro(isCentos bool) distro.Distro {
 			"greenboot-rpm-ostree-grub2-check-fallback", "greenboot-status", "greenboot-task-runner",
 			"redboot-auto-reboot", "redboot-task-runner",
 		},
-		rpmOstree: true,
+		rpmOstree:  true,
+		noWeakDeps: true,
 		assembler: func(pt *disk.PartitionTable, options distro.ImageOptions, arch distro.Arch) *osbuild.Assembler {
 			return ostreeCommitAssembler(options, arch)
 		},
@@ -880,7 +1371,8 @@ func newDistro(isCentos bool) distro.Distro {
 			"greenboot-rpm-ostree-grub2-check-fallback", "greenboot-status", "greenboot-task-runner",
 			"redboot-auto-reboot", "redboot-task-runner",
 		},
-		rpmOstree: true,
+		rpmOstree:  true,
+		noWeakDeps: true,
 		assembler: func(pt *disk.PartitionTable, options distro.ImageOptions, arch distro.Arch) *osbuild.Assembler {
 			return ostreeCommitAssembler(options, arch)
 		},
@@ -1089,6 +1581,7 @@ func newDistro(isCentos bool) distro.Distro {
 			"rng-tools",
 		},
 		bootable:      false,
+		noWeakDeps:    true,
 		kernelOptions: "ro net.ifnames=0",
 		assembler: func(pt *disk.PartitionTable, options distro.ImageOptions, arch distro.Arch) *osbuild.Assembler {
 			return tarAssembler("root.tar.xz", "xz")
@@ -1,8 +1,12 @@
 package rhel84_test
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -10,6 +14,7 @@ import (
 	"github.com/osbuild/osbuild-composer/internal/distro"
 	"github.com/osbuild/osbuild-composer/internal/distro/distro_test_common"
 	"github.com/osbuild/osbuild-composer/internal/distro/rhel84"
+	"github.com/osbuild/osbuild-composer/internal/osbuild"
 )
 
 type rhelFamilyDistro struct {
@@ -407,11 +412,169 @@ func TestImageType_BasePackages(t *testing.T) {
 	}
 }
 
+func TestImageType_TimezonePackages(t *testing.T) {
+	ntpOnly := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Timezone: &blueprint.TimezoneCustomization{
+				NTPServers: []string{"time.example.com"},
+			},
+		},
+	}
+
+	for _, dist := range rhelFamilyDistros {
+		t.Run(dist.name, func(t *testing.T) {
+			arch, err := dist.distro.GetArch("x86_64")
+			require.NoError(t, err)
+			imgType, err := arch.GetImageType("openstack")
+			require.NoError(t, err)
+
+			packages, _ := imgType.Packages(blueprint.Blueprint{})
+			assert.NotContains(t, packages, "chrony")
+
+			packages, _ = imgType.Packages(ntpOnly)
+			assert.Contains(t, packages, "chrony")
+		})
+	}
+}
+
 func TestDistro_Manifest(t *testing.T) {
 	distro_test_common.TestDistro_Manifest(t, "../../../test/data/manifests/", "rhel_84*", rhel84.New())
 	distro_test_common.TestDistro_Manifest(t, "../../../test/data/manifests/", "centos_8*", rhel84.NewCentos())
 }
 
+func TestDistro_ManifestKernelAppendS390x(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Kernel: &blueprint.KernelCustomization{
+				Append: "nosmt",
+			},
+		},
+	}
+
+	arch, err := rhel84.New().GetArch("s390x")
+	require.NoError(t, err)
+	imgType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	manifest, err := imgType.Manifest(bp.Customizations, distro.ImageOptions{Size: imgType.Size(0)}, nil, nil, nil, 0)
+	require.NoError(t, err)
+
+	var m struct {
+		Pipeline struct {
+			Stages []struct {
+				Name    string `json:"name"`
+				Options struct {
+					KernelOpts string `json:"kernel_opts"`
+				} `json:"options"`
+			} `json:"stages"`
+		} `json:"pipeline"`
+	}
+	require.NoError(t, json.Unmarshal(manifest, &m))
+
+	found := false
+	for _, stage := range m.Pipeline.Stages {
+		if stage.Name == "org.osbuild.kernel-cmdline" {
+			found = true
+			assert.True(t, strings.HasSuffix(stage.Options.KernelOpts, " nosmt"), stage.Options.KernelOpts)
+		}
+	}
+	assert.True(t, found, "kernel-cmdline stage not found")
+}
+
+func TestDistro_ManifestFiles(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Directories: []blueprint.DirectoryCustomization{
+				{Path: "/etc/myapp", User: "root", Group: "wheel", Mode: "0750"},
+			},
+			Files: []blueprint.FileCustomization{
+				{Path: "/etc/myapp/config", Data: "aGVsbG8K", Encoding: "base64", Mode: "0640"},
+			},
+		},
+	}
+
+	arch, err := rhel84.New().GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	manifest, err := imgType.Manifest(bp.Customizations, distro.ImageOptions{Size: imgType.Size(0)}, nil, nil, nil, 0)
+	require.NoError(t, err)
+
+	var m osbuild.Manifest
+	require.NoError(t, json.Unmarshal(manifest, &m))
+
+	checksum := osbuild.InlineChecksum([]byte("hello\n"))
+	require.Contains(t, m.Sources, "org.osbuild.inline")
+	assert.Contains(t, m.Sources["org.osbuild.inline"].(*osbuild.InlineSource).Items, checksum)
+
+	stages := map[string]osbuild.StageOptions{}
+	for _, stage := range m.Pipeline.Stages {
+		stages[stage.Name] = stage.Options
+	}
+	assert.Equal(t, &osbuild.MkdirStageOptions{
+		Paths: []osbuild.MkdirStagePath{{Path: "/etc/myapp", ExistOk: true}},
+	}, stages["org.osbuild.mkdir"])
+	assert.Equal(t, &osbuild.CopyStageOptions{
+		Paths: []osbuild.CopyStagePath{{From: checksum, To: "/etc/myapp/config"}},
+	}, stages["org.osbuild.copy"])
+	assert.Equal(t, &osbuild.ChownStageOptions{
+		Items: map[string]osbuild.ChownStagePathOptions{"/etc/myapp": {User: "root", Group: "wheel"}},
+	}, stages["org.osbuild.chown"])
+	assert.Equal(t, &osbuild.ChmodStageOptions{
+		Items: map[string]osbuild.ChmodStagePathOptions{
+			"/etc/myapp":        {Mode: "0750"},
+			"/etc/myapp/config": {Mode: "0640"},
+		},
+	}, stages["org.osbuild.chmod"])
+}
+
+func TestDistro_ManifestOpenSCAP(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			OpenSCAP: &blueprint.OpenSCAPCustomization{
+				ProfileID: "xccdf_org.ssgproject.content_profile_cis",
+			},
+		},
+	}
+
+	for _, dist := range rhelFamilyDistros {
+		t.Run(dist.name, func(t *testing.T) {
+			arch, err := dist.distro.GetArch("x86_64")
+			require.NoError(t, err)
+			imgType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			packages, _ := imgType.Packages(bp)
+			assert.Contains(t, packages, "openscap-scanner")
+			assert.Contains(t, packages, "scap-security-guide")
+
+			manifest, err := imgType.Manifest(bp.Customizations, distro.ImageOptions{Size: imgType.Size(0)}, nil, nil, nil, 0)
+			require.NoError(t, err)
+
+			var m osbuild.Manifest
+			require.NoError(t, json.Unmarshal(manifest, &m))
+
+			datastream := "/usr/share/xml/scap/ssg/content/ssg-rhel8-ds.xml"
+			if dist.name == "centos" {
+				datastream = "/usr/share/xml/scap/ssg/content/ssg-centos8-ds.xml"
+			}
+			var options osbuild.StageOptions
+			for _, stage := range m.Pipeline.Stages {
+				if stage.Name == "org.osbuild.oscap.remediation" {
+					options = stage.Options
+				}
+			}
+			assert.Equal(t, &osbuild.OscapRemediationStageOptions{
+				Config: osbuild.OscapConfig{
+					Datastream: datastream,
+					ProfileID:  "xccdf_org.ssgproject.content_profile_cis",
+				},
+			}, options)
+		})
+	}
+}
+
 // Check that Manifest() function returns an error for unsupported
 // configurations.
 func TestDistro_ManifestError(t *testing.T) {
@@ -582,3 +745,431 @@ func TestRhel84_ModulePlatformID(t *testing.T) {
 	centos := rhel84.NewCentos()
 	assert.Equal(t, "platform:el8", centos.ModulePlatformID())
 }
+
+func TestDistro_ManifestIgnition(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Ignition: &blueprint.IgnitionCustomization{
+				FirstBoot: &blueprint.FirstBootIgnitionCustomization{
+					ProvisioningURL: "http://example.com/config.ign",
+				},
+			},
+		},
+	}
+
+	arch, err := rhel84.New().GetArch("x86_64")
+	require.NoError(t, err)
+
+	imgType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+	packages, _ := imgType.Packages(bp)
+	assert.Contains(t, packages, "ignition")
+
+	manifest, err := imgType.Manifest(bp.Customizations, distro.ImageOptions{Size: imgType.Size(0)}, nil, nil, nil, 0)
+	require.NoError(t, err)
+	var m osbuild.Manifest
+	require.NoError(t, json.Unmarshal(manifest, &m))
+	for _, stage := range m.Pipeline.Stages {
+		if stage.Name == "org.osbuild.grub2" {
+			assert.Contains(t, stage.Options.(*osbuild.GRUB2StageOptions).KernelOptions, "ignition.firstboot ignition.platform.id=metal ignition.config.url=http://example.com/config.ign")
+		}
+	}
+
+	for _, name := range []string{"tar", "rhel-edge-commit"} {
+		imgType, err := arch.GetImageType(name)
+		require.NoError(t, err)
+		_, err = imgType.Manifest(bp.Customizations, distro.ImageOptions{Size: imgType.Size(0)}, nil, nil, nil, 0)
+		assert.EqualError(t, err, "Ignition customizations are only supported for bootable, non-ostree image types")
+	}
+}
+
+func TestDistro_ManifestContainers(t *testing.T) {
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	containers := []blueprint.Container{
+		{Source: "registry.example.com/app@" + digest, Name: "localhost/app"},
+	}
+
+	arch, err := rhel84.New().GetArch("x86_64")
+	require.NoError(t, err)
+
+	imgType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+	manifest, err := imgType.Manifest(nil, distro.ImageOptions{Size: imgType.Size(0), Containers: containers}, nil, nil, nil, 0)
+	require.NoError(t, err)
+	var m osbuild.Manifest
+	require.NoError(t, json.Unmarshal(manifest, &m))
+
+	skopeo := m.Sources["org.osbuild.skopeo"].(*osbuild.SkopeoSource)
+	assert.Equal(t, "registry.example.com/app", skopeo.Items[digest].Image.Name)
+
+	var found bool
+	for _, stage := range m.Pipeline.Stages {
+		if stage.Name == "org.osbuild.skopeo" {
+			found = true
+			assert.Equal(t, []osbuild.SkopeoStageImage{{Digest: digest, Name: "localhost/app"}}, stage.Options.(*osbuild.SkopeoStageOptions).Images)
+		}
+	}
+	assert.True(t, found)
+
+	imgType, err = arch.GetImageType("rhel-edge-commit")
+	require.NoError(t, err)
+	_, err = imgType.Manifest(nil, distro.ImageOptions{Size: imgType.Size(0), Containers: containers}, nil, nil, nil, 0)
+	assert.EqualError(t, err, "embedding containers is not supported for ostree types")
+}
+
+func TestDistro_ManifestBuildInfo(t *testing.T) {
+	arch, err := rhel84.New().GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	buildInfo := distro.NewBuildInfo(uuid.New(), "base", "0.0.1", imgType, nil, time.Now())
+	content, err := buildInfo.Content()
+	require.NoError(t, err)
+
+	manifest, err := imgType.Manifest(nil, distro.ImageOptions{Size: imgType.Size(0), BuildInfo: buildInfo}, nil, nil, nil, 0)
+	require.NoError(t, err)
+	var m osbuild.Manifest
+	require.NoError(t, json.Unmarshal(manifest, &m))
+
+	checksum := osbuild.InlineChecksum(content)
+	inline := m.Sources["org.osbuild.inline"].(*osbuild.InlineSource)
+	assert.Contains(t, inline.Items, checksum)
+
+	var found bool
+	for _, stage := range m.Pipeline.Stages {
+		if stage.Name == "org.osbuild.copy" {
+			found = true
+			assert.Equal(t, []osbuild.CopyStagePath{{From: checksum, To: distro.BuildInfoPath}}, stage.Options.(*osbuild.CopyStageOptions).Paths)
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestImageType_ExcludedPackages(t *testing.T) {
+	arch, err := rhel84.New().GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := arch.GetImageType("ami")
+	require.NoError(t, err)
+
+	_, defaultExcludes := imgType.Packages(blueprint.Blueprint{})
+	_, excludes := imgType.Packages(blueprint.Blueprint{Excludes: []string{"plymouth"}})
+	assert.Equal(t, append([]string{"plymouth"}, defaultExcludes...), excludes)
+
+	// the image type's list must not be modified
+	_, excludes = imgType.Packages(blueprint.Blueprint{})
+	assert.Equal(t, defaultExcludes, excludes)
+}
+
+func TestDistro_ManifestEnabledModules(t *testing.T) {
+	modules := []blueprint.ModuleStream{{Name: "nodejs", Stream: "16"}}
+
+	arch, err := rhel84.New().GetArch("x86_64")
+	require.NoError(t, err)
+
+	imgType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+	manifest, err := imgType.Manifest(nil, distro.ImageOptions{Size: imgType.Size(0), EnabledModules: modules}, nil, nil, nil, 0)
+	require.NoError(t, err)
+	var m osbuild.Manifest
+	require.NoError(t, json.Unmarshal(manifest, &m))
+
+	var found bool
+	for _, stage := range m.Pipeline.Stages {
+		if stage.Name == "org.osbuild.dnf.module-config" {
+			found = true
+			assert.Equal(t, osbuild.DNFModuleConfig{
+				Name:     "nodejs",
+				Stream:   "16",
+				State:    "enabled",
+				Profiles: []string{},
+			}, stage.Options.(*osbuild.DNFModuleConfigStageOptions).Conf)
+		}
+	}
+	assert.True(t, found)
+
+	imgType, err = arch.GetImageType("rhel-edge-commit")
+	require.NoError(t, err)
+	_, err = imgType.Manifest(nil, distro.ImageOptions{Size: imgType.Size(0), EnabledModules: modules}, nil, nil, nil, 0)
+	assert.EqualError(t, err, "enabling module streams is not supported for ostree types")
+}
+
+func TestDistro_ManifestSubscription(t *testing.T) {
+	arch, err := rhel84.New().GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	manifest, err := imgType.Manifest(nil, distro.ImageOptions{
+		Size: imgType.Size(0),
+		Subscription: &distro.SubscriptionImageOptions{
+			Organization:  123,
+			ActivationKey: "key",
+			APIType:       "weldr",
+		},
+	}, nil, nil, nil, 0)
+	require.NoError(t, err)
+	var m osbuild.Manifest
+	require.NoError(t, json.Unmarshal(manifest, &m))
+
+	stages := map[string]osbuild.StageOptions{}
+	for _, stage := range m.Pipeline.Stages {
+		stages[stage.Name] = stage.Options
+	}
+	assert.Equal(t, []string{"/usr/sbin/subscription-manager register --org=123 --activationkey=key"}, stages["org.osbuild.first-boot"].(*osbuild.FirstBootStageOptions).Commands)
+	assert.True(t, *stages["org.osbuild.rhsm"].(*osbuild.RHSMStageOptions).SubMan.Rhsm.ManageRepos)
+	assert.Equal(t, "weldr", stages["org.osbuild.rhsm.facts"].(*osbuild.RHSMFactsStageOptions).Facts.APIType)
+}
+
+func TestDistro_ManifestFirstBoot(t *testing.T) {
+	c := &blueprint.Customizations{
+		FirstBoot: &blueprint.FirstBootCustomization{
+			Scripts: []blueprint.FirstBootScript{{Name: "setup.sh", Data: "#!/bin/bash\necho hello\n"}},
+		},
+	}
+
+	arch, err := rhel84.New().GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	manifest, err := imgType.Manifest(c, distro.ImageOptions{
+		Size: imgType.Size(0),
+		Subscription: &distro.SubscriptionImageOptions{
+			Organization:  123,
+			ActivationKey: "key",
+		},
+	}, nil, nil, nil, 0)
+	require.NoError(t, err)
+	var m osbuild.Manifest
+	require.NoError(t, json.Unmarshal(manifest, &m))
+
+	var firstBootStages []*osbuild.FirstBootStageOptions
+	for _, stage := range m.Pipeline.Stages {
+		if stage.Name == "org.osbuild.first-boot" {
+			firstBootStages = append(firstBootStages, stage.Options.(*osbuild.FirstBootStageOptions))
+		}
+	}
+	assert.Equal(t, []*osbuild.FirstBootStageOptions{{
+		Commands: []string{
+			"/usr/sbin/subscription-manager register --org=123 --activationkey=key",
+			"/usr/local/libexec/first-boot/setup.sh",
+		},
+		WaitForNetwork: true,
+	}}, firstBootStages)
+
+	imgType, err = arch.GetImageType("rhel-edge-commit")
+	require.NoError(t, err)
+	_, err = imgType.Manifest(c, distro.ImageOptions{Size: imgType.Size(0)}, nil, nil, nil, 0)
+	assert.EqualError(t, err, "first-boot scripts are not supported for ostree types")
+}
+
+func TestDistro_ManifestBootloader(t *testing.T) {
+	timeout := 3
+	c := &blueprint.Customizations{
+		Bootloader: &blueprint.BootloaderCustomization{
+			Timeout: &timeout,
+			Serial:  &blueprint.SerialConsoleCustomization{Device: "ttyS0", Speed: 9600},
+		},
+	}
+
+	arch, err := rhel84.New().GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	manifest, err := imgType.Manifest(c, distro.ImageOptions{Size: imgType.Size(0)}, nil, nil, nil, 0)
+	require.NoError(t, err)
+	var m osbuild.Manifest
+	require.NoError(t, json.Unmarshal(manifest, &m))
+
+	var found bool
+	for _, stage := range m.Pipeline.Stages {
+		if stage.Name == "org.osbuild.grub2" {
+			found = true
+			options := stage.Options.(*osbuild.GRUB2StageOptions)
+			assert.True(t, strings.HasSuffix(options.KernelOptions, " console=ttyS0,9600n8"))
+			assert.Equal(t, &osbuild.GRUB2Config{
+				Timeout:        &timeout,
+				TerminalInput:  []string{"serial", "console"},
+				TerminalOutput: []string{"serial", "console"},
+				Serial:         "serial --unit=0 --speed=9600",
+			}, options.Config)
+		}
+	}
+	assert.True(t, found)
+
+	for _, name := range []string{"tar", "rhel-edge-commit"} {
+		imgType, err := arch.GetImageType(name)
+		require.NoError(t, err)
+		_, err = imgType.Manifest(c, distro.ImageOptions{Size: imgType.Size(0)}, nil, nil, nil, 0)
+		assert.EqualError(t, err, "bootloader customizations are only supported for bootable image types using GRUB")
+	}
+}
+
+func TestDistro_ManifestCustomDisk(t *testing.T) {
+	c := &blueprint.Customizations{
+		Disk: &blueprint.DiskCustomization{
+			Partitions: []blueprint.PartitionCustomization{
+				{Size: "1 MiB", Type: "bios-boot"},
+				{Size: "200 MiB", Mountpoint: "/boot/efi", FSType: "vfat"},
+				{Size: "1 GiB", Mountpoint: "/boot", FSType: "ext4"},
+				{Size: "4 GiB", Mountpoint: "/", FSType: "xfs", Label: "root"},
+				{Mountpoint: "/var", FSType: "xfs"},
+			},
+		},
+	}
+
+	arch, err := rhel84.New().GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	manifest, err := imgType.Manifest(c, distro.ImageOptions{Size: imgType.Size(0)}, nil, nil, nil, 0)
+	require.NoError(t, err)
+	var m osbuild.Manifest
+	require.NoError(t, json.Unmarshal(manifest, &m))
+
+	options := m.Pipeline.Assembler.Options.(*osbuild.QEMUAssemblerOptions)
+	assert.Equal(t, "gpt", options.PTType)
+	require.Len(t, options.Partitions, 5)
+	assert.Equal(t, uint64(2048), options.Partitions[0].Start)
+	assert.Equal(t, "21686148-6449-6E6F-744E-656564454649", options.Partitions[0].Type)
+	assert.True(t, options.Partitions[0].Bootable)
+	assert.Equal(t, uint64(4096), options.Partitions[1].Start)
+	assert.Equal(t, uint64(409600), options.Partitions[1].Size)
+	assert.Equal(t, "/boot", options.Partitions[2].Filesystem.Mountpoint)
+	assert.Equal(t, uint64(2097152), options.Partitions[2].Size)
+	assert.Equal(t, "/var", options.Partitions[4].Filesystem.Mountpoint)
+	assert.Equal(t, uint64(0), options.Partitions[4].Size)
+
+	var foundFSTab, foundGRUB bool
+	for _, stage := range m.Pipeline.Stages {
+		switch stage.Name {
+		case "org.osbuild.fstab":
+			foundFSTab = true
+			assert.Len(t, stage.Options.(*osbuild.FSTabStageOptions).FileSystems, 4)
+		case "org.osbuild.grub2":
+			foundGRUB = true
+			grub := stage.Options.(*osbuild.GRUB2StageOptions)
+			assert.Equal(t, options.Partitions[3].Filesystem.UUID, grub.RootFilesystemUUID.String())
+			require.NotNil(t, grub.BootFilesystemUUID)
+			assert.Equal(t, options.Partitions[2].Filesystem.UUID, grub.BootFilesystemUUID.String())
+		}
+	}
+	assert.True(t, foundFSTab)
+	assert.True(t, foundGRUB)
+
+	// the firmware partitions of the image type are required
+	noESP := &blueprint.Customizations{
+		Disk: &blueprint.DiskCustomization{
+			Partitions: []blueprint.PartitionCustomization{
+				{Size: "1 MiB", Type: "bios-boot"},
+				{Mountpoint: "/", FSType: "xfs"},
+			},
+		},
+	}
+	_, err = imgType.Manifest(noESP, distro.ImageOptions{Size: imgType.Size(0)}, nil, nil, nil, 0)
+	assert.EqualError(t, err, "the partition table needs a partition of type esp for this image type")
+
+	dos := &blueprint.Customizations{Disk: &blueprint.DiskCustomization{Type: "dos", Partitions: c.Disk.Partitions}}
+	_, err = imgType.Manifest(dos, distro.ImageOptions{Size: imgType.Size(0)}, nil, nil, nil, 0)
+	assert.EqualError(t, err, "the partition table must be of type gpt for this image type")
+
+	imgType, err = arch.GetImageType("tar")
+	require.NoError(t, err)
+	_, err = imgType.Manifest(c, distro.ImageOptions{Size: imgType.Size(0)}, nil, nil, nil, 0)
+	assert.EqualError(t, err, "disk customizations are not supported for tar")
+}
+
+func TestDistro_ManifestCACerts(t *testing.T) {
+	cert := `-----BEGIN CERTIFICATE-----
+MIIBjTCCATOgAwIBAgIUTlarKT0+1A1h4x+7F/Agz8Zw9AowCgYIKoZIzj0EAwIw
+GzEZMBcGA1UEAwwQRXhhbXBsZSBQcm94eSBDQTAgFw0yNjEwMTUxMDUzNTJaGA8y
+MTI2MDkyMTEwNTM1MlowGzEZMBcGA1UEAwwQRXhhbXBsZSBQcm94eSBDQTBZMBMG
+ByqGSM49AgEGCCqGSM49AwEHA0IABFRZ7L9RAVcOESU4wl1NDM4x1Q9HGI9Ic1CY
+G1I+sxw8ZHZYsAvV/mtJOt48bpbZ6QB17IPHbXGRppJ/409iVeqjUzBRMB0GA1Ud
+DgQWBBSUhIfUp2eDsvlihgOzc/CWiUhX8jAfBgNVHSMEGDAWgBSUhIfUp2eDsvli
+hgOzc/CWiUhX8jAPBgNVHRMBAf8EBTADAQH/MAoGCCqGSM49BAMCA0gAMEUCIQDE
+cp3q5mUwIov723BLTLhY30W4HDiMdUg3T9PhuIEDAgIgd0yBkYnIELY8/xq+vSm9
+uDz9jvspKmLS6DcmYpecGZw=
+-----END CERTIFICATE-----
+`
+	c := &blueprint.Customizations{CACerts: &blueprint.CACustomization{PEMCerts: []string{cert}}}
+
+	arch, err := rhel84.New().GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	manifest, err := imgType.Manifest(c, distro.ImageOptions{Size: imgType.Size(0)}, nil, nil, nil, 0)
+	require.NoError(t, err)
+	var m osbuild.Manifest
+	require.NoError(t, json.Unmarshal(manifest, &m))
+
+	var copied []string
+	var copyIndex, trustIndex int
+	for i, stage := range m.Pipeline.Stages {
+		switch stage.Name {
+		case "org.osbuild.copy":
+			copyIndex = i
+			for _, path := range stage.Options.(*osbuild.CopyStageOptions).Paths {
+				copied = append(copied, path.To)
+			}
+		case "org.osbuild.pki.update-ca-trust":
+			trustIndex = i
+		}
+	}
+	assert.Equal(t, []string{"/etc/pki/ca-trust/source/anchors/e79944fb7b650f26.pem"}, copied)
+	assert.Greater(t, trustIndex, copyIndex)
+}
+
+func TestDistro_ManifestDNFConfig(t *testing.T) {
+	weakDeps := false
+	c := &blueprint.Customizations{DNF: &blueprint.DNFCustomization{
+		InstallWeakDeps: &weakDeps,
+		Exclude:         []string{"kernel-debug*"},
+		Proxy:           "http://proxy.example.com:3128",
+	}}
+
+	arch, err := rhel84.New().GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	manifest, err := imgType.Manifest(c, distro.ImageOptions{Size: imgType.Size(0)}, nil, nil, nil, 0)
+	require.NoError(t, err)
+	var m osbuild.Manifest
+	require.NoError(t, json.Unmarshal(manifest, &m))
+
+	var options []*osbuild.DNFConfigStageOptions
+	for _, stage := range m.Pipeline.Stages {
+		if stage.Name == "org.osbuild.dnf.config" {
+			options = append(options, stage.Options.(*osbuild.DNFConfigStageOptions))
+		}
+	}
+	require.Len(t, options, 1)
+	assert.Equal(t, &osbuild.DNFConfigMain{
+		InstallWeakDeps: &weakDeps,
+		Exclude:         []string{"kernel-debug*"},
+		Proxy:           "http://proxy.example.com:3128",
+	}, options[0].Config.Main)
+}
+
+func TestImageType_InstallWeakDeps(t *testing.T) {
+	minimal := map[string]bool{
+		"rhel-edge-commit": true,
+		"tar":              true,
+	}
+	for _, d := range []distro.Distro{rhel84.New(), rhel84.NewCentos()} {
+		for _, archLabel := range d.ListArches() {
+			arch, err := d.GetArch(archLabel)
+			require.NoError(t, err)
+			for _, itLabel := range arch.ListImageTypes() {
+				it, err := arch.GetImageType(itLabel)
+				require.NoError(t, err)
+				assert.Equalf(t, !minimal[itLabel], it.InstallWeakDeps(), "%s/%s/%s", d.Name(), archLabel, itLabel)
+			}
+		}
+	}
+}
@@ -75,6 +75,10 @@ func (t *TestImageType) BuildPackages() []string {
 	return nil
 }
 
+func (t *TestImageType) InstallWeakDeps() bool {
+	return true
+}
+
 func (t *TestImageType) Manifest(b *blueprint.Customizations, options distro.ImageOptions, repos []rpmmd.RepoConfig, packageSpecs, buildPackageSpecs []rpmmd.PackageSpec, seed int64) (distro.Manifest, error) {
 	return json.Marshal(
 		osbuild.Manifest{
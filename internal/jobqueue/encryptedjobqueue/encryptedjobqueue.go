@@ -0,0 +1,143 @@
+// Package encryptedjobqueue wraps a job queue so that the arguments of jobs,
+// which contain the credentials of upload targets, are encrypted before they
+// are stored. It implements the interfaces in package jobqueue.
+//
+// Arguments are encrypted with AES-256-GCM and decrypted again when jobs are
+// dequeued or read. The id of the job is authenticated together with its
+// arguments, so that they cannot be moved to another job. Arguments of jobs
+// which were enqueued without encryption are returned as they are, so that
+// existing queues keep working.
+package encryptedjobqueue
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/osbuild/osbuild-composer/internal/jobqueue"
+)
+
+// KeySize is the size of the keys in bytes
+const KeySize = 32
+
+type encryptedJobQueue struct {
+	jobqueue.JobQueue
+	enqueuer jobqueue.IDEnqueuer
+	aead     cipher.AEAD
+}
+
+// The arguments of a job as they are stored in the wrapped queue: the nonce
+// followed by the encrypted JSON of the arguments
+type sealedArgs struct {
+	Sealed []byte `json:"sealed"`
+}
+
+// New wraps a job queue, encrypting the arguments of its jobs with `key`,
+// which must be KeySize bytes long. The queue must implement
+// jobqueue.IDEnqueuer, because the id of a job is needed to encrypt its
+// arguments.
+func New(queue jobqueue.JobQueue, key []byte) (jobqueue.JobQueue, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("the key must be %d bytes long", KeySize)
+	}
+	enqueuer, ok := queue.(jobqueue.IDEnqueuer)
+	if !ok {
+		return nil, errors.New("the job queue cannot enqueue jobs with a given id")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedJobQueue{queue, enqueuer, aead}, nil
+}
+
+// ReadKey reads a key from a file, which contains it encoded in base64, such
+// as the output of `head -c 32 /dev/urandom | base64`
+func ReadKey(filename string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("the key in %s is not base64 encoded: %v", filename, err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("the key in %s must be %d bytes long", filename, KeySize)
+	}
+	return key, nil
+}
+
+func (q *encryptedJobQueue) Enqueue(jobType string, args interface{}, dependencies []uuid.UUID, channel string) (uuid.UUID, error) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	nonce := make([]byte, q.aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("cannot generate nonce: %v", err)
+	}
+
+	id := uuid.New()
+	err = q.enqueuer.EnqueueWithID(id, jobType, sealedArgs{q.aead.Seal(nonce, nonce, data, id[:])}, dependencies, channel)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return id, nil
+}
+
+func (q *encryptedJobQueue) Dequeue(ctx context.Context, jobTypes []string, channels []string) (uuid.UUID, []uuid.UUID, string, json.RawMessage, error) {
+	id, dependencies, jobType, args, err := q.JobQueue.Dequeue(ctx, jobTypes, channels)
+	if err != nil {
+		return id, dependencies, jobType, args, err
+	}
+
+	args, err = q.open(id, args)
+	if err != nil {
+		return uuid.Nil, nil, "", nil, fmt.Errorf("cannot decrypt the arguments of job %s: %v", id, err)
+	}
+	return id, dependencies, jobType, args, nil
+}
+
+func (q *encryptedJobQueue) Job(id uuid.UUID) (string, json.RawMessage, []uuid.UUID, string, error) {
+	jobType, args, dependencies, channel, err := q.JobQueue.Job(id)
+	if err != nil {
+		return jobType, args, dependencies, channel, err
+	}
+
+	args, err = q.open(id, args)
+	if err != nil {
+		return "", nil, nil, "", fmt.Errorf("cannot decrypt the arguments of job %s: %v", id, err)
+	}
+	return jobType, args, dependencies, channel, nil
+}
+
+// open decrypts the arguments of the job with `id`, unless they were not
+// encrypted
+func (q *encryptedJobQueue) open(id uuid.UUID, args json.RawMessage) (json.RawMessage, error) {
+	var sealed sealedArgs
+	if json.Unmarshal(args, &sealed) != nil || sealed.Sealed == nil {
+		return args, nil
+	}
+
+	nonceSize := q.aead.NonceSize()
+	if len(sealed.Sealed) < nonceSize {
+		return nil, errors.New("the arguments are too short")
+	}
+	return q.aead.Open(nil, sealed.Sealed[:nonceSize], sealed.Sealed[nonceSize:], id[:])
+}
@@ -0,0 +1,118 @@
+package encryptedjobqueue_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/osbuild-composer/internal/jobqueue/encryptedjobqueue"
+	"github.com/osbuild/osbuild-composer/internal/jobqueue/fsjobqueue"
+)
+
+type testArgs struct {
+	Secret string `json:"secret"`
+}
+
+func TestEncryptedArgs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobqueue-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fs, err := fsjobqueue.New(dir)
+	require.NoError(t, err)
+	key := bytes.Repeat([]byte{1}, encryptedjobqueue.KeySize)
+	q, err := encryptedjobqueue.New(fs, key)
+	require.NoError(t, err)
+
+	plain, err := fs.Enqueue("plain", testArgs{"before"}, nil, "")
+	require.NoError(t, err)
+	id, err := q.Enqueue("sealed", testArgs{"hunter2"}, nil, "")
+	require.NoError(t, err)
+
+	stored, err := ioutil.ReadFile(filepath.Join(dir, id.String()+".json"))
+	require.NoError(t, err)
+	require.NotContains(t, string(stored), "hunter2")
+
+	_, args, _, _, err := q.Job(id)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"secret": "hunter2"}`, string(args))
+
+	_, args, _, _, err = q.Job(plain)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"secret": "before"}`, string(args))
+
+	dequeued, _, jobType, args, err := q.Dequeue(context.Background(), []string{"sealed"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, id, dequeued)
+	require.Equal(t, "sealed", jobType)
+	var parsed testArgs
+	require.NoError(t, json.Unmarshal(args, &parsed))
+	require.Equal(t, "hunter2", parsed.Secret)
+
+	other, err := encryptedjobqueue.New(fs, bytes.Repeat([]byte{2}, encryptedjobqueue.KeySize))
+	require.NoError(t, err)
+	_, _, _, _, err = other.Job(id)
+	require.Error(t, err)
+
+	_, err = encryptedjobqueue.New(fs, []byte("short"))
+	require.EqualError(t, err, "the key must be 32 bytes long")
+}
+
+func TestMovedArgs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobqueue-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fs, err := fsjobqueue.New(dir)
+	require.NoError(t, err)
+	q, err := encryptedjobqueue.New(fs, bytes.Repeat([]byte{1}, encryptedjobqueue.KeySize))
+	require.NoError(t, err)
+
+	admin, err := q.Enqueue("sealed", testArgs{"admin"}, nil, "")
+	require.NoError(t, err)
+	user, err := q.Enqueue("sealed", testArgs{"user"}, nil, "")
+	require.NoError(t, err)
+
+	// copy the sealed arguments of one job into the record of the other
+	readJob := func(id uuid.UUID) map[string]json.RawMessage {
+		data, err := ioutil.ReadFile(filepath.Join(dir, id.String()+".json"))
+		require.NoError(t, err)
+		var j map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(data, &j))
+		return j
+	}
+	j := readJob(user)
+	j["args"] = readJob(admin)["args"]
+	data, err := json.Marshal(j)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, user.String()+".json"), data, 0600))
+
+	_, _, _, _, err = q.Job(user)
+	require.Error(t, err)
+	_, args, _, _, err := q.Job(admin)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"secret": "admin"}`, string(args))
+}
+
+func TestReadKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobqueue-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "key")
+	require.NoError(t, ioutil.WriteFile(filename, []byte("AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=\n"), 0600))
+	key, err := encryptedjobqueue.ReadKey(filename)
+	require.NoError(t, err)
+	require.Equal(t, bytes.Repeat([]byte{1}, encryptedjobqueue.KeySize), key)
+
+	require.NoError(t, ioutil.WriteFile(filename, []byte("AQEB"), 0600))
+	_, err = encryptedjobqueue.ReadKey(filename)
+	require.EqualError(t, err, "the key in "+filename+" must be 32 bytes long")
+}
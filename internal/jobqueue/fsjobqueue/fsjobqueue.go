@@ -14,9 +14,7 @@ package fsjobqueue
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"reflect"
 	"sync"
 	"time"
 
@@ -29,13 +27,18 @@ type fsJobQueue struct {
 	// Protects all fields of this struct. In particular, it ensures
 	// transactions on `db` are atomic. All public functions except
 	// JobStatus hold it while they're running. Dequeue() releases it
-	// briefly while waiting on pending channels.
+	// while waiting for pending jobs.
 	mu sync.Mutex
 
 	db *jsondb.JSONDatabase
 
-	// Maps job types to channels of job ids for that type.
-	pending map[string]chan uuid.UUID
+	// Jobs whose dependencies have finished, in the order they became
+	// ready to run.
+	pending []pendingJob
+
+	// Closed and replaced whenever a job is added to `pending`, to wake up
+	// the Dequeue() calls waiting for one.
+	pendingAdded chan struct{}
 
 	// Maps job ids to the jobs that depend on it, if any of those
 	// dependants have not yet finished.
@@ -57,20 +60,25 @@ type job struct {
 	FinishedAt time.Time `json:"finished_at,omitempty"`
 
 	Canceled bool `json:"canceled,omitempty"`
+
+	Channel string `json:"channel,omitempty"`
 }
 
-// The size of channels used in fsJobQueue for queueing jobs.
-// Note that each job type has its own queue.
-const channelSize = 100
+// The parameters of a pending job which Dequeue() selects jobs by
+type pendingJob struct {
+	id      uuid.UUID
+	jobType string
+	channel string
+}
 
 // Create a new fsJobQueue object for `dir`. This object must have exclusive
 // access to `dir`. If `dir` contains jobs created from previous runs, they are
 // loaded and rescheduled to run if necessary.
 func New(dir string) (*fsJobQueue, error) {
 	q := &fsJobQueue{
-		db:         jsondb.New(dir, 0600),
-		pending:    make(map[string]chan uuid.UUID),
-		dependants: make(map[uuid.UUID][]uuid.UUID),
+		db:           jsondb.New(dir, 0600),
+		pendingAdded: make(chan struct{}),
+		dependants:   make(map[uuid.UUID][]uuid.UUID),
 	}
 
 	// Look for jobs that are still pending and build the dependant map.
@@ -96,21 +104,39 @@ func New(dir string) (*fsJobQueue, error) {
 	return q, nil
 }
 
-func (q *fsJobQueue) Enqueue(jobType string, args interface{}, dependencies []uuid.UUID) (uuid.UUID, error) {
+func (q *fsJobQueue) Enqueue(jobType string, args interface{}, dependencies []uuid.UUID, channel string) (uuid.UUID, error) {
+	id := uuid.New()
+	err := q.EnqueueWithID(id, jobType, args, dependencies, channel)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return id, nil
+}
+
+func (q *fsJobQueue) EnqueueWithID(id uuid.UUID, jobType string, args interface{}, dependencies []uuid.UUID, channel string) error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
 	var j = job{
-		Id:           uuid.New(),
+		Id:           id,
 		Type:         jobType,
 		Dependencies: dependencies,
 		QueuedAt:     time.Now(),
+		Channel:      channel,
 	}
 
 	var err error
 	j.Args, err = json.Marshal(args)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("error marshaling job arguments: %v", err)
+		return fmt.Errorf("error marshaling job arguments: %v", err)
+	}
+
+	exists, err := q.db.Read(j.Id.String(), nil)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("job %s already exists", j.Id)
 	}
 
 	// Verify dependendencies early, so that the job doesn't get written
@@ -118,10 +144,10 @@ func (q *fsJobQueue) Enqueue(jobType string, args interface{}, dependencies []uu
 	for _, d := range j.Dependencies {
 		exists, err := q.db.Read(d.String(), nil)
 		if err != nil {
-			return uuid.Nil, err
+			return err
 		}
 		if !exists {
-			return uuid.Nil, jobqueue.ErrNotExist
+			return jobqueue.ErrNotExist
 		}
 	}
 
@@ -129,18 +155,13 @@ func (q *fsJobQueue) Enqueue(jobType string, args interface{}, dependencies []uu
 	// doesn't become corrupt when writing fails.
 	err = q.db.Write(j.Id.String(), j)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("cannot write job: %v:", err)
-	}
-
-	err = q.maybeEnqueue(&j, true)
-	if err != nil {
-		return uuid.Nil, err
+		return fmt.Errorf("cannot write job: %v:", err)
 	}
 
-	return j.Id, nil
+	return q.maybeEnqueue(&j, true)
 }
 
-func (q *fsJobQueue) Dequeue(ctx context.Context, jobTypes []string) (uuid.UUID, []uuid.UUID, string, json.RawMessage, error) {
+func (q *fsJobQueue) Dequeue(ctx context.Context, jobTypes []string, channels []string) (uuid.UUID, []uuid.UUID, string, json.RawMessage, error) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
@@ -149,40 +170,29 @@ func (q *fsJobQueue) Dequeue(ctx context.Context, jobTypes []string) (uuid.UUID,
 		return uuid.Nil, nil, "", nil, err
 	}
 
-	// Filter q.pending by the `jobTypes`. Ignore those job types that this
-	// queue doesn't accept.
-	chans := []chan uuid.UUID{}
-	for _, jt := range jobTypes {
-		c, exists := q.pending[jt]
-		if !exists {
-			c = make(chan uuid.UUID, channelSize)
-			q.pending[jt] = c
-		}
-		chans = append(chans, c)
-	}
-
 	// Loop until finding a non-canceled job.
 	var j *job
 	for {
-		// Unlock the mutex while polling channels, so that multiple goroutines
-		// can wait at the same time.
-		q.mu.Unlock()
-		id, err := selectUUIDChannel(ctx, chans)
-		q.mu.Lock()
-
-		// Delete empty channels
-		for _, jt := range jobTypes {
-			c, exists := q.pending[jt]
-			if exists && len(c) == 0 {
-				close(c)
-				delete(q.pending, jt)
+		i := q.nextPending(jobTypes, channels)
+		if i < 0 {
+			// Unlock the mutex while waiting, so that multiple
+			// goroutines can wait at the same time.
+			added := q.pendingAdded
+			q.mu.Unlock()
+			select {
+			case <-added:
+				q.mu.Lock()
+				continue
+			case <-ctx.Done():
+				q.mu.Lock()
+				return uuid.Nil, nil, "", nil, ctx.Err()
 			}
 		}
 
-		if err != nil {
-			return uuid.Nil, nil, "", nil, err
-		}
+		id := q.pending[i].id
+		q.pending = append(q.pending[:i], q.pending[i+1:]...)
 
+		var err error
 		j, err = q.readJob(id)
 		if err != nil {
 			return uuid.Nil, nil, "", nil, err
@@ -287,7 +297,7 @@ func (q *fsJobQueue) JobStatus(id uuid.UUID) (result json.RawMessage, queued, st
 	return
 }
 
-func (q *fsJobQueue) Job(id uuid.UUID) (jobType string, args json.RawMessage, dependencies []uuid.UUID, err error) {
+func (q *fsJobQueue) Job(id uuid.UUID) (jobType string, args json.RawMessage, dependencies []uuid.UUID, channel string, err error) {
 	j, err := q.readJob(id)
 	if err != nil {
 		return
@@ -296,10 +306,45 @@ func (q *fsJobQueue) Job(id uuid.UUID) (jobType string, args json.RawMessage, de
 	jobType = j.Type
 	args = j.Args
 	dependencies = j.Dependencies
+	channel = j.Channel
 
 	return
 }
 
+// PendingJobs returns the number of pending jobs, which includes canceled
+// jobs until a worker tries to dequeue them.
+func (q *fsJobQueue) PendingJobs() map[string]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	pending := make(map[string]int)
+	for _, p := range q.pending {
+		pending[p.jobType]++
+	}
+	return pending
+}
+
+// Returns the index of the first pending job with one of `jobTypes` in one of
+// `channels`, or in any channel if `channels` is empty, or -1 if there is none.
+// `q.mu` must be locked when this method is called.
+func (q *fsJobQueue) nextPending(jobTypes []string, channels []string) int {
+	for i, p := range q.pending {
+		if contains(jobTypes, p.jobType) && (len(channels) == 0 || contains(channels, p.channel)) {
+			return i
+		}
+	}
+	return -1
+}
+
+func contains(slice []string, s string) bool {
+	for _, e := range slice {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
 // Reads job with `id`. This is a thin wrapper around `q.db.Read`, which
 // returns the job directly, or and error if a job with `id` does not exist.
 func (q *fsJobQueue) readJob(id uuid.UUID) (*job, error) {
@@ -338,12 +383,9 @@ func (q *fsJobQueue) maybeEnqueue(j *job, updateDependants bool) error {
 	}
 
 	if depsFinished {
-		c, exists := q.pending[j.Type]
-		if !exists {
-			c = make(chan uuid.UUID, channelSize)
-			q.pending[j.Type] = c
-		}
-		c <- j.Id
+		q.pending = append(q.pending, pendingJob{j.Id, j.Type, j.Channel})
+		close(q.pendingAdded)
+		q.pendingAdded = make(chan struct{})
 	} else if updateDependants {
 		for _, id := range j.Dependencies {
 			q.dependants[id] = append(q.dependants[id], j.Id)
@@ -352,34 +394,3 @@ func (q *fsJobQueue) maybeEnqueue(j *job, updateDependants bool) error {
 
 	return nil
 }
-
-// Select on a list of `chan uuid.UUID`s. Returns an error if one of the
-// channels is closed.
-//
-// Uses reflect.Select(), because the `select` statement cannot operate on an
-// unknown amount of channels.
-func selectUUIDChannel(ctx context.Context, chans []chan uuid.UUID) (uuid.UUID, error) {
-	cases := []reflect.SelectCase{
-		{
-			Dir:  reflect.SelectRecv,
-			Chan: reflect.ValueOf(ctx.Done()),
-		},
-	}
-	for _, c := range chans {
-		cases = append(cases, reflect.SelectCase{
-			Dir:  reflect.SelectRecv,
-			Chan: reflect.ValueOf(c),
-		})
-	}
-
-	chosen, value, recvOK := reflect.Select(cases)
-	if !recvOK {
-		if chosen == 0 {
-			return uuid.Nil, ctx.Err()
-		} else {
-			return uuid.Nil, errors.New("channel was closed unexpectedly")
-		}
-	}
-
-	return value.Interface().(uuid.UUID), nil
-}
@@ -36,14 +36,14 @@ func newTemporaryQueue(t *testing.T) (jobqueue.JobQueue, string) {
 
 func pushTestJob(t *testing.T, q jobqueue.JobQueue, jobType string, args interface{}, dependencies []uuid.UUID) uuid.UUID {
 	t.Helper()
-	id, err := q.Enqueue(jobType, args, dependencies)
+	id, err := q.Enqueue(jobType, args, dependencies, "")
 	require.NoError(t, err)
 	require.NotEmpty(t, id)
 	return id
 }
 
 func finishNextTestJob(t *testing.T, q jobqueue.JobQueue, jobType string, result interface{}, deps []uuid.UUID) uuid.UUID {
-	id, d, typ, args, err := q.Dequeue(context.Background(), []string{jobType})
+	id, d, typ, args, err := q.Dequeue(context.Background(), []string{jobType}, nil)
 	require.NoError(t, err)
 	require.NotEmpty(t, id)
 	require.ElementsMatch(t, deps, d)
@@ -67,12 +67,12 @@ func TestErrors(t *testing.T) {
 	defer cleanupTempDir(t, dir)
 
 	// not serializable to JSON
-	id, err := q.Enqueue("test", make(chan string), nil)
+	id, err := q.Enqueue("test", make(chan string), nil, "")
 	require.Error(t, err)
 	require.Equal(t, uuid.Nil, id)
 
 	// invalid dependency
-	id, err = q.Enqueue("test", "arg0", []uuid.UUID{uuid.New()})
+	id, err = q.Enqueue("test", "arg0", []uuid.UUID{uuid.New()}, "")
 	require.Error(t, err)
 	require.Equal(t, uuid.Nil, id)
 }
@@ -94,7 +94,7 @@ func TestArgs(t *testing.T) {
 
 	var parsedArgs argument
 
-	id, deps, typ, args, err := q.Dequeue(context.Background(), []string{"octopus"})
+	id, deps, typ, args, err := q.Dequeue(context.Background(), []string{"octopus"}, nil)
 	require.NoError(t, err)
 	require.Equal(t, two, id)
 	require.Empty(t, deps)
@@ -104,13 +104,13 @@ func TestArgs(t *testing.T) {
 	require.Equal(t, twoargs, parsedArgs)
 
 	// Read job params after Dequeue
-	jtype, jargs, jdeps, err := q.Job(id)
+	jtype, jargs, jdeps, _, err := q.Job(id)
 	require.NoError(t, err)
 	require.Equal(t, args, jargs)
 	require.Equal(t, deps, jdeps)
 	require.Equal(t, typ, jtype)
 
-	id, deps, typ, args, err = q.Dequeue(context.Background(), []string{"fish"})
+	id, deps, typ, args, err = q.Dequeue(context.Background(), []string{"fish"}, nil)
 	require.NoError(t, err)
 	require.Equal(t, one, id)
 	require.Empty(t, deps)
@@ -119,13 +119,13 @@ func TestArgs(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, oneargs, parsedArgs)
 
-	jtype, jargs, jdeps, err = q.Job(id)
+	jtype, jargs, jdeps, _, err = q.Job(id)
 	require.NoError(t, err)
 	require.Equal(t, args, jargs)
 	require.Equal(t, deps, jdeps)
 	require.Equal(t, typ, jtype)
 
-	_, _, _, err = q.Job(uuid.New())
+	_, _, _, _, err = q.Job(uuid.New())
 	require.Error(t, err)
 }
 
@@ -141,7 +141,7 @@ func TestJobTypes(t *testing.T) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
-	id, deps, typ, args, err := q.Dequeue(ctx, []string{"zebra"})
+	id, deps, typ, args, err := q.Dequeue(ctx, []string{"zebra"}, nil)
 	require.Equal(t, err, context.Canceled)
 	require.Equal(t, uuid.Nil, id)
 	require.Empty(t, deps)
@@ -229,7 +229,7 @@ func TestMultipleWorkers(t *testing.T) {
 		defer close(done)
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		id, deps, typ, args, err := q.Dequeue(ctx, []string{"octopus"})
+		id, deps, typ, args, err := q.Dequeue(ctx, []string{"octopus"}, nil)
 		require.NoError(t, err)
 		require.NotEmpty(t, id)
 		require.Empty(t, deps)
@@ -243,7 +243,7 @@ func TestMultipleWorkers(t *testing.T) {
 
 	// This call to Dequeue() should not block on the one in the goroutine.
 	id := pushTestJob(t, q, "clownfish", nil, nil)
-	r, deps, typ, args, err := q.Dequeue(context.Background(), []string{"clownfish"})
+	r, deps, typ, args, err := q.Dequeue(context.Background(), []string{"clownfish"}, nil)
 	require.NoError(t, err)
 	require.Equal(t, id, r)
 	require.Empty(t, deps)
@@ -278,7 +278,7 @@ func TestCancel(t *testing.T) {
 	// Cancel a running job, which should not dequeue the canceled job from above
 	id = pushTestJob(t, q, "clownfish", nil, nil)
 	require.NotEmpty(t, id)
-	r, deps, typ, args, err := q.Dequeue(context.Background(), []string{"clownfish"})
+	r, deps, typ, args, err := q.Dequeue(context.Background(), []string{"clownfish"}, nil)
 	require.NoError(t, err)
 	require.Equal(t, id, r)
 	require.Empty(t, deps)
@@ -296,7 +296,7 @@ func TestCancel(t *testing.T) {
 	// Cancel a finished job, which is a no-op
 	id = pushTestJob(t, q, "clownfish", nil, nil)
 	require.NotEmpty(t, id)
-	r, deps, typ, args, err = q.Dequeue(context.Background(), []string{"clownfish"})
+	r, deps, typ, args, err = q.Dequeue(context.Background(), []string{"clownfish"}, nil)
 	require.NoError(t, err)
 	require.Equal(t, id, r)
 	require.Empty(t, deps)
@@ -312,3 +312,70 @@ func TestCancel(t *testing.T) {
 	err = json.Unmarshal(result, &testResult{})
 	require.NoError(t, err)
 }
+
+func TestPendingJobs(t *testing.T) {
+	q, dir := newTemporaryQueue(t)
+	defer cleanupTempDir(t, dir)
+
+	require.Empty(t, q.PendingJobs())
+
+	one := pushTestJob(t, q, "octopus", nil, nil)
+	pushTestJob(t, q, "octopus", nil, nil)
+	pushTestJob(t, q, "clownfish", nil, nil)
+	// waits for its dependency
+	pushTestJob(t, q, "clownfish", nil, []uuid.UUID{one})
+	require.Equal(t, map[string]int{"octopus": 2, "clownfish": 1}, q.PendingJobs())
+
+	finishNextTestJob(t, q, "octopus", testResult{}, nil)
+	require.Equal(t, map[string]int{"octopus": 1, "clownfish": 2}, q.PendingJobs())
+}
+
+func TestChannels(t *testing.T) {
+	q, dir := newTemporaryQueue(t)
+	defer cleanupTempDir(t, dir)
+
+	one, err := q.Enqueue("octopus", nil, nil, "sea")
+	require.NoError(t, err)
+	two, err := q.Enqueue("octopus", nil, nil, "lake")
+	require.NoError(t, err)
+	three := pushTestJob(t, q, "octopus", nil, nil)
+
+	id, _, _, _, err := q.Dequeue(context.Background(), []string{"octopus"}, []string{"lake"})
+	require.NoError(t, err)
+	require.Equal(t, two, id)
+	_, _, _, channel, err := q.Job(id)
+	require.NoError(t, err)
+	require.Equal(t, "lake", channel)
+
+	// the default channel is the empty string
+	id, _, _, _, err = q.Dequeue(context.Background(), []string{"octopus"}, []string{""})
+	require.NoError(t, err)
+	require.Equal(t, three, id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, _, _, err = q.Dequeue(ctx, []string{"octopus"}, []string{"lake", ""})
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	// jobs of all channels are dequeued without channels
+	id, _, _, _, err = q.Dequeue(context.Background(), []string{"octopus"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, one, id)
+}
+
+func TestEnqueueWithID(t *testing.T) {
+	q, dir := newTemporaryQueue(t)
+	defer cleanupTempDir(t, dir)
+
+	id := uuid.New()
+	err := q.(jobqueue.IDEnqueuer).EnqueueWithID(id, "octopus", nil, nil, "")
+	require.NoError(t, err)
+	jobType, _, _, _, err := q.Job(id)
+	require.NoError(t, err)
+	require.Equal(t, "octopus", jobType)
+
+	err = q.(jobqueue.IDEnqueuer).EnqueueWithID(id, "clownfish", nil, nil, "")
+	require.EqualError(t, err, "job "+id.String()+" already exists")
+
+	require.Equal(t, id, finishNextTestJob(t, q, "octopus", testResult{}, nil))
+}
@@ -10,6 +10,10 @@
 //
 // A job can have dependencies. It is not run until all its dependencies have
 // finished.
+//
+// Each job is queued in a channel, which separates the jobs of different
+// tenants. Workers can restrict which channels they take jobs from. The
+// default channel is the empty string.
 package jobqueue
 
 import (
@@ -31,17 +35,20 @@ type JobQueue interface {
 	// All dependencies must already exist, but the job isn't run until all of them
 	// have finished.
 	//
+	// The job is queued in `channel`.
+	//
 	// Returns the id of the new job, or an error.
-	Enqueue(jobType string, args interface{}, dependencies []uuid.UUID) (uuid.UUID, error)
+	Enqueue(jobType string, args interface{}, dependencies []uuid.UUID, channel string) (uuid.UUID, error)
 
 	// Dequeues a job, blocking until one is available.
 	//
-	// Waits until a job with a type of any of `jobTypes` is available, or `ctx` is
-	// canceled.
+	// Waits until a job with a type of any of `jobTypes` is available in any of
+	// `channels`, or `ctx` is canceled. Jobs of all channels are dequeued if
+	// `channels` is empty.
 	//
 	// Returns the job's id, dependencies, type, and arguments, or an error. Arguments
 	// can be unmarshaled to the type given in Enqueue().
-	Dequeue(ctx context.Context, jobTypes []string) (uuid.UUID, []uuid.UUID, string, json.RawMessage, error)
+	Dequeue(ctx context.Context, jobTypes []string, channels []string) (uuid.UUID, []uuid.UUID, string, json.RawMessage, error)
 
 	// Mark the job with `id` as finished. `result` must fit the associated
 	// job type and must be serializable to JSON.
@@ -61,7 +68,19 @@ type JobQueue interface {
 	JobStatus(id uuid.UUID) (result json.RawMessage, queued, started, finished time.Time, canceled bool, deps []uuid.UUID, err error)
 
 	// Job returns all the parameters that define a job (everything provided during Enqueue).
-	Job(id uuid.UUID) (jobType string, args json.RawMessage, dependencies []uuid.UUID, err error)
+	Job(id uuid.UUID) (jobType string, args json.RawMessage, dependencies []uuid.UUID, channel string, err error)
+
+	// PendingJobs returns how many jobs of each type are waiting for a
+	// worker. Jobs which wait for their dependencies are not included.
+	PendingJobs() map[string]int
+}
+
+// IDEnqueuer is implemented by job queues which can enqueue a job with an id
+// chosen by the caller, e.g. to bind data in its arguments to the job.
+type IDEnqueuer interface {
+	// Enqueues a job like JobQueue.Enqueue(), but with `id`, which must not
+	// be the id of an existing job.
+	EnqueueWithID(id uuid.UUID, jobType string, args interface{}, dependencies []uuid.UUID, channel string) error
 }
 
 var (
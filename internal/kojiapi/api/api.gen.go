@@ -4,10 +4,15 @@
 package api
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
 	"fmt"
 	"github.com/deepmap/oapi-codegen/pkg/runtime"
+	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/labstack/echo/v4"
 	"net/http"
+	"strings"
 )
 
 // ComposeLogs defines model for ComposeLogs.
@@ -90,6 +95,9 @@ type ServerInterface interface {
 	// Get the manifests for a compose.
 	// (GET /compose/{id}/manifests)
 	GetComposeIdManifests(ctx echo.Context, id string) error
+	// The OpenAPI document of this API
+	// (GET /openapi.json)
+	GetOpenapiJson(ctx echo.Context) error
 	// status
 	// (GET /status)
 	GetStatus(ctx echo.Context) error
@@ -157,6 +165,15 @@ func (w *ServerInterfaceWrapper) GetComposeIdManifests(ctx echo.Context) error {
 	return err
 }
 
+// GetOpenapiJson converts echo context to params.
+func (w *ServerInterfaceWrapper) GetOpenapiJson(ctx echo.Context) error {
+	var err error
+
+	// Invoke the callback with all the unmarshalled arguments
+	err = w.Handler.GetOpenapiJson(ctx)
+	return err
+}
+
 // GetStatus converts echo context to params.
 func (w *ServerInterfaceWrapper) GetStatus(ctx echo.Context) error {
 	var err error
@@ -192,6 +209,85 @@ func RegisterHandlers(router EchoRouter, si ServerInterface) {
 	router.GET("/compose/:id", wrapper.GetComposeId)
 	router.GET("/compose/:id/logs", wrapper.GetComposeIdLogs)
 	router.GET("/compose/:id/manifests", wrapper.GetComposeIdManifests)
+	router.GET("/openapi.json", wrapper.GetOpenapiJson)
 	router.GET("/status", wrapper.GetStatus)
 
 }
+
+// Base64 encoded, gzipped, json marshaled Swagger object
+var swaggerSpec = []string{
+
+	"H4sIAAAAAAAC/+xZWZOqypb+K4TdD91hVYEoDhVxHhBRccB5vOw4kUICqZBgkuBwov57R+JQ5bCHE73v",
+	"Q9++PgG5Mtf0fZlrpX9lzMAPAwwxjTLvf2Ui04U+SB8VNhDBTuCkryEJQkgogukb8oED//TOY/QQwsx7",
+	"BhACDpmPl8wmWKM/kR8GhF5krl8x+vz28ZIhcBsjAq3M+z/uBZ6s8/JV8bePl4uRQ7iNYUQf7bRQRAla",
+	"xRQFmL3DPfBDjxlrQysg4DUvZl4u9jNR7GQ+LlrIadWTwxT66cN/Emhn3jP/wX8Gjj9HjdfYtIstHy9P",
+	"4/KzNdpM5uMlg4EPby2unyxWvCC2Xqsggs8sJ9CDILqbKQqiIFSE0pvwbEoCSfQQnnzuUfQuXamFn9M/",
+	"db/chv0hnOdAfLsqCFZraNLM13xGYYAj+JhQZN3amRPzsCAVS6+wXFm95kQr/woKUvG1IBaLklQoCILA",
+	"nLYD4gOaec/EMbKeBSGF2ipGnvXnnYqCeBVHmEIHkodIpEvervAFnCMKaPxdDkXpKPybIDuv+R2M/boj",
+	"Z3kKos29uCjkc4X8synR1SGIY58FIIpNE0YsszZAXkwYBkKILRZcFioHRRSmof728qnhy7QfI+2s8OU+",
+	"ZHfWs5jfUPAh5ICYLqLQpMzEGxzty8U/i4Xv7wWnz19nAB89J2AYRIgGBP2NnA4vkw6PKb2LxY0LN9bd",
+	"6b5G43v4+7tpTEF1eoxDLwDW/zafzML2eUu8Mw2SBJLbeLuUhtE7z7OUv51275AEbON4C4iTfnbj1Q3X",
+	"ifcsQ8/A/gskP9v0Of/Z/vUlkQ9OrUAEmUlPvTIt/Eag5QL6ZgY+bwaYQkx5tpHyxIVemS/zJ4zybJ0g",
+	"4oOI/wVnndDZwMOtzlf2q6oNTef6jT7Xn1Q7msK11QVX7fSUdjpsGNgwsD/Q9KpKnNE4qqqy4opyYSX0",
+	"KjSbHJXKlExpJye3k8kMVXp9Zb1JhqW5Va7R1h7C7lKCdcPAQpMetYZU3taGh9VuAsuV+jIk1Zxtx5P2",
+	"0VKzTbOTr6/02WjUm0/XSw2SyJofmnk9wZ1OJ12ipg+rItrnV/NGZejyw3lYj/R9LRQVtWtNktVCOaql",
+	"Sjun4sl46uY6Qs8WxHyd9kfL+VwwDQPHpf5KqmmSN5cWMJj3isMgsIjWWLSiaXUww2S2K+z1sRngia33",
+	"N4LaWFIVH5OmeDz0g5ZrGHjeqGd1M67Mdyvg4YUFc0JPSUYzeSkdx0oYdLrjZLCehSvFms1bR8UvNrK8",
+	"u6/E4QZKhWLTMLCkrenCL1hjbTrGZn6X1FCv5lTbw2PPqxC1p2hOL27Q2n64NwebNVGy+8SRppstLiXV",
+	"yWFmGLgSNqHVm2T7QT1fa/S6WWubZKHeriZuZVGvxE5dHlQ3ZqOUoKWTNNRuozEJj7qjl+Z4lHPWhoH5",
+	"Wj+o+Et11FvgtgkTKFJNKZdC0d2uyb7XjLWSvVkuoK6ZcgDKeVL1aH0OqrWFO5Pbo5xh4L45H+X4UuvY",
+	"FzaHkiV0ivNVzGu2tNnOBmF7N9HwnB5Gw01MwNSO2/lqCLLz3ByaMyQWWgvDwM0p9fS5IEr7fQ7upseG",
+	"roy9Gc5Fm4Eu9nrBYDwtKGV+Ww/Hs2JtPFvExCpkbVXoteqt5YDhYhW79nyx84fetOfoEiqV9LHWWrYG",
+	"9qK+zpdNXkukZFntB5te0bdt0hsn+W5zNnUG8lAeyFXDwLSmj5azgTNq1IWOUm2t/G6sKe5hOdt7i7nu",
+	"aQ3qwZF8aI/ksilO19a8FVpNTzb96QY06oJh4I6vJ6txsSWvF6q6UxVZkevyJuWM0th1G8puo1V3XaVa",
+	"nSiK3FXrjlaTBxqUB9rckeW2YWBlKC8xyg75SdeazI5hja9E0qg+5Jf1/GG9kKadSb3TnWvtyZHqej4w",
+	"C5J96FhjLZ+dKF5bMQwsUpXEx8V6qzfdrazOgRjpObtLorgNzWKu44m6nbTWm067ljhTzS1tuiUQefpi",
+	"2usXq2ObWcG3WiU+to/5ydI/TFGTn9W8rLywnE2+tVW0nrSTyIEoFe1QPS4S0Rea28VqZofuIh83dxKj",
+	"Gc5jq9My43K22uhL9WxPH6gNL1sbNqWy5lVaYb65Ww1ByUo2fUd17fowm3O1bJVSICollZGdF/X2Udkv",
+	"d6XOPt+vDs1JRVy0D0BFrn3gwaC9VOT4YLdRN4n8Y5QthIE2L2kVfaC0wgNbQmvYmh4sS9P9trkbYt6S",
+	"/J3YXY5b69VxZGcnfmXROsgCUtVDrbhdEzQbDFfxPhzMfU9urRjZy8FmWXBW03qSq5e7x3a2MCxPk1lL",
+	"mO8dijajoCTazfXOBaWuvN7WcW9bhEHRVhW+JDl5vWMYuOFSaWqFjbi5EZKVperNrlLejSoVKG3nDb0W",
+	"QyvvemOQ1LqeKje9fGErNisYbMaNYV1qI8PArYmtu/nadNhwnbLZ1SgSc3iNhm7JOdQ1sWea8nhRWlVH",
+	"pFRpubG+g14z3neIUg8XpanIMtKrb6iXk9bLFuju40F1u7CqzggcxIYwKdZy2dI0msV2eLR4ebXP8WY+",
+	"QJUlaCUzUdzIjrM1DGwdLVksTSaL9SzZF8RdxYe4tXN5YQ0H5hia2sQSLIHUzR1v5sLE6XY9flDKH45t",
+	"p73Y/fGHYeA/jqsmy0x6tKh67Yfnzk/Lh8tB+uwA/vVip9fOfPuZpmuhwgYQtoO0p4SRSVB4aikzI0gS",
+	"ZEKOBlxaIHEAW1wYRy6XlmYRG2BFzlvmJeMhE567mlN7l5FDYLqQE9POLC0OrhXBbrd7A+lwWt+c50Z8",
+	"R1NUfaS+im/Cm0t9j3lNEU1P9t6omtpw7jwI95rq/tKlvWdybEIQQgxCxBq9N+GN9XohoG4an1N9eu69",
+	"gog+uqwQCCjkAIfhjjtLv3BhQCGmCHjegTMDHKGIIuxwgc1FMIEEeJeAsACdCkgOAtNlAdqcA8QyBpgW",
+	"zcq8Z/pBRM+eZE55gRGtBlZazpwrJPYIwtBDZjqPX0enTvZUWf+s7r67Pvi4zT8lMUw/nJrRNDqikPv9",
+	"2k/rn9Tfhfokwrkg4iIKCIUWS19BEO6soHBP+dAD6E7/Pb4fNGg4AR6yLnnkyOf1RSEn/S4tYxdy53U4",
+	"JsyhiMMB5aI4DIOTV6yxjH0fkMMnxC5QZIMXXPJ/IeuDKXXgE2w2IOWoC7kTcxn6AEdijFMoEs5GGEUu",
+	"vLr7xo1dFHEIm15swYjbuZC6kDBRZh5iFpomhBa0XlLgAi8KOB9SwCF8qr1RgDmwCuKTXgKj2KOPWG7A",
+	"C5Q1K2UbAT6kkESZ93/c+6DVmN2XhJw9oQHHHGbbUOY9ZWvmckl0un+4xe3Ll+z87tuSj28PpBB+Nyku",
+	"Fxzfp0R0vQL5J7IBnelW+F0KJniDgx2+UXCD/PEdeL9LAf5y+/p/lged05Xuv7nwEy6kcfrOpspAwNkB",
+	"SYPuoATizxMZYQ4HXAgIRWbsAcKdfOH+i7pB7LjcCeqtUU//77d/OR4xAlyDc6XR2xMe+QAj+3LP/0My",
+	"XSV/gU9DSGOCIy7An/NSW9KaJy2FWIKoez1y3ziVDV2FzSBlVgp3JnbOngVthKHFAcp9LRWDKC0/01rR",
+	"B5g/v79elnuTfsjF7jUI/98Jeb2wfegt7u5ln/LxJtUPnPzXpNktNZ7x7dxuvF2ifWbZAxx7J7lWdP4/",
+	"6TGNt+aRM8koO7yswIx95vDjgcrWlfvaVYRBOJ0j97WThZ/94dMdYIQYSC9wdwG2PEgY7E0XmpvrcZme",
+	"uOeGEEVcHD49AEeXvzP+aQfH96unXvsuPNEX0dMV94n1p2aUByG67JbklbVpfJLLfHz7+J8BAOeVgng5",
+	"HgAA",
+}
+
+// GetSwagger returns the Swagger specification corresponding to the generated code
+// in this file.
+func GetSwagger() (*openapi3.Swagger, error) {
+	zipped, err := base64.StdEncoding.DecodeString(strings.Join(swaggerSpec, ""))
+	if err != nil {
+		return nil, fmt.Errorf("error base64 decoding spec: %s", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(zipped))
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing spec: %s", err)
+	}
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(zr)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing spec: %s", err)
+	}
+
+	swagger, err := openapi3.NewSwaggerLoader().LoadSwaggerFromData(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error loading Swagger: %s", err)
+	}
+	return swagger, nil
+}
@@ -4,6 +4,7 @@ package kojiapi
 import (
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -81,13 +82,15 @@ func (h *apiHandlers) PostCompose(ctx echo.Context) error {
 	}
 
 	type imageRequest struct {
-		manifest distro.Manifest
-		arch     string
-		filename string
+		manifest  distro.Manifest
+		arch      string
+		filename  string
+		imageType string
 	}
 
 	imageRequests := make([]imageRequest, len(request.ImageRequests))
 	kojiFilenames := make([]string, len(request.ImageRequests))
+	kojiLogFilenames := make([]string, len(request.ImageRequests))
 	kojiDirectory := "osbuild-composer-koji-" + uuid.New().String()
 
 	// use the same seed for all images so we get the same IDs
@@ -97,6 +100,10 @@ func (h *apiHandlers) PostCompose(ctx echo.Context) error {
 	}
 	manifestSeed := bigSeed.Int64()
 
+	// the packages of all images are resolved together, so that images
+	// for different architectures are resolved concurrently
+	imageTypes := make([]distro.ImageType, len(request.ImageRequests))
+	depsolveRequests := make([]rpmmd.DepsolveRequest, len(request.ImageRequests))
 	for i, ir := range request.ImageRequests {
 		arch, err := d.GetArch(ir.Architecture)
 		if err != nil {
@@ -119,15 +126,43 @@ func (h *apiHandlers) PostCompose(ctx echo.Context) error {
 			panic("Could not initialize empty blueprint.")
 		}
 		packageSpecs, excludePackageSpecs := imageType.Packages(*bp)
-		packages, _, err := h.server.rpmMetadata.Depsolve(packageSpecs, excludePackageSpecs, repositories, d.ModulePlatformID(), arch.Name())
-		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to depsolve base base packages for %s/%s/%s: %s", ir.ImageType, ir.Architecture, request.Distribution, err))
+		imageTypes[i] = imageType
+		depsolveRequests[i] = rpmmd.DepsolveRequest{
+			Sets: map[string]rpmmd.PackageSet{
+				rpmmd.OSPackageSet:    {Include: packageSpecs, Exclude: excludePackageSpecs, NoWeakDeps: !imageType.InstallWeakDeps()},
+				rpmmd.BuildPackageSet: {Include: imageType.BuildPackages()},
+			},
+			Repos:            repositories,
+			ModulePlatformID: d.ModulePlatformID(),
+			Arch:             arch.Name(),
 		}
-		buildPackageSpecs := imageType.BuildPackages()
-		buildPackages, _, err := h.server.rpmMetadata.Depsolve(buildPackageSpecs, nil, repositories, d.ModulePlatformID(), arch.Name())
-		if err != nil {
+	}
+
+	depsolved, err := rpmmd.DepsolveAll(ctx.Request().Context(), h.server.rpmMetadata, depsolveRequests)
+	if err != nil {
+		ir := request.ImageRequests[0]
+		var requestErr *rpmmd.DepsolveRequestError
+		if errors.As(err, &requestErr) {
+			ir = request.ImageRequests[requestErr.Index]
+		}
+		var setErr *rpmmd.PackageSetError
+		if errors.As(err, &setErr) && setErr.Name == rpmmd.BuildPackageSet {
 			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to depsolve build packages for %s/%s/%s: %s", ir.ImageType, ir.Architecture, request.Distribution, err))
 		}
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to depsolve base base packages for %s/%s/%s: %s", ir.ImageType, ir.Architecture, request.Distribution, err))
+	}
+
+	for i, ir := range request.ImageRequests {
+		imageType := imageTypes[i]
+		repositories := depsolveRequests[i].Repos
+		results := depsolved[i]
+		packages := results[rpmmd.OSPackageSet].Packages
+		buildPackages := results[rpmmd.BuildPackageSet].Packages
+
+		repositories, err = h.server.rpmMetadata.ResolveGPGKeys(ctx.Request().Context(), repositories)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to fetch GPG keys for %s/%s/%s: %s", ir.ImageType, ir.Architecture, request.Distribution, err))
+		}
 
 		manifest, err := imageType.Manifest(nil, distro.ImageOptions{Size: imageType.Size(0)}, repositories, packages, buildPackages, manifestSeed)
 		if err != nil {
@@ -135,8 +170,9 @@ func (h *apiHandlers) PostCompose(ctx echo.Context) error {
 		}
 
 		imageRequests[i].manifest = manifest
-		imageRequests[i].arch = arch.Name()
+		imageRequests[i].arch = imageType.Arch().Name()
 		imageRequests[i].filename = imageType.Filename()
+		imageRequests[i].imageType = imageType.Name()
 
 		kojiFilenames[i] = fmt.Sprintf(
 			"%s-%s-%s.%s%s",
@@ -146,14 +182,18 @@ func (h *apiHandlers) PostCompose(ctx echo.Context) error {
 			ir.Architecture,
 			splitExtension(imageType.Filename()),
 		)
+		kojiLogFilenames[i] = kojiFilenames[i] + ".log"
 	}
 
+	// clients of the koji API are authenticated by their certificates and
+	// do not belong to a tenant, so their jobs are queued in the default
+	// channel
 	initID, err := h.server.workers.EnqueueKojiInit(&worker.KojiInitJob{
 		Server:  request.Koji.Server,
 		Name:    request.Name,
 		Version: request.Version,
 		Release: request.Release,
-	})
+	}, "")
 	if err != nil {
 		// This is a programming error.
 		panic(err)
@@ -162,12 +202,15 @@ func (h *apiHandlers) PostCompose(ctx echo.Context) error {
 	var buildIDs []uuid.UUID
 	for i, ir := range imageRequests {
 		id, err := h.server.workers.EnqueueOSBuildKoji(ir.arch, &worker.OSBuildKojiJob{
-			Manifest:      ir.manifest,
-			ImageName:     ir.filename,
-			KojiServer:    request.Koji.Server,
-			KojiDirectory: kojiDirectory,
-			KojiFilename:  kojiFilenames[i],
-		}, initID)
+			Manifest:        ir.manifest,
+			ImageName:       ir.filename,
+			KojiServer:      request.Koji.Server,
+			KojiDirectory:   kojiDirectory,
+			KojiFilename:    kojiFilenames[i],
+			KojiLogFilename: kojiLogFilenames[i],
+			Distro:          request.Distribution,
+			ImageType:       ir.imageType,
+		}, initID, "")
 		if err != nil {
 			// This is a programming error.
 			panic(err)
@@ -176,15 +219,16 @@ func (h *apiHandlers) PostCompose(ctx echo.Context) error {
 	}
 
 	id, err := h.server.workers.EnqueueKojiFinalize(&worker.KojiFinalizeJob{
-		Server:        request.Koji.Server,
-		Name:          request.Name,
-		Version:       request.Version,
-		Release:       request.Release,
-		KojiFilenames: kojiFilenames,
-		KojiDirectory: kojiDirectory,
-		TaskID:        uint64(request.Koji.TaskId),
-		StartTime:     uint64(time.Now().Unix()),
-	}, initID, buildIDs)
+		Server:           request.Koji.Server,
+		Name:             request.Name,
+		Version:          request.Version,
+		Release:          request.Release,
+		KojiFilenames:    kojiFilenames,
+		KojiLogFilenames: kojiLogFilenames,
+		KojiDirectory:    kojiDirectory,
+		TaskID:           uint64(request.Koji.TaskId),
+		StartTime:        uint64(time.Now().Unix()),
+	}, initID, buildIDs, "")
 	if err != nil {
 		// This is a programming error.
 		panic(err)
@@ -354,6 +398,15 @@ func (h *apiHandlers) GetStatus(ctx echo.Context) error {
 	})
 }
 
+// GetOpenapiJson handles a /openapi.json GET request
+func (h *apiHandlers) GetOpenapiJson(ctx echo.Context) error {
+	spec, err := api.GetSwagger()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not load openapi spec")
+	}
+	return ctx.JSON(http.StatusOK, spec)
+}
+
 // Get logs for a compose
 func (h *apiHandlers) GetComposeIdLogs(ctx echo.Context, idstr string) error {
 	id, err := uuid.Parse(idstr)
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	kojiclient "github.com/osbuild/osbuild-composer/internal/client/kojiapi"
 	"github.com/osbuild/osbuild-composer/internal/kojiapi"
 	"github.com/osbuild/osbuild-composer/internal/kojiapi/api"
 	distro_mock "github.com/osbuild/osbuild-composer/internal/mocks/distro"
@@ -47,8 +48,39 @@ func TestStatus(t *testing.T) {
 	defer os.RemoveAll(dir)
 
 	kojiServer, _ := newTestKojiServer(t, dir)
-	handler := kojiServer.Handler("/api/composer-koji/v1")
-	test.TestRoute(t, handler, false, "GET", "/api/composer-koji/v1/status", ``, http.StatusOK, `{"status":"OK"}`, "message")
+	server := httptest.NewServer(kojiServer.Handler("/api/composer-koji/v1"))
+	defer server.Close()
+
+	client, err := kojiclient.NewClientWithResponses(server.URL + "/api/composer-koji/v1")
+	require.NoError(t, err)
+	resp, err := client.GetStatusWithResponse(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+	require.Equal(t, &kojiclient.Status{Status: "OK"}, resp.JSON200)
+}
+
+func TestOpenapiJson(t *testing.T) {
+	dir, err := ioutil.TempDir("", "osbuild-composer-test-kojiapi-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kojiServer, _ := newTestKojiServer(t, dir)
+	server := httptest.NewServer(kojiServer.Handler("/api/composer-koji/v1"))
+	defer server.Close()
+
+	client, err := kojiclient.NewClientWithResponses(server.URL + "/api/composer-koji/v1")
+	require.NoError(t, err)
+	resp, err := client.GetOpenapiJsonWithResponse(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+
+	var spec struct {
+		Paths map[string]interface{} `json:"paths"`
+	}
+	err = json.Unmarshal(resp.Body, &spec)
+	require.NoError(t, err)
+	require.Contains(t, spec.Paths, "/compose")
+	require.Contains(t, spec.Paths, "/openapi.json")
 }
 
 type jobResult struct {
@@ -234,7 +266,7 @@ func TestCompose(t *testing.T) {
 		wg.Add(1)
 
 		go func(t *testing.T, result worker.KojiInitJobResult) {
-			token, _, jobType, rawJob, _, err := workerServer.RequestJob(context.Background(), "x86_64", []string{"koji-init"})
+			token, _, jobType, rawJob, _, err := workerServer.RequestJob(context.Background(), "x86_64", []string{"koji-init"}, nil)
 			require.NoError(t, err)
 			require.Equal(t, "koji-init", jobType)
 
@@ -285,7 +317,7 @@ func TestCompose(t *testing.T) {
 		}`, c.composeReplyCode, c.composeReply, "id")
 		wg.Wait()
 
-		token, _, jobType, rawJob, _, err := workerServer.RequestJob(context.Background(), "x86_64", []string{"osbuild-koji"})
+		token, _, jobType, rawJob, _, err := workerServer.RequestJob(context.Background(), "x86_64", []string{"osbuild-koji"}, nil)
 		require.NoError(t, err)
 		require.Equal(t, "osbuild-koji", jobType)
 
@@ -300,7 +332,7 @@ func TestCompose(t *testing.T) {
 		require.NoError(t, err)
 		test.TestRoute(t, workerHandler, false, "PATCH", fmt.Sprintf("/api/worker/v1/jobs/%v", token), string(buildJobResult), http.StatusOK, `{}`)
 
-		token, _, jobType, rawJob, _, err = workerServer.RequestJob(context.Background(), "x86_64", []string{"osbuild-koji"})
+		token, _, jobType, rawJob, _, err = workerServer.RequestJob(context.Background(), "x86_64", []string{"osbuild-koji"}, nil)
 		require.NoError(t, err)
 		require.Equal(t, "osbuild-koji", jobType)
 
@@ -309,6 +341,7 @@ func TestCompose(t *testing.T) {
 		require.Equal(t, "koji.example.com", osbuildJob.KojiServer)
 		require.Equal(t, "test.img", osbuildJob.ImageName)
 		require.NotEmpty(t, osbuildJob.KojiDirectory)
+		require.Equal(t, osbuildJob.KojiFilename+".log", osbuildJob.KojiLogFilename)
 
 		test.TestRoute(t, workerHandler, false, "PATCH", fmt.Sprintf("/api/worker/v1/jobs/%v", token), `{
 			"result": {
@@ -322,7 +355,7 @@ func TestCompose(t *testing.T) {
 			}
 		}`, http.StatusOK, `{}`)
 
-		token, finalizeID, jobType, rawJob, _, err := workerServer.RequestJob(context.Background(), "x86_64", []string{"koji-finalize"})
+		token, finalizeID, jobType, rawJob, _, err := workerServer.RequestJob(context.Background(), "x86_64", []string{"koji-finalize"}, nil)
 		require.NoError(t, err)
 		require.Equal(t, "koji-finalize", jobType)
 
@@ -333,6 +366,7 @@ func TestCompose(t *testing.T) {
 		require.Equal(t, "1", kojiFinalizeJob.Version)
 		require.Equal(t, "2", kojiFinalizeJob.Release)
 		require.ElementsMatch(t, []string{"foo-1-2.x86_64.img", "foo-1-2.x86_64.img"}, kojiFinalizeJob.KojiFilenames)
+		require.ElementsMatch(t, []string{"foo-1-2.x86_64.img.log", "foo-1-2.x86_64.img.log"}, kojiFinalizeJob.KojiLogFilenames)
 		require.NotEmpty(t, kojiFinalizeJob.KojiDirectory)
 
 		finalizeResult, err := json.Marshal(&jobResult{Result: c.finalizeResult})
@@ -399,7 +433,7 @@ func TestJobTypeValidation(t *testing.T) {
 		Version: "42",
 		Release: "1",
 	}
-	initID, err := workers.EnqueueKojiInit(&initJob)
+	initID, err := workers.EnqueueKojiInit(&initJob, "")
 	require.NoError(t, err)
 
 	buildJobs := make([]worker.OSBuildKojiJob, nImages)
@@ -413,7 +447,7 @@ func TestJobTypeValidation(t *testing.T) {
 			KojiDirectory: "koji-server-test-dir",
 			KojiFilename:  fname,
 		}
-		buildID, err := workers.EnqueueOSBuildKoji(fmt.Sprintf("fake-arch-%d", idx), &buildJob, initID)
+		buildID, err := workers.EnqueueOSBuildKoji(fmt.Sprintf("fake-arch-%d", idx), &buildJob, initID, "")
 		require.NoError(t, err)
 
 		buildJobs[idx] = buildJob
@@ -431,7 +465,7 @@ func TestJobTypeValidation(t *testing.T) {
 		TaskID:        0,
 		StartTime:     uint64(time.Now().Unix()),
 	}
-	finalizeID, err := workers.EnqueueKojiFinalize(&finalizeJob, initID, buildJobIDs)
+	finalizeID, err := workers.EnqueueKojiFinalize(&finalizeJob, initID, buildJobIDs, "")
 	require.NoError(t, err)
 
 	// ----- Jobs queued - Test API endpoints (status, manifests, logs) ----- //
@@ -35,6 +35,7 @@ func generatePackageList() rpmmd.PackageList {
 			Arch:        "x86_64",
 			BuildTime:   baseTime.AddDate(0, i, 0),
 			License:     "MIT",
+			Repo:        "test-id",
 		}
 
 		secondBuild := basePackage
@@ -52,6 +53,21 @@ func generatePackageList() rpmmd.PackageList {
 	return packageList
 }
 
+func generatePackageGroups() rpmmd.PackageGroups {
+	return rpmmd.PackageGroups{
+		{ID: "core", Name: "Core", Description: "Smallest possible installation", UserVisible: false},
+		{ID: "minimal-environment", Name: "Minimal Install", Description: "Basic functionality.", Environment: true, UserVisible: true},
+		{ID: "standard", Name: "Standard", Description: "Common set of utilities", UserVisible: true},
+	}
+}
+
+func generateModuleStreams() []rpmmd.ModuleStream {
+	return []rpmmd.ModuleStream{
+		{Name: "nodejs", Stream: "12", Summary: "Javascript runtime", Profiles: []string{"default", "minimal"}, Default: true},
+		{Name: "nodejs", Stream: "14", Summary: "Javascript runtime", Profiles: []string{"default", "minimal"}},
+	}
+}
+
 func createBaseWorkersFixture(tmpdir string) *worker.Server {
 	q, err := fsjobqueue.New(tmpdir)
 	if err != nil {
@@ -160,6 +176,33 @@ func BadDepsolve(tmpdir string) Fixture {
 	}
 }
 
+// DetailedBadDepsolve fails to depsolve with the details dnf-json reports
+// about the problem
+func DetailedBadDepsolve(tmpdir string) Fixture {
+	return Fixture{
+		fetchPackageList{
+			generatePackageList(),
+			map[string]string{"base": "sha256:f34848ca92665c342abd5816c9e3eda0e82180671195362bcd0080544a3bc2ac"},
+			nil,
+		},
+		depsolve{
+			nil,
+			nil,
+			&rpmmd.DNFError{
+				Kind:   "DepsolveError",
+				Reason: "There was a problem depsolving ['dep-package1']: \n Problem: conflicting requests\n  - nothing provides askalono-cli needed by dep-package1-1.33-2.fc30.x86_64",
+				Details: &rpmmd.DepsolveErrorDetails{
+					MissingProvides: []string{"askalono-cli needed by dep-package1-1.33-2.fc30.x86_64"},
+					Problems:        []string{"conflicting requests", "nothing provides askalono-cli needed by dep-package1-1.33-2.fc30.x86_64"},
+					Culprits:        []string{"dep-package1"},
+				},
+			},
+		},
+		store.FixtureBase(),
+		createBaseWorkersFixture(tmpdir),
+	}
+}
+
 func BadFetch(tmpdir string) Fixture {
 	return Fixture{
 		fetchPackageList{
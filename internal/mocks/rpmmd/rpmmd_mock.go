@@ -1,6 +1,8 @@
 package rpmmd_mock
 
 import (
+	"context"
+
 	"github.com/osbuild/osbuild-composer/internal/rpmmd"
 	"github.com/osbuild/osbuild-composer/internal/store"
 	"github.com/osbuild/osbuild-composer/internal/worker"
@@ -32,10 +34,56 @@ func NewRPMMDMock(fixture Fixture) rpmmd.RPMMD {
 	return &rpmmdMock{Fixture: fixture}
 }
 
-func (r *rpmmdMock) FetchMetadata(repos []rpmmd.RepoConfig, modulePlatformID string, arch string) (rpmmd.PackageList, map[string]string, error) {
+func (r *rpmmdMock) FetchMetadata(ctx context.Context, repos []rpmmd.RepoConfig, modulePlatformID string, arch string) (rpmmd.PackageList, map[string]string, error) {
 	return r.Fixture.fetchPackageList.ret, r.Fixture.fetchPackageList.checksums, r.Fixture.fetchPackageList.err
 }
 
-func (r *rpmmdMock) Depsolve(specs, excludeSpecs []string, repos []rpmmd.RepoConfig, modulePlatformID, arch string) ([]rpmmd.PackageSpec, map[string]string, error) {
+func (r *rpmmdMock) Depsolve(ctx context.Context, specs, excludeSpecs, moduleSpecs []string, repos []rpmmd.RepoConfig, modulePlatformID, arch string) ([]rpmmd.PackageSpec, map[string]string, error) {
 	return r.Fixture.depsolve.ret, r.Fixture.fetchPackageList.checksums, r.Fixture.depsolve.err
 }
+
+func (r *rpmmdMock) SearchPackages(ctx context.Context, query rpmmd.PackageQuery, repos []rpmmd.RepoConfig, modulePlatformID, arch string) (rpmmd.PackageSearchResult, error) {
+	if r.Fixture.fetchPackageList.err != nil {
+		return rpmmd.PackageSearchResult{}, r.Fixture.fetchPackageList.err
+	}
+	return r.Fixture.fetchPackageList.ret.Query(query)
+}
+
+func (r *rpmmdMock) ResolveGPGKeys(ctx context.Context, repos []rpmmd.RepoConfig) ([]rpmmd.RepoConfig, error) {
+	return repos, nil
+}
+
+func (r *rpmmdMock) DepsolvePackageSet(ctx context.Context, set rpmmd.PackageSet, repos []rpmmd.RepoConfig, modulePlatformID, arch string) (rpmmd.DepsolveResult, error) {
+	return rpmmd.DepsolveResult{
+		Packages:  r.Fixture.depsolve.ret,
+		Checksums: r.Fixture.fetchPackageList.checksums,
+	}, r.Fixture.depsolve.err
+}
+
+func (r *rpmmdMock) DepsolvePackageSets(ctx context.Context, sets map[string]rpmmd.PackageSet, repos []rpmmd.RepoConfig, modulePlatformID, arch string) (map[string]rpmmd.DepsolveResult, error) {
+	if r.Fixture.depsolve.err != nil {
+		return nil, &rpmmd.PackageSetError{Name: rpmmd.OSPackageSet, Err: r.Fixture.depsolve.err}
+	}
+	results := make(map[string]rpmmd.DepsolveResult, len(sets))
+	for name := range sets {
+		results[name] = rpmmd.DepsolveResult{
+			Packages:  r.Fixture.depsolve.ret,
+			Checksums: r.Fixture.fetchPackageList.checksums,
+		}
+	}
+	return results, nil
+}
+
+func (r *rpmmdMock) ListModuleStreams(ctx context.Context, repos []rpmmd.RepoConfig, modulePlatformID, arch string) ([]rpmmd.ModuleStream, error) {
+	if r.Fixture.fetchPackageList.err != nil {
+		return nil, r.Fixture.fetchPackageList.err
+	}
+	return generateModuleStreams(), nil
+}
+
+func (r *rpmmdMock) ListPackageGroups(ctx context.Context, repos []rpmmd.RepoConfig, modulePlatformID, arch string) (rpmmd.PackageGroups, error) {
+	if r.Fixture.fetchPackageList.err != nil {
+		return nil, r.Fixture.fetchPackageList.err
+	}
+	return generatePackageGroups(), nil
+}
@@ -0,0 +1,21 @@
+package osbuild
+
+// ChmodStageOptions describe the permissions to set on paths in the tree
+type ChmodStageOptions struct {
+	Items map[string]ChmodStagePathOptions `json:"items"`
+}
+
+type ChmodStagePathOptions struct {
+	Mode      string `json:"mode"`
+	Recursive bool   `json:"recursive,omitempty"`
+}
+
+func (ChmodStageOptions) isStageOptions() {}
+
+// NewChmodStage creates a new chmod Stage object.
+func NewChmodStage(options *ChmodStageOptions) *Stage {
+	return &Stage{
+		Name:    "org.osbuild.chmod",
+		Options: options,
+	}
+}
@@ -0,0 +1,16 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewChmodStage(t *testing.T) {
+	expectedStage := &Stage{
+		Name:    "org.osbuild.chmod",
+		Options: &ChmodStageOptions{},
+	}
+	actualStage := NewChmodStage(&ChmodStageOptions{})
+	assert.Equal(t, expectedStage, actualStage)
+}
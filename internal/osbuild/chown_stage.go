@@ -0,0 +1,23 @@
+package osbuild
+
+// ChownStageOptions describe the ownership to set on paths in the tree.
+// Users and groups can be given by name or numeric id.
+type ChownStageOptions struct {
+	Items map[string]ChownStagePathOptions `json:"items"`
+}
+
+type ChownStagePathOptions struct {
+	User      string `json:"user,omitempty"`
+	Group     string `json:"group,omitempty"`
+	Recursive bool   `json:"recursive,omitempty"`
+}
+
+func (ChownStageOptions) isStageOptions() {}
+
+// NewChownStage creates a new chown Stage object.
+func NewChownStage(options *ChownStageOptions) *Stage {
+	return &Stage{
+		Name:    "org.osbuild.chown",
+		Options: options,
+	}
+}
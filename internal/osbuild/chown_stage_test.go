@@ -0,0 +1,16 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewChownStage(t *testing.T) {
+	expectedStage := &Stage{
+		Name:    "org.osbuild.chown",
+		Options: &ChownStageOptions{},
+	}
+	actualStage := NewChownStage(&ChownStageOptions{})
+	assert.Equal(t, expectedStage, actualStage)
+}
@@ -0,0 +1,22 @@
+package osbuild
+
+// CopyStageOptions describe files to copy into the tree. The source of each
+// path is the checksum of an item in the org.osbuild.inline source.
+type CopyStageOptions struct {
+	Paths []CopyStagePath `json:"paths"`
+}
+
+type CopyStagePath struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (CopyStageOptions) isStageOptions() {}
+
+// NewCopyStage creates a new copy Stage object.
+func NewCopyStage(options *CopyStageOptions) *Stage {
+	return &Stage{
+		Name:    "org.osbuild.copy",
+		Options: options,
+	}
+}
@@ -0,0 +1,16 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCopyStage(t *testing.T) {
+	expectedStage := &Stage{
+		Name:    "org.osbuild.copy",
+		Options: &CopyStageOptions{},
+	}
+	actualStage := NewCopyStage(&CopyStageOptions{})
+	assert.Equal(t, expectedStage, actualStage)
+}
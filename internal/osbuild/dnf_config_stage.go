@@ -0,0 +1,27 @@
+package osbuild
+
+// DNFConfigStageOptions describe the options written to the [main] section
+// of /etc/dnf/dnf.conf in the tree
+type DNFConfigStageOptions struct {
+	Config *DNFConfig `json:"config,omitempty"`
+}
+
+type DNFConfig struct {
+	Main *DNFConfigMain `json:"main,omitempty"`
+}
+
+type DNFConfigMain struct {
+	InstallWeakDeps *bool    `json:"install_weak_deps,omitempty"`
+	Exclude         []string `json:"exclude,omitempty"`
+	Proxy           string   `json:"proxy,omitempty"`
+}
+
+func (DNFConfigStageOptions) isStageOptions() {}
+
+// NewDNFConfigStage creates a new DNF config Stage object.
+func NewDNFConfigStage(options *DNFConfigStageOptions) *Stage {
+	return &Stage{
+		Name:    "org.osbuild.dnf.config",
+		Options: options,
+	}
+}
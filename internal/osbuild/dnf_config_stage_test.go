@@ -0,0 +1,16 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDNFConfigStage(t *testing.T) {
+	expectedStage := &Stage{
+		Name:    "org.osbuild.dnf.config",
+		Options: &DNFConfigStageOptions{},
+	}
+	actualStage := NewDNFConfigStage(&DNFConfigStageOptions{})
+	assert.Equal(t, expectedStage, actualStage)
+}
@@ -0,0 +1,24 @@
+package osbuild
+
+// DNFModuleConfigStageOptions describe the state of a DNF module which is
+// written to /etc/dnf/modules.d/<name>.module in the tree
+type DNFModuleConfigStageOptions struct {
+	Conf DNFModuleConfig `json:"conf"`
+}
+
+type DNFModuleConfig struct {
+	Name     string   `json:"name"`
+	Stream   string   `json:"stream"`
+	State    string   `json:"state"`
+	Profiles []string `json:"profiles"`
+}
+
+func (DNFModuleConfigStageOptions) isStageOptions() {}
+
+// NewDNFModuleConfigStage creates a new DNF module config Stage object.
+func NewDNFModuleConfigStage(options *DNFModuleConfigStageOptions) *Stage {
+	return &Stage{
+		Name:    "org.osbuild.dnf.module-config",
+		Options: options,
+	}
+}
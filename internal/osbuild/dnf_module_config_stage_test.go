@@ -0,0 +1,16 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDNFModuleConfigStage(t *testing.T) {
+	expectedStage := &Stage{
+		Name:    "org.osbuild.dnf.module-config",
+		Options: &DNFModuleConfigStageOptions{},
+	}
+	actualStage := NewDNFModuleConfigStage(&DNFModuleConfigStageOptions{})
+	assert.Equal(t, expectedStage, actualStage)
+}
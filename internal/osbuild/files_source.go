@@ -6,6 +6,14 @@ type Secret struct {
 type FileSource struct {
 	URL     string  `json:"url"`
 	Secrets *Secret `json:"secrets,omitempty"`
+	Proxy   string  `json:"proxy,omitempty"`
+	// Insecure disables verifying the certificate of the server, CACert
+	// is the path of a CA bundle to verify it with instead of the system's
+	Insecure bool   `json:"insecure,omitempty"`
+	CACert   string `json:"cacert,omitempty"`
+	// Mirrors are further URLs of the same file, tried in order when URL
+	// cannot be downloaded
+	Mirrors []string `json:"mirrors,omitempty"`
 }
 
 // The FilesSourceOptions specifies a custom script to run in the image
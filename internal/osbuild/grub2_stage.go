@@ -11,17 +11,28 @@ import "github.com/google/uuid"
 // Note that it is the role of an assembler to install any necessary
 // bootloaders that are stored in the image outside of any filesystem.
 type GRUB2StageOptions struct {
-	RootFilesystemUUID uuid.UUID  `json:"root_fs_uuid"`
-	BootFilesystemUUID *uuid.UUID `json:"boot_fs_uuid,omitempty"`
-	KernelOptions      string     `json:"kernel_opts,omitempty"`
-	Legacy             string     `json:"legacy,omitempty"`
-	UEFI               *GRUB2UEFI `json:"uefi,omitempty"`
+	RootFilesystemUUID uuid.UUID    `json:"root_fs_uuid"`
+	BootFilesystemUUID *uuid.UUID   `json:"boot_fs_uuid,omitempty"`
+	KernelOptions      string       `json:"kernel_opts,omitempty"`
+	Legacy             string       `json:"legacy,omitempty"`
+	UEFI               *GRUB2UEFI   `json:"uefi,omitempty"`
+	Config             *GRUB2Config `json:"config,omitempty"`
 }
 
 type GRUB2UEFI struct {
 	Vendor string `json:"vendor"`
 }
 
+// GRUB2Config describes the settings written to /etc/default/grub. Serial
+// is the GRUB serial command, e.g. serial --unit=0 --speed=115200.
+type GRUB2Config struct {
+	Default        string   `json:"default,omitempty"`
+	Timeout        *int     `json:"timeout,omitempty"`
+	TerminalInput  []string `json:"terminal_input,omitempty"`
+	TerminalOutput []string `json:"terminal_output,omitempty"`
+	Serial         string   `json:"serial,omitempty"`
+}
+
 func (GRUB2StageOptions) isStageOptions() {}
 
 // NewGRUB2Stage creates a new GRUB2 stage object.
@@ -0,0 +1,43 @@
+package osbuild
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// InlineSource contains data which is embedded directly in the manifest. It
+// is meant for small files only, such as configuration files.
+type InlineSource struct {
+	Items map[string]InlineSourceItem `json:"items"`
+}
+
+type InlineSourceItem struct {
+	Encoding string `json:"encoding"`
+	Data     string `json:"data"`
+}
+
+func (InlineSource) isSource() {}
+
+// NewInlineSource creates a new, empty inline source.
+func NewInlineSource() *InlineSource {
+	return &InlineSource{
+		Items: make(map[string]InlineSourceItem),
+	}
+}
+
+// AddItem embeds data into the source and returns the checksum under which
+// stages can refer to it.
+func (s *InlineSource) AddItem(data []byte) string {
+	checksum := InlineChecksum(data)
+	s.Items[checksum] = InlineSourceItem{
+		Encoding: "base64",
+		Data:     base64.StdEncoding.EncodeToString(data),
+	}
+	return checksum
+}
+
+// InlineChecksum returns the checksum identifying data in an inline source.
+func InlineChecksum(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
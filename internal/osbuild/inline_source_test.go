@@ -0,0 +1,21 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInlineSource_AddItem(t *testing.T) {
+	source := NewInlineSource()
+	checksum := source.AddItem([]byte("hello\n"))
+
+	assert.Equal(t, "sha256:5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03", checksum)
+	assert.Equal(t, InlineChecksum([]byte("hello\n")), checksum)
+	assert.Equal(t, map[string]InlineSourceItem{
+		checksum: {
+			Encoding: "base64",
+			Data:     "aGVsbG8K",
+		},
+	}, source.Items)
+}
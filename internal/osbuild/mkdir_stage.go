@@ -0,0 +1,23 @@
+package osbuild
+
+// MkdirStageOptions describe the directories to create in the tree
+type MkdirStageOptions struct {
+	Paths []MkdirStagePath `json:"paths"`
+}
+
+type MkdirStagePath struct {
+	Path    string `json:"path"`
+	Mode    *int   `json:"mode,omitempty"`
+	Parents bool   `json:"parents,omitempty"`
+	ExistOk bool   `json:"exist_ok,omitempty"`
+}
+
+func (MkdirStageOptions) isStageOptions() {}
+
+// NewMkdirStage creates a new mkdir Stage object.
+func NewMkdirStage(options *MkdirStageOptions) *Stage {
+	return &Stage{
+		Name:    "org.osbuild.mkdir",
+		Options: options,
+	}
+}
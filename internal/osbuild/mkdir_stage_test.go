@@ -0,0 +1,16 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMkdirStage(t *testing.T) {
+	expectedStage := &Stage{
+		Name:    "org.osbuild.mkdir",
+		Options: &MkdirStageOptions{},
+	}
+	actualStage := NewMkdirStage(&MkdirStageOptions{})
+	assert.Equal(t, expectedStage, actualStage)
+}
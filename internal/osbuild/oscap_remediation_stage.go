@@ -0,0 +1,23 @@
+package osbuild
+
+// OscapRemediationStageOptions describe how to run an OpenSCAP remediation
+// of the tree against a security profile
+type OscapRemediationStageOptions struct {
+	DataDir string      `json:"data_dir,omitempty"`
+	Config  OscapConfig `json:"config"`
+}
+
+type OscapConfig struct {
+	Datastream string `json:"datastream"`
+	ProfileID  string `json:"profile_id"`
+}
+
+func (OscapRemediationStageOptions) isStageOptions() {}
+
+// NewOscapRemediationStage creates a new oscap.remediation Stage object.
+func NewOscapRemediationStage(options *OscapRemediationStageOptions) *Stage {
+	return &Stage{
+		Name:    "org.osbuild.oscap.remediation",
+		Options: options,
+	}
+}
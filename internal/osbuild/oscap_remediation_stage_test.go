@@ -0,0 +1,16 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOscapRemediationStage(t *testing.T) {
+	expectedStage := &Stage{
+		Name:    "org.osbuild.oscap.remediation",
+		Options: &OscapRemediationStageOptions{},
+	}
+	actualStage := NewOscapRemediationStage(&OscapRemediationStageOptions{})
+	assert.Equal(t, expectedStage, actualStage)
+}
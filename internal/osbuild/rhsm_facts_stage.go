@@ -0,0 +1,22 @@
+package osbuild
+
+// RHSMFactsStageOptions describes the custom facts reported by the Red Hat
+// Subscription Manager of the image, stored in /etc/rhsm/facts/osbuild.facts
+type RHSMFactsStageOptions struct {
+	Facts RHSMFacts `json:"facts"`
+}
+
+type RHSMFacts struct {
+	// The API the image was requested through
+	APIType string `json:"image-builder.osbuild-composer.api-type"`
+}
+
+func (RHSMFactsStageOptions) isStageOptions() {}
+
+// NewRHSMFactsStage creates a new rhsm.facts stage
+func NewRHSMFactsStage(options *RHSMFactsStageOptions) *Stage {
+	return &Stage{
+		Name:    "org.osbuild.rhsm.facts",
+		Options: options,
+	}
+}
@@ -0,0 +1,16 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRhsmFactsStage(t *testing.T) {
+	expectedStage := &Stage{
+		Name:    "org.osbuild.rhsm.facts",
+		Options: &RHSMFactsStageOptions{},
+	}
+	actualStage := NewRHSMFactsStage(&RHSMFactsStageOptions{})
+	assert.Equal(t, expectedStage, actualStage)
+}
@@ -3,10 +3,11 @@ package osbuild
 // RHSMStageOptions describes configuration of the RHSM stage.
 //
 // The RHSM stage allows configuration of Red Hat Subscription Manager (RHSM)
-// related components. Currently it allows only configuration of the enablement
-// state of DNF plugins used by the Subscription Manager.
+// related components. It allows configuration of the enablement state of DNF
+// plugins used by the Subscription Manager and of the rhsm.conf options.
 type RHSMStageOptions struct {
 	DnfPlugins *RHSMStageOptionsDnfPlugins `json:"dnf-plugins,omitempty"`
+	SubMan     *RHSMStageOptionsSubMan     `json:"subscription-manager,omitempty"`
 }
 
 func (RHSMStageOptions) isStageOptions() {}
@@ -25,6 +26,18 @@ type RHSMStageOptionsDnfPlugin struct {
 	Enabled bool `json:"enabled"`
 }
 
+// RHSMStageOptionsSubMan describes the sections of the Subscription Manager
+// configuration file, rhsm.conf
+type RHSMStageOptionsSubMan struct {
+	Rhsm *SubManConfigRHSMSection `json:"rhsm,omitempty"`
+}
+
+// SubManConfigRHSMSection describes the [rhsm] section of rhsm.conf
+type SubManConfigRHSMSection struct {
+	// Whether the Subscription Manager generates the redhat.repo file
+	ManageRepos *bool `json:"manage_repos,omitempty"`
+}
+
 // NewRHSMStage creates a new RHSM stage
 func NewRHSMStage(options *RHSMStageOptions) *Stage {
 	return &Stage{
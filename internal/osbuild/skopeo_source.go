@@ -0,0 +1,37 @@
+package osbuild
+
+// SkopeoSource describes container images to be fetched from a registry.
+// Items are keyed by the digest of the image manifest.
+type SkopeoSource struct {
+	Items map[string]SkopeoSourceItem `json:"items"`
+}
+
+type SkopeoSourceItem struct {
+	Image SkopeoSourceImage `json:"image"`
+}
+
+type SkopeoSourceImage struct {
+	Name      string `json:"name"`
+	Digest    string `json:"digest"`
+	TLSVerify *bool  `json:"tls-verify,omitempty"`
+}
+
+func (SkopeoSource) isSource() {}
+
+// NewSkopeoSource creates a new, empty skopeo source.
+func NewSkopeoSource() *SkopeoSource {
+	return &SkopeoSource{
+		Items: make(map[string]SkopeoSourceItem),
+	}
+}
+
+// AddItem adds the image name@digest to the source.
+func (s *SkopeoSource) AddItem(name, digest string, tlsVerify *bool) {
+	s.Items[digest] = SkopeoSourceItem{
+		Image: SkopeoSourceImage{
+			Name:      name,
+			Digest:    digest,
+			TLSVerify: tlsVerify,
+		},
+	}
+}
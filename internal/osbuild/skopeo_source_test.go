@@ -0,0 +1,23 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkopeoSource_AddItem(t *testing.T) {
+	tlsVerify := false
+	source := NewSkopeoSource()
+	source.AddItem("registry.example.com/app", "sha256:checksum1", &tlsVerify)
+
+	assert.Equal(t, map[string]SkopeoSourceItem{
+		"sha256:checksum1": {
+			Image: SkopeoSourceImage{
+				Name:      "registry.example.com/app",
+				Digest:    "sha256:checksum1",
+				TLSVerify: &tlsVerify,
+			},
+		},
+	}, source.Items)
+}
@@ -0,0 +1,30 @@
+package osbuild
+
+// SkopeoStageOptions describe the container images to copy from the skopeo
+// source into the container storage of the tree
+type SkopeoStageOptions struct {
+	Images      []SkopeoStageImage `json:"images"`
+	Destination SkopeoDestination  `json:"destination"`
+}
+
+type SkopeoStageImage struct {
+	// Digest of the image in the skopeo source
+	Digest string `json:"digest"`
+	// Name the image is tagged with in the destination storage
+	Name string `json:"name"`
+}
+
+type SkopeoDestination struct {
+	Type        string `json:"type"`
+	StoragePath string `json:"storage-path,omitempty"`
+}
+
+func (SkopeoStageOptions) isStageOptions() {}
+
+// NewSkopeoStage creates a new skopeo Stage object.
+func NewSkopeoStage(options *SkopeoStageOptions) *Stage {
+	return &Stage{
+		Name:    "org.osbuild.skopeo",
+		Options: options,
+	}
+}
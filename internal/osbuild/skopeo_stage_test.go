@@ -0,0 +1,16 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSkopeoStage(t *testing.T) {
+	expectedStage := &Stage{
+		Name:    "org.osbuild.skopeo",
+		Options: &SkopeoStageOptions{},
+	}
+	actualStage := NewSkopeoStage(&SkopeoStageOptions{})
+	assert.Equal(t, expectedStage, actualStage)
+}
@@ -29,6 +29,10 @@ func (sources *Sources) UnmarshalJSON(data []byte) error {
 		switch name {
 		case "org.osbuild.files":
 			source = new(FilesSource)
+		case "org.osbuild.inline":
+			source = new(InlineSource)
+		case "org.osbuild.skopeo":
+			source = new(SkopeoSource)
 		default:
 			return errors.New("unexpected suorce name" + name)
 		}
@@ -72,6 +72,42 @@ func TestSource_UnmarshalJSON(t *testing.T) {
 				data: []byte(`{"org.osbuild.files":{"urls":{"checksum1":{"url":"url1"},"checksum2":{"url":"url2"}}}}`),
 			},
 		},
+		{
+			name: "files-proxy",
+			fields: fields{
+				Name: "org.osbuild.files",
+				Source: &FilesSource{URLs: map[string]FileSource{
+					"checksum1": FileSource{URL: "url1", Proxy: "http://proxy.example.com:3128"},
+				}},
+			},
+			args: args{
+				data: []byte(`{"org.osbuild.files":{"urls":{"checksum1":{"url":"url1","proxy":"http://proxy.example.com:3128"}}}}`),
+			},
+		},
+		{
+			name: "inline",
+			fields: fields{
+				Name: "org.osbuild.inline",
+				Source: &InlineSource{Items: map[string]InlineSourceItem{
+					"sha256:checksum1": InlineSourceItem{Encoding: "base64", Data: "aGVsbG8K"},
+				}},
+			},
+			args: args{
+				data: []byte(`{"org.osbuild.inline":{"items":{"sha256:checksum1":{"encoding":"base64","data":"aGVsbG8K"}}}}`),
+			},
+		},
+		{
+			name: "skopeo",
+			fields: fields{
+				Name: "org.osbuild.skopeo",
+				Source: &SkopeoSource{Items: map[string]SkopeoSourceItem{
+					"sha256:checksum1": SkopeoSourceItem{Image: SkopeoSourceImage{Name: "registry.example.com/app", Digest: "sha256:checksum1"}},
+				}},
+			},
+			args: args{
+				data: []byte(`{"org.osbuild.skopeo":{"items":{"sha256:checksum1":{"image":{"name":"registry.example.com/app","digest":"sha256:checksum1"}}}}}`),
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
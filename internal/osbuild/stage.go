@@ -62,6 +62,8 @@ func (stage *Stage) UnmarshalJSON(data []byte) error {
 		options = new(FirewallStageOptions)
 	case "org.osbuild.rhsm":
 		options = new(RHSMStageOptions)
+	case "org.osbuild.rhsm.facts":
+		options = new(RHSMFactsStageOptions)
 	case "org.osbuild.rpm":
 		options = new(RPMStageOptions)
 	case "org.osbuild.rpm-ostree":
@@ -70,6 +72,32 @@ func (stage *Stage) UnmarshalJSON(data []byte) error {
 		options = new(SystemdStageOptions)
 	case "org.osbuild.script":
 		options = new(ScriptStageOptions)
+	case "org.osbuild.sysconfig":
+		options = new(SysconfigStageOptions)
+	case "org.osbuild.kernel-cmdline":
+		options = new(KernelCmdlineStageOptions)
+	case "org.osbuild.first-boot":
+		options = new(FirstBootStageOptions)
+	case "org.osbuild.zipl":
+		options = new(ZiplStageOptions)
+	case "org.osbuild.mkdir":
+		options = new(MkdirStageOptions)
+	case "org.osbuild.copy":
+		options = new(CopyStageOptions)
+	case "org.osbuild.chown":
+		options = new(ChownStageOptions)
+	case "org.osbuild.chmod":
+		options = new(ChmodStageOptions)
+	case "org.osbuild.oscap.remediation":
+		options = new(OscapRemediationStageOptions)
+	case "org.osbuild.skopeo":
+		options = new(SkopeoStageOptions)
+	case "org.osbuild.dnf.config":
+		options = new(DNFConfigStageOptions)
+	case "org.osbuild.dnf.module-config":
+		options = new(DNFModuleConfigStageOptions)
+	case "org.osbuild.pki.update-ca-trust":
+		options = new(UpdateCATrustStageOptions)
 	default:
 		return fmt.Errorf("unexpected stage name: %s", rawStage.Name)
 	}
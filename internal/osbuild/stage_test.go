@@ -11,6 +11,9 @@ import (
 
 func TestStage_UnmarshalJSON(t *testing.T) {
 	nullUUID := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	manageRepos := true
+	timeout := 5
+	installWeakDeps := false
 	type fields struct {
 		Name    string
 		Options StageOptions
@@ -72,6 +75,36 @@ func TestStage_UnmarshalJSON(t *testing.T) {
 				data: []byte(`{"name":"org.osbuild.firewall","options":{}}`),
 			},
 		},
+		{
+			name: "dnf.config",
+			fields: fields{
+				Name: "org.osbuild.dnf.config",
+				Options: &DNFConfigStageOptions{
+					Config: &DNFConfig{
+						Main: &DNFConfigMain{
+							InstallWeakDeps: &installWeakDeps,
+							Exclude:         []string{"kernel-debug*"},
+							Proxy:           "http://proxy.example.com:3128",
+						},
+					},
+				},
+			},
+			args: args{
+				data: []byte(`{"name":"org.osbuild.dnf.config","options":{"config":{"main":{"install_weak_deps":false,"exclude":["kernel-debug*"],"proxy":"http://proxy.example.com:3128"}}}}`),
+			},
+		},
+		{
+			name: "dnf.module-config",
+			fields: fields{
+				Name: "org.osbuild.dnf.module-config",
+				Options: &DNFModuleConfigStageOptions{
+					Conf: DNFModuleConfig{Name: "nodejs", Stream: "16", State: "enabled", Profiles: []string{}},
+				},
+			},
+			args: args{
+				data: []byte(`{"name":"org.osbuild.dnf.module-config","options":{"conf":{"name":"nodejs","stream":"16","state":"enabled","profiles":[]}}}`),
+			},
+		},
 		{
 			name: "fix-bls",
 			fields: fields{
@@ -129,6 +162,25 @@ func TestStage_UnmarshalJSON(t *testing.T) {
 				data: []byte(`{"name":"org.osbuild.grub2","options":{"root_fs_uuid":"00000000-0000-0000-0000-000000000000","uefi":{"vendor":"vendor"}}}`),
 			},
 		},
+		{
+			name: "grub2-config",
+			fields: fields{
+				Name: "org.osbuild.grub2",
+				Options: &GRUB2StageOptions{
+					RootFilesystemUUID: nullUUID,
+					Config: &GRUB2Config{
+						Default:        "saved",
+						Timeout:        &timeout,
+						TerminalInput:  []string{"serial", "console"},
+						TerminalOutput: []string{"serial", "console"},
+						Serial:         "serial --unit=0 --speed=115200",
+					},
+				},
+			},
+			args: args{
+				data: []byte(`{"name":"org.osbuild.grub2","options":{"root_fs_uuid":"00000000-0000-0000-0000-000000000000","config":{"default":"saved","timeout":5,"terminal_input":["serial","console"],"terminal_output":["serial","console"],"serial":"serial --unit=0 --speed=115200"}}}`),
+			},
+		},
 		{
 			name: "grub2-separate-boot",
 			fields: fields{
@@ -172,6 +224,16 @@ func TestStage_UnmarshalJSON(t *testing.T) {
 				data: []byte(`{"name":"org.osbuild.locale","options":{"language":""}}`),
 			},
 		},
+		{
+			name: "pki.update-ca-trust",
+			fields: fields{
+				Name:    "org.osbuild.pki.update-ca-trust",
+				Options: &UpdateCATrustStageOptions{},
+			},
+			args: args{
+				data: []byte(`{"name":"org.osbuild.pki.update-ca-trust","options":{}}`),
+			},
+		},
 		{
 			name: "rhsm-empty",
 			fields: fields{
@@ -201,6 +263,34 @@ func TestStage_UnmarshalJSON(t *testing.T) {
 				data: []byte(`{"name":"org.osbuild.rhsm","options":{"dnf-plugins":{"product-id":{"enabled":false},"subscription-manager":{"enabled":false}}}}`),
 			},
 		},
+		{
+			name: "rhsm-submanager",
+			fields: fields{
+				Name: "org.osbuild.rhsm",
+				Options: &RHSMStageOptions{
+					SubMan: &RHSMStageOptionsSubMan{
+						Rhsm: &SubManConfigRHSMSection{
+							ManageRepos: &manageRepos,
+						},
+					},
+				},
+			},
+			args: args{
+				data: []byte(`{"name":"org.osbuild.rhsm","options":{"subscription-manager":{"rhsm":{"manage_repos":true}}}}`),
+			},
+		},
+		{
+			name: "rhsm.facts",
+			fields: fields{
+				Name: "org.osbuild.rhsm.facts",
+				Options: &RHSMFactsStageOptions{
+					Facts: RHSMFacts{APIType: "weldr"},
+				},
+			},
+			args: args{
+				data: []byte(`{"name":"org.osbuild.rhsm.facts","options":{"facts":{"image-builder.osbuild-composer.api-type":"weldr"}}}`),
+			},
+		},
 		{
 			name: "rpm-empty",
 			fields: fields{
@@ -298,6 +388,82 @@ func TestStage_UnmarshalJSON(t *testing.T) {
 				data: []byte(`{"name":"org.osbuild.timezone","options":{"zone":""}}`),
 			},
 		},
+		{
+			name: "mkdir",
+			fields: fields{
+				Name: "org.osbuild.mkdir",
+				Options: &MkdirStageOptions{
+					Paths: []MkdirStagePath{{Path: "/etc/foo", Parents: true}},
+				},
+			},
+			args: args{
+				data: []byte(`{"name":"org.osbuild.mkdir","options":{"paths":[{"path":"/etc/foo","parents":true}]}}`),
+			},
+		},
+		{
+			name: "copy",
+			fields: fields{
+				Name: "org.osbuild.copy",
+				Options: &CopyStageOptions{
+					Paths: []CopyStagePath{{From: "sha256:checksum1", To: "/etc/foo"}},
+				},
+			},
+			args: args{
+				data: []byte(`{"name":"org.osbuild.copy","options":{"paths":[{"from":"sha256:checksum1","to":"/etc/foo"}]}}`),
+			},
+		},
+		{
+			name: "chown",
+			fields: fields{
+				Name: "org.osbuild.chown",
+				Options: &ChownStageOptions{
+					Items: map[string]ChownStagePathOptions{"/etc/foo": {User: "root", Group: "wheel"}},
+				},
+			},
+			args: args{
+				data: []byte(`{"name":"org.osbuild.chown","options":{"items":{"/etc/foo":{"user":"root","group":"wheel"}}}}`),
+			},
+		},
+		{
+			name: "chmod",
+			fields: fields{
+				Name: "org.osbuild.chmod",
+				Options: &ChmodStageOptions{
+					Items: map[string]ChmodStagePathOptions{"/etc/foo": {Mode: "0600"}},
+				},
+			},
+			args: args{
+				data: []byte(`{"name":"org.osbuild.chmod","options":{"items":{"/etc/foo":{"mode":"0600"}}}}`),
+			},
+		},
+		{
+			name: "oscap.remediation",
+			fields: fields{
+				Name: "org.osbuild.oscap.remediation",
+				Options: &OscapRemediationStageOptions{
+					Config: OscapConfig{
+						Datastream: "/usr/share/xml/scap/ssg/content/ssg-rhel8-ds.xml",
+						ProfileID:  "xccdf_org.ssgproject.content_profile_cis",
+					},
+				},
+			},
+			args: args{
+				data: []byte(`{"name":"org.osbuild.oscap.remediation","options":{"config":{"datastream":"/usr/share/xml/scap/ssg/content/ssg-rhel8-ds.xml","profile_id":"xccdf_org.ssgproject.content_profile_cis"}}}`),
+			},
+		},
+		{
+			name: "skopeo",
+			fields: fields{
+				Name: "org.osbuild.skopeo",
+				Options: &SkopeoStageOptions{
+					Images:      []SkopeoStageImage{{Digest: "sha256:checksum1", Name: "registry.example.com/app"}},
+					Destination: SkopeoDestination{Type: "containers-storage"},
+				},
+			},
+			args: args{
+				data: []byte(`{"name":"org.osbuild.skopeo","options":{"images":[{"digest":"sha256:checksum1","name":"registry.example.com/app"}],"destination":{"type":"containers-storage"}}}`),
+			},
+		},
 		{
 			name: "users",
 			fields: fields{
@@ -0,0 +1,19 @@
+package osbuild
+
+// An UpdateCATrustStageOptions struct is empty, as the stage takes no
+// options.
+//
+// The UpdateCATrustStage regenerates the consolidated trust store of the
+// tree from the certificates in /etc/pki/ca-trust/source.
+type UpdateCATrustStageOptions struct {
+}
+
+func (UpdateCATrustStageOptions) isStageOptions() {}
+
+// NewUpdateCATrustStage creates a new UpdateCATrustStage.
+func NewUpdateCATrustStage() *Stage {
+	return &Stage{
+		Name:    "org.osbuild.pki.update-ca-trust",
+		Options: &UpdateCATrustStageOptions{},
+	}
+}
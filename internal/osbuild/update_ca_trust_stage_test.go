@@ -0,0 +1,16 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUpdateCATrustStage(t *testing.T) {
+	expectedStage := &Stage{
+		Name:    "org.osbuild.pki.update-ca-trust",
+		Options: &UpdateCATrustStageOptions{},
+	}
+	actualStage := NewUpdateCATrustStage()
+	assert.Equal(t, expectedStage, actualStage)
+}
@@ -15,6 +15,7 @@ type UsersStageOptionsUser struct {
 	Shell       *string  `json:"shell,omitempty"`
 	Password    *string  `json:"password,omitempty"`
 	Key         *string  `json:"key,omitempty"`
+	ExpireDate  *int     `json:"expiredate,omitempty"`
 }
 
 func NewUsersStage(options *UsersStageOptions) *Stage {
@@ -0,0 +1,134 @@
+package rpmmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// cacheLockFile is locked by everything using a cache directory: shared by
+// the calls to dnf-json and exclusively by CleanCache, so that metadata is
+// never removed while it is read or written. dnf-json additionally locks the
+// cache exclusively while loading repositories, because concurrent dnf
+// processes would write the same metadata files.
+const cacheLockFile = ".lock"
+
+// lockCache creates dir if needed and locks it, shared or exclusively. The
+// lock is released by closing the returned file.
+func lockCache(dir string, exclusive bool) (*os.File, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, cacheLockFile), os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	how := unix.LOCK_SH
+	if exclusive {
+		how = unix.LOCK_EX
+	}
+	for {
+		err = unix.Flock(int(f.Fd()), how)
+		if err != unix.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cannot lock cache %s: %v", dir, err)
+	}
+	return f, nil
+}
+
+// A cacheEntry is a file or directory at the top level of a cache directory
+type cacheEntry struct {
+	name     string
+	size     uint64
+	lastUsed time.Time
+}
+
+// readCacheEntries returns the entries of the cache in dir, with their total
+// size and the time any file in them was last modified. Hidden files, like
+// the lock files, are not entries.
+func readCacheEntries(dir string) ([]cacheEntry, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []cacheEntry
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		entry := cacheEntry{name: info.Name()}
+		err = filepath.Walk(filepath.Join(dir, info.Name()), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				entry.size += uint64(info.Size())
+			}
+			if info.ModTime().After(entry.lastUsed) {
+				entry.lastUsed = info.ModTime()
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// CleanCache removes the entries of the rpmmd cache in dir which were not
+// used for longer than maxAge, and then the least recently used ones until
+// the cache takes up at most maxSize bytes. A zero maxAge or maxSize disables
+// the respective limit. The entries are the metadata of single repositories,
+// the solv files dnf creates from them and the downloaded GPG keys. It waits
+// for running calls using the cache to finish, and removed entries are
+// downloaded again when they are needed.
+func CleanCache(dir string, maxSize uint64, maxAge time.Duration) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	lock, err := lockCache(dir, true)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	entries, err := readCacheEntries(dir)
+	if err != nil {
+		return fmt.Errorf("cannot read cache %s: %v", dir, err)
+	}
+
+	var size uint64
+	for _, entry := range entries {
+		size += entry.size
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastUsed.Before(entries[j].lastUsed)
+	})
+	now := time.Now()
+	for _, entry := range entries {
+		expired := maxAge > 0 && now.Sub(entry.lastUsed) > maxAge
+		if !expired && (maxSize == 0 || size <= maxSize) {
+			break
+		}
+		err = os.RemoveAll(filepath.Join(dir, entry.name))
+		if err != nil {
+			return fmt.Errorf("cannot remove %s from cache: %v", entry.name, err)
+		}
+		size -= entry.size
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package rpmmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// entries of 100 bytes, the oldest first
+	now := time.Now()
+	names := []string{"0-0123456789abcdef", "0.solv", "1-0123456789abcdef", "gpgkeys"}
+	for i, name := range names {
+		path := filepath.Join(dir, name)
+		if name == "0.solv" {
+			require.NoError(t, ioutil.WriteFile(path, make([]byte, 100), 0600))
+		} else {
+			require.NoError(t, os.MkdirAll(path, 0700))
+			path = filepath.Join(path, "file")
+			require.NoError(t, ioutil.WriteFile(path, make([]byte, 100), 0600))
+		}
+		mtime := now.Add(time.Duration(i-len(names)) * time.Hour)
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+		require.NoError(t, os.Chtimes(filepath.Join(dir, name), mtime, mtime))
+	}
+	// the first repository was used recently
+	require.NoError(t, os.Chtimes(filepath.Join(dir, names[0]), now, now))
+
+	readNames := func() []string {
+		entries, err := readCacheEntries(dir)
+		require.NoError(t, err)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.name)
+		}
+		return names
+	}
+
+	require.NoError(t, CleanCache(dir, 400, 0))
+	assert.ElementsMatch(t, names, readNames())
+
+	require.NoError(t, CleanCache(dir, 0, 0))
+	assert.ElementsMatch(t, names, readNames())
+
+	require.NoError(t, CleanCache(dir, 0, 150*time.Minute))
+	assert.ElementsMatch(t, []string{"0-0123456789abcdef", "1-0123456789abcdef", "gpgkeys"}, readNames())
+
+	require.NoError(t, CleanCache(dir, 150, 0))
+	assert.ElementsMatch(t, []string{"0-0123456789abcdef"}, readNames())
+	assert.FileExists(t, filepath.Join(dir, cacheLockFile))
+
+	require.NoError(t, CleanCache(dir, 1, time.Hour))
+	assert.Empty(t, readNames())
+
+	require.NoError(t, CleanCache(filepath.Join(dir, "non-existing"), 1, time.Hour))
+}
+
+func TestCacheLocking(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	shared, err := lockCache(dir, false)
+	require.NoError(t, err)
+	other, err := lockCache(dir, false)
+	require.NoError(t, err)
+	other.Close()
+
+	cleaned := make(chan error)
+	go func() {
+		cleaned <- CleanCache(dir, 1, 0)
+	}()
+	select {
+	case <-cleaned:
+		t.Fatal("CleanCache did not wait for the shared lock")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	shared.Close()
+	assert.NoError(t, <-cleaned)
+}
@@ -0,0 +1,155 @@
+package rpmmd
+
+import (
+	"context"
+	"sync"
+)
+
+// maxParallelDepsolves limits the number of dnf-json processes DepsolveAll
+// runs at the same time
+const maxParallelDepsolves = 4
+
+// A PackageSet is the input of a single depsolve transaction: the packages to
+// install, the packages to exclude, the module streams to enable (in the
+// name:stream format) and the modules to disable (by name). NoWeakDeps
+// resolves the packages without their weak dependencies, like dnf's
+// install_weak_deps=False. WithSources additionally looks up the source
+// packages of the resolved packages.
+//
+// Pinned are NEVRAs, e.g. from a frozen blueprint, which packages of the
+// same name and architecture are resolved to if they are installed at all.
+// Depsolving fails with a DNFError of the PinnedPackagesUnavailable kind if
+// any of them is not available anymore.
+type PackageSet struct {
+	Include         []string
+	Exclude         []string
+	Modules         []string
+	DisabledModules []string
+	NoWeakDeps      bool
+	WithSources     bool
+	Pinned          []string
+}
+
+// A DepsolveResult is the output of a single depsolve transaction. Modules
+// is the state of all modules after the transaction, which includes the
+// default streams dnf enabled for the resolved packages. Mirrors maps the
+// names of the repositories to the base URL their metadata was loaded from,
+// which is the first working mirror for metalinks and mirrorlists.
+//
+// SourcePackages are only set for package sets WithSources: the source
+// packages the resolved packages were built from, sorted by name, with the
+// src architecture. Source packages not available in any of the repositories
+// have neither a remote location nor a checksum.
+type DepsolveResult struct {
+	Packages       []PackageSpec
+	Checksums      map[string]string
+	Modules        []ModuleState
+	Mirrors        map[string]string
+	SourcePackages []PackageSpec
+}
+
+// The states a module can be in after depsolving
+const (
+	ModuleStateEnabled  = "enabled"
+	ModuleStateDisabled = "disabled"
+)
+
+// A ModuleState is the state dnf records for a module in
+// /etc/dnf/modules.d. Stream is empty for disabled modules.
+type ModuleState struct {
+	Name   string `json:"name"`
+	Stream string `json:"stream,omitempty"`
+	State  string `json:"state"`
+}
+
+// The names of the package sets of a compose, after the pipelines they are
+// installed in
+const (
+	BuildPackageSet = "build"
+	OSPackageSet    = "os"
+)
+
+// A PackageSetError is returned by DepsolvePackageSets when a package set
+// could not be resolved. Name is the name of the package set.
+type PackageSetError struct {
+	Name string
+	Err  error
+}
+
+func (e *PackageSetError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PackageSetError) Unwrap() error {
+	return e.Err
+}
+
+// A DepsolveRequest is the input of a single DepsolvePackageSets call, e.g.
+// the package sets of one image of a compose
+type DepsolveRequest struct {
+	Sets             map[string]PackageSet
+	Repos            []RepoConfig
+	ModulePlatformID string
+	Arch             string
+}
+
+// A DepsolveRequestError is returned by DepsolveAll when a request could not
+// be resolved. Index is the position of the request. Err is usually a
+// *PackageSetError naming the package set which failed.
+type DepsolveRequestError struct {
+	Index int
+	Err   error
+}
+
+func (e *DepsolveRequestError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DepsolveRequestError) Unwrap() error {
+	return e.Err
+}
+
+// DepsolveAll resolves the package sets of multiple requests, such as the
+// images of a compose with different architectures and repositories,
+// concurrently, sharing the metadata cache of rpmmd. The results are in the
+// order of requests. If any request cannot be resolved, the error of the
+// first one is returned as a *DepsolveRequestError. Canceling ctx cancels all
+// requests.
+//
+// The dnf-json daemon serves one call at a time, so each of multiple
+// requests runs in a dnf-json process of its own instead.
+func DepsolveAll(ctx context.Context, rpmmd RPMMD, requests []DepsolveRequest) ([]map[string]DepsolveResult, error) {
+	if len(requests) > 1 {
+		ctx = withoutService(ctx)
+	}
+
+	results := make([]map[string]DepsolveResult, len(requests))
+	errs := make([]error, len(requests))
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, maxParallelDepsolves)
+	for i := range requests {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-slots }()
+
+			r := requests[i]
+			results[i], errs[i] = rpmmd.DepsolvePackageSets(ctx, r.Sets, r.Repos, r.ModulePlatformID, r.Arch)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, &DepsolveRequestError{i, err}
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,130 @@
+package rpmmd
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRPMMD resolves every spec to a package of the same name and the
+// architecture of the request, and fails for specs called "missing"
+type fakeRPMMD struct {
+	RPMMD
+
+	mu      sync.Mutex
+	running int
+	maximum int
+}
+
+func (f *fakeRPMMD) DepsolvePackageSets(ctx context.Context, sets map[string]PackageSet, repos []RepoConfig, modulePlatformID, arch string) (map[string]DepsolveResult, error) {
+	f.mu.Lock()
+	f.running++
+	if f.running > f.maximum {
+		f.maximum = f.running
+	}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.running--
+		f.mu.Unlock()
+	}()
+
+	results := make(map[string]DepsolveResult, len(sets))
+	for name, set := range sets {
+		var packages []PackageSpec
+		for _, spec := range set.Include {
+			if spec == "missing" {
+				return nil, &PackageSetError{name, errors.New("no package matches missing")}
+			}
+			packages = append(packages, PackageSpec{Name: spec, Arch: arch})
+		}
+		results[name] = DepsolveResult{Packages: packages}
+	}
+	return results, nil
+}
+
+func TestDepsolveAll(t *testing.T) {
+	rpm := &fakeRPMMD{}
+	arches := []string{"x86_64", "aarch64", "ppc64le", "s390x", "x86_64", "aarch64", "ppc64le", "s390x"}
+	requests := make([]DepsolveRequest, len(arches))
+	for i, arch := range arches {
+		requests[i] = DepsolveRequest{
+			Sets: map[string]PackageSet{
+				OSPackageSet:    {Include: []string{"bash"}},
+				BuildPackageSet: {Include: []string{"rpm"}},
+			},
+			ModulePlatformID: "platform:f33",
+			Arch:             arch,
+		}
+	}
+
+	results, err := DepsolveAll(context.Background(), rpm, requests)
+	require.NoError(t, err)
+	require.Len(t, results, len(requests))
+	for i, result := range results {
+		assert.Equal(t, []PackageSpec{{Name: "bash", Arch: arches[i]}}, result[OSPackageSet].Packages)
+		assert.Equal(t, []PackageSpec{{Name: "rpm", Arch: arches[i]}}, result[BuildPackageSet].Packages)
+	}
+	assert.LessOrEqual(t, rpm.maximum, maxParallelDepsolves)
+
+	requests[2].Sets[BuildPackageSet] = PackageSet{Include: []string{"missing"}}
+	requests[5].Sets[OSPackageSet] = PackageSet{Include: []string{"missing"}}
+	_, err = DepsolveAll(context.Background(), rpm, requests)
+	var requestErr *DepsolveRequestError
+	require.True(t, errors.As(err, &requestErr))
+	assert.Equal(t, 2, requestErr.Index)
+	var setErr *PackageSetError
+	require.True(t, errors.As(err, &setErr))
+	assert.Equal(t, BuildPackageSet, setErr.Name)
+	assert.EqualError(t, err, "no package matches missing")
+}
+
+func TestDepsolveAllConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the daemon serves one call at a time, so it must not be used
+	socketPath := serveDNFJSON(t, dir, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("DepsolveAll called the dnf-json daemon")
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	// a dnf-json which only answers when two calls run at the same time
+	dnfJSON := filepath.Join(dir, "dnf-json")
+	err = ioutil.WriteFile(dnfJSON, []byte(`#!/bin/sh
+cat >/dev/null
+touch `+dir+`/running.$$
+i=0
+while [ $(ls `+dir+`/running.* | wc -l) -lt 2 ]; do
+	i=$((i+1))
+	if [ $i -gt 100 ]; then
+		echo '{"kind": "Timeout", "reason": "the calls did not overlap"}'
+		exit 10
+	fi
+	sleep 0.1
+done
+echo '{"results": {"os": {"checksums": {"0": "sha256:01"}, "dependencies": []}}}'
+`), 0755)
+	require.NoError(t, err)
+
+	rpm := NewRPMMDService(dir, dnfJSON, socketPath)
+	requests := []DepsolveRequest{
+		{Sets: map[string]PackageSet{OSPackageSet: {Include: []string{"bash"}}}, ModulePlatformID: "platform:f33", Arch: "x86_64"},
+		{Sets: map[string]PackageSet{OSPackageSet: {Include: []string{"bash"}}}, ModulePlatformID: "platform:f33", Arch: "aarch64"},
+	}
+	results, err := DepsolveAll(context.Background(), rpm, requests)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	for _, result := range results {
+		assert.Equal(t, map[string]string{"0": "sha256:01"}, result[OSPackageSet].Checksums)
+	}
+}
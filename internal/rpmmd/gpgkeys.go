@@ -0,0 +1,183 @@
+package rpmmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// gpgKeyFetchTimeout limits the time to download a single GPG key
+const gpgKeyFetchTimeout = 30 * time.Second
+
+const pgpPublicKeyHeader = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+
+// isGPGKeyURL returns whether the gpgkey of a repository is a list of https
+// URLs of keys rather than the key material itself
+func isGPGKeyURL(key string) bool {
+	return strings.HasPrefix(strings.TrimSpace(key), "https://")
+}
+
+// validateGPGKey checks that the URLs in gpgkey are https URLs and that a
+// pinned checksum belongs to a single key URL
+func (repo RepoConfig) validateGPGKey() error {
+	if !isGPGKeyURL(repo.GPGKey) {
+		if repo.GPGKeyChecksum != "" {
+			return fmt.Errorf("gpgkey_checksum requires gpgkey to be an https URL")
+		}
+		return nil
+	}
+
+	urls := strings.Fields(repo.GPGKey)
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return fmt.Errorf("invalid gpgkey URL %q, only https URLs are supported", u)
+		}
+	}
+	if repo.GPGKeyChecksum != "" {
+		if len(urls) > 1 {
+			return fmt.Errorf("gpgkey_checksum can only be used with a single gpgkey URL")
+		}
+		if !strings.HasPrefix(repo.GPGKeyChecksum, "sha256:") {
+			return fmt.Errorf("invalid gpgkey_checksum %q, expected sha256:<hex digest>", repo.GPGKeyChecksum)
+		}
+	}
+	return nil
+}
+
+func gpgKeyChecksum(key []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(key))
+}
+
+// ResolveGPGKeys returns a copy of repos in which gpgkeys given as https
+// URLs are replaced by the key material, so that it can be embedded into
+// manifests. Keys are downloaded only once and cached in the gpgkeys
+// directory of the cache dir. If a repository pins the checksum of its key,
+// the cached or downloaded key must match it.
+func (r *rpmmdImpl) ResolveGPGKeys(ctx context.Context, repos []RepoConfig) ([]RepoConfig, error) {
+	result := make([]RepoConfig, len(repos))
+	var lock *os.File
+	defer func() {
+		if lock != nil {
+			lock.Close()
+		}
+	}()
+	for i, repo := range repos {
+		if isGPGKeyURL(repo.GPGKey) {
+			err := repo.validateGPGKey()
+			if err != nil {
+				return nil, fmt.Errorf("repository %s: %v", repo.Name, err)
+			}
+			if lock == nil {
+				lock, err = lockCache(r.CacheDir, false)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			var keys []string
+			for _, u := range strings.Fields(repo.GPGKey) {
+				key, err := r.gpgKey(ctx, u, repo.GPGKeyChecksum, repo.Proxy)
+				if err != nil {
+					return nil, fmt.Errorf("repository %s: %v", repo.Name, err)
+				}
+				keys = append(keys, key)
+			}
+			repo.GPGKey = strings.Join(keys, "\n")
+			repo.GPGKeyChecksum = ""
+		}
+		result[i] = repo
+	}
+	return result, nil
+}
+
+// gpgKey returns the key at keyURL from the cache, downloading it if it is
+// not cached yet or doesn't match checksum
+func (r *rpmmdImpl) gpgKey(ctx context.Context, keyURL, checksum, proxy string) (string, error) {
+	dir := filepath.Join(r.CacheDir, "gpgkeys")
+	path := filepath.Join(dir, fmt.Sprintf("%x.asc", sha256.Sum256([]byte(keyURL))))
+
+	key, err := ioutil.ReadFile(path)
+	if err == nil && (checksum == "" || gpgKeyChecksum(key) == checksum) {
+		// record the use for CleanCache
+		now := time.Now()
+		_ = os.Chtimes(path, now, now)
+		return string(key), nil
+	}
+
+	key, err = fetchGPGKey(ctx, keyURL, proxy)
+	if err != nil {
+		return "", err
+	}
+	if checksum != "" && gpgKeyChecksum(key) != checksum {
+		return "", fmt.Errorf("the key at %s has checksum %s instead of the pinned %s", keyURL, gpgKeyChecksum(key), checksum)
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", err
+	}
+	// write to a temporary file first, so that concurrent readers never see
+	// a partial key
+	tmp, err := ioutil.TempFile(dir, ".key-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(key)
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return "", err
+	}
+
+	return string(key), nil
+}
+
+func fetchGPGKey(ctx context.Context, keyURL, proxy string) ([]byte, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %v", proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	client := &http.Client{Transport: transport, Timeout: gpgKeyFetchTimeout}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, keyURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch GPG key: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot fetch GPG key %s: %s", keyURL, resp.Status)
+	}
+
+	key, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch GPG key %s: %v", keyURL, err)
+	}
+	if !strings.Contains(string(key), pgpPublicKeyHeader) {
+		return nil, fmt.Errorf("%s is not an armored PGP public key", keyURL)
+	}
+	return key, nil
+}
@@ -0,0 +1,121 @@
+package rpmmd
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testGPGKey = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mQINBFz6ZfIBEADJ5yGcMhqNn3THBWP7KqSEK5WyKrSzLPGPN5RBHTvkD9ihbHqP
+-----END PGP PUBLIC KEY BLOCK-----
+`
+
+func TestResolveGPGKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(testGPGKey))
+	}))
+	defer server.Close()
+
+	// trust the certificate of the test server
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = server.Client().Transport
+	defer func() { http.DefaultTransport = defaultTransport }()
+
+	rpm := &rpmmdImpl{CacheDir: dir}
+	checksum := gpgKeyChecksum([]byte(testGPGKey))
+
+	repos := []RepoConfig{
+		{Name: "material", GPGKey: testGPGKey},
+		{Name: "url", GPGKey: server.URL + "/key.gpg"},
+		{Name: "pinned", GPGKey: server.URL + "/key.gpg", GPGKeyChecksum: checksum},
+		{Name: "none"},
+	}
+	resolved, err := rpm.ResolveGPGKeys(context.Background(), repos)
+	require.NoError(t, err)
+	assert.Equal(t, []RepoConfig{
+		{Name: "material", GPGKey: testGPGKey},
+		{Name: "url", GPGKey: testGPGKey},
+		{Name: "pinned", GPGKey: testGPGKey},
+		{Name: "none"},
+	}, resolved)
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, server.URL+"/key.gpg", repos[1].GPGKey, "repos must not be modified")
+
+	// cached keys don't need the server anymore
+	server.Close()
+	resolved, err = rpm.ResolveGPGKeys(context.Background(), repos[1:2])
+	require.NoError(t, err)
+	assert.Equal(t, testGPGKey, resolved[0].GPGKey)
+	assert.Equal(t, 1, requests)
+}
+
+func TestResolveGPGKeysErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/key.gpg":
+			_, _ = w.Write([]byte(testGPGKey))
+		case "/not-a-key":
+			_, _ = w.Write([]byte("<html>Not a key</html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = server.Client().Transport
+	defer func() { http.DefaultTransport = defaultTransport }()
+
+	rpm := &rpmmdImpl{CacheDir: dir}
+
+	_, err = rpm.ResolveGPGKeys(context.Background(), []RepoConfig{{Name: "pinned", GPGKey: server.URL + "/key.gpg", GPGKeyChecksum: "sha256:00"}})
+	assert.EqualError(t, err, "repository pinned: the key at "+server.URL+"/key.gpg has checksum "+gpgKeyChecksum([]byte(testGPGKey))+" instead of the pinned sha256:00")
+
+	_, err = rpm.ResolveGPGKeys(context.Background(), []RepoConfig{{Name: "html", GPGKey: server.URL + "/not-a-key"}})
+	assert.EqualError(t, err, "repository html: "+server.URL+"/not-a-key is not an armored PGP public key")
+
+	_, err = rpm.ResolveGPGKeys(context.Background(), []RepoConfig{{Name: "missing", GPGKey: server.URL + "/missing.gpg"}})
+	assert.EqualError(t, err, "repository missing: cannot fetch GPG key "+server.URL+"/missing.gpg: 404 Not Found")
+}
+
+func TestValidateGPGKey(t *testing.T) {
+	cases := []struct {
+		repo RepoConfig
+		err  string
+	}{
+		{RepoConfig{GPGKey: testGPGKey}, ""},
+		{RepoConfig{GPGKey: "https://example.com/key.gpg https://example.com/other.gpg"}, ""},
+		{RepoConfig{GPGKey: "https://example.com/key.gpg", GPGKeyChecksum: "sha256:01"}, ""},
+		{RepoConfig{GPGKey: "https://example.com/key.gpg http://example.com/other.gpg"}, `invalid gpgkey URL "http://example.com/other.gpg", only https URLs are supported`},
+		{RepoConfig{GPGKey: "https://example.com/key.gpg https://example.com/other.gpg", GPGKeyChecksum: "sha256:01"}, "gpgkey_checksum can only be used with a single gpgkey URL"},
+		{RepoConfig{GPGKey: "https://example.com/key.gpg", GPGKeyChecksum: "01"}, `invalid gpgkey_checksum "01", expected sha256:<hex digest>`},
+		{RepoConfig{GPGKey: testGPGKey, GPGKeyChecksum: "sha256:01"}, "gpgkey_checksum requires gpgkey to be an https URL"},
+	}
+
+	for _, c := range cases {
+		err := c.repo.validateGPGKey()
+		if c.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, c.err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package rpmmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// LocalRepositories returns the repositories in dir, for depsolving without
+// network access. Every subdirectory containing repodata/repomd.xml is a
+// repository named after the subdirectory. The packages of a repository are
+// expected next to its repodata, as created by createrepo or reposync, and
+// are referred to with file:// URLs. A gpgkey file in the subdirectory is
+// used to check the signatures of the packages.
+func LocalRepositories(dir string) ([]RepoConfig, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, &RepositoryError{fmt.Sprintf("cannot read local repositories: %v", err)}
+	}
+
+	var repos []RepoConfig
+	for _, info := range infos {
+		path := filepath.Join(dir, info.Name())
+		if !info.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(path, "repodata", "repomd.xml")); err != nil {
+			continue
+		}
+
+		repo := RepoConfig{
+			Name:    info.Name(),
+			BaseURL: "file://" + path,
+			// reading local metadata is cheap, so that changes to it are
+			// always picked up
+			MetadataExpire: "0",
+		}
+		key, err := ioutil.ReadFile(filepath.Join(path, "gpgkey"))
+		if err == nil {
+			repo.GPGKey = string(key)
+			repo.CheckGPG = true
+		} else if !os.IsNotExist(err) {
+			return nil, &RepositoryError{fmt.Sprintf("cannot read the gpgkey of local repository %s: %v", repo.Name, err)}
+		}
+		repos = append(repos, repo)
+	}
+
+	if len(repos) == 0 {
+		return nil, &RepositoryError{fmt.Sprintf("%s contains no repositories", dir)}
+	}
+	return repos, nil
+}
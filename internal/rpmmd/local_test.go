@@ -0,0 +1,39 @@
+package rpmmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalRepositories(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = LocalRepositories(dir)
+	assert.EqualError(t, err, dir+" contains no repositories")
+
+	for _, name := range []string{"baseos", "appstream"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, name, "repodata"), 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name, "repodata", "repomd.xml"), []byte("<repomd/>"), 0600))
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "baseos", "gpgkey"), []byte(testGPGKey), 0600))
+	// neither a directory nor a repository
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README"), nil, 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "empty"), 0700))
+
+	repos, err := LocalRepositories(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []RepoConfig{
+		{Name: "appstream", BaseURL: "file://" + dir + "/appstream", MetadataExpire: "0"},
+		{Name: "baseos", BaseURL: "file://" + dir + "/baseos", MetadataExpire: "0", GPGKey: testGPGKey, CheckGPG: true},
+	}, repos)
+
+	_, err = LocalRepositories(filepath.Join(dir, "non-existing"))
+	assert.Error(t, err)
+}
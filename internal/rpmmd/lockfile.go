@@ -0,0 +1,98 @@
+package rpmmd
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// LockfileVersion is the version of the lockfile format written by
+// MarshalLockfile. Fields may be added to the format without changing the
+// version, but not renamed, removed or given a different meaning.
+const LockfileVersion = 1
+
+// lockfile is the stable format in which depsolved packages are exported for
+// other tools, such as SBOM generators and vulnerability scanners. Unlike
+// PackageSpec, whose serialization is internal to osbuild-composer, it must
+// only change in compatible ways.
+type lockfile struct {
+	Version  int             `json:"version"`
+	Packages []lockedPackage `json:"packages"`
+}
+
+type lockedPackage struct {
+	Name      string `json:"name"`
+	Epoch     uint   `json:"epoch"`
+	Version   string `json:"version"`
+	Release   string `json:"release"`
+	Arch      string `json:"arch"`
+	NEVRA     string `json:"nevra"`
+	Repo      string `json:"repo,omitempty"`
+	Checksum  string `json:"checksum,omitempty"`
+	URL       string `json:"url,omitempty"`
+	License   string `json:"license,omitempty"`
+	Summary   string `json:"summary,omitempty"`
+	SourceRPM string `json:"sourcerpm,omitempty"`
+}
+
+// MarshalLockfile returns the lockfile of depsolved packages: a JSON object
+// with the version of the format and the packages, with their NEVRA, the
+// name of their repository, their checksum, the URL they are downloaded
+// from, their license, summary and source RPM.
+func MarshalLockfile(packages []PackageSpec) ([]byte, error) {
+	l := lockfile{
+		Version:  LockfileVersion,
+		Packages: make([]lockedPackage, len(packages)),
+	}
+	for i, pkg := range packages {
+		l.Packages[i] = lockedPackage{
+			Name:      pkg.Name,
+			Epoch:     pkg.Epoch,
+			Version:   pkg.Version,
+			Release:   pkg.Release,
+			Arch:      pkg.Arch,
+			NEVRA:     pkg.GetNEVRA(),
+			Repo:      pkg.Repo,
+			Checksum:  pkg.Checksum,
+			URL:       pkg.RemoteLocation,
+			License:   pkg.License,
+			Summary:   pkg.Summary,
+			SourceRPM: pkg.SourceRPM,
+		}
+	}
+	return json.MarshalIndent(l, "", "  ")
+}
+
+// UnmarshalLockfile returns the packages of a lockfile written by
+// MarshalLockfile. It fails for lockfiles of newer versions of the format.
+// The returned packages only have the fields stored in the lockfile.
+func UnmarshalLockfile(data []byte) ([]PackageSpec, error) {
+	var l lockfile
+	err := json.Unmarshal(data, &l)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lockfile: %v", err)
+	}
+	if l.Version < 1 || l.Version > LockfileVersion {
+		return nil, fmt.Errorf("unsupported lockfile version %d, expected %d", l.Version, LockfileVersion)
+	}
+
+	packages := make([]PackageSpec, len(l.Packages))
+	for i, pkg := range l.Packages {
+		if pkg.Name == "" || pkg.Version == "" || pkg.Release == "" || pkg.Arch == "" {
+			return nil, fmt.Errorf("invalid lockfile: package %d has an incomplete NEVRA", i)
+		}
+		packages[i] = PackageSpec{
+			Name:           pkg.Name,
+			Epoch:          pkg.Epoch,
+			Version:        pkg.Version,
+			Release:        pkg.Release,
+			Arch:           pkg.Arch,
+			Repo:           pkg.Repo,
+			Checksum:       pkg.Checksum,
+			RemoteLocation: pkg.URL,
+			License:        pkg.License,
+			Summary:        pkg.Summary,
+			SourceRPM:      pkg.SourceRPM,
+		}
+	}
+	return packages, nil
+}
@@ -0,0 +1,45 @@
+package rpmmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockfile(t *testing.T) {
+	packages := []PackageSpec{
+		{Name: "bash", Version: "5.0", Release: "1", Arch: "x86_64", Repo: "fedora", RemoteLocation: "http://example.com/fedora/bash-5.0-1.x86_64.rpm", Checksum: "sha256:01", CheckGPG: true, Reason: ReasonUser, License: "GPLv3+", Summary: "The GNU Bourne Again shell", SourceRPM: "bash-5.0-1.src.rpm"},
+		{Name: "shadow-utils", Epoch: 2, Version: "4.8", Release: "1", Arch: "x86_64"},
+	}
+
+	data, err := MarshalLockfile(packages)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"version": 1,
+		"packages": [
+			{"name": "bash", "epoch": 0, "version": "5.0", "release": "1", "arch": "x86_64", "nevra": "bash-5.0-1.x86_64", "repo": "fedora", "checksum": "sha256:01", "url": "http://example.com/fedora/bash-5.0-1.x86_64.rpm", "license": "GPLv3+", "summary": "The GNU Bourne Again shell", "sourcerpm": "bash-5.0-1.src.rpm"},
+			{"name": "shadow-utils", "epoch": 2, "version": "4.8", "release": "1", "arch": "x86_64", "nevra": "shadow-utils-2:4.8-1.x86_64"}
+		]
+	}`, string(data))
+
+	read, err := UnmarshalLockfile(data)
+	require.NoError(t, err)
+	assert.Equal(t, []PackageSpec{
+		{Name: "bash", Version: "5.0", Release: "1", Arch: "x86_64", Repo: "fedora", RemoteLocation: "http://example.com/fedora/bash-5.0-1.x86_64.rpm", Checksum: "sha256:01", License: "GPLv3+", Summary: "The GNU Bourne Again shell", SourceRPM: "bash-5.0-1.src.rpm"},
+		{Name: "shadow-utils", Epoch: 2, Version: "4.8", Release: "1", Arch: "x86_64"},
+	}, read)
+
+	// fields added later are ignored
+	_, err = UnmarshalLockfile([]byte(`{"version": 1, "packages": [{"name": "bash", "version": "5.0", "release": "1", "arch": "x86_64", "buildtime": 1588000000}]}`))
+	assert.NoError(t, err)
+
+	_, err = UnmarshalLockfile([]byte(`{"version": 2, "packages": []}`))
+	assert.EqualError(t, err, "unsupported lockfile version 2, expected 1")
+	_, err = UnmarshalLockfile([]byte(`{"packages": []}`))
+	assert.EqualError(t, err, "unsupported lockfile version 0, expected 1")
+	_, err = UnmarshalLockfile([]byte(`{"version": 1, "packages": [{"name": "bash"}]}`))
+	assert.EqualError(t, err, "invalid lockfile: package 0 has an incomplete NEVRA")
+	_, err = UnmarshalLockfile([]byte(`[]`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package rpmmd
+
+import "context"
+
+// A ModuleStream is a stream of a module available in the repositories.
+// Default is true for the stream dnf enables when the module is requested
+// without a stream.
+type ModuleStream struct {
+	Name     string   `json:"name"`
+	Stream   string   `json:"stream"`
+	Summary  string   `json:"summary,omitempty"`
+	Profiles []string `json:"profiles,omitempty"`
+	Default  bool     `json:"default,omitempty"`
+}
+
+// A PackageGroup is a comps group or, if Environment is true, a comps
+// environment. Both can be installed by adding "@" and their ID to the
+// packages of a blueprint.
+type PackageGroup struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Environment bool   `json:"environment,omitempty"`
+	UserVisible bool   `json:"user_visible"`
+}
+
+// PackageGroups is a list of comps groups and environments sorted by ID
+type PackageGroups []PackageGroup
+
+// Find returns the group or environment with the given ID or name
+func (groups PackageGroups) Find(idOrName string) (PackageGroup, bool) {
+	for _, group := range groups {
+		if group.ID == idOrName || group.Name == idOrName {
+			return group, true
+		}
+	}
+	return PackageGroup{}, false
+}
+
+func (r *rpmmdImpl) ListModuleStreams(ctx context.Context, repos []RepoConfig, modulePlatformID, arch string) ([]ModuleStream, error) {
+	var reply struct {
+		Modules []ModuleStream `json:"modules"`
+	}
+	err := r.listMetadata(ctx, "modules", repos, modulePlatformID, arch, &reply)
+	return reply.Modules, err
+}
+
+func (r *rpmmdImpl) ListPackageGroups(ctx context.Context, repos []RepoConfig, modulePlatformID, arch string) (PackageGroups, error) {
+	var reply struct {
+		Groups PackageGroups `json:"groups"`
+	}
+	err := r.listMetadata(ctx, "groups", repos, modulePlatformID, arch, &reply)
+	return reply.Groups, err
+}
+
+// listMetadata runs a dnf-json command which only needs the repositories
+func (r *rpmmdImpl) listMetadata(ctx context.Context, command string, repos []RepoConfig, modulePlatformID, arch string, reply interface{}) error {
+	var dnfRepoConfigs []dnfRepoConfig
+	for i, repo := range repos {
+		dnfRepo, err := repo.toDNFRepoConfig(r, i)
+		if err != nil {
+			return err
+		}
+		dnfRepoConfigs = append(dnfRepoConfigs, dnfRepo)
+	}
+
+	var arguments = struct {
+		Repos            []dnfRepoConfig `json:"repos"`
+		CacheDir         string          `json:"cachedir"`
+		ModulePlatformID string          `json:"module_platform_id"`
+		Arch             string          `json:"arch"`
+	}{dnfRepoConfigs, r.CacheDir, modulePlatformID, arch}
+	return r.runDNF(ctx, command, arguments, reply)
+}
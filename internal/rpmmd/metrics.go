@@ -0,0 +1,138 @@
+package rpmmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// dnfStats are the statistics dnf-json adds to the result of every call
+// about loading the metadata of the repositories
+type dnfStats struct {
+	CacheHits       uint64 `json:"cache_hits"`
+	CacheMisses     uint64 `json:"cache_misses"`
+	DownloadedBytes uint64 `json:"downloaded_bytes"`
+}
+
+// statsReply decodes the result of a call to dnf-json together with its
+// statistics
+type statsReply struct {
+	result interface{}
+	stats  dnfStats
+}
+
+func (r *statsReply) UnmarshalJSON(data []byte) error {
+	var reply struct {
+		Stats dnfStats `json:"stats"`
+	}
+	err := json.Unmarshal(data, &reply)
+	if err != nil {
+		return err
+	}
+	r.stats = reply.Stats
+	return json.Unmarshal(data, r.result)
+}
+
+type commandMetrics struct {
+	calls    uint64
+	failures uint64
+	duration time.Duration
+}
+
+// metrics are collected for all calls to dnf-json of the process, because
+// slow mirrors are the most common reason for slow composes
+type metrics struct {
+	mu              sync.Mutex
+	commands        map[string]*commandMetrics
+	cacheHits       uint64
+	cacheMisses     uint64
+	downloadedBytes uint64
+}
+
+var dnfMetrics = &metrics{commands: make(map[string]*commandMetrics)}
+
+func (m *metrics) record(command string, duration time.Duration, err error, stats dnfStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.commands[command]
+	if !ok {
+		c = &commandMetrics{}
+		m.commands[command] = c
+	}
+	c.calls++
+	c.duration += duration
+	if err != nil {
+		c.failures++
+	}
+	m.cacheHits += stats.CacheHits
+	m.cacheMisses += stats.CacheMisses
+	m.downloadedBytes += stats.DownloadedBytes
+}
+
+func (m *metrics) write(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var names []string
+	for name := range m.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{
+		"# HELP osbuild_composer_rpmmd_call_duration_seconds Time spent in calls to dnf-json, by command.",
+		"# TYPE osbuild_composer_rpmmd_call_duration_seconds summary",
+	}
+	for _, name := range names {
+		c := m.commands[name]
+		lines = append(lines,
+			fmt.Sprintf("osbuild_composer_rpmmd_call_duration_seconds_sum{command=%q} %g", name, c.duration.Seconds()),
+			fmt.Sprintf("osbuild_composer_rpmmd_call_duration_seconds_count{command=%q} %d", name, c.calls))
+	}
+	lines = append(lines,
+		"# HELP osbuild_composer_rpmmd_call_failures_total Calls to dnf-json which failed or were canceled, by command.",
+		"# TYPE osbuild_composer_rpmmd_call_failures_total counter")
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("osbuild_composer_rpmmd_call_failures_total{command=%q} %d", name, m.commands[name].failures))
+	}
+	lines = append(lines,
+		"# HELP osbuild_composer_rpmmd_metadata_cache_hits_total Repositories loaded from cached metadata.",
+		"# TYPE osbuild_composer_rpmmd_metadata_cache_hits_total counter",
+		fmt.Sprintf("osbuild_composer_rpmmd_metadata_cache_hits_total %d", m.cacheHits),
+		"# HELP osbuild_composer_rpmmd_metadata_cache_misses_total Repositories whose metadata had to be downloaded.",
+		"# TYPE osbuild_composer_rpmmd_metadata_cache_misses_total counter",
+		fmt.Sprintf("osbuild_composer_rpmmd_metadata_cache_misses_total %d", m.cacheMisses),
+		"# HELP osbuild_composer_rpmmd_metadata_downloaded_bytes_total Bytes of metadata downloaded from repositories.",
+		"# TYPE osbuild_composer_rpmmd_metadata_downloaded_bytes_total counter",
+		fmt.Sprintf("osbuild_composer_rpmmd_metadata_downloaded_bytes_total %d", m.downloadedBytes))
+
+	for _, line := range lines {
+		_, err := fmt.Fprintln(w, line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteMetrics writes the metrics of all calls to dnf-json made by this
+// process in the Prometheus text format
+func WriteMetrics(w io.Writer) error {
+	return dnfMetrics.write(w)
+}
+
+// MetricsHandler serves the metrics of all depsolves, metadata fetches and
+// other calls to dnf-json made by this process, in the Prometheus text
+// format: their duration and failures by command, how often the metadata of
+// repositories was found in the cache and how much of it was downloaded.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_ = WriteMetrics(w)
+	})
+}
@@ -0,0 +1,52 @@
+package rpmmd
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defaultMetrics := dnfMetrics
+	dnfMetrics = &metrics{commands: make(map[string]*commandMetrics)}
+	defer func() { dnfMetrics = defaultMetrics }()
+
+	calls := 0
+	socketPath := serveDNFJSON(t, dir, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			_, _ = w.Write([]byte(`{"groups": [], "stats": {"cache_hits": 1, "cache_misses": 2, "downloaded_bytes": 1024}}`))
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"kind":"RepoError","reason":"cannot download repomd.xml"}`))
+	})
+
+	rpm := NewRPMMDService(dir, filepath.Join(dir, "non-existing"), socketPath)
+	repos := []RepoConfig{{Name: "fedora", BaseURL: "http://example.com/fedora"}}
+
+	_, err = rpm.ListPackageGroups(context.Background(), repos, "platform:f33", "x86_64")
+	require.NoError(t, err)
+	_, err = rpm.ListPackageGroups(context.Background(), repos, "platform:f33", "x86_64")
+	require.Error(t, err)
+
+	recorder := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	assert.Contains(t, body, `osbuild_composer_rpmmd_call_duration_seconds_count{command="groups"} 2`+"\n")
+	assert.Contains(t, body, `osbuild_composer_rpmmd_call_failures_total{command="groups"} 1`+"\n")
+	assert.Contains(t, body, "osbuild_composer_rpmmd_metadata_cache_hits_total 1\n")
+	assert.Contains(t, body, "osbuild_composer_rpmmd_metadata_cache_misses_total 2\n")
+	assert.Contains(t, body, "osbuild_composer_rpmmd_metadata_downloaded_bytes_total 1024\n")
+}
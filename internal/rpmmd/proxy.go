@@ -0,0 +1,61 @@
+package rpmmd
+
+import "context"
+
+type defaultProxyRPMMD struct {
+	RPMMD
+	proxy string
+}
+
+// WithDefaultProxy returns an RPMMD which uses proxy for all repositories
+// that don't configure a proxy themselves. The proxy ends up in the returned
+// package specs and thus in the sources of the manifests, too.
+func WithDefaultProxy(rpmmd RPMMD, proxy string) RPMMD {
+	if proxy == "" {
+		return rpmmd
+	}
+	return &defaultProxyRPMMD{rpmmd, proxy}
+}
+
+func (r *defaultProxyRPMMD) withProxy(repos []RepoConfig) []RepoConfig {
+	result := make([]RepoConfig, len(repos))
+	for i, repo := range repos {
+		if repo.Proxy == "" {
+			repo.Proxy = r.proxy
+		}
+		result[i] = repo
+	}
+	return result
+}
+
+func (r *defaultProxyRPMMD) FetchMetadata(ctx context.Context, repos []RepoConfig, modulePlatformID string, arch string) (PackageList, map[string]string, error) {
+	return r.RPMMD.FetchMetadata(ctx, r.withProxy(repos), modulePlatformID, arch)
+}
+
+func (r *defaultProxyRPMMD) Depsolve(ctx context.Context, specs, excludeSpecs, moduleSpecs []string, repos []RepoConfig, modulePlatformID, arch string) ([]PackageSpec, map[string]string, error) {
+	return r.RPMMD.Depsolve(ctx, specs, excludeSpecs, moduleSpecs, r.withProxy(repos), modulePlatformID, arch)
+}
+
+func (r *defaultProxyRPMMD) SearchPackages(ctx context.Context, query PackageQuery, repos []RepoConfig, modulePlatformID, arch string) (PackageSearchResult, error) {
+	return r.RPMMD.SearchPackages(ctx, query, r.withProxy(repos), modulePlatformID, arch)
+}
+
+func (r *defaultProxyRPMMD) ResolveGPGKeys(ctx context.Context, repos []RepoConfig) ([]RepoConfig, error) {
+	return r.RPMMD.ResolveGPGKeys(ctx, r.withProxy(repos))
+}
+
+func (r *defaultProxyRPMMD) DepsolvePackageSet(ctx context.Context, set PackageSet, repos []RepoConfig, modulePlatformID, arch string) (DepsolveResult, error) {
+	return r.RPMMD.DepsolvePackageSet(ctx, set, r.withProxy(repos), modulePlatformID, arch)
+}
+
+func (r *defaultProxyRPMMD) DepsolvePackageSets(ctx context.Context, sets map[string]PackageSet, repos []RepoConfig, modulePlatformID, arch string) (map[string]DepsolveResult, error) {
+	return r.RPMMD.DepsolvePackageSets(ctx, sets, r.withProxy(repos), modulePlatformID, arch)
+}
+
+func (r *defaultProxyRPMMD) ListModuleStreams(ctx context.Context, repos []RepoConfig, modulePlatformID, arch string) ([]ModuleStream, error) {
+	return r.RPMMD.ListModuleStreams(ctx, r.withProxy(repos), modulePlatformID, arch)
+}
+
+func (r *defaultProxyRPMMD) ListPackageGroups(ctx context.Context, repos []RepoConfig, modulePlatformID, arch string) (PackageGroups, error) {
+	return r.RPMMD.ListPackageGroups(ctx, r.withProxy(repos), modulePlatformID, arch)
+}
@@ -0,0 +1,98 @@
+package rpmmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reposRPMMD records the repositories it is called with
+type reposRPMMD struct {
+	repos []RepoConfig
+}
+
+func (r *reposRPMMD) FetchMetadata(ctx context.Context, repos []RepoConfig, modulePlatformID string, arch string) (PackageList, map[string]string, error) {
+	r.repos = repos
+	return nil, nil, nil
+}
+
+func (r *reposRPMMD) Depsolve(ctx context.Context, specs, excludeSpecs, moduleSpecs []string, repos []RepoConfig, modulePlatformID, arch string) ([]PackageSpec, map[string]string, error) {
+	r.repos = repos
+	return nil, nil, nil
+}
+
+func (r *reposRPMMD) SearchPackages(ctx context.Context, query PackageQuery, repos []RepoConfig, modulePlatformID, arch string) (PackageSearchResult, error) {
+	r.repos = repos
+	return PackageSearchResult{}, nil
+}
+
+func (r *reposRPMMD) ResolveGPGKeys(ctx context.Context, repos []RepoConfig) ([]RepoConfig, error) {
+	r.repos = repos
+	return repos, nil
+}
+
+func (r *reposRPMMD) DepsolvePackageSet(ctx context.Context, set PackageSet, repos []RepoConfig, modulePlatformID, arch string) (DepsolveResult, error) {
+	r.repos = repos
+	return DepsolveResult{}, nil
+}
+
+func (r *reposRPMMD) DepsolvePackageSets(ctx context.Context, sets map[string]PackageSet, repos []RepoConfig, modulePlatformID, arch string) (map[string]DepsolveResult, error) {
+	r.repos = repos
+	return nil, nil
+}
+
+func (r *reposRPMMD) ListModuleStreams(ctx context.Context, repos []RepoConfig, modulePlatformID, arch string) ([]ModuleStream, error) {
+	r.repos = repos
+	return nil, nil
+}
+
+func (r *reposRPMMD) ListPackageGroups(ctx context.Context, repos []RepoConfig, modulePlatformID, arch string) (PackageGroups, error) {
+	r.repos = repos
+	return nil, nil
+}
+
+func TestWithDefaultProxy(t *testing.T) {
+	repos := []RepoConfig{
+		{Name: "direct", BaseURL: "http://example.com/direct"},
+		{Name: "proxied", BaseURL: "http://example.com/proxied", Proxy: "http://other.example.com:8080"},
+	}
+
+	inner := &reposRPMMD{}
+	rpm := WithDefaultProxy(inner, "http://proxy.example.com:3128")
+
+	_, _, err := rpm.Depsolve(context.Background(), []string{"bash"}, nil, nil, repos, "platform:f32", "x86_64")
+	require.NoError(t, err)
+	require.Len(t, inner.repos, 2)
+	assert.Equal(t, "http://proxy.example.com:3128", inner.repos[0].Proxy)
+	assert.Equal(t, "http://other.example.com:8080", inner.repos[1].Proxy)
+
+	_, _, err = rpm.FetchMetadata(context.Background(), repos, "platform:f32", "x86_64")
+	require.NoError(t, err)
+	assert.Equal(t, "http://proxy.example.com:3128", inner.repos[0].Proxy)
+
+	inner.repos = nil
+	_, err = rpm.DepsolvePackageSet(context.Background(), PackageSet{Include: []string{"bash"}}, repos, "platform:f32", "x86_64")
+	require.NoError(t, err)
+	require.Len(t, inner.repos, 2)
+	assert.Equal(t, "http://proxy.example.com:3128", inner.repos[0].Proxy)
+
+	inner.repos = nil
+	_, err = rpm.DepsolvePackageSets(context.Background(), map[string]PackageSet{OSPackageSet: {Include: []string{"bash"}}}, repos, "platform:f32", "x86_64")
+	require.NoError(t, err)
+	require.Len(t, inner.repos, 2)
+	assert.Equal(t, "http://proxy.example.com:3128", inner.repos[0].Proxy)
+
+	inner.repos = nil
+	_, err = rpm.ListPackageGroups(context.Background(), repos, "platform:f32", "x86_64")
+	require.NoError(t, err)
+	require.Len(t, inner.repos, 2)
+	assert.Equal(t, "http://proxy.example.com:3128", inner.repos[0].Proxy)
+
+	// the repositories of the caller are left alone
+	assert.Empty(t, repos[0].Proxy)
+
+	// no default proxy doesn't wrap at all
+	assert.Equal(t, RPMMD(inner), WithDefaultProxy(inner, ""))
+}
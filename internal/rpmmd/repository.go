@@ -1,9 +1,12 @@
 package rpmmd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,42 +16,106 @@ import (
 	"time"
 
 	"github.com/gobwas/glob"
+
+	"github.com/osbuild/osbuild-composer/internal/copr"
 )
 
 type repository struct {
-	Name           string `json:"name"`
-	BaseURL        string `json:"baseurl,omitempty"`
-	Metalink       string `json:"metalink,omitempty"`
-	MirrorList     string `json:"mirrorlist,omitempty"`
-	GPGKey         string `json:"gpgkey,omitempty"`
-	CheckGPG       bool   `json:"check_gpg,omitempty"`
-	RHSM           bool   `json:"rhsm,omitempty"`
-	MetadataExpire string `json:"metadata_expire,omitempty"`
+	Name           string            `json:"name"`
+	BaseURL        string            `json:"baseurl,omitempty"`
+	Metalink       string            `json:"metalink,omitempty"`
+	MirrorList     string            `json:"mirrorlist,omitempty"`
+	GPGKey         string            `json:"gpgkey,omitempty"`
+	GPGKeyChecksum string            `json:"gpgkey_checksum,omitempty"`
+	CheckGPG       bool              `json:"check_gpg,omitempty"`
+	RHSM           bool              `json:"rhsm,omitempty"`
+	MetadataExpire string            `json:"metadata_expire,omitempty"`
+	Proxy          string            `json:"proxy,omitempty"`
+	Priority       int               `json:"priority,omitempty"`
+	ModuleHotfixes bool              `json:"module_hotfixes,omitempty"`
+	SSLVerify      *bool             `json:"sslverify,omitempty"`
+	SSLCACert      string            `json:"sslcacert,omitempty"`
+	SSLClientKey   string            `json:"sslclientkey,omitempty"`
+	SSLClientCert  string            `json:"sslclientcert,omitempty"`
+	IncludePkgs    []string          `json:"includepkgs,omitempty"`
+	ExcludePkgs    []string          `json:"excludepkgs,omitempty"`
+	Variables      map[string]string `json:"variables,omitempty"`
 }
 
 type dnfRepoConfig struct {
-	ID             string `json:"id"`
-	BaseURL        string `json:"baseurl,omitempty"`
-	Metalink       string `json:"metalink,omitempty"`
-	MirrorList     string `json:"mirrorlist,omitempty"`
-	GPGKey         string `json:"gpgkey,omitempty"`
-	IgnoreSSL      bool   `json:"ignoressl"`
-	SSLCACert      string `json:"sslcacert,omitempty"`
-	SSLClientKey   string `json:"sslclientkey,omitempty"`
-	SSLClientCert  string `json:"sslclientcert,omitempty"`
-	MetadataExpire string `json:"metadata_expire,omitempty"`
+	ID             string            `json:"id"`
+	BaseURL        string            `json:"baseurl,omitempty"`
+	Metalink       string            `json:"metalink,omitempty"`
+	MirrorList     string            `json:"mirrorlist,omitempty"`
+	GPGKey         string            `json:"gpgkey,omitempty"`
+	IgnoreSSL      bool              `json:"ignoressl"`
+	SSLCACert      string            `json:"sslcacert,omitempty"`
+	SSLClientKey   string            `json:"sslclientkey,omitempty"`
+	SSLClientCert  string            `json:"sslclientcert,omitempty"`
+	MetadataExpire string            `json:"metadata_expire,omitempty"`
+	Proxy          string            `json:"proxy,omitempty"`
+	Priority       int               `json:"priority,omitempty"`
+	ModuleHotfixes bool              `json:"module_hotfixes,omitempty"`
+	IncludePkgs    []string          `json:"includepkgs,omitempty"`
+	ExcludePkgs    []string          `json:"excludepkgs,omitempty"`
+	Variables      map[string]string `json:"variables,omitempty"`
 }
 
 type RepoConfig struct {
-	Name           string
-	BaseURL        string
-	Metalink       string
-	MirrorList     string
-	GPGKey         string
-	CheckGPG       bool
+	Name string
+	// BaseURL may list several mirrors of the repository separated by
+	// whitespace, as with dnf's baseurl option. They are tried in order
+	// when loading the metadata and all of them are passed on to osbuild
+	// for downloading the packages.
+	BaseURL    string
+	Metalink   string
+	MirrorList string
+	GPGKey     string
+	CheckGPG   bool
+	// IgnoreSSL disables verifying the certificates of the servers of the
+	// repository, as with dnf's sslverify=0, when downloading both the
+	// metadata and the packages
 	IgnoreSSL      bool
 	MetadataExpire string
 	RHSM           bool
+	// GPGKeyChecksum pins the key downloaded when GPGKey is an https URL,
+	// in the sha256:<hex digest> format
+	GPGKeyChecksum string
+	// Proxy is the URL of the proxy used for downloading both the metadata
+	// and the packages of the repository, e.g. http://proxy.example.com:3128
+	Proxy string
+	// Priority of the repository, as with dnf's priority option: packages
+	// are taken from the repository with the lowest value, even if other
+	// repositories carry higher versions of them. Zero keeps dnf's default
+	// of 99.
+	Priority int
+	// ModuleHotfixes makes the packages of the repository available even if
+	// they are filtered out by enabled module streams, as for repositories
+	// carrying fixes of modular packages
+	ModuleHotfixes bool
+	// SSLCACert is the path of a CA bundle for verifying the servers of the
+	// repository, e.g. mirrors with self-signed certificates. It is used for
+	// downloading the packages, too, so it has to exist on the hosts of the
+	// workers as well.
+	//
+	// Paths of the certificates of repositories requiring client
+	// certificates. The client certificate and key have to be entitlement
+	// certificates from /etc/pki/entitlement, because osbuild only gets
+	// access to those when downloading the packages. RHSM sets them to the
+	// first entitlement of the host.
+	SSLCACert     string
+	SSLClientKey  string
+	SSLClientCert string
+	// IncludePkgs and ExcludePkgs limit the packages taken from the
+	// repository, as with dnf's includepkgs and excludepkgs options. They
+	// are lists of globs matching package names, such as "python3-*".
+	IncludePkgs []string
+	ExcludePkgs []string
+	// Variables are the values of dnf variables used in the URLs of the
+	// repository, such as $contentdir, e.g. {"contentdir": "centos"}.
+	// $arch, $basearch and $releasever are set for every repository, the
+	// latter from the module platform ID, but can be overridden, too.
+	Variables map[string]string
 }
 
 type PackageList []Package
@@ -64,6 +131,8 @@ type Package struct {
 	Arch        string
 	BuildTime   time.Time
 	License     string
+	// Repo is the name of the repository the package is from
+	Repo string
 }
 
 func (pkg Package) ToPackageBuild() PackageBuild {
@@ -96,30 +165,72 @@ func (pkg Package) ToPackageInfo() PackageInfo {
 	}
 }
 
+// The reasons a package is installed, as recorded by dnf
+const (
+	ReasonUser           = "user"
+	ReasonDependency     = "dependency"
+	ReasonWeakDependency = "weak-dependency"
+	ReasonGroup          = "group"
+)
+
 // TODO: the public API of this package should not be reused for serialization.
+//
+// Repo is the name of the repository a depsolved package comes from.
+// Reason is why a depsolved package is installed, one of the Reason*
+// constants. RecommendedBy lists the packages which pulled in a weak
+// dependency: the ones recommending it and the ones it supplements.
 type PackageSpec struct {
-	Name           string `json:"name"`
-	Epoch          uint   `json:"epoch"`
-	Version        string `json:"version,omitempty"`
-	Release        string `json:"release,omitempty"`
-	Arch           string `json:"arch,omitempty"`
-	RemoteLocation string `json:"remote_location,omitempty"`
-	Checksum       string `json:"checksum,omitempty"`
-	Secrets        string `json:"secrets,omitempty"`
-	CheckGPG       bool   `json:"check_gpg,omitempty"`
+	Name           string   `json:"name"`
+	Epoch          uint     `json:"epoch"`
+	Version        string   `json:"version,omitempty"`
+	Release        string   `json:"release,omitempty"`
+	Arch           string   `json:"arch,omitempty"`
+	Repo           string   `json:"repo,omitempty"`
+	RemoteLocation string   `json:"remote_location,omitempty"`
+	Checksum       string   `json:"checksum,omitempty"`
+	Secrets        string   `json:"secrets,omitempty"`
+	CheckGPG       bool     `json:"check_gpg,omitempty"`
+	Proxy          string   `json:"proxy,omitempty"`
+	IgnoreSSL      bool     `json:"ignore_ssl,omitempty"`
+	SSLCACert      string   `json:"sslcacert,omitempty"`
+	Reason         string   `json:"reason,omitempty"`
+	RecommendedBy  []string `json:"recommended_by,omitempty"`
+	// License, Summary and SourceRPM are taken from the headers of the
+	// package, e.g. "GPLv3+", "The GNU Bourne Again shell" and
+	// "bash-5.0.17-1.fc32.src.rpm"
+	License   string `json:"license,omitempty"`
+	Summary   string `json:"summary,omitempty"`
+	SourceRPM string `json:"sourcerpm,omitempty"`
+	// Mirrors are the URLs of the package on the other base URLs of its
+	// repository, to be tried when RemoteLocation fails
+	Mirrors []string `json:"mirrors,omitempty"`
+}
+
+// GetNEVRA returns the full name of the package as accepted by dnf, with the
+// epoch omitted when it is 0, e.g. "bash-5.0.17-1.fc32.x86_64"
+func (ps PackageSpec) GetNEVRA() string {
+	if ps.Epoch == 0 {
+		return fmt.Sprintf("%s-%s-%s.%s", ps.Name, ps.Version, ps.Release, ps.Arch)
+	}
+	return fmt.Sprintf("%s-%d:%s-%s.%s", ps.Name, ps.Epoch, ps.Version, ps.Release, ps.Arch)
 }
 
 type dnfPackageSpec struct {
-	Name           string `json:"name"`
-	Epoch          uint   `json:"epoch"`
-	Version        string `json:"version,omitempty"`
-	Release        string `json:"release,omitempty"`
-	Arch           string `json:"arch,omitempty"`
-	RepoID         string `json:"repo_id,omitempty"`
-	Path           string `json:"path,omitempty"`
-	RemoteLocation string `json:"remote_location,omitempty"`
-	Checksum       string `json:"checksum,omitempty"`
-	Secrets        string `json:"secrets,omitempty"`
+	Name           string   `json:"name"`
+	Epoch          uint     `json:"epoch"`
+	Version        string   `json:"version,omitempty"`
+	Release        string   `json:"release,omitempty"`
+	Arch           string   `json:"arch,omitempty"`
+	RepoID         string   `json:"repo_id,omitempty"`
+	Path           string   `json:"path,omitempty"`
+	RemoteLocation string   `json:"remote_location,omitempty"`
+	Checksum       string   `json:"checksum,omitempty"`
+	Secrets        string   `json:"secrets,omitempty"`
+	Reason         string   `json:"reason,omitempty"`
+	RecommendedBy  []string `json:"recommended_by,omitempty"`
+	License        string   `json:"license,omitempty"`
+	Summary        string   `json:"summary,omitempty"`
+	SourceRPM      string   `json:"sourcerpm,omitempty"`
 }
 
 type PackageSource struct {
@@ -151,20 +262,70 @@ type PackageInfo struct {
 	Dependencies []PackageSpec  `json:"dependencies,omitempty"`
 }
 
+// RPMMD resolves packages from repositories. All calls can be canceled with
+// their context, which stops the depsolver, too.
 type RPMMD interface {
 	// FetchMetadata returns all metadata about the repositories we use in the code. Specifically it is a
 	// list of packages and dictionary of checksums of the repositories.
-	FetchMetadata(repos []RepoConfig, modulePlatformID string, arch string) (PackageList, map[string]string, error)
-
-	// Depsolve takes a list of required content (specs), explicitly unwanted content (excludeSpecs), list
-	// or repositories, and platform ID for modularity. It returns a list of all packages (with solved
-	// dependencies) that will be installed into the system.
-	Depsolve(specs, excludeSpecs []string, repos []RepoConfig, modulePlatformID, arch string) ([]PackageSpec, map[string]string, error)
+	FetchMetadata(ctx context.Context, repos []RepoConfig, modulePlatformID string, arch string) (PackageList, map[string]string, error)
+
+	// Depsolve takes a list of required content (specs), explicitly unwanted content (excludeSpecs), module
+	// streams to enable before resolving (moduleSpecs, in the name:stream format), list or repositories, and
+	// platform ID for modularity. It returns a list of all packages (with solved dependencies) that will be
+	// installed into the system.
+	Depsolve(ctx context.Context, specs, excludeSpecs, moduleSpecs []string, repos []RepoConfig, modulePlatformID, arch string) ([]PackageSpec, map[string]string, error)
+
+	// SearchPackages returns the page of packages of the repositories selected by query. It
+	// fails when the query has invalid globs or sort keys.
+	SearchPackages(ctx context.Context, query PackageQuery, repos []RepoConfig, modulePlatformID, arch string) (PackageSearchResult, error)
+
+	// ResolveGPGKeys returns a copy of repos in which the gpgkeys given as https URLs are
+	// replaced by the keys themselves, which are downloaded once and cached.
+	ResolveGPGKeys(ctx context.Context, repos []RepoConfig) ([]RepoConfig, error)
+
+	// DepsolvePackageSet works like Depsolve, but additionally disables the modules in
+	// set.DisabledModules before resolving and returns the resulting state of the modules.
+	DepsolvePackageSet(ctx context.Context, set PackageSet, repos []RepoConfig, modulePlatformID, arch string) (DepsolveResult, error)
+
+	// DepsolvePackageSets resolves several named package sets, such as the ones of the
+	// pipelines of a compose, in a single call, which loads the metadata of repos only once.
+	// It returns the results by name. If a set cannot be resolved, a *PackageSetError is
+	// returned.
+	DepsolvePackageSets(ctx context.Context, sets map[string]PackageSet, repos []RepoConfig, modulePlatformID, arch string) (map[string]DepsolveResult, error)
+
+	// ListModuleStreams returns the streams of all modules in repos, sorted by module
+	// name and stream.
+	ListModuleStreams(ctx context.Context, repos []RepoConfig, modulePlatformID, arch string) ([]ModuleStream, error)
+
+	// ListPackageGroups returns the comps groups and environments of repos, sorted by ID.
+	ListPackageGroups(ctx context.Context, repos []RepoConfig, modulePlatformID, arch string) (PackageGroups, error)
 }
 
+// A DNFError is an error reported by dnf. PackageSet is the name of the
+// package set which failed when resolving several ones.
 type DNFError struct {
-	Kind   string `json:"kind"`
-	Reason string `json:"reason"`
+	Kind       string                `json:"kind"`
+	Reason     string                `json:"reason"`
+	Details    *DepsolveErrorDetails `json:"details,omitempty"`
+	PackageSet string                `json:"package_set,omitempty"`
+}
+
+// DepsolveErrorDetails break down why a depsolve failed. Specs are package
+// specs as passed to Depsolve, packages are given by their NEVRA.
+type DepsolveErrorDetails struct {
+	// MissingSpecs match no package of the repositories
+	MissingSpecs []string `json:"missing_specs,omitempty"`
+	// ExcludedSpecs match only packages which are excluded
+	ExcludedSpecs []string `json:"excluded_specs,omitempty"`
+	// Conflicts are packages which cannot be installed together
+	Conflicts []string `json:"conflicts,omitempty"`
+	// MissingProvides are the requirements no package provides, e.g.
+	// "libfoo.so.1()(64bit) needed by bar-1.0-1.fc33.x86_64"
+	MissingProvides []string `json:"missing_provides,omitempty"`
+	// Problems are dnf's descriptions of all problems
+	Problems []string `json:"problems,omitempty"`
+	// Culprits are the specs involved in the problems
+	Culprits []string `json:"culprits,omitempty"`
 }
 
 func (err *DNFError) Error() string {
@@ -179,6 +340,11 @@ func (re *RepositoryError) Error() string {
 	return re.msg
 }
 
+// entitlementDir is where subscription-manager stores the entitlement
+// certificates. The org.osbuild.rhsm secrets of osbuild's sources are read
+// from there on the worker.
+const entitlementDir = "/etc/pki/entitlement"
+
 type RHSMSecrets struct {
 	SSLCACert     string `json:"sslcacert,omitempty"`
 	SSLClientKey  string `json:"sslclientkey,omitempty"`
@@ -186,7 +352,7 @@ type RHSMSecrets struct {
 }
 
 func getRHSMSecrets() *RHSMSecrets {
-	keys, err := filepath.Glob("/etc/pki/entitlement/*-key.pem")
+	keys, err := filepath.Glob(filepath.Join(entitlementDir, "*-key.pem"))
 	if err != nil {
 		return nil
 	}
@@ -203,52 +369,93 @@ func getRHSMSecrets() *RHSMSecrets {
 	return nil
 }
 
+// LoadRepositories loads the repositories of distro from the
+// repositories/<distro>.json files in confPaths, which are given in order of
+// precedence. The files are layered per architecture: the repositories an
+// earlier file defines for an architecture replace the ones of later files,
+// while the other architectures keep those, so that the built-in
+// configuration can be overridden for single architectures.
 func LoadRepositories(confPaths []string, distro string) (map[string][]RepoConfig, error) {
-	var f *os.File
-	var err error
 	path := "/repositories/" + distro + ".json"
 
-	for _, confPath := range confPaths {
-		f, err = os.Open(confPath + path)
-		if err == nil {
-			break
-		} else if !os.IsNotExist(err) {
+	repoConfigs := make(map[string][]RepoConfig)
+	found := false
+	for i := len(confPaths) - 1; i >= 0; i-- {
+		reposMap, err := loadRepositoriesFile(confPaths[i] + path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
 			return nil, err
 		}
+		found = true
+
+		for arch, repos := range reposMap {
+			var configs []RepoConfig
+			for _, repo := range repos {
+				config := RepoConfig{
+					Name:           repo.Name,
+					BaseURL:        repo.BaseURL,
+					Metalink:       repo.Metalink,
+					MirrorList:     repo.MirrorList,
+					GPGKey:         repo.GPGKey,
+					GPGKeyChecksum: repo.GPGKeyChecksum,
+					CheckGPG:       repo.CheckGPG,
+					RHSM:           repo.RHSM,
+					MetadataExpire: repo.MetadataExpire,
+					Proxy:          repo.Proxy,
+					Priority:       repo.Priority,
+					ModuleHotfixes: repo.ModuleHotfixes,
+					IgnoreSSL:      repo.SSLVerify != nil && !*repo.SSLVerify,
+					SSLCACert:      repo.SSLCACert,
+					SSLClientKey:   repo.SSLClientKey,
+					SSLClientCert:  repo.SSLClientCert,
+					IncludePkgs:    repo.IncludePkgs,
+					ExcludePkgs:    repo.ExcludePkgs,
+					Variables:      repo.Variables,
+				}
+
+				err = config.validateCertificates()
+				if err == nil {
+					err = config.validatePackageGlobs()
+				}
+				if err == nil {
+					err = config.validateGPGKey()
+				}
+				if err == nil && copr.IsSpec(config.BaseURL) {
+					err = config.expandCopr(distro, arch)
+				}
+				if err != nil {
+					return nil, &RepositoryError{fmt.Sprintf("LoadRepositories failed: repository %s: %v", repo.Name, err)}
+				}
+
+				configs = append(configs, config)
+			}
+			repoConfigs[arch] = configs
+		}
 	}
-	if err != nil {
+	if !found {
 		return nil, &RepositoryError{"LoadRepositories failed: none of the provided paths contain distro configuration"}
 	}
-	defer f.Close()
 
-	var reposMap map[string][]repository
-	repoConfigs := make(map[string][]RepoConfig)
+	return repoConfigs, nil
+}
 
-	err = json.NewDecoder(f).Decode(&reposMap)
+func loadRepositoriesFile(path string) (map[string][]repository, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 
-	for arch, repos := range reposMap {
-		for _, repo := range repos {
-			config := RepoConfig{
-				Name:           repo.Name,
-				BaseURL:        repo.BaseURL,
-				Metalink:       repo.Metalink,
-				MirrorList:     repo.MirrorList,
-				GPGKey:         repo.GPGKey,
-				CheckGPG:       repo.CheckGPG,
-				RHSM:           repo.RHSM,
-				MetadataExpire: repo.MetadataExpire,
-			}
-
-			repoConfigs[arch] = append(repoConfigs[arch], config)
-		}
+	var reposMap map[string][]repository
+	err = json.NewDecoder(f).Decode(&reposMap)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
 	}
-	return repoConfigs, nil
+	return reposMap, nil
 }
 
-func runDNF(dnfJsonPath string, command string, arguments interface{}, result interface{}) error {
+func runDNF(ctx context.Context, dnfJsonPath string, command string, arguments interface{}, result interface{}) error {
 	var call = struct {
 		Command   string      `json:"command"`
 		Arguments interface{} `json:"arguments,omitempty"`
@@ -257,7 +464,7 @@ func runDNF(dnfJsonPath string, command string, arguments interface{}, result in
 		arguments,
 	}
 
-	cmd := exec.Command(dnfJsonPath)
+	cmd := exec.CommandContext(ctx, dnfJsonPath)
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -287,6 +494,10 @@ func runDNF(dnfJsonPath string, command string, arguments interface{}, result in
 	}
 
 	err = cmd.Wait()
+	if ctx.Err() != nil {
+		// dnf-json was killed
+		return ctx.Err()
+	}
 
 	const DnfErrorExitCode = 10
 	if runError, ok := err.(*exec.ExitError); ok && runError.ExitCode() == DnfErrorExitCode {
@@ -312,6 +523,7 @@ type rpmmdImpl struct {
 	CacheDir    string
 	RHSM        *RHSMSecrets
 	dnfJsonPath string
+	service     *dnfJSONService
 }
 
 func NewRPMMD(cacheDir, dnfJsonPath string) RPMMD {
@@ -322,6 +534,47 @@ func NewRPMMD(cacheDir, dnfJsonPath string) RPMMD {
 	}
 }
 
+// NewRPMMDService returns an RPMMD which sends its calls to a long-running
+// dnf-json daemon listening on socketPath, which it starts when needed. The
+// daemon keeps the metadata of repositories loaded between calls. If the
+// daemon is unavailable, dnf-json is run for each call as with NewRPMMD.
+func NewRPMMDService(cacheDir, dnfJsonPath, socketPath string) RPMMD {
+	return &rpmmdImpl{
+		CacheDir:    cacheDir,
+		RHSM:        getRHSMSecrets(),
+		dnfJsonPath: dnfJsonPath,
+		service:     newDNFJSONService(dnfJsonPath, socketPath),
+	}
+}
+
+// runDNF runs a call on the daemon, if there is one, or in a new dnf-json
+// process, and records its metrics. The cache is locked while the call runs,
+// so that CleanCache doesn't remove the metadata it uses.
+func (r *rpmmdImpl) runDNF(ctx context.Context, command string, arguments interface{}, result interface{}) error {
+	lock, err := lockCache(r.CacheDir, false)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	reply := &statsReply{result: result}
+	start := time.Now()
+	err = r.callDNF(ctx, command, arguments, reply)
+	dnfMetrics.record(command, time.Since(start), err, reply.stats)
+	return err
+}
+
+func (r *rpmmdImpl) callDNF(ctx context.Context, command string, arguments interface{}, result interface{}) error {
+	if r.service != nil && ctx.Value(withoutServiceKey{}) == nil {
+		err := r.service.call(ctx, command, arguments, result)
+		if !errors.Is(err, errServiceUnavailable) {
+			return err
+		}
+		log.Printf("%v, running dnf-json for a single call", err)
+	}
+	return runDNF(ctx, r.dnfJsonPath, command, arguments, result)
+}
+
 func (repo RepoConfig) toDNFRepoConfig(rpmmd *rpmmdImpl, i int) (dnfRepoConfig, error) {
 	id := strconv.Itoa(i)
 	dnfRepo := dnfRepoConfig{
@@ -332,19 +585,89 @@ func (repo RepoConfig) toDNFRepoConfig(rpmmd *rpmmdImpl, i int) (dnfRepoConfig,
 		GPGKey:         repo.GPGKey,
 		IgnoreSSL:      repo.IgnoreSSL,
 		MetadataExpire: repo.MetadataExpire,
+		Proxy:          repo.Proxy,
+		Priority:       repo.Priority,
+		ModuleHotfixes: repo.ModuleHotfixes,
+		SSLCACert:      repo.SSLCACert,
+		SSLClientKey:   repo.SSLClientKey,
+		SSLClientCert:  repo.SSLClientCert,
+		IncludePkgs:    repo.IncludePkgs,
+		ExcludePkgs:    repo.ExcludePkgs,
+		Variables:      repo.Variables,
 	}
-	if repo.RHSM {
+	if repo.RHSM && repo.SSLClientCert == "" {
 		if rpmmd.RHSM == nil {
 			return dnfRepoConfig{}, fmt.Errorf("RHSM secrets not found on host")
 		}
-		dnfRepo.SSLCACert = rpmmd.RHSM.SSLCACert
 		dnfRepo.SSLClientKey = rpmmd.RHSM.SSLClientKey
 		dnfRepo.SSLClientCert = rpmmd.RHSM.SSLClientCert
+		if dnfRepo.SSLCACert == "" {
+			dnfRepo.SSLCACert = rpmmd.RHSM.SSLCACert
+		}
 	}
 	return dnfRepo, nil
 }
 
-func (r *rpmmdImpl) FetchMetadata(repos []RepoConfig, modulePlatformID string, arch string) (PackageList, map[string]string, error) {
+// validateCertificates checks that client certificates come in pairs and
+// are entitlement certificates, which osbuild can use to download packages,
+// and that the CA bundle doesn't depend on the working directory
+func (repo RepoConfig) validateCertificates() error {
+	if repo.SSLCACert != "" && !filepath.IsAbs(repo.SSLCACert) {
+		return fmt.Errorf("sslcacert %s is not an absolute path", repo.SSLCACert)
+	}
+	if (repo.SSLClientKey == "") != (repo.SSLClientCert == "") {
+		return fmt.Errorf("sslclientkey and sslclientcert must be set together")
+	}
+	for _, path := range []string{repo.SSLClientKey, repo.SSLClientCert} {
+		if path != "" && filepath.Dir(filepath.Clean(path)) != entitlementDir {
+			return fmt.Errorf("client certificate %s is not in %s", path, entitlementDir)
+		}
+	}
+	return nil
+}
+
+// validatePackageGlobs checks the includepkgs and excludepkgs globs
+func (repo RepoConfig) validatePackageGlobs() error {
+	for _, pattern := range append(append([]string{}, repo.IncludePkgs...), repo.ExcludePkgs...) {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("empty package glob")
+		}
+		if _, err := glob.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid package glob %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// expandCopr replaces the copr:owner/project shorthand in the baseurl with
+// the URL of the COPR repository for distro and arch. The key of the COPR
+// project is used, unless the repository configures one.
+func (repo *RepoConfig) expandCopr(distro, arch string) error {
+	r, err := copr.Parse(repo.BaseURL)
+	if err != nil {
+		return err
+	}
+	chroot, err := copr.Chroot(distro)
+	if err != nil {
+		return err
+	}
+	repo.BaseURL = r.BaseURL(chroot, arch)
+	if repo.GPGKey == "" {
+		repo.GPGKey = r.GPGKey()
+	}
+	return nil
+}
+
+// secrets returns the name of the osbuild secrets needed to download the
+// packages of the repository
+func (repo RepoConfig) secrets() string {
+	if repo.RHSM || repo.SSLClientCert != "" {
+		return "org.osbuild.rhsm"
+	}
+	return ""
+}
+
+func (r *rpmmdImpl) FetchMetadata(ctx context.Context, repos []RepoConfig, modulePlatformID string, arch string) (PackageList, map[string]string, error) {
 	var dnfRepoConfigs []dnfRepoConfig
 	for i, repo := range repos {
 		dnfRepo, err := repo.toDNFRepoConfig(r, i)
@@ -365,11 +688,12 @@ func (r *rpmmdImpl) FetchMetadata(repos []RepoConfig, modulePlatformID string, a
 		Packages  PackageList       `json:"packages"`
 	}
 
-	err := runDNF(r.dnfJsonPath, "dump", arguments, &reply)
+	err := r.runDNF(ctx, "dump", arguments, &reply)
 
 	sort.Slice(reply.Packages, func(i, j int) bool {
 		return reply.Packages[i].Name < reply.Packages[j].Name
 	})
+	setRepoNames(reply.Packages, repos)
 	checksums := make(map[string]string)
 	for i, repo := range repos {
 		checksums[repo.Name] = reply.Checksums[strconv.Itoa(i)]
@@ -377,53 +701,251 @@ func (r *rpmmdImpl) FetchMetadata(repos []RepoConfig, modulePlatformID string, a
 	return reply.Packages, checksums, err
 }
 
-func (r *rpmmdImpl) Depsolve(specs, excludeSpecs []string, repos []RepoConfig, modulePlatformID, arch string) ([]PackageSpec, map[string]string, error) {
+// setRepoNames replaces the ids dnf-json returns as the repositories of
+// packages by the names of the repositories
+func setRepoNames(packages PackageList, repos []RepoConfig) {
+	for i := range packages {
+		id, err := strconv.Atoi(packages[i].Repo)
+		if err == nil && id >= 0 && id < len(repos) {
+			packages[i].Repo = repos[id].Name
+		}
+	}
+}
+
+func (r *rpmmdImpl) SearchPackages(ctx context.Context, query PackageQuery, repos []RepoConfig, modulePlatformID, arch string) (PackageSearchResult, error) {
+	err := query.Validate()
+	if err != nil {
+		return PackageSearchResult{}, err
+	}
+
+	var dnfRepoConfigs []dnfRepoConfig
+	var repoIDs []string
+	for i, repo := range repos {
+		dnfRepo, err := repo.toDNFRepoConfig(r, i)
+		if err != nil {
+			return PackageSearchResult{}, err
+		}
+		dnfRepoConfigs = append(dnfRepoConfigs, dnfRepo)
+		if contains(query.Repos, repo.Name) {
+			repoIDs = append(repoIDs, dnfRepo.ID)
+		}
+	}
+	// dnf-json doesn't filter by an empty list of repositories
+	if len(query.Repos) > 0 && len(repoIDs) == 0 {
+		return PackageSearchResult{}, nil
+	}
+
+	var arguments = struct {
+		Globs            []string        `json:"globs,omitempty"`
+		RepoIDs          []string        `json:"repo-ids,omitempty"`
+		Arches           []string        `json:"arches,omitempty"`
+		Sort             string          `json:"sort,omitempty"`
+		Offset           uint            `json:"offset"`
+		Limit            uint            `json:"limit"`
+		Repos            []dnfRepoConfig `json:"repos"`
+		CacheDir         string          `json:"cachedir"`
+		ModulePlatformID string          `json:"module_platform_id"`
+		Arch             string          `json:"arch"`
+	}{query.Globs, repoIDs, query.Arches, query.Sort, query.Offset, query.Limit, dnfRepoConfigs, r.CacheDir, modulePlatformID, arch}
+	var reply struct {
+		Total    uint        `json:"total"`
+		Packages PackageList `json:"packages"`
+	}
+	err = r.runDNF(ctx, "search", arguments, &reply)
+	setRepoNames(reply.Packages, repos)
+
+	return PackageSearchResult{reply.Total, reply.Packages}, err
+}
+
+func (r *rpmmdImpl) Depsolve(ctx context.Context, specs, excludeSpecs, moduleSpecs []string, repos []RepoConfig, modulePlatformID, arch string) ([]PackageSpec, map[string]string, error) {
+	result, err := r.DepsolvePackageSet(ctx, PackageSet{Include: specs, Exclude: excludeSpecs, Modules: moduleSpecs}, repos, modulePlatformID, arch)
+	return result.Packages, result.Checksums, err
+}
+
+func (r *rpmmdImpl) DepsolvePackageSet(ctx context.Context, set PackageSet, repos []RepoConfig, modulePlatformID, arch string) (DepsolveResult, error) {
 	var dnfRepoConfigs []dnfRepoConfig
 
 	for i, repo := range repos {
 		dnfRepo, err := repo.toDNFRepoConfig(r, i)
 		if err != nil {
-			return nil, nil, err
+			return DepsolveResult{}, err
 		}
 		dnfRepoConfigs = append(dnfRepoConfigs, dnfRepo)
 	}
 
 	var arguments = struct {
-		PackageSpecs     []string        `json:"package-specs"`
-		ExcludSpecs      []string        `json:"exclude-specs"`
+		dnfPackageSet
 		Repos            []dnfRepoConfig `json:"repos"`
 		CacheDir         string          `json:"cachedir"`
 		ModulePlatformID string          `json:"module_platform_id"`
 		Arch             string          `json:"arch"`
-	}{specs, excludeSpecs, dnfRepoConfigs, r.CacheDir, modulePlatformID, arch}
+	}{set.toDNFPackageSet(), dnfRepoConfigs, r.CacheDir, modulePlatformID, arch}
+	var reply dnfDepsolveResult
+	err := r.runDNF(ctx, "depsolve", arguments, &reply)
+	return reply.toDepsolveResult(repos), err
+}
+
+func (r *rpmmdImpl) DepsolvePackageSets(ctx context.Context, sets map[string]PackageSet, repos []RepoConfig, modulePlatformID, arch string) (map[string]DepsolveResult, error) {
+	var dnfRepoConfigs []dnfRepoConfig
+
+	for i, repo := range repos {
+		dnfRepo, err := repo.toDNFRepoConfig(r, i)
+		if err != nil {
+			return nil, err
+		}
+		dnfRepoConfigs = append(dnfRepoConfigs, dnfRepo)
+	}
+
+	dnfSets := make(map[string]dnfPackageSet, len(sets))
+	for name, set := range sets {
+		dnfSets[name] = set.toDNFPackageSet()
+	}
+
+	var arguments = struct {
+		PackageSets      map[string]dnfPackageSet `json:"package-sets"`
+		Repos            []dnfRepoConfig          `json:"repos"`
+		CacheDir         string                   `json:"cachedir"`
+		ModulePlatformID string                   `json:"module_platform_id"`
+		Arch             string                   `json:"arch"`
+	}{dnfSets, dnfRepoConfigs, r.CacheDir, modulePlatformID, arch}
 	var reply struct {
-		Checksums    map[string]string `json:"checksums"`
-		Dependencies []dnfPackageSpec  `json:"dependencies"`
+		Results map[string]dnfDepsolveResult `json:"results"`
+	}
+	err := r.runDNF(ctx, "depsolve-sets", arguments, &reply)
+	if err != nil {
+		var dnfError *DNFError
+		if errors.As(err, &dnfError) && dnfError.PackageSet != "" {
+			return nil, &PackageSetError{Name: dnfError.PackageSet, Err: err}
+		}
+		return nil, err
 	}
-	err := runDNF(r.dnfJsonPath, "depsolve", arguments, &reply)
 
-	dependencies := make([]PackageSpec, len(reply.Dependencies))
-	for i, pack := range reply.Dependencies {
-		id, err := strconv.Atoi(pack.RepoID)
+	results := make(map[string]DepsolveResult, len(reply.Results))
+	for name, result := range reply.Results {
+		results[name] = result.toDepsolveResult(repos)
+	}
+	return results, nil
+}
+
+// dnfPackageSet is a PackageSet as passed to dnf-json
+type dnfPackageSet struct {
+	PackageSpecs       []string `json:"package-specs"`
+	ExcludSpecs        []string `json:"exclude-specs"`
+	ModuleEnableSpecs  []string `json:"module-enable-specs,omitempty"`
+	ModuleDisableSpecs []string `json:"module-disable-specs,omitempty"`
+	InstallWeakDeps    bool     `json:"install_weak_deps"`
+	WithSources        bool     `json:"with-sources,omitempty"`
+	PinnedSpecs        []string `json:"pinned-specs,omitempty"`
+}
+
+func (set PackageSet) toDNFPackageSet() dnfPackageSet {
+	return dnfPackageSet{set.Include, set.Exclude, set.Modules, set.DisabledModules, !set.NoWeakDeps, set.WithSources, set.Pinned}
+}
+
+// dnfDepsolveResult is the result of resolving a single package set in
+// dnf-json, referring to repositories by their index
+type dnfDepsolveResult struct {
+	Checksums    map[string]string `json:"checksums"`
+	Dependencies []dnfPackageSpec  `json:"dependencies"`
+	Sources      []dnfPackageSpec  `json:"sources"`
+	Modules      []ModuleState     `json:"modules"`
+	Mirrors      map[string]string `json:"mirrors"`
+}
+
+func (reply dnfDepsolveResult) toDepsolveResult(repos []RepoConfig) DepsolveResult {
+	dependencies := toPackageSpecs(reply.Dependencies, repos)
+	sources := toPackageSpecs(reply.Sources, repos)
+
+	var mirrors map[string]string
+	if reply.Mirrors != nil {
+		mirrors = make(map[string]string)
+		for i, repo := range repos {
+			if mirror, ok := reply.Mirrors[strconv.Itoa(i)]; ok {
+				mirrors[repo.Name] = mirror
+			}
+		}
+	}
+
+	return DepsolveResult{dependencies, reply.Checksums, reply.Modules, mirrors, sources}
+}
+
+// toPackageSpecs converts the packages returned by dnf-json, adding the
+// download options of their repositories. Packages without a repository
+// only get their NEVRA.
+func toPackageSpecs(packages []dnfPackageSpec, repos []RepoConfig) []PackageSpec {
+	if packages == nil {
+		return nil
+	}
+	specs := make([]PackageSpec, len(packages))
+	for i, pkg := range packages {
+		specs[i] = PackageSpec{
+			Name:           pkg.Name,
+			Epoch:          pkg.Epoch,
+			Version:        pkg.Version,
+			Release:        pkg.Release,
+			Arch:           pkg.Arch,
+			RemoteLocation: pkg.RemoteLocation,
+			Checksum:       pkg.Checksum,
+			Reason:         pkg.Reason,
+			RecommendedBy:  pkg.RecommendedBy,
+			License:        pkg.License,
+			Summary:        pkg.Summary,
+			SourceRPM:      pkg.SourceRPM,
+		}
+		if pkg.RepoID == "" {
+			continue
+		}
+		id, err := strconv.Atoi(pkg.RepoID)
 		if err != nil {
 			panic(err)
 		}
 		repo := repos[id]
-		dep := reply.Dependencies[i]
-		dependencies[i].Name = dep.Name
-		dependencies[i].Epoch = dep.Epoch
-		dependencies[i].Version = dep.Version
-		dependencies[i].Release = dep.Release
-		dependencies[i].Arch = dep.Arch
-		dependencies[i].RemoteLocation = dep.RemoteLocation
-		dependencies[i].Checksum = dep.Checksum
-		dependencies[i].CheckGPG = repo.CheckGPG
-		if repo.RHSM {
-			dependencies[i].Secrets = "org.osbuild.rhsm"
+		specs[i].Repo = repo.Name
+		specs[i].CheckGPG = repo.CheckGPG
+		specs[i].Proxy = repo.Proxy
+		specs[i].IgnoreSSL = repo.IgnoreSSL
+		specs[i].SSLCACert = repo.SSLCACert
+		specs[i].Secrets = repo.secrets()
+		specs[i].Mirrors = repo.mirrors(pkg.RemoteLocation)
+	}
+	return specs
+}
+
+// BaseURLs returns the mirrors listed in BaseURL
+func (repo RepoConfig) BaseURLs() []string {
+	return strings.Fields(repo.BaseURL)
+}
+
+// mirrors returns the URLs of a package of the repository on all of its base
+// URLs except the one dnf downloaded the metadata from, which is the prefix of
+// remoteLocation
+func (repo RepoConfig) mirrors(remoteLocation string) []string {
+	baseURLs := repo.BaseURLs()
+	if len(baseURLs) < 2 {
+		return nil
+	}
+	var path string
+	found := false
+	for _, baseURL := range baseURLs {
+		prefix := strings.TrimSuffix(baseURL, "/") + "/"
+		if strings.HasPrefix(remoteLocation, prefix) {
+			path = strings.TrimPrefix(remoteLocation, prefix)
+			found = true
+			break
 		}
 	}
+	if !found {
+		return nil
+	}
 
-	return dependencies, reply.Checksums, err
+	var mirrors []string
+	for _, baseURL := range baseURLs {
+		url := strings.TrimSuffix(baseURL, "/") + "/" + path
+		if url != remoteLocation {
+			mirrors = append(mirrors, url)
+		}
+	}
+	return mirrors
 }
 
 func (packages PackageList) Search(globPatterns ...string) (PackageList, error) {
@@ -478,7 +1000,7 @@ func (packages PackageList) ToPackageInfos() []PackageInfo {
 	return results
 }
 
-func (pkg *PackageInfo) FillDependencies(rpmmd RPMMD, repos []RepoConfig, modulePlatformID string, arch string) (err error) {
-	pkg.Dependencies, _, err = rpmmd.Depsolve([]string{pkg.Name}, nil, repos, modulePlatformID, arch)
+func (pkg *PackageInfo) FillDependencies(ctx context.Context, rpmmd RPMMD, repos []RepoConfig, modulePlatformID string, arch string) (err error) {
+	pkg.Dependencies, _, err = rpmmd.Depsolve(ctx, []string{pkg.Name}, nil, nil, repos, modulePlatformID, arch)
 	return
 }
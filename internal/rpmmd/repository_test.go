@@ -0,0 +1,333 @@
+package rpmmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadRepositoriesPriority(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "repositories"), 0755)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(dir, "repositories", "fedora-32.json"), []byte(`{
+		"x86_64": [
+			{"name": "fedora", "baseurl": "http://example.com/fedora", "priority": 10},
+			{"name": "third-party", "baseurl": "http://example.com/third-party"}
+		]
+	}`), 0644)
+	require.NoError(t, err)
+
+	repos, err := LoadRepositories([]string{dir}, "fedora-32")
+	require.NoError(t, err)
+	require.Len(t, repos["x86_64"], 2)
+	assert.Equal(t, 10, repos["x86_64"][0].Priority)
+	assert.Equal(t, 0, repos["x86_64"][1].Priority)
+}
+
+func TestLoadRepositoriesLayering(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	etc := filepath.Join(dir, "etc")
+	usr := filepath.Join(dir, "usr")
+	for _, confPath := range []string{etc, usr} {
+		err = os.MkdirAll(filepath.Join(confPath, "repositories"), 0755)
+		require.NoError(t, err)
+	}
+	err = ioutil.WriteFile(filepath.Join(usr, "repositories", "fedora-33.json"), []byte(`{
+		"x86_64": [{"name": "fedora", "baseurl": "http://example.com/fedora/x86_64"}],
+		"aarch64": [{"name": "fedora", "baseurl": "http://example.com/fedora/aarch64"}]
+	}`), 0644)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(etc, "repositories", "fedora-33.json"), []byte(`{
+		"x86_64": [
+			{"name": "mirror", "baseurl": "http://mirror.example.com/fedora/x86_64"},
+			{"name": "internal", "baseurl": "http://internal.example.com/$stage/$basearch", "variables": {"stage": "prod"}}
+		]
+	}`), 0644)
+	require.NoError(t, err)
+
+	repos, err := LoadRepositories([]string{etc, usr}, "fedora-33")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]RepoConfig{
+		"x86_64": {
+			{Name: "mirror", BaseURL: "http://mirror.example.com/fedora/x86_64"},
+			{Name: "internal", BaseURL: "http://internal.example.com/$stage/$basearch", Variables: map[string]string{"stage": "prod"}},
+		},
+		"aarch64": {
+			{Name: "fedora", BaseURL: "http://example.com/fedora/aarch64"},
+		},
+	}, repos)
+
+	// a distribution only configured in one of the paths
+	repos, err = LoadRepositories([]string{filepath.Join(dir, "missing"), usr}, "fedora-33")
+	require.NoError(t, err)
+	assert.Len(t, repos, 2)
+
+	_, err = LoadRepositories([]string{etc, usr}, "fedora-32")
+	assert.EqualError(t, err, "LoadRepositories failed: none of the provided paths contain distro configuration")
+}
+
+func TestToDNFRepoConfig(t *testing.T) {
+	rpm := &rpmmdImpl{}
+
+	repo := RepoConfig{
+		Name:           "fedora",
+		BaseURL:        "http://example.com/$contentdir/$releasever",
+		Proxy:          "http://proxy.example.com:3128",
+		Priority:       10,
+		ModuleHotfixes: true,
+		Variables:      map[string]string{"contentdir": "fedora"},
+	}
+	dnfRepo, err := repo.toDNFRepoConfig(rpm, 3)
+	require.NoError(t, err)
+	assert.Equal(t, dnfRepoConfig{
+		ID:             "3",
+		BaseURL:        "http://example.com/$contentdir/$releasever",
+		Proxy:          "http://proxy.example.com:3128",
+		Priority:       10,
+		ModuleHotfixes: true,
+		Variables:      map[string]string{"contentdir": "fedora"},
+	}, dnfRepo)
+
+	// without priority, dnf's default is kept
+	repo.Priority = 0
+	dnfRepo, err = repo.toDNFRepoConfig(rpm, 3)
+	require.NoError(t, err)
+	assert.Zero(t, dnfRepo.Priority)
+
+	repo.RHSM = true
+	_, err = repo.toDNFRepoConfig(rpm, 3)
+	assert.EqualError(t, err, "RHSM secrets not found on host")
+}
+
+func TestLoadRepositoriesCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "repositories"), 0755)
+	require.NoError(t, err)
+	path := filepath.Join(dir, "repositories", "rhel-84.json")
+
+	err = ioutil.WriteFile(path, []byte(`{
+		"x86_64": [{
+			"name": "entitled",
+			"baseurl": "https://cdn.example.com/entitled",
+			"sslcacert": "/etc/rhsm/ca/redhat-uep.pem",
+			"sslclientkey": "/etc/pki/entitlement/1234-key.pem",
+			"sslclientcert": "/etc/pki/entitlement/1234.pem"
+		}]
+	}`), 0644)
+	require.NoError(t, err)
+
+	repos, err := LoadRepositories([]string{dir}, "rhel-84")
+	require.NoError(t, err)
+	require.Len(t, repos["x86_64"], 1)
+	repo := repos["x86_64"][0]
+	assert.Equal(t, "/etc/rhsm/ca/redhat-uep.pem", repo.SSLCACert)
+	assert.Equal(t, "/etc/pki/entitlement/1234-key.pem", repo.SSLClientKey)
+	assert.Equal(t, "/etc/pki/entitlement/1234.pem", repo.SSLClientCert)
+	assert.Equal(t, "org.osbuild.rhsm", repo.secrets())
+
+	err = ioutil.WriteFile(path, []byte(`{
+		"x86_64": [{
+			"name": "entitled",
+			"baseurl": "https://cdn.example.com/entitled",
+			"sslclientkey": "/home/user/key.pem",
+			"sslclientcert": "/home/user/cert.pem"
+		}]
+	}`), 0644)
+	require.NoError(t, err)
+	_, err = LoadRepositories([]string{dir}, "rhel-84")
+	assert.EqualError(t, err, "LoadRepositories failed: repository entitled: client certificate /home/user/key.pem is not in /etc/pki/entitlement")
+
+	err = ioutil.WriteFile(path, []byte(`{
+		"x86_64": [{
+			"name": "entitled",
+			"baseurl": "https://cdn.example.com/entitled",
+			"sslclientcert": "/etc/pki/entitlement/1234.pem"
+		}]
+	}`), 0644)
+	require.NoError(t, err)
+	_, err = LoadRepositories([]string{dir}, "rhel-84")
+	assert.EqualError(t, err, "LoadRepositories failed: repository entitled: sslclientkey and sslclientcert must be set together")
+
+	err = ioutil.WriteFile(path, []byte(`{
+		"x86_64": [
+			{"name": "lab", "baseurl": "https://mirror.lab/repo", "sslverify": false},
+			{"name": "internal", "baseurl": "https://mirror.internal/repo", "sslverify": true, "sslcacert": "/etc/pki/tls/certs/internal.pem"}
+		]
+	}`), 0644)
+	require.NoError(t, err)
+	repos, err = LoadRepositories([]string{dir}, "rhel-84")
+	require.NoError(t, err)
+	require.Len(t, repos["x86_64"], 2)
+	assert.True(t, repos["x86_64"][0].IgnoreSSL)
+	assert.False(t, repos["x86_64"][1].IgnoreSSL)
+	assert.Equal(t, "/etc/pki/tls/certs/internal.pem", repos["x86_64"][1].SSLCACert)
+
+	specs := toPackageSpecs([]dnfPackageSpec{
+		{Name: "lab", RepoID: "0"},
+		{Name: "internal", RepoID: "1"},
+	}, repos["x86_64"])
+	assert.True(t, specs[0].IgnoreSSL)
+	assert.Equal(t, "/etc/pki/tls/certs/internal.pem", specs[1].SSLCACert)
+
+	err = ioutil.WriteFile(path, []byte(`{
+		"x86_64": [{"name": "internal", "baseurl": "https://mirror.internal/repo", "sslcacert": "certs/internal.pem"}]
+	}`), 0644)
+	require.NoError(t, err)
+	_, err = LoadRepositories([]string{dir}, "rhel-84")
+	assert.EqualError(t, err, "LoadRepositories failed: repository internal: sslcacert certs/internal.pem is not an absolute path")
+}
+
+func TestPackageSpecMirrors(t *testing.T) {
+	repos := []RepoConfig{
+		{Name: "mirrored", BaseURL: "https://one.example.com/fedora/ https://two.example.com/fedora\n\thttps://three.example.com/fedora"},
+		{Name: "single", BaseURL: "https://example.com/fedora"},
+	}
+	assert.Equal(t, []string{"https://one.example.com/fedora/", "https://two.example.com/fedora", "https://three.example.com/fedora"}, repos[0].BaseURLs())
+
+	specs := toPackageSpecs([]dnfPackageSpec{
+		{Name: "bash", RepoID: "0", RemoteLocation: "https://two.example.com/fedora/Packages/b/bash.rpm"},
+		{Name: "dnf", RepoID: "1", RemoteLocation: "https://example.com/fedora/Packages/d/dnf.rpm"},
+	}, repos)
+	assert.Equal(t, []string{"https://one.example.com/fedora/Packages/b/bash.rpm", "https://three.example.com/fedora/Packages/b/bash.rpm"}, specs[0].Mirrors)
+	assert.Nil(t, specs[1].Mirrors)
+}
+
+func TestToDNFRepoConfigRHSM(t *testing.T) {
+	rpm := &rpmmdImpl{
+		RHSM: &RHSMSecrets{
+			SSLCACert:     "/etc/rhsm/ca/redhat-uep.pem",
+			SSLClientKey:  "/etc/pki/entitlement/1-key.pem",
+			SSLClientCert: "/etc/pki/entitlement/1.pem",
+		},
+	}
+
+	// RHSM uses the first entitlement of the host
+	repo := RepoConfig{Name: "rhel", BaseURL: "https://cdn.example.com/rhel", RHSM: true}
+	dnfRepo, err := repo.toDNFRepoConfig(rpm, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/rhsm/ca/redhat-uep.pem", dnfRepo.SSLCACert)
+	assert.Equal(t, "/etc/pki/entitlement/1-key.pem", dnfRepo.SSLClientKey)
+	assert.Equal(t, "/etc/pki/entitlement/1.pem", dnfRepo.SSLClientCert)
+
+	// explicit certificates win over the ones of RHSM
+	repo.SSLCACert = "/etc/rhsm/ca/other.pem"
+	repo.SSLClientKey = "/etc/pki/entitlement/2-key.pem"
+	repo.SSLClientCert = "/etc/pki/entitlement/2.pem"
+	dnfRepo, err = repo.toDNFRepoConfig(rpm, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/rhsm/ca/other.pem", dnfRepo.SSLCACert)
+	assert.Equal(t, "/etc/pki/entitlement/2-key.pem", dnfRepo.SSLClientKey)
+	assert.Equal(t, "/etc/pki/entitlement/2.pem", dnfRepo.SSLClientCert)
+
+	// and don't need RHSM secrets on the host
+	rpm.RHSM = nil
+	repo.RHSM = false
+	_, err = repo.toDNFRepoConfig(rpm, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "org.osbuild.rhsm", repo.secrets())
+}
+
+func TestLoadRepositoriesCopr(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "repositories"), 0755)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(dir, "repositories", "fedora-33.json"), []byte(`{
+		"x86_64": [
+			{"name": "tools", "baseurl": "copr:@group/tools", "check_gpg": true},
+			{"name": "mirror", "baseurl": "copr:user/project", "gpgkey": "https://example.com/key.asc"}
+		],
+		"aarch64": [
+			{"name": "tools", "baseurl": "copr:@group/tools", "check_gpg": true}
+		]
+	}`), 0644)
+	require.NoError(t, err)
+
+	repos, err := LoadRepositories([]string{dir}, "fedora-33")
+	require.NoError(t, err)
+	assert.Equal(t, []RepoConfig{
+		{
+			Name:     "tools",
+			BaseURL:  "https://download.copr.fedorainfracloud.org/results/@group/tools/fedora-33-x86_64/",
+			GPGKey:   "https://download.copr.fedorainfracloud.org/results/@group/tools/pubkey.gpg",
+			CheckGPG: true,
+		},
+		{
+			Name:    "mirror",
+			BaseURL: "https://download.copr.fedorainfracloud.org/results/user/project/fedora-33-x86_64/",
+			GPGKey:  "https://example.com/key.asc",
+		},
+	}, repos["x86_64"])
+	require.Len(t, repos["aarch64"], 1)
+	assert.Equal(t, "https://download.copr.fedorainfracloud.org/results/@group/tools/fedora-33-aarch64/", repos["aarch64"][0].BaseURL)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "repositories", "fedora-33.json"), []byte(`{
+		"x86_64": [{"name": "tools", "baseurl": "copr:tools"}]
+	}`), 0644)
+	require.NoError(t, err)
+	_, err = LoadRepositories([]string{dir}, "fedora-33")
+	assert.EqualError(t, err, `LoadRepositories failed: repository tools: invalid COPR repository "copr:tools", expected copr:owner/project`)
+}
+
+func TestLoadRepositoriesPackageGlobs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "repositories"), 0755)
+	require.NoError(t, err)
+	path := filepath.Join(dir, "repositories", "fedora-33.json")
+
+	err = ioutil.WriteFile(path, []byte(`{
+		"x86_64": [{
+			"name": "third-party",
+			"baseurl": "http://example.com/third-party",
+			"includepkgs": ["tool", "tool-*"],
+			"excludepkgs": ["tool-debug*"]
+		}]
+	}`), 0644)
+	require.NoError(t, err)
+
+	repos, err := LoadRepositories([]string{dir}, "fedora-33")
+	require.NoError(t, err)
+	require.Len(t, repos["x86_64"], 1)
+	repo := repos["x86_64"][0]
+	assert.Equal(t, []string{"tool", "tool-*"}, repo.IncludePkgs)
+	assert.Equal(t, []string{"tool-debug*"}, repo.ExcludePkgs)
+
+	dnfRepo, err := repo.toDNFRepoConfig(&rpmmdImpl{}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tool", "tool-*"}, dnfRepo.IncludePkgs)
+	assert.Equal(t, []string{"tool-debug*"}, dnfRepo.ExcludePkgs)
+
+	err = ioutil.WriteFile(path, []byte(`{
+		"x86_64": [{"name": "third-party", "baseurl": "http://example.com/third-party", "excludepkgs": ["tool-[debug"]}]
+	}`), 0644)
+	require.NoError(t, err)
+	_, err = LoadRepositories([]string{dir}, "fedora-33")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `LoadRepositories failed: repository third-party: invalid package glob "tool-[debug"`)
+
+	err = ioutil.WriteFile(path, []byte(`{
+		"x86_64": [{"name": "third-party", "baseurl": "http://example.com/third-party", "includepkgs": [" "]}]
+	}`), 0644)
+	require.NoError(t, err)
+	_, err = LoadRepositories([]string{dir}, "fedora-33")
+	assert.EqualError(t, err, "LoadRepositories failed: repository third-party: empty package glob")
+}
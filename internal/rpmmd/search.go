@@ -0,0 +1,140 @@
+package rpmmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// The keys packages can be sorted by. Prefixing a key with "-" sorts in
+// descending order.
+const (
+	SortByName      = "name"
+	SortByBuildTime = "buildtime"
+)
+
+// A PackageQuery selects the packages of a set of repositories. Globs match
+// package names, Repos are names of repositories and Arches architectures of
+// packages; empty lists match everything. The matching packages are grouped
+// by name and sorted by Sort, name by default. Offset and Limit select a page
+// of these groups.
+type PackageQuery struct {
+	Globs  []string
+	Repos  []string
+	Arches []string
+	Sort   string
+	Offset uint
+	Limit  uint
+}
+
+// A PackageSearchResult is a page of the packages matching a PackageQuery.
+// Total is the number of distinct package names matching the query, the
+// Packages contain all builds of the names on the page.
+type PackageSearchResult struct {
+	Total    uint
+	Packages PackageList
+}
+
+// Validate checks the globs and the sort key of the query
+func (q PackageQuery) Validate() error {
+	for _, pattern := range q.Globs {
+		if _, err := glob.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid glob %q: %v", pattern, err)
+		}
+	}
+	switch strings.TrimPrefix(q.Sort, "-") {
+	case "", SortByName, SortByBuildTime:
+	default:
+		return fmt.Errorf("cannot sort packages by %q, use %s or %s", q.Sort, SortByName, SortByBuildTime)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Query applies query to packages, like SearchPackages does with the packages
+// of repositories. The builds of each name keep their order in packages.
+func (packages PackageList) Query(query PackageQuery) (PackageSearchResult, error) {
+	err := query.Validate()
+	if err != nil {
+		return PackageSearchResult{}, err
+	}
+
+	var globs []glob.Glob
+	for _, pattern := range query.Globs {
+		globs = append(globs, glob.MustCompile(pattern))
+	}
+
+	var names []string
+	builds := make(map[string]PackageList)
+	for _, pkg := range packages {
+		if len(query.Repos) > 0 && !contains(query.Repos, pkg.Repo) {
+			continue
+		}
+		if len(query.Arches) > 0 && !contains(query.Arches, pkg.Arch) {
+			continue
+		}
+		if len(globs) > 0 {
+			matched := false
+			for _, g := range globs {
+				if g.Match(pkg.Name) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+		}
+		if _, ok := builds[pkg.Name]; !ok {
+			names = append(names, pkg.Name)
+		}
+		builds[pkg.Name] = append(builds[pkg.Name], pkg)
+	}
+
+	newest := func(name string) int64 {
+		var t int64
+		for _, pkg := range builds[name] {
+			if pkg.BuildTime.Unix() > t {
+				t = pkg.BuildTime.Unix()
+			}
+		}
+		return t
+	}
+	descending := strings.HasPrefix(query.Sort, "-")
+	sort.Slice(names, func(i, j int) bool {
+		a, b := names[i], names[j]
+		if descending {
+			a, b = b, a
+		}
+		if strings.TrimPrefix(query.Sort, "-") == SortByBuildTime && newest(a) != newest(b) {
+			return newest(a) < newest(b)
+		}
+		return a < b
+	})
+
+	total := uint(len(names))
+	start := query.Offset
+	if start > total {
+		start = total
+	}
+	end := total
+	if query.Limit < total-start {
+		end = start + query.Limit
+	}
+
+	result := PackageSearchResult{Total: total}
+	for _, name := range names[start:end] {
+		result.Packages = append(result.Packages, builds[name]...)
+	}
+	return result, nil
+}
@@ -0,0 +1,86 @@
+package rpmmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageListQuery(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+	packages := PackageList{
+		{Name: "bash", Version: "5.0", Arch: "x86_64", BuildTime: day(1), Repo: "fedora"},
+		{Name: "bash", Version: "5.1", Arch: "x86_64", BuildTime: day(5), Repo: "updates"},
+		{Name: "glibc", Version: "2.32", Arch: "i686", BuildTime: day(3), Repo: "fedora"},
+		{Name: "glibc", Version: "2.32", Arch: "x86_64", BuildTime: day(3), Repo: "fedora"},
+		{Name: "python3", Version: "3.9", Arch: "x86_64", BuildTime: day(2), Repo: "fedora"},
+		{Name: "python3-pip", Version: "20.2", Arch: "noarch", BuildTime: day(4), Repo: "updates"},
+	}
+
+	names := func(packages PackageList) []string {
+		var names []string
+		for _, pkg := range packages {
+			names = append(names, pkg.Name+"-"+pkg.Version+"."+pkg.Arch)
+		}
+		return names
+	}
+
+	cases := []struct {
+		query PackageQuery
+		total uint
+		names []string
+	}{
+		{
+			PackageQuery{Limit: 2},
+			4,
+			[]string{"bash-5.0.x86_64", "bash-5.1.x86_64", "glibc-2.32.i686", "glibc-2.32.x86_64"},
+		},
+		{
+			PackageQuery{Offset: 3, Limit: 2},
+			4,
+			[]string{"python3-pip-20.2.noarch"},
+		},
+		{
+			PackageQuery{Offset: 10, Limit: 2},
+			4,
+			nil,
+		},
+		{
+			PackageQuery{Globs: []string{"python3*"}, Sort: "-name", Limit: 10},
+			2,
+			[]string{"python3-pip-20.2.noarch", "python3-3.9.x86_64"},
+		},
+		{
+			PackageQuery{Repos: []string{"updates"}, Limit: 10},
+			2,
+			[]string{"bash-5.1.x86_64", "python3-pip-20.2.noarch"},
+		},
+		{
+			PackageQuery{Arches: []string{"x86_64", "noarch"}, Sort: SortByBuildTime, Limit: 10},
+			4,
+			[]string{"python3-3.9.x86_64", "glibc-2.32.x86_64", "python3-pip-20.2.noarch", "bash-5.0.x86_64", "bash-5.1.x86_64"},
+		},
+		{
+			PackageQuery{Sort: "-" + SortByBuildTime, Limit: 1},
+			4,
+			[]string{"bash-5.0.x86_64", "bash-5.1.x86_64"},
+		},
+	}
+
+	for _, c := range cases {
+		result, err := packages.Query(c.query)
+		require.NoError(t, err)
+		assert.Equal(t, c.total, result.Total, "%+v", c.query)
+		assert.Equal(t, c.names, names(result.Packages), "%+v", c.query)
+	}
+
+	_, err := packages.Query(PackageQuery{Globs: []string{"[bash"}})
+	assert.EqualError(t, err, `invalid glob "[bash": unexpected end of input`)
+
+	_, err = packages.Query(PackageQuery{Sort: "size"})
+	assert.EqualError(t, err, `cannot sort packages by "size", use name or buildtime`)
+}
@@ -0,0 +1,181 @@
+package rpmmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// serviceStartTimeout is how long dnfJSONService waits for a newly started
+// dnf-json daemon to listen on its socket
+const serviceStartTimeout = 10 * time.Second
+
+// errServiceUnavailable is returned when the dnf-json daemon could not be
+// reached or started. rpmmdImpl runs dnf-json for the single call instead.
+var errServiceUnavailable = errors.New("dnf-json service unavailable")
+
+type withoutServiceKey struct{}
+
+// withoutService returns a context for calls which run dnf-json in a process
+// of their own even if there is a daemon, so that they can run concurrently
+func withoutService(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withoutServiceKey{}, true)
+}
+
+// A dnfJSONService is a dnf-json daemon listening on a unix socket. The
+// daemon keeps the metadata of repositories loaded between calls, which
+// saves starting python and loading the metadata for every call. It is
+// started on the first call and restarted when it exits.
+type dnfJSONService struct {
+	dnfJsonPath string
+	socketPath  string
+	client      *http.Client
+
+	mu      sync.Mutex
+	running bool
+	process *os.Process
+}
+
+func newDNFJSONService(dnfJsonPath, socketPath string) *dnfJSONService {
+	return &dnfJSONService{
+		dnfJsonPath: dnfJsonPath,
+		socketPath:  socketPath,
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// ensureRunning starts the daemon, unless it is running or something else
+// already listens on the socket
+func (s *dnfJSONService) ensureRunning() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running || s.listening() {
+		return nil
+	}
+
+	cmd := exec.Command(s.dnfJsonPath, "--socket", s.socketPath)
+	cmd.Stderr = os.Stderr
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+	s.running = true
+	s.process = cmd.Process
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	timeout := time.After(serviceStartTimeout)
+	for !s.listening() {
+		select {
+		case err := <-exited:
+			return fmt.Errorf("dnf-json exited before listening on %s: %v", s.socketPath, err)
+		case <-timeout:
+			_ = cmd.Process.Kill()
+			return fmt.Errorf("dnf-json did not listen on %s after %v", s.socketPath, serviceStartTimeout)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+func (s *dnfJSONService) listening() bool {
+	conn, err := net.Dial("unix", s.socketPath)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// kill stops the daemon, if it was started by s. The daemon keeps working on
+// a call when its client goes away, and serves one call at a time, so that a
+// canceled call would block all others until it is done. Other running calls
+// fail over to running dnf-json for single calls, and the daemon is started
+// again for the next call.
+func (s *dnfJSONService) kill() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		_ = s.process.Kill()
+	}
+}
+
+// call sends a call to the daemon, with the same semantics as runDNF
+func (s *dnfJSONService) call(ctx context.Context, command string, arguments interface{}, result interface{}) error {
+	err := s.ensureRunning()
+	if err != nil {
+		return fmt.Errorf("%w: %v", errServiceUnavailable, err)
+	}
+
+	var call = struct {
+		Command   string      `json:"command"`
+		Arguments interface{} `json:"arguments,omitempty"`
+	}{
+		command,
+		arguments,
+	}
+	body, err := json.Marshal(call)
+	if err != nil {
+		return err
+	}
+
+	// the host is ignored when dialing the socket
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://dnf-json/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if ctx.Err() != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		s.kill()
+		return ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errServiceUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	output, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return json.Unmarshal(output, result)
+	case http.StatusUnprocessableEntity:
+		var dnfError DNFError
+		err = json.Unmarshal(output, &dnfError)
+		if err != nil {
+			return err
+		}
+		return &dnfError
+	default:
+		return fmt.Errorf("dnf-json service returned %s: %s", resp.Status, output)
+	}
+}
@@ -0,0 +1,301 @@
+package rpmmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveDNFJSON serves handler on a unix socket in dir, like a dnf-json daemon
+func serveDNFJSON(t *testing.T, dir string, handler http.HandlerFunc) string {
+	socketPath := filepath.Join(dir, "dnf-json.socket")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	server := &http.Server{Handler: handler}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(func() { server.Close() })
+	return socketPath
+}
+
+func TestServiceDepsolve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var installWeakDeps, withSources []bool
+	socketPath := serveDNFJSON(t, dir, func(w http.ResponseWriter, r *http.Request) {
+		var call struct {
+			Command   string `json:"command"`
+			Arguments struct {
+				PackageSpecs    []string `json:"package-specs"`
+				InstallWeakDeps bool     `json:"install_weak_deps"`
+				WithSources     bool     `json:"with-sources"`
+			} `json:"arguments"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&call))
+		assert.Equal(t, "depsolve", call.Command)
+		installWeakDeps = append(installWeakDeps, call.Arguments.InstallWeakDeps)
+		withSources = append(withSources, call.Arguments.WithSources)
+
+		if call.Arguments.PackageSpecs[0] == "missing" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"kind":"MarkingErrors","reason":"no package matches missing","details":{"missing_specs":["missing"],"culprits":["missing"]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{
+			"checksums": {"0": "sha256:01"},
+			"dependencies": [
+				{"name": "bash", "version": "5.0", "release": "1", "arch": "x86_64", "repo_id": "0", "checksum": "sha256:02", "reason": "user", "license": "GPLv3+", "summary": "The GNU Bourne Again shell", "sourcerpm": "bash-5.0-1.src.rpm"},
+				{"name": "bash-completion", "version": "2.11", "release": "1", "arch": "noarch", "repo_id": "0", "checksum": "sha256:04", "reason": "weak-dependency", "recommended_by": ["bash"]}
+			],
+			"modules": [],
+			"mirrors": {"0": "http://mirror.example.com/fedora"},
+			"sources": [
+				{"name": "bash", "version": "5.0", "release": "1", "arch": "src", "repo_id": "1", "remote_location": "http://example.com/fedora-source/bash-5.0-1.src.rpm", "checksum": "sha256:03"},
+				{"name": "glibc", "version": "2.32", "release": "1", "arch": "src"}
+			]
+		}`))
+	})
+
+	// the daemon is never started, because the socket is listening already
+	rpm := NewRPMMDService(dir, filepath.Join(dir, "non-existing"), socketPath)
+	repos := []RepoConfig{
+		{Name: "fedora", BaseURL: "http://example.com/fedora"},
+		{Name: "fedora-source", BaseURL: "http://example.com/fedora-source", CheckGPG: true},
+	}
+
+	packages, checksums, err := rpm.Depsolve(context.Background(), []string{"bash"}, nil, nil, repos, "platform:f33", "x86_64")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"0": "sha256:01"}, checksums)
+	assert.Equal(t, []PackageSpec{
+		{Name: "bash", Version: "5.0", Release: "1", Arch: "x86_64", Repo: "fedora", Checksum: "sha256:02", Reason: ReasonUser, License: "GPLv3+", Summary: "The GNU Bourne Again shell", SourceRPM: "bash-5.0-1.src.rpm"},
+		{Name: "bash-completion", Version: "2.11", Release: "1", Arch: "noarch", Repo: "fedora", Checksum: "sha256:04", Reason: ReasonWeakDependency, RecommendedBy: []string{"bash"}},
+	}, packages)
+
+	result, err := rpm.DepsolvePackageSet(context.Background(), PackageSet{Include: []string{"bash"}, NoWeakDeps: true, WithSources: true}, repos, "platform:f33", "x86_64")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"fedora": "http://mirror.example.com/fedora"}, result.Mirrors)
+	assert.Equal(t, []PackageSpec{
+		{Name: "bash", Version: "5.0", Release: "1", Arch: "src", Repo: "fedora-source", RemoteLocation: "http://example.com/fedora-source/bash-5.0-1.src.rpm", Checksum: "sha256:03", CheckGPG: true},
+		{Name: "glibc", Version: "2.32", Release: "1", Arch: "src"},
+	}, result.SourcePackages)
+	assert.Equal(t, []bool{true, false}, installWeakDeps)
+	assert.Equal(t, []bool{false, true}, withSources)
+
+	_, _, err = rpm.Depsolve(context.Background(), []string{"missing"}, nil, nil, repos, "platform:f33", "x86_64")
+	assert.Equal(t, &DNFError{
+		Kind:    "MarkingErrors",
+		Reason:  "no package matches missing",
+		Details: &DepsolveErrorDetails{MissingSpecs: []string{"missing"}, Culprits: []string{"missing"}},
+	}, err)
+}
+
+func TestServiceUnavailable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// a dnf-json which can't run as a daemon, but answers single calls
+	dnfJSON := filepath.Join(dir, "dnf-json")
+	err = ioutil.WriteFile(dnfJSON, []byte(`#!/bin/sh
+[ "$1" = "--socket" ] && exit 1
+cat >/dev/null
+echo '{"checksums": {"0": "sha256:01"}, "dependencies": []}'
+`), 0755)
+	require.NoError(t, err)
+
+	service := newDNFJSONService(dnfJSON, filepath.Join(dir, "dnf-json.socket"))
+	var result interface{}
+	err = service.call(context.Background(), "depsolve", nil, &result)
+	assert.True(t, errors.Is(err, errServiceUnavailable))
+
+	rpm := NewRPMMDService(dir, dnfJSON, filepath.Join(dir, "dnf-json.socket"))
+	packages, checksums, err := rpm.Depsolve(context.Background(), []string{"bash"}, nil, nil, nil, "platform:f33", "x86_64")
+	require.NoError(t, err)
+	assert.Empty(t, packages)
+	assert.Equal(t, map[string]string{"0": "sha256:01"}, checksums)
+}
+
+func TestDepsolveCanceled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// a dnf-json which never answers
+	dnfJSON := filepath.Join(dir, "dnf-json")
+	err = ioutil.WriteFile(dnfJSON, []byte("#!/bin/sh\nexec sleep 60\n"), 0755)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err = NewRPMMD(dir, dnfJSON).Depsolve(ctx, []string{"bash"}, nil, nil, nil, "platform:f33", "x86_64")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Less(t, int64(time.Since(start)), int64(10*time.Second), "dnf-json was not killed")
+
+	// a daemon which never answers
+	socketPath := serveDNFJSON(t, dir, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	rpm := NewRPMMDService(dir, filepath.Join(dir, "non-existing"), socketPath)
+	_, err = rpm.DepsolvePackageSet(ctx, PackageSet{Include: []string{"bash"}}, nil, "platform:f33", "x86_64")
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestServiceDepsolvePackageSets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socketPath := serveDNFJSON(t, dir, func(w http.ResponseWriter, r *http.Request) {
+		var call struct {
+			Command   string `json:"command"`
+			Arguments struct {
+				PackageSets map[string]struct {
+					PackageSpecs    []string `json:"package-specs"`
+					InstallWeakDeps bool     `json:"install_weak_deps"`
+				} `json:"package-sets"`
+			} `json:"arguments"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&call))
+		assert.Equal(t, "depsolve-sets", call.Command)
+		require.Len(t, call.Arguments.PackageSets, 2)
+		assert.False(t, call.Arguments.PackageSets[OSPackageSet].InstallWeakDeps)
+		assert.True(t, call.Arguments.PackageSets[BuildPackageSet].InstallWeakDeps)
+
+		if call.Arguments.PackageSets[BuildPackageSet].PackageSpecs[0] == "missing" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"kind":"MarkingErrors","reason":"no package matches missing","package_set":"build"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"results": {
+			"build": {
+				"checksums": {"0": "sha256:01"},
+				"dependencies": [{"name": "rpm", "version": "4.16", "release": "1", "arch": "x86_64", "repo_id": "0", "checksum": "sha256:03"}]
+			},
+			"os": {
+				"checksums": {"0": "sha256:01"},
+				"dependencies": [{"name": "bash", "version": "5.0", "release": "1", "arch": "x86_64", "repo_id": "0", "checksum": "sha256:02"}],
+				"mirrors": {"0": "http://mirror.example.com/fedora"}
+			}
+		}}`))
+	})
+
+	rpm := NewRPMMDService(dir, filepath.Join(dir, "non-existing"), socketPath)
+	repos := []RepoConfig{{Name: "fedora", BaseURL: "http://example.com/fedora", CheckGPG: true}}
+
+	results, err := rpm.DepsolvePackageSets(context.Background(), map[string]PackageSet{
+		OSPackageSet:    {Include: []string{"bash"}, NoWeakDeps: true},
+		BuildPackageSet: {Include: []string{"rpm"}},
+	}, repos, "platform:f33", "x86_64")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]DepsolveResult{
+		OSPackageSet: {
+			Packages:  []PackageSpec{{Name: "bash", Version: "5.0", Release: "1", Arch: "x86_64", Repo: "fedora", Checksum: "sha256:02", CheckGPG: true}},
+			Checksums: map[string]string{"0": "sha256:01"},
+			Mirrors:   map[string]string{"fedora": "http://mirror.example.com/fedora"},
+		},
+		BuildPackageSet: {
+			Packages:  []PackageSpec{{Name: "rpm", Version: "4.16", Release: "1", Arch: "x86_64", Repo: "fedora", Checksum: "sha256:03", CheckGPG: true}},
+			Checksums: map[string]string{"0": "sha256:01"},
+		},
+	}, results)
+
+	_, err = rpm.DepsolvePackageSets(context.Background(), map[string]PackageSet{
+		OSPackageSet:    {Include: []string{"bash"}, NoWeakDeps: true},
+		BuildPackageSet: {Include: []string{"missing"}},
+	}, repos, "platform:f33", "x86_64")
+	var setErr *PackageSetError
+	require.True(t, errors.As(err, &setErr))
+	assert.Equal(t, BuildPackageSet, setErr.Name)
+	assert.EqualError(t, err, "DNF error occured: MarkingErrors: no package matches missing")
+}
+
+func TestServiceListMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socketPath := serveDNFJSON(t, dir, func(w http.ResponseWriter, r *http.Request) {
+		var call struct {
+			Command string `json:"command"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&call))
+		switch call.Command {
+		case "groups":
+			_, _ = w.Write([]byte(`{"groups": [
+				{"id": "core", "name": "Core", "description": "Smallest possible installation", "user_visible": false},
+				{"id": "server-product-environment", "name": "Fedora Server Edition", "environment": true, "user_visible": true}
+			]}`))
+		case "modules":
+			_, _ = w.Write([]byte(`{"modules": [
+				{"name": "nodejs", "stream": "14", "summary": "Javascript runtime", "profiles": ["default", "minimal"], "default": true}
+			]}`))
+		default:
+			t.Errorf("unexpected command %s", call.Command)
+		}
+	})
+
+	rpm := NewRPMMDService(dir, filepath.Join(dir, "non-existing"), socketPath)
+	repos := []RepoConfig{{Name: "fedora", BaseURL: "http://example.com/fedora"}}
+
+	groups, err := rpm.ListPackageGroups(context.Background(), repos, "platform:f33", "x86_64")
+	require.NoError(t, err)
+	assert.Equal(t, PackageGroups{
+		{ID: "core", Name: "Core", Description: "Smallest possible installation"},
+		{ID: "server-product-environment", Name: "Fedora Server Edition", Environment: true, UserVisible: true},
+	}, groups)
+
+	group, ok := groups.Find("Fedora Server Edition")
+	assert.True(t, ok)
+	assert.Equal(t, "server-product-environment", group.ID)
+	_, ok = groups.Find("missing")
+	assert.False(t, ok)
+
+	streams, err := rpm.ListModuleStreams(context.Background(), repos, "platform:f33", "x86_64")
+	require.NoError(t, err)
+	assert.Equal(t, []ModuleStream{
+		{Name: "nodejs", Stream: "14", Summary: "Javascript runtime", Profiles: []string{"default", "minimal"}, Default: true},
+	}, streams)
+}
+
+func TestServiceDepsolvePinned(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmmd-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socketPath := serveDNFJSON(t, dir, func(w http.ResponseWriter, r *http.Request) {
+		var call struct {
+			Arguments struct {
+				PinnedSpecs []string `json:"pinned-specs"`
+			} `json:"arguments"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&call))
+		assert.Equal(t, []string{"bash-5.0-1.x86_64"}, call.Arguments.PinnedSpecs)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"kind":"PinnedPackagesUnavailable","reason":"pinned packages are not available: bash-5.0-1.x86_64","details":{"missing_specs":["bash-5.0-1.x86_64"],"culprits":["bash-5.0-1.x86_64"]}}`))
+	})
+
+	rpm := NewRPMMDService(dir, filepath.Join(dir, "non-existing"), socketPath)
+	repos := []RepoConfig{{Name: "fedora", BaseURL: "http://example.com/fedora"}}
+
+	_, err = rpm.DepsolvePackageSet(context.Background(), PackageSet{Include: []string{"@core"}, Pinned: []string{"bash-5.0-1.x86_64"}}, repos, "platform:f33", "x86_64")
+	var dnfErr *DNFError
+	require.True(t, errors.As(err, &dnfErr))
+	assert.Equal(t, "PinnedPackagesUnavailable", dnfErr.Kind)
+	assert.Equal(t, []string{"bash-5.0-1.x86_64"}, dnfErr.Details.MissingSpecs)
+}
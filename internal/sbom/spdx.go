@@ -0,0 +1,180 @@
+// Package sbom creates software bills of materials of images from their
+// depsolved packages, in the JSON format of SPDX 2.2.
+package sbom
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/osbuild/osbuild-composer/internal/rpmmd"
+)
+
+const noAssertion = "NOASSERTION"
+
+// Document is an SPDX document describing the packages of an image
+type Document struct {
+	SPDXVersion       string         `json:"spdxVersion"`
+	DataLicense       string         `json:"dataLicense"`
+	SPDXID            string         `json:"SPDXID"`
+	Name              string         `json:"name"`
+	DocumentNamespace string         `json:"documentNamespace"`
+	CreationInfo      CreationInfo   `json:"creationInfo"`
+	Packages          []Package      `json:"packages"`
+	Relationships     []Relationship `json:"relationships"`
+}
+
+type CreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type Package struct {
+	SPDXID           string        `json:"SPDXID"`
+	Name             string        `json:"name"`
+	VersionInfo      string        `json:"versionInfo"`
+	DownloadLocation string        `json:"downloadLocation"`
+	FilesAnalyzed    bool          `json:"filesAnalyzed"`
+	Checksums        []Checksum    `json:"checksums,omitempty"`
+	SourceInfo       string        `json:"sourceInfo,omitempty"`
+	LicenseConcluded string        `json:"licenseConcluded"`
+	LicenseDeclared  string        `json:"licenseDeclared"`
+	LicenseComments  string        `json:"licenseComments,omitempty"`
+	CopyrightText    string        `json:"copyrightText"`
+	Summary          string        `json:"summary,omitempty"`
+	ExternalRefs     []ExternalRef `json:"externalRefs,omitempty"`
+}
+
+type Checksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type ExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type Relationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// NewDocument returns the SBOM of the image of compose id, which contains
+// packages and was built for distro, e.g. "fedora-33". Licenses of RPMs are
+// not SPDX license expressions, which is why they are only mentioned in the
+// license comments of the packages.
+func NewDocument(id uuid.UUID, name, distro string, packages []rpmmd.PackageSpec, created time.Time) *Document {
+	doc := &Document{
+		SPDXVersion:       "SPDX-2.2",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              name,
+		DocumentNamespace: "https://osbuild.org/spdxdocs/" + url.PathEscape(name) + "-" + id.String(),
+		CreationInfo: CreationInfo{
+			Created:  created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: osbuild-composer"},
+		},
+		Packages:      []Package{},
+		Relationships: []Relationship{},
+	}
+
+	// sorted, so that the IDs of the packages do not depend on the order
+	// of the depsolver
+	sorted := make([]rpmmd.PackageSpec, len(packages))
+	copy(sorted, packages)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].GetNEVRA() < sorted[j].GetNEVRA()
+	})
+
+	for i, pkg := range sorted {
+		p := Package{
+			SPDXID:           fmt.Sprintf("SPDXRef-Package-%d", i+1),
+			Name:             pkg.Name,
+			VersionInfo:      pkg.Version + "-" + pkg.Release,
+			DownloadLocation: downloadLocation(pkg.RemoteLocation),
+			LicenseConcluded: noAssertion,
+			LicenseDeclared:  noAssertion,
+			CopyrightText:    noAssertion,
+			Summary:          pkg.Summary,
+			ExternalRefs: []ExternalRef{{
+				ReferenceCategory: "PACKAGE_MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  purl(pkg, distro),
+			}},
+		}
+		if pkg.Epoch != 0 {
+			p.VersionInfo = fmt.Sprintf("%d:%s", pkg.Epoch, p.VersionInfo)
+		}
+		if pkg.License != "" {
+			p.LicenseComments = "License of the RPM: " + pkg.License
+		}
+		if pkg.SourceRPM != "" {
+			p.SourceInfo = "built from " + pkg.SourceRPM
+		}
+		if parts := strings.SplitN(pkg.Checksum, ":", 2); len(parts) == 2 {
+			p.Checksums = []Checksum{{
+				Algorithm:     strings.ToUpper(parts[0]),
+				ChecksumValue: parts[1],
+			}}
+		}
+
+		doc.Packages = append(doc.Packages, p)
+		doc.Relationships = append(doc.Relationships, Relationship{
+			SPDXElementID:      doc.SPDXID,
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: p.SPDXID,
+		})
+	}
+
+	return doc
+}
+
+// downloadLocation returns the URL a package was downloaded from, without
+// any credentials
+func downloadLocation(location string) string {
+	u, err := url.Parse(location)
+	if err != nil || location == "" {
+		return noAssertion
+	}
+	u.User = nil
+	return u.String()
+}
+
+// purl returns the package URL of an RPM, e.g.
+// pkg:rpm/fedora/bash@5.0.17-1.fc33?arch=x86_64&distro=fedora-33
+func purl(pkg rpmmd.PackageSpec, distro string) string {
+	qualifiers := url.Values{}
+	if pkg.Arch != "" {
+		qualifiers.Set("arch", pkg.Arch)
+	}
+	if pkg.Epoch != 0 {
+		qualifiers.Set("epoch", fmt.Sprint(pkg.Epoch))
+	}
+
+	namespace := ""
+	if distro != "" {
+		qualifiers.Set("distro", distro)
+		namespace = distro
+		if i := strings.LastIndex(distro, "-"); i > 0 {
+			namespace = distro[:i]
+		}
+		// the namespace of RHEL in package URLs is the vendor
+		if namespace == "rhel" {
+			namespace = "redhat"
+		}
+		namespace += "/"
+	}
+
+	p := fmt.Sprintf("pkg:rpm/%s%s@%s-%s", namespace, url.PathEscape(pkg.Name), url.PathEscape(pkg.Version), url.PathEscape(pkg.Release))
+	if len(qualifiers) > 0 {
+		p += "?" + qualifiers.Encode()
+	}
+	return p
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/osbuild/osbuild-composer/internal/blueprint"
 	"github.com/osbuild/osbuild-composer/internal/common"
 	"github.com/osbuild/osbuild-composer/internal/distro"
+	"github.com/osbuild/osbuild-composer/internal/rpmmd"
 	"github.com/osbuild/osbuild-composer/internal/target"
 )
 
@@ -20,9 +21,12 @@ func (ste *StateTransitionError) Error() string {
 
 // ImageBuild represents a single image build inside a compose
 type ImageBuild struct {
-	ID          int
-	ImageType   distro.ImageType
-	Manifest    distro.Manifest
+	ID        int
+	ImageType distro.ImageType
+	Manifest  distro.Manifest
+	// Packages are the depsolved packages of the image, kept for reporting
+	// their licenses and sources
+	Packages    []rpmmd.PackageSpec
 	Targets     []*target.Target
 	JobCreated  time.Time
 	JobStarted  time.Time
@@ -48,6 +52,7 @@ func (ib *ImageBuild) DeepCopy() ImageBuild {
 		QueueStatus: ib.QueueStatus,
 		ImageType:   ib.ImageType,
 		Manifest:    ib.Manifest,
+		Packages:    ib.Packages,
 		Targets:     newTargets,
 		JobCreated:  ib.JobCreated,
 		JobStarted:  ib.JobStarted,
@@ -74,6 +79,8 @@ func (ib *ImageBuild) GetLocalTargetOptions() *target.LocalTargetOptions {
 type Compose struct {
 	Blueprint  *blueprint.Blueprint
 	ImageBuild ImageBuild
+	// Pinned composes are never deleted by the retention policy
+	Pinned bool
 }
 
 // DeepCopy creates a copy of the Compose structure
@@ -86,5 +93,6 @@ func (c *Compose) DeepCopy() Compose {
 	return Compose{
 		Blueprint:  newBpPtr,
 		ImageBuild: c.ImageBuild.DeepCopy(),
+		Pinned:     c.Pinned,
 	}
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/osbuild/osbuild-composer/internal/common"
 	"github.com/osbuild/osbuild-composer/internal/distro"
 	"github.com/osbuild/osbuild-composer/internal/distro/fedoratest"
+	"github.com/osbuild/osbuild-composer/internal/rpmmd"
 	"github.com/osbuild/osbuild-composer/internal/target"
 )
 
@@ -92,6 +93,7 @@ func FixtureBase() *Store {
 				QueueStatus: common.IBFinished,
 				ImageType:   imgType,
 				Manifest:    manifest,
+				Packages:    []rpmmd.PackageSpec{{Name: "bash", Version: "5.0", Release: "1", Arch: "x86_64", License: "GPLv3+", SourceRPM: "bash-5.0-1.src.rpm"}},
 				Targets:     []*target.Target{localTarget, awsTarget},
 				JobCreated:  date,
 				JobStarted:  date,
@@ -142,12 +144,11 @@ func FixtureFinished() *Store {
 		ImageName: "awsimage",
 		Created:   date,
 		Status:    common.IBWaiting,
+		// The credentials are not stored
 		Options: &target.AWSTargetOptions{
-			Region:          "frankfurt",
-			AccessKeyID:     "accesskey",
-			SecretAccessKey: "secretkey",
-			Bucket:          "clay",
-			Key:             "imagekey",
+			Region: "frankfurt",
+			Bucket: "clay",
+			Key:    "imagekey",
 		},
 	}
 
@@ -10,6 +10,7 @@ import (
 	"github.com/osbuild/osbuild-composer/internal/blueprint"
 	"github.com/osbuild/osbuild-composer/internal/common"
 	"github.com/osbuild/osbuild-composer/internal/distro"
+	"github.com/osbuild/osbuild-composer/internal/rpmmd"
 	"github.com/osbuild/osbuild-composer/internal/target"
 )
 
@@ -20,6 +21,7 @@ type storeV0 struct {
 	Sources    sourcesV0    `json:"sources"`
 	Changes    changesV0    `json:"changes"`
 	Commits    commitsV0    `json:"commits"`
+	Lockfiles  lockfilesV0  `json:"lockfiles,omitempty"`
 }
 
 type blueprintsV0 map[string]blueprint.Blueprint
@@ -31,21 +33,23 @@ type workspaceV0 map[string]blueprint.Blueprint
 type composeV0 struct {
 	Blueprint   *blueprint.Blueprint `json:"blueprint"`
 	ImageBuilds []imageBuildV0       `json:"image_builds"`
+	Pinned      bool                 `json:"pinned,omitempty"`
 }
 
 type composesV0 map[uuid.UUID]composeV0
 
 // ImageBuild represents a single image build inside a compose
 type imageBuildV0 struct {
-	ID          int              `json:"id"`
-	ImageType   string           `json:"image_type"`
-	Manifest    distro.Manifest  `json:"manifest"`
-	Targets     []*target.Target `json:"targets"`
-	JobCreated  time.Time        `json:"job_created"`
-	JobStarted  time.Time        `json:"job_started"`
-	JobFinished time.Time        `json:"job_finished"`
-	Size        uint64           `json:"size"`
-	JobID       uuid.UUID        `json:"jobid,omitempty"`
+	ID          int                 `json:"id"`
+	ImageType   string              `json:"image_type"`
+	Manifest    distro.Manifest     `json:"manifest"`
+	Packages    []rpmmd.PackageSpec `json:"packages,omitempty"`
+	Targets     []*target.Target    `json:"targets"`
+	JobCreated  time.Time           `json:"job_created"`
+	JobStarted  time.Time           `json:"job_started"`
+	JobFinished time.Time           `json:"job_finished"`
+	Size        uint64              `json:"size"`
+	JobID       uuid.UUID           `json:"jobid,omitempty"`
 
 	// Kept for backwards compatibility. Image builds which were done
 	// before the move to the job queue use this to store whether they
@@ -60,6 +64,8 @@ type sourceV0 struct {
 	CheckGPG bool   `json:"check_gpg"`
 	CheckSSL bool   `json:"check_ssl"`
 	System   bool   `json:"system"`
+	Proxy    string `json:"proxy,omitempty"`
+	Priority int    `json:"priority,omitempty"`
 }
 
 type sourcesV0 map[string]sourceV0
@@ -76,6 +82,15 @@ type changesV0 map[string]map[string]changeV0
 
 type commitsV0 map[string][]string
 
+type lockfileV0 struct {
+	BlueprintVersion string              `json:"blueprint_version"`
+	Packages         []rpmmd.PackageSpec `json:"packages"`
+	Checksums        map[string]string   `json:"checksums"`
+	Created          time.Time           `json:"created"`
+}
+
+type lockfilesV0 map[string]lockfileV0
+
 func newBlueprintsFromV0(blueprintsStruct blueprintsV0) map[string]blueprint.Blueprint {
 	blueprints := make(map[string]blueprint.Blueprint)
 	for name, blueprint := range blueprintsStruct {
@@ -129,6 +144,7 @@ func newImageBuildFromV0(imageBuildStruct imageBuildV0, arch distro.Arch) (Image
 		ID:          imageBuildStruct.ID,
 		ImageType:   imgType,
 		Manifest:    imageBuildStruct.Manifest,
+		Packages:    imageBuildStruct.Packages,
 		Targets:     imageBuildStruct.Targets,
 		JobCreated:  imageBuildStruct.JobCreated,
 		JobStarted:  imageBuildStruct.JobStarted,
@@ -151,6 +167,7 @@ func newComposeFromV0(composeStruct composeV0, arch distro.Arch) (Compose, error
 	return Compose{
 		Blueprint:  &bp,
 		ImageBuild: ib,
+		Pinned:     composeStruct.Pinned,
 	}, nil
 }
 
@@ -225,6 +242,15 @@ func newCommitsFromV0(commitsMapStruct commitsV0, changesMapStruct changesV0) ma
 	return commitsMap
 }
 
+func newLockfilesFromV0(lockfilesStruct lockfilesV0) map[string]Lockfile {
+	lockfiles := make(map[string]Lockfile)
+	for name, lockfile := range lockfilesStruct {
+		l := Lockfile(lockfile)
+		lockfiles[name] = l.DeepCopy()
+	}
+	return lockfiles
+}
+
 func newStoreFromV0(storeStruct storeV0, arch distro.Arch, log *log.Logger) *Store {
 	return &Store{
 		blueprints:        newBlueprintsFromV0(storeStruct.Blueprints),
@@ -233,6 +259,7 @@ func newStoreFromV0(storeStruct storeV0, arch distro.Arch, log *log.Logger) *Sto
 		sources:           newSourceConfigsFromV0(storeStruct.Sources),
 		blueprintsChanges: newChangesFromV0(storeStruct.Changes),
 		blueprintsCommits: newCommitsFromV0(storeStruct.Commits, storeStruct.Changes),
+		lockfiles:         newLockfilesFromV0(storeStruct.Lockfiles),
 	}
 }
 
@@ -254,6 +281,12 @@ func newWorkspaceV0(workspace map[string]blueprint.Blueprint) workspaceV0 {
 
 func newComposeV0(compose Compose) composeV0 {
 	bp := compose.Blueprint.DeepCopy()
+	// The job of the compose carries the credentials of its targets, so
+	// they are not written to the state
+	var targets []*target.Target
+	for _, t := range compose.ImageBuild.Targets {
+		targets = append(targets, t.WithoutSecrets())
+	}
 	return composeV0{
 		Blueprint: &bp,
 		ImageBuilds: []imageBuildV0{
@@ -261,7 +294,8 @@ func newComposeV0(compose Compose) composeV0 {
 				ID:          compose.ImageBuild.ID,
 				ImageType:   imageTypeToCompatString(compose.ImageBuild.ImageType),
 				Manifest:    compose.ImageBuild.Manifest,
-				Targets:     compose.ImageBuild.Targets,
+				Packages:    compose.ImageBuild.Packages,
+				Targets:     targets,
 				JobCreated:  compose.ImageBuild.JobCreated,
 				JobStarted:  compose.ImageBuild.JobStarted,
 				JobFinished: compose.ImageBuild.JobFinished,
@@ -270,6 +304,7 @@ func newComposeV0(compose Compose) composeV0 {
 				QueueStatus: compose.ImageBuild.QueueStatus,
 			},
 		},
+		Pinned: compose.Pinned,
 	}
 }
 
@@ -314,6 +349,15 @@ func newCommitsV0(commits map[string][]string) commitsV0 {
 	return commitsStruct
 }
 
+func newLockfilesV0(lockfiles map[string]Lockfile) lockfilesV0 {
+	lockfilesStruct := make(lockfilesV0)
+	for name, lockfile := range lockfiles {
+		l := lockfile.DeepCopy()
+		lockfilesStruct[name] = lockfileV0(l)
+	}
+	return lockfilesStruct
+}
+
 func (store *Store) toStoreV0() *storeV0 {
 	return &storeV0{
 		Blueprints: newBlueprintsV0(store.blueprints),
@@ -322,6 +366,7 @@ func (store *Store) toStoreV0() *storeV0 {
 		Sources:    newSourcesV0(store.sources),
 		Changes:    newChangesV0(store.blueprintsChanges),
 		Commits:    newCommitsV0(store.blueprintsCommits),
+		Lockfiles:  newLockfilesV0(store.lockfiles),
 	}
 }
 
@@ -129,6 +129,23 @@ func TestMarshalFinished(t *testing.T) {
 	}
 }
 
+func TestMarshalWithoutSecrets(t *testing.T) {
+	store := FixtureBase()
+	data, err := json.Marshal(store.toStoreV0())
+	require.NoError(t, err)
+	require.Contains(t, string(data), "frankfurt")
+	require.NotContains(t, string(data), "secretkey")
+
+	// the composes in memory keep the credentials for their jobs
+	for _, compose := range store.composes {
+		for _, tgt := range compose.ImageBuild.Targets {
+			if options, ok := tgt.Options.(*target.AWSTargetOptions); ok {
+				require.Equal(t, "secretkey", options.SecretAccessKey)
+			}
+		}
+	}
+}
+
 func TestStore_toStoreV0(t *testing.T) {
 	type fields struct {
 		blueprints        map[string]blueprint.Blueprint
@@ -153,6 +170,7 @@ func TestStore_toStoreV0(t *testing.T) {
 				Sources:    make(sourcesV0),
 				Changes:    make(changesV0),
 				Commits:    make(commitsV0),
+				Lockfiles:  make(lockfilesV0),
 			},
 		},
 	}
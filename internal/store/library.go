@@ -0,0 +1,121 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/osbuild/osbuild-composer/internal/blueprint"
+)
+
+// libraryCommitPrefix starts the commit message of every blueprint change
+// made from the blueprint library. It is used to find the blueprints the
+// library manages.
+const libraryCommitPrefix = "Loaded from the blueprint library: "
+
+// SyncBlueprintLibrary makes the committed blueprints match the blueprint
+// TOML files in dir. New and changed files are committed, and blueprints
+// which were last committed from the library but whose file was removed are
+// deleted. Blueprints pushed over the API are left alone unless the library
+// contains a file with the same name, which always wins.
+//
+// Invalid files are skipped and reported in the returned error, which does
+// not prevent the other files from being synchronized.
+func (s *Store) SyncBlueprintLibrary(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	var problems []string
+	seen := make(map[string]string)
+	for _, file := range files {
+		bp, versioned, err := readLibraryBlueprint(file)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", file, err))
+			continue
+		}
+		if other, ok := seen[bp.Name]; ok {
+			problems = append(problems, fmt.Sprintf("%s: blueprint %s is already defined in %s", file, bp.Name, other))
+			continue
+		}
+		seen[bp.Name] = file
+
+		committed := s.GetBlueprintCommitted(bp.Name)
+		if committed != nil {
+			// Unversioned files follow the version of the store
+			if !versioned {
+				bp.Version = committed.Version
+			}
+			if sameBlueprint(bp, *committed) {
+				continue
+			}
+		}
+
+		err = s.PushBlueprint(bp, libraryCommitPrefix+filepath.Base(file))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", file, err))
+		}
+	}
+
+	for _, name := range s.ListBlueprints() {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		changes := s.GetBlueprintChanges(name)
+		if len(changes) == 0 || !strings.HasPrefix(changes[len(changes)-1].Message, libraryCommitPrefix) {
+			continue
+		}
+		err = s.DeleteBlueprint(name)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// readLibraryBlueprint reads and validates a blueprint TOML file. It also
+// returns whether the file sets a version.
+func readLibraryBlueprint(file string) (blueprint.Blueprint, bool, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return blueprint.Blueprint{}, false, err
+	}
+	bp, unknown, err := blueprint.DecodeTOML(data)
+	if err != nil {
+		return blueprint.Blueprint{}, false, err
+	}
+	if len(unknown) > 0 {
+		return blueprint.Blueprint{}, false, unknown
+	}
+	versioned := bp.Version != ""
+	err = bp.Initialize()
+	if err != nil {
+		return blueprint.Blueprint{}, false, err
+	}
+	return bp, versioned, nil
+}
+
+// sameBlueprint compares blueprints by their serialization, which does not
+// distinguish between empty and missing lists
+func sameBlueprint(a, b blueprint.Blueprint) bool {
+	dataA, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	dataB, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(dataA, dataB)
+}
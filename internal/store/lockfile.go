@@ -0,0 +1,71 @@
+package store
+
+import (
+	"time"
+
+	"github.com/osbuild/osbuild-composer/internal/rpmmd"
+)
+
+// A Lockfile records the exact packages a version of a blueprint resolved to
+// when it was frozen, together with the checksums of the repositories at
+// that time. Composes of that version reuse these packages.
+type Lockfile struct {
+	BlueprintVersion string
+	Packages         []rpmmd.PackageSpec
+	Checksums        map[string]string
+	Created          time.Time
+}
+
+// DeepCopy creates a copy of the Lockfile structure
+func (l *Lockfile) DeepCopy() Lockfile {
+	packages := make([]rpmmd.PackageSpec, len(l.Packages))
+	copy(packages, l.Packages)
+	checksums := make(map[string]string, len(l.Checksums))
+	for repo, checksum := range l.Checksums {
+		checksums[repo] = checksum
+	}
+	return Lockfile{
+		BlueprintVersion: l.BlueprintVersion,
+		Packages:         packages,
+		Checksums:        checksums,
+		Created:          l.Created,
+	}
+}
+
+// GetLockfile returns the lockfile of the named blueprint, or nil if the
+// blueprint was not frozen
+func (s *Store) GetLockfile(name string) *Lockfile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	lockfile, ok := s.lockfiles[name]
+	if !ok {
+		return nil
+	}
+	l := lockfile.DeepCopy()
+	return &l
+}
+
+// PushLockfile stores the lockfile of the named blueprint, replacing an
+// existing one
+func (s *Store) PushLockfile(name string, lockfile Lockfile) error {
+	return s.change(func() error {
+		if _, ok := s.blueprints[name]; !ok {
+			return &NotFoundError{"unknown blueprint: " + name}
+		}
+		s.lockfiles[name] = lockfile.DeepCopy()
+		return nil
+	})
+}
+
+// DeleteLockfile removes the lockfile of the named blueprint, so that its
+// packages are resolved again for every compose
+func (s *Store) DeleteLockfile(name string) error {
+	return s.change(func() error {
+		if _, ok := s.lockfiles[name]; !ok {
+			return &NotFoundError{"blueprint is not frozen: " + name}
+		}
+		delete(s.lockfiles, name)
+		return nil
+	})
+}
@@ -36,6 +36,7 @@ type Store struct {
 	sources           map[string]SourceConfig
 	blueprintsChanges map[string]map[string]blueprint.Change
 	blueprintsCommits map[string][]string
+	lockfiles         map[string]Lockfile
 
 	mu       sync.RWMutex // protects all fields
 	stateDir *string
@@ -49,6 +50,8 @@ type SourceConfig struct {
 	CheckGPG bool   `json:"check_gpg" toml:"check_gpg"`
 	CheckSSL bool   `json:"check_ssl" toml:"check_ssl"`
 	System   bool   `json:"system" toml:"system"`
+	Proxy    string `json:"proxy,omitempty" toml:"proxy,omitempty"`
+	Priority int    `json:"priority,omitempty" toml:"priority,omitempty"`
 }
 
 type NotFoundError struct {
@@ -190,6 +193,27 @@ func (s *Store) GetBlueprintChanges(name string) []blueprint.Change {
 	return changes
 }
 
+// GetBlueprintVersion returns the most recent commit of the blueprint with
+// the given version. If the blueprint or version do not exist then an error
+// is returned
+func (s *Store) GetBlueprintVersion(name string, version string) (*blueprint.Blueprint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	commits, ok := s.blueprintsCommits[name]
+	if !ok {
+		return nil, errors.New("Unknown blueprint")
+	}
+	for i := len(commits) - 1; i >= 0; i-- {
+		change := s.blueprintsChanges[name][commits[i]]
+		if change.Blueprint.Version == version {
+			bp := change.Blueprint
+			return &bp, nil
+		}
+	}
+	return nil, errors.New("Unknown version")
+}
+
 func (s *Store) PushBlueprint(bp blueprint.Blueprint, commitMsg string) error {
 	return s.change(func() error {
 		commit, err := randomSHA1String()
@@ -203,6 +227,14 @@ func (s *Store) PushBlueprint(bp blueprint.Blueprint, commitMsg string) error {
 			return err
 		}
 
+		// Bump the version before recording the change, so that the change
+		// has the version the blueprint is stored with
+		if old, ok := s.blueprints[bp.Name]; ok {
+			if bp.Version == "" || bp.Version == old.Version {
+				bp.BumpVersion(old.Version)
+			}
+		}
+
 		timestamp := time.Now().Format("2006-01-02T15:04:05Z")
 		change := blueprint.Change{
 			Commit:    commit,
@@ -219,11 +251,6 @@ func (s *Store) PushBlueprint(bp blueprint.Blueprint, commitMsg string) error {
 		// Keep track of the order of the commits
 		s.blueprintsCommits[bp.Name] = append(s.blueprintsCommits[bp.Name], commit)
 
-		if old, ok := s.blueprints[bp.Name]; ok {
-			if bp.Version == "" || bp.Version == old.Version {
-				bp.BumpVersion(old.Version)
-			}
-		}
 		s.blueprints[bp.Name] = bp
 		return nil
 	})
@@ -252,6 +279,7 @@ func (s *Store) DeleteBlueprint(name string) error {
 			return fmt.Errorf("Unknown blueprint: %s", name)
 		}
 		delete(s.blueprints, name)
+		delete(s.lockfiles, name)
 		return nil
 	})
 }
@@ -331,7 +359,7 @@ func (s *Store) GetAllComposes() map[uuid.UUID]Compose {
 	return composes
 }
 
-func (s *Store) PushCompose(composeID uuid.UUID, manifest distro.Manifest, imageType distro.ImageType, bp *blueprint.Blueprint, size uint64, targets []*target.Target, jobId uuid.UUID) error {
+func (s *Store) PushCompose(composeID uuid.UUID, manifest distro.Manifest, packages []rpmmd.PackageSpec, imageType distro.ImageType, bp *blueprint.Blueprint, size uint64, targets []*target.Target, jobId uuid.UUID) error {
 	if _, exists := s.GetCompose(composeID); exists {
 		panic("a compose with this id already exists")
 	}
@@ -346,6 +374,7 @@ func (s *Store) PushCompose(composeID uuid.UUID, manifest distro.Manifest, image
 			Blueprint: bp,
 			ImageBuild: ImageBuild{
 				Manifest:   manifest,
+				Packages:   packages,
 				ImageType:  imageType,
 				Targets:    targets,
 				JobCreated: time.Now(),
@@ -361,7 +390,7 @@ func (s *Store) PushCompose(composeID uuid.UUID, manifest distro.Manifest, image
 // PushTestCompose is used for testing
 // Set testSuccess to create a fake successful compose, otherwise it will create a failed compose
 // It does not actually run a compose job
-func (s *Store) PushTestCompose(composeID uuid.UUID, manifest distro.Manifest, imageType distro.ImageType, bp *blueprint.Blueprint, size uint64, targets []*target.Target, testSuccess bool) error {
+func (s *Store) PushTestCompose(composeID uuid.UUID, manifest distro.Manifest, packages []rpmmd.PackageSpec, imageType distro.ImageType, bp *blueprint.Blueprint, size uint64, targets []*target.Target, testSuccess bool) error {
 	if targets == nil {
 		targets = []*target.Target{}
 	}
@@ -380,6 +409,7 @@ func (s *Store) PushTestCompose(composeID uuid.UUID, manifest distro.Manifest, i
 			ImageBuild: ImageBuild{
 				QueueStatus: status,
 				Manifest:    manifest,
+				Packages:    packages,
 				ImageType:   imageType,
 				Targets:     targets,
 				JobCreated:  time.Now(),
@@ -407,6 +437,22 @@ func (s *Store) DeleteCompose(id uuid.UUID) error {
 	})
 }
 
+// SetComposePinned pins or unpins a compose. Pinned composes are never
+// deleted by the retention policy.
+func (s *Store) SetComposePinned(id uuid.UUID, pinned bool) error {
+	return s.change(func() error {
+		compose, exists := s.composes[id]
+		if !exists {
+			return &NotFoundError{}
+		}
+
+		compose.Pinned = pinned
+		s.composes[id] = compose
+
+		return nil
+	})
+}
+
 // PushSource stores a SourceConfig in store.Sources
 func (s *Store) PushSource(key string, source SourceConfig) {
 	// FIXME: handle or comment this possible error
@@ -512,6 +558,8 @@ func NewSourceConfig(repo rpmmd.RepoConfig, system bool) SourceConfig {
 		CheckGPG: repo.CheckGPG,
 		CheckSSL: !repo.IgnoreSSL,
 		System:   system,
+		Proxy:    repo.Proxy,
+		Priority: repo.Priority,
 	}
 
 	if repo.BaseURL != "" {
@@ -534,6 +582,8 @@ func (s *SourceConfig) RepoConfig(name string) rpmmd.RepoConfig {
 	repo.Name = name
 	repo.IgnoreSSL = !s.CheckSSL
 	repo.CheckGPG = s.CheckGPG
+	repo.Proxy = s.Proxy
+	repo.Priority = s.Priority
 
 	if s.Type == "yum-baseurl" {
 		repo.BaseURL = s.URL
@@ -3,6 +3,7 @@ package store
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -203,6 +204,27 @@ func (suite *storeTest) TestGetBlueprintChange() {
 	suite.EqualError(err, "Unknown commit")
 }
 
+func (suite *storeTest) TestGetBlueprintVersion() {
+	suite.NoError(suite.myStore.PushBlueprint(suite.myBP, "first commit"))
+	suite.NoError(suite.myStore.PushBlueprint(suite.myBP, "second commit"))
+
+	first, err := suite.myStore.GetBlueprintVersion("testBP", suite.myBP.Version)
+	suite.NoError(err)
+	suite.Equal(suite.myBP, *first)
+
+	second, err := suite.myStore.GetBlueprintVersion("testBP", "0.0.2")
+	suite.NoError(err)
+	suite.Equal("0.0.2", second.Version)
+
+	changes := suite.myStore.GetBlueprintChanges("testBP")
+	suite.Equal("0.0.2", changes[1].Blueprint.Version)
+
+	_, err = suite.myStore.GetBlueprintVersion("testBP", "1.0.0")
+	suite.EqualError(err, "Unknown version")
+	_, err = suite.myStore.GetBlueprintVersion("Non_existing_BP", "0.0.1")
+	suite.EqualError(err, "Unknown blueprint")
+}
+
 func (suite *storeTest) TestTagBlueprint() {
 	Commit := make(map[string]blueprint.Change)
 	Commit[suite.CommitHash] = suite.myChange
@@ -242,12 +264,69 @@ func (suite *storeTest) TestDeleteBlueprintFromWorkspace() {
 	suite.EqualError(suite.myStore.DeleteBlueprintFromWorkspace("WIPtestBP"), "Unknown blueprint: WIPtestBP")
 }
 
+func (suite *storeTest) TestLockfile() {
+	lockfile := Lockfile{
+		BlueprintVersion: "0.0.1",
+		Packages:         []rpmmd.PackageSpec{{Name: "bash", Version: "5.0.17", Release: "1.fc32", Arch: "x86_64"}},
+		Checksums:        map[string]string{"testRepo": "sha256:00"},
+	}
+	suite.Nil(suite.myStore.GetLockfile("testBP"))
+	suite.EqualError(suite.myStore.PushLockfile("testBP", lockfile), "unknown blueprint: testBP")
+
+	suite.myStore.blueprints["testBP"] = suite.myBP
+	suite.NoError(suite.myStore.PushLockfile("testBP", lockfile))
+	suite.Equal(&lockfile, suite.myStore.GetLockfile("testBP"))
+
+	suite.NoError(suite.myStore.DeleteLockfile("testBP"))
+	suite.Nil(suite.myStore.GetLockfile("testBP"))
+	suite.EqualError(suite.myStore.DeleteLockfile("testBP"), "blueprint is not frozen: testBP")
+
+	// deleting a blueprint deletes its lockfile
+	suite.NoError(suite.myStore.PushLockfile("testBP", lockfile))
+	suite.NoError(suite.myStore.DeleteBlueprint("testBP"))
+	suite.Nil(suite.myStore.GetLockfile("testBP"))
+}
+
+func (suite *storeTest) TestSyncBlueprintLibrary() {
+	library := filepath.Join(suite.dir, "library")
+	suite.NoError(os.Mkdir(library, 0755))
+	writeFile := func(name, content string) {
+		suite.NoError(ioutil.WriteFile(filepath.Join(library, name), []byte(content), 0644))
+	}
+
+	suite.myStore.blueprints["api"] = blueprint.Blueprint{Name: "api", Version: "0.0.1"}
+	writeFile("web.toml", "name = \"web\"\n[[packages]]\nname = \"httpd\"\n")
+	writeFile("db.toml", "name = \"db\"\nversion = \"1.0.0\"\n")
+	writeFile("README", "not a blueprint")
+	suite.NoError(suite.myStore.SyncBlueprintLibrary(library))
+	suite.ElementsMatch([]string{"api", "db", "web"}, suite.myStore.ListBlueprints())
+	suite.Equal("0.0.0", suite.myStore.GetBlueprintCommitted("web").Version)
+	suite.Equal("1.0.0", suite.myStore.GetBlueprintCommitted("db").Version)
+
+	// unchanged files are not committed again
+	suite.NoError(suite.myStore.SyncBlueprintLibrary(library))
+	suite.Len(suite.myStore.GetBlueprintChanges("web"), 1)
+
+	// changes to unversioned files bump the version
+	writeFile("web.toml", "name = \"web\"\n[[packages]]\nname = \"nginx\"\n")
+	suite.NoError(suite.myStore.SyncBlueprintLibrary(library))
+	web := suite.myStore.GetBlueprintCommitted("web")
+	suite.Equal("0.0.1", web.Version)
+	suite.Equal("nginx", web.Packages[0].Name)
+
+	// removed files delete their blueprints, invalid files are reported
+	suite.NoError(os.Remove(filepath.Join(library, "db.toml")))
+	writeFile("broken.toml", "name = \"broken\"\nversion = \"one\"\n")
+	suite.Error(suite.myStore.SyncBlueprintLibrary(library))
+	suite.ElementsMatch([]string{"api", "web"}, suite.myStore.ListBlueprints())
+}
+
 func (suite *storeTest) TestPushCompose() {
 	testID := uuid.New()
-	err := suite.myStore.PushCompose(testID, suite.myManifest, suite.myImageType, &suite.myBP, 123, nil, uuid.New())
+	err := suite.myStore.PushCompose(testID, suite.myManifest, nil, suite.myImageType, &suite.myBP, 123, nil, uuid.New())
 	suite.NoError(err)
 	suite.Panics(func() {
-		err = suite.myStore.PushCompose(testID, suite.myManifest, suite.myImageType, &suite.myBP, 123, []*target.Target{suite.myTarget}, uuid.New())
+		err = suite.myStore.PushCompose(testID, suite.myManifest, nil, suite.myImageType, &suite.myBP, 123, []*target.Target{suite.myTarget}, uuid.New())
 	})
 	suite.NoError(err)
 	testID = uuid.New()
@@ -255,11 +334,11 @@ func (suite *storeTest) TestPushCompose() {
 
 func (suite *storeTest) TestPushTestCompose() {
 	ID := uuid.New()
-	err := suite.myStore.PushTestCompose(ID, suite.myManifest, suite.myImageType, &suite.myBP, 123, nil, true)
+	err := suite.myStore.PushTestCompose(ID, suite.myManifest, nil, suite.myImageType, &suite.myBP, 123, nil, true)
 	suite.NoError(err)
 	suite.Equal(common.ImageBuildState(2), suite.myStore.composes[ID].ImageBuild.QueueStatus)
 	ID = uuid.New()
-	err = suite.myStore.PushTestCompose(ID, suite.myManifest, suite.myImageType, &suite.myBP, 123, []*target.Target{suite.myTarget}, false)
+	err = suite.myStore.PushTestCompose(ID, suite.myManifest, nil, suite.myImageType, &suite.myBP, 123, []*target.Target{suite.myTarget}, false)
 	suite.NoError(err)
 	suite.Equal(common.ImageBuildState(3), suite.myStore.composes[ID].ImageBuild.QueueStatus)
 
@@ -283,6 +362,22 @@ func (suite *storeTest) TestDeleteCompose() {
 	suite.Error(err)
 }
 
+func (suite *storeTest) TestSetComposePinned() {
+	ID := uuid.New()
+	suite.myStore.composes = make(map[uuid.UUID]Compose)
+	compose := suite.myCompose
+	compose.ImageBuild.ImageType = suite.myImageType
+	suite.myStore.composes[ID] = compose
+	err := suite.myStore.SetComposePinned(ID, true)
+	suite.NoError(err)
+	suite.True(suite.myStore.composes[ID].Pinned)
+	err = suite.myStore.SetComposePinned(ID, false)
+	suite.NoError(err)
+	suite.False(suite.myStore.composes[ID].Pinned)
+	err = suite.myStore.SetComposePinned(uuid.New(), true)
+	suite.Error(err)
+}
+
 func (suite *storeTest) TestDeleteSourceByName() {
 	suite.myStore.sources = make(map[string]SourceConfig)
 	suite.myStore.sources["testSource"] = suite.mySourceConfig
@@ -398,6 +493,23 @@ func (suite *storeTest) TestRepoConfigMirrorlist() {
 	actualRepo := suite.mySourceConfig.RepoConfig("testSourceConfig")
 	suite.Equal(expectedRepo, actualRepo)
 }
+
+func (suite *storeTest) TestRepoConfigProxy() {
+	expectedRepo := rpmmd.RepoConfig{Name: "testSourceConfig", BaseURL: "testURL", IgnoreSSL: true, Proxy: "http://proxy.example.com:3128"}
+	source := SourceConfig{Name: "testSourceConfig", Type: "yum-baseurl", URL: "testURL", Proxy: "http://proxy.example.com:3128"}
+	actualRepo := source.RepoConfig("testSourceConfig")
+	suite.Equal(expectedRepo, actualRepo)
+	suite.Equal(source, NewSourceConfig(actualRepo, false))
+}
+
+func (suite *storeTest) TestRepoConfigPriority() {
+	expectedRepo := rpmmd.RepoConfig{Name: "testSourceConfig", BaseURL: "testURL", IgnoreSSL: true, Priority: 10}
+	source := SourceConfig{Name: "testSourceConfig", Type: "yum-baseurl", URL: "testURL", Priority: 10}
+	actualRepo := source.RepoConfig("testSourceConfig")
+	suite.Equal(expectedRepo, actualRepo)
+	suite.Equal(source, NewSourceConfig(actualRepo, false))
+}
+
 func TestStore(t *testing.T) {
 	suite.Run(t, new(storeTest))
 }
@@ -8,6 +8,17 @@ type AWSTargetOptions struct {
 	Bucket            string   `json:"bucket"`
 	Key               string   `json:"key"`
 	ShareWithAccounts []string `json:"shareWithAccounts"`
+	// CopyToRegions are the regions the registered AMI is copied to
+	CopyToRegions []string `json:"copyToRegions,omitempty"`
+	// UploadOnly keeps the image in the bucket instead of registering it as
+	// an AMI
+	UploadOnly bool `json:"uploadOnly,omitempty"`
+	// Endpoint is the URL of an S3-compatible object store, like MinIO or
+	// Ceph, which the image is uploaded to instead of Amazon S3. Such
+	// images are only uploaded.
+	Endpoint       string `json:"endpoint,omitempty"`
+	ForcePathStyle bool   `json:"forcePathStyle,omitempty"`
+	Insecure       bool   `json:"insecure,omitempty"`
 }
 
 func (AWSTargetOptions) isTargetOptions() {}
@@ -15,3 +26,23 @@ func (AWSTargetOptions) isTargetOptions() {}
 func NewAWSTarget(options *AWSTargetOptions) *Target {
 	return newTarget("org.osbuild.aws", options)
 }
+
+// AWSTargetResultOptions identify the AMI registered for an image uploaded
+// to an AWS target, or the URL of the image if it is only uploaded
+type AWSTargetResultOptions struct {
+	Ami    string `json:"ami"`
+	Region string `json:"region"`
+	// ARN of the AMI, which depends on the partition of the region
+	ARN string `json:"arn,omitempty"`
+	URL string `json:"url,omitempty"`
+	// DownloadURL is a presigned URL of an image which is only uploaded
+	DownloadURL string `json:"download_url,omitempty"`
+	// Copies are the AMIs copied to other regions
+	Copies []AWSTargetResultOptions `json:"copies,omitempty"`
+}
+
+func (AWSTargetResultOptions) isTargetResultOptions() {}
+
+func NewAWSTargetResult(options *AWSTargetResultOptions) *TargetResult {
+	return newTargetResult("org.osbuild.aws", options)
+}
@@ -5,6 +5,34 @@ type AzureTargetOptions struct {
 	StorageAccount   string `json:"storageAccount"`
 	StorageAccessKey string `json:"storageAccessKey"`
 	Container        string `json:"container"`
+
+	// Cloud is the name of the Azure cloud, e.g. AzureUSGovernmentCloud or
+	// AzureChinaCloud, or empty for the public cloud. The endpoints which
+	// are set replace those of the cloud.
+	Cloud                   string `json:"cloud,omitempty"`
+	ActiveDirectoryEndpoint string `json:"activeDirectoryEndpoint,omitempty"`
+	ResourceManagerEndpoint string `json:"resourceManagerEndpoint,omitempty"`
+	StorageEndpointSuffix   string `json:"storageEndpointSuffix,omitempty"`
+
+	// A managed image is registered from the uploaded VHD when a resource
+	// group is set. It needs the credentials of a service principal.
+	SubscriptionID string `json:"subscriptionID,omitempty"`
+	TenantID       string `json:"tenantID,omitempty"`
+	ClientID       string `json:"clientID,omitempty"`
+	ClientSecret   string `json:"clientSecret,omitempty"`
+	ResourceGroup  string `json:"resourceGroup,omitempty"`
+	Location       string `json:"location,omitempty"`
+	// ShareWith are the object IDs of principals which get the Reader role
+	// on the managed image
+	ShareWith []string `json:"shareWith,omitempty"`
+
+	// The managed image is published as a version of an image definition
+	// in a Shared Image Gallery of the resource group when Gallery is set.
+	// The version is replicated to the TargetRegions besides Location.
+	Gallery                string   `json:"gallery,omitempty"`
+	GalleryImageDefinition string   `json:"galleryImageDefinition,omitempty"`
+	GalleryImageVersion    string   `json:"galleryImageVersion,omitempty"`
+	TargetRegions          []string `json:"targetRegions,omitempty"`
 }
 
 func (AzureTargetOptions) isTargetOptions() {}
@@ -12,3 +40,21 @@ func (AzureTargetOptions) isTargetOptions() {}
 func NewAzureTarget(options *AzureTargetOptions) *Target {
 	return newTarget("org.osbuild.azure", options)
 }
+
+// AzureTargetResultOptions identify the managed image registered for an
+// image uploaded to an Azure target
+type AzureTargetResultOptions struct {
+	ImageID string `json:"image_id,omitempty"`
+	// GalleryImageVersionID is the resource ID of the version in the Shared
+	// Image Gallery, if the image was published to one
+	GalleryImageVersionID string `json:"gallery_image_version_id,omitempty"`
+	// DownloadURL is the URL of the uploaded VHD with a shared access
+	// signature
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+func (AzureTargetResultOptions) isTargetResultOptions() {}
+
+func NewAzureTargetResult(options *AzureTargetResultOptions) *TargetResult {
+	return newTargetResult("org.osbuild.azure", options)
+}
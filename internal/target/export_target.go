@@ -0,0 +1,26 @@
+package target
+
+type ExportTargetOptions struct {
+	Filename string `json:"filename"`
+	// ExportFilename is the name of the copy of the artifact in the export
+	// directory configured on the worker. It must not contain directories.
+	ExportFilename string `json:"export_filename"`
+}
+
+func (ExportTargetOptions) isTargetOptions() {}
+
+func NewExportTarget(options *ExportTargetOptions) *Target {
+	return newTarget("org.osbuild.export", options)
+}
+
+// ExportTargetResultOptions contain the path the artifact of an export target
+// was copied to on the worker
+type ExportTargetResultOptions struct {
+	Path string `json:"path"`
+}
+
+func (ExportTargetResultOptions) isTargetResultOptions() {}
+
+func NewExportTargetResult(options *ExportTargetResultOptions) *TargetResult {
+	return newTargetResult("org.osbuild.export", options)
+}
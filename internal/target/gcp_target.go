@@ -0,0 +1,45 @@
+package target
+
+type GCPTargetOptions struct {
+	Filename string `json:"filename"`
+	// Credentials is the JSON key of a service account. Project defaults
+	// to the project of the service account.
+	Credentials []byte `json:"credentials,omitempty"`
+	Project     string `json:"project,omitempty"`
+	Bucket      string `json:"bucket"`
+	Object      string `json:"object"`
+	// Family and Labels are set on the imported image
+	Family string            `json:"family,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// GuestOSFeatures, such as UEFI_COMPATIBLE, and the URLs of Licenses are
+	// set on the imported image as well
+	GuestOSFeatures []string `json:"guest_os_features,omitempty"`
+	Licenses        []string `json:"licenses,omitempty"`
+	// ShieldedVM marks the image as booting with UEFI, which shielded VMs
+	// need, and ConfidentialVM additionally as supporting AMD SEV
+	ShieldedVM     bool `json:"shielded_vm,omitempty"`
+	ConfidentialVM bool `json:"confidential_vm,omitempty"`
+	// ShareWith are the IAM members, such as user:alice@example.com, which
+	// are allowed to use the image
+	ShareWith []string `json:"share_with,omitempty"`
+}
+
+func (GCPTargetOptions) isTargetOptions() {}
+
+func NewGCPTarget(options *GCPTargetOptions) *Target {
+	return newTarget("org.osbuild.gcp", options)
+}
+
+// GCPTargetResultOptions identify the Compute Engine image imported for an
+// image uploaded to a GCP target
+type GCPTargetResultOptions struct {
+	ImageName string `json:"image_name"`
+	Project   string `json:"project"`
+	SelfLink  string `json:"self_link"`
+}
+
+func (GCPTargetResultOptions) isTargetResultOptions() {}
+
+func NewGCPTargetResult(options *GCPTargetResultOptions) *TargetResult {
+	return newTargetResult("org.osbuild.gcp", options)
+}
@@ -0,0 +1,20 @@
+package target
+
+// HookTargetOptions describe a hook, which runs after all other targets of a
+// job succeeded. The metadata of the artifact is sent to the URL, passed to
+// the command, or both.
+type HookTargetOptions struct {
+	Filename string `json:"filename"`
+	URL      string `json:"url,omitempty"`
+	// Headers are sent to the URL, e.g. for authentication
+	Headers map[string]string `json:"headers,omitempty"`
+	// Command is the name of a hook command configured on the worker.
+	// Commands are never taken from requests.
+	Command string `json:"command,omitempty"`
+}
+
+func (HookTargetOptions) isTargetOptions() {}
+
+func NewHookTarget(options *HookTargetOptions) *Target {
+	return newTarget("org.osbuild.hook", options)
+}
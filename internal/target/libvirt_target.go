@@ -0,0 +1,41 @@
+package target
+
+type LibvirtTargetOptions struct {
+	Filename string `json:"filename"`
+	// Host is the name of a libvirt host configured on the worker. Hosts
+	// are never taken from requests.
+	Host string `json:"host"`
+	// The image is imported into a new volume of the storage pool
+	Pool   string `json:"pool"`
+	Volume string `json:"volume"`
+	// A domain booting the volume is defined unless Domain is empty. Its XML
+	// is made from the domain template configured on the worker with the
+	// name DomainTemplate, or a default template if it is empty.
+	Domain         string `json:"domain,omitempty"`
+	DomainTemplate string `json:"domain_template,omitempty"`
+	// Memory of the domain in MiB
+	Memory  uint64 `json:"memory,omitempty"`
+	VCPUs   int    `json:"vcpus,omitempty"`
+	Network string `json:"network,omitempty"`
+	// Start starts the domain once it is defined
+	Start bool `json:"start,omitempty"`
+}
+
+func (LibvirtTargetOptions) isTargetOptions() {}
+
+func NewLibvirtTarget(options *LibvirtTargetOptions) *Target {
+	return newTarget("org.osbuild.libvirt", options)
+}
+
+// LibvirtTargetResultOptions identify the volume an image was imported into
+// and the domain defined for it, if any
+type LibvirtTargetResultOptions struct {
+	VolumePath string `json:"volume_path"`
+	DomainUUID string `json:"domain_uuid,omitempty"`
+}
+
+func (LibvirtTargetResultOptions) isTargetResultOptions() {}
+
+func NewLibvirtTargetResult(options *LibvirtTargetResultOptions) *TargetResult {
+	return newTargetResult("org.osbuild.libvirt", options)
+}
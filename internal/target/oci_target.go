@@ -0,0 +1,39 @@
+package target
+
+type OCITargetOptions struct {
+	Filename string `json:"filename"`
+	// The API signing key of an OCI user
+	User        string `json:"user"`
+	Tenancy     string `json:"tenancy"`
+	Region      string `json:"region"`
+	Fingerprint string `json:"fingerprint"`
+	PrivateKey  string `json:"private_key"`
+	// Compartment is the OCID of the compartment of the custom image.
+	// Namespace defaults to the Object Storage namespace of the tenancy.
+	Compartment string `json:"compartment"`
+	Namespace   string `json:"namespace,omitempty"`
+	Bucket      string `json:"bucket"`
+	Object      string `json:"object"`
+}
+
+func (OCITargetOptions) isTargetOptions() {}
+
+func NewOCITarget(options *OCITargetOptions) *Target {
+	return newTarget("org.osbuild.oci", options)
+}
+
+// OCITargetResultOptions identify the custom image imported for an image
+// uploaded to an OCI target
+type OCITargetResultOptions struct {
+	ImageID string `json:"image_id"`
+	Region  string `json:"region"`
+	// DownloadURL is the URL of a pre-authenticated request for the
+	// uploaded image
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+func (OCITargetResultOptions) isTargetResultOptions() {}
+
+func NewOCITargetResult(options *OCITargetResultOptions) *TargetResult {
+	return newTargetResult("org.osbuild.oci", options)
+}
@@ -0,0 +1,32 @@
+package target
+
+import "encoding/json"
+
+// PluginTargetOptions describe an upload by a plugin, an executable on the
+// worker implementing a target which composer does not know about
+type PluginTargetOptions struct {
+	Filename string `json:"filename"`
+	// Plugin is the name of the plugin on the worker
+	Plugin string `json:"plugin"`
+	// Options are passed to the plugin as they are
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+func (PluginTargetOptions) isTargetOptions() {}
+
+func NewPluginTarget(options *PluginTargetOptions) *Target {
+	return newTarget("org.osbuild.plugin", options)
+}
+
+// PluginTargetResultOptions identify the image uploaded by a plugin
+type PluginTargetResultOptions struct {
+	Plugin  string `json:"plugin"`
+	ImageID string `json:"image_id,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+func (PluginTargetResultOptions) isTargetResultOptions() {}
+
+func NewPluginTargetResult(options *PluginTargetResultOptions) *TargetResult {
+	return newTargetResult("org.osbuild.plugin", options)
+}
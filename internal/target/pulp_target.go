@@ -0,0 +1,38 @@
+package target
+
+type PulpTargetOptions struct {
+	Filename string `json:"filename"`
+	// The URL of the Pulp server and the credentials of a Pulp user
+	Server   string `json:"server"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// ContentType is the Pulp plugin managing the repository: "ostree" for
+	// commits, which are imported into an ostree repository, or "file"
+	ContentType string `json:"content_type"`
+	Repository  string `json:"repository"`
+	// RelativePath is the path of the artifact in a file repository
+	RelativePath string `json:"relative_path,omitempty"`
+	// BasePath is the base path of a distribution serving the repository,
+	// which is created unless it exists. The repository is not distributed
+	// when it is empty.
+	BasePath string `json:"base_path,omitempty"`
+}
+
+func (PulpTargetOptions) isTargetOptions() {}
+
+func NewPulpTarget(options *PulpTargetOptions) *Target {
+	return newTarget("org.osbuild.pulp", options)
+}
+
+// PulpTargetResultOptions identify the repository version containing the
+// artifact of a Pulp target and the URL it is distributed at, if any
+type PulpTargetResultOptions struct {
+	RepositoryVersion string `json:"repository_version"`
+	BaseURL           string `json:"base_url,omitempty"`
+}
+
+func (PulpTargetResultOptions) isTargetResultOptions() {}
+
+func NewPulpTargetResult(options *PulpTargetResultOptions) *TargetResult {
+	return newTargetResult("org.osbuild.pulp", options)
+}
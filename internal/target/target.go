@@ -31,6 +31,55 @@ type TargetOptions interface {
 	isTargetOptions()
 }
 
+// WithoutSecrets returns a copy of the target whose options do not contain
+// the credentials needed for uploading the image, for storing targets after
+// their job has been queued
+func (t *Target) WithoutSecrets() *Target {
+	redacted := *t
+	switch options := t.Options.(type) {
+	case *AWSTargetOptions:
+		o := *options
+		o.AccessKeyID = ""
+		o.SecretAccessKey = ""
+		redacted.Options = &o
+	case *AzureTargetOptions:
+		o := *options
+		o.StorageAccessKey = ""
+		o.ClientSecret = ""
+		redacted.Options = &o
+	case *GCPTargetOptions:
+		o := *options
+		o.Credentials = nil
+		redacted.Options = &o
+	case *OCITargetOptions:
+		o := *options
+		o.PrivateKey = ""
+		redacted.Options = &o
+	case *VMWareTargetOptions:
+		o := *options
+		o.Password = ""
+		redacted.Options = &o
+	case *PulpTargetOptions:
+		o := *options
+		o.Password = ""
+		redacted.Options = &o
+	case *HookTargetOptions:
+		o := *options
+		o.Headers = nil
+		redacted.Options = &o
+	case *KojiTargetOptions:
+		o := *options
+		o.Token = ""
+		redacted.Options = &o
+	case *PluginTargetOptions:
+		// composer cannot tell which options of a plugin are secret
+		o := *options
+		o.Options = nil
+		redacted.Options = &o
+	}
+	return &redacted
+}
+
 type rawTarget struct {
 	Uuid      uuid.UUID              `json:"uuid"`
 	ImageName string                 `json:"image_name"`
@@ -72,6 +121,22 @@ func UnmarshalTargetOptions(targetName string, rawOptions json.RawMessage) (Targ
 		options = new(LocalTargetOptions)
 	case "org.osbuild.koji":
 		options = new(KojiTargetOptions)
+	case "org.osbuild.gcp":
+		options = new(GCPTargetOptions)
+	case "org.osbuild.oci":
+		options = new(OCITargetOptions)
+	case "org.osbuild.vmware":
+		options = new(VMWareTargetOptions)
+	case "org.osbuild.pulp":
+		options = new(PulpTargetOptions)
+	case "org.osbuild.export":
+		options = new(ExportTargetOptions)
+	case "org.osbuild.libvirt":
+		options = new(LibvirtTargetOptions)
+	case "org.osbuild.hook":
+		options = new(HookTargetOptions)
+	case "org.osbuild.plugin":
+		options = new(PluginTargetOptions)
 	default:
 		return nil, errors.New("unexpected target name")
 	}
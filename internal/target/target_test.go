@@ -0,0 +1,25 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithoutSecrets(t *testing.T) {
+	aws := NewAWSTarget(&AWSTargetOptions{Region: "eu-central-1", AccessKeyID: "id", SecretAccessKey: "secret"})
+	redacted := aws.WithoutSecrets().Options.(*AWSTargetOptions)
+	require.Equal(t, "eu-central-1", redacted.Region)
+	require.Empty(t, redacted.AccessKeyID)
+	require.Empty(t, redacted.SecretAccessKey)
+
+	koji := NewKojiTarget(&KojiTargetOptions{BuildID: 42, Token: "token", Server: "https://koji.example.com/kojihub"})
+	redactedKoji := koji.WithoutSecrets().Options.(*KojiTargetOptions)
+	require.Equal(t, uint64(42), redactedKoji.BuildID)
+	require.Equal(t, "https://koji.example.com/kojihub", redactedKoji.Server)
+	require.Empty(t, redactedKoji.Token)
+
+	// the options of the target itself are left alone
+	require.Equal(t, "secret", aws.Options.(*AWSTargetOptions).SecretAccessKey)
+	require.Equal(t, "token", koji.Options.(*KojiTargetOptions).Token)
+}
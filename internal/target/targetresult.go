@@ -0,0 +1,74 @@
+package target
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// A TargetResult is what a worker reports back about a target it handled,
+// such as the ID of the image it registered
+type TargetResult struct {
+	Name    string              `json:"name"`
+	Options TargetResultOptions `json:"options"`
+}
+
+func newTargetResult(name string, options TargetResultOptions) *TargetResult {
+	return &TargetResult{
+		Name:    name,
+		Options: options,
+	}
+}
+
+type TargetResultOptions interface {
+	isTargetResultOptions()
+}
+
+type rawTargetResult struct {
+	Name    string          `json:"name"`
+	Options json.RawMessage `json:"options"`
+}
+
+func (targetResult *TargetResult) UnmarshalJSON(data []byte) error {
+	var rawTR rawTargetResult
+	err := json.Unmarshal(data, &rawTR)
+	if err != nil {
+		return err
+	}
+	options, err := UnmarshalTargetResultOptions(rawTR.Name, rawTR.Options)
+	if err != nil {
+		return err
+	}
+
+	targetResult.Name = rawTR.Name
+	targetResult.Options = options
+	return nil
+}
+
+func UnmarshalTargetResultOptions(trName string, rawOptions json.RawMessage) (TargetResultOptions, error) {
+	var options TargetResultOptions
+	switch trName {
+	case "org.osbuild.aws":
+		options = new(AWSTargetResultOptions)
+	case "org.osbuild.azure":
+		options = new(AzureTargetResultOptions)
+	case "org.osbuild.gcp":
+		options = new(GCPTargetResultOptions)
+	case "org.osbuild.oci":
+		options = new(OCITargetResultOptions)
+	case "org.osbuild.vmware":
+		options = new(VMWareTargetResultOptions)
+	case "org.osbuild.pulp":
+		options = new(PulpTargetResultOptions)
+	case "org.osbuild.export":
+		options = new(ExportTargetResultOptions)
+	case "org.osbuild.libvirt":
+		options = new(LibvirtTargetResultOptions)
+	case "org.osbuild.plugin":
+		options = new(PluginTargetResultOptions)
+	default:
+		return nil, errors.New("unexpected target result name")
+	}
+	err := json.Unmarshal(rawOptions, options)
+
+	return options, err
+}
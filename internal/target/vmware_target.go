@@ -0,0 +1,42 @@
+package target
+
+type VMWareTargetOptions struct {
+	Filename string `json:"filename"`
+	// The vCenter or ESXi host and the credentials of a vSphere user
+	Host     string `json:"host"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Insecure bool   `json:"insecure,omitempty"`
+	// The image is imported into a datastore, unless ContentLibrary is set.
+	// Empty fields select the only object of their kind.
+	Datacenter string `json:"datacenter,omitempty"`
+	Cluster    string `json:"cluster,omitempty"`
+	Datastore  string `json:"datastore,omitempty"`
+	Folder     string `json:"folder,omitempty"`
+	// Template creates a virtual machine template from the imported disk,
+	// connected to Network, unless it is empty
+	Template bool   `json:"template,omitempty"`
+	Network  string `json:"network,omitempty"`
+	// ContentLibrary is the name of a content library the image is added
+	// to as an OVF template
+	ContentLibrary string `json:"content_library,omitempty"`
+}
+
+func (VMWareTargetOptions) isTargetOptions() {}
+
+func NewVMWareTarget(options *VMWareTargetOptions) *Target {
+	return newTarget("org.osbuild.vmware", options)
+}
+
+// VMWareTargetResultOptions identify the image imported for a VMWare target:
+// the datastore path of the disk, the inventory path of the template or the
+// ID of the content library item
+type VMWareTargetResultOptions struct {
+	ImageID string `json:"image_id"`
+}
+
+func (VMWareTargetResultOptions) isTargetResultOptions() {}
+
+func NewVMWareTargetResult(options *VMWareTargetResultOptions) *TargetResult {
+	return newTargetResult("org.osbuild.vmware", options)
+}
@@ -1,40 +1,141 @@
 package awsupload
 
 import (
+	"bytes"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/google/uuid"
+
+	"github.com/osbuild/osbuild-composer/internal/upload/progress"
+	"github.com/osbuild/osbuild-composer/internal/upload/throttle"
 )
 
 type AWS struct {
+	sess     *session.Session
 	uploader *s3manager.Uploader
 	ec2      *ec2.EC2
 	s3       *s3.S3
+	// partition is the ID of the partition of the region, e.g. "aws"
+	partition string
+	// Images uploaded to a custom S3 endpoint cannot be registered as AMIs
+	customEndpoint bool
+
+	// Progress is called while images are uploaded to S3
+	Progress progress.Func
+	// Limiter limits the bandwidth of uploads, unless it is nil
+	Limiter *throttle.Limiter
 }
 
+// New returns a client of the region, which may be in any partition, e.g.
+// GovCloud or China. Without an access key, the client finds credentials
+// like the AWS CLI does, e.g. in the environment or the instance profile of
+// the EC2 instance it runs on.
 func New(region, accessKeyID, accessKey string) (*AWS, error) {
-	// Session credentials
-	creds := credentials.NewStaticCredentials(accessKeyID, accessKey, "")
-
-	// Create a Session with a custom region
-	sess, err := session.NewSession(&aws.Config{
-		Credentials: creds,
+	partition, err := Partition(region)
+	if err != nil {
+		return nil, err
+	}
+	a, err := newAWS(&aws.Config{
+		Credentials: staticCredentials(accessKeyID, accessKey),
 		Region:      aws.String(region),
 	})
 	if err != nil {
 		return nil, err
 	}
+	a.partition = partition
+	return a, nil
+}
+
+// Partition returns the ID of the partition a region belongs to, which is
+// "aws" for the commercial regions, "aws-us-gov" for GovCloud and "aws-cn"
+// for China. Partitions are isolated from each other: they have their own
+// endpoints, accounts and ARNs.
+func Partition(region string) (string, error) {
+	if region == "" {
+		return "", errors.New("no AWS region given")
+	}
+	p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region)
+	if !ok {
+		return "", fmt.Errorf("unknown AWS region %q", region)
+	}
+	return p.ID(), nil
+}
+
+// staticCredentials returns the credentials of an access key, or nil for
+// the default credential chain of the SDK if no access key is given
+func staticCredentials(accessKeyID, accessKey string) *credentials.Credentials {
+	if accessKeyID == "" && accessKey == "" {
+		return nil
+	}
+	return credentials.NewStaticCredentials(accessKeyID, accessKey, "")
+}
+
+// S3Endpoint is an S3-compatible object store, like MinIO or Ceph, which
+// images are uploaded to instead of Amazon S3
+type S3Endpoint struct {
+	URL string
+	// ForcePathStyle puts the bucket into the path of URLs instead of the
+	// host name, which most S3-compatible stores need
+	ForcePathStyle bool
+	// Insecure disables the verification of the certificate of the store,
+	// which is often self-signed
+	Insecure bool
+}
+
+// NewForEndpoint returns a client which uploads images to an S3-compatible
+// object store. The images cannot be registered as AMIs.
+func NewForEndpoint(endpoint S3Endpoint, region, accessKeyID, accessKey string) (*AWS, error) {
+	// most S3-compatible stores accept any region
+	if region == "" {
+		region = "us-east-1"
+	}
+	config := &aws.Config{
+		Credentials:      staticCredentials(accessKeyID, accessKey),
+		Region:           aws.String(region),
+		Endpoint:         aws.String(endpoint.URL),
+		S3ForcePathStyle: aws.Bool(endpoint.ForcePathStyle),
+	}
+	if endpoint.Insecure {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		config.HTTPClient = &http.Client{Transport: transport}
+	}
+
+	a, err := newAWS(config)
+	if err != nil {
+		return nil, err
+	}
+	a.customEndpoint = true
+	return a, nil
+}
+
+func newAWS(config *aws.Config) (*AWS, error) {
+	// Images are uploaded to S3 in parts, and a part which fails is retried
+	// more often than the SDK does by default, so that a network problem
+	// late in the upload of a large image does not fail it.
+	config.MaxRetries = aws.Int(10)
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, err
+	}
 
 	return &AWS{
+		sess:     sess,
 		uploader: s3manager.NewUploader(sess),
 		ec2:      ec2.New(sess),
 		s3:       s3.New(sess),
@@ -46,17 +147,94 @@ func (a *AWS) Upload(filename, bucket, key string) (*s3manager.UploadOutput, err
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
 
 	log.Printf("[AWS] 🚀 Uploading image to S3: %s/%s", bucket, key)
 	return a.uploader.Upload(
 		&s3manager.UploadInput{
 			Bucket: aws.String(bucket),
 			Key:    aws.String(key),
-			Body:   file,
+			Body:   a.Limiter.NewReader(progress.NewReader(file, info.Size(), a.Progress)),
 		},
 	)
 }
 
+// PresignedURL returns a URL which allows downloading an object without
+// credentials until it expires
+func (a *AWS) PresignedURL(bucket, key string, expiry time.Duration) (string, error) {
+	req, _ := a.s3.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	url, err := req.Presign(expiry)
+	if err != nil {
+		return "", fmt.Errorf("cannot presign the URL of %s/%s: %v", bucket, key, err)
+	}
+	return url, nil
+}
+
+// CheckPermissions verifies that the credentials allow uploading images to
+// the bucket and, if register is set and the bucket is not on a custom
+// endpoint, importing and registering them. An empty object is written to
+// the bucket and deleted again, the EC2 calls are only dry runs.
+func (a *AWS) CheckPermissions(bucket string, register bool) error {
+	key := "osbuild-composer-check-" + uuid.New().String()
+	_, err := a.s3.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(nil),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot write to bucket %s: %v", bucket, err)
+	}
+	_, err = a.s3.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot delete objects from bucket %s: %v", bucket, err)
+	}
+
+	if !register || a.customEndpoint {
+		return nil
+	}
+
+	_, err = a.ec2.ImportSnapshot(&ec2.ImportSnapshotInput{
+		DryRun: aws.Bool(true),
+		DiskContainer: &ec2.SnapshotDiskContainer{
+			UserBucket: &ec2.UserBucket{
+				S3Bucket: aws.String(bucket),
+				S3Key:    aws.String(key),
+			},
+		},
+	})
+	if err = dryRunError(err); err != nil {
+		return fmt.Errorf("cannot import snapshots: %v", err)
+	}
+	_, err = a.ec2.RegisterImage(&ec2.RegisterImageInput{
+		DryRun: aws.Bool(true),
+		Name:   aws.String(key),
+	})
+	if err = dryRunError(err); err != nil {
+		return fmt.Errorf("cannot register images: %v", err)
+	}
+
+	return nil
+}
+
+// dryRunError returns the error of a dry run, which fails with
+// DryRunOperation when the real request would have been allowed
+func dryRunError(err error) error {
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "DryRunOperation" {
+		return nil
+	}
+	return err
+}
+
 // WaitUntilImportSnapshotCompleted uses the Amazon EC2 API operation
 // DescribeImportSnapshots to wait for a condition to be met before returning.
 // If the condition is not met within the max attempt window, an error will
@@ -123,6 +301,10 @@ func (a *AWS) Register(name, bucket, key string, shareWith []string, rpmArch str
 		"aarch64": "arm64",
 	}
 
+	if a.customEndpoint {
+		return nil, errors.New("images uploaded to a custom S3 endpoint cannot be registered")
+	}
+
 	ec2Arch, validArch := rpmArchToEC2Arch[rpmArch]
 	if !validArch {
 		return nil, fmt.Errorf("ec2 doesn't support the following arch: %s", rpmArch)
@@ -284,3 +466,115 @@ func (a *AWS) Register(name, bucket, key string, shareWith []string, rpmArch str
 
 	return registerOutput.ImageId, nil
 }
+
+// ImageARN returns the ARN of an AMI in a region of the partition of the
+// client
+func (a *AWS) ImageARN(region, ami string) string {
+	return fmt.Sprintf("arn:%s:ec2:%s::image/%s", a.partition, region, ami)
+}
+
+// CheckRegions returns an error if region is unknown, or if AMIs cannot be
+// copied from it to one of copyTo, because it is unknown or in another
+// partition. It does not need a client, so that requests can be validated
+// before images are built.
+func CheckRegions(region string, copyTo []string) error {
+	sourcePartition, err := Partition(region)
+	if err != nil {
+		return err
+	}
+	for _, r := range copyTo {
+		partition, err := Partition(r)
+		if err != nil {
+			return err
+		}
+		if partition != sourcePartition {
+			return fmt.Errorf("cannot copy AMIs from %s to %s, which is in another partition (%s)", region, r, partition)
+		}
+	}
+	return nil
+}
+
+// CopyImage copies an AMI to other regions and returns the IDs of the copies
+// by region. The copies are tagged and shared like the AMIs registered by
+// Register. It waits until all copies are available.
+func (a *AWS) CopyImage(name, ami string, regions []string, shareWith []string) (map[string]string, error) {
+	sourceRegion := aws.StringValue(a.sess.Config.Region)
+	err := CheckRegions(sourceRegion, regions)
+	if err != nil {
+		return nil, err
+	}
+
+	clients := make(map[string]*ec2.EC2)
+	copies := make(map[string]string)
+
+	for _, region := range regions {
+		clients[region] = ec2.New(a.sess, aws.NewConfig().WithRegion(region))
+		log.Printf("[AWS] 🛫 Copying AMI %s to %s", ami, region)
+		copyOutput, err := clients[region].CopyImage(
+			&ec2.CopyImageInput{
+				Name:          aws.String(name),
+				SourceImageId: aws.String(ami),
+				SourceRegion:  aws.String(sourceRegion),
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("cannot copy AMI %s to %s: %v", ami, region, err)
+		}
+		copies[region] = *copyOutput.ImageId
+	}
+
+	for _, region := range regions {
+		c := clients[region]
+		imageID := aws.String(copies[region])
+
+		log.Printf("[AWS] 🚚 Waiting for the copy in %s to become available: %s", region, *imageID)
+		// copies of large images can take longer than the default of ten
+		// minutes of the waiter
+		err := c.WaitUntilImageAvailableWithContext(
+			aws.BackgroundContext(),
+			&ec2.DescribeImagesInput{ImageIds: []*string{imageID}},
+			request.WithWaiterMaxAttempts(240),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("waiting for the copy of AMI %s in %s failed: %v", ami, region, err)
+		}
+
+		_, err = c.CreateTags(
+			&ec2.CreateTagsInput{
+				Resources: []*string{imageID},
+				Tags: []*ec2.Tag{
+					{
+						Key:   aws.String("Name"),
+						Value: aws.String(name),
+					},
+				},
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(shareWith) > 0 {
+			var launchPerms []*ec2.LaunchPermission
+			for _, id := range shareWith {
+				launchPerms = append(launchPerms, &ec2.LaunchPermission{
+					UserId: aws.String(id),
+				})
+			}
+			_, err := c.ModifyImageAttribute(
+				&ec2.ModifyImageAttributeInput{
+					ImageId: imageID,
+					LaunchPermission: &ec2.LaunchPermissionModifications{
+						Add: launchPerms,
+					},
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+		log.Printf("[AWS] 🎉 AMI copied to %s: %s", region, *imageID)
+	}
+
+	return copies, nil
+}
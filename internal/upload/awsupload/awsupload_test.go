@@ -0,0 +1,126 @@
+package awsupload
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadToEndpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "awsupload-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	image := filepath.Join(dir, "disk.raw")
+	require.NoError(t, ioutil.WriteFile(image, []byte("disk"), 0600))
+
+	var uploaded []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "PUT /images/disk.raw":
+			assert.Contains(t, r.Header.Get("Authorization"), "Credential=accesskey/")
+			uploaded, _ = ioutil.ReadAll(r.Body)
+			w.Header().Set("ETag", `"etag"`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	a, err := NewForEndpoint(S3Endpoint{URL: server.URL, ForcePathStyle: true}, "", "accesskey", "secretkey")
+	require.NoError(t, err)
+
+	output, err := a.Upload(image, "images", "disk.raw")
+	require.NoError(t, err)
+	assert.Equal(t, "disk", string(uploaded))
+	assert.Equal(t, server.URL+"/images/disk.raw", output.Location)
+
+	downloadURL, err := a.PresignedURL("images", "disk.raw", time.Hour)
+	require.NoError(t, err)
+	u, err := url.Parse(downloadURL)
+	require.NoError(t, err)
+	assert.Equal(t, "/images/disk.raw", u.Path)
+	assert.Equal(t, "3600", u.Query().Get("X-Amz-Expires"))
+	assert.NotEmpty(t, u.Query().Get("X-Amz-Signature"))
+
+	_, err = a.Register("disk", "images", "disk.raw", nil, "x86_64")
+	assert.EqualError(t, err, "images uploaded to a custom S3 endpoint cannot be registered")
+}
+
+func TestDefaultCredentials(t *testing.T) {
+	// the default credential chain of the SDK prefers the environment over
+	// the instance profile
+	for k, v := range map[string]string{"AWS_ACCESS_KEY_ID": "envkey", "AWS_SECRET_ACCESS_KEY": "envsecret"} {
+		old, ok := os.LookupEnv(k)
+		require.NoError(t, os.Setenv(k, v))
+		if ok {
+			defer os.Setenv(k, old)
+		} else {
+			defer os.Unsetenv(k)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header.Get("Authorization"), "Credential=envkey/")
+		w.Header().Set("ETag", `"etag"`)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "awsupload-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	image := filepath.Join(dir, "disk.raw")
+	require.NoError(t, ioutil.WriteFile(image, []byte("disk"), 0600))
+
+	a, err := NewForEndpoint(S3Endpoint{URL: server.URL, ForcePathStyle: true}, "", "", "")
+	require.NoError(t, err)
+	_, err = a.Upload(image, "images", "disk.raw")
+	require.NoError(t, err)
+}
+
+func TestPartition(t *testing.T) {
+	for region, partition := range map[string]string{
+		"eu-central-1":  "aws",
+		"us-gov-west-1": "aws-us-gov",
+		"cn-north-1":    "aws-cn",
+		// regions which the SDK does not know yet are matched by name
+		"us-gov-north-9": "aws-us-gov",
+	} {
+		p, err := Partition(region)
+		require.NoError(t, err)
+		assert.Equal(t, partition, p, region)
+	}
+
+	_, err := Partition("gov-cloud")
+	assert.EqualError(t, err, `unknown AWS region "gov-cloud"`)
+	_, err = Partition("")
+	assert.EqualError(t, err, "no AWS region given")
+
+	_, err = New("gov-cloud", "accesskey", "secretkey")
+	assert.EqualError(t, err, `unknown AWS region "gov-cloud"`)
+}
+
+func TestPartitions(t *testing.T) {
+	a, err := New("us-gov-west-1", "accesskey", "secretkey")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ec2.us-gov-west-1.amazonaws.com", a.ec2.Endpoint)
+	assert.Equal(t, "arn:aws-us-gov:ec2:us-gov-east-1::image/ami-0c830793775595d4b", a.ImageARN("us-gov-east-1", "ami-0c830793775595d4b"))
+	assert.NoError(t, CheckRegions("us-gov-west-1", []string{"us-gov-east-1"}))
+	assert.EqualError(t, CheckRegions("us-gov-west-1", []string{"us-gov-east-1", "us-east-1"}),
+		"cannot copy AMIs from us-gov-west-1 to us-east-1, which is in another partition (aws)")
+	assert.EqualError(t, CheckRegions("us-gov-west-1", []string{"us-gov"}), `unknown AWS region "us-gov"`)
+
+	a, err = New("cn-northwest-1", "accesskey", "secretkey")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ec2.cn-northwest-1.amazonaws.com.cn", a.ec2.Endpoint)
+	assert.Equal(t, "arn:aws-cn:ec2:cn-northwest-1::image/ami-0c830793775595d4b", a.ImageARN("cn-northwest-1", "ami-0c830793775595d4b"))
+	_, err = a.CopyImage("image", "ami-0c830793775595d4b", []string{"eu-central-1"}, nil)
+	assert.EqualError(t, err, "cannot copy AMIs from cn-northwest-1 to eu-central-1, which is in another partition (aws)")
+}
@@ -8,20 +8,78 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+
+	"github.com/osbuild/osbuild-composer/internal/upload/progress"
+	"github.com/osbuild/osbuild-composer/internal/upload/throttle"
 )
 
 // Credentials contains credentials to connect to your account
 // It uses so called "Client credentials", see the official documentation for more information:
 // https://docs.microsoft.com/en-us/azure/go/azure-sdk-go-authorization#available-authentication-types-and-methods
+// Without StorageAccessKey, the storage account is accessed with the
+// managed identity of the virtual machine composer runs on.
 type Credentials struct {
 	StorageAccount   string
 	StorageAccessKey string
+	// Cloud contains the endpoints of the cloud of the storage account
+	Cloud Cloud
+}
+
+// The resource of the tokens of managed identities for Azure Storage
+const storageResource = "https://storage.azure.com/"
+
+// storageCredential returns the credential for the storage account, which is
+// either its access key or a token of the managed identity of the host
+func storageCredential(credentials Credentials) (azblob.Credential, error) {
+	if credentials.StorageAccessKey != "" {
+		credential, err := azblob.NewSharedKeyCredential(credentials.StorageAccount, credentials.StorageAccessKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create azure credentials: %v", err)
+		}
+		return credential, nil
+	}
+
+	config := auth.NewMSIConfig()
+	config.Resource = storageResource
+	authorizer, err := config.Authorizer()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create the managed identity token: %v", err)
+	}
+	token, err := bearerToken(authorizer)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get a token of the managed identity: %v", err)
+	}
+
+	// the token is checked every minute and refreshed shortly before it
+	// expires, so that long uploads outlive it
+	return azblob.NewTokenCredential(token, func(credential azblob.TokenCredential) time.Duration {
+		if token, err := bearerToken(authorizer); err == nil {
+			credential.SetToken(token)
+		}
+		return time.Minute
+	}), nil
+}
+
+// bearerToken returns the token an authorizer adds to requests, refreshing
+// it if it expires soon. The vendored autorest does not expose the token
+// otherwise.
+func bearerToken(authorizer autorest.Authorizer) (string, error) {
+	req, err := autorest.Prepare(&http.Request{Header: http.Header{}, URL: &url.URL{}}, authorizer.WithAuthorization())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "), nil
 }
 
 // ImageMetadata contains information needed to store the image in a proper place.
@@ -31,25 +89,91 @@ type ImageMetadata struct {
 	ImageName     string
 }
 
+// DownloadURL returns the URL of the blob UploadImage creates for an image
+// with a shared access signature, which allows downloading it without
+// credentials until it expires. Without an access key, the signature is made
+// with a user delegation key of the managed identity of the host.
+func DownloadURL(credentials Credentials, metadata ImageMetadata, expiry time.Duration) (string, error) {
+	expiryTime := time.Now().UTC().Add(expiry)
+	var credential azblob.StorageAccountCredential
+	if credentials.StorageAccessKey != "" {
+		sharedKey, err := azblob.NewSharedKeyCredential(credentials.StorageAccount, credentials.StorageAccessKey)
+		if err != nil {
+			return "", fmt.Errorf("cannot create azure credentials: %v", err)
+		}
+		credential = sharedKey
+	} else {
+		tokenCredential, err := storageCredential(credentials)
+		if err != nil {
+			return "", err
+		}
+		p := azblob.NewPipeline(tokenCredential, azblob.PipelineOptions{})
+		URL, _ := url.Parse(credentials.Cloud.blobEndpoint(credentials.StorageAccount))
+		info := azblob.NewKeyInfo(time.Now().UTC(), expiryTime)
+		userDelegation, err := azblob.NewServiceURL(*URL, p).GetUserDelegationCredential(context.Background(), info, nil, nil)
+		if err != nil {
+			return "", fmt.Errorf("cannot get a user delegation key: %v", err)
+		}
+		credential = userDelegation
+	}
+
+	blobURL := BlobURL(credentials, metadata)
+	sas, err := azblob.BlobSASSignatureValues{
+		Protocol:      azblob.SASProtocolHTTPS,
+		ExpiryTime:    expiryTime,
+		ContainerName: metadata.ContainerName,
+		BlobName:      path.Base(blobURL),
+		Permissions:   azblob.BlobSASPermissions{Read: true}.String(),
+	}.NewSASQueryParameters(credential)
+	if err != nil {
+		return "", fmt.Errorf("cannot sign the URL of %s: %v", blobURL, err)
+	}
+	return blobURL + "?" + sas.Encode(), nil
+}
+
+// CheckStorage verifies that the credentials of the storage account are
+// valid and the container exists
+func CheckStorage(ctx context.Context, credentials Credentials, container string) error {
+	credential, err := storageCredential(credentials)
+	if err != nil {
+		return err
+	}
+	p := azblob.NewPipeline(credential, azblob.PipelineOptions{})
+	URL, _ := url.Parse(credentials.Cloud.blobEndpoint(credentials.StorageAccount) + "/" + container)
+	_, err = azblob.NewContainerURL(*URL, p).GetProperties(ctx, azblob.LeaseAccessConditions{})
+	if err != nil {
+		return fmt.Errorf("cannot access container %s: %v", container, err)
+	}
+	return nil
+}
+
 // UploadImage takes the metadata and credentials required to upload the image specified by `fileName`
 // It can speed up the upload by using goroutines. The number of parallel goroutines is bounded by
-// the `threads` argument.
-func UploadImage(credentials Credentials, metadata ImageMetadata, fileName string, threads int) error {
+// the `threads` argument. The progress of the upload is passed to `report`, unless it is nil.
+// The bandwidth of the upload is limited by `limiter`, unless it is nil.
+func UploadImage(credentials Credentials, metadata ImageMetadata, fileName string, threads int, report progress.Func, limiter *throttle.Limiter) error {
 	// Azure cannot create an image from a storage blob without .vhd extension
 	if !strings.HasSuffix(metadata.ImageName, ".vhd") {
 		metadata.ImageName = metadata.ImageName + ".vhd"
 	}
 
 	// Create a default request pipeline using your storage account name and account key.
-	credential, err := azblob.NewSharedKeyCredential(credentials.StorageAccount, credentials.StorageAccessKey)
+	credential, err := storageCredential(credentials)
 	if err != nil {
-		return fmt.Errorf("cannot create azure credentials: %v", err)
+		return err
 	}
 
-	p := azblob.NewPipeline(credential, azblob.PipelineOptions{})
+	// The image is uploaded in pages, and a page which fails is retried
+	// more often than by default, so that a network problem late in the
+	// upload of a large image does not fail it.
+	p := azblob.NewPipeline(credential, azblob.PipelineOptions{
+		Retry: azblob.RetryOptions{
+			MaxTries: 10,
+		},
+	})
 
 	// get storage account blob service URL endpoint.
-	URL, _ := url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net/%s", credentials.StorageAccount, metadata.ContainerName))
+	URL, _ := url.Parse(credentials.Cloud.blobEndpoint(credentials.StorageAccount) + "/" + metadata.ContainerName)
 
 	// Create a ContainerURL object that wraps the container URL and a request
 	// pipeline to make requests.
@@ -101,7 +225,7 @@ func UploadImage(credentials Credentials, metadata ImageMetadata, fileName strin
 	var counter int64 = 0
 
 	// Create buffered reader to speed up the upload
-	reader := bufio.NewReader(imageFile)
+	reader := bufio.NewReader(limiter.NewReader(progress.NewReader(imageFile, stat.Size(), report)))
 	// Run the upload
 	run := true
 	var wg sync.WaitGroup
@@ -122,7 +246,7 @@ func UploadImage(credentials Credentials, metadata ImageMetadata, fileName strin
 		semaphore <- 1
 		go func(counter int64, buffer []byte, n int) {
 			defer wg.Done()
-			_, err = blobURL.UploadPages(ctx, counter*azblob.PageBlobMaxUploadPagesBytes, bytes.NewReader(buffer[:n]), azblob.PageBlobAccessConditions{}, nil)
+			_, err := blobURL.UploadPages(ctx, counter*azblob.PageBlobMaxUploadPagesBytes, bytes.NewReader(buffer[:n]), azblob.PageBlobAccessConditions{}, nil)
 			if err != nil {
 				err = fmt.Errorf("uploading a page failed: %v", err)
 				// Send the error to the error channel in a non-blocking way. If there is already an error, just discard this one
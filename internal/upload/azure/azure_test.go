@@ -0,0 +1,21 @@
+package azure
+
+import (
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticToken string
+
+func (t staticToken) OAuthToken() string {
+	return string(t)
+}
+
+func TestBearerToken(t *testing.T) {
+	token, err := bearerToken(autorest.NewBearerAuthorizer(staticToken("token")))
+	require.NoError(t, err)
+	assert.Equal(t, "token", token)
+}
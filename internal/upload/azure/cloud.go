@@ -0,0 +1,75 @@
+package azure
+
+import (
+	"fmt"
+
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+// Cloud contains the endpoints of an Azure cloud, like Azure Government or
+// Azure China. The endpoints of the public cloud are used where they are
+// empty, so that the zero value is the public cloud.
+type Cloud struct {
+	// ActiveDirectoryEndpoint is the authority which issues the tokens of
+	// service principals, e.g. https://login.microsoftonline.us/
+	ActiveDirectoryEndpoint string
+	// ResourceManagerEndpoint is the URL of the Azure Resource Manager,
+	// e.g. https://management.usgovcloudapi.net/
+	ResourceManagerEndpoint string
+	// StorageEndpointSuffix is the suffix of the host names of storage
+	// accounts, e.g. core.usgovcloudapi.net
+	StorageEndpointSuffix string
+}
+
+// NewCloud returns the endpoints of the well-known cloud name, which is one
+// of AzurePublicCloud, AzureUSGovernmentCloud, AzureChinaCloud and
+// AzureGermanCloud, or the public cloud if it is empty. The endpoints which
+// are set in overrides replace those of the cloud, e.g. for private clouds.
+func NewCloud(name string, overrides Cloud) (Cloud, error) {
+	var cloud Cloud
+	if name != "" {
+		env, err := azure.EnvironmentFromName(name)
+		if err != nil {
+			return Cloud{}, fmt.Errorf("unknown azure cloud %q", name)
+		}
+		cloud = Cloud{
+			ActiveDirectoryEndpoint: env.ActiveDirectoryEndpoint,
+			ResourceManagerEndpoint: env.ResourceManagerEndpoint,
+			StorageEndpointSuffix:   env.StorageEndpointSuffix,
+		}
+	}
+
+	if overrides.ActiveDirectoryEndpoint != "" {
+		cloud.ActiveDirectoryEndpoint = overrides.ActiveDirectoryEndpoint
+	}
+	if overrides.ResourceManagerEndpoint != "" {
+		cloud.ResourceManagerEndpoint = overrides.ResourceManagerEndpoint
+	}
+	if overrides.StorageEndpointSuffix != "" {
+		cloud.StorageEndpointSuffix = overrides.StorageEndpointSuffix
+	}
+	return cloud, nil
+}
+
+func (c Cloud) activeDirectoryEndpoint() string {
+	if c.ActiveDirectoryEndpoint == "" {
+		return azure.PublicCloud.ActiveDirectoryEndpoint
+	}
+	return c.ActiveDirectoryEndpoint
+}
+
+func (c Cloud) resourceManagerEndpoint() string {
+	if c.ResourceManagerEndpoint == "" {
+		return azure.PublicCloud.ResourceManagerEndpoint
+	}
+	return c.ResourceManagerEndpoint
+}
+
+// blobEndpoint returns the URL of the blob service of a storage account
+func (c Cloud) blobEndpoint(storageAccount string) string {
+	suffix := c.StorageEndpointSuffix
+	if suffix == "" {
+		suffix = azure.PublicCloud.StorageEndpointSuffix
+	}
+	return fmt.Sprintf("https://%s.blob.%s", storageAccount, suffix)
+}
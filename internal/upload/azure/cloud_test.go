@@ -0,0 +1,34 @@
+package azure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCloud(t *testing.T) {
+	cloud, err := NewCloud("", Cloud{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://login.microsoftonline.com/", cloud.activeDirectoryEndpoint())
+	assert.Equal(t, "https://management.azure.com/", cloud.resourceManagerEndpoint())
+	assert.Equal(t, "https://account.blob.core.windows.net", cloud.blobEndpoint("account"))
+
+	cloud, err = NewCloud("AzureUSGovernmentCloud", Cloud{})
+	require.NoError(t, err)
+	assert.Equal(t, Cloud{
+		ActiveDirectoryEndpoint: "https://login.microsoftonline.us/",
+		ResourceManagerEndpoint: "https://management.usgovcloudapi.net/",
+		StorageEndpointSuffix:   "core.usgovcloudapi.net",
+	}, cloud)
+
+	cloud, err = NewCloud("azurechinacloud", Cloud{ResourceManagerEndpoint: "https://management.example.cn/"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://login.chinacloudapi.cn/", cloud.activeDirectoryEndpoint())
+	assert.Equal(t, "https://management.example.cn/", cloud.resourceManagerEndpoint())
+	assert.Equal(t, "https://account.blob.core.chinacloudapi.cn/images/disk.vhd",
+		BlobURL(Credentials{StorageAccount: "account", Cloud: cloud}, ImageMetadata{ContainerName: "images", ImageName: "disk"}))
+
+	_, err = NewCloud("AzureMoonCloud", Cloud{})
+	assert.EqualError(t, err, `unknown azure cloud "AzureMoonCloud"`)
+}
@@ -0,0 +1,358 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+)
+
+// The versions of the Azure Resource Manager APIs of managed images,
+// galleries, role assignments and permissions. There are no vendored SDKs
+// for these APIs, so the requests are made directly.
+const (
+	imagesAPIVersion          = "2020-06-01"
+	galleriesAPIVersion       = "2019-12-01"
+	roleAssignmentsAPIVersion = "2015-07-01"
+	permissionsAPIVersion     = "2015-07-01"
+)
+
+// The actions a service principal needs for registering, publishing and
+// sharing images
+const (
+	ImageWriteAction               = "Microsoft.Compute/images/write"
+	GalleryImageVersionWriteAction = "Microsoft.Compute/galleries/images/versions/write"
+	RoleAssignmentWriteAction      = "Microsoft.Authorization/roleAssignments/write"
+)
+
+// The ID of the built-in Reader role, which allows creating virtual machines
+// from an image
+const readerRoleID = "acdd72a7-3385-48ef-bd42-f606fba81ae7"
+
+// ClientCredentials are the credentials of a service principal, which are
+// needed to manage resources like images, in contrast to the Credentials of
+// a storage account, which are enough for uploading blobs. Without
+// ClientSecret, the managed identity of the host is used instead, or the
+// user-assigned identity of ClientID if it is set.
+type ClientCredentials struct {
+	SubscriptionID string
+	TenantID       string
+	ClientID       string
+	ClientSecret   string
+	// Cloud contains the endpoints of the cloud of the subscription
+	Cloud Cloud
+}
+
+// ImageRegistration describes the managed image to be created from an
+// uploaded VHD
+type ImageRegistration struct {
+	ResourceGroup string
+	Location      string
+	ImageName     string
+	BlobURL       string
+}
+
+type image struct {
+	ID         string          `json:"id,omitempty"`
+	Location   string          `json:"location"`
+	Properties imageProperties `json:"properties"`
+}
+
+type imageProperties struct {
+	StorageProfile struct {
+		OSDisk struct {
+			OSType  string `json:"osType"`
+			OSState string `json:"osState"`
+			BlobURI string `json:"blobUri"`
+		} `json:"osDisk"`
+	} `json:"storageProfile"`
+	HyperVGeneration string `json:"hyperVGeneration"`
+}
+
+// BlobURL returns the URL of the page blob UploadImage creates for an image
+func BlobURL(credentials Credentials, metadata ImageMetadata) string {
+	imageName := metadata.ImageName
+	if !strings.HasSuffix(imageName, ".vhd") {
+		imageName = imageName + ".vhd"
+	}
+	return fmt.Sprintf("%s/%s/%s", credentials.Cloud.blobEndpoint(credentials.StorageAccount), metadata.ContainerName, imageName)
+}
+
+// newClient returns a client of the Azure Resource Manager authenticated as
+// a service principal or managed identity
+func newClient(credentials ClientCredentials) (autorest.Client, error) {
+	client := autorest.NewClientWithUserAgent("osbuild-composer")
+	var authorizer autorest.Authorizer
+	var err error
+	if credentials.ClientSecret != "" {
+		config := auth.NewClientCredentialsConfig(credentials.ClientID, credentials.ClientSecret, credentials.TenantID)
+		config.AADEndpoint = credentials.Cloud.activeDirectoryEndpoint()
+		config.Resource = credentials.Cloud.resourceManagerEndpoint()
+		authorizer, err = config.Authorizer()
+	} else {
+		config := auth.NewMSIConfig()
+		config.ClientID = credentials.ClientID
+		config.Resource = credentials.Cloud.resourceManagerEndpoint()
+		authorizer, err = config.Authorizer()
+	}
+	if err != nil {
+		return client, fmt.Errorf("cannot create the azure authorizer: %v", err)
+	}
+	client.Authorizer = authorizer
+	return client, nil
+}
+
+// RegisterImage creates a managed image from a VHD uploaded by UploadImage
+// and returns its resource ID. It waits until Azure has finished creating
+// the image.
+func RegisterImage(ctx context.Context, credentials ClientCredentials, registration ImageRegistration) (string, error) {
+	client, err := newClient(credentials)
+	if err != nil {
+		return "", err
+	}
+
+	var body image
+	body.Location = registration.Location
+	body.Properties.StorageProfile.OSDisk.OSType = "Linux"
+	body.Properties.StorageProfile.OSDisk.OSState = "Generalized"
+	body.Properties.StorageProfile.OSDisk.BlobURI = registration.BlobURL
+	body.Properties.HyperVGeneration = "V1"
+
+	var result image
+	err = createResource(ctx, client, credentials.Cloud, "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Compute/images/{imageName}", map[string]interface{}{
+		"subscriptionId":    autorest.Encode("path", credentials.SubscriptionID),
+		"resourceGroupName": autorest.Encode("path", registration.ResourceGroup),
+		"imageName":         autorest.Encode("path", registration.ImageName),
+	}, imagesAPIVersion, body, &result)
+	if err != nil {
+		return "", fmt.Errorf("cannot create image %s: %v", registration.ImageName, err)
+	}
+	return result.ID, nil
+}
+
+// GalleryImageVersion describes a version of an image definition in a
+// Shared Image Gallery, which is replicated to the target regions
+type GalleryImageVersion struct {
+	ResourceGroup string
+	Location      string
+	Gallery       string
+	Definition    string
+	Version       string
+	TargetRegions []string
+}
+
+// PublishToGallery creates an image version in a Shared Image Gallery from a
+// managed image created by RegisterImage and returns its resource ID. The
+// gallery and the image definition must exist. It waits until the version
+// has been replicated to all target regions, which always include the
+// location of the version.
+func PublishToGallery(ctx context.Context, credentials ClientCredentials, imageID string, version GalleryImageVersion) (string, error) {
+	client, err := newClient(credentials)
+	if err != nil {
+		return "", err
+	}
+
+	type targetRegion struct {
+		Name                 string `json:"name"`
+		RegionalReplicaCount int    `json:"regionalReplicaCount"`
+	}
+	regions := []targetRegion{{version.Location, 1}}
+	for _, region := range version.TargetRegions {
+		if region != version.Location {
+			regions = append(regions, targetRegion{region, 1})
+		}
+	}
+
+	body := map[string]interface{}{
+		"location": version.Location,
+		"properties": map[string]interface{}{
+			"publishingProfile": map[string]interface{}{
+				"targetRegions": regions,
+			},
+			"storageProfile": map[string]interface{}{
+				"source": map[string]string{
+					"id": imageID,
+				},
+			},
+		},
+	}
+
+	var result struct {
+		ID string `json:"id"`
+	}
+	err = createResource(ctx, client, credentials.Cloud, "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Compute/galleries/{galleryName}/images/{galleryImageName}/versions/{galleryImageVersionName}", map[string]interface{}{
+		"subscriptionId":          autorest.Encode("path", credentials.SubscriptionID),
+		"resourceGroupName":       autorest.Encode("path", version.ResourceGroup),
+		"galleryName":             autorest.Encode("path", version.Gallery),
+		"galleryImageName":        autorest.Encode("path", version.Definition),
+		"galleryImageVersionName": autorest.Encode("path", version.Version),
+	}, galleriesAPIVersion, body, &result)
+	if err != nil {
+		return "", fmt.Errorf("cannot create version %s of gallery image %s/%s: %v", version.Version, version.Gallery, version.Definition, err)
+	}
+	return result.ID, nil
+}
+
+// createResource creates or updates a resource with a PUT request, waits
+// until Azure has finished provisioning it and decodes it into result
+func createResource(ctx context.Context, client autorest.Client, cloud Cloud, path string, pathParameters map[string]interface{}, apiVersion string, body interface{}, result interface{}) error {
+	req, err := autorest.Prepare((&http.Request{}).WithContext(ctx),
+		autorest.AsContentType("application/json; charset=utf-8"),
+		autorest.AsPut(),
+		autorest.WithBaseURL(cloud.resourceManagerEndpoint()),
+		autorest.WithPathParameters(path, pathParameters),
+		autorest.WithQueryParameters(map[string]interface{}{
+			"api-version": apiVersion,
+		}),
+		autorest.WithJSON(body))
+	if err != nil {
+		return fmt.Errorf("cannot prepare the request: %v", err)
+	}
+
+	resp, err := client.Send(req, azure.DoRetryWithRegistration(client))
+	if err != nil {
+		return err
+	}
+	future, err := azure.NewFutureFromResponse(resp)
+	if err != nil {
+		return err
+	}
+	err = future.WaitForCompletionRef(ctx, client)
+	if err != nil {
+		return fmt.Errorf("waiting for completion failed: %v", err)
+	}
+
+	resp, err = future.GetResult(client)
+	if err != nil {
+		return err
+	}
+	return autorest.Respond(resp,
+		azure.WithErrorUnlessStatusCode(http.StatusOK, http.StatusCreated),
+		autorest.ByUnmarshallingJSON(result),
+		autorest.ByClosing())
+}
+
+// ShareImage assigns the Reader role on a managed image to the principals
+// with the given object IDs, which can be users, groups or service
+// principals of the tenant
+func ShareImage(ctx context.Context, credentials ClientCredentials, imageID string, principals []string) error {
+	client, err := newClient(credentials)
+	if err != nil {
+		return err
+	}
+
+	for _, principal := range principals {
+		body := map[string]interface{}{
+			"properties": map[string]string{
+				"roleDefinitionId": fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s", credentials.SubscriptionID, readerRoleID),
+				"principalId":      principal,
+			},
+		}
+		req, err := autorest.Prepare((&http.Request{}).WithContext(ctx),
+			autorest.AsContentType("application/json; charset=utf-8"),
+			autorest.AsPut(),
+			autorest.WithBaseURL(credentials.Cloud.resourceManagerEndpoint()),
+			autorest.WithPath(imageID),
+			autorest.WithPathParameters("/providers/Microsoft.Authorization/roleAssignments/{roleAssignmentName}", map[string]interface{}{
+				"roleAssignmentName": autorest.Encode("path", uuid.New().String()),
+			}),
+			autorest.WithQueryParameters(map[string]interface{}{
+				"api-version": roleAssignmentsAPIVersion,
+			}),
+			autorest.WithJSON(body))
+		if err != nil {
+			return fmt.Errorf("cannot prepare the role assignment request: %v", err)
+		}
+
+		resp, err := client.Send(req, azure.DoRetryWithRegistration(client))
+		if err == nil {
+			err = autorest.Respond(resp,
+				azure.WithErrorUnlessStatusCode(http.StatusOK, http.StatusCreated),
+				autorest.ByClosing())
+		}
+		if err != nil {
+			return fmt.Errorf("cannot share image %s with %s: %v", imageID, principal, err)
+		}
+	}
+	return nil
+}
+
+type permission struct {
+	Actions    []string `json:"actions"`
+	NotActions []string `json:"notActions"`
+}
+
+// CheckPermissions verifies that the service principal may perform the
+// given actions in the resource group
+func CheckPermissions(ctx context.Context, credentials ClientCredentials, resourceGroup string, actions []string) error {
+	client, err := newClient(credentials)
+	if err != nil {
+		return err
+	}
+
+	req, err := autorest.Prepare((&http.Request{}).WithContext(ctx),
+		autorest.AsGet(),
+		autorest.WithBaseURL(credentials.Cloud.resourceManagerEndpoint()),
+		autorest.WithPathParameters("/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Authorization/permissions", map[string]interface{}{
+			"subscriptionId":    autorest.Encode("path", credentials.SubscriptionID),
+			"resourceGroupName": autorest.Encode("path", resourceGroup),
+		}),
+		autorest.WithQueryParameters(map[string]interface{}{
+			"api-version": permissionsAPIVersion,
+		}))
+	if err != nil {
+		return fmt.Errorf("cannot prepare the permissions request: %v", err)
+	}
+
+	var result struct {
+		Value []permission `json:"value"`
+	}
+	resp, err := client.Send(req, azure.DoRetryWithRegistration(client))
+	if err == nil {
+		err = autorest.Respond(resp,
+			azure.WithErrorUnlessStatusCode(http.StatusOK),
+			autorest.ByUnmarshallingJSON(&result),
+			autorest.ByClosing())
+	}
+	if err != nil {
+		return fmt.Errorf("cannot get the permissions in resource group %s: %v", resourceGroup, err)
+	}
+
+	var missing []string
+	for _, action := range actions {
+		if !actionAllowed(result.Value, action) {
+			missing = append(missing, action)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing permissions in resource group %s: %s", resourceGroup, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// actionAllowed returns whether one of the permissions allows an action.
+// Actions are matched case insensitively and may contain wildcards.
+func actionAllowed(permissions []permission, action string) bool {
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			re := "(?i)^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+			if regexp.MustCompile(re).MatchString(action) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, p := range permissions {
+		if matches(p.Actions) && !matches(p.NotActions) {
+			return true
+		}
+	}
+	return false
+}
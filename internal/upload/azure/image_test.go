@@ -0,0 +1,26 @@
+package azure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActionAllowed(t *testing.T) {
+	contributor := []permission{{
+		Actions:    []string{"*"},
+		NotActions: []string{"Microsoft.Authorization/*/Delete", "Microsoft.Authorization/*/Write"},
+	}}
+	assert.True(t, actionAllowed(contributor, ImageWriteAction))
+	assert.True(t, actionAllowed(contributor, GalleryImageVersionWriteAction))
+	assert.False(t, actionAllowed(contributor, RoleAssignmentWriteAction))
+
+	computeOnly := []permission{
+		{Actions: []string{"microsoft.compute/images/*"}},
+		{Actions: []string{"Microsoft.Storage/*"}},
+	}
+	assert.True(t, actionAllowed(computeOnly, ImageWriteAction))
+	assert.False(t, actionAllowed(computeOnly, GalleryImageVersionWriteAction))
+
+	assert.False(t, actionAllowed(nil, ImageWriteAction))
+}
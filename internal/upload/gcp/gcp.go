@@ -0,0 +1,668 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/osbuild/osbuild-composer/internal/upload/progress"
+	"github.com/osbuild/osbuild-composer/internal/upload/throttle"
+)
+
+// The scope of the access tokens, which covers both Cloud Storage and
+// Compute Engine
+const scope = "https://www.googleapis.com/auth/cloud-platform"
+
+// Images are uploaded to Cloud Storage in chunks of chunkSize, which must be
+// a multiple of 256 KiB. A chunk which fails is retried up to maxRetries
+// times, waiting a little longer after every failure.
+const (
+	chunkSize  = 16 * 1024 * 1024
+	maxRetries = 5
+)
+
+// Credentials are the fields of the JSON key of a service account which are
+// needed for getting access tokens
+type Credentials struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+type GCP struct {
+	credentials Credentials
+	key         *rsa.PrivateKey
+	client      *http.Client
+	token       string
+	expiry      time.Time
+
+	// Progress is called while images are uploaded to Cloud Storage
+	Progress progress.Func
+	// Limiter limits the bandwidth of uploads, unless it is nil
+	Limiter *throttle.Limiter
+
+	// The endpoints of the APIs and the parameters of uploads, which are
+	// only changed by tests
+	storageURL         string
+	computeURL         string
+	resourceManagerURL string
+	metadataURL        string
+	chunkSize          int64
+	retryDelay         time.Duration
+}
+
+// New returns a client authenticated with the JSON key of a service account,
+// as downloaded from the Google Cloud console. Without a key, the client is
+// authenticated as the service account of the Compute Engine instance it
+// runs on, which is looked up on the metadata server of the instance.
+func New(credentials []byte) (*GCP, error) {
+	g := &GCP{
+		client:     &http.Client{},
+		storageURL: "https://storage.googleapis.com",
+		computeURL: "https://compute.googleapis.com/compute/v1",
+		chunkSize:  chunkSize,
+		retryDelay: 5 * time.Second,
+
+		resourceManagerURL: "https://cloudresourcemanager.googleapis.com/v1",
+		metadataURL:        "http://metadata.google.internal/computeMetadata/v1",
+	}
+	if len(credentials) == 0 {
+		return g, nil
+	}
+
+	var c Credentials
+	err := json.Unmarshal(credentials, &c)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse GCP credentials: %v", err)
+	}
+	if c.ClientEmail == "" || c.PrivateKey == "" {
+		return nil, errors.New("GCP credentials must be the key of a service account")
+	}
+	if c.TokenURI == "" {
+		c.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	block, _ := pem.Decode([]byte(c.PrivateKey))
+	if block == nil {
+		return nil, errors.New("the private key of the GCP credentials is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse the private key of the GCP credentials: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the private key of the GCP credentials is not an RSA key")
+	}
+
+	g.credentials = c
+	g.key = key
+	return g, nil
+}
+
+// ProjectID returns the project of the service account, or of the instance
+// when the client is authenticated with the metadata server
+func (g *GCP) ProjectID(ctx context.Context) (string, error) {
+	if g.credentials.ProjectID == "" && g.key == nil {
+		project, err := g.metadata(ctx, "/project/project-id")
+		if err != nil {
+			return "", fmt.Errorf("cannot get the GCP project of the instance: %v", err)
+		}
+		g.credentials.ProjectID = string(project)
+	}
+	return g.credentials.ProjectID, nil
+}
+
+// metadata returns a value from the metadata server of the Compute Engine
+// instance
+func (g *GCP) metadata(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", g.metadataURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(req, resp)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+type tokenReply struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// accessToken returns an OAuth2 access token of the service account. It is
+// obtained with a signed JWT, or from the metadata server without a key, and
+// reused until shortly before it expires.
+func (g *GCP) accessToken(ctx context.Context) (string, error) {
+	if g.token != "" && time.Now().Before(g.expiry) {
+		return g.token, nil
+	}
+
+	now := time.Now()
+	var reply tokenReply
+	var err error
+	if g.key != nil {
+		err = g.requestToken(ctx, now, &reply)
+	} else {
+		var data []byte
+		data, err = g.metadata(ctx, "/instance/service-accounts/default/token")
+		if err == nil {
+			err = json.Unmarshal(data, &reply)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot get a GCP access token: %v", err)
+	}
+
+	g.token = reply.AccessToken
+	g.expiry = now.Add(time.Duration(reply.ExpiresIn)*time.Second - time.Minute)
+	return g.token, nil
+}
+
+// requestToken exchanges a JWT signed with the key of the service account
+// for an access token
+func (g *GCP) requestToken(ctx context.Context, now time.Time, reply *tokenReply) error {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   g.credentials.ClientEmail,
+		"scope": scope,
+		"aud":   g.credentials.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, g.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return fmt.Errorf("cannot sign the GCP token request: %v", err)
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", g.credentials.TokenURI, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return g.do(req, reply)
+}
+
+// request sends an authenticated request to one of the APIs and decodes its
+// JSON reply into reply, unless it is nil
+func (g *GCP) request(ctx context.Context, method, url string, body io.Reader, contentType string, reply interface{}) error {
+	token, err := g.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return g.do(req, reply)
+}
+
+func (g *GCP) do(req *http.Request, reply interface{}) error {
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return responseError(req, resp)
+	}
+	if reply == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(reply)
+}
+
+// responseError returns the error of a response whose status is not 2xx
+func responseError(req *http.Request, resp *http.Response) error {
+	var apiError struct {
+		Error json.RawMessage `json:"error"`
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	if json.Unmarshal(data, &apiError) == nil && len(apiError.Error) > 0 {
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, apiError.Error)
+	}
+	return fmt.Errorf("%s %s: %s", req.Method, req.URL.Path, resp.Status)
+}
+
+// Upload uploads a file to a Cloud Storage bucket as the given object. The
+// file is sent in chunks with a resumable upload, so that a failed chunk is
+// resent from where Cloud Storage lost it instead of failing the upload.
+func (g *GCP) Upload(ctx context.Context, filename, bucket, object string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	log.Printf("[GCP] 🚀 Uploading image to gs://%s/%s", bucket, object)
+	session, err := g.startUpload(ctx, bucket, object, size)
+	if err != nil {
+		return fmt.Errorf("cannot upload %s to gs://%s/%s: %v", filename, bucket, object, err)
+	}
+
+	var offset int64
+	failures := 0
+	for offset < size {
+		end := offset + g.chunkSize
+		if end > size {
+			end = size
+		}
+		committed, err := g.uploadChunk(ctx, session, g.Limiter.NewReader(io.NewSectionReader(f, offset, end-offset)), offset, end, size)
+		if err != nil {
+			failures++
+			if _, ok := err.(retryableError); !ok || failures > maxRetries {
+				return fmt.Errorf("cannot upload %s to gs://%s/%s: %v", filename, bucket, object, err)
+			}
+			log.Printf("[GCP] Uploading a chunk failed, retrying: %v", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(failures) * g.retryDelay):
+			}
+			// Cloud Storage may have received a part of the chunk
+			committed, err = g.uploadChunk(ctx, session, nil, 0, 0, size)
+			if err != nil {
+				if _, ok := err.(retryableError); !ok {
+					return fmt.Errorf("cannot upload %s to gs://%s/%s: %v", filename, bucket, object, err)
+				}
+				continue
+			}
+		} else {
+			failures = 0
+		}
+		offset = committed
+		if g.Progress != nil {
+			g.Progress(offset, size)
+		}
+	}
+	return nil
+}
+
+// retryableError is an error of a request which may succeed when it is sent
+// again: a network error or a status which indicates a temporary problem
+type retryableError struct {
+	error
+}
+
+// startUpload starts a resumable upload of an object and returns the URL of
+// the upload session
+func (g *GCP) startUpload(ctx context.Context, bucket, object string, size int64) (string, error) {
+	token, err := g.accessToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", g.storageURL, url.PathEscape(bucket), url.Values{
+		"uploadType": {"resumable"},
+		"name":       {object},
+	}.Encode())
+	req, err := http.NewRequestWithContext(ctx, "POST", u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Upload-Content-Type", "application/octet-stream")
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", responseError(req, resp)
+	}
+	session := resp.Header.Get("Location")
+	if session == "" {
+		return "", errors.New("cloud storage did not return an upload session")
+	}
+	return session, nil
+}
+
+// uploadChunk sends the bytes from offset to end of an upload of size bytes
+// and returns how many bytes Cloud Storage has received. Without a chunk, it
+// only asks for the latter.
+func (g *GCP) uploadChunk(ctx context.Context, session string, chunk io.Reader, offset, end, size int64) (int64, error) {
+	token, err := g.accessToken(ctx)
+	if err != nil {
+		return 0, retryableError{err}
+	}
+	req, err := http.NewRequestWithContext(ctx, "PUT", session, chunk)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if chunk != nil {
+		req.ContentLength = end - offset
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, end-1, size))
+	} else {
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+	}
+
+	// Cloud Storage acknowledges chunks with a 308 response without a
+	// Location header, which the redirect handling of http.Client rejects
+	transport := g.client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return 0, retryableError{err}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated:
+		return size, nil
+	case resp.StatusCode == http.StatusPermanentRedirect:
+		// The Range header is missing until the first byte is received
+		r := resp.Header.Get("Range")
+		if r == "" {
+			return 0, nil
+		}
+		last, err := strconv.ParseInt(r[strings.LastIndex(r, "-")+1:], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid range of the upload: %s", r)
+		}
+		return last + 1, nil
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return 0, retryableError{responseError(req, resp)}
+	default:
+		return 0, responseError(req, resp)
+	}
+}
+
+// ImageImport describes a Compute Engine image to be created from a GCE
+// tarball, a gzip compressed tar archive of a disk.raw file, in Cloud Storage
+type ImageImport struct {
+	Project string
+	Name    string
+	Bucket  string
+	Object  string
+	// Family groups the images of an operating system, so that instances
+	// can be created from the latest image of the family
+	Family string
+	Labels map[string]string
+	// GuestOSFeatures are enabled in addition to VIRTIO_SCSI_MULTIQUEUE,
+	// e.g. UEFI_COMPATIBLE, which shielded VMs require, or SEV_CAPABLE
+	GuestOSFeatures []string
+	// ShieldedVM marks the image as booting with UEFI, so that shielded VMs
+	// can be created from it. The image must have an EFI system partition.
+	ShieldedVM bool
+	// ConfidentialVM marks the image as supporting AMD SEV, so that
+	// confidential VMs can be created from it. It implies ShieldedVM.
+	ConfidentialVM bool
+	// Licenses are the URLs of the licenses of the image, such as
+	// https://compute.googleapis.com/compute/v1/projects/project/global/licenses/license
+	Licenses []string
+}
+
+type operation struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	SelfLink   string `json:"selfLink"`
+	TargetLink string `json:"targetLink"`
+	Error      *struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"error"`
+}
+
+// The guest OS features of images which shielded and confidential VMs need
+const (
+	UEFICompatible = "UEFI_COMPATIBLE"
+	SEVCapable     = "SEV_CAPABLE"
+)
+
+// knownGuestOSFeatures are the guest OS features Compute Engine accepts for
+// Linux images
+var knownGuestOSFeatures = []string{
+	"VIRTIO_SCSI_MULTIQUEUE",
+	UEFICompatible,
+	SEVCapable,
+	"GVNIC",
+	"MULTI_IP_SUBNET",
+	"SECURE_BOOT",
+}
+
+// CheckGuestOSFeatures returns an error if one of the features is not a
+// guest OS feature of Compute Engine
+func CheckGuestOSFeatures(features []string) error {
+	for _, feature := range features {
+		known := false
+		for _, k := range knownGuestOSFeatures {
+			if feature == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown guest OS feature %s, expected one of %s", feature, strings.Join(knownGuestOSFeatures, ", "))
+		}
+	}
+	return nil
+}
+
+// guestOSFeatures returns the guest OS features of an image, which always
+// include VIRTIO_SCSI_MULTIQUEUE
+func guestOSFeatures(image ImageImport) []map[string]string {
+	features := append([]string{"VIRTIO_SCSI_MULTIQUEUE"}, image.GuestOSFeatures...)
+	if image.ShieldedVM || image.ConfidentialVM {
+		features = append(features, UEFICompatible)
+	}
+	if image.ConfidentialVM {
+		features = append(features, SEVCapable)
+	}
+
+	var types []map[string]string
+	seen := make(map[string]bool)
+	for _, feature := range features {
+		if !seen[feature] {
+			seen[feature] = true
+			types = append(types, map[string]string{"type": feature})
+		}
+	}
+	return types
+}
+
+// ImportImage creates a Compute Engine image from a GCE tarball uploaded to
+// Cloud Storage and returns its URL once the image is ready
+func (g *GCP) ImportImage(ctx context.Context, image ImageImport) (string, error) {
+	err := CheckGuestOSFeatures(image.GuestOSFeatures)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"name":   image.Name,
+		"family": image.Family,
+		"labels": image.Labels,
+		"rawDisk": map[string]string{
+			"source": fmt.Sprintf("%s/%s/%s", g.storageURL, image.Bucket, image.Object),
+		},
+		"guestOsFeatures": guestOSFeatures(image),
+		"licenses":        image.Licenses,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("[GCP] 📥 Importing image %s into project %s", image.Name, image.Project)
+	var op operation
+	u := fmt.Sprintf("%s/projects/%s/global/images", g.computeURL, url.PathEscape(image.Project))
+	err = g.request(ctx, "POST", u, bytes.NewReader(body), "application/json", &op)
+	if err != nil {
+		return "", fmt.Errorf("cannot import image %s: %v", image.Name, err)
+	}
+
+	for op.Status != "DONE" {
+		// the wait method returns when the operation is done or after
+		// at most two minutes
+		u := fmt.Sprintf("%s/projects/%s/global/operations/%s/wait", g.computeURL, url.PathEscape(image.Project), url.PathEscape(op.Name))
+		err = g.request(ctx, "POST", u, nil, "", &op)
+		if err != nil {
+			return "", fmt.Errorf("waiting for the import of image %s failed: %v", image.Name, err)
+		}
+	}
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		e := op.Error.Errors[0]
+		return "", fmt.Errorf("importing image %s failed: %s: %s", image.Name, e.Code, e.Message)
+	}
+
+	log.Printf("[GCP] 🎉 Image %s is ready", image.Name)
+	return op.TargetLink, nil
+}
+
+// The role which allows members to create instances and disks from an image
+const imageUserRole = "roles/compute.imageUser"
+
+type binding struct {
+	Role    string   `json:"role"`
+	Members []string `json:"members"`
+}
+
+// ShareImage allows IAM members, such as user:alice@example.com or
+// serviceAccount:builder@project.iam.gserviceaccount.com, to use an image
+func (g *GCP) ShareImage(ctx context.Context, project, name string, members []string) error {
+	u := fmt.Sprintf("%s/projects/%s/global/images/%s", g.computeURL, url.PathEscape(project), url.PathEscape(name))
+
+	// the policy is read first, so that existing bindings are kept
+	var policy struct {
+		Bindings []binding `json:"bindings"`
+		Etag     string    `json:"etag"`
+	}
+	err := g.request(ctx, "GET", u+"/getIamPolicy", nil, "", &policy)
+	if err != nil {
+		return fmt.Errorf("cannot get the IAM policy of image %s: %v", name, err)
+	}
+
+	found := false
+	for i := range policy.Bindings {
+		if policy.Bindings[i].Role == imageUserRole {
+			policy.Bindings[i].Members = append(policy.Bindings[i].Members, members...)
+			found = true
+		}
+	}
+	if !found {
+		policy.Bindings = append(policy.Bindings, binding{imageUserRole, members})
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"policy": policy})
+	if err != nil {
+		return err
+	}
+	log.Printf("[GCP] 🤝 Sharing image %s with %v", name, members)
+	err = g.request(ctx, "POST", u+"/setIamPolicy", bytes.NewReader(body), "application/json", nil)
+	if err != nil {
+		return fmt.Errorf("cannot share image %s: %v", name, err)
+	}
+	return nil
+}
+
+// CheckPermissions verifies that the service account may upload images to
+// the bucket and create images in the project, and share them if share is
+// set, without doing any of it
+func (g *GCP) CheckPermissions(ctx context.Context, project, bucket string, share bool) error {
+	bucketPermissions := []string{"storage.objects.create"}
+	query := url.Values{"permissions": bucketPermissions}
+	u := fmt.Sprintf("%s/storage/v1/b/%s/iam/testPermissions?%s", g.storageURL, url.PathEscape(bucket), query.Encode())
+	var reply struct {
+		Permissions []string `json:"permissions"`
+	}
+	err := g.request(ctx, "GET", u, nil, "", &reply)
+	if err != nil {
+		return fmt.Errorf("cannot check the permissions on bucket %s: %v", bucket, err)
+	}
+	if missing := missingPermissions(bucketPermissions, reply.Permissions); len(missing) > 0 {
+		return fmt.Errorf("missing permissions on bucket %s: %s", bucket, strings.Join(missing, ", "))
+	}
+
+	projectPermissions := []string{"compute.images.create"}
+	if share {
+		projectPermissions = append(projectPermissions, "compute.images.getIamPolicy", "compute.images.setIamPolicy")
+	}
+	body, err := json.Marshal(map[string][]string{"permissions": projectPermissions})
+	if err != nil {
+		return err
+	}
+	reply.Permissions = nil
+	u = fmt.Sprintf("%s/projects/%s:testIamPermissions", g.resourceManagerURL, url.PathEscape(project))
+	err = g.request(ctx, "POST", u, bytes.NewReader(body), "application/json", &reply)
+	if err != nil {
+		return fmt.Errorf("cannot check the permissions in project %s: %v", project, err)
+	}
+	if missing := missingPermissions(projectPermissions, reply.Permissions); len(missing) > 0 {
+		return fmt.Errorf("missing permissions in project %s: %s", project, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// missingPermissions returns the permissions which are wanted, but not
+// granted
+func missingPermissions(wanted, granted []string) []string {
+	var missing []string
+	for _, w := range wanted {
+		found := false
+		for _, g := range granted {
+			if g == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, w)
+		}
+	}
+	return missing
+}
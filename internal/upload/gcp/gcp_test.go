@@ -0,0 +1,356 @@
+package gcp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCredentials(t *testing.T, tokenURI string) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	credentials, err := json.Marshal(Credentials{
+		ProjectID:   "project",
+		ClientEmail: "composer@project.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:    tokenURI,
+	})
+	require.NoError(t, err)
+	return credentials
+}
+
+func TestUploadAndImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcp-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	image := filepath.Join(dir, "image.tar.gz")
+	require.NoError(t, ioutil.WriteFile(image, []byte("disk.raw"), 0600))
+
+	var uploaded []byte
+	var imported map[string]interface{}
+	tokens := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.Form.Get("grant_type"))
+			tokens++
+			_, _ = w.Write([]byte(`{"access_token": "token", "expires_in": 3600}`))
+			return
+		}
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/upload/storage/v1/b/bucket/o":
+			assert.Equal(t, "image.tar.gz", r.URL.Query().Get("name"))
+			assert.Equal(t, "resumable", r.URL.Query().Get("uploadType"))
+			w.Header().Set("Location", "http://"+r.Host+"/upload/session")
+		case "/upload/session":
+			assert.Equal(t, "bytes 0-7/8", r.Header.Get("Content-Range"))
+			uploaded, _ = ioutil.ReadAll(r.Body)
+			_, _ = w.Write([]byte(`{}`))
+		case "/compute/v1/projects/project/global/images":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&imported))
+			_, _ = w.Write([]byte(`{"name": "op", "status": "RUNNING"}`))
+		case "/compute/v1/projects/project/global/operations/op/wait":
+			_, _ = w.Write([]byte(`{"name": "op", "status": "DONE", "targetLink": "https://compute/projects/project/global/images/fedora"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	g, err := New(testCredentials(t, server.URL+"/token"))
+	require.NoError(t, err)
+	g.storageURL = server.URL
+	g.computeURL = server.URL + "/compute/v1"
+	project, err := g.ProjectID(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "project", project)
+
+	err = g.Upload(context.Background(), image, "bucket", "image.tar.gz")
+	require.NoError(t, err)
+	assert.Equal(t, "disk.raw", string(uploaded))
+
+	link, err := g.ImportImage(context.Background(), ImageImport{
+		Project: "project",
+		Name:    "fedora",
+		Bucket:  "bucket",
+		Object:  "image.tar.gz",
+		Family:  "fedora-33",
+		Labels:  map[string]string{"build": "1"},
+
+		GuestOSFeatures: []string{"UEFI_COMPATIBLE", "VIRTIO_SCSI_MULTIQUEUE"},
+		Licenses:        []string{"https://compute/projects/project/global/licenses/fedora"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://compute/projects/project/global/images/fedora", link)
+	assert.Equal(t, "fedora-33", imported["family"])
+	assert.Equal(t, map[string]interface{}{"build": "1"}, imported["labels"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"type": "VIRTIO_SCSI_MULTIQUEUE"},
+		map[string]interface{}{"type": "UEFI_COMPATIBLE"},
+	}, imported["guestOsFeatures"])
+	assert.Equal(t, []interface{}{"https://compute/projects/project/global/licenses/fedora"}, imported["licenses"])
+	assert.Equal(t, map[string]interface{}{"source": server.URL + "/bucket/image.tar.gz"}, imported["rawDisk"])
+	assert.Equal(t, 1, tokens, "the access token must be reused")
+
+	_, err = g.ImportImage(context.Background(), ImageImport{Project: "other", Name: "fedora"})
+	assert.EqualError(t, err, "cannot import image fedora: POST /compute/v1/projects/other/global/images: 404 Not Found")
+
+	_, err = New([]byte(`{"type": "authorized_user"}`))
+	assert.EqualError(t, err, "GCP credentials must be the key of a service account")
+}
+
+func TestGuestOSFeatures(t *testing.T) {
+	assert.Equal(t, []map[string]string{
+		{"type": "VIRTIO_SCSI_MULTIQUEUE"},
+	}, guestOSFeatures(ImageImport{}))
+	assert.Equal(t, []map[string]string{
+		{"type": "VIRTIO_SCSI_MULTIQUEUE"},
+		{"type": "UEFI_COMPATIBLE"},
+	}, guestOSFeatures(ImageImport{GuestOSFeatures: []string{"UEFI_COMPATIBLE"}, ShieldedVM: true}))
+	assert.Equal(t, []map[string]string{
+		{"type": "VIRTIO_SCSI_MULTIQUEUE"},
+		{"type": "GVNIC"},
+		{"type": "UEFI_COMPATIBLE"},
+		{"type": "SEV_CAPABLE"},
+	}, guestOSFeatures(ImageImport{GuestOSFeatures: []string{"GVNIC"}, ConfidentialVM: true}))
+
+	assert.NoError(t, CheckGuestOSFeatures([]string{"SEV_CAPABLE", "GVNIC"}))
+	assert.EqualError(t, CheckGuestOSFeatures([]string{"UEFI"}), "unknown guest OS feature UEFI, expected one of "+
+		"VIRTIO_SCSI_MULTIQUEUE, UEFI_COMPATIBLE, SEV_CAPABLE, GVNIC, MULTI_IP_SUBNET, SECURE_BOOT")
+}
+
+func TestResumableUpload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcp-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	image := filepath.Join(dir, "image.tar.gz")
+	require.NoError(t, ioutil.WriteFile(image, []byte("0123456789"), 0600))
+
+	var ranges []string
+	var uploaded []byte
+	failed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			_, _ = w.Write([]byte(`{"access_token": "token", "expires_in": 3600}`))
+		case "/upload/storage/v1/b/bucket/o":
+			w.Header().Set("Location", "http://"+r.Host+"/upload/session")
+		case "/upload/session":
+			contentRange := r.Header.Get("Content-Range")
+			ranges = append(ranges, contentRange)
+			chunk, _ := ioutil.ReadAll(r.Body)
+			switch contentRange {
+			case "bytes 4-7/10":
+				if !failed {
+					// only the first two bytes of the chunk arrive
+					failed = true
+					uploaded = append(uploaded, chunk[:2]...)
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+			case "bytes */10":
+				w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(uploaded)-1))
+				w.WriteHeader(http.StatusPermanentRedirect)
+				return
+			}
+			uploaded = append(uploaded, chunk...)
+			if len(uploaded) < 10 {
+				w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(uploaded)-1))
+				w.WriteHeader(http.StatusPermanentRedirect)
+				return
+			}
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	g, err := New(testCredentials(t, server.URL+"/token"))
+	require.NoError(t, err)
+	g.storageURL = server.URL
+	g.chunkSize = 4
+	g.retryDelay = 0
+	var reported []int64
+	g.Progress = func(transferred, total int64) {
+		assert.Equal(t, int64(10), total)
+		reported = append(reported, transferred)
+	}
+
+	err = g.Upload(context.Background(), image, "bucket", "image.tar.gz")
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(uploaded))
+	assert.Equal(t, []string{"bytes 0-3/10", "bytes 4-7/10", "bytes */10", "bytes 6-9/10"}, ranges)
+	assert.Equal(t, []int64{4, 6, 10}, reported)
+
+	g.chunkSize = 16
+	ranges = nil
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/upload/storage/v1/b/bucket/o":
+			w.Header().Set("Location", "http://"+r.Host+"/upload/session")
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	err = g.Upload(context.Background(), image, "bucket", "image.tar.gz")
+	assert.EqualError(t, err, "cannot upload "+image+" to gs://bucket/image.tar.gz: PUT /upload/session: 503 Service Unavailable")
+
+	// an expired session is not retried
+	ranges = nil
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/upload/storage/v1/b/bucket/o":
+			w.Header().Set("Location", "http://"+r.Host+"/upload/session")
+		default:
+			ranges = append(ranges, r.Header.Get("Content-Range"))
+			if r.Header.Get("Content-Range") == "bytes */10" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	err = g.Upload(context.Background(), image, "bucket", "image.tar.gz")
+	assert.EqualError(t, err, "cannot upload "+image+" to gs://bucket/image.tar.gz: PUT /upload/session: 404 Not Found")
+	assert.Equal(t, []string{"bytes 0-9/10", "bytes */10"}, ranges)
+}
+
+func TestShareImage(t *testing.T) {
+	var policy map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /token":
+			_, _ = w.Write([]byte(`{"access_token": "token", "expires_in": 3600}`))
+		case "GET /compute/v1/projects/project/global/images/fedora/getIamPolicy":
+			_, _ = w.Write([]byte(`{"bindings": [{"role": "roles/owner", "members": ["user:owner@example.com"]}], "etag": "BwW="}`))
+		case "POST /compute/v1/projects/project/global/images/fedora/setIamPolicy":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&policy))
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	g, err := New(testCredentials(t, server.URL+"/token"))
+	require.NoError(t, err)
+	g.computeURL = server.URL + "/compute/v1"
+
+	err = g.ShareImage(context.Background(), "project", "fedora", []string{"user:alice@example.com", "group:qa@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"policy": map[string]interface{}{
+			"bindings": []interface{}{
+				map[string]interface{}{"role": "roles/owner", "members": []interface{}{"user:owner@example.com"}},
+				map[string]interface{}{"role": "roles/compute.imageUser", "members": []interface{}{"user:alice@example.com", "group:qa@example.com"}},
+			},
+			"etag": "BwW=",
+		},
+	}, policy)
+
+	err = g.ShareImage(context.Background(), "other", "fedora", nil)
+	assert.EqualError(t, err, "cannot get the IAM policy of image fedora: GET /compute/v1/projects/other/global/images/fedora/getIamPolicy: 404 Not Found")
+}
+
+func TestCheckPermissions(t *testing.T) {
+	var projectPermissions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /token":
+			_, _ = w.Write([]byte(`{"access_token": "token", "expires_in": 3600}`))
+		case "GET /storage/v1/b/bucket/iam/testPermissions":
+			assert.Equal(t, []string{"storage.objects.create"}, r.URL.Query()["permissions"])
+			_, _ = w.Write([]byte(`{"permissions": ["storage.objects.create"]}`))
+		case "GET /storage/v1/b/readonly/iam/testPermissions":
+			_, _ = w.Write([]byte(`{}`))
+		case "POST /v1/projects/project:testIamPermissions":
+			var body struct {
+				Permissions []string `json:"permissions"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			projectPermissions = body.Permissions
+			_, _ = w.Write([]byte(`{"permissions": ["compute.images.create"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	g, err := New(testCredentials(t, server.URL+"/token"))
+	require.NoError(t, err)
+	g.storageURL = server.URL
+	g.resourceManagerURL = server.URL + "/v1"
+
+	err = g.CheckPermissions(context.Background(), "project", "bucket", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"compute.images.create"}, projectPermissions)
+
+	err = g.CheckPermissions(context.Background(), "project", "bucket", true)
+	assert.EqualError(t, err, "missing permissions in project project: compute.images.getIamPolicy, compute.images.setIamPolicy")
+
+	err = g.CheckPermissions(context.Background(), "project", "readonly", false)
+	assert.EqualError(t, err, "missing permissions on bucket readonly: storage.objects.create")
+}
+
+func TestMetadataCredentials(t *testing.T) {
+	tokens := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/computeMetadata/") {
+			assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+		} else {
+			assert.Equal(t, "Bearer instance-token", r.Header.Get("Authorization"))
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /computeMetadata/v1/instance/service-accounts/default/token":
+			tokens++
+			_, _ = w.Write([]byte(`{"access_token": "instance-token", "expires_in": 3600, "token_type": "Bearer"}`))
+		case "GET /computeMetadata/v1/project/project-id":
+			_, _ = w.Write([]byte(`instance-project`))
+		case "GET /storage/v1/b/bucket/iam/testPermissions":
+			_, _ = w.Write([]byte(`{"permissions": ["storage.objects.create"]}`))
+		case "POST /v1/projects/instance-project:testIamPermissions":
+			_, _ = w.Write([]byte(`{"permissions": ["compute.images.create"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	g, err := New(nil)
+	require.NoError(t, err)
+	g.storageURL = server.URL
+	g.resourceManagerURL = server.URL + "/v1"
+	g.metadataURL = server.URL + "/computeMetadata/v1"
+
+	project, err := g.ProjectID(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "instance-project", project)
+
+	err = g.CheckPermissions(context.Background(), project, "bucket", false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, tokens, "the access token must be reused")
+
+	g.metadataURL = server.URL + "/computeMetadata/missing"
+	g.token = ""
+	err = g.CheckPermissions(context.Background(), project, "bucket", false)
+	assert.EqualError(t, err, "cannot check the permissions on bucket bucket: cannot get a GCP access token: GET /computeMetadata/missing/instance/service-accounts/default/token: 404 Not Found")
+}
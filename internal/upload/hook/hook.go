@@ -0,0 +1,147 @@
+// Package hook notifies webhooks and runs commands once images are built and
+// uploaded, so that pipelines rolling out the images can be triggered.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/osbuild/osbuild-composer/internal/target"
+)
+
+// Payload is the metadata of a built image, which is sent to webhooks and
+// passed to commands as JSON
+type Payload struct {
+	JobID    uuid.UUID `json:"job_id"`
+	Filename string    `json:"filename"`
+	Size     int64     `json:"size"`
+	SHA256   string    `json:"sha256"`
+	// Results of the other targets of the job, like the AMI the image was
+	// registered as
+	Results []*target.TargetResult `json:"results"`
+}
+
+// NewPayload returns the payload of the image at path
+func NewPayload(jobID uuid.UUID, path string, results []*target.TargetResult) (*Payload, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot hash the image: %v", err)
+	}
+
+	if results == nil {
+		results = []*target.TargetResult{}
+	}
+	return &Payload{
+		JobID:    jobID,
+		Filename: filepath.Base(path),
+		Size:     size,
+		SHA256:   hex.EncodeToString(hash.Sum(nil)),
+		Results:  results,
+	}, nil
+}
+
+// Webhook sends payloads to a URL in POST requests
+type Webhook struct {
+	URL string
+	// Headers are added to the requests, e.g. for authentication
+	Headers map[string]string
+
+	// Only changed by tests
+	client *http.Client
+}
+
+// NewWebhook returns a webhook of url, whose requests time out after a
+// minute
+func NewWebhook(url string, headers map[string]string) *Webhook {
+	return &Webhook{
+		URL:     url,
+		Headers: headers,
+		client:  &http.Client{Timeout: time.Minute},
+	}
+}
+
+// Send posts the payload to the webhook. Responses with other status codes
+// than 2xx are errors.
+func (w *Webhook) Send(ctx context.Context, payload *Payload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", w.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.Headers {
+		req.Header.Set(name, value)
+	}
+
+	log.Printf("[hook] 📣 Sending the metadata of %s to %s", payload.Filename, req.URL.Host)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot send the webhook: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+// maxErrorOutput limits how much of the output of a failed command is
+// included in its error
+const maxErrorOutput = 1000
+
+// Run runs command with the payload as JSON on its standard input. Its
+// output is logged and, if it fails, the end of the output is returned in
+// the error.
+func Run(ctx context.Context, command []string, payload *Payload) error {
+	if len(command) == 0 {
+		return fmt.Errorf("empty hook command")
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[hook] 🏃 Running %s for %s", command[0], payload.Filename)
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = io.MultiWriter(&output, log.Writer())
+	cmd.Stderr = cmd.Stdout
+	err = cmd.Run()
+	if err != nil {
+		out, _ := ioutil.ReadAll(&output)
+		if len(out) > maxErrorOutput {
+			out = out[len(out)-maxErrorOutput:]
+		}
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("hook %s failed: %v: %s", command[0], err, msg)
+		}
+		return fmt.Errorf("hook %s failed: %v", command[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/osbuild-composer/internal/target"
+)
+
+func testPayload(t *testing.T, dir string) *Payload {
+	image := filepath.Join(dir, "disk.qcow2")
+	require.NoError(t, ioutil.WriteFile(image, []byte("disk"), 0600))
+
+	results := []*target.TargetResult{
+		target.NewAWSTargetResult(&target.AWSTargetResultOptions{Ami: "ami-0c830793775595d4b", Region: "eu-central-1"}),
+	}
+	payload, err := NewPayload(uuid.MustParse("10000000-0000-0000-0000-000000000000"), image, results)
+	require.NoError(t, err)
+	return payload
+}
+
+func TestNewPayload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hook-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	data, err := json.Marshal(testPayload(t, dir))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"job_id": "10000000-0000-0000-0000-000000000000",
+		"filename": "disk.qcow2",
+		"size": 4,
+		"sha256": "1044dec7206e8d7c9fbb4ae8f766668406d2567fc7fc1a160a9d4700fcf8f8e9",
+		"results": [{"name": "org.osbuild.aws", "options": {"ami": "ami-0c830793775595d4b", "region": "eu-central-1"}}]
+	}`, string(data))
+}
+
+func TestWebhook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hook-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	payload := testPayload(t, dir)
+
+	var received Payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	err = NewWebhook(server.URL, map[string]string{"Authorization": "Bearer token"}).Send(context.Background(), payload)
+	require.NoError(t, err)
+	assert.Equal(t, payload.SHA256, received.SHA256)
+	assert.Equal(t, payload.JobID, received.JobID)
+
+	err = NewWebhook(server.URL, nil).Send(context.Background(), payload)
+	assert.EqualError(t, err, "webhook "+server.Listener.Addr().String()+" returned 401 Unauthorized")
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hook-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	payload := testPayload(t, dir)
+
+	output := filepath.Join(dir, "payload.json")
+	err = Run(context.Background(), []string{"sh", "-c", "cat > " + output}, payload)
+	require.NoError(t, err)
+	data, err := ioutil.ReadFile(output)
+	require.NoError(t, err)
+	var received Payload
+	require.NoError(t, json.Unmarshal(data, &received))
+	assert.Equal(t, payload.Filename, received.Filename)
+
+	err = Run(context.Background(), []string{"sh", "-c", "echo rollout failed; exit 3"}, payload)
+	assert.EqualError(t, err, "hook sh failed: exit status 3: rollout failed")
+
+	err = Run(context.Background(), nil, payload)
+	assert.Error(t, err)
+}
@@ -0,0 +1,330 @@
+// Package libvirt imports images into the storage pools of libvirt hosts and
+// defines domains booting them. It runs virsh, which connects to local and
+// remote hosts alike, for example qemu:///system or
+// qemu+ssh://root@lab.example.com/system.
+//
+// The URIs of hosts and the domain templates must come from the
+// configuration of the worker, never from requests: some transports of
+// libvirt run arbitrary commands, and domains can be given the disks and
+// devices of the host they run on.
+package libvirt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/osbuild/osbuild-composer/internal/upload/progress"
+	"github.com/osbuild/osbuild-composer/internal/upload/throttle"
+)
+
+type Libvirt struct {
+	uri string
+
+	// Progress is called while images are uploaded into storage pools
+	Progress progress.Func
+	// Limiter limits the bandwidth of uploads, unless it is nil
+	Limiter *throttle.Limiter
+
+	// The virsh command, which is only changed by tests
+	virsh string
+}
+
+// transports are the transports of libvirt URIs which connect to a daemon
+// without running a command given in the URI. The ext transport runs any
+// command, so it is not allowed.
+var transports = map[string]bool{
+	"":        true,
+	"unix":    true,
+	"tls":     true,
+	"tcp":     true,
+	"ssh":     true,
+	"libssh":  true,
+	"libssh2": true,
+}
+
+// ValidateURI returns an error unless uri is a libvirt URI with one of the
+// allowed transports. URIs with query parameters are rejected, because they
+// choose the commands, sockets and keys used to connect.
+func ValidateURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("invalid libvirt URI %q: %v", uri, err)
+	}
+	if u.Scheme == "" {
+		return fmt.Errorf("invalid libvirt URI %q: it has no driver", uri)
+	}
+	parts := strings.SplitN(u.Scheme, "+", 2)
+	var transport string
+	if len(parts) == 2 {
+		transport = parts[1]
+	}
+	if !transports[transport] {
+		return fmt.Errorf("invalid libvirt URI %q: transport %s is not allowed", uri, transport)
+	}
+	if u.RawQuery != "" || u.ForceQuery {
+		return fmt.Errorf("invalid libvirt URI %q: query parameters are not allowed", uri)
+	}
+	return nil
+}
+
+// New returns a client of the libvirt host at uri, which must be valid
+// according to ValidateURI
+func New(uri string) (*Libvirt, error) {
+	err := ValidateURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return &Libvirt{
+		uri:   uri,
+		virsh: "virsh",
+	}, nil
+}
+
+// run runs virsh with args and returns its output. The input of virsh is
+// read from stdin, unless it is nil.
+func (l *Libvirt) run(ctx context.Context, stdin io.Reader, args ...string) (string, error) {
+	command := args[0]
+	args = append([]string{"--connect", l.uri}, args...)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, l.virsh, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("virsh %s: %s", command, msg)
+		}
+		return "", fmt.Errorf("virsh %s: %v", command, err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// imageFormat returns the format of a disk image, qcow2 or raw, and the size
+// of the disk it contains
+func imageFormat(f *os.File) (string, uint64, error) {
+	var header struct {
+		Magic             [4]byte
+		Version           uint32
+		BackingFileOffset uint64
+		BackingFileSize   uint32
+		ClusterBits       uint32
+		Size              uint64
+	}
+	err := binary.Read(f, binary.BigEndian, &header)
+	if err == nil && string(header.Magic[:]) == "QFI\xfb" {
+		return "qcow2", header.Size, nil
+	}
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", 0, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	return "raw", uint64(info.Size()), nil
+}
+
+// Volume is a volume an image was imported into
+type Volume struct {
+	Pool   string
+	Name   string
+	Format string
+	// Path is where the host stores the volume
+	Path string
+}
+
+// ImportVolume creates a volume in a storage pool and uploads the disk image
+// at filename into it. The volume is deleted again when the upload fails.
+func (l *Libvirt) ImportVolume(ctx context.Context, filename, pool, name string) (*Volume, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	format, capacity, err := imageFormat(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the image: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[libvirt] 🚀 Importing image into volume %s of pool %s", name, pool)
+	_, err = l.run(ctx, nil, "vol-create-as", pool, name, strconv.FormatUint(capacity, 10), "--format", format)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create volume %s: %v", name, err)
+	}
+
+	// the image is piped into virsh, so that the upload can report its
+	// progress and be limited like the uploads to clouds
+	reader := l.Limiter.NewReader(progress.NewReader(f, info.Size(), l.Progress))
+	_, err = l.run(ctx, reader, "vol-upload", "--pool", pool, name, "/dev/stdin")
+	if err != nil {
+		_, deleteErr := l.run(context.Background(), nil, "vol-delete", "--pool", pool, name)
+		if deleteErr != nil {
+			log.Printf("[libvirt] Cannot delete volume %s: %v", name, deleteErr)
+		}
+		return nil, fmt.Errorf("cannot upload the image into volume %s: %v", name, err)
+	}
+
+	path, err := l.run(ctx, nil, "vol-path", "--pool", pool, name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get the path of volume %s: %v", name, err)
+	}
+
+	return &Volume{
+		Pool:   pool,
+		Name:   name,
+		Format: format,
+		Path:   path,
+	}, nil
+}
+
+// DefaultDomainTemplate defines a KVM domain booting the volume from a
+// virtio disk, which is connected to a virtual network
+const DefaultDomainTemplate = `<domain type='kvm'>
+  <name>{{.Name}}</name>
+  <memory unit='MiB'>{{.Memory}}</memory>
+  <vcpu>{{.VCPUs}}</vcpu>
+  <os>
+    <type>hvm</type>
+    <boot dev='hd'/>
+  </os>
+  <features>
+    <acpi/>
+    <apic/>
+  </features>
+  <cpu mode='host-model'/>
+  <devices>
+    <disk type='volume' device='disk'>
+      <driver name='qemu' type='{{.Format}}'/>
+      <source pool='{{.Pool}}' volume='{{.Volume}}'/>
+      <target dev='vda' bus='virtio'/>
+    </disk>
+    <interface type='network'>
+      <source network='{{.Network}}'/>
+      <model type='virtio'/>
+    </interface>
+    <serial type='pty'/>
+    <console type='pty'/>
+    <rng model='virtio'>
+      <backend model='random'>/dev/urandom</backend>
+    </rng>
+  </devices>
+</domain>
+`
+
+// Domain contains the values domain templates are executed with. They are
+// escaped for XML, so that templates can use them anywhere.
+type Domain struct {
+	Name string
+	// Memory in MiB
+	Memory  uint64
+	VCPUs   int
+	Network string
+	// The volume the image was imported into
+	Pool   string
+	Volume string
+	Format string
+	Path   string
+}
+
+// ParseDomainTemplate parses the text of a domain template
+func ParseDomainTemplate(text string) (*template.Template, error) {
+	t, err := template.New("domain").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid domain template: %v", err)
+	}
+	return t, nil
+}
+
+var defaultDomainTemplate = template.Must(ParseDomainTemplate(DefaultDomainTemplate))
+
+// DomainXML executes a domain template, or DefaultDomainTemplate if it is
+// nil
+func DomainXML(t *template.Template, domain Domain) (string, error) {
+	if t == nil {
+		t = defaultDomainTemplate
+	}
+
+	escape := func(s string) string {
+		var buf bytes.Buffer
+		_ = xml.EscapeText(&buf, []byte(s))
+		return buf.String()
+	}
+	escaped := domain
+	escaped.Name = escape(domain.Name)
+	escaped.Network = escape(domain.Network)
+	escaped.Pool = escape(domain.Pool)
+	escaped.Volume = escape(domain.Volume)
+	escaped.Format = escape(domain.Format)
+	escaped.Path = escape(domain.Path)
+
+	var buf bytes.Buffer
+	err := t.Execute(&buf, escaped)
+	if err != nil {
+		return "", fmt.Errorf("cannot execute the domain template: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// DefineDomain defines a persistent domain from its XML and starts it if
+// start is set. It returns the UUID of the domain.
+func (l *Libvirt) DefineDomain(ctx context.Context, domainXML string, start bool) (string, error) {
+	f, err := ioutil.TempFile("", "osbuild-libvirt-domain-*.xml")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(domainXML)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var definition struct {
+		Name string `xml:"name"`
+	}
+	err = xml.Unmarshal([]byte(domainXML), &definition)
+	if err != nil {
+		return "", fmt.Errorf("invalid domain XML: %v", err)
+	}
+
+	log.Printf("[libvirt] 📝 Defining domain %s", definition.Name)
+	_, err = l.run(ctx, nil, "define", f.Name())
+	if err != nil {
+		return "", fmt.Errorf("cannot define domain %s: %v", definition.Name, err)
+	}
+
+	if start {
+		log.Printf("[libvirt] ▶️ Starting domain %s", definition.Name)
+		_, err = l.run(ctx, nil, "start", definition.Name)
+		if err != nil {
+			return "", fmt.Errorf("cannot start domain %s: %v", definition.Name, err)
+		}
+	}
+
+	return l.run(ctx, nil, "domuuid", definition.Name)
+}
@@ -0,0 +1,152 @@
+package libvirt
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVirsh writes a script to dir which logs its arguments, keeps what is
+// uploaded and defined, and fails for the pool "missing"
+func fakeVirsh(t *testing.T, dir string) string {
+	script := `#!/bin/sh
+echo "$@" >> "` + dir + `/log"
+while [ "$1" = "--connect" ]; do shift 2; done
+case "$1" in
+vol-create-as) if [ "$2" = missing ]; then echo "error: pool missing not found" >&2; exit 1; fi ;;
+vol-upload) cat "$5" > "` + dir + `/uploaded" ;;
+vol-path) echo "/var/lib/libvirt/images/$4" ;;
+define) cp "$2" "` + dir + `/defined.xml" ;;
+domuuid) echo "9a8f1d3e-6b1c-4b4e-9c4a-1b2f3c4d5e6f" ;;
+esac
+`
+	virsh := filepath.Join(dir, "virsh")
+	require.NoError(t, ioutil.WriteFile(virsh, []byte(script), 0700))
+	return virsh
+}
+
+func TestImportAndDefine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "libvirt-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	header := make([]byte, 32)
+	copy(header, "QFI\xfb")
+	binary.BigEndian.PutUint64(header[24:], 10*1024*1024*1024)
+	image := filepath.Join(dir, "disk.qcow2")
+	require.NoError(t, ioutil.WriteFile(image, header, 0600))
+
+	l, err := New("qemu+ssh://root@lab.example.com/system")
+	require.NoError(t, err)
+	l.virsh = fakeVirsh(t, dir)
+
+	volume, err := l.ImportVolume(context.Background(), image, "default", "fedora.qcow2")
+	require.NoError(t, err)
+	assert.Equal(t, &Volume{
+		Pool:   "default",
+		Name:   "fedora.qcow2",
+		Format: "qcow2",
+		Path:   "/var/lib/libvirt/images/fedora.qcow2",
+	}, volume)
+	uploaded, err := ioutil.ReadFile(filepath.Join(dir, "uploaded"))
+	require.NoError(t, err)
+	assert.Equal(t, header, uploaded)
+
+	domainXML, err := DomainXML(nil, Domain{
+		Name:    "fedora & friends",
+		Memory:  2048,
+		VCPUs:   2,
+		Network: "default",
+		Pool:    volume.Pool,
+		Volume:  volume.Name,
+		Format:  volume.Format,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, domainXML, "<name>fedora &amp; friends</name>")
+	assert.Contains(t, domainXML, "<source pool='default' volume='fedora.qcow2'/>")
+
+	uuid, err := l.DefineDomain(context.Background(), domainXML, true)
+	require.NoError(t, err)
+	assert.Equal(t, "9a8f1d3e-6b1c-4b4e-9c4a-1b2f3c4d5e6f", uuid)
+	defined, err := ioutil.ReadFile(filepath.Join(dir, "defined.xml"))
+	require.NoError(t, err)
+	assert.Equal(t, domainXML, string(defined))
+
+	log, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+	require.NoError(t, err)
+	commands := strings.Split(strings.TrimSpace(string(log)), "\n")
+	require.Len(t, commands, 6)
+	assert.Equal(t, "--connect qemu+ssh://root@lab.example.com/system vol-create-as default fedora.qcow2 10737418240 --format qcow2", commands[0])
+	assert.Equal(t, "--connect qemu+ssh://root@lab.example.com/system vol-upload --pool default fedora.qcow2 /dev/stdin", commands[1])
+	assert.Equal(t, "--connect qemu+ssh://root@lab.example.com/system start fedora & friends", commands[4])
+
+	_, err = l.ImportVolume(context.Background(), image, "missing", "fedora.qcow2")
+	assert.EqualError(t, err, "cannot create volume fedora.qcow2: virsh vol-create-as: error: pool missing not found")
+}
+
+func TestRawImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "libvirt-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	image := filepath.Join(dir, "disk.raw")
+	require.NoError(t, ioutil.WriteFile(image, []byte("disk"), 0600))
+
+	f, err := os.Open(image)
+	require.NoError(t, err)
+	defer f.Close()
+	format, size, err := imageFormat(f)
+	require.NoError(t, err)
+	assert.Equal(t, "raw", format)
+	assert.Equal(t, uint64(4), size)
+}
+
+func TestDomainXML(t *testing.T) {
+	tmpl, err := ParseDomainTemplate("<domain><name>{{.Name}}</name><disk>{{.Path}}</disk></domain>")
+	require.NoError(t, err)
+	domainXML, err := DomainXML(tmpl, Domain{Name: "test", Path: "/images/<disk>"})
+	require.NoError(t, err)
+	assert.Equal(t, "<domain><name>test</name><disk>/images/&lt;disk&gt;</disk></domain>", domainXML)
+
+	tmpl, err = ParseDomainTemplate("{{.Missing}}")
+	require.NoError(t, err)
+	_, err = DomainXML(tmpl, Domain{})
+	assert.Error(t, err)
+
+	_, err = ParseDomainTemplate("{{.Name")
+	assert.Error(t, err)
+}
+
+func TestValidateURI(t *testing.T) {
+	for _, uri := range []string{
+		"qemu:///system",
+		"qemu+unix:///session",
+		"qemu+ssh://root@lab.example.com/system",
+		"qemu+libssh2://lab.example.com:2222/system",
+		"qemu+tls://lab.example.com/system",
+	} {
+		assert.NoErrorf(t, ValidateURI(uri), uri)
+	}
+
+	for uri, msg := range map[string]string{
+		"":                   "it has no driver",
+		"/system":            "it has no driver",
+		"qemu+ext:///system": "transport ext is not allowed",
+		"qemu+ssh://lab.example.com/system?command=sh": "query parameters are not allowed",
+		"qemu+ssh://lab.example.com/system?netcat=sh":  "query parameters are not allowed",
+		"qemu:///system?socket=/tmp/sock":              "query parameters are not allowed",
+		"qemu:///system?":                              "query parameters are not allowed",
+	} {
+		assert.EqualErrorf(t, ValidateURI(uri), fmt.Sprintf("invalid libvirt URI %q: %s", uri, msg), uri)
+	}
+
+	_, err := New("qemu+ext:///system?command=/bin/sh")
+	assert.Error(t, err)
+}
@@ -0,0 +1,301 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/osbuild/osbuild-composer/internal/upload/progress"
+	"github.com/osbuild/osbuild-composer/internal/upload/throttle"
+)
+
+// The version of the Core Services API, which manages images
+const coreAPIVersion = "20160918"
+
+// Credentials identify an API signing key of an OCI user, as in the
+// configuration file of the OCI CLI
+type Credentials struct {
+	User        string
+	Tenancy     string
+	Region      string
+	Fingerprint string
+	// PrivateKey is the PEM encoded RSA key whose public key has been
+	// added to the user
+	PrivateKey string
+}
+
+type OCI struct {
+	credentials Credentials
+	key         *rsa.PrivateKey
+	client      *http.Client
+
+	// Progress is called while images are uploaded to Object Storage
+	Progress progress.Func
+	// Limiter limits the bandwidth of uploads, unless it is nil
+	Limiter *throttle.Limiter
+
+	// The endpoints of the APIs, which are only changed by tests
+	objectStorageURL string
+	coreURL          string
+	pollInterval     time.Duration
+}
+
+func New(credentials Credentials) (*OCI, error) {
+	block, _ := pem.Decode([]byte(credentials.PrivateKey))
+	if block == nil {
+		return nil, errors.New("the OCI private key is not PEM encoded")
+	}
+	var key *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		key = parsed
+	} else {
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse the OCI private key: %v", err)
+		}
+		var ok bool
+		key, ok = parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("the OCI private key is not an RSA key")
+		}
+	}
+
+	return &OCI{
+		credentials:      credentials,
+		key:              key,
+		client:           &http.Client{},
+		objectStorageURL: fmt.Sprintf("https://objectstorage.%s.oraclecloud.com", credentials.Region),
+		coreURL:          fmt.Sprintf("https://iaas.%s.oraclecloud.com", credentials.Region),
+		pollInterval:     10 * time.Second,
+	}, nil
+}
+
+// sign adds the signature of a request to its headers, as described in
+// https://docs.oracle.com/en-us/iaas/Content/API/Concepts/signingrequests.htm.
+// The body is signed as well, unless it is the content of an object.
+func (o *OCI) sign(req *http.Request, body []byte, signBody bool) error {
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("Host", req.URL.Host)
+
+	headers := []string{"date", "(request-target)", "host"}
+	if signBody {
+		digest := sha256.Sum256(body)
+		req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		req.Header.Set("X-Content-Sha256", base64.StdEncoding.EncodeToString(digest[:]))
+		headers = append(headers, "content-length", "content-type", "x-content-sha256")
+	}
+
+	var lines []string
+	for _, h := range headers {
+		if h == "(request-target)" {
+			lines = append(lines, fmt.Sprintf("(request-target): %s %s", strings.ToLower(req.Method), req.URL.RequestURI()))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s: %s", h, req.Header.Get(h)))
+		}
+	}
+	digest := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, o.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return fmt.Errorf("cannot sign the OCI request: %v", err)
+	}
+
+	keyID := fmt.Sprintf("%s/%s/%s", o.credentials.Tenancy, o.credentials.User, o.credentials.Fingerprint)
+	req.Header.Set("Authorization", fmt.Sprintf(`Signature version="1",keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+// request sends a signed request with a JSON body, unless body is nil, and
+// decodes the JSON reply into reply, unless it is nil
+func (o *OCI) request(ctx context.Context, method, url string, body interface{}, reply interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	err = o.sign(req, data, method == "POST" || method == "PUT")
+	if err != nil {
+		return err
+	}
+	return o.do(req, reply)
+}
+
+func (o *OCI) do(req *http.Request, reply interface{}) error {
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiError struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		if json.Unmarshal(data, &apiError) == nil && apiError.Code != "" {
+			return fmt.Errorf("%s %s: %s: %s: %s", req.Method, req.URL.Path, resp.Status, apiError.Code, apiError.Message)
+		}
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL.Path, resp.Status)
+	}
+	if reply == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(reply)
+}
+
+// Namespace returns the Object Storage namespace of the tenancy
+func (o *OCI) Namespace(ctx context.Context) (string, error) {
+	var namespace string
+	err := o.request(ctx, "GET", o.objectStorageURL+"/n/", nil, &namespace)
+	if err != nil {
+		return "", fmt.Errorf("cannot get the object storage namespace: %v", err)
+	}
+	return namespace, nil
+}
+
+// CheckBucket verifies that the user can access an Object Storage bucket
+func (o *OCI) CheckBucket(ctx context.Context, namespace, bucket string) error {
+	u := fmt.Sprintf("%s/n/%s/b/%s", o.objectStorageURL, url.PathEscape(namespace), url.PathEscape(bucket))
+	err := o.request(ctx, "HEAD", u, nil, nil)
+	if err != nil {
+		return fmt.Errorf("cannot access bucket %s: %v", bucket, err)
+	}
+	return nil
+}
+
+// Upload uploads a file to an Object Storage bucket as the given object
+func (o *OCI) Upload(ctx context.Context, filename, namespace, bucket, object string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[OCI] 🚀 Uploading image to %s/%s", bucket, object)
+	u := fmt.Sprintf("%s/n/%s/b/%s/o/%s", o.objectStorageURL, url.PathEscape(namespace), url.PathEscape(bucket), url.PathEscape(object))
+	req, err := http.NewRequestWithContext(ctx, "PUT", u, o.Limiter.NewReader(progress.NewReader(f, info.Size(), o.Progress)))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	err = o.sign(req, nil, false)
+	if err == nil {
+		err = o.do(req, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot upload %s to %s/%s: %v", filename, bucket, object, err)
+	}
+	return nil
+}
+
+// DownloadURL creates a pre-authenticated request for an object and returns
+// its URL, which allows downloading the object without credentials until it
+// expires
+func (o *OCI) DownloadURL(ctx context.Context, namespace, bucket, object string, expiry time.Duration) (string, error) {
+	body := map[string]interface{}{
+		"name":        "download-" + object,
+		"objectName":  object,
+		"accessType":  "ObjectRead",
+		"timeExpires": time.Now().Add(expiry).UTC().Format(time.RFC3339),
+	}
+	var par struct {
+		AccessURI string `json:"accessUri"`
+	}
+	u := fmt.Sprintf("%s/n/%s/b/%s/p/", o.objectStorageURL, url.PathEscape(namespace), url.PathEscape(bucket))
+	err := o.request(ctx, "POST", u, body, &par)
+	if err != nil {
+		return "", fmt.Errorf("cannot create a pre-authenticated request for %s/%s: %v", bucket, object, err)
+	}
+	return o.objectStorageURL + par.AccessURI, nil
+}
+
+// ImageImport describes a custom image to be created from a QCOW2 image in
+// Object Storage
+type ImageImport struct {
+	Compartment string
+	Name        string
+	Namespace   string
+	Bucket      string
+	Object      string
+}
+
+type image struct {
+	ID             string `json:"id"`
+	LifecycleState string `json:"lifecycleState"`
+}
+
+// ImportImage creates a custom image from a QCOW2 image uploaded to Object
+// Storage and returns its OCID once the image is available
+func (o *OCI) ImportImage(ctx context.Context, imageImport ImageImport) (string, error) {
+	body := map[string]interface{}{
+		"compartmentId": imageImport.Compartment,
+		"displayName":   imageImport.Name,
+		"launchMode":    "PARAVIRTUALIZED",
+		"imageSourceDetails": map[string]string{
+			"sourceType":      "objectStorageTuple",
+			"sourceImageType": "QCOW2",
+			"namespaceName":   imageImport.Namespace,
+			"bucketName":      imageImport.Bucket,
+			"objectName":      imageImport.Object,
+		},
+	}
+
+	log.Printf("[OCI] 📥 Importing image %s", imageImport.Name)
+	var img image
+	err := o.request(ctx, "POST", fmt.Sprintf("%s/%s/images", o.coreURL, coreAPIVersion), body, &img)
+	if err != nil {
+		return "", fmt.Errorf("cannot import image %s: %v", imageImport.Name, err)
+	}
+
+	for img.LifecycleState != "AVAILABLE" {
+		switch img.LifecycleState {
+		case "PROVISIONING", "IMPORTING":
+		default:
+			return "", fmt.Errorf("importing image %s failed: the image is %s", imageImport.Name, img.LifecycleState)
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(o.pollInterval):
+		}
+		err = o.request(ctx, "GET", fmt.Sprintf("%s/%s/images/%s", o.coreURL, coreAPIVersion, url.PathEscape(img.ID)), nil, &img)
+		if err != nil {
+			return "", fmt.Errorf("waiting for the import of image %s failed: %v", imageImport.Name, err)
+		}
+	}
+
+	log.Printf("[OCI] 🎉 Image %s is available", imageImport.Name)
+	return img.ID, nil
+}
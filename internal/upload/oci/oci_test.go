@@ -0,0 +1,144 @@
+package oci
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// verifySignature checks the Authorization header of a request like OCI does
+func verifySignature(t *testing.T, key *rsa.PublicKey, r *http.Request) {
+	match := regexp.MustCompile(`^Signature version="1",keyId="tenancy/user/aa:bb",algorithm="rsa-sha256",headers="([^"]+)",signature="([^"]+)"$`).FindStringSubmatch(r.Header.Get("Authorization"))
+	require.NotNil(t, match, "invalid Authorization header: %s", r.Header.Get("Authorization"))
+
+	var lines []string
+	for _, h := range strings.Split(match[1], " ") {
+		switch h {
+		case "(request-target)":
+			lines = append(lines, fmt.Sprintf("(request-target): %s %s", strings.ToLower(r.Method), r.URL.RequestURI()))
+		case "host":
+			lines = append(lines, "host: "+r.Host)
+		case "content-length":
+			lines = append(lines, fmt.Sprintf("content-length: %d", r.ContentLength))
+		default:
+			lines = append(lines, fmt.Sprintf("%s: %s", h, r.Header.Get(h)))
+		}
+	}
+	signature, err := base64.StdEncoding.DecodeString(match[2])
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	assert.NoError(t, rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature))
+}
+
+func TestUploadAndImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	image := filepath.Join(dir, "disk.qcow2")
+	require.NoError(t, ioutil.WriteFile(image, []byte("qcow2"), 0600))
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var uploaded []byte
+	var imported map[string]interface{}
+	var par map[string]interface{}
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verifySignature(t, &key.PublicKey, r)
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/n/":
+			_, _ = w.Write([]byte(`"namespace"`))
+		case r.Method == "PUT" && r.URL.Path == "/n/namespace/b/bucket/o/disk.qcow2":
+			uploaded, _ = ioutil.ReadAll(r.Body)
+		case r.Method == "POST" && r.URL.Path == "/20160918/images":
+			body, _ := ioutil.ReadAll(r.Body)
+			digest := sha256.Sum256(body)
+			assert.Equal(t, base64.StdEncoding.EncodeToString(digest[:]), r.Header.Get("X-Content-Sha256"))
+			require.NoError(t, json.Unmarshal(body, &imported))
+			_, _ = w.Write([]byte(`{"id": "ocid1.image.oc1..image", "lifecycleState": "IMPORTING"}`))
+		case r.Method == "POST" && r.URL.Path == "/n/namespace/b/bucket/p/":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&par))
+			_, _ = w.Write([]byte(`{"accessUri": "/p/token/n/namespace/b/bucket/o/disk.qcow2"}`))
+		case r.Method == "GET" && r.URL.Path == "/20160918/images/ocid1.image.oc1..image":
+			polls++
+			_, _ = w.Write([]byte(`{"id": "ocid1.image.oc1..image", "lifecycleState": "AVAILABLE"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	o, err := New(Credentials{
+		User:        "user",
+		Tenancy:     "tenancy",
+		Region:      "eu-frankfurt-1",
+		Fingerprint: "aa:bb",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+	})
+	require.NoError(t, err)
+	o.objectStorageURL = server.URL
+	o.coreURL = server.URL
+	o.pollInterval = 0
+
+	namespace, err := o.Namespace(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "namespace", namespace)
+
+	err = o.Upload(context.Background(), image, namespace, "bucket", "disk.qcow2")
+	require.NoError(t, err)
+	assert.Equal(t, "qcow2", string(uploaded))
+
+	id, err := o.ImportImage(context.Background(), ImageImport{
+		Compartment: "ocid1.compartment.oc1..compartment",
+		Name:        "fedora",
+		Namespace:   namespace,
+		Bucket:      "bucket",
+		Object:      "disk.qcow2",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ocid1.image.oc1..image", id)
+	assert.Equal(t, 1, polls)
+	assert.Equal(t, "ocid1.compartment.oc1..compartment", imported["compartmentId"])
+	assert.Equal(t, map[string]interface{}{
+		"sourceType":      "objectStorageTuple",
+		"sourceImageType": "QCOW2",
+		"namespaceName":   "namespace",
+		"bucketName":      "bucket",
+		"objectName":      "disk.qcow2",
+	}, imported["imageSourceDetails"])
+
+	downloadURL, err := o.DownloadURL(context.Background(), namespace, "bucket", "disk.qcow2", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/p/token/n/namespace/b/bucket/o/disk.qcow2", downloadURL)
+	assert.Equal(t, "disk.qcow2", par["objectName"])
+	assert.Equal(t, "ObjectRead", par["accessType"])
+	expires, err := time.Parse(time.RFC3339, par["timeExpires"].(string))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expires, time.Minute)
+
+	err = o.Upload(context.Background(), image, namespace, "other", "disk.qcow2")
+	assert.EqualError(t, err, "cannot upload "+image+" to other/disk.qcow2: PUT /n/namespace/b/other/o/disk.qcow2: 404 Not Found")
+
+	_, err = New(Credentials{PrivateKey: "key"})
+	assert.EqualError(t, err, "the OCI private key is not PEM encoded")
+}
@@ -0,0 +1,200 @@
+// Package plugin runs upload targets which are implemented by external
+// executables, so that proprietary targets can be added without patching
+// composer.
+//
+// A plugin is an executable in the plugin directory of the worker and is
+// named after the file. The worker runs it with a Request as JSON on its
+// standard input. The plugin writes Status messages to its standard output,
+// one JSON object per line: any number of progress updates, followed by
+// either a result or an error. For example:
+//
+//	{"progress": {"transferred": 1048576, "total": 4294967296}}
+//	{"result": {"image_id": "image-42", "url": "https://images.example.com/42"}}
+//
+// Its standard error is logged. A plugin which exits with an error or
+// without writing a result fails the upload, and so does a plugin which
+// runs longer than its timeout.
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/osbuild/osbuild-composer/internal/upload/progress"
+)
+
+// ProtocolVersion is the version of the protocol between the worker and
+// plugins, which is increased when it changes incompatibly
+const ProtocolVersion = 1
+
+// Request is what a plugin gets on its standard input
+type Request struct {
+	Version   int       `json:"version"`
+	JobID     uuid.UUID `json:"job_id"`
+	ImageName string    `json:"image_name"`
+	// Path is the absolute path of the image to upload
+	Path string `json:"path"`
+	// Options are passed on from the target as they are
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+// Status is a message a plugin writes to its standard output
+type Status struct {
+	Progress *Progress `json:"progress,omitempty"`
+	Result   *Result   `json:"result,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Progress is how many bytes of the image have been uploaded
+type Progress struct {
+	Transferred int64 `json:"transferred"`
+	Total       int64 `json:"total"`
+}
+
+// Result identifies the uploaded image
+type Result struct {
+	ImageID string `json:"image_id,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+// DefaultTimeout is how long a plugin may run when it has no timeout
+const DefaultTimeout = 6 * time.Hour
+
+// Plugin is an executable implementing an upload target
+type Plugin struct {
+	Name string
+	Path string
+	// Timeout is how long an upload may take, DefaultTimeout if it is 0
+	Timeout time.Duration
+}
+
+// Discover returns the plugins in dir by name, which are the executable
+// regular files in it. A directory which does not exist has no plugins.
+func Discover(dir string) (map[string]*Plugin, error) {
+	plugins := make(map[string]*Plugin)
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return plugins, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the plugin directory: %v", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() || entry.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		plugins[entry.Name()] = &Plugin{
+			Name: entry.Name(),
+			Path: filepath.Join(dir, entry.Name()),
+		}
+	}
+	return plugins, nil
+}
+
+// maxErrorOutput limits how much of the standard error of a failed plugin
+// is included in its error
+const maxErrorOutput = 1000
+
+// Upload runs the plugin to upload the image of the request. The progress
+// the plugin writes is passed to report, unless it is nil.
+func (p *Plugin) Upload(ctx context.Context, request *Request, report progress.Func) (*Result, error) {
+	request.Version = ProtocolVersion
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Path)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[plugin] 🔌 Uploading %s with plugin %s", filepath.Base(request.Path), p.Name)
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("cannot run plugin %s: %v", p.Name, err)
+	}
+
+	var result *Result
+	var statusErr error
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var status Status
+		err = json.Unmarshal(line, &status)
+		if err != nil {
+			statusErr = fmt.Errorf("plugin %s wrote an invalid status: %v", p.Name, err)
+			// the plugin is killed, so that it does not block on writing
+			cancel()
+			break
+		}
+		switch {
+		case status.Error != "":
+			statusErr = fmt.Errorf("plugin %s failed: %s", p.Name, status.Error)
+		case status.Result != nil:
+			result = status.Result
+		case status.Progress != nil && report != nil:
+			report(status.Progress.Transferred, status.Progress.Total)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if statusErr == nil {
+			statusErr = fmt.Errorf("cannot read the status of plugin %s: %v", p.Name, err)
+		}
+		// nothing reads the output of the plugin anymore
+		cancel()
+	}
+	err = cmd.Wait()
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("plugin %s timed out after %v", p.Name, timeout)
+	}
+
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		log.Printf("[plugin] %s: %s", p.Name, msg)
+	}
+	if statusErr != nil {
+		return nil, statusErr
+	}
+	if err != nil {
+		out := stderr.Bytes()
+		if len(out) > maxErrorOutput {
+			out = out[len(out)-maxErrorOutput:]
+		}
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return nil, fmt.Errorf("plugin %s failed: %v: %s", p.Name, err, msg)
+		}
+		return nil, fmt.Errorf("plugin %s failed: %v", p.Name, err)
+	}
+	if result == nil {
+		return nil, fmt.Errorf("plugin %s exited without a result", p.Name)
+	}
+	return result, nil
+}
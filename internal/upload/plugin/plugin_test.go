@@ -0,0 +1,110 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePlugin writes an executable shell script to dir
+func writePlugin(t *testing.T, dir, name, script string) *Plugin {
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0700))
+	return &Plugin{Name: name, Path: path}
+}
+
+func TestDiscover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	plugins, err := Discover(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, plugins)
+
+	writePlugin(t, dir, "acme", "")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README"), nil, 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "lib"), 0755))
+
+	plugins, err = Discover(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]*Plugin{
+		"acme": {Name: "acme", Path: filepath.Join(dir, "acme")},
+	}, plugins)
+}
+
+func TestUpload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	requestPath := filepath.Join(dir, "request.json")
+	p := writePlugin(t, dir, "acme", `cat > `+requestPath+`
+echo "uploading" >&2
+echo '{"progress": {"transferred": 2, "total": 4}}'
+echo '{"progress": {"transferred": 4, "total": 4}}'
+echo '{"result": {"image_id": "image-42", "url": "https://images.example.com/42"}}'
+`)
+
+	var reports [][2]int64
+	result, err := p.Upload(context.Background(), &Request{
+		JobID:     uuid.MustParse("10000000-0000-0000-0000-000000000000"),
+		ImageName: "fedora",
+		Path:      "/var/cache/osbuild-worker/output/disk.qcow2",
+		Options:   json.RawMessage(`{"region":"moon-1"}`),
+	}, func(transferred, total int64) {
+		reports = append(reports, [2]int64{transferred, total})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &Result{ImageID: "image-42", URL: "https://images.example.com/42"}, result)
+	assert.Equal(t, [][2]int64{{2, 4}, {4, 4}}, reports)
+
+	request, err := ioutil.ReadFile(requestPath)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"version": 1,
+		"job_id": "10000000-0000-0000-0000-000000000000",
+		"image_name": "fedora",
+		"path": "/var/cache/osbuild-worker/output/disk.qcow2",
+		"options": {"region": "moon-1"}
+	}`, string(request))
+}
+
+func TestUploadFailures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for script, expected := range map[string]string{
+		`echo '{"error": "quota exceeded"}'`: "plugin acme failed: quota exceeded",
+		`echo "no such region" >&2; exit 3`:  "plugin acme failed: exit status 3: no such region",
+		`echo uploaded`:                      "plugin acme wrote an invalid status: invalid character 'u' looking for beginning of value",
+		`echo '{"progress": {"total": 4}}'`:  "plugin acme exited without a result",
+		`echo '{"result": {}}'; exit 1`:      "plugin acme failed: exit status 1",
+	} {
+		p := writePlugin(t, dir, "acme", "cat > /dev/null\n"+script)
+		_, err := p.Upload(context.Background(), &Request{}, nil)
+		assert.EqualError(t, err, expected, script)
+	}
+
+	// a status line which is too long for the scanner
+	p := writePlugin(t, dir, "acme", "cat > /dev/null\nexec cat /dev/zero")
+	_, err = p.Upload(context.Background(), &Request{}, nil)
+	assert.EqualError(t, err, "cannot read the status of plugin acme: bufio.Scanner: token too long")
+
+	p = writePlugin(t, dir, "acme", "cat > /dev/null\nexec sleep 60")
+	p.Timeout = 100 * time.Millisecond
+	_, err = p.Upload(context.Background(), &Request{}, nil)
+	assert.EqualError(t, err, "plugin acme timed out after 100ms")
+
+	_, err = (&Plugin{Name: "missing", Path: filepath.Join(dir, "missing")}).Upload(context.Background(), &Request{}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,46 @@
+// Package progress lets the upload clients report how much of an image has
+// been uploaded.
+package progress
+
+import (
+	"io"
+	"sync"
+)
+
+// Func is called with the number of bytes of an image which have been
+// uploaded and the size of the image. A nil Func reports nothing.
+type Func func(transferred, total int64)
+
+type reader struct {
+	reader      io.Reader
+	report      Func
+	transferred int64
+	total       int64
+	mutex       sync.Mutex
+}
+
+// NewReader returns a reader of r, which is total bytes long, reporting the
+// number of bytes read from it so far. Uploads read the image while sending
+// it, which makes that number a good estimate of the uploaded bytes.
+func NewReader(r io.Reader, total int64, report Func) io.Reader {
+	if report == nil {
+		return r
+	}
+	return &reader{
+		reader: r,
+		report: report,
+		total:  total,
+	}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		// reports are serialized, so that they arrive in order
+		r.mutex.Lock()
+		r.transferred += int64(n)
+		r.report(r.transferred, r.total)
+		r.mutex.Unlock()
+	}
+	return n, err
+}
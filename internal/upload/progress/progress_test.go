@@ -0,0 +1,33 @@
+package progress
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader(t *testing.T) {
+	var reports [][2]int64
+	r := NewReader(bytes.NewReader(make([]byte, 10)), 10, func(transferred, total int64) {
+		reports = append(reports, [2]int64{transferred, total})
+	})
+
+	buf := make([]byte, 4)
+	for {
+		_, err := r.Read(buf)
+		if err != nil {
+			break
+		}
+	}
+	assert.Equal(t, [][2]int64{{4, 10}, {8, 10}, {10, 10}}, reports)
+
+	data := bytes.NewBufferString("data")
+	r = NewReader(data, 4, nil)
+	assert.Equal(t, data, r)
+	content, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(content))
+}
@@ -0,0 +1,331 @@
+package pulp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/osbuild/osbuild-composer/internal/upload/progress"
+	"github.com/osbuild/osbuild-composer/internal/upload/throttle"
+)
+
+// The content types of Pulp plugins, which name their API endpoints
+const (
+	File   = "file"
+	OSTree = "ostree"
+)
+
+// Credentials of a Pulp user, which are sent with basic authentication
+type Credentials struct {
+	Username string
+	Password string
+}
+
+type Pulp struct {
+	url         string
+	credentials Credentials
+	client      *http.Client
+
+	// Progress is called while files are uploaded to Pulp
+	Progress progress.Func
+	// Limiter limits the bandwidth of uploads, unless it is nil
+	Limiter *throttle.Limiter
+
+	// Only changed by tests
+	pollInterval time.Duration
+}
+
+// New returns a client of the Pulp 3 API of the server, e.g.
+// https://pulp.example.com
+func New(server string, credentials Credentials) *Pulp {
+	return &Pulp{
+		url:          strings.TrimSuffix(server, "/"),
+		credentials:  credentials,
+		client:       &http.Client{},
+		pollInterval: 2 * time.Second,
+	}
+}
+
+// request sends a JSON body, unless body is nil, to the href of an API
+// object and decodes the JSON reply into reply, unless it is nil
+func (p *Pulp) request(ctx context.Context, method, href string, body interface{}, reply interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.url+href, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return p.do(req, reply)
+}
+
+// upload posts a file and the given fields as a multipart form, without
+// reading the whole file into memory
+func (p *Pulp) upload(ctx context.Context, href, filename string, fields map[string]string, reply interface{}) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	content := p.Limiter.NewReader(progress.NewReader(f, info.Size(), p.Progress))
+
+	r, w := io.Pipe()
+	form := multipart.NewWriter(w)
+	go func() {
+		for name, value := range fields {
+			if err := form.WriteField(name, value); err != nil {
+				w.CloseWithError(err)
+				return
+			}
+		}
+		part, err := form.CreateFormFile("file", filepath.Base(filename))
+		if err == nil {
+			_, err = io.Copy(part, content)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		w.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.url+href, r)
+	if err != nil {
+		r.Close()
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	err = p.do(req, reply)
+	r.Close()
+	return err
+}
+
+func (p *Pulp) do(req *http.Request, reply interface{}) error {
+	req.SetBasicAuth(p.credentials.Username, p.credentials.Password)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := ioutil.ReadAll(resp.Body)
+		if len(data) > 0 && json.Valid(data) {
+			return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, bytes.TrimSpace(data))
+		}
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL.Path, resp.Status)
+	}
+	if reply == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(reply)
+}
+
+type task struct {
+	State string `json:"state"`
+	Error *struct {
+		Description string `json:"description"`
+	} `json:"error"`
+	CreatedResources []string `json:"created_resources"`
+}
+
+// waitForTask polls a task until it has finished and returns the hrefs of
+// the objects it created
+func (p *Pulp) waitForTask(ctx context.Context, href string) ([]string, error) {
+	for {
+		var t task
+		err := p.request(ctx, "GET", href, nil, &t)
+		if err != nil {
+			return nil, err
+		}
+		switch t.State {
+		case "completed":
+			return t.CreatedResources, nil
+		case "failed", "canceled":
+			if t.Error != nil && t.Error.Description != "" {
+				return nil, fmt.Errorf("task %s: %s", t.State, t.Error.Description)
+			}
+			return nil, fmt.Errorf("task %s", t.State)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(p.pollInterval):
+		}
+	}
+}
+
+// runTask waits for the task started by a request, whose reply refers to it
+func (p *Pulp) runTask(ctx context.Context, started func(reply interface{}) error) ([]string, error) {
+	var reply struct {
+		Task string `json:"task"`
+	}
+	err := started(&reply)
+	if err != nil {
+		return nil, err
+	}
+	return p.waitForTask(ctx, reply.Task)
+}
+
+// findCreated returns the first of the created objects whose href contains
+// the given path
+func findCreated(created []string, path string) (string, error) {
+	for _, href := range created {
+		if strings.Contains(href, path) {
+			return href, nil
+		}
+	}
+	return "", fmt.Errorf("the task did not create any %s", strings.Trim(path, "/"))
+}
+
+// Repository returns the href of the repository of a plugin with the given
+// name
+func (p *Pulp) Repository(ctx context.Context, plugin, name string) (string, error) {
+	var reply struct {
+		Results []struct {
+			Href string `json:"pulp_href"`
+		} `json:"results"`
+	}
+	href := fmt.Sprintf("/pulp/api/v3/repositories/%s/%s/?%s", plugin, plugin, url.Values{"name": {name}}.Encode())
+	err := p.request(ctx, "GET", href, nil, &reply)
+	if err != nil {
+		return "", fmt.Errorf("cannot find %s repository %s: %v", plugin, name, err)
+	}
+	if len(reply.Results) == 0 {
+		return "", fmt.Errorf("there is no %s repository named %s", plugin, name)
+	}
+	return reply.Results[0].Href, nil
+}
+
+// UploadFile adds a file to a file repository at the given relative path and
+// returns the href of the new repository version
+func (p *Pulp) UploadFile(ctx context.Context, filename, repository, relativePath string) (string, error) {
+	log.Printf("[Pulp] 🚀 Uploading %s", relativePath)
+	created, err := p.runTask(ctx, func(reply interface{}) error {
+		return p.upload(ctx, "/pulp/api/v3/content/file/files/", filename, map[string]string{
+			"repository":    repository,
+			"relative_path": relativePath,
+		}, reply)
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot upload %s: %v", filename, err)
+	}
+	return findCreated(created, "/versions/")
+}
+
+// ImportCommit imports the ostree repository in a tarball, as produced for
+// commit image types, into an ostree repository and returns the href of the
+// new repository version
+func (p *Pulp) ImportCommit(ctx context.Context, filename, repository string) (string, error) {
+	log.Printf("[Pulp] 🚀 Uploading ostree commit %s", filepath.Base(filename))
+	var artifact struct {
+		Href string `json:"pulp_href"`
+	}
+	err := p.upload(ctx, "/pulp/api/v3/artifacts/", filename, nil, &artifact)
+	if err != nil {
+		return "", fmt.Errorf("cannot upload %s: %v", filename, err)
+	}
+
+	log.Printf("[Pulp] 📥 Importing ostree commit")
+	created, err := p.runTask(ctx, func(reply interface{}) error {
+		// the tarball contains the ostree repository in repo/
+		return p.request(ctx, "POST", repository+"import_all/", map[string]string{
+			"artifact":        artifact.Href,
+			"repository_name": "repo",
+		}, reply)
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot import ostree commit %s: %v", filename, err)
+	}
+	return findCreated(created, "/versions/")
+}
+
+// Distribute serves the latest version of a repository at the base path of
+// a distribution, which is created unless it exists, and returns its URL.
+// File repositories are published first; ostree repositories are served
+// directly.
+func (p *Pulp) Distribute(ctx context.Context, plugin, repository, basePath string) (string, error) {
+	content := map[string]string{"repository": repository}
+	if plugin == File {
+		created, err := p.runTask(ctx, func(reply interface{}) error {
+			return p.request(ctx, "POST", "/pulp/api/v3/publications/file/file/", content, reply)
+		})
+		if err != nil {
+			return "", fmt.Errorf("cannot publish the repository: %v", err)
+		}
+		publication, err := findCreated(created, "/publications/")
+		if err != nil {
+			return "", err
+		}
+		content = map[string]string{"publication": publication}
+	}
+
+	var existing struct {
+		Results []struct {
+			Href string `json:"pulp_href"`
+		} `json:"results"`
+	}
+	distributions := fmt.Sprintf("/pulp/api/v3/distributions/%s/%s/", plugin, plugin)
+	err := p.request(ctx, "GET", distributions+"?"+url.Values{"base_path": {basePath}}.Encode(), nil, &existing)
+	if err != nil {
+		return "", fmt.Errorf("cannot find distribution %s: %v", basePath, err)
+	}
+
+	var distribution string
+	if len(existing.Results) > 0 {
+		distribution = existing.Results[0].Href
+		_, err = p.runTask(ctx, func(reply interface{}) error {
+			return p.request(ctx, "PATCH", distribution, content, reply)
+		})
+	} else {
+		content["name"] = basePath
+		content["base_path"] = basePath
+		var created []string
+		created, err = p.runTask(ctx, func(reply interface{}) error {
+			return p.request(ctx, "POST", distributions, content, reply)
+		})
+		if err == nil {
+			distribution, err = findCreated(created, "/distributions/")
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot distribute the repository at %s: %v", basePath, err)
+	}
+
+	var reply struct {
+		BaseURL string `json:"base_url"`
+	}
+	err = p.request(ctx, "GET", distribution, nil, &reply)
+	if err != nil {
+		return "", fmt.Errorf("cannot get distribution %s: %v", basePath, err)
+	}
+	if reply.BaseURL == "" {
+		return "", errors.New("the distribution has no base URL")
+	}
+	log.Printf("[Pulp] 🎉 Repository is served at %s", reply.BaseURL)
+	return reply.BaseURL, nil
+}
@@ -0,0 +1,128 @@
+package pulp
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadAndDistributeFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pulp-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	image := filepath.Join(dir, "disk.qcow2")
+	require.NoError(t, ioutil.WriteFile(image, []byte("qcow2"), 0600))
+
+	var uploaded map[string]string
+	var distribution map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		assert.Equal(t, "admin:password", user+":"+password)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /pulp/api/v3/repositories/file/file/":
+			if r.URL.Query().Get("name") == "images" {
+				_, _ = w.Write([]byte(`{"results": [{"pulp_href": "/pulp/api/v3/repositories/file/file/1/"}]}`))
+			} else {
+				_, _ = w.Write([]byte(`{"results": []}`))
+			}
+		case "POST /pulp/api/v3/content/file/files/":
+			file, _, err := r.FormFile("file")
+			require.NoError(t, err)
+			content, _ := ioutil.ReadAll(file)
+			uploaded = map[string]string{
+				"file":          string(content),
+				"repository":    r.FormValue("repository"),
+				"relative_path": r.FormValue("relative_path"),
+			}
+			_, _ = w.Write([]byte(`{"task": "/pulp/api/v3/tasks/upload/"}`))
+		case "GET /pulp/api/v3/tasks/upload/":
+			_, _ = w.Write([]byte(`{"state": "completed", "created_resources": ["/pulp/api/v3/content/file/files/1/", "/pulp/api/v3/repositories/file/file/1/versions/1/"]}`))
+		case "POST /pulp/api/v3/publications/file/file/":
+			_, _ = w.Write([]byte(`{"task": "/pulp/api/v3/tasks/publish/"}`))
+		case "GET /pulp/api/v3/tasks/publish/":
+			_, _ = w.Write([]byte(`{"state": "completed", "created_resources": ["/pulp/api/v3/publications/file/file/1/"]}`))
+		case "GET /pulp/api/v3/distributions/file/file/":
+			_, _ = w.Write([]byte(`{"results": []}`))
+		case "POST /pulp/api/v3/distributions/file/file/":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&distribution))
+			_, _ = w.Write([]byte(`{"task": "/pulp/api/v3/tasks/distribute/"}`))
+		case "GET /pulp/api/v3/tasks/distribute/":
+			_, _ = w.Write([]byte(`{"state": "completed", "created_resources": ["/pulp/api/v3/distributions/file/file/1/"]}`))
+		case "GET /pulp/api/v3/distributions/file/file/1/":
+			_, _ = w.Write([]byte(`{"base_url": "https://pulp.example.com/pulp/content/images/"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := New(server.URL+"/", Credentials{Username: "admin", Password: "password"})
+	p.pollInterval = 0
+
+	repository, err := p.Repository(context.Background(), File, "images")
+	require.NoError(t, err)
+	assert.Equal(t, "/pulp/api/v3/repositories/file/file/1/", repository)
+
+	version, err := p.UploadFile(context.Background(), image, repository, "fedora/disk.qcow2")
+	require.NoError(t, err)
+	assert.Equal(t, "/pulp/api/v3/repositories/file/file/1/versions/1/", version)
+	assert.Equal(t, map[string]string{
+		"file":          "qcow2",
+		"repository":    repository,
+		"relative_path": "fedora/disk.qcow2",
+	}, uploaded)
+
+	baseURL, err := p.Distribute(context.Background(), File, repository, "images")
+	require.NoError(t, err)
+	assert.Equal(t, "https://pulp.example.com/pulp/content/images/", baseURL)
+	assert.Equal(t, map[string]string{
+		"publication": "/pulp/api/v3/publications/file/file/1/",
+		"name":        "images",
+		"base_path":   "images",
+	}, distribution)
+
+	_, err = p.Repository(context.Background(), File, "other")
+	assert.EqualError(t, err, "there is no file repository named other")
+}
+
+func TestImportCommit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pulp-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	commit := filepath.Join(dir, "commit.tar")
+	require.NoError(t, ioutil.WriteFile(commit, []byte("tar"), 0600))
+
+	var imported map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /pulp/api/v3/artifacts/":
+			_, _ = w.Write([]byte(`{"pulp_href": "/pulp/api/v3/artifacts/1/"}`))
+		case "POST /pulp/api/v3/repositories/ostree/ostree/1/import_all/":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&imported))
+			_, _ = w.Write([]byte(`{"task": "/pulp/api/v3/tasks/import/"}`))
+		case "GET /pulp/api/v3/tasks/import/":
+			_, _ = w.Write([]byte(`{"state": "failed", "error": {"description": "invalid ostree repository"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := New(server.URL, Credentials{})
+	p.pollInterval = 0
+
+	_, err = p.ImportCommit(context.Background(), commit, "/pulp/api/v3/repositories/ostree/ostree/1/")
+	assert.EqualError(t, err, "cannot import ostree commit "+commit+": task failed: invalid ostree repository")
+	assert.Equal(t, map[string]string{
+		"artifact":        "/pulp/api/v3/artifacts/1/",
+		"repository_name": "repo",
+	}, imported)
+}
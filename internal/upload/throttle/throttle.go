@@ -0,0 +1,82 @@
+// Package throttle limits the bandwidth the upload clients use for sending
+// images.
+package throttle
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Limiter limits the rate at which all readers created by it are read
+// together. A nil Limiter does not limit anything.
+type Limiter struct {
+	rate  int64
+	burst int
+
+	mutex sync.Mutex
+	next  time.Time
+}
+
+// New returns a Limiter of bytesPerSecond, or nil if bytesPerSecond is not
+// positive
+func New(bytesPerSecond int64) *Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	// reads are split up, so that the bandwidth is limited smoothly rather
+	// than in bursts of whole chunks of an upload
+	burst := bytesPerSecond / 10
+	if burst < 1 {
+		burst = 1
+	}
+	if burst > 1024*1024 {
+		burst = 1024 * 1024
+	}
+	return &Limiter{
+		rate:  bytesPerSecond,
+		burst: int(burst),
+	}
+}
+
+// wait blocks until n more bytes may be read
+func (l *Limiter) wait(n int) {
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	delay := l.next.Sub(now)
+	l.mutex.Unlock()
+
+	time.Sleep(delay)
+}
+
+type reader struct {
+	reader  io.Reader
+	limiter *Limiter
+}
+
+// NewReader returns a reader of r, which is read no faster than the limiter
+// allows
+func (l *Limiter) NewReader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &reader{
+		reader:  r,
+		limiter: l,
+	}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > r.limiter.burst {
+		p = p[:r.limiter.burst]
+	}
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.limiter.wait(n)
+	}
+	return n, err
+}
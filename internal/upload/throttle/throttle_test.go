@@ -0,0 +1,48 @@
+package throttle
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader(t *testing.T) {
+	l := New(1024 * 1024)
+	data := make([]byte, 256*1024)
+
+	start := time.Now()
+	content, err := ioutil.ReadAll(l.NewReader(bytes.NewReader(data)))
+	require.NoError(t, err)
+	assert.Equal(t, data, content)
+	assert.True(t, time.Since(start) >= 200*time.Millisecond, "read too fast: %v", time.Since(start))
+}
+
+func TestReadersShareLimit(t *testing.T) {
+	l := New(1024 * 1024)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ioutil.ReadAll(l.NewReader(bytes.NewReader(make([]byte, 128*1024))))
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.True(t, time.Since(start) >= 200*time.Millisecond, "read too fast: %v", time.Since(start))
+}
+
+func TestNoLimit(t *testing.T) {
+	assert.Nil(t, New(0))
+
+	var l *Limiter
+	data := bytes.NewBufferString("data")
+	assert.Equal(t, data, l.NewReader(data))
+}
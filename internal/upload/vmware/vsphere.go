@@ -0,0 +1,459 @@
+package vmware
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/vapi/library"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"github.com/vmware/govmomi/vmdk"
+)
+
+// The virtual hardware of the templates created from images. Like the
+// virtual machines the image tests boot, they use an IDE disk, so that the
+// images do not depend on the drivers of a paravirtual controller.
+const (
+	guestID  = "otherLinux64Guest"
+	numCPUs  = 2
+	memoryMB = 2048
+)
+
+// Credentials of a vSphere user on a vCenter or ESXi host
+type Credentials struct {
+	Host     string
+	Username string
+	Password string
+	// Insecure disables the verification of the certificate of the host,
+	// which is often self-signed
+	Insecure bool
+}
+
+type VSphere struct {
+	client *vim25.Client
+	rest   *rest.Client
+	user   *url.Userinfo
+}
+
+// Connect logs into the vSphere API of the host. The session must be ended
+// with Logout.
+func Connect(ctx context.Context, credentials Credentials) (*VSphere, error) {
+	u, err := soap.ParseURL(credentials.Host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vSphere host %s: %v", credentials.Host, err)
+	}
+	if u == nil {
+		return nil, errors.New("no vSphere host given")
+	}
+	user := url.UserPassword(credentials.Username, credentials.Password)
+	u.User = nil
+
+	client, err := vim25.NewClient(ctx, soap.NewClient(u, credentials.Insecure))
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to %s: %v", u.Host, err)
+	}
+	err = session.NewManager(client).Login(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("cannot log into %s: %v", u.Host, err)
+	}
+
+	return &VSphere{
+		client: client,
+		rest:   rest.NewClient(client),
+		user:   user,
+	}, nil
+}
+
+// Logout ends the sessions of the vSphere APIs
+func (v *VSphere) Logout(ctx context.Context) error {
+	if v.rest.SessionID() != "" {
+		if err := v.rest.Logout(ctx); err != nil {
+			return err
+		}
+	}
+	return session.NewManager(v.client).Logout(ctx)
+}
+
+// DatastoreImport describes where an image is imported into a datastore.
+// Empty fields select the only object of their kind, as govc does.
+type DatastoreImport struct {
+	Name       string
+	Datacenter string
+	Cluster    string
+	Datastore  string
+	Folder     string
+	// Template creates a virtual machine template from the imported disk,
+	// which is attached to Network, unless it is empty
+	Template bool
+	Network  string
+}
+
+// ImportToDatastore imports a stream optimized VMDK into the directory
+// Name of a datastore. It returns the datastore path of the disk, or the
+// inventory path of the template if one is created.
+func (v *VSphere) ImportToDatastore(ctx context.Context, imagePath string, imp DatastoreImport) (string, error) {
+	pl, err := v.findPlacement(ctx, imp)
+	if err != nil {
+		return "", err
+	}
+	finder, dc, ds, pool, folder := pl.finder, pl.dc, pl.ds, pl.pool, pl.folder
+
+	log.Printf("[vSphere] 🚀 Importing disk to %s", ds.Path(imp.Name))
+	err = vmdk.Import(ctx, v.client, imagePath, ds, vmdk.ImportParams{
+		Path:       imp.Name,
+		Datacenter: dc,
+		Pool:       pool,
+		Folder:     folder,
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot import %s to %s: %v", imagePath, ds.Path(imp.Name), err)
+	}
+	diskPath := ds.Path(path.Join(imp.Name, filepath.Base(imagePath)))
+	if !imp.Template {
+		return diskPath, nil
+	}
+
+	devices := object.VirtualDeviceList{}
+	controller, err := devices.CreateIDEController()
+	if err != nil {
+		return "", err
+	}
+	devices = append(devices, controller)
+	devices = append(devices, devices.CreateDisk(controller.(types.BaseVirtualController), ds.Reference(), diskPath))
+	if imp.Network != "" {
+		network, err := finder.Network(ctx, imp.Network)
+		if err != nil {
+			return "", err
+		}
+		backing, err := network.EthernetCardBackingInfo(ctx)
+		if err != nil {
+			return "", err
+		}
+		card, err := devices.CreateEthernetCard("vmxnet3", backing)
+		if err != nil {
+			return "", err
+		}
+		devices = append(devices, card)
+	}
+	deviceChange, err := devices.ConfigSpec(types.VirtualDeviceConfigSpecOperationAdd)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("[vSphere] 📥 Creating template %s", imp.Name)
+	task, err := folder.CreateVM(ctx, types.VirtualMachineConfigSpec{
+		Name:         imp.Name,
+		GuestId:      guestID,
+		NumCPUs:      numCPUs,
+		MemoryMB:     memoryMB,
+		Firmware:     string(types.GuestOsDescriptorFirmwareTypeBios),
+		Files:        &types.VirtualMachineFileInfo{VmPathName: ds.Path(imp.Name)},
+		DeviceChange: deviceChange,
+	}, pool, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot create template %s: %v", imp.Name, err)
+	}
+	result, err := task.WaitForResult(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot create template %s: %v", imp.Name, err)
+	}
+	vm := object.NewVirtualMachine(v.client, result.Result.(types.ManagedObjectReference))
+	err = vm.MarkAsTemplate(ctx)
+	if err != nil {
+		return "", fmt.Errorf("cannot mark %s as a template: %v", imp.Name, err)
+	}
+
+	log.Printf("[vSphere] 🎉 Template %s is ready", imp.Name)
+	return path.Join(folder.InventoryPath, imp.Name), nil
+}
+
+// placement holds the inventory objects an image is imported into
+type placement struct {
+	finder *find.Finder
+	dc     *object.Datacenter
+	ds     *object.Datastore
+	pool   *object.ResourcePool
+	folder *object.Folder
+}
+
+func (v *VSphere) findPlacement(ctx context.Context, imp DatastoreImport) (*placement, error) {
+	finder := find.NewFinder(v.client, true)
+	dc, err := finder.DatacenterOrDefault(ctx, imp.Datacenter)
+	if err != nil {
+		return nil, err
+	}
+	finder.SetDatacenter(dc)
+	ds, err := finder.DatastoreOrDefault(ctx, imp.Datastore)
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := finder.ClusterComputeResourceOrDefault(ctx, imp.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := cluster.ResourcePool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	folder, err := finder.FolderOrDefault(ctx, imp.Folder)
+	if err != nil {
+		return nil, err
+	}
+	return &placement{finder, dc, ds, pool, folder}, nil
+}
+
+// CheckDatastore verifies that the datacenter, datastore, cluster, folder
+// and network an image would be imported into by ImportToDatastore exist
+func (v *VSphere) CheckDatastore(ctx context.Context, imp DatastoreImport) error {
+	pl, err := v.findPlacement(ctx, imp)
+	if err != nil {
+		return err
+	}
+	if imp.Template && imp.Network != "" {
+		_, err = pl.finder.Network(ctx, imp.Network)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckLibrary verifies that the content library exists
+func (v *VSphere) CheckLibrary(ctx context.Context, name string) error {
+	if v.rest.SessionID() == "" {
+		err := v.rest.Login(ctx, v.user)
+		if err != nil {
+			return fmt.Errorf("cannot log into the content library service: %v", err)
+		}
+	}
+	_, err := library.NewManager(v.rest).GetLibraryByName(ctx, name)
+	if err != nil {
+		return fmt.Errorf("cannot find content library %s: %v", name, err)
+	}
+	return nil
+}
+
+// LibraryImport describes the OVF template to be created from an image in a
+// content library
+type LibraryImport struct {
+	Name    string
+	Library string
+}
+
+// ImportToLibrary adds a stream optimized VMDK to a content library, as an
+// OVF template which virtual machines can be deployed from. It returns the
+// ID of the library item.
+func (v *VSphere) ImportToLibrary(ctx context.Context, imagePath string, imp LibraryImport) (string, error) {
+	if v.rest.SessionID() == "" {
+		err := v.rest.Login(ctx, v.user)
+		if err != nil {
+			return "", fmt.Errorf("cannot log into the content library service: %v", err)
+		}
+	}
+
+	disk, err := os.Open(imagePath)
+	if err != nil {
+		return "", err
+	}
+	defer disk.Close()
+	info, err := disk.Stat()
+	if err != nil {
+		return "", err
+	}
+	capacity, err := diskCapacity(disk)
+	if err != nil {
+		return "", fmt.Errorf("cannot read the capacity of %s: %v", imagePath, err)
+	}
+	_, err = disk.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+	diskName := filepath.Base(imagePath)
+	descriptor, err := ovfDescriptor(imp.Name, diskName, info.Size(), capacity)
+	if err != nil {
+		return "", err
+	}
+
+	m := library.NewManager(v.rest)
+	lib, err := m.GetLibraryByName(ctx, imp.Library)
+	if err != nil {
+		return "", fmt.Errorf("cannot find content library %s: %v", imp.Library, err)
+	}
+
+	log.Printf("[vSphere] 🚀 Uploading image to content library %s", imp.Library)
+	itemID, err := m.CreateLibraryItem(ctx, library.Item{
+		Name:      imp.Name,
+		Type:      library.ItemTypeOVF,
+		LibraryID: lib.ID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot create library item %s: %v", imp.Name, err)
+	}
+	sessionID, err := m.CreateLibraryItemUpdateSession(ctx, library.Session{LibraryItemID: itemID})
+	if err != nil {
+		return "", fmt.Errorf("cannot update library item %s: %v", imp.Name, err)
+	}
+
+	err = v.uploadLibraryFile(ctx, m, sessionID, imp.Name+".ovf", bytes.NewReader(descriptor), int64(len(descriptor)))
+	if err == nil {
+		err = v.uploadLibraryFile(ctx, m, sessionID, diskName, disk, info.Size())
+	}
+	if err == nil {
+		err = m.CompleteLibraryItemUpdateSession(ctx, sessionID)
+	}
+	if err != nil {
+		if failErr := m.FailLibraryItemUpdateSession(ctx, sessionID); failErr != nil {
+			log.Printf("[vSphere] cannot cancel the update of library item %s: %v", imp.Name, failErr)
+		}
+		return "", fmt.Errorf("cannot upload library item %s: %v", imp.Name, err)
+	}
+	err = m.WaitOnLibraryItemUpdateSession(ctx, sessionID, 5*time.Second, nil)
+	if err != nil {
+		return "", fmt.Errorf("updating library item %s failed: %v", imp.Name, err)
+	}
+
+	log.Printf("[vSphere] 🎉 Library item %s is ready", imp.Name)
+	return itemID, nil
+}
+
+func (v *VSphere) uploadLibraryFile(ctx context.Context, m *library.Manager, sessionID, name string, r io.Reader, size int64) error {
+	file, err := m.AddLibraryItemFile(ctx, sessionID, library.UpdateFile{
+		Name:       name,
+		SourceType: "PUSH",
+		Size:       size,
+	})
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(file.UploadEndpoint.URI)
+	if err != nil {
+		return err
+	}
+	return v.rest.Upload(ctx, r, u, &soap.Upload{
+		Type:          "application/octet-stream",
+		Method:        "PUT",
+		ContentLength: size,
+	})
+}
+
+// diskCapacity returns the virtual size of a sparse VMDK in bytes, which is
+// stored in its header
+func diskCapacity(r io.Reader) (int64, error) {
+	var header struct {
+		Magic    [4]byte
+		Version  uint32
+		Flags    uint32
+		Capacity uint64
+	}
+	err := binary.Read(r, binary.LittleEndian, &header)
+	if err != nil {
+		return 0, err
+	}
+	if string(header.Magic[:]) != "KDMV" {
+		return 0, errors.New("not a sparse VMDK")
+	}
+	// the capacity is counted in sectors
+	return int64(header.Capacity) * 512, nil
+}
+
+var ovfTemplate = template.Must(template.New("ovf").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1" xmlns:rasd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData" xmlns:vmw="http://www.vmware.com/schema/ovf" xmlns:vssd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_VirtualSystemSettingData">
+  <References>
+    <File ovf:href="{{.Disk}}" ovf:id="file1" ovf:size="{{.Size}}"/>
+  </References>
+  <DiskSection>
+    <Info>Virtual disk information</Info>
+    <Disk ovf:capacity="{{.Capacity}}" ovf:capacityAllocationUnits="byte" ovf:diskId="vmdisk1" ovf:fileRef="file1" ovf:format="http://www.vmware.com/interfaces/specifications/vmdk.html#streamOptimized"/>
+  </DiskSection>
+  <VirtualSystem ovf:id="{{.Name}}">
+    <Info>A virtual machine</Info>
+    <Name>{{.Name}}</Name>
+    <OperatingSystemSection ovf:id="101" vmw:osType="{{.GuestID}}">
+      <Info>The kind of installed guest operating system</Info>
+    </OperatingSystemSection>
+    <VirtualHardwareSection>
+      <Info>Virtual hardware requirements</Info>
+      <System>
+        <vssd:ElementName>Virtual Hardware Family</vssd:ElementName>
+        <vssd:InstanceID>0</vssd:InstanceID>
+        <vssd:VirtualSystemIdentifier>{{.Name}}</vssd:VirtualSystemIdentifier>
+        <vssd:VirtualSystemType>vmx-13</vssd:VirtualSystemType>
+      </System>
+      <Item>
+        <rasd:AllocationUnits>hertz * 10^6</rasd:AllocationUnits>
+        <rasd:Description>Number of Virtual CPUs</rasd:Description>
+        <rasd:ElementName>{{.NumCPUs}} virtual CPU(s)</rasd:ElementName>
+        <rasd:InstanceID>1</rasd:InstanceID>
+        <rasd:ResourceType>3</rasd:ResourceType>
+        <rasd:VirtualQuantity>{{.NumCPUs}}</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:AllocationUnits>byte * 2^20</rasd:AllocationUnits>
+        <rasd:Description>Memory Size</rasd:Description>
+        <rasd:ElementName>{{.MemoryMB}}MB of memory</rasd:ElementName>
+        <rasd:InstanceID>2</rasd:InstanceID>
+        <rasd:ResourceType>4</rasd:ResourceType>
+        <rasd:VirtualQuantity>{{.MemoryMB}}</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:Address>0</rasd:Address>
+        <rasd:Description>IDE Controller</rasd:Description>
+        <rasd:ElementName>IDE Controller 0</rasd:ElementName>
+        <rasd:InstanceID>3</rasd:InstanceID>
+        <rasd:ResourceType>5</rasd:ResourceType>
+      </Item>
+      <Item>
+        <rasd:AddressOnParent>0</rasd:AddressOnParent>
+        <rasd:ElementName>Hard Disk 1</rasd:ElementName>
+        <rasd:HostResource>ovf:/disk/vmdisk1</rasd:HostResource>
+        <rasd:InstanceID>4</rasd:InstanceID>
+        <rasd:Parent>3</rasd:Parent>
+        <rasd:ResourceType>17</rasd:ResourceType>
+      </Item>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>
+`))
+
+// ovfDescriptor returns an OVF descriptor of a virtual machine with the
+// given stream optimized disk, for the hardware of the templates
+func ovfDescriptor(name, disk string, size, capacity int64) ([]byte, error) {
+	var buf bytes.Buffer
+	err := ovfTemplate.Execute(&buf, struct {
+		Name     string
+		Disk     string
+		Size     int64
+		Capacity int64
+		GuestID  string
+		NumCPUs  int
+		MemoryMB int
+	}{escape(name), escape(disk), size, capacity, guestID, numCPUs, memoryMB})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package vmware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskCapacity(t *testing.T) {
+	var header bytes.Buffer
+	header.WriteString("KDMV")
+	require.NoError(t, binary.Write(&header, binary.LittleEndian, []uint32{3, 0x30001}))
+	require.NoError(t, binary.Write(&header, binary.LittleEndian, uint64(4194304)))
+
+	capacity, err := diskCapacity(&header)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2*1024*1024*1024), capacity)
+
+	_, err = diskCapacity(bytes.NewBufferString("QFI\xfb0000000000000000"))
+	assert.EqualError(t, err, "not a sparse VMDK")
+}
+
+func TestOVFDescriptor(t *testing.T) {
+	descriptor, err := ovfDescriptor("fedora <33>", "disk-stream.vmdk", 1000, 2000)
+	require.NoError(t, err)
+
+	var envelope struct {
+		References struct {
+			File struct {
+				Href string `xml:"href,attr"`
+				Size int64  `xml:"size,attr"`
+			}
+		}
+		DiskSection struct {
+			Disk struct {
+				Capacity int64 `xml:"capacity,attr"`
+			}
+		}
+		VirtualSystem struct {
+			Name string
+		}
+	}
+	require.NoError(t, xml.Unmarshal(descriptor, &envelope))
+	assert.Equal(t, "disk-stream.vmdk", envelope.References.File.Href)
+	assert.Equal(t, int64(1000), envelope.References.File.Size)
+	assert.Equal(t, int64(2000), envelope.DiskSection.Disk.Capacity)
+	assert.Equal(t, "fedora <33>", envelope.VirtualSystem.Name)
+}
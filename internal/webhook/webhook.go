@@ -0,0 +1,183 @@
+// Package webhook notifies other services about the state transitions of
+// composes by POSTing events to their URLs, so that they do not have to poll
+// the status of composes.
+//
+// Events are JSON objects. When a webhook has a secret, the body of each
+// request is signed with HMAC-SHA256 and the signature is sent in the
+// X-Composer-Signature header as "sha256=" followed by its hex encoding.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The types of events
+const (
+	// ComposeStarted is sent when a worker starts building an image
+	ComposeStarted = "compose.started"
+	// ComposeFinished is sent when an image was built successfully
+	ComposeFinished = "compose.finished"
+	// ComposeFailed is sent when building an image failed or was canceled
+	ComposeFailed = "compose.failed"
+)
+
+var eventTypes = []string{ComposeStarted, ComposeFinished, ComposeFailed}
+
+// deliveryAttempts is how often delivering an event is tried, waiting
+// retryDelay after the first failed attempt and doubling it after each one
+const deliveryAttempts = 3
+
+var retryDelay = 5 * time.Second
+
+// Event is the body of the requests to webhooks
+type Event struct {
+	Type string `json:"event"`
+	// ID is the ID of the job which builds the image, which is the ID of
+	// the compose in the cloud API
+	ID        uuid.UUID `json:"id"`
+	JobType   string    `json:"job_type"`
+	Distro    string    `json:"distro,omitempty"`
+	ImageType string    `json:"image_type,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Webhook is a URL events are sent to
+type Webhook struct {
+	URL string
+	// Secret is the key the events are signed with, unless it is empty
+	Secret string
+	// Events are the types of events sent to the webhook, all of them if
+	// it is empty
+	Events []string
+}
+
+func (w *Webhook) wants(eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Notifier sends events to webhooks
+type Notifier struct {
+	webhooks []Webhook
+	client   *http.Client
+}
+
+// NewNotifier returns a notifier which sends events to webhooks, using client
+// or a client with a timeout of 30 seconds if it is nil
+func NewNotifier(webhooks []Webhook, client *http.Client) (*Notifier, error) {
+	for _, w := range webhooks {
+		u, err := url.Parse(w.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook URL %q: %v", w.URL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("invalid webhook URL %q: scheme must be http or https", w.URL)
+		}
+		for _, e := range w.Events {
+			if !isEventType(e) {
+				return nil, fmt.Errorf("unknown event %q for webhook %s", e, w.URL)
+			}
+		}
+	}
+
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Notifier{webhooks: webhooks, client: client}, nil
+}
+
+func isEventType(s string) bool {
+	for _, t := range eventTypes {
+		if s == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify sends event to the webhooks which want it in the background.
+// Deliveries which fail are retried a few times and then logged. Notify does
+// nothing if n is nil.
+func (n *Notifier) Notify(event Event) {
+	if n == nil {
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshaling webhook event: %v", err)
+		return
+	}
+
+	for i := range n.webhooks {
+		w := &n.webhooks[i]
+		if !w.wants(event.Type) {
+			continue
+		}
+		go n.deliver(w, event.Type, body)
+	}
+}
+
+func (n *Notifier) deliver(w *Webhook, eventType string, body []byte) {
+	delay := retryDelay
+	var err error
+	for attempt := 1; attempt <= deliveryAttempts; attempt++ {
+		err = n.post(w, eventType, body)
+		if err == nil {
+			return
+		}
+		if attempt < deliveryAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	log.Printf("Error sending %s event to webhook %s: %v", eventType, w.URL, err)
+}
+
+func (n *Notifier) post(w *Webhook, eventType string, body []byte) error {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Composer-Event", eventType)
+	if w.Secret != "" {
+		req.Header.Set("X-Composer-Signature", Signature(w.Secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	return nil
+}
+
+// Signature returns the value of the X-Composer-Signature header of a request
+// with body to a webhook with secret
+func Signature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,115 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, status int) (*httptest.Server, chan request) {
+	requests := make(chan request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		requests <- request{r.Header, body}
+		w.WriteHeader(status)
+	}))
+	return server, requests
+}
+
+func receive(t *testing.T, requests chan request) request {
+	select {
+	case r := <-requests:
+		return r
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the webhook was not called")
+		return request{}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	server, requests := newReceiver(t, http.StatusNoContent)
+	defer server.Close()
+
+	n, err := NewNotifier([]Webhook{{URL: server.URL, Secret: "secret", Events: []string{ComposeFinished, ComposeFailed}}}, nil)
+	require.NoError(t, err)
+
+	id := uuid.New()
+	n.Notify(Event{Type: ComposeStarted, ID: id, JobType: "osbuild"})
+	n.Notify(Event{Type: ComposeFinished, ID: id, JobType: "osbuild", Distro: "fedora-33", ImageType: "qcow2"})
+
+	// started events are filtered
+	r := receive(t, requests)
+	require.Equal(t, ComposeFinished, r.header.Get("X-Composer-Event"))
+	require.Equal(t, "application/json", r.header.Get("Content-Type"))
+	require.Equal(t, Signature("secret", r.body), r.header.Get("X-Composer-Signature"))
+	require.NotEqual(t, Signature("other", r.body), r.header.Get("X-Composer-Signature"))
+
+	var event Event
+	err = json.Unmarshal(r.body, &event)
+	require.NoError(t, err)
+	require.Equal(t, ComposeFinished, event.Type)
+	require.Equal(t, id, event.ID)
+	require.Equal(t, "fedora-33", event.Distro)
+	require.Equal(t, "qcow2", event.ImageType)
+
+	require.Len(t, requests, 0)
+}
+
+func TestNotifyWithoutSecret(t *testing.T) {
+	server, requests := newReceiver(t, http.StatusOK)
+	defer server.Close()
+
+	n, err := NewNotifier([]Webhook{{URL: server.URL}}, nil)
+	require.NoError(t, err)
+
+	n.Notify(Event{Type: ComposeStarted, ID: uuid.New(), JobType: "osbuild"})
+	r := receive(t, requests)
+	require.Equal(t, ComposeStarted, r.header.Get("X-Composer-Event"))
+	require.Empty(t, r.header.Get("X-Composer-Signature"))
+}
+
+func TestNotifyRetries(t *testing.T) {
+	retryDelay = 10 * time.Millisecond
+	defer func() { retryDelay = 5 * time.Second }()
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < deliveryAttempts {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	n, err := NewNotifier([]Webhook{{URL: server.URL}}, nil)
+	require.NoError(t, err)
+
+	n.Notify(Event{Type: ComposeFailed, ID: uuid.New(), JobType: "osbuild"})
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&calls) == deliveryAttempts
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestNewNotifier(t *testing.T) {
+	_, err := NewNotifier([]Webhook{{URL: "ftp://example.com"}}, nil)
+	require.EqualError(t, err, `invalid webhook URL "ftp://example.com": scheme must be http or https`)
+
+	_, err = NewNotifier([]Webhook{{URL: "https://example.com", Events: []string{"compose.queued"}}}, nil)
+	require.EqualError(t, err, `unknown event "compose.queued" for webhook https://example.com`)
+
+	// a nil notifier does nothing
+	var n *Notifier
+	n.Notify(Event{Type: ComposeStarted})
+}
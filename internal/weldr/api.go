@@ -3,6 +3,7 @@ package weldr
 import (
 	"archive/tar"
 	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/json"
 	errors_package "errors"
@@ -27,14 +28,18 @@ import (
 	"github.com/google/uuid"
 	"github.com/julienschmidt/httprouter"
 
+	"github.com/osbuild/osbuild-composer/internal/artifact"
 	"github.com/osbuild/osbuild-composer/internal/blueprint"
 	"github.com/osbuild/osbuild-composer/internal/common"
 	"github.com/osbuild/osbuild-composer/internal/distro"
 	"github.com/osbuild/osbuild-composer/internal/jobqueue"
 	"github.com/osbuild/osbuild-composer/internal/osbuild"
 	"github.com/osbuild/osbuild-composer/internal/rpmmd"
+	"github.com/osbuild/osbuild-composer/internal/sbom"
 	"github.com/osbuild/osbuild-composer/internal/store"
 	"github.com/osbuild/osbuild-composer/internal/target"
+	"github.com/osbuild/osbuild-composer/internal/upload/awsupload"
+	"github.com/osbuild/osbuild-composer/internal/upload/gcp"
 	"github.com/osbuild/osbuild-composer/internal/worker"
 )
 
@@ -121,6 +126,8 @@ func New(rpmmd rpmmd.RPMMD, arch distro.Arch, distro distro.Distro, repos []rpmm
 	api.router.GET("/api/v:version/modules/list/*modules", api.modulesListHandler)
 	api.router.GET("/api/v:version/projects/list", api.projectsListHandler)
 	api.router.GET("/api/v:version/projects/list/", api.projectsListHandler)
+	api.router.GET("/api/v:version/projects/groups", api.projectsGroupsHandler)
+	api.router.GET("/api/v:version/modules/streams", api.modulesStreamsHandler)
 
 	// these are the same, except that modules/info also includes dependencies
 	api.router.GET("/api/v:version/modules/info", api.modulesInfoHandler)
@@ -134,6 +141,11 @@ func New(rpmmd rpmmd.RPMMD, arch distro.Arch, distro distro.Distro, repos []rpmm
 	api.router.GET("/api/v:version/blueprints/freeze/*blueprints", api.blueprintsFreezeHandler)
 	api.router.GET("/api/v:version/blueprints/diff/:blueprint/:from/:to", api.blueprintsDiffHandler)
 	api.router.GET("/api/v:version/blueprints/changes/*blueprints", api.blueprintsChangesHandler)
+	api.router.GET("/api/v:version/blueprints/history/:blueprint", api.blueprintsHistoryHandler)
+	api.router.GET("/api/v:version/blueprints/export/:blueprint", api.blueprintsExportHandler)
+	api.router.GET("/api/v:version/blueprints/lock/:blueprint", api.blueprintsLockInfoHandler)
+	api.router.POST("/api/v:version/blueprints/lock/:blueprint", api.blueprintsLockHandler)
+	api.router.DELETE("/api/v:version/blueprints/lock/:blueprint", api.blueprintsLockDeleteHandler)
 	api.router.POST("/api/v:version/blueprints/new", api.blueprintsNewHandler)
 	api.router.POST("/api/v:version/blueprints/workspace", api.blueprintsWorkspaceHandler)
 	api.router.POST("/api/v:version/blueprints/undo/:blueprint/:commit", api.blueprintUndoHandler)
@@ -147,15 +159,19 @@ func New(rpmmd rpmmd.RPMMD, arch distro.Arch, distro distro.Distro, repos []rpmm
 	api.router.GET("/api/v:version/compose/queue", api.composeQueueHandler)
 	api.router.GET("/api/v:version/compose/status/:uuids", api.composeStatusHandler)
 	api.router.GET("/api/v:version/compose/info/:uuid", api.composeInfoHandler)
+	api.router.GET("/api/v:version/compose/events/:uuid", api.composeEventsHandler)
 	api.router.GET("/api/v:version/compose/finished", api.composeFinishedHandler)
 	api.router.GET("/api/v:version/compose/failed", api.composeFailedHandler)
 	api.router.GET("/api/v:version/compose/image/:uuid", api.composeImageHandler)
 	api.router.GET("/api/v:version/compose/metadata/:uuid", api.composeMetadataHandler)
 	api.router.GET("/api/v:version/compose/results/:uuid", api.composeResultsHandler)
+	api.router.GET("/api/v:version/compose/sbom/:uuid", api.composeSBOMHandler)
 	api.router.GET("/api/v:version/compose/logs/:uuid", api.composeLogsHandler)
 	api.router.GET("/api/v:version/compose/log/:uuid", api.composeLogHandler)
 	api.router.POST("/api/v:version/compose/uploads/schedule/:uuid", api.uploadsScheduleHandler)
 	api.router.DELETE("/api/v:version/compose/cancel/:uuid", api.composeCancelHandler)
+	api.router.POST("/api/v:version/compose/pin/:uuid", api.composePinHandler)
+	api.router.POST("/api/v:version/compose/unpin/:uuid", api.composeUnpinHandler)
 
 	api.router.DELETE("/api/v:version/upload/delete/:uuid", api.uploadsDeleteHandler)
 	api.router.GET("/api/v:version/upload/info/:uuid", api.uploadsInfoHandler)
@@ -163,6 +179,7 @@ func New(rpmmd rpmmd.RPMMD, arch distro.Arch, distro distro.Distro, repos []rpmm
 	api.router.POST("/api/v:version/upload/reset/:uuid", api.uploadsResetHandler)
 	api.router.DELETE("/api/v:version/upload/cancel/:uuid", api.uploadsCancelHandler)
 
+	api.router.POST("/api/v:version/upload/validate", api.uploadsValidateHandler)
 	api.router.GET("/api/v:version/upload/providers", api.providersHandler)
 	api.router.POST("/api/v:version/upload/providers/save", api.providersSaveHandler)
 	api.router.DELETE("/api/v:version/upload/providers/delete/:provider/:profile", api.providersDeleteHandler)
@@ -196,6 +213,17 @@ type composeStatus struct {
 	Started  time.Time
 	Finished time.Time
 	Result   *osbuild.Result
+	// TargetResults are what the worker reported about the targets, such as
+	// the IDs of the images it registered
+	TargetResults []*target.TargetResult
+	// Progress is the latest progress of an upload of a running compose
+	Progress *worker.UploadProgress
+	// TargetStatuses are the states of the targets of the compose, which
+	// are unknown for composes from before the job queue
+	TargetStatuses []worker.TargetStatus
+	// Checksums of the artifacts, and their signatures if the worker
+	// signed them
+	Checksums *worker.ArtifactChecksums
 }
 
 func composeStateFromJobStatus(js *worker.JobStatus, result *worker.OSBuildJobResult) ComposeState {
@@ -255,12 +283,18 @@ func (api *API) getComposeStatus(compose store.Compose) *composeStatus {
 	if err != nil {
 		panic(err)
 	}
+	progress := api.workers.JobProgress(jobId)
 	return &composeStatus{
 		State:    composeStateFromJobStatus(jobStatus, &result),
 		Queued:   jobStatus.Queued,
 		Started:  jobStatus.Started,
 		Finished: jobStatus.Finished,
 		Result:   result.OSBuildOutput,
+
+		TargetResults:  result.TargetResults,
+		Progress:       progress,
+		TargetStatuses: worker.TargetStatuses(compose.ImageBuild.Targets, jobStatus, &result, progress),
+		Checksums:      result.Checksums,
 	}
 }
 
@@ -319,6 +353,21 @@ func isRequestVersionAtLeast(params httprouter.Params, minVersion uint) bool {
 	return uint(version) >= minVersion
 }
 
+// composePageFromRequest returns the page requested from a route listing
+// composes, or writes an error and returns false if it is invalid
+func composePageFromRequest(writer http.ResponseWriter, request *http.Request) (*composePage, bool) {
+	page, err := parseComposePage(request.URL.Query())
+	if err != nil {
+		errors := responseError{
+			ID:  "BadLimitOrOffset",
+			Msg: fmt.Sprintf("BadRequest: %s", err.Error()),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return nil, false
+	}
+	return page, true
+}
+
 func methodNotAllowedHandler(writer http.ResponseWriter, request *http.Request) {
 	writer.WriteHeader(http.StatusMethodNotAllowed)
 }
@@ -347,9 +396,44 @@ func statusResponseOK(writer http.ResponseWriter) {
 }
 
 type responseError struct {
-	Code int    `json:"code,omitempty"`
-	ID   string `json:"id"`
-	Msg  string `json:"msg"`
+	Code    int                         `json:"code,omitempty"`
+	ID      string                      `json:"id"`
+	Msg     string                      `json:"msg"`
+	Details *rpmmd.DepsolveErrorDetails `json:"details,omitempty"`
+}
+
+// addDepsolveDetails adds the details of a failed depsolve of bp to the
+// error and names the packages, modules or groups of bp which caused it. If
+// the culprits are not part of bp, e.g. packages of the image type, they are
+// named instead.
+func (e *responseError) addDepsolveDetails(bp *blueprint.Blueprint, err error) {
+	var dnfErr *rpmmd.DNFError
+	if !errors_package.As(err, &dnfErr) || dnfErr.Details == nil {
+		return
+	}
+	e.Details = dnfErr.Details
+	if len(dnfErr.Details.Culprits) == 0 {
+		return
+	}
+
+	names := make(map[string]string)
+	for _, pkg := range append(append([]blueprint.Package{}, bp.Packages...), bp.Modules...) {
+		names[pkg.ToNameVersion()] = pkg.Name
+	}
+	for _, group := range bp.Groups {
+		names["@"+group.Name] = group.Name
+	}
+
+	var culprits []string
+	for _, spec := range dnfErr.Details.Culprits {
+		if name, ok := names[spec]; ok {
+			culprits = append(culprits, name)
+		}
+	}
+	if len(culprits) == 0 {
+		culprits = dnfErr.Details.Culprits
+	}
+	e.Msg += fmt.Sprintf(" (caused by %s)", strings.Join(culprits, ", "))
 }
 
 // verifyStringsWithRegex checks a slive of strings against a regex of allowed characters
@@ -788,7 +872,7 @@ func (api *API) modulesListHandler(writer http.ResponseWriter, request *http.Req
 		Modules []module `json:"modules"`
 	}
 
-	offset, limit, err := parseOffsetAndLimit(request.URL.Query())
+	query, err := parsePackageQuery(request.URL.Query())
 	if err != nil {
 		errors := responseError{
 			ID:  "BadLimitOrOffset",
@@ -800,66 +884,52 @@ func (api *API) modulesListHandler(writer http.ResponseWriter, request *http.Req
 	}
 
 	modulesParam := params.ByName("modules")
+	if modulesParam != "" && modulesParam != "/" {
+		// we have modules for search
+
+		// remove leading /
+		modulesParam = modulesParam[1:]
 
-	availablePackages, err := api.fetchPackageList()
+		query.Globs = strings.Split(modulesParam, ",")
+	}
 
-	if err != nil {
+	if err := query.Validate(); err != nil {
 		errors := responseError{
 			ID:  "ModulesError",
-			Msg: fmt.Sprintf("msg: %s", err.Error()),
+			Msg: fmt.Sprintf("BadRequest: %s", err.Error()),
 		}
 		statusResponseError(writer, http.StatusBadRequest, errors)
 		return
 	}
 
-	var packages rpmmd.PackageList
-	if modulesParam != "" && modulesParam != "/" {
-		// we have modules for search
-
-		// remove leading /
-		modulesParam = modulesParam[1:]
-
-		names := strings.Split(modulesParam, ",")
-
-		packages, err = availablePackages.Search(names...)
-
-		if err != nil {
-			errors := responseError{
-				ID:  "ModulesError",
-				Msg: fmt.Sprintf("Wrong glob pattern: %s", err.Error()),
-			}
-			statusResponseError(writer, http.StatusBadRequest, errors)
-			return
+	result, err := api.searchPackages(request.Context(), query)
+	if err != nil {
+		errors := responseError{
+			ID:  "ModulesError",
+			Msg: fmt.Sprintf("msg: %s", err.Error()),
 		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
 
-		if len(packages) == 0 {
-			errors := responseError{
-				ID:  "UnknownModule",
-				Msg: "No packages have been found.",
-			}
-			statusResponseError(writer, http.StatusBadRequest, errors)
-			return
+	if len(query.Globs) > 0 && result.Total == 0 {
+		errors := responseError{
+			ID:  "UnknownModule",
+			Msg: "No packages have been found.",
 		}
-	} else {
-		// just return all available packages
-		packages = availablePackages
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
 	}
 
-	packageInfos := packages.ToPackageInfos()
-
-	total := uint(len(packageInfos))
-	start := min(offset, total)
-	n := min(limit, total-start)
-
-	modules := make([]module, n)
-	for i := uint(0); i < n; i++ {
-		modules[i] = module{packageInfos[start+i].Name, "rpm"}
+	modules := []module{}
+	for _, info := range result.Packages.ToPackageInfos() {
+		modules = append(modules, module{info.Name, "rpm"})
 	}
 
 	err = json.NewEncoder(writer).Encode(reply{
-		Total:   total,
-		Offset:  offset,
-		Limit:   limit,
+		Total:   result.Total,
+		Offset:  query.Offset,
+		Limit:   query.Limit,
 		Modules: modules,
 	})
 	common.PanicOnError(err)
@@ -877,7 +947,7 @@ func (api *API) projectsListHandler(writer http.ResponseWriter, request *http.Re
 		Projects []rpmmd.PackageInfo `json:"projects"`
 	}
 
-	offset, limit, err := parseOffsetAndLimit(request.URL.Query())
+	query, err := parsePackageQuery(request.URL.Query())
 	if err != nil {
 		errors := responseError{
 			ID:  "BadLimitOrOffset",
@@ -887,8 +957,16 @@ func (api *API) projectsListHandler(writer http.ResponseWriter, request *http.Re
 		return
 	}
 
-	availablePackages, err := api.fetchPackageList()
+	if err := query.Validate(); err != nil {
+		errors := responseError{
+			ID:  "ProjectsError",
+			Msg: fmt.Sprintf("BadRequest: %s", err.Error()),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
 
+	result, err := api.searchPackages(request.Context(), query)
 	if err != nil {
 		errors := responseError{
 			ID:  "ProjectsError",
@@ -898,26 +976,72 @@ func (api *API) projectsListHandler(writer http.ResponseWriter, request *http.Re
 		return
 	}
 
-	packageInfos := availablePackages.ToPackageInfos()
-
-	total := uint(len(packageInfos))
-	start := min(offset, total)
-	n := min(limit, total-start)
-
-	packages := make([]rpmmd.PackageInfo, n)
-	for i := uint(0); i < n; i++ {
-		packages[i] = packageInfos[start+i]
+	packages := result.Packages.ToPackageInfos()
+	if packages == nil {
+		packages = []rpmmd.PackageInfo{}
 	}
 
 	err = json.NewEncoder(writer).Encode(reply{
-		Total:    total,
-		Offset:   offset,
-		Limit:    limit,
+		Total:    result.Total,
+		Offset:   query.Offset,
+		Limit:    query.Limit,
 		Projects: packages,
 	})
 	common.PanicOnError(err)
 }
 
+func (api *API) projectsGroupsHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	if !verifyRequestVersion(writer, params, 1) {
+		return
+	}
+
+	type reply struct {
+		Groups rpmmd.PackageGroups `json:"groups"`
+	}
+
+	groups, err := api.rpmmd.ListPackageGroups(request.Context(), api.allRepositories(), api.distro.ModulePlatformID(), api.arch.Name())
+	if err != nil {
+		errors := responseError{
+			ID:  "ProjectsError",
+			Msg: fmt.Sprintf("msg: %s", err.Error()),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+	if groups == nil {
+		groups = rpmmd.PackageGroups{}
+	}
+
+	err = json.NewEncoder(writer).Encode(reply{groups})
+	common.PanicOnError(err)
+}
+
+func (api *API) modulesStreamsHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	if !verifyRequestVersion(writer, params, 1) {
+		return
+	}
+
+	type reply struct {
+		Streams []rpmmd.ModuleStream `json:"streams"`
+	}
+
+	streams, err := api.rpmmd.ListModuleStreams(request.Context(), api.allRepositories(), api.distro.ModulePlatformID(), api.arch.Name())
+	if err != nil {
+		errors := responseError{
+			ID:  "ModulesError",
+			Msg: fmt.Sprintf("msg: %s", err.Error()),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+	if streams == nil {
+		streams = []rpmmd.ModuleStream{}
+	}
+
+	err = json.NewEncoder(writer).Encode(reply{streams})
+	common.PanicOnError(err)
+}
+
 func (api *API) modulesInfoHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
 	if !verifyRequestVersion(writer, params, 0) {
 		return
@@ -958,7 +1082,7 @@ func (api *API) modulesInfoHandler(writer http.ResponseWriter, request *http.Req
 
 	names := strings.Split(modules, ",")
 
-	availablePackages, err := api.fetchPackageList()
+	availablePackages, err := api.fetchPackageList(request.Context())
 
 	if err != nil {
 		errors := responseError{
@@ -993,7 +1117,7 @@ func (api *API) modulesInfoHandler(writer http.ResponseWriter, request *http.Req
 
 	if modulesRequested {
 		for i := range packageInfos {
-			err := packageInfos[i].FillDependencies(api.rpmmd, api.repos, api.distro.ModulePlatformID(), api.arch.Name())
+			err := packageInfos[i].FillDependencies(request.Context(), api.rpmmd, api.repos, api.distro.ModulePlatformID(), api.arch.Name())
 			if err != nil {
 				errors := responseError{
 					ID:  errorId,
@@ -1037,7 +1161,7 @@ func (api *API) projectsDepsolveHandler(writer http.ResponseWriter, request *htt
 	projects = projects[1:]
 	names := strings.Split(projects, ",")
 
-	packages, _, err := api.rpmmd.Depsolve(names, nil, api.repos, api.distro.ModulePlatformID(), api.arch.Name())
+	packages, _, err := api.rpmmd.Depsolve(request.Context(), names, nil, nil, api.repos, api.distro.ModulePlatformID(), api.arch.Name())
 
 	if err != nil {
 		errors := responseError{
@@ -1232,13 +1356,15 @@ func (api *API) blueprintsDepsolveHandler(writer http.ResponseWriter, request *h
 			continue
 		}
 
-		dependencies, _, err := api.depsolveBlueprint(blueprint, nil)
+		dependencies, _, _, err := api.depsolveBlueprint(request.Context(), blueprint, nil, nil)
 
 		if err != nil {
-			blueprintsErrors = append(blueprintsErrors, responseError{
+			blueprintsError := responseError{
 				ID:  "BlueprintsError",
 				Msg: fmt.Sprintf("%s: %s", name, err.Error()),
-			})
+			}
+			blueprintsError.addDepsolveDetails(blueprint, err)
+			blueprintsErrors = append(blueprintsErrors, blueprintsError)
 			dependencies = []rpmmd.PackageSpec{}
 		}
 
@@ -1258,6 +1384,10 @@ func (api *API) blueprintsDepsolveHandler(writer http.ResponseWriter, request *h
 // It will return an error if it cannot find a package in the dependencies
 func setPkgEVRA(dependencies []rpmmd.PackageSpec, packages []blueprint.Package) error {
 	for pkgIndex, pkg := range packages {
+		// Groups are not part of the depsolve results, their packages are
+		if pkg.IsGroup() {
+			continue
+		}
 		i := sort.Search(len(dependencies), func(i int) bool {
 			return dependencies[i].Name >= pkg.Name
 		})
@@ -1321,12 +1451,13 @@ func (api *API) blueprintsFreezeHandler(writer http.ResponseWriter, request *htt
 		}
 		// Make a copy of the blueprint since we will be replacing the version globs
 		blueprint := bp.DeepCopy()
-		dependencies, _, err := api.depsolveBlueprint(&blueprint, nil)
+		dependencies, _, _, err := api.depsolveBlueprint(request.Context(), &blueprint, nil, nil)
 		if err != nil {
 			rerr := responseError{
 				ID:  "BlueprintsError",
 				Msg: fmt.Sprintf("%s: %s", name, err.Error()),
 			}
+			rerr.addDepsolveDetails(&blueprint, err)
 			errors = append(errors, rerr)
 			break
 		}
@@ -1390,129 +1521,329 @@ func (api *API) blueprintsFreezeHandler(writer http.ResponseWriter, request *htt
 	}
 }
 
-func (api *API) blueprintsDiffHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
-	if !verifyRequestVersion(writer, params, 0) {
+// blueprintsExportHandler returns the committed version of a blueprint
+// together with its depsolved packages and the checksums of the
+// repositories. With the image_type query parameter, the packages of the
+// image type and its build root are included, and the reply can be passed
+// to osbuild-pipeline -resolved to create manifests offline.
+func (api *API) blueprintsExportHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	if !verifyRequestVersion(writer, params, 1) {
 		return
 	}
 
-	type pack struct {
-		Package blueprint.Package `json:"Package"`
+	type reply struct {
+		Blueprint     blueprint.Blueprint `json:"blueprint"`
+		Distro        string              `json:"distro"`
+		Arch          string              `json:"arch"`
+		ImageType     string              `json:"image-type,omitempty"`
+		Packages      []rpmmd.PackageSpec `json:"packages"`
+		BuildPackages []rpmmd.PackageSpec `json:"build-packages,omitempty"`
+		Checksums     map[string]string   `json:"checksums"`
 	}
 
-	type diff struct {
-		New *pack `json:"new"`
-		Old *pack `json:"old"`
+	name := params.ByName("blueprint")
+	if !verifyStringsWithRegex(writer, []string{name}, ValidBlueprintName) {
+		return
 	}
 
-	type reply struct {
-		Diffs []diff `json:"diff"`
+	bp := api.store.GetBlueprintCommitted(name)
+	if bp == nil {
+		errors := responseError{
+			ID:  "UnknownBlueprint",
+			Msg: fmt.Sprintf("Unknown blueprint name: %s", name),
+		}
+		statusResponseError(writer, http.StatusNotFound, errors)
+		return
 	}
 
-	name := params.ByName("blueprint")
-	if !verifyStringsWithRegex(writer, []string{name}, ValidBlueprintName) {
+	var imageType distro.ImageType
+	if imageTypeName := request.URL.Query().Get("image_type"); imageTypeName != "" {
+		var err error
+		imageType, err = api.arch.GetImageType(imageTypeName)
+		if err != nil {
+			errors := responseError{
+				ID:  "UnknownComposeType",
+				Msg: fmt.Sprintf("Unknown compose type for architecture: %s", imageTypeName),
+			}
+			statusResponseError(writer, http.StatusBadRequest, errors)
+			return
+		}
+	}
+
+	lockfile := api.store.GetLockfile(bp.Name)
+	if lockfile != nil && lockfile.BlueprintVersion != bp.Version {
+		lockfile = nil
+	}
+
+	packages, buildPackages, checksums, err := api.depsolveBlueprint(request.Context(), bp, imageType, lockfile)
+	if err != nil {
+		errors := responseError{
+			ID:  "BlueprintsError",
+			Msg: fmt.Sprintf("%s: %s", name, err.Error()),
+		}
+		errors.addDepsolveDetails(bp, err)
+		statusResponseError(writer, http.StatusBadRequest, errors)
 		return
 	}
 
-	fromCommit := params.ByName("from")
-	if !verifyStringsWithRegex(writer, []string{fromCommit}, ValidBlueprintName) {
+	r := reply{
+		Blueprint:     *bp,
+		Distro:        api.distro.Name(),
+		Arch:          api.arch.Name(),
+		Packages:      packages,
+		BuildPackages: buildPackages,
+		Checksums:     checksums,
+	}
+	if imageType != nil {
+		r.ImageType = imageType.Name()
+	}
+	err = json.NewEncoder(writer).Encode(r)
+	common.PanicOnError(err)
+}
+
+type lockfileReply struct {
+	Name      string            `json:"name"`
+	Version   string            `json:"version"`
+	Created   time.Time         `json:"created"`
+	Packages  []string          `json:"packages"`
+	Checksums map[string]string `json:"checksums"`
+}
+
+func newLockfileReply(name string, lockfile *store.Lockfile) lockfileReply {
+	packages := make([]string, 0, len(lockfile.Packages))
+	for _, pkg := range lockfile.Packages {
+		packages = append(packages, pkg.GetNEVRA())
+	}
+	sort.Strings(packages)
+	return lockfileReply{
+		Name:      name,
+		Version:   lockfile.BlueprintVersion,
+		Created:   lockfile.Created,
+		Packages:  packages,
+		Checksums: lockfile.Checksums,
+	}
+}
+
+// blueprintsLockHandler freezes the committed version of a blueprint: its
+// packages are resolved once and all later composes of that version reuse
+// the exact same packages
+func (api *API) blueprintsLockHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	if !verifyRequestVersion(writer, params, 1) {
 		return
 	}
 
-	toCommit := params.ByName("to")
-	if !verifyStringsWithRegex(writer, []string{toCommit}, ValidBlueprintName) {
+	name := params.ByName("blueprint")
+	if !verifyStringsWithRegex(writer, []string{name}, ValidBlueprintName) {
 		return
 	}
 
-	if len(name) == 0 || len(fromCommit) == 0 || len(toCommit) == 0 {
+	bp := api.store.GetBlueprintCommitted(name)
+	if bp == nil {
 		errors := responseError{
-			Code: http.StatusNotFound,
-			ID:   "HTTPError",
-			Msg:  "Not Found",
+			ID:  "UnknownBlueprint",
+			Msg: fmt.Sprintf("Unknown blueprint name: %s", name),
 		}
 		statusResponseError(writer, http.StatusNotFound, errors)
 		return
 	}
-	if fromCommit != "NEWEST" {
+
+	packages, checksums, err := api.rpmmd.Depsolve(request.Context(), bp.GetPackages(), bp.GetExcludedPackages(), bp.GetModuleStreams(), api.allRepositories(), api.distro.ModulePlatformID(), api.arch.Name())
+	if err != nil {
 		errors := responseError{
-			ID:  "UnknownCommit",
-			Msg: fmt.Sprintf("ggit-error: revspec '%s' not found (-3)", fromCommit),
+			ID:  "BlueprintsError",
+			Msg: fmt.Sprintf("%s: %s", name, err.Error()),
 		}
 		statusResponseError(writer, http.StatusBadRequest, errors)
 		return
 	}
-	if toCommit != "WORKSPACE" {
+
+	lockfile := store.Lockfile{
+		BlueprintVersion: bp.Version,
+		Packages:         packages,
+		Checksums:        checksums,
+		Created:          time.Now().UTC(),
+	}
+	err = api.store.PushLockfile(name, lockfile)
+	if err != nil {
 		errors := responseError{
-			ID:  "UnknownCommit",
-			Msg: fmt.Sprintf("ggit-error: revspec '%s' not found (-3)", toCommit),
+			ID:  "BlueprintsError",
+			Msg: err.Error(),
 		}
 		statusResponseError(writer, http.StatusBadRequest, errors)
 		return
 	}
 
-	// Fetch old and new blueprint details from store and return error if not found
-	oldBlueprint := api.store.GetBlueprintCommitted(name)
-	newBlueprint, _ := api.store.GetBlueprint(name)
-	if oldBlueprint == nil || newBlueprint == nil {
+	err = json.NewEncoder(writer).Encode(newLockfileReply(name, &lockfile))
+	common.PanicOnError(err)
+}
+
+func (api *API) blueprintsLockInfoHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	if !verifyRequestVersion(writer, params, 1) {
+		return
+	}
+
+	name := params.ByName("blueprint")
+	if !verifyStringsWithRegex(writer, []string{name}, ValidBlueprintName) {
+		return
+	}
+
+	lockfile := api.store.GetLockfile(name)
+	if lockfile == nil {
 		errors := responseError{
-			ID:  "UnknownBlueprint",
-			Msg: fmt.Sprintf("Unknown blueprint name: %s", name),
+			ID:  "UnknownLockfile",
+			Msg: fmt.Sprintf("%s: blueprint is not frozen", name),
 		}
 		statusResponseError(writer, http.StatusNotFound, errors)
 		return
 	}
 
-	newSlice := newBlueprint.Packages
-	oldMap := make(map[string]blueprint.Package)
-	diffs := []diff{}
+	err := json.NewEncoder(writer).Encode(newLockfileReply(name, lockfile))
+	common.PanicOnError(err)
+}
 
-	for _, oldPackage := range oldBlueprint.Packages {
-		oldMap[oldPackage.Name] = oldPackage
+func (api *API) blueprintsLockDeleteHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	if !verifyRequestVersion(writer, params, 1) {
+		return
 	}
 
-	// For each package in new blueprint check if the old one contains it
-	for _, newPackage := range newSlice {
-		oldPackage, found := oldMap[newPackage.Name]
-		// If found remove from old packages map but otherwise create a diff with the added package
-		if found {
-			delete(oldMap, oldPackage.Name)
-			// Create a diff if the versions changed
-			if oldPackage.Version != newPackage.Version {
-				diffs = append(diffs, diff{Old: &pack{oldPackage}, New: &pack{newPackage}})
-			}
-		} else {
-			diffs = append(diffs, diff{Old: nil, New: &pack{newPackage}})
-		}
+	name := params.ByName("blueprint")
+	if !verifyStringsWithRegex(writer, []string{name}, ValidBlueprintName) {
+		return
 	}
 
-	// All packages remaining in the old packages map have been removed in the new blueprint so create a diff
-	for _, oldPackage := range oldMap {
-		diffs = append(diffs, diff{Old: &pack{oldPackage}, New: nil})
+	if err := api.store.DeleteLockfile(name); err != nil {
+		errors := responseError{
+			ID:  "UnknownLockfile",
+			Msg: err.Error(),
+		}
+		statusResponseError(writer, http.StatusNotFound, errors)
+		return
 	}
-
-	err := json.NewEncoder(writer).Encode(reply{diffs})
-	common.PanicOnError(err)
+	statusResponseOK(writer)
 }
 
-func (api *API) blueprintsChangesHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+func (api *API) blueprintsDiffHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
 	if !verifyRequestVersion(writer, params, 0) {
 		return
 	}
 
-	type change struct {
-		Changes []blueprint.Change `json:"changes"`
-		Name    string             `json:"name"`
-		Total   int                `json:"total"`
+	type diff struct {
+		New map[string]interface{} `json:"new"`
+		Old map[string]interface{} `json:"old"`
 	}
 
 	type reply struct {
-		BlueprintsChanges []change        `json:"blueprints"`
-		Errors            []responseError `json:"errors"`
-		Limit             uint            `json:"limit"`
-		Offset            uint            `json:"offset"`
+		Diffs []diff `json:"diff"`
 	}
 
-	names := strings.Split(params.ByName("blueprints"), ",")
-	if names[0] == "/" {
+	name := params.ByName("blueprint")
+	if !verifyStringsWithRegex(writer, []string{name}, ValidBlueprintName) {
+		return
+	}
+
+	fromCommit := params.ByName("from")
+	if !verifyStringsWithRegex(writer, []string{fromCommit}, ValidBlueprintName) {
+		return
+	}
+
+	toCommit := params.ByName("to")
+	if !verifyStringsWithRegex(writer, []string{toCommit}, ValidBlueprintName) {
+		return
+	}
+
+	if len(name) == 0 || len(fromCommit) == 0 || len(toCommit) == 0 {
+		errors := responseError{
+			Code: http.StatusNotFound,
+			ID:   "HTTPError",
+			Msg:  "Not Found",
+		}
+		statusResponseError(writer, http.StatusNotFound, errors)
+		return
+	}
+
+	if api.store.GetBlueprintCommitted(name) == nil {
+		errors := responseError{
+			ID:  "UnknownBlueprint",
+			Msg: fmt.Sprintf("Unknown blueprint name: %s", name),
+		}
+		statusResponseError(writer, http.StatusNotFound, errors)
+		return
+	}
+
+	// Fetch old and new blueprint details from store and return error if not found
+	oldBlueprint := api.blueprintAtCommit(name, fromCommit)
+	if oldBlueprint == nil {
+		errors := responseError{
+			ID:  "UnknownCommit",
+			Msg: fmt.Sprintf("ggit-error: revspec '%s' not found (-3)", fromCommit),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+	newBlueprint := api.blueprintAtCommit(name, toCommit)
+	if newBlueprint == nil {
+		errors := responseError{
+			ID:  "UnknownCommit",
+			Msg: fmt.Sprintf("ggit-error: revspec '%s' not found (-3)", toCommit),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+
+	diffs := []diff{}
+	for _, d := range blueprint.Diff(oldBlueprint, newBlueprint) {
+		var entry diff
+		if d.Old != nil {
+			entry.Old = map[string]interface{}{d.Section: d.Old}
+		}
+		if d.New != nil {
+			entry.New = map[string]interface{}{d.Section: d.New}
+		}
+		diffs = append(diffs, entry)
+	}
+
+	err := json.NewEncoder(writer).Encode(reply{diffs})
+	common.PanicOnError(err)
+}
+
+// blueprintAtCommit returns the blueprint at a commit, NEWEST for the latest
+// commit or WORKSPACE for the workspace, and nil if there is no such commit
+func (api *API) blueprintAtCommit(name, commit string) *blueprint.Blueprint {
+	switch commit {
+	case "NEWEST":
+		return api.store.GetBlueprintCommitted(name)
+	case "WORKSPACE":
+		bp, _ := api.store.GetBlueprint(name)
+		return bp
+	default:
+		change, err := api.store.GetBlueprintChange(name, commit)
+		if err != nil {
+			return nil
+		}
+		return &change.Blueprint
+	}
+}
+
+func (api *API) blueprintsChangesHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	if !verifyRequestVersion(writer, params, 0) {
+		return
+	}
+
+	type change struct {
+		Changes []blueprint.Change `json:"changes"`
+		Name    string             `json:"name"`
+		Total   int                `json:"total"`
+	}
+
+	type reply struct {
+		BlueprintsChanges []change        `json:"blueprints"`
+		Errors            []responseError `json:"errors"`
+		Limit             uint            `json:"limit"`
+		Offset            uint            `json:"offset"`
+	}
+
+	names := strings.Split(params.ByName("blueprints"), ",")
+	if names[0] == "/" {
 		errors := responseError{
 			Code: http.StatusNotFound,
 			ID:   "HTTPError",
@@ -1573,6 +1904,94 @@ func (api *API) blueprintsChangesHandler(writer http.ResponseWriter, request *ht
 	common.PanicOnError(err)
 }
 
+// blueprintsHistoryHandler returns all committed versions of a blueprint,
+// newest first
+func (api *API) blueprintsHistoryHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	if !verifyRequestVersion(writer, params, 1) {
+		return
+	}
+
+	type entry struct {
+		Version   string              `json:"version"`
+		Commit    string              `json:"commit"`
+		Message   string              `json:"message"`
+		Timestamp string              `json:"timestamp"`
+		Blueprint blueprint.Blueprint `json:"blueprint"`
+	}
+
+	type reply struct {
+		Name    string  `json:"name"`
+		History []entry `json:"history"`
+	}
+
+	name := params.ByName("blueprint")
+	if !verifyStringsWithRegex(writer, []string{name}, ValidBlueprintName) {
+		return
+	}
+
+	changes := api.store.GetBlueprintChanges(name)
+	if changes == nil {
+		errors := responseError{
+			ID:  "UnknownBlueprint",
+			Msg: fmt.Sprintf("Unknown blueprint name: %s", name),
+		}
+		statusResponseError(writer, http.StatusNotFound, errors)
+		return
+	}
+
+	history := make([]entry, 0, len(changes))
+	for i := len(changes) - 1; i >= 0; i-- {
+		history = append(history, entry{
+			Version:   changes[i].Blueprint.Version,
+			Commit:    changes[i].Commit,
+			Message:   changes[i].Message,
+			Timestamp: changes[i].Timestamp,
+			Blueprint: changes[i].Blueprint,
+		})
+	}
+
+	err := json.NewEncoder(writer).Encode(reply{
+		Name:    name,
+		History: history,
+	})
+	common.PanicOnError(err)
+}
+
+// decodeBlueprint decodes a blueprint in the format given by contentType.
+// Keys which are not part of a blueprint are returned as validation errors.
+func decodeBlueprint(contentType string, body io.Reader) (blueprint.Blueprint, blueprint.ValidationErrors, error) {
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return blueprint.Blueprint{}, nil, err
+	}
+
+	switch contentType {
+	case "application/json":
+		return blueprint.DecodeJSON(data)
+	case "text/x-toml":
+		return blueprint.DecodeTOML(data)
+	default:
+		return blueprint.Blueprint{}, nil, errors_package.New("blueprint must be in json or toml format")
+	}
+}
+
+// blueprintValidationErrors returns a response error for each problem found
+// in a blueprint
+func blueprintValidationErrors(validationErrors blueprint.ValidationErrors) []responseError {
+	errors := make([]responseError, len(validationErrors))
+	for i, e := range validationErrors {
+		msg := e.Error()
+		if e.Suggestion != "" {
+			msg += " (" + e.Suggestion + ")"
+		}
+		errors[i] = responseError{
+			ID:  "BlueprintsError",
+			Msg: msg,
+		}
+	}
+	return errors
+}
+
 func (api *API) blueprintsNewHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
 	if !verifyRequestVersion(writer, params, 0) {
 		return
@@ -1597,16 +2016,7 @@ func (api *API) blueprintsNewHandler(writer http.ResponseWriter, request *http.R
 		return
 	}
 
-	var blueprint blueprint.Blueprint
-	var err error
-	if contentType[0] == "application/json" {
-		err = json.NewDecoder(request.Body).Decode(&blueprint)
-	} else if contentType[0] == "text/x-toml" {
-		_, err = toml.DecodeReader(request.Body, &blueprint)
-	} else {
-		err = errors_package.New("blueprint must be in json or toml format")
-	}
-
+	blueprint, validationErrors, err := decodeBlueprint(contentType[0], request.Body)
 	if err != nil {
 		errors := responseError{
 			ID:  "BlueprintsError",
@@ -1620,6 +2030,11 @@ func (api *API) blueprintsNewHandler(writer http.ResponseWriter, request *http.R
 		return
 	}
 
+	if validationErrors = append(validationErrors, blueprint.Validate()...); len(validationErrors) > 0 {
+		statusResponseError(writer, http.StatusBadRequest, blueprintValidationErrors(validationErrors)...)
+		return
+	}
+
 	commitMsg := "Recipe " + blueprint.Name + ", version " + blueprint.Version + " saved."
 	err = api.store.PushBlueprint(blueprint, commitMsg)
 	if err != nil {
@@ -1658,16 +2073,7 @@ func (api *API) blueprintsWorkspaceHandler(writer http.ResponseWriter, request *
 		return
 	}
 
-	var blueprint blueprint.Blueprint
-	var err error
-	if contentType[0] == "application/json" {
-		err = json.NewDecoder(request.Body).Decode(&blueprint)
-	} else if contentType[0] == "text/x-toml" {
-		_, err = toml.DecodeReader(request.Body, &blueprint)
-	} else {
-		err = errors_package.New("blueprint must be in json or toml format")
-	}
-
+	blueprint, validationErrors, err := decodeBlueprint(contentType[0], request.Body)
 	if err != nil {
 		errors := responseError{
 			ID:  "BlueprintsError",
@@ -1681,6 +2087,11 @@ func (api *API) blueprintsWorkspaceHandler(writer http.ResponseWriter, request *
 		return
 	}
 
+	if validationErrors = append(validationErrors, blueprint.Validate()...); len(validationErrors) > 0 {
+		statusResponseError(writer, http.StatusBadRequest, blueprintValidationErrors(validationErrors)...)
+		return
+	}
+
 	err = api.store.PushBlueprintToWorkspace(blueprint)
 	if err != nil {
 		errors := responseError{
@@ -1812,12 +2223,15 @@ func (api *API) composeHandler(writer http.ResponseWriter, request *http.Request
 
 	// https://weldr.io/lorax/pylorax.api.html#pylorax.api.v0.v0_compose_start
 	type ComposeRequest struct {
-		BlueprintName string         `json:"blueprint_name"`
-		ComposeType   string         `json:"compose_type"`
-		Size          uint64         `json:"size"`
-		OSTree        OSTreeRequest  `json:"ostree"`
-		Branch        string         `json:"branch"`
-		Upload        *uploadRequest `json:"upload"`
+		BlueprintName    string              `json:"blueprint_name"`
+		BlueprintVersion string              `json:"blueprint_version,omitempty"`
+		ComposeType      string              `json:"compose_type"`
+		Size             uint64              `json:"size"`
+		OSTree           OSTreeRequest       `json:"ostree"`
+		Branch           string              `json:"branch"`
+		Upload           *uploadRequest      `json:"upload"`
+		Hook             *hookUploadSettings `json:"hook,omitempty"`
+		Variables        map[string]string   `json:"variables,omitempty"`
 	}
 	type ComposeReply struct {
 		BuildID uuid.UUID `json:"build_id"`
@@ -1865,8 +2279,43 @@ func (api *API) composeHandler(writer http.ResponseWriter, request *http.Request
 	var targets []*target.Target
 	if isRequestVersionAtLeast(params, 1) && cr.Upload != nil {
 		t := uploadRequestToTarget(*cr.Upload, imageType)
+		switch options := t.Options.(type) {
+		case *target.AWSTargetOptions:
+			if options.Endpoint == "" {
+				err = awsupload.CheckRegions(options.Region, options.CopyToRegions)
+			}
+		case *target.AzureTargetOptions:
+			_, err = azureCloud(options)
+		case *target.GCPTargetOptions:
+			err = gcp.CheckGuestOSFeatures(options.GuestOSFeatures)
+		case *target.PluginTargetOptions:
+			// plugins are installed on the workers, so only the name
+			// can be checked here
+			if options.Plugin == "" {
+				err = errors_package.New("plugin uploads need the name of a plugin")
+			}
+		}
+		if err != nil {
+			errors := responseError{
+				ID:  "UploadError",
+				Msg: err.Error(),
+			}
+			statusResponseError(writer, http.StatusBadRequest, errors)
+			return
+		}
 		targets = append(targets, t)
 	}
+	if isRequestVersionAtLeast(params, 1) && cr.Hook != nil {
+		if cr.Hook.URL == "" && cr.Hook.Command == "" {
+			errors := responseError{
+				ID:  "UploadError",
+				Msg: "hook needs a url or a command",
+			}
+			statusResponseError(writer, http.StatusBadRequest, errors)
+			return
+		}
+		targets = append(targets, hookToTarget(*cr.Hook, imageType))
+	}
 
 	bp := api.store.GetBlueprintCommitted(cr.BlueprintName)
 	if bp == nil {
@@ -1878,7 +2327,46 @@ func (api *API) composeHandler(writer http.ResponseWriter, request *http.Request
 		return
 	}
 
-	packages, buildPackages, err := api.depsolveBlueprint(bp, imageType)
+	if cr.BlueprintVersion != "" && cr.BlueprintVersion != bp.Version {
+		bp, err = api.store.GetBlueprintVersion(cr.BlueprintName, cr.BlueprintVersion)
+		if err != nil {
+			errors := responseError{
+				ID:  "UnknownBlueprintVersion",
+				Msg: fmt.Sprintf("Unknown version %s of blueprint %s", cr.BlueprintVersion, cr.BlueprintName),
+			}
+			statusResponseError(writer, http.StatusBadRequest, errors)
+			return
+		}
+	}
+
+	if bp.HasVariables() || len(cr.Variables) > 0 {
+		expanded, err := bp.Expand(cr.Variables)
+		if err != nil {
+			errors := responseError{
+				ID:  "BlueprintsError",
+				Msg: fmt.Sprintf("%s: %v", cr.BlueprintName, err),
+			}
+			statusResponseError(writer, http.StatusBadRequest, errors)
+			return
+		}
+		bp = &expanded
+	}
+
+	for _, t := range targets {
+		if options, ok := t.Options.(*target.ExportTargetOptions); ok {
+			options.ExportFilename, err = expandExportFilename(options.ExportFilename, bp, imageType, t.Created)
+			if err != nil {
+				errors := responseError{
+					ID:  "InvalidChars",
+					Msg: err.Error(),
+				}
+				statusResponseError(writer, http.StatusBadRequest, errors)
+				return
+			}
+		}
+	}
+
+	unknownGroups, err := api.unknownGroups(request.Context(), bp)
 	if err != nil {
 		errors := responseError{
 			ID:  "DepsolveError",
@@ -1886,6 +2374,30 @@ func (api *API) composeHandler(writer http.ResponseWriter, request *http.Request
 		}
 		statusResponseError(writer, http.StatusInternalServerError, errors)
 		return
+	} else if len(unknownGroups) > 0 {
+		errors := responseError{
+			ID:  "UnknownGroup",
+			Msg: fmt.Sprintf("%s: unknown package groups: %s", bp.Name, strings.Join(unknownGroups, ", ")),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+
+	// Composes of the frozen version of a blueprint reuse its packages
+	lockfile := api.store.GetLockfile(bp.Name)
+	if lockfile != nil && lockfile.BlueprintVersion != bp.Version {
+		lockfile = nil
+	}
+
+	packages, buildPackages, _, err := api.depsolveBlueprint(request.Context(), bp, imageType, lockfile)
+	if err != nil {
+		errors := responseError{
+			ID:  "DepsolveError",
+			Msg: err.Error(),
+		}
+		errors.addDepsolveDetails(bp, err)
+		statusResponseError(writer, http.StatusInternalServerError, errors)
+		return
 	}
 
 	// Check for test parameter
@@ -1907,15 +2419,40 @@ func (api *API) composeHandler(writer http.ResponseWriter, request *http.Request
 	}
 	seed := bigSeed.Int64()
 
-	manifest, err := imageType.Manifest(bp.Customizations,
-		distro.ImageOptions{
-			Size: size,
-			OSTree: distro.OSTreeImageOptions{
-				Ref:    cr.OSTree.Ref,
-				Parent: cr.OSTree.Parent,
-			},
+	imageOptions := distro.ImageOptions{
+		Size: size,
+		OSTree: distro.OSTreeImageOptions{
+			Ref:    cr.OSTree.Ref,
+			Parent: cr.OSTree.Parent,
 		},
-		api.allRepositories(),
+		Containers:     bp.Containers,
+		EnabledModules: bp.EnabledModules,
+		BuildInfo:      distro.NewBuildInfo(composeID, bp.Name, bp.Version, imageType, packages, time.Now()),
+	}
+	if subscription := bp.Customizations.GetSubscription(); subscription != nil {
+		imageOptions.Subscription = &distro.SubscriptionImageOptions{
+			Organization:  subscription.Organization,
+			ActivationKey: subscription.ActivationKey,
+			ServerUrl:     subscription.ServerURL,
+			BaseUrl:       subscription.BaseURL,
+			Insights:      subscription.Insights,
+			APIType:       "weldr",
+		}
+	}
+
+	repos, err := api.rpmmd.ResolveGPGKeys(request.Context(), api.allRepositories())
+	if err != nil {
+		errors := responseError{
+			ID:  "ManifestCreationFailed",
+			Msg: fmt.Sprintf("failed to fetch GPG keys: %v", err),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+
+	manifest, err := imageType.Manifest(bp.Customizations,
+		imageOptions,
+		repos,
 		packages,
 		buildPackages,
 		seed)
@@ -1931,21 +2468,25 @@ func (api *API) composeHandler(writer http.ResponseWriter, request *http.Request
 	testMode := q.Get("test")
 	if testMode == "1" {
 		// Create a failed compose
-		err = api.store.PushTestCompose(composeID, manifest, imageType, bp, size, targets, false)
+		err = api.store.PushTestCompose(composeID, manifest, packages, imageType, bp, size, targets, false)
 	} else if testMode == "2" {
 		// Create a successful compose
-		err = api.store.PushTestCompose(composeID, manifest, imageType, bp, size, targets, true)
+		err = api.store.PushTestCompose(composeID, manifest, packages, imageType, bp, size, targets, true)
 	} else {
 		var jobId uuid.UUID
 
+		// the weldr API serves a single tenant, whose jobs are queued
+		// in the default channel
 		jobId, err = api.workers.EnqueueOSBuild(api.arch.Name(), &worker.OSBuildJob{
 			Manifest:        manifest,
 			Targets:         targets,
 			ImageName:       imageType.Filename(),
 			StreamOptimized: imageType.Name() == "vmdk", // https://github.com/osbuild/osbuild/issues/528
-		})
+			Distro:          api.distro.Name(),
+			ImageType:       imageType.Name(),
+		}, "")
 		if err == nil {
-			err = api.store.PushCompose(composeID, manifest, imageType, bp, size, targets, jobId)
+			err = api.store.PushCompose(composeID, manifest, packages, imageType, bp, size, targets, jobId)
 		}
 	}
 
@@ -2016,7 +2557,7 @@ func (api *API) composeDeleteHandler(writer http.ResponseWriter, request *http.R
 			continue
 		}
 
-		err = api.store.DeleteCompose(id)
+		err = api.deleteCompose(id, compose)
 		if err != nil {
 			errors = append(errors, composeDeleteError{
 				"ComposeError",
@@ -2025,15 +2566,6 @@ func (api *API) composeDeleteHandler(writer http.ResponseWriter, request *http.R
 			continue
 		}
 
-		// Delete artifacts from the worker server or — if that doesn't
-		// have this job — the compat output dir. Ignore errors,
-		// because there's no point of reporting them to the client
-		// after the compose itself has already been deleted.
-		err = api.workers.DeleteArtifacts(compose.ImageBuild.JobID)
-		if err == jobqueue.ErrNotExist && api.compatOutputDir != "" {
-			_ = os.RemoveAll(path.Join(api.compatOutputDir, id.String()))
-		}
-
 		results = append(results, composeDeleteStatus{id, true})
 	}
 
@@ -2046,6 +2578,64 @@ func (api *API) composeDeleteHandler(writer http.ResponseWriter, request *http.R
 	common.PanicOnError(err)
 }
 
+// deleteCompose deletes a finished compose from the store and its artifacts
+func (api *API) deleteCompose(id uuid.UUID, compose store.Compose) error {
+	err := api.store.DeleteCompose(id)
+	if err != nil {
+		return err
+	}
+
+	// Delete artifacts from the worker server or — if that doesn't
+	// have this job — the compat output dir. Ignore errors,
+	// because there's no point of reporting them to the client
+	// after the compose itself has already been deleted.
+	err = api.workers.DeleteArtifacts(compose.ImageBuild.JobID)
+	if err == jobqueue.ErrNotExist && api.compatOutputDir != "" {
+		_ = os.RemoveAll(path.Join(api.compatOutputDir, id.String()))
+	}
+
+	return nil
+}
+
+func (api *API) composePinHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	api.setComposePinned(writer, params, true)
+}
+
+func (api *API) composeUnpinHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	api.setComposePinned(writer, params, false)
+}
+
+// setComposePinned pins a compose, so that it is never deleted by the
+// retention policy, or unpins it
+func (api *API) setComposePinned(writer http.ResponseWriter, params httprouter.Params, pinned bool) {
+	if !verifyRequestVersion(writer, params, 1) {
+		return
+	}
+
+	uuidString := params.ByName("uuid")
+	id, err := uuid.Parse(uuidString)
+	if err != nil {
+		errors := responseError{
+			ID:  "UnknownUUID",
+			Msg: fmt.Sprintf("%s is not a valid build uuid", uuidString),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+
+	err = api.store.SetComposePinned(id, pinned)
+	if err != nil {
+		errors := responseError{
+			ID:  "UnknownUUID",
+			Msg: fmt.Sprintf("Compose %s doesn't exist", uuidString),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+
+	statusResponseOK(writer)
+}
+
 func (api *API) composeCancelHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
 	if !verifyRequestVersion(writer, params, 0) {
 		return
@@ -2122,21 +2712,28 @@ func (api *API) composeQueueHandler(writer http.ResponseWriter, request *http.Re
 		return
 	}
 
+	page, ok := composePageFromRequest(writer, request)
+	if !ok {
+		return
+	}
+
 	reply := struct {
 		New []*ComposeEntry `json:"new"`
 		Run []*ComposeEntry `json:"run"`
-	}{[]*ComposeEntry{}, []*ComposeEntry{}}
+		*composePage
+	}{[]*ComposeEntry{}, []*ComposeEntry{}, page}
 
 	includeUploads := isRequestVersionAtLeast(params, 1)
 
-	composes := api.store.GetAllComposes()
-	for id, compose := range composes {
-		composeStatus := api.getComposeStatus(compose)
-		switch composeStatus.State {
-		case ComposeWaiting:
-			reply.New = append(reply.New, composeToComposeEntry(id, compose, composeStatus, includeUploads))
-		case ComposeRunning:
-			reply.Run = append(reply.Run, composeToComposeEntry(id, compose, composeStatus, includeUploads))
+	// the queue is paginated as a whole, with the waiting composes first
+	composes := api.listComposes(ComposeWaiting, ComposeRunning)
+	start, end := page.apply(len(composes))
+	for _, c := range composes[start:end] {
+		entry := composeToComposeEntry(c.ID, c.Compose, c.Status, includeUploads)
+		if c.Status.State == ComposeWaiting {
+			reply.New = append(reply.New, entry)
+		} else {
+			reply.Run = append(reply.Run, entry)
 		}
 	}
 
@@ -2259,15 +2856,17 @@ func (api *API) composeInfoHandler(writer http.ResponseWriter, request *http.Req
 	}
 
 	var reply struct {
-		ID          uuid.UUID            `json:"id"`
-		Config      string               `json:"config"`    // anaconda config, let's ignore this field
-		Blueprint   *blueprint.Blueprint `json:"blueprint"` // blueprint not frozen!
-		Commit      string               `json:"commit"`    // empty for now
-		Deps        Dependencies         `json:"deps"`      // empty for now
-		ComposeType string               `json:"compose_type"`
-		QueueStatus string               `json:"queue_status"`
-		ImageSize   uint64               `json:"image_size"`
-		Uploads     []uploadResponse     `json:"uploads,omitempty"`
+		ID          uuid.UUID                 `json:"id"`
+		Config      string                    `json:"config"`    // anaconda config, let's ignore this field
+		Blueprint   *blueprint.Blueprint      `json:"blueprint"` // blueprint not frozen!
+		Commit      string                    `json:"commit"`    // empty for now
+		Deps        Dependencies              `json:"deps"`      // empty for now
+		ComposeType string                    `json:"compose_type"`
+		QueueStatus string                    `json:"queue_status"`
+		ImageSize   uint64                    `json:"image_size"`
+		Uploads     []uploadResponse          `json:"uploads,omitempty"`
+		Checksums   *worker.ArtifactChecksums `json:"checksums,omitempty"`
+		Pinned      bool                      `json:"pinned,omitempty"`
 	}
 
 	reply.ID = id
@@ -2283,13 +2882,155 @@ func (api *API) composeInfoHandler(writer http.ResponseWriter, request *http.Req
 	reply.ImageSize = compose.ImageBuild.Size
 
 	if isRequestVersionAtLeast(params, 1) {
-		reply.Uploads = targetsToUploadResponses(compose.ImageBuild.Targets, composeStatus.State)
+		reply.Uploads = targetsToUploadResponses(compose.ImageBuild.Targets, composeStatus)
+		reply.Checksums = composeStatus.Checksums
+		reply.Pinned = compose.Pinned
 	}
 
 	err = json.NewEncoder(writer).Encode(reply)
 	common.PanicOnError(err)
 }
 
+// composeEventsKeepalive is how often a comment is sent to clients which
+// follow a compose which does not change, so that proxies do not close the
+// connection
+var composeEventsKeepalive = 15 * time.Second
+
+// composeProgressEvent is the data of a "progress" event of
+// /compose/events, the progress of the upload a worker is running
+type composeProgressEvent struct {
+	UUID uuid.UUID `json:"uuid"`
+	uploadProgress
+}
+
+// composeEventsHandler streams the status of a compose as server-sent events,
+// instead of clients polling /compose/status. A "status" event with the same
+// entry as /compose/status returns is sent right away and then whenever the
+// compose changes, until it finished or failed. Since API version 1, a
+// "progress" event is sent whenever the worker reports the progress of an
+// upload. The progress of osbuild stages is not streamed, because osbuild
+// only reports the results of the stages when it is done.
+func (api *API) composeEventsHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	if !verifyRequestVersion(writer, params, 0) {
+		return
+	}
+
+	uuidString := params.ByName("uuid")
+	id, err := uuid.Parse(uuidString)
+	if err != nil {
+		errors := responseError{
+			ID:  "UnknownUUID",
+			Msg: fmt.Sprintf("%s is not a valid build uuid", uuidString),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+
+	if _, exists := api.store.GetCompose(id); !exists {
+		errors := responseError{
+			ID:  "UnknownUUID",
+			Msg: fmt.Sprintf("%s is not a valid build uuid", uuidString),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		errors := responseError{
+			ID:  "InternalServerError",
+			Msg: "streaming is not supported",
+		}
+		statusResponseError(writer, http.StatusInternalServerError, errors)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(composeEventsKeepalive)
+	defer keepalive.Stop()
+
+	includeUploads := isRequestVersionAtLeast(params, 1)
+	var sent, sentProgress []byte
+	for {
+		changed := api.workers.Changed()
+
+		compose, exists := api.store.GetCompose(id)
+		if !exists {
+			// the compose was deleted
+			return
+		}
+		composeStatus := api.getComposeStatus(compose)
+		data, err := json.Marshal(composeToComposeEntry(id, compose, composeStatus, includeUploads))
+		common.PanicOnError(err)
+
+		if !bytes.Equal(data, sent) {
+			_, err = fmt.Fprintf(writer, "event: status\ndata: %s\n\n", data)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+			sent = data
+		}
+
+		if progress := uploadProgressEvent(compose, composeStatus); includeUploads && progress != nil {
+			data, err = json.Marshal(progress)
+			common.PanicOnError(err)
+
+			if !bytes.Equal(data, sentProgress) {
+				_, err = fmt.Fprintf(writer, "event: progress\ndata: %s\n\n", data)
+				if err != nil {
+					return
+				}
+				flusher.Flush()
+				sentProgress = data
+			}
+		}
+
+		if composeStatus.State == ComposeFinished || composeStatus.State == ComposeFailed {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-keepalive.C:
+			_, err = fmt.Fprint(writer, ": keepalive\n\n")
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-request.Context().Done():
+			return
+		}
+	}
+}
+
+// uploadProgressEvent returns the progress of the upload of a running
+// compose, or nil if the worker has not reported any
+func uploadProgressEvent(compose store.Compose, status *composeStatus) *composeProgressEvent {
+	p := status.Progress
+	if status.State != ComposeRunning || p == nil || p.Total <= 0 {
+		return nil
+	}
+
+	for _, t := range compose.ImageBuild.Targets {
+		if progressOfTarget(p, t) {
+			return &composeProgressEvent{
+				UUID: t.Uuid,
+				uploadProgress: uploadProgress{
+					Transferred: p.Transferred,
+					Total:       p.Total,
+					Percent:     int(p.Transferred * 100 / p.Total),
+				},
+			}
+		}
+	}
+	return nil
+}
+
 func (api *API) composeImageHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
 	if !verifyRequestVersion(writer, params, 0) {
 		return
@@ -2404,10 +3145,153 @@ func (api *API) composeMetadataHandler(writer http.ResponseWriter, request *http
 	_, err = tw.Write(metadata)
 	common.PanicOnError(err)
 
+	writePackagesLockfile(tw, uuid, compose.ImageBuild.Packages)
+	api.writeSBOM(tw, uuid, compose, composeStatus)
+	writeChecksums(tw, uuid, composeStatus.Checksums)
+
 	err = tw.Close()
 	common.PanicOnError(err)
 }
 
+// writePackagesLockfile adds the lockfile of the packages of a compose to its
+// metadata, which lets license-compliance reports be created without
+// querying the repositories again. Composes from before the packages were
+// stored don't get it.
+func writePackagesLockfile(tw *tar.Writer, id uuid.UUID, packages []rpmmd.PackageSpec) {
+	if len(packages) == 0 {
+		return
+	}
+
+	lockfile, err := rpmmd.MarshalLockfile(packages)
+	common.PanicOnError(err)
+
+	hdr := &tar.Header{
+		Name:    id.String() + "-packages.json",
+		Mode:    0644,
+		Size:    int64(len(lockfile)),
+		ModTime: time.Now().Truncate(time.Second),
+	}
+	err = tw.WriteHeader(hdr)
+	common.PanicOnError(err)
+	_, err = tw.Write(lockfile)
+	common.PanicOnError(err)
+}
+
+// composeSBOM returns the SPDX document of the packages of a compose, or nil
+// for composes from before the packages were stored
+func (api *API) composeSBOM(id uuid.UUID, compose store.Compose, status *composeStatus) []byte {
+	if len(compose.ImageBuild.Packages) == 0 {
+		return nil
+	}
+
+	created := status.Queued
+	if created.IsZero() {
+		created = time.Now()
+	}
+	name := compose.Blueprint.Name + "-" + compose.Blueprint.Version
+	doc := sbom.NewDocument(id, name, api.distro.Name(), compose.ImageBuild.Packages, created)
+	data, err := json.MarshalIndent(doc, "", "  ")
+	common.PanicOnError(err)
+	return data
+}
+
+// writeSBOM adds the SBOM of a compose to its metadata
+func (api *API) writeSBOM(tw *tar.Writer, id uuid.UUID, compose store.Compose, status *composeStatus) {
+	data := api.composeSBOM(id, compose, status)
+	if data == nil {
+		return
+	}
+
+	hdr := &tar.Header{
+		Name:    id.String() + "-sbom.spdx.json",
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now().Truncate(time.Second),
+	}
+	err := tw.WriteHeader(hdr)
+	common.PanicOnError(err)
+	_, err = tw.Write(data)
+	common.PanicOnError(err)
+}
+
+// composeSBOMHandler returns the software bill of materials of a compose, in
+// the JSON format of SPDX
+func (api *API) composeSBOMHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	if !verifyRequestVersion(writer, params, 1) {
+		return
+	}
+
+	uuidString := params.ByName("uuid")
+	id, err := uuid.Parse(uuidString)
+	if err != nil {
+		errors := responseError{
+			ID:  "UnknownUUID",
+			Msg: fmt.Sprintf("%s is not a valid build uuid", uuidString),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+
+	compose, exists := api.store.GetCompose(id)
+	if !exists {
+		errors := responseError{
+			ID:  "UnknownUUID",
+			Msg: fmt.Sprintf("Compose %s doesn't exist", uuidString),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+
+	data := api.composeSBOM(id, compose, api.getComposeStatus(compose))
+	if data == nil {
+		errors := responseError{
+			ID:  "BuildMissingFile",
+			Msg: fmt.Sprintf("Compose %s has no SBOM, because it was created before its packages were stored", uuidString),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+
+	writer.Header().Set("Content-Disposition", "attachment; filename="+id.String()+"-sbom.spdx.json")
+	_, err = writer.Write(data)
+	common.PanicOnError(err)
+}
+
+// writeChecksums adds SHA256SUMS and the detached signatures of a compose to
+// its metadata. They are named like the other files, with the ID of the
+// compose as prefix, while SHA256SUMS lists the names the worker built the
+// artifacts with.
+func writeChecksums(tw *tar.Writer, id uuid.UUID, checksums *worker.ArtifactChecksums) {
+	if checksums == nil {
+		return
+	}
+
+	files := map[string]string{
+		artifact.SHA256SumsFilename: checksums.SHA256Sums,
+	}
+	for name, signature := range checksums.Signatures {
+		files[name+artifact.SignatureSuffix] = signature
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		hdr := &tar.Header{
+			Name:    id.String() + "-" + name,
+			Mode:    0644,
+			Size:    int64(len(files[name])),
+			ModTime: time.Now().Truncate(time.Second),
+		}
+		err := tw.WriteHeader(hdr)
+		common.PanicOnError(err)
+		_, err = tw.Write([]byte(files[name]))
+		common.PanicOnError(err)
+	}
+}
+
 // composeResultsHandler returns a tar of the metadata, logs, and image from a compose
 func (api *API) composeResultsHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
 	if !verifyRequestVersion(writer, params, 0) {
@@ -2464,6 +3348,10 @@ func (api *API) composeResultsHandler(writer http.ResponseWriter, request *http.
 	_, err = tw.Write(metadata)
 	common.PanicOnError(err)
 
+	writePackagesLockfile(tw, uuid, compose.ImageBuild.Packages)
+	api.writeSBOM(tw, uuid, compose, composeStatus)
+	writeChecksums(tw, uuid, composeStatus.Checksums)
+
 	// Add the logs
 	var fileContents bytes.Buffer
 	if composeStatus.Result != nil {
@@ -2614,19 +3502,22 @@ func (api *API) composeFinishedHandler(writer http.ResponseWriter, request *http
 		return
 	}
 
+	page, ok := composePageFromRequest(writer, request)
+	if !ok {
+		return
+	}
+
 	reply := struct {
 		Finished []*ComposeEntry `json:"finished"`
-	}{[]*ComposeEntry{}}
+		*composePage
+	}{[]*ComposeEntry{}, page}
 
 	includeUploads := isRequestVersionAtLeast(params, 1)
-	for id, compose := range api.store.GetAllComposes() {
-		composeStatus := api.getComposeStatus(compose)
-		if composeStatus.State != ComposeFinished {
-			continue
-		}
-		reply.Finished = append(reply.Finished, composeToComposeEntry(id, compose, composeStatus, includeUploads))
+	composes := api.listComposes(ComposeFinished)
+	start, end := page.apply(len(composes))
+	for _, c := range composes[start:end] {
+		reply.Finished = append(reply.Finished, composeToComposeEntry(c.ID, c.Compose, c.Status, includeUploads))
 	}
-	sortComposeEntries(reply.Finished)
 
 	err := json.NewEncoder(writer).Encode(reply)
 	common.PanicOnError(err)
@@ -2637,26 +3528,49 @@ func (api *API) composeFailedHandler(writer http.ResponseWriter, request *http.R
 		return
 	}
 
+	page, ok := composePageFromRequest(writer, request)
+	if !ok {
+		return
+	}
+
 	reply := struct {
 		Failed []*ComposeEntry `json:"failed"`
-	}{[]*ComposeEntry{}}
+		*composePage
+	}{[]*ComposeEntry{}, page}
 
 	includeUploads := isRequestVersionAtLeast(params, 1)
-	for id, compose := range api.store.GetAllComposes() {
-		composeStatus := api.getComposeStatus(compose)
-		if composeStatus.State != ComposeFailed {
-			continue
-		}
-		reply.Failed = append(reply.Failed, composeToComposeEntry(id, compose, composeStatus, includeUploads))
+	composes := api.listComposes(ComposeFailed)
+	start, end := page.apply(len(composes))
+	for _, c := range composes[start:end] {
+		reply.Failed = append(reply.Failed, composeToComposeEntry(c.ID, c.Compose, c.Status, includeUploads))
 	}
-	sortComposeEntries(reply.Failed)
 
 	err := json.NewEncoder(writer).Encode(reply)
 	common.PanicOnError(err)
 }
 
-func (api *API) fetchPackageList() (rpmmd.PackageList, error) {
-	packages, _, err := api.rpmmd.FetchMetadata(api.allRepositories(), api.distro.ModulePlatformID(), api.arch.Name())
+// parsePackageQuery parses the offset, limit, repo, arch and sort parameters
+// of the routes listing packages
+func parsePackageQuery(values url.Values) (rpmmd.PackageQuery, error) {
+	offset, limit, err := parseOffsetAndLimit(values)
+	if err != nil {
+		return rpmmd.PackageQuery{}, err
+	}
+	return rpmmd.PackageQuery{
+		Repos:  parseList(values, "repo"),
+		Arches: parseList(values, "arch"),
+		Sort:   values.Get("sort"),
+		Offset: offset,
+		Limit:  limit,
+	}, nil
+}
+
+func (api *API) searchPackages(ctx context.Context, query rpmmd.PackageQuery) (rpmmd.PackageSearchResult, error) {
+	return api.rpmmd.SearchPackages(ctx, query, api.allRepositories(), api.distro.ModulePlatformID(), api.arch.Name())
+}
+
+func (api *API) fetchPackageList(ctx context.Context) (rpmmd.PackageList, error) {
+	packages, _, err := api.rpmmd.FetchMetadata(ctx, api.allRepositories(), api.distro.ModulePlatformID(), api.arch.Name())
 	return packages, err
 }
 
@@ -2669,32 +3583,115 @@ func (api *API) allRepositories() []rpmmd.RepoConfig {
 	return repos
 }
 
-func (api *API) depsolveBlueprint(bp *blueprint.Blueprint, imageType distro.ImageType) ([]rpmmd.PackageSpec, []rpmmd.PackageSpec, error) {
+// unknownGroups returns the comps groups and environments requested by bp
+// which none of the repositories provide. The repositories are only asked
+// when bp requests any groups.
+func (api *API) unknownGroups(ctx context.Context, bp *blueprint.Blueprint) ([]string, error) {
+	var names []string
+	for _, group := range bp.Groups {
+		names = append(names, group.Name)
+	}
+	for _, pkg := range bp.Packages {
+		if pkg.IsGroup() {
+			names = append(names, strings.TrimPrefix(pkg.Name, "@"))
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	groups, err := api.rpmmd.ListPackageGroups(ctx, api.allRepositories(), api.distro.ModulePlatformID(), api.arch.Name())
+	if err != nil {
+		return nil, err
+	}
+	var unknown []string
+	for _, name := range names {
+		// dnf refers to environments with an optional ^ prefix
+		if _, ok := groups.Find(strings.TrimPrefix(name, "^")); !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown, nil
+}
+
+// depsolveBlueprint resolves the packages of a blueprint and, if imageType is
+// not nil, of the image type and its build root, and returns them together
+// with the checksums of the repositories. If lockfile is not nil, the
+// packages recorded in it are requested in their exact versions, and an
+// error is returned if any of them is not available anymore.
+func (api *API) depsolveBlueprint(ctx context.Context, bp *blueprint.Blueprint, imageType distro.ImageType, lockfile *store.Lockfile) ([]rpmmd.PackageSpec, []rpmmd.PackageSpec, map[string]string, error) {
 	repos := api.allRepositories()
 
 	specs := bp.GetPackages()
-	excludeSpecs := []string{}
+	excludeSpecs := bp.GetExcludedPackages()
 	if imageType != nil {
 		// When the output type is known, include the base packages in the depsolve
 		// transaction.
 		specs, excludeSpecs = imageType.Packages(*bp)
 	}
-
-	packages, _, err := api.rpmmd.Depsolve(specs, excludeSpecs, repos, api.distro.ModulePlatformID(), api.arch.Name())
-	if err != nil {
-		return nil, nil, err
+	var pinned []string
+	if lockfile != nil {
+		for _, pkg := range lockfile.Packages {
+			pinned = append(pinned, pkg.GetNEVRA())
+		}
+		specs = append(specs, pinned...)
 	}
 
-	buildPackages := []rpmmd.PackageSpec{}
+	osSet := rpmmd.PackageSet{Include: specs, Exclude: excludeSpecs, Modules: bp.GetModuleStreams(), Pinned: pinned}
+	sets := map[string]rpmmd.PackageSet{rpmmd.OSPackageSet: osSet}
 	if imageType != nil {
+		osSet.NoWeakDeps = !imageType.InstallWeakDeps()
+		sets[rpmmd.OSPackageSet] = osSet
 		buildSpecs := imageType.BuildPackages()
-		buildPackages, _, err = api.rpmmd.Depsolve(buildSpecs, nil, repos, api.distro.ModulePlatformID(), api.arch.Name())
+		if len(bp.Containers) > 0 {
+			// the skopeo stage runs in the build root
+			buildSpecs = append(buildSpecs, "skopeo")
+		}
+		sets[rpmmd.BuildPackageSet] = rpmmd.PackageSet{Include: buildSpecs}
+	}
+
+	results, err := api.rpmmd.DepsolvePackageSets(ctx, sets, repos, api.distro.ModulePlatformID(), api.arch.Name())
+	if err != nil {
+		var setErr *rpmmd.PackageSetError
+		if lockfile != nil && errors_package.As(err, &setErr) && setErr.Name == rpmmd.OSPackageSet {
+			return nil, nil, nil, fmt.Errorf("cannot resolve the packages blueprint %s was frozen with: %v", bp.Name, err)
+		}
+		return nil, nil, nil, err
+	}
+	packages := results[rpmmd.OSPackageSet].Packages
+	if lockfile != nil {
+		err = verifyLockedPackages(lockfile.Packages, packages)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, fmt.Errorf("blueprint %s: %v", bp.Name, err)
 		}
 	}
 
-	return packages, buildPackages, err
+	buildPackages := []rpmmd.PackageSpec{}
+	if imageType != nil {
+		buildPackages = results[rpmmd.BuildPackageSet].Packages
+	}
+
+	return packages, buildPackages, results[rpmmd.OSPackageSet].Checksums, nil
+}
+
+// verifyLockedPackages checks that every locked package is part of packages
+// in its exact version and, if known, with the same checksum
+func verifyLockedPackages(locked, packages []rpmmd.PackageSpec) error {
+	resolved := make(map[string]rpmmd.PackageSpec, len(packages))
+	for _, pkg := range packages {
+		resolved[pkg.GetNEVRA()] = pkg
+	}
+	for _, pkg := range locked {
+		nevra := pkg.GetNEVRA()
+		found, ok := resolved[nevra]
+		if !ok {
+			return fmt.Errorf("frozen package %s is not available anymore", nevra)
+		}
+		if pkg.Checksum != "" && found.Checksum != "" && pkg.Checksum != found.Checksum {
+			return fmt.Errorf("frozen package %s changed in the repositories", nevra)
+		}
+	}
+	return nil
 }
 
 func (api *API) uploadsScheduleHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
@@ -2751,6 +3748,72 @@ func (api *API) uploadsCancelHandler(writer http.ResponseWriter, request *http.R
 	notImplementedHandler(writer, request, params)
 }
 
+// How long the checks of the settings of an upload may take
+const uploadValidationTimeout = 2 * time.Minute
+
+// uploadsValidateHandler checks the credentials and permissions of the
+// settings of an upload, so that mistakes in them are found before a
+// compose is started rather than after the image has been built
+func (api *API) uploadsValidateHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	if !verifyRequestVersion(writer, params, 1) {
+		return
+	}
+
+	type ValidateRequest struct {
+		ComposeType string         `json:"compose_type"`
+		Upload      *uploadRequest `json:"upload"`
+	}
+
+	contentType := request.Header["Content-Type"]
+	if len(contentType) != 1 || contentType[0] != "application/json" {
+		errors := responseError{
+			ID:  "MissingPost",
+			Msg: "upload must be json",
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+
+	var vr ValidateRequest
+	err := json.NewDecoder(request.Body).Decode(&vr)
+	if err != nil || vr.Upload == nil {
+		msg := "missing upload"
+		if err != nil {
+			msg = err.Error()
+		}
+		errors := responseError{
+			ID:  "UploadError",
+			Msg: msg,
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+
+	imageType, err := api.arch.GetImageType(vr.ComposeType)
+	if err != nil {
+		errors := responseError{
+			ID:  "UnknownComposeType",
+			Msg: fmt.Sprintf("Unknown compose type for architecture: %s", vr.ComposeType),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), uploadValidationTimeout)
+	defer cancel()
+	err = checkTarget(ctx, uploadRequestToTarget(*vr.Upload, imageType))
+	if err != nil {
+		errors := responseError{
+			ID:  "UploadError",
+			Msg: err.Error(),
+		}
+		statusResponseError(writer, http.StatusBadRequest, errors)
+		return
+	}
+
+	statusResponseOK(writer)
+}
+
 func (api *API) providersHandler(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
 	if !verifyRequestVersion(writer, params, 1) {
 		return
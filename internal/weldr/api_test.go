@@ -2,7 +2,9 @@ package weldr
 
 import (
 	"archive/tar"
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"io/ioutil"
@@ -11,6 +13,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -20,12 +23,15 @@ import (
 	test_distro "github.com/osbuild/osbuild-composer/internal/distro/fedoratest"
 	rpmmd_mock "github.com/osbuild/osbuild-composer/internal/mocks/rpmmd"
 	"github.com/osbuild/osbuild-composer/internal/rpmmd"
+	"github.com/osbuild/osbuild-composer/internal/sbom"
 	"github.com/osbuild/osbuild-composer/internal/store"
 	"github.com/osbuild/osbuild-composer/internal/target"
 	"github.com/osbuild/osbuild-composer/internal/test"
+	"github.com/osbuild/osbuild-composer/internal/worker"
 
 	"github.com/BurntSushi/toml"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
 
@@ -86,6 +92,7 @@ func TestBlueprintsNew(t *testing.T) {
 		{"POST", "/api/v0/blueprints/new", `{"name":"test","description":"Test","packages:}`, http.StatusBadRequest, `{"status":false,"errors":[{"id":"BlueprintsError","msg":"400 Bad Request: The browser (or proxy) sent a request that this server could not understand: unexpected EOF"}]}`},
 		{"POST", "/api/v0/blueprints/new", `{"name":"","description":"Test","packages":[{"name":"httpd","version":"2.4.*"}],"version":"0.0.0"}`, http.StatusBadRequest, `{"status":false,"errors":[{"id":"InvalidChars","msg":"Invalid characters in API path"}]}`},
 		{"POST", "/api/v0/blueprints/new", ``, http.StatusBadRequest, `{"status":false,"errors":[{"id":"BlueprintsError","msg":"Missing blueprint"}]}`},
+		{"POST", "/api/v0/blueprints/new", `{"name":"test","description":"Test","packages":[],"version":"0.0.0","customizations":{"hostnmae":"foo","user":[{"name":"alice","shell":"bash"}]}}`, http.StatusBadRequest, `{"status":false,"errors":[{"id":"BlueprintsError","msg":"customizations.hostnmae: unknown key \"hostnmae\" (did you mean \"hostname\"?)"},{"id":"BlueprintsError","msg":"customizations.user[0].shell: shell \"bash\" for user \"alice\" must be an absolute path (for example /bin/bash)"}]}`},
 	}
 
 	tempdir, err := ioutil.TempDir("", "weldr-tests-")
@@ -374,6 +381,15 @@ func TestSetPkgEVRA(t *testing.T) {
 	}
 	err = setPkgEVRA(deps, pkgs)
 	require.EqualErrorf(t, err, "dep-package0 missing from depsolve results", "setPkgEVRA missing package failed to return error")
+
+	// Test that groups are left alone
+	pkgs = []blueprint.Package{
+		{Name: "dep-package1", Version: "*"},
+		{Name: "@core", Version: "*"},
+	}
+	err = setPkgEVRA(deps, pkgs)
+	require.NoErrorf(t, err, "setPkgEVRA failed")
+	require.Equalf(t, "*", pkgs[1].Version, "setPkgEVRA changed a group")
 }
 
 func TestBlueprintsFreeze(t *testing.T) {
@@ -452,7 +468,9 @@ func TestBlueprintsDiff(t *testing.T) {
 		ExpectedStatus int
 		ExpectedJSON   string
 	}{
-		{"GET", "/api/v0/blueprints/diff/test/NEWEST/WORKSPACE", ``, http.StatusOK, `{"diff":[{"new":{"Package":{"name":"systemd","version":"123"}},"old":null},{"new":null,"old":{"Package":{"name":"httpd","version":"2.4.*"}}}]}`},
+		{"GET", "/api/v0/blueprints/diff/test/NEWEST/WORKSPACE", ``, http.StatusOK, `{"diff":[{"new":{"Version":"0.0.0"},"old":{"Version":"0.0.1"}},{"new":{"Package":{"name":"systemd","version":"123"}},"old":null},{"new":null,"old":{"Package":{"name":"httpd","version":"2.4.*"}}},{"new":{"Customizations.hostname":"foo"},"old":null}]}`},
+		{"GET", "/api/v0/blueprints/diff/test/NEWEST/NEWEST", ``, http.StatusOK, `{"diff":[]}`},
+		{"GET", "/api/v0/blueprints/diff/test/NEWEST/abcdef", ``, http.StatusBadRequest, `{"status":false,"errors":[{"id":"UnknownCommit","msg":"ggit-error: revspec 'abcdef' not found (-3)"}]}`},
 	}
 
 	tempdir, err := ioutil.TempDir("", "weldr-tests-")
@@ -462,7 +480,7 @@ func TestBlueprintsDiff(t *testing.T) {
 	for _, c := range cases {
 		api, _ := createWeldrAPI(tempdir, rpmmd_mock.BaseFixture)
 		test.SendHTTP(api, true, "POST", "/api/v0/blueprints/new", `{"name":"test","description":"Test","packages":[{"name":"httpd","version":"2.4.*"}],"version":"0.0.0"}`)
-		test.SendHTTP(api, true, "POST", "/api/v0/blueprints/workspace", `{"name":"test","description":"Test","packages":[{"name":"systemd","version":"123"}],"version":"0.0.0"}`)
+		test.SendHTTP(api, true, "POST", "/api/v0/blueprints/workspace", `{"name":"test","description":"Test","packages":[{"name":"systemd","version":"123"}],"version":"0.0.0","customizations":{"hostname":"foo"}}`)
 		test.TestRoute(t, api, true, c.Method, c.Path, c.Body, c.ExpectedStatus, c.ExpectedJSON)
 		test.SendHTTP(api, true, "DELETE", "/api/v0/blueprints/delete/test", ``)
 	}
@@ -511,6 +529,132 @@ func TestBlueprintsChanges(t *testing.T) {
 	test.SendHTTP(api, true, "DELETE", "/api/v0/blueprints/delete/"+id, ``)
 }
 
+func TestBlueprintsHistory(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "weldr-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	api, s := createWeldrAPI(tempdir, rpmmd_mock.NoComposesFixture)
+	ignoreFields := []string{"commit", "timestamp"}
+
+	test.SendHTTP(api, true, "POST", "/api/v0/blueprints/new", `{"name":"history","description":"Test","packages":[{"name":"httpd","version":"2.4.*"}],"version":"0.0.1"}`)
+	test.SendHTTP(api, true, "POST", "/api/v0/blueprints/new", `{"name":"history","description":"Test","packages":[{"name":"tmux","version":"*"}],"version":"0.0.1"}`)
+	test.TestRoute(t, api, true, "GET", "/api/v0/blueprints/history/history", ``, http.StatusNotFound, `{"status":false,"errors":[{"id":"HTTPError","msg":"Not Found","code":404}]}`)
+	test.TestRoute(t, api, true, "GET", "/api/v1/blueprints/history/unknown", ``, http.StatusNotFound, `{"status":false,"errors":[{"id":"UnknownBlueprint","msg":"Unknown blueprint name: unknown"}]}`)
+	test.TestRoute(t, api, true, "GET", "/api/v1/blueprints/history/history", ``, http.StatusOK, `{"name":"history","history":[`+
+		`{"version":"0.0.2","commit":"","message":"Recipe history, version 0.0.1 saved.","timestamp":"","blueprint":{"name":"history","description":"Test","version":"0.0.2","packages":[{"name":"tmux","version":"*"}],"modules":[],"groups":[]}},`+
+		`{"version":"0.0.1","commit":"","message":"Recipe history, version 0.0.1 saved.","timestamp":"","blueprint":{"name":"history","description":"Test","version":"0.0.1","packages":[{"name":"httpd","version":"2.4.*"}],"modules":[],"groups":[]}}]}`, ignoreFields...)
+
+	test.TestRoute(t, api, false, "POST", "/api/v1/compose", `{"blueprint_name":"history","blueprint_version":"1.0.0","compose_type":"qcow2","branch":"master"}`, http.StatusBadRequest, `{"status":false,"errors":[{"id":"UnknownBlueprintVersion","msg":"Unknown version 1.0.0 of blueprint history"}]}`)
+	test.TestRoute(t, api, false, "POST", "/api/v1/compose", `{"blueprint_name":"history","blueprint_version":"0.0.1","compose_type":"qcow2","branch":"master"}`, http.StatusOK, `{"status":true}`, "build_id")
+	composes := s.GetAllComposes()
+	require.Len(t, composes, 1)
+	for _, compose := range composes {
+		require.Equal(t, "0.0.1", compose.Blueprint.Version)
+		require.Equal(t, "httpd", compose.Blueprint.Packages[0].Name)
+	}
+}
+
+func TestBlueprintsExport(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "weldr-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	api, _ := createWeldrAPI(tempdir, rpmmd_mock.NoComposesFixture)
+
+	test.SendHTTP(api, false, "POST", "/api/v0/blueprints/new", `{"name":"exported","description":"Test","packages":[{"name":"dep-package1","version":"*"}],"version":"0.0.1"}`)
+	test.TestRoute(t, api, false, "GET", "/api/v1/blueprints/export/unknown", ``, http.StatusNotFound, `{"status":false,"errors":[{"id":"UnknownBlueprint","msg":"Unknown blueprint name: unknown"}]}`)
+	test.TestRoute(t, api, false, "GET", "/api/v1/blueprints/export/exported?image_type=unknown", ``, http.StatusBadRequest, `{"status":false,"errors":[{"id":"UnknownComposeType","msg":"Unknown compose type for architecture: unknown"}]}`)
+
+	blueprintJSON := `{"name":"exported","description":"Test","version":"0.0.1","packages":[{"name":"dep-package1","version":"*"}],"modules":[],"groups":[]}`
+	packagesJSON := `[{"name":"dep-package3","epoch":7,"version":"3.0.3","release":"1.fc30","arch":"x86_64"},{"name":"dep-package1","epoch":0,"version":"1.33","release":"2.fc30","arch":"x86_64"},{"name":"dep-package2","epoch":0,"version":"2.9","release":"1.fc30","arch":"x86_64"}]`
+	checksumsJSON := `{"base":"sha256:f34848ca92665c342abd5816c9e3eda0e82180671195362bcd0080544a3bc2ac"}`
+	test.TestRoute(t, api, false, "GET", "/api/v1/blueprints/export/exported", ``, http.StatusOK,
+		`{"blueprint":`+blueprintJSON+`,"distro":"fedora-30","arch":"x86_64","packages":`+packagesJSON+`,"checksums":`+checksumsJSON+`}`)
+	test.TestRoute(t, api, false, "GET", "/api/v1/blueprints/export/exported?image_type=qcow2", ``, http.StatusOK,
+		`{"blueprint":`+blueprintJSON+`,"distro":"fedora-30","arch":"x86_64","image-type":"qcow2","packages":`+packagesJSON+`,"build-packages":`+packagesJSON+`,"checksums":`+checksumsJSON+`}`)
+}
+
+func TestBlueprintsLock(t *testing.T) {
+	if len(os.Getenv("OSBUILD_COMPOSER_TEST_EXTERNAL")) > 0 {
+		t.Skip("This test is for internal testing only")
+	}
+
+	tempdir, err := ioutil.TempDir("", "weldr-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	api, s := createWeldrAPI(tempdir, rpmmd_mock.NoComposesFixture)
+
+	test.SendHTTP(api, false, "POST", "/api/v0/blueprints/new", `{"name":"locked","description":"Test","packages":[{"name":"dep-package1","version":"*"}],"version":"0.0.1"}`)
+	test.TestRoute(t, api, false, "POST", "/api/v1/blueprints/lock/unknown", ``, http.StatusNotFound, `{"status":false,"errors":[{"id":"UnknownBlueprint","msg":"Unknown blueprint name: unknown"}]}`)
+	test.TestRoute(t, api, false, "GET", "/api/v1/blueprints/lock/locked", ``, http.StatusNotFound, `{"status":false,"errors":[{"id":"UnknownLockfile","msg":"locked: blueprint is not frozen"}]}`)
+
+	lockReply := `{"name":"locked","version":"0.0.1","packages":["dep-package1-1.33-2.fc30.x86_64","dep-package2-2.9-1.fc30.x86_64","dep-package3-7:3.0.3-1.fc30.x86_64"],"checksums":{"base":"sha256:f34848ca92665c342abd5816c9e3eda0e82180671195362bcd0080544a3bc2ac"}}`
+	test.TestRoute(t, api, false, "POST", "/api/v1/blueprints/lock/locked", ``, http.StatusOK, lockReply, "created")
+	test.TestRoute(t, api, false, "GET", "/api/v1/blueprints/lock/locked", ``, http.StatusOK, lockReply, "created")
+
+	test.TestRoute(t, api, false, "POST", "/api/v1/compose", `{"blueprint_name":"locked","compose_type":"qcow2","branch":"master"}`, http.StatusOK, `{"status":true}`, "build_id")
+	require.Len(t, s.GetAllComposes(), 1)
+
+	// a frozen package which vanished from the repositories
+	lockfile := s.GetLockfile("locked")
+	require.NotNil(t, lockfile)
+	lockfile.Packages[0].Release = "2.fc30"
+	require.NoError(t, s.PushLockfile("locked", *lockfile))
+	test.TestRoute(t, api, false, "POST", "/api/v1/compose", `{"blueprint_name":"locked","compose_type":"qcow2","branch":"master"}`, http.StatusInternalServerError, `{"status":false,"errors":[{"id":"DepsolveError","msg":"blueprint locked: frozen package dep-package3-7:3.0.3-2.fc30.x86_64 is not available anymore"}]}`)
+
+	// other versions of the blueprint are not affected by the lockfile
+	test.SendHTTP(api, false, "POST", "/api/v0/blueprints/new", `{"name":"locked","description":"Test","packages":[{"name":"dep-package1","version":"*"}],"version":"0.0.2"}`)
+	test.TestRoute(t, api, false, "POST", "/api/v1/compose", `{"blueprint_name":"locked","compose_type":"qcow2","branch":"master"}`, http.StatusOK, `{"status":true}`, "build_id")
+	require.Len(t, s.GetAllComposes(), 2)
+
+	test.TestRoute(t, api, false, "DELETE", "/api/v1/blueprints/lock/locked", ``, http.StatusOK, `{"status":true}`)
+	test.TestRoute(t, api, false, "DELETE", "/api/v1/blueprints/lock/locked", ``, http.StatusNotFound, `{"status":false,"errors":[{"id":"UnknownLockfile","msg":"blueprint is not frozen: locked"}]}`)
+}
+
+func TestComposeVariables(t *testing.T) {
+	if len(os.Getenv("OSBUILD_COMPOSER_TEST_EXTERNAL")) > 0 {
+		t.Skip("This test is for internal testing only")
+	}
+
+	tempdir, err := ioutil.TempDir("", "weldr-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	api, s := createWeldrAPI(tempdir, rpmmd_mock.NoComposesFixture)
+
+	test.SendHTTP(api, false, "POST", "/api/v0/blueprints/new", `{"name":"templated","description":"Test","version":"0.0.1","customizations":{"hostname":"${PREFIX}-web"}}`)
+	test.TestRoute(t, api, false, "POST", "/api/v1/compose", `{"blueprint_name":"templated","compose_type":"qcow2","branch":"master"}`, http.StatusBadRequest, `{"status":false,"errors":[{"id":"BlueprintsError","msg":"templated: undefined variables: PREFIX"}]}`)
+	test.TestRoute(t, api, false, "POST", "/api/v1/compose", `{"blueprint_name":"templated","compose_type":"qcow2","branch":"master","variables":{"PREFIX":"prod"}}`, http.StatusOK, `{"status":true}`, "build_id")
+
+	composes := s.GetAllComposes()
+	require.Len(t, composes, 1)
+	for _, compose := range composes {
+		require.Equal(t, "prod-web", *compose.Blueprint.Customizations.GetHostname())
+	}
+	// the stored blueprint keeps its variables
+	require.Equal(t, "${PREFIX}-web", *s.GetBlueprintCommitted("templated").Customizations.GetHostname())
+}
+
+func TestComposeUnknownGroups(t *testing.T) {
+	if len(os.Getenv("OSBUILD_COMPOSER_TEST_EXTERNAL")) > 0 {
+		t.Skip("This test is for internal testing only")
+	}
+
+	tempdir, err := ioutil.TempDir("", "weldr-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	api, _ := createWeldrAPI(tempdir, rpmmd_mock.NoComposesFixture)
+
+	test.SendHTTP(api, false, "POST", "/api/v0/blueprints/new", `{"name":"groups","description":"Test","version":"0.0.1","packages":[{"name":"@standard"},{"name":"@^minimal-environment"}],"groups":[{"name":"Core"},{"name":"missing"}]}`)
+	test.TestRoute(t, api, false, "POST", "/api/v1/compose", `{"blueprint_name":"groups","compose_type":"qcow2","branch":"master"}`, http.StatusBadRequest, `{"status":false,"errors":[{"id":"UnknownGroup","msg":"groups: unknown package groups: missing"}]}`)
+
+	test.SendHTTP(api, false, "POST", "/api/v0/blueprints/new", `{"name":"groups","description":"Test","version":"0.0.2","groups":[{"name":"core"}]}`)
+	test.TestRoute(t, api, false, "POST", "/api/v1/compose", `{"blueprint_name":"groups","compose_type":"qcow2","branch":"master"}`, http.StatusOK, `{"status":true}`, "build_id")
+}
+
 func TestBlueprintsDepsolve(t *testing.T) {
 	var cases = []struct {
 		Fixture        rpmmd_mock.FixtureGenerator
@@ -520,6 +664,7 @@ func TestBlueprintsDepsolve(t *testing.T) {
 		{rpmmd_mock.BaseFixture, http.StatusOK, `{"blueprints":[{"blueprint":{"name":"test","description":"Test","version":"0.0.1","packages":[{"name":"dep-package1","version":"*"}],"groups":[],"modules":[{"name":"dep-package3","version":"*"}]},"dependencies":[{"name":"dep-package3","epoch":7,"version":"3.0.3","release":"1.fc30","arch":"x86_64"},{"name":"dep-package1","epoch":0,"version":"1.33","release":"2.fc30","arch":"x86_64"},{"name":"dep-package2","epoch":0,"version":"2.9","release":"1.fc30","arch":"x86_64"}]}],"errors":[]}`},
 		{rpmmd_mock.NonExistingPackage, http.StatusOK, `{"blueprints":[{"blueprint":{"name":"test","description":"Test","version":"0.0.1","packages":[{"name":"dep-package1","version":"*"}],"groups":[],"modules":[{"name":"dep-package3","version":"*"}]},"dependencies":[]}],"errors":[{"id":"BlueprintsError","msg":"test: DNF error occured: MarkingErrors: Error occurred when marking packages for installation: Problems in request:\nmissing packages: fash"}]}`},
 		{rpmmd_mock.BadDepsolve, http.StatusOK, `{"blueprints":[{"blueprint":{"name":"test","description":"Test","version":"0.0.1","packages":[{"name":"dep-package1","version":"*"}],"groups":[],"modules":[{"name":"dep-package3","version":"*"}]},"dependencies":[]}],"errors":[{"id":"BlueprintsError","msg":"test: DNF error occured: DepsolveError: There was a problem depsolving ['go2rpm']: \n Problem: conflicting requests\n  - nothing provides askalono-cli needed by go2rpm-1-4.fc31.noarch"}]}`},
+		{rpmmd_mock.DetailedBadDepsolve, http.StatusOK, `{"blueprints":[{"blueprint":{"name":"test","description":"Test","version":"0.0.1","packages":[{"name":"dep-package1","version":"*"}],"groups":[],"modules":[{"name":"dep-package3","version":"*"}]},"dependencies":[]}],"errors":[{"id":"BlueprintsError","msg":"test: DNF error occured: DepsolveError: There was a problem depsolving ['dep-package1']: \n Problem: conflicting requests\n  - nothing provides askalono-cli needed by dep-package1-1.33-2.fc30.x86_64 (caused by dep-package1)","details":{"missing_provides":["askalono-cli needed by dep-package1-1.33-2.fc30.x86_64"],"problems":["conflicting requests","nothing provides askalono-cli needed by dep-package1-1.33-2.fc30.x86_64"],"culprits":["dep-package1"]}}]}`},
 	}
 
 	tempdir, err := ioutil.TempDir("", "weldr-tests-")
@@ -580,6 +725,11 @@ func TestCompose(t *testing.T) {
 	require.NoError(t, err)
 	manifest, err := imgType.Manifest(nil, distro.ImageOptions{}, nil, nil, nil, 0)
 	require.NoError(t, err)
+	packages := []rpmmd.PackageSpec{
+		{Name: "dep-package3", Epoch: 7, Version: "3.0.3", Release: "1.fc30", Arch: "x86_64"},
+		{Name: "dep-package1", Version: "1.33", Release: "2.fc30", Arch: "x86_64"},
+		{Name: "dep-package2", Version: "2.9", Release: "1.fc30", Arch: "x86_64"},
+	}
 	expectedComposeLocal := &store.Compose{
 		Blueprint: &blueprint.Blueprint{
 			Name:           "test",
@@ -593,6 +743,7 @@ func TestCompose(t *testing.T) {
 			QueueStatus: common.IBWaiting,
 			ImageType:   imgType,
 			Manifest:    manifest,
+			Packages:    packages,
 		},
 	}
 	expectedComposeLocalAndAws := &store.Compose{
@@ -608,6 +759,7 @@ func TestCompose(t *testing.T) {
 			QueueStatus: common.IBWaiting,
 			ImageType:   imgType,
 			Manifest:    manifest,
+			Packages:    packages,
 			Targets: []*target.Target{
 				{
 					Name:      "org.osbuild.aws",
@@ -615,7 +767,7 @@ func TestCompose(t *testing.T) {
 					ImageName: "test_upload",
 					Options: &target.AWSTargetOptions{
 						Filename:        "test.img",
-						Region:          "frankfurt",
+						Region:          "eu-central-1",
 						AccessKeyID:     "accesskey",
 						SecretAccessKey: "secretkey",
 						Bucket:          "clay",
@@ -638,7 +790,11 @@ func TestCompose(t *testing.T) {
 	}{
 		{true, "POST", "/api/v0/compose", `{"blueprint_name": "http-server","compose_type": "qcow2","branch": "master"}`, http.StatusBadRequest, `{"status":false,"errors":[{"id":"UnknownBlueprint","msg":"Unknown blueprint name: http-server"}]}`, nil, []string{"build_id"}},
 		{false, "POST", "/api/v0/compose", `{"blueprint_name": "test","compose_type": "qcow2","branch": "master"}`, http.StatusOK, `{"status": true}`, expectedComposeLocal, []string{"build_id"}},
-		{false, "POST", "/api/v1/compose", `{"blueprint_name": "test","compose_type":"qcow2","branch":"master","upload":{"image_name":"test_upload","provider":"aws","settings":{"region":"frankfurt","accessKeyID":"accesskey","secretAccessKey":"secretkey","bucket":"clay","key":"imagekey"}}}`, http.StatusOK, `{"status": true}`, expectedComposeLocalAndAws, []string{"build_id"}},
+		{false, "POST", "/api/v1/compose", `{"blueprint_name": "test","compose_type":"qcow2","branch":"master","upload":{"image_name":"test_upload","provider":"aws","settings":{"region":"eu-central-1","accessKeyID":"accesskey","secretAccessKey":"secretkey","bucket":"clay","key":"imagekey"}}}`, http.StatusOK, `{"status": true}`, expectedComposeLocalAndAws, []string{"build_id"}},
+
+		{false, "POST", "/api/v1/compose", `{"blueprint_name": "test","compose_type":"qcow2","branch":"master","upload":{"image_name":"test_upload","provider":"aws","settings":{"region":"us-gov-west-1","copyToRegions":["us-east-1"],"bucket":"clay","key":"imagekey"}}}`, http.StatusBadRequest, `{"status":false,"errors":[{"id":"UploadError","msg":"cannot copy AMIs from us-gov-west-1 to us-east-1, which is in another partition (aws)"}]}`, nil, []string{"build_id"}},
+		{false, "POST", "/api/v1/compose", `{"blueprint_name": "test","compose_type":"qcow2","branch":"master","upload":{"image_name":"test_upload","provider":"azure","settings":{"cloud":"AzureMoonCloud","storageAccount":"account","container":"images"}}}`, http.StatusBadRequest, `{"status":false,"errors":[{"id":"UploadError","msg":"unknown azure cloud \"AzureMoonCloud\""}]}`, nil, []string{"build_id"}},
+		{false, "POST", "/api/v1/compose", `{"blueprint_name": "test","compose_type":"qcow2","branch":"master","upload":{"image_name":"test_upload","provider":"gcp","settings":{"bucket":"images","guest_os_features":["SEV"],"confidential_vm":true}}}`, http.StatusBadRequest, `{"status":false,"errors":[{"id":"UploadError","msg":"unknown guest OS feature SEV, expected one of VIRTIO_SCSI_MULTIQUEUE, UEFI_COMPATIBLE, SEV_CAPABLE, GVNIC, MULTI_IP_SUBNET, SECURE_BOOT"}]}`, nil, []string{"build_id"}},
 	}
 
 	tempdir, err := ioutil.TempDir("", "weldr-tests-")
@@ -836,6 +992,269 @@ func TestComposeLogs(t *testing.T) {
 	}
 }
 
+func TestComposeSBOM(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "weldr-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	api, _ := createWeldrAPI(tempdir, rpmmd_mock.BaseFixture)
+
+	response := test.SendHTTP(api, false, "GET", "/api/v1/compose/sbom/30000000-0000-0000-0000-000000000002", "")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	var doc sbom.Document
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&doc))
+	require.Equal(t, "SPDX-2.2", doc.SPDXVersion)
+	require.Len(t, doc.Packages, 1)
+	require.Equal(t, "bash", doc.Packages[0].Name)
+	require.Equal(t, "built from bash-5.0-1.src.rpm", doc.Packages[0].SourceInfo)
+
+	test.TestRoute(t, api, true, "GET", "/api/v1/compose/sbom/30000000-0000-0000-0000-000000000000", ``, http.StatusBadRequest,
+		`{"status":false,"errors":[{"id":"BuildMissingFile","msg":"Compose 30000000-0000-0000-0000-000000000000 has no SBOM, because it was created before its packages were stored"}]}`)
+	test.TestRoute(t, api, true, "GET", "/api/v0/compose/sbom/30000000-0000-0000-0000-000000000002", ``, http.StatusNotFound, "*")
+}
+
+func TestWriteChecksums(t *testing.T) {
+	id := uuid.MustParse("30000000-0000-0000-0000-000000000002")
+	checksums := &worker.ArtifactChecksums{
+		SHA256:     map[string]string{"disk.qcow2": "1044dec7206e8d7c9fbb4ae8f766668406d2567fc7fc1a160a9d4700fcf8f8e9"},
+		SHA256Sums: "1044dec7206e8d7c9fbb4ae8f766668406d2567fc7fc1a160a9d4700fcf8f8e9  disk.qcow2\n",
+		Signatures: map[string]string{
+			"SHA256SUMS": "-----BEGIN PGP SIGNATURE-----\nsums\n",
+			"disk.qcow2": "-----BEGIN PGP SIGNATURE-----\ndisk\n",
+		},
+	}
+
+	var buffer bytes.Buffer
+	tw := tar.NewWriter(&buffer)
+	writeChecksums(tw, id, nil)
+	writeChecksums(tw, id, checksums)
+	require.NoError(t, tw.Close())
+
+	files := make(map[string]string)
+	var names []string
+	tr := tar.NewReader(&buffer)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		names = append(names, h.Name)
+		files[h.Name] = string(content)
+	}
+	require.Equal(t, []string{
+		"30000000-0000-0000-0000-000000000002-SHA256SUMS",
+		"30000000-0000-0000-0000-000000000002-SHA256SUMS.asc",
+		"30000000-0000-0000-0000-000000000002-disk.qcow2.asc",
+	}, names)
+	require.Equal(t, checksums.SHA256Sums, files[names[0]])
+	require.Equal(t, checksums.Signatures["disk.qcow2"], files[names[2]])
+}
+
+func TestComposeMetadataPackages(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "weldr-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	api, _ := createWeldrAPI(tempdir, rpmmd_mock.BaseFixture)
+
+	for _, path := range []string{"/api/v1/compose/metadata/30000000-0000-0000-0000-000000000002", "/api/v1/compose/results/30000000-0000-0000-0000-000000000002"} {
+		response := test.SendHTTP(api, false, "GET", path, "")
+		require.Equalf(t, http.StatusOK, response.StatusCode, "%s: unexpected status code", path)
+
+		tr := tar.NewReader(response.Body)
+		_, err = tr.Next()
+		require.NoError(t, err)
+		h, err := tr.Next()
+		require.NoError(t, err)
+		require.Equal(t, "30000000-0000-0000-0000-000000000002-packages.json", h.Name)
+
+		var buffer bytes.Buffer
+		_, err = io.Copy(&buffer, tr)
+		require.NoError(t, err)
+		packages, err := rpmmd.UnmarshalLockfile(buffer.Bytes())
+		require.NoError(t, err)
+		require.Equal(t, []rpmmd.PackageSpec{{Name: "bash", Version: "5.0", Release: "1", Arch: "x86_64", License: "GPLv3+", SourceRPM: "bash-5.0-1.src.rpm"}}, packages)
+	}
+}
+
+func TestTargetsToUploadResponses(t *testing.T) {
+	created := time.Unix(1574857140, 0)
+	targets := []*target.Target{
+		{Uuid: uuid.MustParse("10000000-0000-0000-0000-000000000000"), ImageName: "awsimage", Name: "org.osbuild.aws", Created: created, Options: &target.AWSTargetOptions{Region: "frankfurt", AccessKeyID: "accesskey", SecretAccessKey: "secretkey", Bucket: "clay", Key: "imagekey", ShareWithAccounts: []string{"123456789012"}, CopyToRegions: []string{"us-east-1"}}},
+		{Uuid: uuid.MustParse("10000000-0000-0000-0000-000000000001"), ImageName: "azureimage", Name: "org.osbuild.azure", Created: created, Options: &target.AzureTargetOptions{StorageAccount: "account", StorageAccessKey: "accesskey", Container: "images", ClientSecret: "secret", ResourceGroup: "group", Location: "westeurope", Gallery: "gallery", GalleryImageDefinition: "fedora", GalleryImageVersion: "1.0.0", TargetRegions: []string{"northeurope"}}},
+		{Uuid: uuid.MustParse("10000000-0000-0000-0000-000000000002"), ImageName: "gcpimage", Name: "org.osbuild.gcp", Created: created, Options: &target.GCPTargetOptions{Credentials: []byte("{}"), Bucket: "images", Object: "gcpimage.tar.gz", Family: "fedora-33", Labels: map[string]string{"build": "1"}, ShareWith: []string{"user:alice@example.com"}, GuestOSFeatures: []string{"UEFI_COMPATIBLE"}}},
+		{Uuid: uuid.MustParse("10000000-0000-0000-0000-000000000003"), ImageName: "ociimage", Name: "org.osbuild.oci", Created: created, Options: &target.OCITargetOptions{User: "user", Tenancy: "tenancy", Region: "eu-frankfurt-1", Fingerprint: "aa:bb", PrivateKey: "key", Compartment: "compartment", Bucket: "images", Object: "disk.qcow2"}},
+		{Uuid: uuid.MustParse("10000000-0000-0000-0000-000000000004"), ImageName: "vmwareimage", Name: "org.osbuild.vmware", Created: created, Options: &target.VMWareTargetOptions{Host: "vcenter.example.com", Username: "user", Password: "password", Datacenter: "dc", Datastore: "ds", Template: true}},
+		{Uuid: uuid.MustParse("10000000-0000-0000-0000-000000000005"), ImageName: "pulpimage", Name: "org.osbuild.pulp", Created: created, Options: &target.PulpTargetOptions{Server: "https://pulp.example.com", Username: "admin", Password: "password", ContentType: "ostree", Repository: "edge", BasePath: "edge"}},
+		{Uuid: uuid.MustParse("10000000-0000-0000-0000-000000000006"), ImageName: "localimage", Name: "org.osbuild.export", Created: created, Options: &target.ExportTargetOptions{Filename: "disk.qcow2", ExportFilename: "test-0.0.1-2019-11-27-disk.qcow2"}},
+		{Uuid: uuid.MustParse("10000000-0000-0000-0000-000000000007"), ImageName: "libvirtimage", Name: "org.osbuild.libvirt", Created: created, Options: &target.LibvirtTargetOptions{Filename: "disk.qcow2", Host: "lab", Pool: "default", Volume: "fedora.qcow2", Domain: "fedora", Start: true}},
+		{Uuid: uuid.MustParse("10000000-0000-0000-0000-000000000008"), Name: "org.osbuild.hook", Created: created, Options: &target.HookTargetOptions{Filename: "disk.qcow2", URL: "https://ci.example.com/hooks/rollout", Headers: map[string]string{"Authorization": "Bearer token"}, Command: "terraform"}},
+		{Uuid: uuid.MustParse("10000000-0000-0000-0000-000000000009"), ImageName: "pluginimage", Name: "org.osbuild.plugin", Created: created, Options: &target.PluginTargetOptions{Filename: "disk.qcow2", Plugin: "acme", Options: json.RawMessage(`{"token": "secret"}`)}},
+	}
+	status := &composeStatus{
+		State: ComposeFinished,
+		TargetResults: []*target.TargetResult{
+			target.NewAWSTargetResult(&target.AWSTargetResultOptions{Ami: "ami-0c830793775595d4b", Region: "frankfurt", Copies: []target.AWSTargetResultOptions{{Ami: "ami-0d1ab5c8a71b3e2f9", Region: "us-east-1"}}}),
+			target.NewAzureTargetResult(&target.AzureTargetResultOptions{ImageID: "/subscriptions/1/resourceGroups/group/providers/Microsoft.Compute/images/azureimage", GalleryImageVersionID: "/subscriptions/1/resourceGroups/group/providers/Microsoft.Compute/galleries/gallery/images/fedora/versions/1.0.0"}),
+			target.NewGCPTargetResult(&target.GCPTargetResultOptions{ImageName: "gcpimage", Project: "project", SelfLink: "https://www.googleapis.com/compute/v1/projects/project/global/images/gcpimage"}),
+			target.NewOCITargetResult(&target.OCITargetResultOptions{ImageID: "ocid1.image.oc1..image", Region: "eu-frankfurt-1", DownloadURL: "https://objectstorage.eu-frankfurt-1.oraclecloud.com/p/token/n/namespace/b/images/o/disk.qcow2"}),
+			target.NewVMWareTargetResult(&target.VMWareTargetResultOptions{ImageID: "/dc/vm/vmwareimage"}),
+			target.NewPulpTargetResult(&target.PulpTargetResultOptions{RepositoryVersion: "/pulp/api/v3/repositories/ostree/ostree/1/versions/2/", BaseURL: "https://pulp.example.com/pulp/content/edge/"}),
+			target.NewExportTargetResult(&target.ExportTargetResultOptions{Path: "/var/lib/images/test-0.0.1-2019-11-27-disk.qcow2"}),
+			target.NewLibvirtTargetResult(&target.LibvirtTargetResultOptions{VolumePath: "/var/lib/libvirt/images/fedora.qcow2", DomainUUID: "9a8f1d3e-6b1c-4b4e-9c4a-1b2f3c4d5e6f"}),
+			target.NewPluginTargetResult(&target.PluginTargetResultOptions{Plugin: "acme", ImageID: "image-42", URL: "https://images.example.com/42"}),
+		},
+	}
+
+	uploads, err := json.Marshal(targetsToUploadResponses(targets, status))
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"uuid": "10000000-0000-0000-0000-000000000000", "status": "FINISHED", "provider_name": "aws", "image_name": "awsimage", "creation_time": 1574857140, "settings": {"region": "frankfurt", "bucket": "clay", "key": "imagekey", "shareWithAccounts": ["123456789012"], "copyToRegions": ["us-east-1"], "copies": {"us-east-1": "ami-0d1ab5c8a71b3e2f9"}}, "image_id": "ami-0c830793775595d4b"},
+		{"uuid": "10000000-0000-0000-0000-000000000001", "status": "FINISHED", "provider_name": "azure", "image_name": "azureimage", "creation_time": 1574857140, "settings": {"container": "images", "resourceGroup": "group", "location": "westeurope", "gallery": "gallery", "galleryImageDefinition": "fedora", "galleryImageVersion": "1.0.0", "targetRegions": ["northeurope"], "galleryImageVersionID": "/subscriptions/1/resourceGroups/group/providers/Microsoft.Compute/galleries/gallery/images/fedora/versions/1.0.0"}, "image_id": "/subscriptions/1/resourceGroups/group/providers/Microsoft.Compute/images/azureimage"},
+		{"uuid": "10000000-0000-0000-0000-000000000002", "status": "FINISHED", "provider_name": "gcp", "image_name": "gcpimage", "creation_time": 1574857140, "settings": {"bucket": "images", "object": "gcpimage.tar.gz", "family": "fedora-33", "labels": {"build": "1"}, "share_with": ["user:alice@example.com"], "guest_os_features": ["UEFI_COMPATIBLE"]}, "image_id": "https://www.googleapis.com/compute/v1/projects/project/global/images/gcpimage"},
+		{"uuid": "10000000-0000-0000-0000-000000000003", "status": "FINISHED", "provider_name": "oci", "image_name": "ociimage", "creation_time": 1574857140, "settings": {"region": "eu-frankfurt-1", "compartment": "compartment", "bucket": "images", "object": "disk.qcow2"}, "image_id": "ocid1.image.oc1..image", "download_url": "https://objectstorage.eu-frankfurt-1.oraclecloud.com/p/token/n/namespace/b/images/o/disk.qcow2"},
+		{"uuid": "10000000-0000-0000-0000-000000000004", "status": "FINISHED", "provider_name": "vmware", "image_name": "vmwareimage", "creation_time": 1574857140, "settings": {"host": "vcenter.example.com", "datacenter": "dc", "datastore": "ds", "template": true}, "image_id": "/dc/vm/vmwareimage"},
+		{"uuid": "10000000-0000-0000-0000-000000000005", "status": "FINISHED", "provider_name": "pulp", "image_name": "pulpimage", "creation_time": 1574857140, "settings": {"server": "https://pulp.example.com", "repository": "edge", "base_path": "edge", "base_url": "https://pulp.example.com/pulp/content/edge/"}, "image_id": "/pulp/api/v3/repositories/ostree/ostree/1/versions/2/"},
+		{"uuid": "10000000-0000-0000-0000-000000000006", "status": "FINISHED", "provider_name": "local", "image_name": "localimage", "creation_time": 1574857140, "settings": {"filename": "test-0.0.1-2019-11-27-disk.qcow2"}, "image_id": "/var/lib/images/test-0.0.1-2019-11-27-disk.qcow2"},
+		{"uuid": "10000000-0000-0000-0000-000000000007", "status": "FINISHED", "provider_name": "libvirt", "image_name": "libvirtimage", "creation_time": 1574857140, "settings": {"host": "lab", "pool": "default", "volume": "fedora.qcow2", "domain": "fedora", "start": true, "domain_uuid": "9a8f1d3e-6b1c-4b4e-9c4a-1b2f3c4d5e6f"}, "image_id": "/var/lib/libvirt/images/fedora.qcow2"},
+		{"uuid": "10000000-0000-0000-0000-000000000008", "status": "FINISHED", "provider_name": "hook", "image_name": "", "creation_time": 1574857140, "settings": {"url": "https://ci.example.com/hooks/rollout", "command": "terraform"}},
+		{"uuid": "10000000-0000-0000-0000-000000000009", "status": "FINISHED", "provider_name": "plugin", "image_name": "pluginimage", "creation_time": 1574857140, "settings": {"plugin": "acme", "url": "https://images.example.com/42"}, "image_id": "image-42"}
+	]`, string(uploads))
+}
+
+func TestExpandExportFilename(t *testing.T) {
+	arch, err := test_distro.New().GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+	bp := &blueprint.Blueprint{Name: "test", Version: "0.0.1"}
+	created := time.Date(2019, 11, 27, 12, 0, 0, 0, time.UTC)
+
+	filename, err := expandExportFilename(defaultExportFilename, bp, imgType, created)
+	require.NoError(t, err)
+	require.Equal(t, "test-0.0.1-2019-11-27-"+imgType.Filename(), filename)
+
+	filename, err = expandExportFilename("{blueprint}_{image_type}.img", bp, imgType, created)
+	require.NoError(t, err)
+	require.Equal(t, "test_qcow2.img", filename)
+
+	for _, template := range []string{"", "..", "images/{filename}", "../{filename}"} {
+		_, err = expandExportFilename(template, bp, imgType, created)
+		require.Error(t, err, template)
+	}
+}
+
+func TestTargetsToUploadResponsesS3Endpoint(t *testing.T) {
+	created := time.Unix(1574857140, 0)
+	targets := []*target.Target{
+		{Uuid: uuid.MustParse("10000000-0000-0000-0000-000000000000"), ImageName: "awsimage", Name: "org.osbuild.aws", Created: created, Options: &target.AWSTargetOptions{AccessKeyID: "accesskey", SecretAccessKey: "secretkey", Bucket: "images", Key: "disk.raw", Endpoint: "https://minio.example.com", ForcePathStyle: true}},
+	}
+	status := &composeStatus{
+		State: ComposeFinished,
+		TargetResults: []*target.TargetResult{
+			target.NewAWSTargetResult(&target.AWSTargetResultOptions{URL: "https://minio.example.com/images/disk.raw", DownloadURL: "https://minio.example.com/images/disk.raw?X-Amz-Signature=signature"}),
+		},
+	}
+
+	uploads, err := json.Marshal(targetsToUploadResponses(targets, status))
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"uuid": "10000000-0000-0000-0000-000000000000", "status": "FINISHED", "provider_name": "aws", "image_name": "awsimage", "creation_time": 1574857140, "settings": {"region": "", "bucket": "images", "key": "disk.raw", "endpoint": "https://minio.example.com", "forcePathStyle": true}, "image_id": "https://minio.example.com/images/disk.raw", "download_url": "https://minio.example.com/images/disk.raw?X-Amz-Signature=signature"}
+	]`, string(uploads))
+}
+
+func TestTargetsToUploadResponsesProgress(t *testing.T) {
+	created := time.Unix(1574857140, 0)
+	targets := []*target.Target{
+		{Uuid: uuid.MustParse("10000000-0000-0000-0000-000000000000"), ImageName: "awsimage", Name: "org.osbuild.aws", Created: created, Options: &target.AWSTargetOptions{Region: "frankfurt", Bucket: "clay", Key: "imagekey"}},
+	}
+	status := &composeStatus{
+		State:    ComposeRunning,
+		Progress: &worker.UploadProgress{Target: "org.osbuild.aws", Transferred: 430, Total: 1000},
+	}
+
+	uploads, err := json.Marshal(targetsToUploadResponses(targets, status))
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"uuid": "10000000-0000-0000-0000-000000000000", "status": "RUNNING", "provider_name": "aws", "image_name": "awsimage", "creation_time": 1574857140, "settings": {"region": "frankfurt", "bucket": "clay", "key": "imagekey"}, "progress": {"transferred": 430, "total": 1000, "percent": 43}}
+	]`, string(uploads))
+
+	status.Progress.Target = "org.osbuild.azure"
+	uploads, err = json.Marshal(targetsToUploadResponses(targets, status))
+	require.NoError(t, err)
+	require.NotContains(t, string(uploads), "progress")
+}
+
+func TestTargetsToUploadResponsesTargetStatus(t *testing.T) {
+	created := time.Unix(1574857140, 0)
+	targets := []*target.Target{
+		{Uuid: uuid.MustParse("10000000-0000-0000-0000-000000000000"), ImageName: "awsimage", Name: "org.osbuild.aws", Created: created, Options: &target.AWSTargetOptions{Region: "frankfurt", Bucket: "clay", Key: "imagekey"}},
+	}
+	status := &composeStatus{
+		State: ComposeRunning,
+		TargetStatuses: []worker.TargetStatus{
+			{Target: targets[0].Uuid, State: worker.TargetPending},
+		},
+	}
+
+	uploads, err := json.Marshal(targetsToUploadResponses(targets, status))
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"uuid": "10000000-0000-0000-0000-000000000000", "status": "WAITING", "provider_name": "aws", "image_name": "awsimage", "creation_time": 1574857140, "settings": {"region": "frankfurt", "bucket": "clay", "key": "imagekey"}}
+	]`, string(uploads))
+
+	status.State = ComposeFailed
+	status.TargetStatuses[0] = worker.TargetStatus{Target: targets[0].Uuid, State: worker.TargetFailure, Error: "access denied"}
+	uploads, err = json.Marshal(targetsToUploadResponses(targets, status))
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"uuid": "10000000-0000-0000-0000-000000000000", "status": "FAILED", "provider_name": "aws", "image_name": "awsimage", "creation_time": 1574857140, "settings": {"region": "frankfurt", "bucket": "clay", "key": "imagekey"}, "error": "access denied"}
+	]`, string(uploads))
+}
+
+func TestUploadsValidate(t *testing.T) {
+	if len(os.Getenv("OSBUILD_COMPOSER_TEST_EXTERNAL")) > 0 {
+		t.Skip("This test is for internal testing only")
+	}
+
+	pulpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/pulp/api/v3/repositories/file/file/", r.URL.Path)
+		if r.URL.Query().Get("name") == "images" {
+			_, _ = w.Write([]byte(`{"results": [{"pulp_href": "/pulp/api/v3/repositories/file/file/1/"}]}`))
+		} else {
+			_, _ = w.Write([]byte(`{"results": []}`))
+		}
+	}))
+	defer pulpServer.Close()
+
+	var cases = []struct {
+		Path           string
+		Body           string
+		ExpectedStatus int
+		ExpectedJSON   string
+	}{
+		{"/api/v0/upload/validate", `{"compose_type": "qcow2", "upload": {"provider": "local", "settings": {}}}`, http.StatusNotFound, `{"status":false,"errors":[{"id":"HTTPError","code":404,"msg":"Not Found"}]}`},
+		{"/api/v1/upload/validate", `{"compose_type": "qcow2", "upload": {"provider": "local", "settings": {}}}`, http.StatusOK, `{"status":true}`},
+		{"/api/v1/upload/validate", `{"compose_type": "qcow2", "upload": {"provider": "pulp", "settings": {"server": "` + pulpServer.URL + `", "repository": "images"}}}`, http.StatusOK, `{"status":true}`},
+		{"/api/v1/upload/validate", `{"compose_type": "qcow2", "upload": {"provider": "pulp", "settings": {"server": "` + pulpServer.URL + `", "repository": "missing"}}}`, http.StatusBadRequest, `{"status":false,"errors":[{"id":"UploadError","msg":"there is no file repository named missing"}]}`},
+		{"/api/v1/upload/validate", `{"compose_type": "qcow2"}`, http.StatusBadRequest, `{"status":false,"errors":[{"id":"UploadError","msg":"missing upload"}]}`},
+		{"/api/v1/upload/validate", `{"compose_type": "qcow2", "upload": {"provider": "dropbox", "settings": {}}}`, http.StatusBadRequest, `{"status":false,"errors":[{"id":"UploadError","msg":"unexpected provider name"}]}`},
+		{"/api/v1/upload/validate", `{"compose_type": "floppy", "upload": {"provider": "local", "settings": {}}}`, http.StatusBadRequest, `{"status":false,"errors":[{"id":"UnknownComposeType","msg":"Unknown compose type for architecture: floppy"}]}`},
+	}
+
+	tempdir, err := ioutil.TempDir("", "weldr-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	for _, c := range cases {
+		api, _ := createWeldrAPI(tempdir, rpmmd_mock.BaseFixture)
+		test.TestRoute(t, api, false, "POST", c.Path, c.Body, c.ExpectedStatus, c.ExpectedJSON)
+	}
+}
+
 func TestComposeLog(t *testing.T) {
 	var cases = []struct {
 		Fixture          rpmmd_mock.FixtureGenerator
@@ -878,6 +1297,9 @@ func TestComposeQueue(t *testing.T) {
 		{rpmmd_mock.BaseFixture, "GET", "/api/v0/compose/queue", ``, http.StatusOK, `{"new":[{"blueprint":"test","version":"0.0.0","compose_type":"qcow2","image_size":0,"queue_status":"WAITING"}],"run":[{"blueprint":"test","version":"0.0.0","compose_type":"qcow2","image_size":0,"queue_status":"RUNNING"}]}`},
 		{rpmmd_mock.BaseFixture, "GET", "/api/v1/compose/queue", ``, http.StatusOK, `{"new":[{"blueprint":"test","version":"0.0.0","compose_type":"qcow2","image_size":0,"queue_status":"WAITING","uploads":[{"uuid":"10000000-0000-0000-0000-000000000000","status":"WAITING","provider_name":"aws","image_name":"awsimage","creation_time":1574857140,"settings":{"region":"frankfurt","bucket":"clay","key":"imagekey"}}]}],"run":[{"blueprint":"test","version":"0.0.0","compose_type":"qcow2","image_size":0,"queue_status":"RUNNING"}]}`},
 		{rpmmd_mock.NoComposesFixture, "GET", "/api/v0/compose/queue", ``, http.StatusOK, `{"new":[],"run":[]}`},
+		{rpmmd_mock.BaseFixture, "GET", "/api/v0/compose/queue?limit=1", ``, http.StatusOK, `{"new":[{"blueprint":"test","version":"0.0.0","compose_type":"qcow2","image_size":0,"queue_status":"WAITING"}],"run":[],"total":2,"offset":0,"limit":1}`},
+		{rpmmd_mock.BaseFixture, "GET", "/api/v0/compose/queue?offset=1", ``, http.StatusOK, `{"new":[],"run":[{"blueprint":"test","version":"0.0.0","compose_type":"qcow2","image_size":0,"queue_status":"RUNNING"}],"total":2,"offset":1,"limit":1}`},
+		{rpmmd_mock.BaseFixture, "GET", "/api/v0/compose/queue?limit=none", ``, http.StatusBadRequest, `{"status":false,"errors":[{"id":"BadLimitOrOffset","msg":"BadRequest: invalid value for 'limit': strconv.ParseUint: parsing \"none\": invalid syntax"}]}`},
 	}
 
 	if len(os.Getenv("OSBUILD_COMPOSER_TEST_EXTERNAL")) > 0 {
@@ -906,6 +1328,8 @@ func TestComposeFinished(t *testing.T) {
 		{rpmmd_mock.BaseFixture, "GET", "/api/v0/compose/finished", ``, http.StatusOK, `{"finished":[{"id":"30000000-0000-0000-0000-000000000002","blueprint":"test","version":"0.0.0","compose_type":"qcow2","image_size":0,"queue_status":"FINISHED","job_created":1574857140,"job_started":1574857140,"job_finished":1574857140}]}`},
 		{rpmmd_mock.BaseFixture, "GET", "/api/v1/compose/finished", ``, http.StatusOK, `{"finished":[{"id":"30000000-0000-0000-0000-000000000002","blueprint":"test","version":"0.0.0","compose_type":"qcow2","image_size":0,"queue_status":"FINISHED","job_created":1574857140,"job_started":1574857140,"job_finished":1574857140,"uploads":[{"uuid":"10000000-0000-0000-0000-000000000000","status":"FINISHED","provider_name":"aws","image_name":"awsimage","creation_time":1574857140,"settings":{"region":"frankfurt","bucket":"clay","key":"imagekey"}}]}]}`},
 		{rpmmd_mock.NoComposesFixture, "GET", "/api/v0/compose/finished", ``, http.StatusOK, `{"finished":[]}`},
+		{rpmmd_mock.BaseFixture, "GET", "/api/v0/compose/finished?offset=0&limit=10", ``, http.StatusOK, `{"finished":[{"id":"30000000-0000-0000-0000-000000000002","blueprint":"test","version":"0.0.0","compose_type":"qcow2","image_size":0,"queue_status":"FINISHED","job_created":1574857140,"job_started":1574857140,"job_finished":1574857140}],"total":1,"offset":0,"limit":1}`},
+		{rpmmd_mock.BaseFixture, "GET", "/api/v0/compose/finished?offset=5", ``, http.StatusOK, `{"finished":[],"total":1,"offset":1,"limit":0}`},
 	}
 
 	if len(os.Getenv("OSBUILD_COMPOSER_TEST_EXTERNAL")) > 0 {
@@ -934,6 +1358,7 @@ func TestComposeFailed(t *testing.T) {
 		{rpmmd_mock.BaseFixture, "GET", "/api/v0/compose/failed", ``, http.StatusOK, `{"failed":[{"id":"30000000-0000-0000-0000-000000000003","blueprint":"test","version":"0.0.0","compose_type":"qcow2","image_size":0,"queue_status":"FAILED","job_created":1574857140,"job_started":1574857140,"job_finished":1574857140}]}`},
 		{rpmmd_mock.BaseFixture, "GET", "/api/v1/compose/failed", ``, http.StatusOK, `{"failed":[{"id":"30000000-0000-0000-0000-000000000003","blueprint":"test","version":"0.0.0","compose_type":"qcow2","image_size":0,"queue_status":"FAILED","job_created":1574857140,"job_started":1574857140,"job_finished":1574857140,"uploads":[{"uuid":"10000000-0000-0000-0000-000000000000","status":"FAILED","provider_name":"aws","image_name":"awsimage","creation_time":1574857140,"settings":{"region":"frankfurt","bucket":"clay","key":"imagekey"}}]}]}`},
 		{rpmmd_mock.NoComposesFixture, "GET", "/api/v0/compose/failed", ``, http.StatusOK, `{"failed":[]}`},
+		{rpmmd_mock.BaseFixture, "GET", "/api/v0/compose/failed?limit=0", ``, http.StatusOK, `{"failed":[],"total":1,"offset":0,"limit":0}`},
 	}
 
 	if len(os.Getenv("OSBUILD_COMPOSER_TEST_EXTERNAL")) > 0 {
@@ -1129,6 +1554,10 @@ func TestSourcesInfo(t *testing.T) {
 	test.TestRoute(t, api, true, "GET", "/api/v0/projects/source/info/fish", ``, 200, `{"sources":{"fish":`+sourceStr+`},"errors":[]}`)
 	test.TestRoute(t, api, true, "GET", "/api/v0/projects/source/info/fish?format=json", ``, 200, `{"sources":{"fish":`+sourceStr+`},"errors":[]}`)
 	test.TestRoute(t, api, true, "GET", "/api/v0/projects/source/info/fish?format=son", ``, 400, `{"status":false,"errors":[{"id":"InvalidChars","msg":"invalid format parameter: son"}]}`)
+
+	proxySourceStr := `{"name":"fish","type":"yum-baseurl","url":"https://download.opensuse.org/repositories/shells:/fish:/release:/3/Fedora_29/","check_gpg":false,"check_ssl":false,"system":false,"proxy":"http://proxy.example.com:3128"}`
+	test.SendHTTP(api, true, "POST", "/api/v0/projects/source/new", proxySourceStr)
+	test.TestRoute(t, api, true, "GET", "/api/v0/projects/source/info/fish", ``, 200, `{"sources":{"fish":`+proxySourceStr+`},"errors":[]}`)
 }
 
 func TestSourcesInfoToml(t *testing.T) {
@@ -1281,6 +1710,49 @@ func TestProjectsList(t *testing.T) {
 	}
 }
 
+func TestProjectsGroups(t *testing.T) {
+	var cases = []struct {
+		Fixture        rpmmd_mock.FixtureGenerator
+		Path           string
+		ExpectedStatus int
+		ExpectedJSON   string
+	}{
+		{rpmmd_mock.BaseFixture, "/api/v1/projects/groups", http.StatusOK, `{"groups":[{"id":"core","name":"Core","description":"Smallest possible installation","user_visible":false},{"id":"minimal-environment","name":"Minimal Install","description":"Basic functionality.","environment":true,"user_visible":true},{"id":"standard","name":"Standard","description":"Common set of utilities","user_visible":true}]}`},
+		{rpmmd_mock.BadFetch, "/api/v1/projects/groups", http.StatusBadRequest, `{"status":false,"errors":[{"id":"ProjectsError","msg":"msg: DNF error occured: FetchError: There was a problem when fetching packages."}]}`},
+		{rpmmd_mock.BaseFixture, "/api/v0/projects/groups", http.StatusNotFound, `{"status":false,"errors":[{"id":"HTTPError","code":404,"msg":"Not Found"}]}`},
+	}
+
+	tempdir, err := ioutil.TempDir("", "weldr-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	for _, c := range cases {
+		api, _ := createWeldrAPI(tempdir, c.Fixture)
+		test.TestRoute(t, api, true, "GET", c.Path, ``, c.ExpectedStatus, c.ExpectedJSON)
+	}
+}
+
+func TestModulesStreams(t *testing.T) {
+	var cases = []struct {
+		Fixture        rpmmd_mock.FixtureGenerator
+		Path           string
+		ExpectedStatus int
+		ExpectedJSON   string
+	}{
+		{rpmmd_mock.BaseFixture, "/api/v1/modules/streams", http.StatusOK, `{"streams":[{"name":"nodejs","stream":"12","summary":"Javascript runtime","profiles":["default","minimal"],"default":true},{"name":"nodejs","stream":"14","summary":"Javascript runtime","profiles":["default","minimal"]}]}`},
+		{rpmmd_mock.BadFetch, "/api/v1/modules/streams", http.StatusBadRequest, `{"status":false,"errors":[{"id":"ModulesError","msg":"msg: DNF error occured: FetchError: There was a problem when fetching packages."}]}`},
+	}
+
+	tempdir, err := ioutil.TempDir("", "weldr-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	for _, c := range cases {
+		api, _ := createWeldrAPI(tempdir, c.Fixture)
+		test.TestRoute(t, api, true, "GET", c.Path, ``, c.ExpectedStatus, c.ExpectedJSON)
+	}
+}
+
 func TestModulesList(t *testing.T) {
 	var cases = []struct {
 		Fixture        rpmmd_mock.FixtureGenerator
@@ -1295,6 +1767,11 @@ func TestModulesList(t *testing.T) {
 		{rpmmd_mock.BadFetch, "/api/v0/modules/list/package2*,package16", http.StatusBadRequest, `{"status":false,"errors":[{"id":"ModulesError","msg":"msg: DNF error occured: FetchError: There was a problem when fetching packages."}]}`},
 		{rpmmd_mock.BaseFixture, "/api/v0/modules/list/package2*,package16?offset=1&limit=1", http.StatusOK, `{"total":4,"offset":1,"limit":1,"modules":[{"name":"package2","group_type":"rpm"}]}`},
 		{rpmmd_mock.BaseFixture, "/api/v0/modules/list/*", http.StatusOK, `{"total":22,"offset":0,"limit":20,"modules":[{"name":"package0","group_type":"rpm"},{"name":"package1","group_type":"rpm"},{"name":"package10","group_type":"rpm"},{"name":"package11","group_type":"rpm"},{"name":"package12","group_type":"rpm"},{"name":"package13","group_type":"rpm"},{"name":"package14","group_type":"rpm"},{"name":"package15","group_type":"rpm"},{"name":"package16","group_type":"rpm"},{"name":"package17","group_type":"rpm"},{"name":"package18","group_type":"rpm"},{"name":"package19","group_type":"rpm"},{"name":"package2","group_type":"rpm"},{"name":"package20","group_type":"rpm"},{"name":"package21","group_type":"rpm"},{"name":"package3","group_type":"rpm"},{"name":"package4","group_type":"rpm"},{"name":"package5","group_type":"rpm"},{"name":"package6","group_type":"rpm"},{"name":"package7","group_type":"rpm"}]}`},
+		{rpmmd_mock.BaseFixture, "/api/v0/modules/list/package1*?sort=-buildtime&limit=3", http.StatusOK, `{"total":11,"offset":0,"limit":3,"modules":[{"name":"package19","group_type":"rpm"},{"name":"package18","group_type":"rpm"},{"name":"package17","group_type":"rpm"}]}`},
+		{rpmmd_mock.BaseFixture, "/api/v0/modules/list?repo=test-id&arch=x86_64&limit=2", http.StatusOK, `{"total":22,"offset":0,"limit":2,"modules":[{"name":"package0","group_type":"rpm"},{"name":"package1","group_type":"rpm"}]}`},
+		{rpmmd_mock.BaseFixture, "/api/v0/modules/list?repo=other,another", http.StatusOK, `{"total":0,"offset":0,"limit":20,"modules":[]}`},
+		{rpmmd_mock.BaseFixture, "/api/v0/modules/list?arch=aarch64", http.StatusOK, `{"total":0,"offset":0,"limit":20,"modules":[]}`},
+		{rpmmd_mock.BaseFixture, "/api/v0/modules/list?sort=size", http.StatusBadRequest, `{"status":false,"errors":[{"id":"ModulesError","msg":"BadRequest: cannot sort packages by \"size\", use name or buildtime"}]}`},
 	}
 
 	tempdir, err := ioutil.TempDir("", "weldr-tests-")
@@ -1306,3 +1783,98 @@ func TestModulesList(t *testing.T) {
 		test.TestRoute(t, api, true, "GET", c.Path, ``, c.ExpectedStatus, c.ExpectedJSON)
 	}
 }
+
+func TestComposeEvents(t *testing.T) {
+	if len(os.Getenv("OSBUILD_COMPOSER_TEST_EXTERNAL")) > 0 {
+		t.Skip("This test is for internal testing only")
+	}
+
+	tempdir, err := ioutil.TempDir("", "weldr-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	api, _ := createWeldrAPI(tempdir, rpmmd_mock.BaseFixture)
+
+	resp := test.SendHTTP(api, false, "POST", "/api/v1/compose", `{"blueprint_name": "test","compose_type":"qcow2","branch":"master","upload":{"image_name":"test_upload","provider":"aws","settings":{"region":"eu-central-1","accessKeyID":"accesskey","secretAccessKey":"secretkey","bucket":"clay","key":"imagekey"}}}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var compose ComposeResponseV0
+	err = json.NewDecoder(resp.Body).Decode(&compose)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	resp, err = http.Get(server.URL + "/api/v1/compose/events/" + compose.BuildID.String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	read := func() (string, string) {
+		var event, data string
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				break
+			}
+			if strings.HasPrefix(line, "event: ") {
+				event = strings.TrimPrefix(line, "event: ")
+			} else if strings.HasPrefix(line, "data: ") {
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+		return event, data
+	}
+	// uploadResponse cannot be unmarshaled, so only decode what the test needs
+	type entryStatus struct {
+		ID          uuid.UUID              `json:"id"`
+		QueueStatus common.ImageBuildState `json:"queue_status"`
+		Uploads     []struct {
+			UUID uuid.UUID `json:"uuid"`
+		} `json:"uploads"`
+	}
+	next := func() entryStatus {
+		event, data := read()
+		require.Equal(t, "status", event)
+		var entry entryStatus
+		require.NoError(t, json.Unmarshal([]byte(data), &entry))
+		require.Equal(t, compose.BuildID, entry.ID)
+		return entry
+	}
+
+	require.Equal(t, common.IBWaiting, next().QueueStatus)
+
+	token, _, _, _, _, err := api.workers.RequestJob(context.Background(), "x86_64", []string{"osbuild"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, common.IBRunning, next().QueueStatus)
+
+	// the progress of uploads is sent as its own event
+	err = api.workers.UpdateProgress(token, worker.UploadProgress{Target: "org.osbuild.aws", Transferred: 430, Total: 1000})
+	require.NoError(t, err)
+	entry := next()
+	require.Len(t, entry.Uploads, 1)
+	event, data := read()
+	require.Equal(t, "progress", event)
+	require.JSONEq(t, `{"uuid":"`+entry.Uploads[0].UUID.String()+`","transferred":430,"total":1000,"percent":43}`, data)
+
+	err = api.workers.FinishJob(token, json.RawMessage(`{"success": true}`))
+	require.NoError(t, err)
+	require.Equal(t, common.IBFinished, next().QueueStatus)
+
+	// the stream ends when the compose finished
+	_, err = reader.ReadString('\n')
+	require.Equal(t, io.EOF, err)
+
+	// finished composes are sent once
+	resp = test.SendHTTP(api, false, "GET", "/api/v0/compose/events/30000000-0000-0000-0000-000000000002", ``)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, 1, strings.Count(string(body), "event: status\n"))
+	require.Contains(t, string(body), `"queue_status":"FINISHED"`)
+
+	test.TestRoute(t, api, false, "GET", "/api/v0/compose/events/30000000-0000-0000-0000-000000000009", ``, http.StatusBadRequest, `{"status":false,"errors":[{"id":"UnknownUUID","msg":"30000000-0000-0000-0000-000000000009 is not a valid build uuid"}]}`)
+}
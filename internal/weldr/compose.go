@@ -1,6 +1,7 @@
 package weldr
 
 import (
+	"net/url"
 	"sort"
 
 	"github.com/google/uuid"
@@ -20,6 +21,7 @@ type ComposeEntry struct {
 	JobStarted  float64                `json:"job_started,omitempty"`
 	JobFinished float64                `json:"job_finished,omitempty"`
 	Uploads     []uploadResponse       `json:"uploads,omitempty"`
+	Pinned      bool                   `json:"pinned,omitempty"`
 }
 
 func composeToComposeEntry(id uuid.UUID, compose store.Compose, status *composeStatus, includeUploads bool) *ComposeEntry {
@@ -29,9 +31,10 @@ func composeToComposeEntry(id uuid.UUID, compose store.Compose, status *composeS
 	composeEntry.Blueprint = compose.Blueprint.Name
 	composeEntry.Version = compose.Blueprint.Version
 	composeEntry.ComposeType = compose.ImageBuild.ImageType.Name()
+	composeEntry.Pinned = compose.Pinned
 
 	if includeUploads {
-		composeEntry.Uploads = targetsToUploadResponses(compose.ImageBuild.Targets, status.State)
+		composeEntry.Uploads = targetsToUploadResponses(compose.ImageBuild.Targets, status)
 	}
 
 	switch status.State {
@@ -68,3 +71,69 @@ func sortComposeEntries(entries []*ComposeEntry) {
 		return entries[i].ID.String() < entries[j].ID.String()
 	})
 }
+
+// composePage is the position of a page in a list of composes, which is
+// included in the replies of the routes listing composes when they are
+// paginated
+type composePage struct {
+	Total  uint `json:"total"`
+	Offset uint `json:"offset"`
+	Limit  uint `json:"limit"`
+}
+
+// parseComposePage parses the offset and limit of the routes listing
+// composes. Unlike other lists, these are only paginated when either is
+// given, so that clients which do not know about pagination still get all
+// composes.
+func parseComposePage(query url.Values) (*composePage, error) {
+	if query.Get("offset") == "" && query.Get("limit") == "" {
+		return nil, nil
+	}
+	offset, limit, err := parseOffsetAndLimit(query)
+	if err != nil {
+		return nil, err
+	}
+	return &composePage{Offset: offset, Limit: limit}, nil
+}
+
+// apply clamps the page to a list of total composes and returns its bounds,
+// which span the whole list if the page is nil
+func (p *composePage) apply(total int) (int, int) {
+	if p == nil {
+		return 0, total
+	}
+	p.Total = uint(total)
+	p.Offset = min(p.Offset, p.Total)
+	p.Limit = min(p.Limit, p.Total-p.Offset)
+	return int(p.Offset), int(p.Offset + p.Limit)
+}
+
+type listedCompose struct {
+	ID      uuid.UUID
+	Compose store.Compose
+	Status  *composeStatus
+}
+
+// listComposes returns the composes which are in one of states, ordered by
+// their state in the order of states and by their ID
+func (api *API) listComposes(states ...ComposeState) []listedCompose {
+	order := make(map[ComposeState]int)
+	for i, state := range states {
+		order[state] = i
+	}
+
+	var composes []listedCompose
+	for id, compose := range api.store.GetAllComposes() {
+		status := api.getComposeStatus(compose)
+		if _, ok := order[status.State]; ok {
+			composes = append(composes, listedCompose{id, compose, status})
+		}
+	}
+	sort.Slice(composes, func(i, j int) bool {
+		if oi, oj := order[composes[i].Status.State], order[composes[j].Status.State]; oi != oj {
+			return oi < oj
+		}
+		return composes[i].ID.String() < composes[j].ID.String()
+	})
+	return composes
+}
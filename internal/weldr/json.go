@@ -115,7 +115,7 @@ type SourceConfig interface {
 }
 
 // NewSourceConfigV0 converts a store.SourceConfig to a SourceConfigV0
-// The store does not support proxy and gpgkey_urls
+// The store does not support gpgkey_urls
 func NewSourceConfigV0(s store.SourceConfig) SourceConfigV0 {
 	var sc SourceConfigV0
 
@@ -125,6 +125,8 @@ func NewSourceConfigV0(s store.SourceConfig) SourceConfigV0 {
 	sc.CheckGPG = s.CheckGPG
 	sc.CheckSSL = s.CheckSSL
 	sc.System = s.System
+	sc.Proxy = s.Proxy
+	sc.Priority = s.Priority
 
 	return sc
 }
@@ -139,6 +141,7 @@ type SourceConfigV0 struct {
 	System   bool     `json:"system" toml:"system"`
 	Proxy    string   `json:"proxy,omitempty" toml:"proxy,omitempty"`
 	GPGUrls  []string `json:"gpgkey_urls,omitempty" toml:"gpgkey_urls,omitempty"`
+	Priority int      `json:"priority,omitempty" toml:"priority,omitempty"`
 }
 
 // Key return the key, .Name in this case
@@ -157,13 +160,15 @@ func (s SourceConfigV0) GetType() string {
 }
 
 // SourceConfig returns a SourceConfig struct populated with the supported variables
-// The store does not support proxy and gpgkey_urls
+// The store does not support gpgkey_urls
 func (s SourceConfigV0) SourceConfig() (ssc store.SourceConfig) {
 	ssc.Name = s.Name
 	ssc.Type = s.Type
 	ssc.URL = s.URL
 	ssc.CheckGPG = s.CheckGPG
 	ssc.CheckSSL = s.CheckSSL
+	ssc.Proxy = s.Proxy
+	ssc.Priority = s.Priority
 
 	return ssc
 }
@@ -175,7 +180,7 @@ type SourceInfoResponseV0 struct {
 }
 
 // NewSourceConfigV1 converts a store.SourceConfig to a SourceConfigV1
-// The store does not support proxy and gpgkey_urls
+// The store does not support gpgkey_urls
 func NewSourceConfigV1(id string, s store.SourceConfig) SourceConfigV1 {
 	var sc SourceConfigV1
 
@@ -186,6 +191,8 @@ func NewSourceConfigV1(id string, s store.SourceConfig) SourceConfigV1 {
 	sc.CheckGPG = s.CheckGPG
 	sc.CheckSSL = s.CheckSSL
 	sc.System = s.System
+	sc.Proxy = s.Proxy
+	sc.Priority = s.Priority
 
 	return sc
 }
@@ -201,6 +208,7 @@ type SourceConfigV1 struct {
 	System   bool     `json:"system" toml:"system"`
 	Proxy    string   `json:"proxy,omitempty" toml:"proxy,omitempty"`
 	GPGUrls  []string `json:"gpgkey_urls,omitempty" toml:"gpgkey_urls,omitempty"`
+	Priority int      `json:"priority,omitempty" toml:"priority,omitempty"`
 }
 
 // Key returns the key, .ID in this case
@@ -219,13 +227,15 @@ func (s SourceConfigV1) GetType() string {
 }
 
 // SourceConfig returns a SourceConfig struct populated with the supported variables
-// The store does not support proxy and gpgkey_urls
+// The store does not support gpgkey_urls
 func (s SourceConfigV1) SourceConfig() (ssc store.SourceConfig) {
 	ssc.Name = s.Name
 	ssc.Type = s.Type
 	ssc.URL = s.URL
 	ssc.CheckGPG = s.CheckGPG
 	ssc.CheckSSL = s.CheckSSL
+	ssc.Proxy = s.Proxy
+	ssc.Priority = s.Priority
 
 	return ssc
 }
@@ -0,0 +1,154 @@
+package weldr
+
+import (
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RetentionPolicy limits which finished composes are kept. Composes which
+// exceed any of the limits are deleted with their artifacts, unless they are
+// pinned. Limits which are zero are not enforced.
+type RetentionPolicy struct {
+	// MaxAge is how long composes are kept after they finished
+	MaxAge time.Duration
+	// MaxSize limits the total size of the artifacts of all composes. The
+	// oldest composes are deleted first.
+	MaxSize uint64
+	// KeepLast is how many of the newest composes of each blueprint are
+	// kept
+	KeepLast int
+}
+
+// Enabled returns whether the policy has any limits
+func (p RetentionPolicy) Enabled() bool {
+	return p.MaxAge > 0 || p.MaxSize > 0 || p.KeepLast > 0
+}
+
+// retainedCompose is what the policy decides on about a finished compose
+type retainedCompose struct {
+	ID        uuid.UUID
+	Blueprint string
+	Finished  time.Time
+	Size      uint64
+	Pinned    bool
+}
+
+// expired returns the IDs of the composes the policy deletes, oldest first
+func (p RetentionPolicy) expired(composes []retainedCompose, now time.Time) []uuid.UUID {
+	sorted := make([]retainedCompose, len(composes))
+	copy(sorted, composes)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Finished.After(sorted[j].Finished)
+	})
+
+	// pinned composes are kept regardless, but take up space
+	var size uint64
+	for _, c := range sorted {
+		if c.Pinned {
+			size += c.Size
+		}
+	}
+
+	var expired []uuid.UUID
+	kept := make(map[string]int)
+	for _, c := range sorted {
+		if c.Pinned {
+			continue
+		}
+		if (p.MaxAge > 0 && now.Sub(c.Finished) > p.MaxAge) ||
+			(p.KeepLast > 0 && kept[c.Blueprint] >= p.KeepLast) ||
+			(p.MaxSize > 0 && size+c.Size > p.MaxSize) {
+			expired = append(expired, c.ID)
+			continue
+		}
+		kept[c.Blueprint]++
+		size += c.Size
+	}
+
+	for i, j := 0, len(expired)-1; i < j; i, j = i+1, j-1 {
+		expired[i], expired[j] = expired[j], expired[i]
+	}
+	return expired
+}
+
+// dirSize returns the total size of the files in dir, which is 0 if it does
+// not exist
+func dirSize(dir string) (uint64, error) {
+	var size uint64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return size, err
+}
+
+// CleanupComposes deletes the finished and failed composes which exceed the
+// retention policy, and returns their IDs
+func (api *API) CleanupComposes(policy RetentionPolicy) []uuid.UUID {
+	var composes []retainedCompose
+	all := api.store.GetAllComposes()
+	for id, compose := range all {
+		status := api.getComposeStatus(compose)
+		if status.State != ComposeFinished && status.State != ComposeFailed {
+			continue
+		}
+
+		var size uint64
+		var err error
+		if compose.ImageBuild.JobID != uuid.Nil {
+			var s int64
+			s, err = api.workers.ArtifactsSize(compose.ImageBuild.JobID)
+			size = uint64(s)
+		} else if api.compatOutputDir != "" {
+			size, err = dirSize(path.Join(api.compatOutputDir, id.String()))
+		}
+		if err != nil {
+			log.Printf("Cannot get the size of the artifacts of compose %s: %v", id, err)
+		}
+
+		var blueprintName string
+		if compose.Blueprint != nil {
+			blueprintName = compose.Blueprint.Name
+		}
+		composes = append(composes, retainedCompose{
+			ID:        id,
+			Blueprint: blueprintName,
+			Finished:  status.Finished,
+			Size:      size,
+			Pinned:    compose.Pinned,
+		})
+	}
+
+	var deleted []uuid.UUID
+	for _, id := range policy.expired(composes, time.Now()) {
+		err := api.deleteCompose(id, all[id])
+		if err != nil {
+			log.Printf("Cannot delete compose %s: %v", id, err)
+			continue
+		}
+		log.Printf("Deleted compose %s, which exceeded the retention policy", id)
+		deleted = append(deleted, id)
+	}
+	return deleted
+}
+
+// RunRetention cleans up composes every interval, forever
+func (api *API) RunRetention(policy RetentionPolicy, interval time.Duration) {
+	for range time.Tick(interval) {
+		api.CleanupComposes(policy)
+	}
+}
@@ -0,0 +1,67 @@
+package weldr
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	rpmmd_mock "github.com/osbuild/osbuild-composer/internal/mocks/rpmmd"
+	"github.com/osbuild/osbuild-composer/internal/test"
+)
+
+func TestRetentionPolicyExpired(t *testing.T) {
+	now := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	id := func(n int) uuid.UUID {
+		return uuid.MustParse("40000000-0000-0000-0000-00000000000" + string(rune('0'+n)))
+	}
+	composes := []retainedCompose{
+		{ID: id(1), Blueprint: "web", Finished: now.Add(-1 * day), Size: 100},
+		{ID: id(2), Blueprint: "web", Finished: now.Add(-2 * day), Size: 100},
+		{ID: id(3), Blueprint: "web", Finished: now.Add(-3 * day), Size: 100},
+		{ID: id(4), Blueprint: "db", Finished: now.Add(-4 * day), Size: 100},
+		{ID: id(5), Blueprint: "web", Finished: now.Add(-5 * day), Size: 100, Pinned: true},
+	}
+
+	cases := []struct {
+		Policy  RetentionPolicy
+		Expired []uuid.UUID
+	}{
+		{RetentionPolicy{}, nil},
+		{RetentionPolicy{MaxAge: 60 * time.Hour}, []uuid.UUID{id(4), id(3)}},
+		{RetentionPolicy{KeepLast: 1}, []uuid.UUID{id(3), id(2)}},
+		// the pinned compose takes up space, too
+		{RetentionPolicy{MaxSize: 300}, []uuid.UUID{id(4), id(3)}},
+		{RetentionPolicy{MaxAge: 36 * time.Hour, KeepLast: 2}, []uuid.UUID{id(4), id(3), id(2)}},
+	}
+	for _, c := range cases {
+		require.Equalf(t, c.Expired, c.Policy.expired(composes, now), "%+v", c.Policy)
+	}
+}
+
+func TestCleanupComposes(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "weldr-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	api, s := createWeldrAPI(tempdir, rpmmd_mock.BaseFixture)
+
+	test.TestRoute(t, api, true, "POST", "/api/v1/compose/pin/30000000-0000-0000-0000-000000000002", ``, http.StatusOK, `{"status":true}`)
+	test.TestRoute(t, api, true, "POST", "/api/v1/compose/pin/30000000-0000-0000-0000-000000000009", ``, http.StatusBadRequest,
+		`{"status":false,"errors":[{"id":"UnknownUUID","msg":"Compose 30000000-0000-0000-0000-000000000009 doesn't exist"}]}`)
+
+	// only the failed compose is deleted, because the finished one is pinned
+	// and the others are still running
+	deleted := api.CleanupComposes(RetentionPolicy{MaxAge: time.Hour})
+	require.Equal(t, []uuid.UUID{uuid.MustParse("30000000-0000-0000-0000-000000000003")}, deleted)
+	require.Len(t, s.GetAllComposes(), 3)
+
+	test.TestRoute(t, api, true, "POST", "/api/v1/compose/unpin/30000000-0000-0000-0000-000000000002", ``, http.StatusOK, `{"status":true}`)
+	deleted = api.CleanupComposes(RetentionPolicy{MaxAge: time.Hour})
+	require.Equal(t, []uuid.UUID{uuid.MustParse("30000000-0000-0000-0000-000000000002")}, deleted)
+}
@@ -1,15 +1,26 @@
 package weldr
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/osbuild/osbuild-composer/internal/blueprint"
 	"github.com/osbuild/osbuild-composer/internal/common"
 	"github.com/osbuild/osbuild-composer/internal/distro"
 
 	"github.com/google/uuid"
 	"github.com/osbuild/osbuild-composer/internal/target"
+	"github.com/osbuild/osbuild-composer/internal/upload/awsupload"
+	"github.com/osbuild/osbuild-composer/internal/upload/azure"
+	"github.com/osbuild/osbuild-composer/internal/upload/gcp"
+	"github.com/osbuild/osbuild-composer/internal/upload/oci"
+	"github.com/osbuild/osbuild-composer/internal/upload/pulp"
+	"github.com/osbuild/osbuild-composer/internal/upload/vmware"
+	"github.com/osbuild/osbuild-composer/internal/worker"
 )
 
 type uploadResponse struct {
@@ -19,6 +30,27 @@ type uploadResponse struct {
 	ImageName    string                 `json:"image_name"`
 	CreationTime float64                `json:"creation_time"`
 	Settings     uploadSettings         `json:"settings"`
+	// ImageID is the ID of the image registered by the upload: the AMI for
+	// AWS, or the URL of the image if it is only uploaded, the resource ID
+	// of the managed image for Azure, the URL of the image for GCP, the OCID
+	// of the custom image for OCI, the path of the disk or template, or the
+	// content library item, for VMWare, the repository version for Pulp, the
+	// path of the volume for libvirt and the path of the exported image for
+	// local exports
+	ImageID string `json:"image_id,omitempty"`
+	// DownloadURL allows downloading the uploaded image from object
+	// storage without credentials, until it expires
+	DownloadURL string `json:"download_url,omitempty"`
+	// Progress of the upload, while the compose is running
+	Progress *uploadProgress `json:"progress,omitempty"`
+	// Error is why the upload failed
+	Error string `json:"error,omitempty"`
+}
+
+type uploadProgress struct {
+	Transferred int64 `json:"transferred"`
+	Total       int64 `json:"total"`
+	Percent     int   `json:"percent"`
 }
 
 type uploadSettings interface {
@@ -31,6 +63,23 @@ type awsUploadSettings struct {
 	SecretAccessKey string `json:"secretAccessKey,omitempty"`
 	Bucket          string `json:"bucket"`
 	Key             string `json:"key"`
+	// ShareWithAccounts are the IDs of AWS accounts allowed to launch the
+	// AMI
+	ShareWithAccounts []string `json:"shareWithAccounts,omitempty"`
+	// CopyToRegions are the regions the AMI is copied to
+	CopyToRegions []string `json:"copyToRegions,omitempty"`
+	// UploadOnly keeps the image in the bucket instead of registering an
+	// AMI
+	UploadOnly bool `json:"uploadOnly,omitempty"`
+	// Copies are the IDs of the copied AMIs by region. They are only
+	// returned with the results of uploads.
+	Copies map[string]string `json:"copies,omitempty"`
+	// Endpoint is the URL of an S3-compatible object store, like MinIO or
+	// Ceph, which the image is uploaded to instead of Amazon S3, without
+	// registering an AMI
+	Endpoint       string `json:"endpoint,omitempty"`
+	ForcePathStyle bool   `json:"forcePathStyle,omitempty"`
+	Insecure       bool   `json:"insecure,omitempty"`
 }
 
 func (awsUploadSettings) isUploadSettings() {}
@@ -39,10 +88,167 @@ type azureUploadSettings struct {
 	StorageAccount   string `json:"storageAccount,omitempty"`
 	StorageAccessKey string `json:"storageAccessKey,omitempty"`
 	Container        string `json:"container"`
+
+	// Cloud is the name of the Azure cloud, e.g. AzureUSGovernmentCloud,
+	// or empty for the public cloud. The endpoints which are set replace
+	// those of the cloud.
+	Cloud                   string `json:"cloud,omitempty"`
+	ActiveDirectoryEndpoint string `json:"activeDirectoryEndpoint,omitempty"`
+	ResourceManagerEndpoint string `json:"resourceManagerEndpoint,omitempty"`
+	StorageEndpointSuffix   string `json:"storageEndpointSuffix,omitempty"`
+
+	// A managed image is registered from the uploaded VHD when a resource
+	// group is set, which needs the credentials of a service principal
+	SubscriptionID string `json:"subscriptionID,omitempty"`
+	TenantID       string `json:"tenantID,omitempty"`
+	ClientID       string `json:"clientID,omitempty"`
+	ClientSecret   string `json:"clientSecret,omitempty"`
+	ResourceGroup  string `json:"resourceGroup,omitempty"`
+	Location       string `json:"location,omitempty"`
+	// ShareWith are the object IDs of principals which get the Reader role
+	// on the managed image
+	ShareWith []string `json:"shareWith,omitempty"`
+	// The managed image is published to a Shared Image Gallery of the
+	// resource group when a gallery is set
+	Gallery                string   `json:"gallery,omitempty"`
+	GalleryImageDefinition string   `json:"galleryImageDefinition,omitempty"`
+	GalleryImageVersion    string   `json:"galleryImageVersion,omitempty"`
+	TargetRegions          []string `json:"targetRegions,omitempty"`
+	// GalleryImageVersionID is the resource ID of the published version. It
+	// is only returned with the results of uploads.
+	GalleryImageVersionID string `json:"galleryImageVersionID,omitempty"`
 }
 
 func (azureUploadSettings) isUploadSettings() {}
 
+type gcpUploadSettings struct {
+	// Credentials is the JSON key of a service account, base64 encoded
+	Credentials []byte            `json:"credentials,omitempty"`
+	Project     string            `json:"project,omitempty"`
+	Bucket      string            `json:"bucket"`
+	Object      string            `json:"object,omitempty"`
+	Family      string            `json:"family,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	// GuestOSFeatures are enabled on the image, e.g. UEFI_COMPATIBLE for
+	// shielded VMs, and Licenses are the URLs of its licenses
+	GuestOSFeatures []string `json:"guest_os_features,omitempty"`
+	Licenses        []string `json:"licenses,omitempty"`
+	// ShieldedVM and ConfidentialVM allow creating shielded VMs and
+	// confidential VMs with AMD SEV from the image
+	ShieldedVM     bool `json:"shielded_vm,omitempty"`
+	ConfidentialVM bool `json:"confidential_vm,omitempty"`
+	// ShareWith are the IAM members allowed to use the image
+	ShareWith []string `json:"share_with,omitempty"`
+}
+
+func (gcpUploadSettings) isUploadSettings() {}
+
+type ociUploadSettings struct {
+	User        string `json:"user,omitempty"`
+	Tenancy     string `json:"tenancy,omitempty"`
+	Region      string `json:"region"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	PrivateKey  string `json:"private_key,omitempty"`
+	Compartment string `json:"compartment"`
+	Namespace   string `json:"namespace,omitempty"`
+	Bucket      string `json:"bucket"`
+	Object      string `json:"object,omitempty"`
+}
+
+func (ociUploadSettings) isUploadSettings() {}
+
+type vmwareUploadSettings struct {
+	Host           string `json:"host"`
+	Username       string `json:"username,omitempty"`
+	Password       string `json:"password,omitempty"`
+	Insecure       bool   `json:"insecure,omitempty"`
+	Datacenter     string `json:"datacenter,omitempty"`
+	Cluster        string `json:"cluster,omitempty"`
+	Datastore      string `json:"datastore,omitempty"`
+	Folder         string `json:"folder,omitempty"`
+	Template       bool   `json:"template,omitempty"`
+	Network        string `json:"network,omitempty"`
+	ContentLibrary string `json:"content_library,omitempty"`
+}
+
+func (vmwareUploadSettings) isUploadSettings() {}
+
+type pulpUploadSettings struct {
+	Server       string `json:"server"`
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"password,omitempty"`
+	Repository   string `json:"repository"`
+	RelativePath string `json:"relative_path,omitempty"`
+	BasePath     string `json:"base_path,omitempty"`
+	// BaseURL is where the distribution serves the repository. It is only
+	// returned with the results of uploads.
+	BaseURL string `json:"base_url,omitempty"`
+}
+
+func (pulpUploadSettings) isUploadSettings() {}
+
+type libvirtUploadSettings struct {
+	// Host is the name of a libvirt host configured on the worker
+	Host   string `json:"host"`
+	Pool   string `json:"pool"`
+	Volume string `json:"volume,omitempty"`
+	// A domain booting the image is defined when a domain name is set.
+	// DomainTemplate names a domain template configured on the worker.
+	Domain         string `json:"domain,omitempty"`
+	DomainTemplate string `json:"domain_template,omitempty"`
+	Memory         uint64 `json:"memory,omitempty"`
+	VCPUs          int    `json:"vcpus,omitempty"`
+	Network        string `json:"network,omitempty"`
+	Start          bool   `json:"start,omitempty"`
+	// DomainUUID is the UUID of the defined domain. It is only returned
+	// with the results of uploads.
+	DomainUUID string `json:"domain_uuid,omitempty"`
+}
+
+func (libvirtUploadSettings) isUploadSettings() {}
+
+type pluginUploadSettings struct {
+	// Plugin is the name of the plugin on the worker which uploads the
+	// image
+	Plugin string `json:"plugin"`
+	// Options are passed to the plugin. They are never returned, because
+	// they may contain credentials.
+	Options json.RawMessage `json:"options,omitempty"`
+	// URL of the uploaded image, if the plugin returned one. It is only
+	// returned with the results of uploads.
+	URL string `json:"url,omitempty"`
+}
+
+func (pluginUploadSettings) isUploadSettings() {}
+
+// hookUploadSettings describe a hook, which is given the metadata of the
+// image after the upload of a compose succeeded. It is not an upload
+// provider, but is listed with the uploads of the compose.
+type hookUploadSettings struct {
+	// URL the metadata is posted to
+	URL string `json:"url,omitempty"`
+	// Headers are sent with the metadata, e.g. for authentication. They
+	// are never returned.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Command is the name of a hook command configured on the worker
+	Command string `json:"command,omitempty"`
+}
+
+func (hookUploadSettings) isUploadSettings() {}
+
+// The filename images are exported with when the request does not specify one
+const defaultExportFilename = "{blueprint}-{version}-{date}-{filename}"
+
+type localUploadSettings struct {
+	// Filename is a template for the name of the image in the export
+	// directory of the worker. It may contain the placeholders {blueprint},
+	// {version}, {date}, {image_type} and {filename}, which is the name of
+	// the image built by osbuild. Responses contain the expanded name.
+	Filename string `json:"filename,omitempty"`
+}
+
+func (localUploadSettings) isUploadSettings() {}
+
 type uploadRequest struct {
 	Provider  string         `json:"provider"`
 	ImageName string         `json:"image_name"`
@@ -68,6 +274,20 @@ func (u *uploadRequest) UnmarshalJSON(data []byte) error {
 		settings = new(azureUploadSettings)
 	case "aws":
 		settings = new(awsUploadSettings)
+	case "gcp":
+		settings = new(gcpUploadSettings)
+	case "oci":
+		settings = new(ociUploadSettings)
+	case "vmware":
+		settings = new(vmwareUploadSettings)
+	case "pulp":
+		settings = new(pulpUploadSettings)
+	case "libvirt":
+		settings = new(libvirtUploadSettings)
+	case "plugin":
+		settings = new(pluginUploadSettings)
+	case "local":
+		settings = new(localUploadSettings)
 	default:
 		return errors.New("unexpected provider name")
 	}
@@ -87,11 +307,12 @@ func (u *uploadRequest) UnmarshalJSON(data []byte) error {
 //
 // This ignore the status in `targets`, because that's never set correctly.
 // Instead, it sets each target's status to the ImageBuildState equivalent of
-// `state`.
+// the state of the compose. The IDs of registered images are taken from the
+// results the worker reported for the targets.
 //
 // This also ignores any sensitive data passed into targets. Access keys may
 // be passed as input to composer, but should not be possible to be queried.
-func targetsToUploadResponses(targets []*target.Target, state ComposeState) []uploadResponse {
+func targetsToUploadResponses(targets []*target.Target, status *composeStatus) []uploadResponse {
 	var uploads []uploadResponse
 	for _, t := range targets {
 		upload := uploadResponse{
@@ -100,32 +321,212 @@ func targetsToUploadResponses(targets []*target.Target, state ComposeState) []up
 			CreationTime: float64(t.Created.UnixNano()) / 1000000000,
 		}
 
-		switch state {
-		case ComposeWaiting:
-			upload.Status = common.IBWaiting
-		case ComposeRunning:
-			upload.Status = common.IBRunning
-		case ComposeFinished:
-			upload.Status = common.IBFinished
-		case ComposeFailed:
-			upload.Status = common.IBFailed
+		if ts := findTargetStatus(status.TargetStatuses, t.Uuid); ts != nil {
+			switch ts.State {
+			case worker.TargetPending:
+				upload.Status = common.IBWaiting
+			case worker.TargetRunning:
+				upload.Status = common.IBRunning
+			case worker.TargetSuccess:
+				upload.Status = common.IBFinished
+			case worker.TargetFailure:
+				upload.Status = common.IBFailed
+			}
+			upload.Error = ts.Error
+		} else {
+			switch status.State {
+			case ComposeWaiting:
+				upload.Status = common.IBWaiting
+			case ComposeRunning:
+				upload.Status = common.IBRunning
+			case ComposeFinished:
+				upload.Status = common.IBFinished
+			case ComposeFailed:
+				upload.Status = common.IBFailed
+			}
+		}
+
+		if p := status.Progress; status.State == ComposeRunning && p != nil && progressOfTarget(p, t) && p.Total > 0 {
+			upload.Progress = &uploadProgress{
+				Transferred: p.Transferred,
+				Total:       p.Total,
+				Percent:     int(p.Transferred * 100 / p.Total),
+			}
 		}
 
 		switch options := t.Options.(type) {
 		case *target.AWSTargetOptions:
 			upload.ProviderName = "aws"
-			upload.Settings = &awsUploadSettings{
-				Region: options.Region,
-				Bucket: options.Bucket,
-				Key:    options.Key,
+			settings := &awsUploadSettings{
+				Region:            options.Region,
+				Bucket:            options.Bucket,
+				Key:               options.Key,
+				ShareWithAccounts: options.ShareWithAccounts,
+				CopyToRegions:     options.CopyToRegions,
+				UploadOnly:        options.UploadOnly,
+				Endpoint:          options.Endpoint,
+				ForcePathStyle:    options.ForcePathStyle,
+				Insecure:          options.Insecure,
 				// AccessKeyID and SecretAccessKey are intentionally not included.
 			}
+			if result := findTargetResult(status.TargetResults, t.Name); result != nil {
+				upload.ImageID = result.(*target.AWSTargetResultOptions).Ami
+				if upload.ImageID == "" {
+					upload.ImageID = result.(*target.AWSTargetResultOptions).URL
+				}
+				upload.DownloadURL = result.(*target.AWSTargetResultOptions).DownloadURL
+				for _, c := range result.(*target.AWSTargetResultOptions).Copies {
+					if settings.Copies == nil {
+						settings.Copies = make(map[string]string)
+					}
+					settings.Copies[c.Region] = c.Ami
+				}
+			}
+			upload.Settings = settings
 			uploads = append(uploads, upload)
 		case *target.AzureTargetOptions:
 			upload.ProviderName = "azure"
-			upload.Settings = &azureUploadSettings{
+			settings := &azureUploadSettings{
 				Container: options.Container,
-				// StorageAccount and StorageAccessKey are intentionally not included.
+
+				Cloud:                   options.Cloud,
+				ActiveDirectoryEndpoint: options.ActiveDirectoryEndpoint,
+				ResourceManagerEndpoint: options.ResourceManagerEndpoint,
+				StorageEndpointSuffix:   options.StorageEndpointSuffix,
+
+				ResourceGroup: options.ResourceGroup,
+				Location:      options.Location,
+				ShareWith:     options.ShareWith,
+
+				Gallery:                options.Gallery,
+				GalleryImageDefinition: options.GalleryImageDefinition,
+				GalleryImageVersion:    options.GalleryImageVersion,
+				TargetRegions:          options.TargetRegions,
+				// StorageAccount, StorageAccessKey and the credentials of the
+				// service principal are intentionally not included.
+			}
+			if result := findTargetResult(status.TargetResults, t.Name); result != nil {
+				upload.ImageID = result.(*target.AzureTargetResultOptions).ImageID
+				settings.GalleryImageVersionID = result.(*target.AzureTargetResultOptions).GalleryImageVersionID
+				upload.DownloadURL = result.(*target.AzureTargetResultOptions).DownloadURL
+			}
+			upload.Settings = settings
+			uploads = append(uploads, upload)
+		case *target.GCPTargetOptions:
+			upload.ProviderName = "gcp"
+			upload.Settings = &gcpUploadSettings{
+				Project:   options.Project,
+				Bucket:    options.Bucket,
+				Object:    options.Object,
+				Family:    options.Family,
+				Labels:    options.Labels,
+				ShareWith: options.ShareWith,
+
+				GuestOSFeatures: options.GuestOSFeatures,
+				Licenses:        options.Licenses,
+				ShieldedVM:      options.ShieldedVM,
+				ConfidentialVM:  options.ConfidentialVM,
+				// Credentials are intentionally not included.
+			}
+			if result := findTargetResult(status.TargetResults, t.Name); result != nil {
+				upload.ImageID = result.(*target.GCPTargetResultOptions).SelfLink
+			}
+			uploads = append(uploads, upload)
+		case *target.OCITargetOptions:
+			upload.ProviderName = "oci"
+			upload.Settings = &ociUploadSettings{
+				Region:      options.Region,
+				Compartment: options.Compartment,
+				Namespace:   options.Namespace,
+				Bucket:      options.Bucket,
+				Object:      options.Object,
+				// The signing key and the user it belongs to are
+				// intentionally not included.
+			}
+			if result := findTargetResult(status.TargetResults, t.Name); result != nil {
+				upload.ImageID = result.(*target.OCITargetResultOptions).ImageID
+				upload.DownloadURL = result.(*target.OCITargetResultOptions).DownloadURL
+			}
+			uploads = append(uploads, upload)
+		case *target.VMWareTargetOptions:
+			upload.ProviderName = "vmware"
+			upload.Settings = &vmwareUploadSettings{
+				Host:           options.Host,
+				Insecure:       options.Insecure,
+				Datacenter:     options.Datacenter,
+				Cluster:        options.Cluster,
+				Datastore:      options.Datastore,
+				Folder:         options.Folder,
+				Template:       options.Template,
+				Network:        options.Network,
+				ContentLibrary: options.ContentLibrary,
+				// Username and Password are intentionally not included.
+			}
+			if result := findTargetResult(status.TargetResults, t.Name); result != nil {
+				upload.ImageID = result.(*target.VMWareTargetResultOptions).ImageID
+			}
+			uploads = append(uploads, upload)
+		case *target.PulpTargetOptions:
+			upload.ProviderName = "pulp"
+			settings := &pulpUploadSettings{
+				Server:       options.Server,
+				Repository:   options.Repository,
+				RelativePath: options.RelativePath,
+				BasePath:     options.BasePath,
+				// Username and Password are intentionally not included.
+			}
+			if result := findTargetResult(status.TargetResults, t.Name); result != nil {
+				upload.ImageID = result.(*target.PulpTargetResultOptions).RepositoryVersion
+				settings.BaseURL = result.(*target.PulpTargetResultOptions).BaseURL
+			}
+			upload.Settings = settings
+			uploads = append(uploads, upload)
+		case *target.LibvirtTargetOptions:
+			upload.ProviderName = "libvirt"
+			settings := &libvirtUploadSettings{
+				Host:           options.Host,
+				Pool:           options.Pool,
+				Volume:         options.Volume,
+				Domain:         options.Domain,
+				DomainTemplate: options.DomainTemplate,
+				Memory:         options.Memory,
+				VCPUs:          options.VCPUs,
+				Network:        options.Network,
+				Start:          options.Start,
+			}
+			if result := findTargetResult(status.TargetResults, t.Name); result != nil {
+				upload.ImageID = result.(*target.LibvirtTargetResultOptions).VolumePath
+				settings.DomainUUID = result.(*target.LibvirtTargetResultOptions).DomainUUID
+			}
+			upload.Settings = settings
+			uploads = append(uploads, upload)
+		case *target.PluginTargetOptions:
+			upload.ProviderName = "plugin"
+			settings := &pluginUploadSettings{
+				Plugin: options.Plugin,
+				// Options are intentionally not included.
+			}
+			if result := findTargetResult(status.TargetResults, t.Name); result != nil {
+				upload.ImageID = result.(*target.PluginTargetResultOptions).ImageID
+				settings.URL = result.(*target.PluginTargetResultOptions).URL
+			}
+			upload.Settings = settings
+			uploads = append(uploads, upload)
+		case *target.HookTargetOptions:
+			upload.ProviderName = "hook"
+			upload.Settings = &hookUploadSettings{
+				URL:     options.URL,
+				Command: options.Command,
+				// Headers are intentionally not included.
+			}
+			uploads = append(uploads, upload)
+		case *target.ExportTargetOptions:
+			upload.ProviderName = "local"
+			upload.Settings = &localUploadSettings{
+				Filename: options.ExportFilename,
+			}
+			if result := findTargetResult(status.TargetResults, t.Name); result != nil {
+				upload.ImageID = result.(*target.ExportTargetResultOptions).Path
 			}
 			uploads = append(uploads, upload)
 		}
@@ -134,6 +535,38 @@ func targetsToUploadResponses(targets []*target.Target, state ComposeState) []up
 	return uploads
 }
 
+// findTargetResult returns the options of the result of the target with the
+// given name. Weldr composes have at most one upload target, so the name is
+// enough to match them.
+func findTargetResult(results []*target.TargetResult, name string) target.TargetResultOptions {
+	for _, result := range results {
+		if result.Name == name {
+			return result.Options
+		}
+	}
+	return nil
+}
+
+// findTargetStatus returns the state of the target with the given UUID, or
+// nil if it is unknown
+func findTargetStatus(statuses []worker.TargetStatus, id uuid.UUID) *worker.TargetStatus {
+	for i := range statuses {
+		if statuses[i].Target == id {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
+
+// progressOfTarget returns whether the progress is of the upload to t. Older
+// workers only report the name of the target.
+func progressOfTarget(progress *worker.UploadProgress, t *target.Target) bool {
+	if progress.TargetID != uuid.Nil {
+		return progress.TargetID == t.Uuid
+	}
+	return progress.Target == t.Name
+}
+
 func uploadRequestToTarget(u uploadRequest, imageType distro.ImageType) *target.Target {
 	var t target.Target
 
@@ -146,12 +579,18 @@ func uploadRequestToTarget(u uploadRequest, imageType distro.ImageType) *target.
 	case *awsUploadSettings:
 		t.Name = "org.osbuild.aws"
 		t.Options = &target.AWSTargetOptions{
-			Filename:        imageType.Filename(),
-			Region:          options.Region,
-			AccessKeyID:     options.AccessKeyID,
-			SecretAccessKey: options.SecretAccessKey,
-			Bucket:          options.Bucket,
-			Key:             options.Key,
+			Filename:          imageType.Filename(),
+			Region:            options.Region,
+			AccessKeyID:       options.AccessKeyID,
+			SecretAccessKey:   options.SecretAccessKey,
+			Bucket:            options.Bucket,
+			Key:               options.Key,
+			ShareWithAccounts: options.ShareWithAccounts,
+			CopyToRegions:     options.CopyToRegions,
+			UploadOnly:        options.UploadOnly,
+			Endpoint:          options.Endpoint,
+			ForcePathStyle:    options.ForcePathStyle,
+			Insecure:          options.Insecure,
 		}
 	case *azureUploadSettings:
 		t.Name = "org.osbuild.azure"
@@ -160,8 +599,299 @@ func uploadRequestToTarget(u uploadRequest, imageType distro.ImageType) *target.
 			StorageAccount:   options.StorageAccount,
 			StorageAccessKey: options.StorageAccessKey,
 			Container:        options.Container,
+
+			Cloud:                   options.Cloud,
+			ActiveDirectoryEndpoint: options.ActiveDirectoryEndpoint,
+			ResourceManagerEndpoint: options.ResourceManagerEndpoint,
+			StorageEndpointSuffix:   options.StorageEndpointSuffix,
+
+			SubscriptionID: options.SubscriptionID,
+			TenantID:       options.TenantID,
+			ClientID:       options.ClientID,
+			ClientSecret:   options.ClientSecret,
+			ResourceGroup:  options.ResourceGroup,
+			Location:       options.Location,
+			ShareWith:      options.ShareWith,
+
+			Gallery:                options.Gallery,
+			GalleryImageDefinition: options.GalleryImageDefinition,
+			GalleryImageVersion:    options.GalleryImageVersion,
+			TargetRegions:          options.TargetRegions,
+		}
+	case *gcpUploadSettings:
+		t.Name = "org.osbuild.gcp"
+		object := options.Object
+		if object == "" {
+			object = t.Uuid.String() + "-" + imageType.Filename()
+		}
+		t.Options = &target.GCPTargetOptions{
+			Filename:    imageType.Filename(),
+			Credentials: options.Credentials,
+			Project:     options.Project,
+			Bucket:      options.Bucket,
+			Object:      object,
+			Family:      options.Family,
+			Labels:      options.Labels,
+			ShareWith:   options.ShareWith,
+
+			GuestOSFeatures: options.GuestOSFeatures,
+			Licenses:        options.Licenses,
+			ShieldedVM:      options.ShieldedVM,
+			ConfidentialVM:  options.ConfidentialVM,
+		}
+	case *ociUploadSettings:
+		t.Name = "org.osbuild.oci"
+		object := options.Object
+		if object == "" {
+			object = t.Uuid.String() + "-" + imageType.Filename()
+		}
+		t.Options = &target.OCITargetOptions{
+			Filename:    imageType.Filename(),
+			User:        options.User,
+			Tenancy:     options.Tenancy,
+			Region:      options.Region,
+			Fingerprint: options.Fingerprint,
+			PrivateKey:  options.PrivateKey,
+			Compartment: options.Compartment,
+			Namespace:   options.Namespace,
+			Bucket:      options.Bucket,
+			Object:      object,
+		}
+	case *vmwareUploadSettings:
+		t.Name = "org.osbuild.vmware"
+		t.Options = &target.VMWareTargetOptions{
+			Filename:       imageType.Filename(),
+			Host:           options.Host,
+			Username:       options.Username,
+			Password:       options.Password,
+			Insecure:       options.Insecure,
+			Datacenter:     options.Datacenter,
+			Cluster:        options.Cluster,
+			Datastore:      options.Datastore,
+			Folder:         options.Folder,
+			Template:       options.Template,
+			Network:        options.Network,
+			ContentLibrary: options.ContentLibrary,
+		}
+	case *pulpUploadSettings:
+		t.Name = "org.osbuild.pulp"
+		// ostree commits are imported into ostree repositories, all other
+		// images are added to file repositories
+		contentType := pulp.File
+		if strings.HasSuffix(imageType.Name(), "-commit") {
+			contentType = pulp.OSTree
+		}
+		relativePath := options.RelativePath
+		if relativePath == "" {
+			relativePath = t.Uuid.String() + "-" + imageType.Filename()
+		}
+		t.Options = &target.PulpTargetOptions{
+			Filename:     imageType.Filename(),
+			Server:       options.Server,
+			Username:     options.Username,
+			Password:     options.Password,
+			ContentType:  contentType,
+			Repository:   options.Repository,
+			RelativePath: relativePath,
+			BasePath:     options.BasePath,
+		}
+	case *libvirtUploadSettings:
+		t.Name = "org.osbuild.libvirt"
+		volume := options.Volume
+		if volume == "" {
+			volume = t.Uuid.String() + "-" + imageType.Filename()
+		}
+		t.Options = &target.LibvirtTargetOptions{
+			Filename:       imageType.Filename(),
+			Host:           options.Host,
+			Pool:           options.Pool,
+			Volume:         volume,
+			Domain:         options.Domain,
+			DomainTemplate: options.DomainTemplate,
+			Memory:         options.Memory,
+			VCPUs:          options.VCPUs,
+			Network:        options.Network,
+			Start:          options.Start,
+		}
+	case *pluginUploadSettings:
+		t.Name = "org.osbuild.plugin"
+		t.Options = &target.PluginTargetOptions{
+			Filename: imageType.Filename(),
+			Plugin:   options.Plugin,
+			Options:  options.Options,
+		}
+	case *localUploadSettings:
+		t.Name = "org.osbuild.export"
+		// The filename template is expanded by the caller, once the
+		// blueprint of the compose is known.
+		filename := options.Filename
+		if filename == "" {
+			filename = defaultExportFilename
+		}
+		t.Options = &target.ExportTargetOptions{
+			Filename:       imageType.Filename(),
+			ExportFilename: filename,
 		}
 	}
 
 	return &t
 }
+
+// hookToTarget returns the target of a hook, which runs after the upload of
+// the compose
+func hookToTarget(h hookUploadSettings, imageType distro.ImageType) *target.Target {
+	return target.NewHookTarget(&target.HookTargetOptions{
+		Filename: imageType.Filename(),
+		URL:      h.URL,
+		Headers:  h.Headers,
+		Command:  h.Command,
+	})
+}
+
+// expandExportFilename replaces the placeholders in the filename template of
+// a local export with the values of the compose. The result must be a plain
+// filename, without any directories.
+func expandExportFilename(template string, bp *blueprint.Blueprint, imageType distro.ImageType, created time.Time) (string, error) {
+	filename := strings.NewReplacer(
+		"{blueprint}", bp.Name,
+		"{version}", bp.Version,
+		"{date}", created.Format("2006-01-02"),
+		"{image_type}", imageType.Name(),
+		"{filename}", imageType.Filename(),
+	).Replace(template)
+
+	if filename == "" || filename == "." || filename == ".." || strings.ContainsRune(filename, '/') {
+		return "", fmt.Errorf("invalid export filename: %q", filename)
+	}
+
+	return filename, nil
+}
+
+// azureCloud returns the endpoints of the cloud of an Azure target
+func azureCloud(options *target.AzureTargetOptions) (azure.Cloud, error) {
+	return azure.NewCloud(options.Cloud, azure.Cloud{
+		ActiveDirectoryEndpoint: options.ActiveDirectoryEndpoint,
+		ResourceManagerEndpoint: options.ResourceManagerEndpoint,
+		StorageEndpointSuffix:   options.StorageEndpointSuffix,
+	})
+}
+
+// checkTarget verifies that the credentials of an upload target are valid
+// and allow everything the worker will do with them, without uploading
+// anything. Exports and libvirt imports are not checked, because the export
+// directory and the libvirt hosts are only known to the worker. Composer must
+// never run virsh itself, which would connect to a host taken from a request.
+func checkTarget(ctx context.Context, t *target.Target) error {
+	switch options := t.Options.(type) {
+	case *target.AWSTargetOptions:
+		var a *awsupload.AWS
+		var err error
+		if options.Endpoint != "" {
+			a, err = awsupload.NewForEndpoint(awsupload.S3Endpoint{
+				URL:            options.Endpoint,
+				ForcePathStyle: options.ForcePathStyle,
+				Insecure:       options.Insecure,
+			}, options.Region, options.AccessKeyID, options.SecretAccessKey)
+		} else {
+			err = awsupload.CheckRegions(options.Region, options.CopyToRegions)
+			if err == nil {
+				a, err = awsupload.New(options.Region, options.AccessKeyID, options.SecretAccessKey)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		return a.CheckPermissions(options.Bucket, !options.UploadOnly)
+	case *target.AzureTargetOptions:
+		cloud, err := azureCloud(options)
+		if err != nil {
+			return err
+		}
+		err = azure.CheckStorage(ctx, azure.Credentials{
+			StorageAccount:   options.StorageAccount,
+			StorageAccessKey: options.StorageAccessKey,
+			Cloud:            cloud,
+		}, options.Container)
+		if err != nil || options.ResourceGroup == "" {
+			return err
+		}
+		actions := []string{azure.ImageWriteAction}
+		if len(options.ShareWith) > 0 {
+			actions = append(actions, azure.RoleAssignmentWriteAction)
+		}
+		if options.Gallery != "" {
+			actions = append(actions, azure.GalleryImageVersionWriteAction)
+		}
+		return azure.CheckPermissions(ctx, azure.ClientCredentials{
+			SubscriptionID: options.SubscriptionID,
+			TenantID:       options.TenantID,
+			ClientID:       options.ClientID,
+			ClientSecret:   options.ClientSecret,
+			Cloud:          cloud,
+		}, options.ResourceGroup, actions)
+	case *target.GCPTargetOptions:
+		g, err := gcp.New(options.Credentials)
+		if err != nil {
+			return err
+		}
+		project := options.Project
+		if project == "" {
+			project, err = g.ProjectID(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return g.CheckPermissions(ctx, project, options.Bucket, len(options.ShareWith) > 0)
+	case *target.OCITargetOptions:
+		o, err := oci.New(oci.Credentials{
+			User:        options.User,
+			Tenancy:     options.Tenancy,
+			Region:      options.Region,
+			Fingerprint: options.Fingerprint,
+			PrivateKey:  options.PrivateKey,
+		})
+		if err != nil {
+			return err
+		}
+		namespace := options.Namespace
+		if namespace == "" {
+			namespace, err = o.Namespace(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return o.CheckBucket(ctx, namespace, options.Bucket)
+	case *target.VMWareTargetOptions:
+		v, err := vmware.Connect(ctx, vmware.Credentials{
+			Host:     options.Host,
+			Username: options.Username,
+			Password: options.Password,
+			Insecure: options.Insecure,
+		})
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = v.Logout(ctx)
+		}()
+		if options.ContentLibrary != "" {
+			return v.CheckLibrary(ctx, options.ContentLibrary)
+		}
+		return v.CheckDatastore(ctx, vmware.DatastoreImport{
+			Datacenter: options.Datacenter,
+			Cluster:    options.Cluster,
+			Datastore:  options.Datastore,
+			Folder:     options.Folder,
+			Template:   options.Template,
+			Network:    options.Network,
+		})
+	case *target.PulpTargetOptions:
+		p := pulp.New(options.Server, pulp.Credentials{
+			Username: options.Username,
+			Password: options.Password,
+		})
+		_, err := p.Repository(ctx, options.ContentType, options.Repository)
+		return err
+	}
+	return nil
+}
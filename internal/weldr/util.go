@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/url"
 	"strconv"
+	"strings"
 )
 
 func parseOffsetAndLimit(query url.Values) (uint, uint, error) {
@@ -27,6 +28,20 @@ func parseOffsetAndLimit(query url.Values) (uint, uint, error) {
 	return uint(offset), uint(limit), nil
 }
 
+// parseList returns the values of a query parameter, which can be given
+// multiple times as well as a comma-separated list
+func parseList(query url.Values, key string) []string {
+	var values []string
+	for _, v := range query[key] {
+		for _, value := range strings.Split(v, ",") {
+			if value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
 func min(a, b uint) uint {
 	if a < b {
 		return a
@@ -17,8 +17,11 @@ type Error struct {
 
 // RequestJobJSONBody defines parameters for RequestJob.
 type RequestJobJSONBody struct {
-	Arch  string   `json:"arch"`
-	Types []string `json:"types"`
+	Arch string `json:"arch"`
+
+	// The channels jobs are taken from, all of them if this is not set.
+	Channels *[]string `json:"channels,omitempty"`
+	Types    []string  `json:"types"`
 }
 
 // UpdateJobJSONBody defines parameters for UpdateJob.
@@ -27,12 +30,23 @@ type UpdateJobJSONBody struct {
 	Status string      `json:"status"`
 }
 
+// UpdateJobProgressJSONBody defines parameters for UpdateJobProgress.
+type UpdateJobProgressJSONBody struct {
+	Target      string  `json:"target"`
+	TargetId    *string `json:"target_id,omitempty"`
+	Total       int64   `json:"total"`
+	Transferred int64   `json:"transferred"`
+}
+
 // RequestJobRequestBody defines body for RequestJob for application/json ContentType.
 type RequestJobJSONRequestBody RequestJobJSONBody
 
 // UpdateJobRequestBody defines body for UpdateJob for application/json ContentType.
 type UpdateJobJSONRequestBody UpdateJobJSONBody
 
+// UpdateJobProgressRequestBody defines body for UpdateJobProgress for application/json ContentType.
+type UpdateJobProgressJSONRequestBody UpdateJobProgressJSONBody
+
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
 	// Request a job
@@ -47,6 +61,9 @@ type ServerInterface interface {
 	// Upload an artifact
 	// (PUT /jobs/{token}/artifacts/{name})
 	UploadJobArtifact(ctx echo.Context, token string, name string) error
+	// Report the progress of an upload of a running job
+	// (PUT /jobs/{token}/progress)
+	UpdateJobProgress(ctx echo.Context, token string) error
 	// status
 	// (GET /status)
 	GetStatus(ctx echo.Context) error
@@ -122,6 +139,22 @@ func (w *ServerInterfaceWrapper) UploadJobArtifact(ctx echo.Context) error {
 	return err
 }
 
+// UpdateJobProgress converts echo context to params.
+func (w *ServerInterfaceWrapper) UpdateJobProgress(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "token" -------------
+	var token string
+
+	err = runtime.BindStyledParameter("simple", false, "token", ctx.Param("token"), &token)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter token: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshalled arguments
+	err = w.Handler.UpdateJobProgress(ctx, token)
+	return err
+}
+
 // GetStatus converts echo context to params.
 func (w *ServerInterfaceWrapper) GetStatus(ctx echo.Context) error {
 	var err error
@@ -157,6 +190,7 @@ func RegisterHandlers(router EchoRouter, si ServerInterface) {
 	router.GET("/jobs/:token", wrapper.GetJob)
 	router.PATCH("/jobs/:token", wrapper.UpdateJob)
 	router.PUT("/jobs/:token/artifacts/:name", wrapper.UploadJobArtifact)
+	router.PUT("/jobs/:token/progress", wrapper.UpdateJobProgress)
 	router.GET("/status", wrapper.GetStatus)
 
 }
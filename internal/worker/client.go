@@ -30,6 +30,7 @@ type Job interface {
 	Update(result interface{}) error
 	Canceled() (bool, error)
 	UploadArtifact(name string, reader io.Reader) error
+	UpdateProgress(progress UploadProgress) error
 }
 
 type job struct {
@@ -84,7 +85,9 @@ func NewClientUnix(path string) *Client {
 	return &Client{server, requester}
 }
 
-func (c *Client) RequestJob(types []string) (Job, error) {
+// RequestJob requests a job with one of types from channels, or from all
+// channels if it is empty
+func (c *Client) RequestJob(types []string, channels []string) (Job, error) {
 	url, err := c.server.Parse("jobs")
 	if err != nil {
 		// This only happens when "jobs" cannot be parsed.
@@ -92,10 +95,14 @@ func (c *Client) RequestJob(types []string) (Job, error) {
 	}
 
 	var buf bytes.Buffer
-	err = json.NewEncoder(&buf).Encode(api.RequestJobJSONRequestBody{
+	body := api.RequestJobJSONRequestBody{
 		Types: types,
 		Arch:  common.CurrentArch(),
-	})
+	}
+	if len(channels) > 0 {
+		body.Channels = &channels
+	}
+	err = json.NewEncoder(&buf).Encode(body)
 	if err != nil {
 		panic(err)
 	}
@@ -194,6 +201,42 @@ func (j *job) Update(result interface{}) error {
 	return nil
 }
 
+func (j *job) UpdateProgress(progress UploadProgress) error {
+	var buf bytes.Buffer
+	body := api.UpdateJobProgressJSONRequestBody{
+		Target:      progress.Target,
+		Transferred: progress.Transferred,
+		Total:       progress.Total,
+	}
+	if progress.TargetID != uuid.Nil {
+		targetID := progress.TargetID.String()
+		body.TargetId = &targetID
+	}
+	err := json.NewEncoder(&buf).Encode(body)
+	if err != nil {
+		panic(err)
+	}
+
+	req, err := http.NewRequest("PUT", j.location+"/progress", &buf)
+	if err != nil {
+		panic(err)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	response, err := j.requester.Do(req)
+	if err != nil {
+		return fmt.Errorf("error reporting progress: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return errorFromResponse(response, "error reporting progress")
+	}
+
+	return nil
+}
+
 func (j *job) Canceled() (bool, error) {
 	response, err := j.requester.Get(j.location)
 	if err != nil {
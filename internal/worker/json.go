@@ -6,6 +6,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/osbuild/osbuild-composer/internal/distro"
 	"github.com/osbuild/osbuild-composer/internal/osbuild"
+	"github.com/osbuild/osbuild-composer/internal/rpmmd"
 	"github.com/osbuild/osbuild-composer/internal/target"
 )
 
@@ -18,6 +19,13 @@ type OSBuildJob struct {
 	Targets         []*target.Target `json:"targets,omitempty"`
 	ImageName       string           `json:"image_name,omitempty"`
 	StreamOptimized bool             `json:"stream_optimized,omitempty"`
+	// Packages are the depsolved packages of the image and Distro its
+	// distribution. Workers ignore them, but composer keeps them for the
+	// SBOM of the compose.
+	Packages []rpmmd.PackageSpec `json:"packages,omitempty"`
+	Distro   string              `json:"distro,omitempty"`
+	// ImageType is only used for the metrics of composer
+	ImageType string `json:"image_type,omitempty"`
 }
 
 type OSBuildJobResult struct {
@@ -25,6 +33,45 @@ type OSBuildJobResult struct {
 	OSBuildOutput *osbuild.Result `json:"osbuild_output,omitempty"`
 	TargetErrors  []string        `json:"target_errors,omitempty"`
 	UploadStatus  string          `json:"upload_status"`
+	// TargetResults are reported for the targets which were handled
+	// successfully and have something to report, like the AMI of an
+	// image uploaded to AWS
+	TargetResults []*target.TargetResult `json:"target_results,omitempty"`
+	// TargetStatuses are the states of all targets of the job. Older
+	// workers do not report them.
+	TargetStatuses []TargetStatus `json:"target_statuses,omitempty"`
+	// Checksums of the artifacts, which older workers do not report
+	Checksums *ArtifactChecksums `json:"checksums,omitempty"`
+}
+
+// ArtifactChecksums are the checksums of the artifacts osbuild built and, if
+// the worker signs artifacts, their detached signatures
+type ArtifactChecksums struct {
+	// SHA256 checksums by filename
+	SHA256 map[string]string `json:"sha256"`
+	// SHA256Sums is the content of SHA256SUMS, in the format of sha256sum
+	SHA256Sums string `json:"sha256sums"`
+	// Signatures are ASCII-armored detached signatures by the name of the
+	// signed file, which include SHA256SUMS
+	Signatures map[string]string `json:"signatures,omitempty"`
+}
+
+// TargetState is the state of the upload of an image to a target
+type TargetState string
+
+const (
+	TargetPending TargetState = "pending"
+	TargetRunning TargetState = "running"
+	TargetSuccess TargetState = "success"
+	TargetFailure TargetState = "failure"
+)
+
+// TargetStatus is the state of one target of a job, identified by its UUID,
+// and the error it failed with
+type TargetStatus struct {
+	Target uuid.UUID   `json:"target"`
+	State  TargetState `json:"state"`
+	Error  string      `json:"error,omitempty"`
 }
 
 type KojiInitJob struct {
@@ -46,6 +93,11 @@ type OSBuildKojiJob struct {
 	KojiServer    string          `json:"koji_server"`
 	KojiDirectory string          `json:"koji_directory"`
 	KojiFilename  string          `json:"koji_filename"`
+	// KojiLogFilename is the name the osbuild log is uploaded as
+	KojiLogFilename string `json:"koji_log_filename,omitempty"`
+	// Distro and ImageType are only used for the metrics of composer
+	Distro    string `json:"distro,omitempty"`
+	ImageType string `json:"image_type,omitempty"`
 }
 
 type OSBuildKojiJobResult struct {
@@ -54,6 +106,8 @@ type OSBuildKojiJobResult struct {
 	OSBuildOutput *osbuild.Result `json:"osbuild_output"`
 	ImageHash     string          `json:"image_hash"`
 	ImageSize     uint64          `json:"image_size"`
+	LogHash       string          `json:"log_hash,omitempty"`
+	LogSize       uint64          `json:"log_size,omitempty"`
 	KojiError     string          `json:"koji_error"`
 }
 
@@ -63,15 +117,29 @@ type KojiFinalizeJob struct {
 	Version       string   `json:"version"`
 	Release       string   `json:"release"`
 	KojiFilenames []string `json:"koji_filenames"`
-	KojiDirectory string   `json:"koji_directory"`
-	TaskID        uint64   `json:"task_id"` /* https://pagure.io/koji/issue/215 */
-	StartTime     uint64   `json:"start_time"`
+	// KojiLogFilenames are the names of the osbuild logs of the images.
+	// Older jobs do not have them, and no logs are imported for them.
+	KojiLogFilenames []string `json:"koji_log_filenames,omitempty"`
+	KojiDirectory    string   `json:"koji_directory"`
+	TaskID           uint64   `json:"task_id"` /* https://pagure.io/koji/issue/215 */
+	StartTime        uint64   `json:"start_time"`
 }
 
 type KojiFinalizeJobResult struct {
 	KojiError string `json:"koji_error"`
 }
 
+// UploadProgress is the latest progress a worker reported for the upload of
+// an image to a target of a running job
+type UploadProgress struct {
+	Target string `json:"target"`
+	// TargetID is the UUID of the target. Older workers only report the
+	// name of the target.
+	TargetID    uuid.UUID `json:"target_id,omitempty"`
+	Transferred int64     `json:"transferred"`
+	Total       int64     `json:"total"`
+}
+
 //
 // JSON-serializable types for the HTTP API
 //
@@ -0,0 +1,227 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/osbuild/osbuild-composer/internal/webhook"
+)
+
+// composeLabels are the labels of the metrics of composes
+type composeLabels struct {
+	Distro    string
+	ImageType string
+}
+
+type composeMetrics struct {
+	successes uint64
+	failures  uint64
+	// duration is the total time the finished composes were running
+	duration time.Duration
+}
+
+// metrics are the metrics of the workers and of the composes they build,
+// which are collected since composer started
+type metrics struct {
+	mu sync.Mutex
+	// idleWorkers are the workers waiting for a job, by architecture
+	idleWorkers map[string]int
+	// busyArches are the architectures of the workers running a job, by
+	// token
+	busyArches map[uuid.UUID]string
+	composes   map[composeLabels]*composeMetrics
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		idleWorkers: make(map[string]int),
+		busyArches:  make(map[uuid.UUID]string),
+		composes:    make(map[composeLabels]*composeMetrics),
+	}
+}
+
+func (m *metrics) workerWaiting(arch string, delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.idleWorkers[arch] += delta
+}
+
+func (m *metrics) jobStarted(token uuid.UUID, arch string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.busyArches[token] = arch
+}
+
+func (m *metrics) jobFinished(token uuid.UUID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.busyArches, token)
+}
+
+func (m *metrics) composeFinished(labels composeLabels, success bool, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.composes[labels]
+	if !ok {
+		c = &composeMetrics{}
+		m.composes[labels] = c
+	}
+	if success {
+		c.successes++
+	} else {
+		c.failures++
+	}
+	c.duration += duration
+}
+
+// composeLabelsOf returns the labels of a job, and whether it builds an image
+func composeLabelsOf(jobType string, rawArgs json.RawMessage) (composeLabels, bool, error) {
+	switch {
+	case strings.HasPrefix(jobType, "osbuild:"):
+		var args OSBuildJob
+		err := json.Unmarshal(rawArgs, &args)
+		if err != nil {
+			return composeLabels{}, false, err
+		}
+		return composeLabels{args.Distro, args.ImageType}, true, nil
+	case strings.HasPrefix(jobType, "osbuild-koji:"):
+		var args OSBuildKojiJob
+		err := json.Unmarshal(rawArgs, &args)
+		if err != nil {
+			return composeLabels{}, false, err
+		}
+		return composeLabels{args.Distro, args.ImageType}, true, nil
+	}
+	return composeLabels{}, false, nil
+}
+
+// recordCompose records the result of a finished osbuild job and notifies
+// the webhooks about it
+func (s *Server) recordCompose(id uuid.UUID) error {
+	jobType, rawArgs, _, _, err := s.jobs.Job(id)
+	if err != nil {
+		return err
+	}
+
+	labels, ok, err := composeLabelsOf(jobType, rawArgs)
+	if err != nil || !ok {
+		return err
+	}
+
+	var success bool
+	var status *JobStatus
+	switch {
+	case strings.HasPrefix(jobType, "osbuild:"):
+		var result OSBuildJobResult
+		status, _, err = s.JobStatus(id, &result)
+		if err != nil {
+			return err
+		}
+		success = result.Success
+	case strings.HasPrefix(jobType, "osbuild-koji:"):
+		var result OSBuildKojiJobResult
+		status, _, err = s.JobStatus(id, &result)
+		if err != nil {
+			return err
+		}
+		success = result.OSBuildOutput != nil && result.OSBuildOutput.Success
+	}
+
+	s.metrics.composeFinished(labels, success, status.Finished.Sub(status.Started))
+
+	eventType := webhook.ComposeFinished
+	if !success {
+		eventType = webhook.ComposeFailed
+	}
+	s.notify(eventType, id, jobType, labels)
+	return nil
+}
+
+// WriteMetrics writes the metrics of workers and composes in the Prometheus
+// text format: the number of idle and busy workers by architecture, the
+// number of jobs waiting for a worker by type, and the number and duration
+// of the composes which finished since composer started, by distribution
+// and image type.
+func (s *Server) WriteMetrics(w io.Writer) error {
+	pending := s.jobs.PendingJobs()
+
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+
+	lines := []string{
+		"# HELP osbuild_composer_workers Workers which are connected to composer, by architecture and whether they are running a job.",
+		"# TYPE osbuild_composer_workers gauge",
+	}
+	busyWorkers := make(map[string]int)
+	for _, arch := range s.metrics.busyArches {
+		busyWorkers[arch]++
+	}
+	for _, arch := range sortedKeys(s.metrics.idleWorkers) {
+		lines = append(lines, fmt.Sprintf("osbuild_composer_workers{arch=%q,state=\"idle\"} %d", arch, s.metrics.idleWorkers[arch]))
+	}
+	for _, arch := range sortedKeys(busyWorkers) {
+		lines = append(lines, fmt.Sprintf("osbuild_composer_workers{arch=%q,state=\"busy\"} %d", arch, busyWorkers[arch]))
+	}
+
+	lines = append(lines,
+		"# HELP osbuild_composer_pending_jobs Jobs waiting for a worker, by type.",
+		"# TYPE osbuild_composer_pending_jobs gauge")
+	for _, jobType := range sortedKeys(pending) {
+		lines = append(lines, fmt.Sprintf("osbuild_composer_pending_jobs{type=%q} %d", jobType, pending[jobType]))
+	}
+
+	var labels []composeLabels
+	for l := range s.metrics.composes {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].Distro != labels[j].Distro {
+			return labels[i].Distro < labels[j].Distro
+		}
+		return labels[i].ImageType < labels[j].ImageType
+	})
+
+	lines = append(lines,
+		"# HELP osbuild_composer_composes_total Composes which finished, by distribution, image type and result.",
+		"# TYPE osbuild_composer_composes_total counter")
+	for _, l := range labels {
+		c := s.metrics.composes[l]
+		lines = append(lines,
+			fmt.Sprintf("osbuild_composer_composes_total{distro=%q,image_type=%q,result=\"success\"} %d", l.Distro, l.ImageType, c.successes),
+			fmt.Sprintf("osbuild_composer_composes_total{distro=%q,image_type=%q,result=\"failure\"} %d", l.Distro, l.ImageType, c.failures))
+	}
+	lines = append(lines,
+		"# HELP osbuild_composer_compose_duration_seconds Time finished composes were running on a worker, by distribution and image type.",
+		"# TYPE osbuild_composer_compose_duration_seconds summary")
+	for _, l := range labels {
+		c := s.metrics.composes[l]
+		lines = append(lines,
+			fmt.Sprintf("osbuild_composer_compose_duration_seconds_sum{distro=%q,image_type=%q} %g", l.Distro, l.ImageType, c.duration.Seconds()),
+			fmt.Sprintf("osbuild_composer_compose_duration_seconds_count{distro=%q,image_type=%q} %d", l.Distro, l.ImageType, c.successes+c.failures))
+	}
+
+	for _, line := range lines {
+		_, err := fmt.Fprintln(w, line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -11,6 +11,8 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,6 +20,8 @@ import (
 	"github.com/labstack/echo/v4"
 
 	"github.com/osbuild/osbuild-composer/internal/jobqueue"
+	"github.com/osbuild/osbuild-composer/internal/target"
+	"github.com/osbuild/osbuild-composer/internal/webhook"
 	"github.com/osbuild/osbuild-composer/internal/worker/api"
 )
 
@@ -37,6 +41,20 @@ type Server struct {
 	// reported as done.
 	running      map[uuid.UUID]uuid.UUID
 	runningMutex sync.Mutex
+
+	// The upload progress of running jobs by job id, guarded by
+	// runningMutex
+	progress map[uuid.UUID]UploadProgress
+
+	metrics *metrics
+
+	// webhooks are notified when image builds start and finish
+	webhooks *webhook.Notifier
+
+	// changed is closed and replaced whenever a job starts, reports
+	// progress, finishes, or is canceled
+	changed      chan struct{}
+	changedMutex sync.Mutex
 }
 
 type JobStatus struct {
@@ -54,6 +72,55 @@ func NewServer(logger *log.Logger, jobs jobqueue.JobQueue, artifactsDir string)
 		logger:       logger,
 		artifactsDir: artifactsDir,
 		running:      make(map[uuid.UUID]uuid.UUID),
+		progress:     make(map[uuid.UUID]UploadProgress),
+		metrics:      newMetrics(),
+		changed:      make(chan struct{}),
+	}
+}
+
+// Changed returns a channel which is closed the next time a job starts,
+// reports progress, finishes, or is canceled. Callers which watch jobs must
+// get the channel before they check the status of the jobs, so that they do
+// not miss any changes.
+func (s *Server) Changed() <-chan struct{} {
+	s.changedMutex.Lock()
+	defer s.changedMutex.Unlock()
+	return s.changed
+}
+
+func (s *Server) signalChange() {
+	s.changedMutex.Lock()
+	defer s.changedMutex.Unlock()
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// SetWebhooks sets the webhooks which are notified when jobs which build
+// images start, finish, or fail. It must be called before the server is used.
+func (s *Server) SetWebhooks(webhooks *webhook.Notifier) {
+	s.webhooks = webhooks
+}
+
+func (s *Server) notify(eventType string, id uuid.UUID, jobType string, labels composeLabels) {
+	s.webhooks.Notify(webhook.Event{
+		Type:      eventType,
+		ID:        id,
+		JobType:   strings.SplitN(jobType, ":", 2)[0],
+		Distro:    labels.Distro,
+		ImageType: labels.ImageType,
+		Time:      time.Now(),
+	})
+}
+
+// notifyJob notifies the webhooks about a job if it builds an image
+func (s *Server) notifyJob(eventType string, id uuid.UUID, jobType string, rawArgs json.RawMessage) {
+	labels, ok, err := composeLabelsOf(jobType, rawArgs)
+	if err != nil {
+		log.Printf("Error notifying webhooks about job %s: %v", id, err)
+		return
+	}
+	if ok {
+		s.notify(eventType, id, jobType, labels)
 	}
 }
 
@@ -76,20 +143,22 @@ func (s *Server) Handler() http.Handler {
 	return e
 }
 
-func (s *Server) EnqueueOSBuild(arch string, job *OSBuildJob) (uuid.UUID, error) {
-	return s.jobs.Enqueue("osbuild:"+arch, job, nil)
+// EnqueueOSBuild queues an osbuild job in channel, which is the channel of a
+// tenant, or the default channel if it is empty
+func (s *Server) EnqueueOSBuild(arch string, job *OSBuildJob, channel string) (uuid.UUID, error) {
+	return s.jobs.Enqueue("osbuild:"+arch, job, nil, channel)
 }
 
-func (s *Server) EnqueueOSBuildKoji(arch string, job *OSBuildKojiJob, initID uuid.UUID) (uuid.UUID, error) {
-	return s.jobs.Enqueue("osbuild-koji:"+arch, job, []uuid.UUID{initID})
+func (s *Server) EnqueueOSBuildKoji(arch string, job *OSBuildKojiJob, initID uuid.UUID, channel string) (uuid.UUID, error) {
+	return s.jobs.Enqueue("osbuild-koji:"+arch, job, []uuid.UUID{initID}, channel)
 }
 
-func (s *Server) EnqueueKojiInit(job *KojiInitJob) (uuid.UUID, error) {
-	return s.jobs.Enqueue("koji-init", job, nil)
+func (s *Server) EnqueueKojiInit(job *KojiInitJob, channel string) (uuid.UUID, error) {
+	return s.jobs.Enqueue("koji-init", job, nil, channel)
 }
 
-func (s *Server) EnqueueKojiFinalize(job *KojiFinalizeJob, initID uuid.UUID, buildIDs []uuid.UUID) (uuid.UUID, error) {
-	return s.jobs.Enqueue("koji-finalize", job, append([]uuid.UUID{initID}, buildIDs...))
+func (s *Server) EnqueueKojiFinalize(job *KojiFinalizeJob, initID uuid.UUID, buildIDs []uuid.UUID, channel string) (uuid.UUID, error) {
+	return s.jobs.Enqueue("koji-finalize", job, append([]uuid.UUID{initID}, buildIDs...), channel)
 }
 
 func (s *Server) JobStatus(id uuid.UUID, result interface{}) (*JobStatus, []uuid.UUID, error) {
@@ -121,9 +190,15 @@ func (s *Server) JobStatus(id uuid.UUID, result interface{}) (*JobStatus, []uuid
 	}, deps, nil
 }
 
+// JobChannel returns the channel a job was queued in
+func (s *Server) JobChannel(id uuid.UUID) (string, error) {
+	_, _, _, channel, err := s.jobs.Job(id)
+	return channel, err
+}
+
 // Job provides access to all the parameters of a job.
 func (s *Server) Job(id uuid.UUID, job interface{}) (string, json.RawMessage, []uuid.UUID, error) {
-	jobType, rawArgs, deps, err := s.jobs.Job(id)
+	jobType, rawArgs, deps, _, err := s.jobs.Job(id)
 	if err != nil {
 		return "", nil, nil, err
 	}
@@ -136,7 +211,26 @@ func (s *Server) Job(id uuid.UUID, job interface{}) (string, json.RawMessage, []
 }
 
 func (s *Server) Cancel(id uuid.UUID) error {
-	return s.jobs.CancelJob(id)
+	_, _, _, finished, canceled, _, err := s.jobs.JobStatus(id)
+	if err != nil {
+		return err
+	}
+
+	err = s.jobs.CancelJob(id)
+	if err != nil {
+		return err
+	}
+
+	if finished.IsZero() && !canceled {
+		s.signalChange()
+		jobType, rawArgs, _, _, err := s.jobs.Job(id)
+		if err != nil {
+			log.Printf("Error notifying webhooks about job %s: %v", id, err)
+			return nil
+		}
+		s.notifyJob(webhook.ComposeFailed, id, jobType, rawArgs)
+	}
+	return nil
 }
 
 // Provides access to artifacts of a job. Returns an io.Reader for the artifact
@@ -187,7 +281,32 @@ func (s *Server) DeleteArtifacts(id uuid.UUID) error {
 	return os.RemoveAll(path.Join(s.artifactsDir, id.String()))
 }
 
-func (s *Server) RequestJob(ctx context.Context, arch string, jobTypes []string) (uuid.UUID, uuid.UUID, string, json.RawMessage, []json.RawMessage, error) {
+// ArtifactsSize returns the total size of the artifacts of job `id`, which is
+// 0 for jobs without artifacts.
+func (s *Server) ArtifactsSize(id uuid.UUID) (int64, error) {
+	if s.artifactsDir == "" {
+		return 0, errors.New("Artifacts not enabled")
+	}
+
+	var size int64
+	err := filepath.Walk(path.Join(s.artifactsDir, id.String()), func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return size, err
+}
+
+// RequestJob dequeues a job with one of jobTypes for a worker on arch, which
+// takes jobs from channels, or from all channels if it is empty
+func (s *Server) RequestJob(ctx context.Context, arch string, jobTypes []string, channels []string) (uuid.UUID, uuid.UUID, string, json.RawMessage, []json.RawMessage, error) {
 	token := uuid.New()
 
 	// treat osbuild jobs specially until we have found a generic way to
@@ -201,7 +320,9 @@ func (s *Server) RequestJob(ctx context.Context, arch string, jobTypes []string)
 		jts = append(jts, t)
 	}
 
-	jobId, depIDs, jobType, args, err := s.jobs.Dequeue(ctx, jts)
+	s.metrics.workerWaiting(arch, 1)
+	jobId, depIDs, jobType, args, err := s.jobs.Dequeue(ctx, jts, channels)
+	s.metrics.workerWaiting(arch, -1)
 	if err != nil {
 		return uuid.Nil, uuid.Nil, "", nil, nil, err
 	}
@@ -222,6 +343,9 @@ func (s *Server) RequestJob(ctx context.Context, arch string, jobTypes []string)
 	s.runningMutex.Lock()
 	defer s.runningMutex.Unlock()
 	s.running[token] = jobId
+	s.metrics.jobStarted(token, arch)
+	s.notifyJob(webhook.ComposeStarted, jobId, jobType, args)
+	s.signalChange()
 
 	if jobType == "osbuild:"+arch {
 		jobType = "osbuild"
@@ -244,6 +368,80 @@ func (s *Server) RunningJob(token uuid.UUID) (uuid.UUID, error) {
 	return jobId, nil
 }
 
+// UpdateProgress records the upload progress of the running job of a token
+func (s *Server) UpdateProgress(token uuid.UUID, progress UploadProgress) error {
+	s.runningMutex.Lock()
+	defer s.runningMutex.Unlock()
+
+	jobId, ok := s.running[token]
+	if !ok {
+		return ErrTokenNotExist
+	}
+
+	s.progress[jobId] = progress
+	s.signalChange()
+	return nil
+}
+
+// JobProgress returns the upload progress a worker last reported for a job,
+// or nil if the job is not running or has not uploaded anything yet
+func (s *Server) JobProgress(id uuid.UUID) *UploadProgress {
+	s.runningMutex.Lock()
+	defer s.runningMutex.Unlock()
+
+	progress, ok := s.progress[id]
+	if !ok {
+		return nil
+	}
+	return &progress
+}
+
+// TargetStatuses returns the state of each target of an osbuild job. The
+// targets of finished jobs have the states the worker reported. Until then,
+// they are pending, except for the target the worker is uploading to.
+func TargetStatuses(targets []*target.Target, status *JobStatus, result *OSBuildJobResult, progress *UploadProgress) []TargetStatus {
+	statuses := make([]TargetStatus, len(targets))
+	for i, t := range targets {
+		statuses[i] = TargetStatus{Target: t.Uuid, State: TargetPending}
+	}
+
+	if status.Finished.IsZero() && !status.Canceled {
+		if progress != nil {
+			for i, t := range targets {
+				if progress.TargetID == t.Uuid || (progress.TargetID == uuid.Nil && progress.Target == t.Name) {
+					statuses[i].State = TargetRunning
+					break
+				}
+			}
+		}
+		return statuses
+	}
+
+	reported := make(map[uuid.UUID]TargetStatus)
+	for _, s := range result.TargetStatuses {
+		reported[s.Target] = s
+	}
+	for i, t := range targets {
+		if s, ok := reported[t.Uuid]; ok {
+			statuses[i] = s
+			continue
+		}
+		// canceled jobs and results of older workers, which only tell
+		// whether all targets succeeded
+		statuses[i].State = TargetFailure
+		if status.Canceled {
+			statuses[i].Error = "the job was canceled"
+		} else if result.Success {
+			statuses[i].State = TargetSuccess
+		} else if len(result.TargetErrors) > 0 {
+			statuses[i].Error = strings.Join(result.TargetErrors, "; ")
+		} else {
+			statuses[i].Error = "the image build failed"
+		}
+	}
+	return statuses
+}
+
 func (s *Server) FinishJob(token uuid.UUID, result json.RawMessage) error {
 	s.runningMutex.Lock()
 	defer s.runningMutex.Unlock()
@@ -256,11 +454,19 @@ func (s *Server) FinishJob(token uuid.UUID, result json.RawMessage) error {
 	// Always delete the running job, even if there are errors finishing
 	// the job, because callers won't call this a second time on error.
 	delete(s.running, token)
+	delete(s.progress, jobId)
+	s.metrics.jobFinished(token)
 
 	err := s.jobs.FinishJob(jobId, result)
 	if err != nil {
 		return fmt.Errorf("error finishing job: %v", err)
 	}
+	s.signalChange()
+
+	err = s.recordCompose(jobId)
+	if err != nil {
+		log.Printf("Error recording the metrics of job %s: %v", jobId, err)
+	}
 
 	// Move artifacts from the temporary location to the final job
 	// location. Log any errors, but do not treat them as fatal. The job is
@@ -295,7 +501,12 @@ func (h *apiHandlers) RequestJob(ctx echo.Context) error {
 		return err
 	}
 
-	token, jobId, jobType, jobArgs, dynamicJobArgs, err := h.server.RequestJob(ctx.Request().Context(), body.Arch, body.Types)
+	var channels []string
+	if body.Channels != nil {
+		channels = *body.Channels
+	}
+
+	token, jobId, jobType, jobArgs, dynamicJobArgs, err := h.server.RequestJob(ctx.Request().Context(), body.Arch, body.Types, channels)
 	if err != nil {
 		return err
 	}
@@ -365,6 +576,31 @@ func (h *apiHandlers) UpdateJob(ctx echo.Context, idstr string) error {
 	return ctx.JSON(http.StatusOK, updateJobResponse{})
 }
 
+func (h *apiHandlers) UpdateJobProgress(ctx echo.Context, tokenstr string) error {
+	token, err := uuid.Parse(tokenstr)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "cannot parse job token")
+	}
+
+	var body UploadProgress
+	err = ctx.Bind(&body)
+	if err != nil {
+		return err
+	}
+
+	err = h.server.UpdateProgress(token, body)
+	if err != nil {
+		switch err {
+		case ErrTokenNotExist:
+			return echo.NewHTTPError(http.StatusNotFound, "not found")
+		default:
+			return err
+		}
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
 func (h *apiHandlers) UploadJobArtifact(ctx echo.Context, tokenstr string, name string) error {
 	token, err := uuid.Parse(tokenstr)
 	if err != nil {
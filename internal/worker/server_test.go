@@ -2,11 +2,15 @@ package worker_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
@@ -14,7 +18,9 @@ import (
 	"github.com/osbuild/osbuild-composer/internal/distro"
 	"github.com/osbuild/osbuild-composer/internal/distro/fedoratest"
 	"github.com/osbuild/osbuild-composer/internal/jobqueue/fsjobqueue"
+	"github.com/osbuild/osbuild-composer/internal/target"
 	"github.com/osbuild/osbuild-composer/internal/test"
+	"github.com/osbuild/osbuild-composer/internal/webhook"
 	"github.com/osbuild/osbuild-composer/internal/worker"
 )
 
@@ -90,7 +96,7 @@ func TestCreate(t *testing.T) {
 	server := newTestServer(t, tempdir)
 	handler := server.Handler()
 
-	_, err = server.EnqueueOSBuild(arch.Name(), &worker.OSBuildJob{Manifest: manifest})
+	_, err = server.EnqueueOSBuild(arch.Name(), &worker.OSBuildJob{Manifest: manifest}, "")
 	require.NoError(t, err)
 
 	test.TestRoute(t, handler, false, "POST", "/api/worker/v1/jobs", `{"types":["osbuild"],"arch":"x86_64"}`, http.StatusCreated,
@@ -118,10 +124,10 @@ func TestCancel(t *testing.T) {
 	server := newTestServer(t, tempdir)
 	handler := server.Handler()
 
-	jobId, err := server.EnqueueOSBuild(arch.Name(), &worker.OSBuildJob{Manifest: manifest})
+	jobId, err := server.EnqueueOSBuild(arch.Name(), &worker.OSBuildJob{Manifest: manifest}, "")
 	require.NoError(t, err)
 
-	token, j, typ, args, dynamicArgs, err := server.RequestJob(context.Background(), arch.Name(), []string{"osbuild"})
+	token, j, typ, args, dynamicArgs, err := server.RequestJob(context.Background(), arch.Name(), []string{"osbuild"}, nil)
 	require.NoError(t, err)
 	require.Equal(t, jobId, j)
 	require.Equal(t, "osbuild", typ)
@@ -159,10 +165,10 @@ func TestUpdate(t *testing.T) {
 	server := newTestServer(t, tempdir)
 	handler := server.Handler()
 
-	jobId, err := server.EnqueueOSBuild(arch.Name(), &worker.OSBuildJob{Manifest: manifest})
+	jobId, err := server.EnqueueOSBuild(arch.Name(), &worker.OSBuildJob{Manifest: manifest}, "")
 	require.NoError(t, err)
 
-	token, j, typ, args, dynamicArgs, err := server.RequestJob(context.Background(), arch.Name(), []string{"osbuild"})
+	token, j, typ, args, dynamicArgs, err := server.RequestJob(context.Background(), arch.Name(), []string{"osbuild"}, nil)
 	require.NoError(t, err)
 	require.Equal(t, jobId, j)
 	require.Equal(t, "osbuild", typ)
@@ -173,6 +179,108 @@ func TestUpdate(t *testing.T) {
 	test.TestRoute(t, handler, false, "PATCH", fmt.Sprintf("/api/worker/v1/jobs/%s", token), `{}`, http.StatusNotFound, `*`)
 }
 
+func TestProgress(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "worker-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	distroStruct := fedoratest.New()
+	arch, err := distroStruct.GetArch("x86_64")
+	require.NoError(t, err)
+	server := newTestServer(t, tempdir)
+	handler := server.Handler()
+
+	jobId, err := server.EnqueueOSBuild(arch.Name(), &worker.OSBuildJob{}, "")
+	require.NoError(t, err)
+	require.Nil(t, server.JobProgress(jobId))
+
+	token, _, _, _, _, err := server.RequestJob(context.Background(), arch.Name(), []string{"osbuild"}, nil)
+	require.NoError(t, err)
+
+	test.TestRoute(t, handler, false, "PUT", fmt.Sprintf("/api/worker/v1/jobs/%s/progress", token), `{"target": "org.osbuild.aws", "transferred": 430, "total": 1000}`, http.StatusOK, "?")
+	require.Equal(t, &worker.UploadProgress{Target: "org.osbuild.aws", Transferred: 430, Total: 1000}, server.JobProgress(jobId))
+
+	targetID := uuid.MustParse("10000000-0000-0000-0000-000000000000")
+	test.TestRoute(t, handler, false, "PUT", fmt.Sprintf("/api/worker/v1/jobs/%s/progress", token), `{"target": "org.osbuild.aws", "target_id": "10000000-0000-0000-0000-000000000000", "transferred": 500, "total": 1000}`, http.StatusOK, "?")
+	require.Equal(t, &worker.UploadProgress{Target: "org.osbuild.aws", TargetID: targetID, Transferred: 500, Total: 1000}, server.JobProgress(jobId))
+
+	test.TestRoute(t, handler, false, "PATCH", fmt.Sprintf("/api/worker/v1/jobs/%s", token), `{}`, http.StatusOK, `{}`)
+	require.Nil(t, server.JobProgress(jobId))
+	test.TestRoute(t, handler, false, "PUT", fmt.Sprintf("/api/worker/v1/jobs/%s/progress", token), `{"target": "org.osbuild.aws", "transferred": 1000, "total": 1000}`, http.StatusNotFound, `*`)
+}
+
+func TestTargetResults(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "worker-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	arch, err := fedoratest.New().GetArch("x86_64")
+	require.NoError(t, err)
+	server := newTestServer(t, tempdir)
+	handler := server.Handler()
+
+	jobId, err := server.EnqueueOSBuild(arch.Name(), &worker.OSBuildJob{}, "")
+	require.NoError(t, err)
+	token, _, _, _, _, err := server.RequestJob(context.Background(), arch.Name(), []string{"osbuild"}, nil)
+	require.NoError(t, err)
+
+	test.TestRoute(t, handler, false, "PATCH", fmt.Sprintf("/api/worker/v1/jobs/%s", token),
+		`{"result": {"success": true, "upload_status": "success", "target_results": [{"name": "org.osbuild.aws", "options": {"ami": "ami-0c830793775595d4b", "region": "eu-west-1"}}]}}`,
+		http.StatusOK, `{}`)
+
+	var result worker.OSBuildJobResult
+	_, _, err = server.JobStatus(jobId, &result)
+	require.NoError(t, err)
+	require.Equal(t, []*target.TargetResult{
+		target.NewAWSTargetResult(&target.AWSTargetResultOptions{Ami: "ami-0c830793775595d4b", Region: "eu-west-1"}),
+	}, result.TargetResults)
+}
+
+func TestTargetStatuses(t *testing.T) {
+	aws := &target.Target{Uuid: uuid.MustParse("10000000-0000-0000-0000-000000000000"), Name: "org.osbuild.aws"}
+	azure := &target.Target{Uuid: uuid.MustParse("20000000-0000-0000-0000-000000000000"), Name: "org.osbuild.azure"}
+	targets := []*target.Target{aws, azure}
+
+	queued := &worker.JobStatus{Queued: time.Now()}
+	require.Equal(t, []worker.TargetStatus{
+		{Target: aws.Uuid, State: worker.TargetPending},
+		{Target: azure.Uuid, State: worker.TargetPending},
+	}, worker.TargetStatuses(targets, queued, &worker.OSBuildJobResult{}, nil))
+
+	running := &worker.JobStatus{Queued: time.Now(), Started: time.Now()}
+	require.Equal(t, []worker.TargetStatus{
+		{Target: aws.Uuid, State: worker.TargetPending},
+		{Target: azure.Uuid, State: worker.TargetRunning},
+	}, worker.TargetStatuses(targets, running, &worker.OSBuildJobResult{}, &worker.UploadProgress{Target: "org.osbuild.aws", TargetID: azure.Uuid}))
+	require.Equal(t, []worker.TargetStatus{
+		{Target: aws.Uuid, State: worker.TargetRunning},
+		{Target: azure.Uuid, State: worker.TargetPending},
+	}, worker.TargetStatuses(targets, running, &worker.OSBuildJobResult{}, &worker.UploadProgress{Target: "org.osbuild.aws"}))
+
+	finished := &worker.JobStatus{Queued: time.Now(), Started: time.Now(), Finished: time.Now()}
+	reported := []worker.TargetStatus{
+		{Target: aws.Uuid, State: worker.TargetSuccess},
+		{Target: azure.Uuid, State: worker.TargetFailure, Error: "access denied"},
+	}
+	require.Equal(t, reported, worker.TargetStatuses(targets, finished, &worker.OSBuildJobResult{TargetStatuses: reported}, nil))
+
+	// results of older workers only tell whether all targets succeeded
+	require.Equal(t, []worker.TargetStatus{
+		{Target: aws.Uuid, State: worker.TargetSuccess},
+		{Target: azure.Uuid, State: worker.TargetSuccess},
+	}, worker.TargetStatuses(targets, finished, &worker.OSBuildJobResult{Success: true}, nil))
+	require.Equal(t, []worker.TargetStatus{
+		{Target: aws.Uuid, State: worker.TargetFailure, Error: "access denied"},
+		{Target: azure.Uuid, State: worker.TargetFailure, Error: "access denied"},
+	}, worker.TargetStatuses(targets, finished, &worker.OSBuildJobResult{TargetErrors: []string{"access denied"}}, nil))
+
+	canceled := &worker.JobStatus{Queued: time.Now(), Canceled: true}
+	require.Equal(t, []worker.TargetStatus{
+		{Target: aws.Uuid, State: worker.TargetFailure, Error: "the job was canceled"},
+		{Target: azure.Uuid, State: worker.TargetFailure, Error: "the job was canceled"},
+	}, worker.TargetStatuses(targets, canceled, &worker.OSBuildJobResult{}, nil))
+}
+
 func TestArgs(t *testing.T) {
 	distroStruct := fedoratest.New()
 	arch, err := distroStruct.GetArch("x86_64")
@@ -191,10 +299,10 @@ func TestArgs(t *testing.T) {
 		Manifest:  manifest,
 		ImageName: "test-image",
 	}
-	jobId, err := server.EnqueueOSBuild(arch.Name(), &job)
+	jobId, err := server.EnqueueOSBuild(arch.Name(), &job, "")
 	require.NoError(t, err)
 
-	_, _, _, args, _, err := server.RequestJob(context.Background(), arch.Name(), []string{"osbuild"})
+	_, _, _, args, _, err := server.RequestJob(context.Background(), arch.Name(), []string{"osbuild"}, nil)
 	require.NoError(t, err)
 	require.NotNil(t, args)
 
@@ -228,10 +336,10 @@ func TestUpload(t *testing.T) {
 	server := newTestServer(t, tempdir)
 	handler := server.Handler()
 
-	jobID, err := server.EnqueueOSBuild(arch.Name(), &worker.OSBuildJob{Manifest: manifest})
+	jobID, err := server.EnqueueOSBuild(arch.Name(), &worker.OSBuildJob{Manifest: manifest}, "")
 	require.NoError(t, err)
 
-	token, j, typ, args, dynamicArgs, err := server.RequestJob(context.Background(), arch.Name(), []string{"osbuild"})
+	token, j, typ, args, dynamicArgs, err := server.RequestJob(context.Background(), arch.Name(), []string{"osbuild"}, nil)
 	require.NoError(t, err)
 	require.Equal(t, jobID, j)
 	require.Equal(t, "osbuild", typ)
@@ -240,3 +348,147 @@ func TestUpload(t *testing.T) {
 
 	test.TestRoute(t, handler, false, "PUT", fmt.Sprintf("/api/worker/v1/jobs/%s/artifacts/foobar", token), `this is my artifact`, http.StatusOK, `?`)
 }
+
+func TestMetrics(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "worker-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	server := newTestServer(t, tempdir)
+	handler := server.Handler()
+
+	for i := 0; i < 3; i++ {
+		_, err = server.EnqueueOSBuild("x86_64", &worker.OSBuildJob{Distro: "fedora-33", ImageType: "qcow2"}, "")
+		require.NoError(t, err)
+	}
+	_, err = server.EnqueueOSBuild("aarch64", &worker.OSBuildJob{Distro: "fedora-33", ImageType: "ami"}, "")
+	require.NoError(t, err)
+
+	var tokens []uuid.UUID
+	for i := 0; i < 2; i++ {
+		token, _, _, _, _, err := server.RequestJob(context.Background(), "x86_64", []string{"osbuild"}, nil)
+		require.NoError(t, err)
+		tokens = append(tokens, token)
+	}
+	test.TestRoute(t, handler, false, "PATCH", fmt.Sprintf("/api/worker/v1/jobs/%s", tokens[0]), `{"result": {"success": true}}`, http.StatusOK, `{}`)
+
+	// a worker waiting for a job
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	waiting := make(chan struct{})
+	go func() {
+		_, _, _, _, _, _ = server.RequestJob(ctx, "s390x", []string{"osbuild"}, nil)
+		close(waiting)
+	}()
+
+	expected := `# HELP osbuild_composer_workers Workers which are connected to composer, by architecture and whether they are running a job.
+# TYPE osbuild_composer_workers gauge
+osbuild_composer_workers{arch="s390x",state="idle"} 1
+osbuild_composer_workers{arch="x86_64",state="idle"} 0
+osbuild_composer_workers{arch="x86_64",state="busy"} 1
+# HELP osbuild_composer_pending_jobs Jobs waiting for a worker, by type.
+# TYPE osbuild_composer_pending_jobs gauge
+osbuild_composer_pending_jobs{type="osbuild:aarch64"} 1
+osbuild_composer_pending_jobs{type="osbuild:x86_64"} 1
+# HELP osbuild_composer_composes_total Composes which finished, by distribution, image type and result.
+# TYPE osbuild_composer_composes_total counter
+osbuild_composer_composes_total{distro="fedora-33",image_type="qcow2",result="success"} 1
+osbuild_composer_composes_total{distro="fedora-33",image_type="qcow2",result="failure"} 0
+# HELP osbuild_composer_compose_duration_seconds Time finished composes were running on a worker, by distribution and image type.
+# TYPE osbuild_composer_compose_duration_seconds summary
+`
+	var metrics strings.Builder
+	require.Eventually(t, func() bool {
+		metrics.Reset()
+		require.NoError(t, server.WriteMetrics(&metrics))
+		return strings.HasPrefix(metrics.String(), expected)
+	}, 5*time.Second, 10*time.Millisecond, metrics.String())
+	require.Contains(t, metrics.String(), `osbuild_composer_compose_duration_seconds_count{distro="fedora-33",image_type="qcow2"} 1`)
+
+	cancel()
+	<-waiting
+}
+
+func TestWebhooks(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "worker-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	events := make(chan webhook.Event, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event webhook.Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events <- event
+	}))
+	defer receiver.Close()
+
+	notifier, err := webhook.NewNotifier([]webhook.Webhook{{URL: receiver.URL}}, nil)
+	require.NoError(t, err)
+
+	server := newTestServer(t, tempdir)
+	server.SetWebhooks(notifier)
+	handler := server.Handler()
+
+	receive := func() webhook.Event {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "the webhook was not called")
+			return webhook.Event{}
+		}
+	}
+
+	jobID, err := server.EnqueueOSBuild("x86_64", &worker.OSBuildJob{Distro: "fedora-33", ImageType: "qcow2"}, "")
+	require.NoError(t, err)
+	token, _, _, _, _, err := server.RequestJob(context.Background(), "x86_64", []string{"osbuild"}, nil)
+	require.NoError(t, err)
+
+	event := receive()
+	require.Equal(t, webhook.ComposeStarted, event.Type)
+	require.Equal(t, jobID, event.ID)
+	require.Equal(t, "osbuild", event.JobType)
+	require.Equal(t, "fedora-33", event.Distro)
+	require.Equal(t, "qcow2", event.ImageType)
+
+	test.TestRoute(t, handler, false, "PATCH", fmt.Sprintf("/api/worker/v1/jobs/%s", token), `{"result": {"success": false}}`, http.StatusOK, `{}`)
+	event = receive()
+	require.Equal(t, webhook.ComposeFailed, event.Type)
+	require.Equal(t, jobID, event.ID)
+
+	// canceling a finished job does not send another event
+	require.NoError(t, server.Cancel(jobID))
+
+	jobID, err = server.EnqueueOSBuild("x86_64", &worker.OSBuildJob{Distro: "fedora-33", ImageType: "ami"}, "")
+	require.NoError(t, err)
+	require.NoError(t, server.Cancel(jobID))
+	event = receive()
+	require.Equal(t, webhook.ComposeFailed, event.Type)
+	require.Equal(t, jobID, event.ID)
+	require.Equal(t, "ami", event.ImageType)
+
+	require.Len(t, events, 0)
+}
+
+func TestRequestJobFromChannels(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "worker-tests-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempdir)
+
+	server := newTestServer(t, tempdir)
+
+	jobID, err := server.EnqueueOSBuild("x86_64", &worker.OSBuildJob{}, "acme")
+	require.NoError(t, err)
+	channel, err := server.JobChannel(jobID)
+	require.NoError(t, err)
+	require.Equal(t, "acme", channel)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, _, _, _, err = server.RequestJob(ctx, "x86_64", []string{"osbuild"}, []string{""})
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	_, j, _, _, _, err := server.RequestJob(context.Background(), "x86_64", []string{"osbuild"}, []string{"", "acme"})
+	require.NoError(t, err)
+	require.Equal(t, jobID, j)
+}